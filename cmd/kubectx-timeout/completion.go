@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mrf/kubectx-timeout/internal"
+)
+
+// Shorthands for the completion table
+const (
+	completeAny      = internal.CompleteAny
+	completeFiles    = internal.CompleteFiles
+	completeContexts = internal.CompleteContexts
+	completeShells   = "bash zsh fish"
+	// integrationShells also have shell integration, but no completion
+	integrationShells = completeShells + " powershell"
+)
+
+// completionFlags builds a command's flags from "name" for a boolean flag
+// and "name=values" for one that takes a value
+func completionFlags(specs ...string) []internal.CompletionFlag {
+	flags := make([]internal.CompletionFlag, 0, len(specs))
+	for _, spec := range specs {
+		name, values, _ := strings.Cut(spec, "=")
+		flags = append(flags, internal.CompletionFlag{Name: name, Values: values})
+	}
+	return flags
+}
+
+// completionCommands describes every command and its flags for shell
+// completion. Keep it in step with the flag sets; TestCompletionFlags
+// checks that it is.
+var completionCommands = []internal.CompletionCommand{
+	{Name: "version", Description: "Show version information"},
+	{Name: "init", Description: "Initialize configuration file", Flags: completionFlags(
+		"config="+completeFiles, "default-context="+completeContexts, "preset=paranoid standard relaxed",
+		"timeout="+completeAny, "context="+completeAny, "force", "quiet")},
+	{Name: "migrate", Description: "Move config and state to the XDG directories", Flags: completionFlags("dry-run")},
+	{Name: "config", Description: "Validate, show, set, or edit the configuration", Subcommands: []internal.CompletionCommand{
+		{Name: "validate", Description: "Check the configuration and report every problem found",
+			Flags: completionFlags("skip-contexts"), Args: completeFiles},
+		{Name: "show", Description: "Print the effective configuration",
+			Flags: completionFlags("config="+completeFiles, "json")},
+		{Name: "set", Description: "Set one configuration key, keeping the file's comments",
+			Flags: completionFlags("config=" + completeFiles)},
+		{Name: "edit", Description: "Edit the configuration in $EDITOR",
+			Flags: completionFlags("config=" + completeFiles)},
+		{Name: "schema", Description: "Print a JSON Schema of the configuration for editors"},
+	}},
+	{Name: "daemon", Description: "Run the timeout monitoring daemon (foreground)", Flags: completionFlags(
+		"config="+completeFiles, "state="+completeFiles, "foreground", "debug", "dry-run", "check-interval="+completeAny)},
+	{Name: "daemon-install", Description: "Install daemon as a service"},
+	{Name: "daemon-uninstall", Description: "Remove daemon service"},
+	{Name: "daemon-start", Description: "Start the daemon via the service manager"},
+	{Name: "daemon-stop", Description: "Stop the daemon via the service manager"},
+	{Name: "daemon-restart", Description: "Restart the daemon via the service manager"},
+	{Name: "daemon-status", Description: "Show daemon service status"},
+	{Name: "healthcheck", Description: "Exit 0 only if the daemon is healthy", Flags: completionFlags(
+		"state="+completeFiles, "max-age="+completeAny, "quiet")},
+	{Name: "status", Description: "Show daemon status and timeout information", Flags: completionFlags(
+		"state="+completeFiles, "config="+completeFiles)},
+	{Name: "start", Description: "Start the daemon in background (direct)"},
+	{Name: "stop", Description: "Stop the daemon (direct)"},
+	{Name: "reload", Description: "Reload daemon configuration"},
+	{Name: "contexts", Description: "List every context with its effective timeout", Flags: completionFlags(
+		"config="+completeFiles, "json")},
+	{Name: "why", Description: "Explain what the daemon would do right now", Flags: completionFlags(
+		"state="+completeFiles, "config="+completeFiles, "json")},
+	{Name: "simulate", Description: "Explain what the daemon would do after a context idles", Flags: completionFlags(
+		"state="+completeFiles, "config="+completeFiles, "context="+completeContexts, "idle="+completeAny,
+		"at="+completeAny, "processes="+completeAny, "json")},
+	{Name: "reset", Description: "Reset activity timer", Flags: completionFlags(
+		"state="+completeFiles, "config="+completeFiles)},
+	{Name: "extend", Description: "Suppress timeout switching for a duration", Flags: completionFlags(
+		"state=" + completeFiles)},
+	{Name: "pause-context", Description: "Suppress timeout switching away from one context", Flags: completionFlags(
+		"state="+completeFiles, "clear"), Args: completeContexts},
+	{Name: "cancel-switch", Description: "Cancel a switch waiting out the grace period", Flags: completionFlags(
+		"state=" + completeFiles)},
+	{Name: "switch-now", Description: "Switch to the default context now", Flags: completionFlags(
+		"state="+completeFiles, "config="+completeFiles)},
+	{Name: "undo", Description: "Switch back after an automatic switch", Flags: completionFlags(
+		"state="+completeFiles, "config="+completeFiles)},
+	{Name: "profile", Description: "List, use, or clear named profiles", Subcommands: []internal.CompletionCommand{
+		{Name: "list", Description: "List the configured profiles, marking the one in use",
+			Flags: completionFlags("state="+completeFiles, "config="+completeFiles)},
+		{Name: "use", Description: "Use a profile's timeouts, until cleared or for a duration",
+			Flags: completionFlags("state="+completeFiles, "config="+completeFiles, "for="+completeAny)},
+		{Name: "clear", Description: "Go back to the timeouts without a profile",
+			Flags: completionFlags("state=" + completeFiles)},
+	}},
+	{Name: "history", Description: "Show recorded activity, context changes, and switches", Flags: completionFlags(
+		"state="+completeFiles, "context="+completeContexts, "type=activity context_change switch",
+		"since="+completeAny, "until="+completeAny, "limit="+completeAny, "json"),
+		Subcommands: []internal.CompletionCommand{
+			{Name: "prune", Description: "Remove history beyond the retention limits", Flags: completionFlags(
+				"state="+completeFiles, "config="+completeFiles, "max-entries="+completeAny, "max-age="+completeAny)},
+		}},
+	{Name: "stats", Description: "Summarize per-context usage and switches", Flags: completionFlags(
+		"state="+completeFiles, "since="+completeAny, "until="+completeAny, "top="+completeAny, "json")},
+	{Name: "logs", Description: "Print or follow the daemon's log files", Flags: completionFlags(
+		"state="+completeFiles, "config="+completeFiles, "f", "follow", "n="+completeAny)},
+	{Name: "prompt", Description: "Print a short segment for a shell prompt", Flags: completionFlags(
+		"state="+completeFiles, "warn="+completeAny, "critical="+completeAny, "color=never ansi bash zsh", "left-below="+completeAny)},
+	{Name: "menubar", Description: "Show the context and time remaining in the macOS menu bar", Flags: completionFlags(
+		"state=" + completeFiles)},
+	{Name: "ui", Description: "Interactive dashboard of contexts and countdown", Flags: completionFlags(
+		"config="+completeFiles, "state="+completeFiles)},
+	{Name: "prune-contexts", Description: "Remove contexts whose credentials have expired", Flags: completionFlags(
+		"config="+completeFiles, "dry-run", "yes")},
+	{Name: "install-shell", Description: "Install shell integration (kubectl wrapper)", Flags: completionFlags(
+		"yes", "no-reload", "binary="+completeFiles, "detect", "config="+completeFiles, "mode=wrapper hook", "completion"),
+		Args: integrationShells},
+	{Name: "uninstall-shell", Description: "Remove shell integration", Flags: completionFlags("yes", "detect"),
+		Args: integrationShells},
+	{Name: "restore-profile", Description: "Restore a shell profile from one of its backups", Flags: completionFlags(
+		"config="+completeFiles, "list", "backup="+completeFiles, "yes"),
+		Args: integrationShells},
+	{Name: "uninstall", Description: "Complete uninstallation of kubectx-timeout", Flags: completionFlags(
+		"all", "keep-config", "keep-binary", "yes", "all-shells", "binary="+completeFiles)},
+	{Name: "completion", Description: "Print shell completion definitions", Args: completeShells},
+	{Name: "help", Description: "Show this help message"},
+}
+
+func cmdCompletion() {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	args := fs.Args()
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: kubectx-timeout completion <bash|zsh|fish>\n\n")
+		fmt.Fprintf(os.Stderr, "Load completions in the current shell:\n")
+		fmt.Fprintf(os.Stderr, "  source <(kubectx-timeout completion bash)\n")
+		fmt.Fprintf(os.Stderr, "  source <(kubectx-timeout completion zsh)\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout completion fish | source\n\n")
+		fmt.Fprintf(os.Stderr, "Or load them with the shell integration:\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout install-shell --completion <shell>\n")
+		os.Exit(exitUsage)
+	}
+
+	script, err := internal.GetCompletionScript(args[0], "kubectx-timeout", completionCommands)
+	if err != nil {
+		fatalf(exitUsage, "%v\nSupported shells: bash, zsh, fish", err)
+	}
+	fmt.Print(script)
+}
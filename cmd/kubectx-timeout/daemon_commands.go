@@ -2,11 +2,13 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/mrf/kubectx-timeout/internal"
 )
@@ -20,13 +22,14 @@ func cmdDaemonInstall() {
 		}
 	}
 
-	// Create launchd manager
-	manager, err := internal.NewLaunchdManager(defaultBinaryPath)
+	// Create service manager (launchd on macOS, systemd on Linux, Task
+	// Scheduler on Windows)
+	manager, err := internal.NewServiceManager(defaultBinaryPath)
 	if err != nil {
-		log.Fatalf("Failed to create launchd manager: %v", err)
+		fatalf(exitCodeFor(err), "Failed to create service manager: %v", err)
 	}
 
-	fmt.Println("Installing kubectx-timeout daemon with launchd")
+	fmt.Printf("Installing kubectx-timeout daemon with %s\n", manager.Name())
 	fmt.Printf("Binary path: %s\n", defaultBinaryPath)
 
 	// Confirm
@@ -34,7 +37,7 @@ func cmdDaemonInstall() {
 	reader := bufio.NewReader(os.Stdin)
 	response, err := reader.ReadString('\n')
 	if err != nil {
-		log.Fatalf("Failed to read input: %v", err)
+		fatalf(exitCodeFor(err), "Failed to read input: %v", err)
 	}
 	response = strings.TrimSpace(strings.ToLower(response))
 	if response != "y" && response != "yes" {
@@ -43,14 +46,20 @@ func cmdDaemonInstall() {
 	}
 
 	// Install
+	installStarted := time.Now()
 	if err := manager.Install(); err != nil {
-		log.Fatalf("Failed to install daemon: %v", err)
+		fatalf(exitCodeFor(err), "Failed to install daemon: %v", err)
 	}
 
-	fmt.Println("\n✓ Daemon plist installed successfully")
-	fmt.Println("\nNext steps:")
-	fmt.Println("  1. Start the daemon: kubectx-timeout daemon-start")
-	fmt.Println("  2. Check status: kubectx-timeout daemon-status")
+	fmt.Println("\n✓ Daemon service installed successfully")
+
+	// Verify the installation end to end and point at anything left to do
+	fmt.Println("\nWaiting for the daemon to start...")
+	checker, err := internal.NewOnboardingChecker(manager, installStarted)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to check installation: %v", err)
+	}
+	fmt.Print(internal.FormatOnboardingResult(checker.Run()))
 }
 
 func cmdDaemonUninstall() {
@@ -62,20 +71,21 @@ func cmdDaemonUninstall() {
 		}
 	}
 
-	// Create launchd manager
-	manager, err := internal.NewLaunchdManager(defaultBinaryPath)
+	// Create service manager (launchd on macOS, systemd on Linux, Task
+	// Scheduler on Windows)
+	manager, err := internal.NewServiceManager(defaultBinaryPath)
 	if err != nil {
-		log.Fatalf("Failed to create launchd manager: %v", err)
+		fatalf(exitCodeFor(err), "Failed to create service manager: %v", err)
 	}
 
-	fmt.Println("Uninstalling kubectx-timeout daemon from launchd")
+	fmt.Printf("Uninstalling kubectx-timeout daemon from %s\n", manager.Name())
 
 	// Confirm
 	fmt.Print("\nDo you want to proceed with the uninstallation? [y/N]: ")
 	reader := bufio.NewReader(os.Stdin)
 	response, err := reader.ReadString('\n')
 	if err != nil {
-		log.Fatalf("Failed to read input: %v", err)
+		fatalf(exitCodeFor(err), "Failed to read input: %v", err)
 	}
 	response = strings.TrimSpace(strings.ToLower(response))
 	if response != "y" && response != "yes" {
@@ -85,10 +95,10 @@ func cmdDaemonUninstall() {
 
 	// Uninstall
 	if err := manager.Uninstall(); err != nil {
-		log.Fatalf("Failed to uninstall daemon: %v", err)
+		fatalf(exitCodeFor(err), "Failed to uninstall daemon: %v", err)
 	}
 
-	fmt.Println("\n✓ Daemon plist uninstalled successfully")
+	fmt.Println("\n✓ Daemon service uninstalled successfully")
 }
 
 func cmdDaemonStart() {
@@ -100,16 +110,17 @@ func cmdDaemonStart() {
 		}
 	}
 
-	// Create launchd manager
-	manager, err := internal.NewLaunchdManager(defaultBinaryPath)
+	// Create service manager (launchd on macOS, systemd on Linux, Task
+	// Scheduler on Windows)
+	manager, err := internal.NewServiceManager(defaultBinaryPath)
 	if err != nil {
-		log.Fatalf("Failed to create launchd manager: %v", err)
+		fatalf(exitCodeFor(err), "Failed to create service manager: %v", err)
 	}
 
 	// Load daemon
 	fmt.Println("Starting kubectx-timeout daemon...")
 	if err := manager.Load(); err != nil {
-		log.Fatalf("Failed to start daemon: %v", err)
+		fatalf(exitCodeFor(err), "Failed to start daemon: %v", err)
 	}
 
 	fmt.Println("✓ Daemon started successfully")
@@ -125,16 +136,17 @@ func cmdDaemonStop() {
 		}
 	}
 
-	// Create launchd manager
-	manager, err := internal.NewLaunchdManager(defaultBinaryPath)
+	// Create service manager (launchd on macOS, systemd on Linux, Task
+	// Scheduler on Windows)
+	manager, err := internal.NewServiceManager(defaultBinaryPath)
 	if err != nil {
-		log.Fatalf("Failed to create launchd manager: %v", err)
+		fatalf(exitCodeFor(err), "Failed to create service manager: %v", err)
 	}
 
 	// Unload daemon
 	fmt.Println("Stopping kubectx-timeout daemon...")
 	if err := manager.Unload(); err != nil {
-		log.Fatalf("Failed to stop daemon: %v", err)
+		fatalf(exitCodeFor(err), "Failed to stop daemon: %v", err)
 	}
 
 	fmt.Println("✓ Daemon stopped successfully")
@@ -149,16 +161,17 @@ func cmdDaemonRestart() {
 		}
 	}
 
-	// Create launchd manager
-	manager, err := internal.NewLaunchdManager(defaultBinaryPath)
+	// Create service manager (launchd on macOS, systemd on Linux, Task
+	// Scheduler on Windows)
+	manager, err := internal.NewServiceManager(defaultBinaryPath)
 	if err != nil {
-		log.Fatalf("Failed to create launchd manager: %v", err)
+		fatalf(exitCodeFor(err), "Failed to create service manager: %v", err)
 	}
 
 	// Restart daemon
 	fmt.Println("Restarting kubectx-timeout daemon...")
 	if err := manager.Restart(); err != nil {
-		log.Fatalf("Failed to restart daemon: %v", err)
+		fatalf(exitCodeFor(err), "Failed to restart daemon: %v", err)
 	}
 
 	fmt.Println("✓ Daemon restarted successfully")
@@ -174,17 +187,65 @@ func cmdDaemonStatus() {
 		}
 	}
 
-	// Create launchd manager
-	manager, err := internal.NewLaunchdManager(defaultBinaryPath)
+	// Create service manager (launchd on macOS, systemd on Linux, Task
+	// Scheduler on Windows)
+	manager, err := internal.NewServiceManager(defaultBinaryPath)
 	if err != nil {
-		log.Fatalf("Failed to create launchd manager: %v", err)
+		fatalf(exitCodeFor(err), "Failed to create service manager: %v", err)
 	}
 
 	// Get status
 	status, err := manager.GetStatus()
 	if err != nil {
-		log.Fatalf("Failed to get daemon status: %v", err)
+		fatalf(exitCodeFor(err), "Failed to get daemon status: %v", err)
 	}
 
 	fmt.Print(status)
+
+	// The service manager only knows the process is up, not whether it works
+	pidFile := internal.NewPIDFile()
+	if !pidFile.IsRunning() {
+		return
+	}
+	heartbeatPath := internal.HeartbeatPathForState(internal.GetStatePath())
+	problems := internal.CheckHealth(pidFile, heartbeatPath, internal.DefaultHealthMaxAge, time.Now())
+	if len(problems) == 0 {
+		fmt.Println("Health: ✓ healthy")
+		return
+	}
+	fmt.Println("Health:")
+	for _, problem := range problems {
+		fmt.Printf("  ✗ %s\n", problem)
+	}
+}
+
+func cmdHealthcheck() {
+	defaultStatePath := internal.GetStatePath()
+
+	fs := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	maxAge := fs.Duration("max-age", internal.DefaultHealthMaxAge, "How old the daemon's heartbeat may be")
+	quiet := fs.Bool("quiet", false, "Print nothing, only set the exit status")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	pidFile := internal.NewPIDFile()
+	problems := internal.CheckHealth(pidFile, internal.HeartbeatPathForState(*statePath), *maxAge, time.Now())
+	if len(problems) == 0 {
+		if !*quiet {
+			fmt.Println("✓ Daemon is healthy")
+		}
+		return
+	}
+
+	if !*quiet {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "✗ %s\n", problem)
+		}
+	}
+	if !pidFile.IsRunning() {
+		os.Exit(exitDaemonNotRunning)
+	}
+	os.Exit(exitFailure)
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/mrf/kubectx-timeout/internal"
+)
+
+// Exit codes, so scripts and CI can branch on the type of failure instead
+// of parsing stderr. They are part of the command-line interface: don't
+// renumber them.
+const (
+	// exitFailure is any failure without a code of its own
+	exitFailure = 1
+	// exitUsage is an unknown command or bad flags or arguments, as the
+	// flag package reports them
+	exitUsage = 2
+	// exitConfig is a configuration that can't be read or isn't valid
+	exitConfig = 3
+	// exitDaemonNotRunning is a command that needs the daemon without it
+	exitDaemonNotRunning = 4
+	// exitAlreadyInstalled is an install of something that is already there
+	exitAlreadyInstalled = 5
+	// exitSwitchRefused is a switch the safety settings don't allow
+	exitSwitchRefused = 6
+	// exitKubectl is kubectl failing, timing out, or missing
+	exitKubectl = 7
+)
+
+// exitCodeFor returns the exit code for err's type of failure
+func exitCodeFor(err error) int {
+	switch {
+	case errors.Is(err, internal.ErrConfigInvalid):
+		return exitConfig
+	case errors.Is(err, internal.ErrControlUnavailable):
+		return exitDaemonNotRunning
+	case errors.Is(err, internal.ErrAlreadyInstalled):
+		return exitAlreadyInstalled
+	case errors.Is(err, internal.ErrSwitchRefused):
+		return exitSwitchRefused
+	case errors.Is(err, internal.ErrKubectlFailed):
+		return exitKubectl
+	}
+	return exitFailure
+}
+
+// fatalf logs a message like log.Fatalf, but exits with code
+func fatalf(code int, format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(code)
+}
@@ -2,17 +2,30 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/mrf/kubectx-timeout/internal"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -20,18 +33,30 @@ const (
 )
 
 func main() {
+	if isPlugin() {
+		pluginMain()
+		return
+	}
+
 	if len(os.Args) < 2 {
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
-	command := os.Args[1]
+	run(os.Args[1])
+}
 
+// run runs a command, whose flags and arguments follow it in os.Args
+func run(command string) {
 	switch command {
 	case "version":
 		cmdVersion()
 	case "init":
 		cmdInit()
+	case "migrate":
+		cmdMigrate()
+	case "config":
+		cmdConfig()
 	case "daemon":
 		cmdDaemon()
 	case "daemon-install":
@@ -46,6 +71,8 @@ func main() {
 		cmdDaemonRestart()
 	case "daemon-status":
 		cmdDaemonStatus()
+	case "healthcheck":
+		cmdHealthcheck()
 	case "start":
 		cmdStart()
 	case "stop":
@@ -54,22 +81,60 @@ func main() {
 		cmdStatus()
 	case "reload":
 		cmdReload()
+	case "contexts":
+		cmdContexts()
+	case "why":
+		cmdWhy()
+	case "simulate":
+		cmdSimulate()
 	case "reset":
 		cmdReset()
+	case "extend":
+		cmdExtend()
+	case "pause-context":
+		cmdPauseContext()
+	case "cancel-switch":
+		cmdCancelSwitch()
+	case "switch-now":
+		cmdSwitchNow()
+	case "undo":
+		cmdUndo()
+	case "profile":
+		cmdProfile()
+	case "history":
+		cmdHistory()
+	case "stats":
+		cmdStats()
+	case "logs":
+		cmdLogs()
+	case "prompt":
+		cmdPrompt()
+	case "menubar":
+		cmdMenubar()
+	case "ui":
+		cmdUI()
+	case "prune-contexts":
+		cmdPruneContexts()
 	case "install-shell":
 		cmdInstallShell()
 	case "uninstall-shell":
 		cmdUninstallShell()
+	case "restore-profile":
+		cmdRestoreProfile()
 	case "uninstall":
 		cmdUninstall()
 	case "record-activity":
 		cmdRecordActivity()
+	case "switch-notice":
+		cmdSwitchNotice()
+	case "completion":
+		cmdCompletion()
 	case "help", "-h", "--help":
 		printUsage()
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", command)
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -82,27 +147,76 @@ Usage:
 Commands:
   version              Show version information
   init                 Initialize configuration file
+  migrate              Move config and state from ~/.kubectx-timeout to the XDG directories
+  config validate [path]
+                       Check the configuration and report every problem found
+  config show          Print the effective configuration (defaults, file, environment)
+  config set <key> <value>
+                       Set one configuration key, keeping the file's comments
+  config edit          Edit the configuration in $EDITOR, validating it before saving
+  config schema        Print a JSON Schema of the configuration for editors
   daemon               Run the timeout monitoring daemon (foreground)
-  daemon-install       Install daemon as launchd service (macOS)
-  daemon-uninstall     Remove daemon launchd service
-  daemon-start         Start the daemon via launchd
-  daemon-stop          Stop the daemon via launchd
-  daemon-restart       Restart the daemon via launchd
-  daemon-status        Show daemon launchd status
+  daemon-install       Install daemon as a service (launchd, systemd, or Task Scheduler)
+  daemon-uninstall     Remove daemon service
+  daemon-start         Start the daemon via the service manager
+  daemon-stop          Stop the daemon via the service manager
+  daemon-restart       Restart the daemon via the service manager
+  daemon-status        Show daemon service status
+  healthcheck          Exit 0 only if the daemon is running, responsive, and its last check succeeded
   status               Show daemon status and timeout information
   start                Start the daemon in background (direct)
   stop                 Stop the daemon (direct)
   reload               Reload daemon configuration
+  contexts             List every context with its effective timeout and safety flags
+  why                  Explain what the daemon would do right now, and why
+  simulate --idle <duration>
+                       Explain what the daemon would do after a context idles, without switching
   reset                Reset activity timer
+  extend <duration>    Suppress timeout switching for a duration (e.g. 30m)
+  pause-context <name> <duration>
+                       Suppress timeout switching away from one context
+  cancel-switch        Cancel a switch waiting out the grace period
+  switch-now           Switch to the default context now, without waiting for the timeout
+  undo                 Switch back after an automatic switch, within timeout.undo_window
+  profile use <name> [--for <duration>]
+                       Use a named set of timeouts from profiles, until cleared or for a duration
+  profile list|clear   List the profiles, or go back to the timeouts without one
+  history              Show recorded activity, context changes, and switches
+  history prune        Remove history beyond history.max_entries and history.max_age
+  stats                Summarize per-context usage and switches from the history
+  logs [-f] [-n 100]   Print or follow the daemon's log files
+  prompt               Print a short segment like "⏱ prod 12m" for a shell prompt
+  menubar              Show the context and time remaining in the macOS menu bar
+  ui                   Interactive dashboard of contexts, countdown, and recent switches
+  prune-contexts       Remove contexts whose credentials have expired
   install-shell        Install shell integration (kubectl wrapper)
   uninstall-shell      Remove shell integration
+  restore-profile      Restore a shell profile from one of its backups
   uninstall            Complete uninstallation of kubectx-timeout
   record-activity      Record kubectl activity (used by shell integration)
+  switch-notice        Print an automatic switch not seen yet (used by shell integration)
+  completion <shell>   Print completion definitions for bash, zsh, or fish
   help                 Show this help message
 
 Examples:
   # Initialize configuration
   kubectx-timeout init
+  kubectx-timeout init --default-context docker-desktop --context prod=5m --quiet
+
+  # Move an old ~/.kubectx-timeout setup to ~/.config and ~/.local/state
+  kubectx-timeout migrate --dry-run
+  kubectx-timeout migrate
+
+  # Check the configuration, including that its contexts exist
+  kubectx-timeout config validate
+  kubectx-timeout config show --json
+
+  # Change the configuration; the daemon reloads it if running
+  kubectx-timeout config set timeout.default 45m
+  kubectx-timeout config edit
+
+  # Let editors validate and complete config.yaml
+  kubectx-timeout config schema > ~/.config/kubectx-timeout/config.schema.json
 
   # Detect your current shell
   kubectx-timeout install-shell --detect
@@ -111,25 +225,58 @@ Examples:
   kubectx-timeout install-shell bash
   kubectx-timeout install-shell zsh
   kubectx-timeout install-shell fish
+  kubectx-timeout install-shell powershell
+
+  # Track activity with preexec hooks instead of wrapping kubectl
+  # (keeps your own kubectl functions and aliases working)
+  kubectx-timeout install-shell --mode hook zsh
+
+  # Tab completion, loaded now or with the shell integration
+  source <(kubectx-timeout completion bash)
+  kubectx-timeout install-shell --completion zsh
 
   # Uninstall shell integration
   kubectx-timeout uninstall-shell bash
 
-  # Install daemon to run automatically via launchd (macOS)
+  # Restore a shell profile from a backup install-shell or uninstall-shell made
+  kubectx-timeout restore-profile --list zsh
+  kubectx-timeout restore-profile --backup 2 zsh
+
+  # Install daemon to run automatically (launchd on macOS, systemd on Linux,
+  # Task Scheduler on Windows)
   kubectx-timeout daemon-install
   kubectx-timeout daemon-start
   kubectx-timeout daemon-status
+  kubectx-timeout healthcheck || echo "daemon unhealthy"
 
-  # Direct daemon control (alternative to launchd)
+  # Direct daemon control (alternative to the service manager)
   kubectx-timeout start         # Start daemon in background
   kubectx-timeout status        # Check status and timeout info
   kubectx-timeout stop          # Stop daemon
   kubectx-timeout reload        # Reload configuration
+  kubectx-timeout contexts      # Check each context's timeout before trusting the policy
+  kubectx-timeout why --json    # Explain the switch decision as JSON
   kubectx-timeout reset         # Reset activity timer
+  kubectx-timeout extend 30m    # Don't switch for the next 30 minutes
+  kubectx-timeout pause-context prod-eu 4h  # Exempt only prod-eu for 4 hours
+  kubectx-timeout pause-context --clear prod-eu  # End the pause early
+  kubectx-timeout switch-now    # Switch to the default context before stepping away
+  kubectx-timeout undo          # Back to prod after being switched away mid-task
+  kubectx-timeout profile use oncall --for 8h  # On-call timeouts for the shift
+  kubectx-timeout history --since 24h --context prod-eu  # Review the last day in prod-eu
+  kubectx-timeout stats --since 720h  # Usage over the last 30 days
+  kubectx-timeout logs -f       # Follow the daemon's output
+  kubectx-timeout ui            # Dashboard with pause, extend, and switch keys
+  PS1='$(kubectx-timeout prompt --color bash) \$ '  # Time left in the bash prompt
+  kubectx-timeout simulate --context prod --idle 42m  # Would prod be switched away from?
+  kubectx-timeout prune-contexts --dry-run  # List contexts with expired credentials
 
   # Run daemon in foreground (for debugging)
   kubectx-timeout daemon
 
+  # Troubleshoot the policy: debug output, no switches, a check every 5s
+  kubectx-timeout daemon run --debug --dry-run --check-interval 5s
+
   # Complete uninstallation
   kubectx-timeout uninstall
 
@@ -147,6 +294,280 @@ func cmdVersion() {
 	fmt.Printf("kubectx-timeout version %s\n", version)
 }
 
+func cmdConfig() {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: kubectx-timeout config <validate|show|set|edit|schema> [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Subcommands:\n")
+		fmt.Fprintf(os.Stderr, "  validate [path]  Check the configuration and report every problem found\n")
+		fmt.Fprintf(os.Stderr, "  show             Print the effective configuration (defaults, file, environment)\n")
+		fmt.Fprintf(os.Stderr, "  set <key> <value>\n")
+		fmt.Fprintf(os.Stderr, "                   Set one key, such as timeout.default, keeping the file's comments\n")
+		fmt.Fprintf(os.Stderr, "  edit             Open the configuration in $EDITOR and validate it before saving\n")
+		fmt.Fprintf(os.Stderr, "  schema           Print a JSON Schema of the configuration for editors\n")
+		os.Exit(exitUsage)
+	}
+	if len(os.Args) < 3 {
+		usage()
+	}
+
+	switch os.Args[2] {
+	case "validate":
+		cmdConfigValidate(os.Args[3:])
+	case "show":
+		cmdConfigShow(os.Args[3:])
+	case "set":
+		cmdConfigSet(os.Args[3:])
+	case "edit":
+		cmdConfigEdit(os.Args[3:])
+	case "schema":
+		cmdConfigSchema(os.Args[3:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config subcommand: %s\n\n", os.Args[2])
+		usage()
+	}
+}
+
+func cmdConfigValidate(args []string) {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	skipContexts := fs.Bool("skip-contexts", false, "Don't check that the configured contexts exist in kubeconfig")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	configPath := internal.GetConfigPath()
+	if fs.NArg() > 0 {
+		configPath = fs.Arg(0)
+	}
+
+	fmt.Printf("Config file: %s\n", configPath)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		fmt.Println("\n✗ Config file not found")
+		fmt.Println("  Create one with: kubectx-timeout init")
+		os.Exit(exitConfig)
+	}
+
+	includes, err := internal.ConfigIncludes(configPath)
+	if err != nil {
+		fmt.Printf("\n✗ %v\n", err)
+		os.Exit(exitConfig)
+	}
+	for _, include := range includes {
+		fmt.Printf("Includes:    %s\n", include)
+	}
+
+	config, err := internal.ReadConfig(configPath)
+	if err != nil {
+		fmt.Printf("\n✗ %v\n", err)
+		os.Exit(exitConfig)
+	}
+	if policy := config.OrgPolicy(); policy != nil {
+		fmt.Printf("Policy:      %s\n", policy.Path())
+	}
+
+	problems := config.ValidationErrors()
+	if !*skipContexts {
+		contexts, err := internal.GetAvailableContexts()
+		if err != nil {
+			fmt.Printf("Warning: Skipping context checks, failed to list kubeconfig contexts: %v\n", err)
+		} else {
+			problems = append(problems, config.CheckContexts(contexts)...)
+		}
+	}
+
+	if len(problems) == 0 {
+		fmt.Println("\n✓ Configuration is valid")
+		return
+	}
+
+	fmt.Println()
+	for _, problem := range problems {
+		fmt.Printf("✗ %v\n", problem)
+	}
+	if len(problems) == 1 {
+		fmt.Println("\n1 problem found")
+	} else {
+		fmt.Printf("\n%d problems found\n", len(problems))
+	}
+	os.Exit(exitConfig)
+}
+
+func cmdConfigShow(args []string) {
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	configPath := fs.String("config", internal.GetConfigPath(), "Path to configuration file")
+	jsonOutput := fs.Bool("json", false, "Print the configuration as JSON")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	// Show the configuration even when it's invalid, so it can be inspected
+	config, sources, err := internal.ReadConfigSources(*configPath)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to load configuration: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Invalid configuration: %v\n", err)
+		fmt.Fprintf(os.Stderr, "  Run 'kubectx-timeout config validate' for details\n")
+	}
+
+	// Note where each setting came from: the file, one it includes, or
+	// the environment. Secrets are redacted.
+	data, err := internal.MarshalConfigSources(config, sources)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to encode configuration: %v", err)
+	}
+
+	if *jsonOutput {
+		// Round-trip through YAML so JSON uses the same keys and durations
+		var fields map[string]any
+		if err := yaml.Unmarshal(data, &fields); err != nil {
+			fatalf(exitCodeFor(err), "Failed to encode configuration: %v", err)
+		}
+		var err error
+		data, err = json.MarshalIndent(fields, "", "  ")
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to encode configuration: %v", err)
+		}
+		data = append(data, '\n')
+	}
+
+	fmt.Print(string(data))
+}
+
+func cmdConfigSet(args []string) {
+	fs := flag.NewFlagSet("config set", flag.ExitOnError)
+	configPath := fs.String("config", internal.GetConfigPath(), "Path to configuration file")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: kubectx-timeout config set <key> <value>\n")
+		fmt.Fprintf(os.Stderr, "  e.g. kubectx-timeout config set timeout.default 45m\n")
+		fmt.Fprintf(os.Stderr, "       kubectx-timeout config set contexts.prod-eu.timeout 5m\n")
+		fmt.Fprintf(os.Stderr, "       kubectx-timeout config set safety.never_switch_to prod,prod-*\n")
+		os.Exit(exitUsage)
+	}
+
+	key, value := fs.Arg(0), fs.Arg(1)
+	if err := internal.SetConfigValue(*configPath, key, value); err != nil {
+		fatalf(exitCodeFor(err), "Failed to set %s: %v", key, err)
+	}
+	fmt.Printf("✓ Set %s to %s\n", key, value)
+	reloadRunningDaemon()
+}
+
+func cmdConfigEdit(args []string) {
+	fs := flag.NewFlagSet("config edit", flag.ExitOnError)
+	configPath := fs.String("config", internal.GetConfigPath(), "Path to configuration file")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// #nosec G304 -- path is the user's configuration file
+	original, err := os.ReadFile(*configPath)
+	if os.IsNotExist(err) {
+		original, err = internal.MarshalConfig(internal.DefaultConfig())
+	}
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to read configuration: %v", err)
+	}
+
+	// Edit a copy, so an invalid configuration never reaches the daemon
+	tmp, err := os.CreateTemp("", "kubectx-timeout-*.yaml")
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(original); err != nil {
+		fatalf(exitCodeFor(err), "Failed to write temporary file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		fatalf(exitCodeFor(err), "Failed to write temporary file: %v", err)
+	}
+
+	input := bufio.NewReader(os.Stdin)
+	for {
+		// The editor may come with arguments, like "code --wait"
+		parts := strings.Fields(editor)
+		// #nosec G204 -- the editor is the user's own $VISUAL or $EDITOR
+		cmd := exec.Command(parts[0], append(parts[1:], tmp.Name())...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fatalf(exitCodeFor(err), "Editor %s failed: %v", editor, err)
+		}
+
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to read edited configuration: %v", err)
+		}
+		if bytes.Equal(edited, original) {
+			fmt.Println("No changes")
+			return
+		}
+
+		problems := internal.ValidateConfigFileData(*configPath, edited)
+		if len(problems) == 0 {
+			if err := internal.ReplaceConfigFile(*configPath, edited); err != nil {
+				fatalf(exitCodeFor(err), "Failed to save configuration: %v", err)
+			}
+			fmt.Printf("✓ Saved %s\n", *configPath)
+			reloadRunningDaemon()
+			return
+		}
+
+		fmt.Println()
+		for _, problem := range problems {
+			fmt.Printf("✗ %v\n", problem)
+		}
+		fmt.Print("\nEdit again? [Y/n]: ")
+		answer, _ := input.ReadString('\n')
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "n") {
+			fmt.Println("Changes discarded")
+			_ = os.Remove(tmp.Name())
+			os.Exit(1)
+		}
+	}
+}
+
+func cmdConfigSchema(args []string) {
+	fs := flag.NewFlagSet("config schema", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	schema, err := internal.ConfigSchema()
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to generate schema: %v", err)
+	}
+	os.Stdout.Write(schema)
+}
+
+// reloadRunningDaemon sends SIGHUP to the daemon, if it is running, so it
+// applies a configuration just written
+func reloadRunningDaemon() {
+	pidFile := internal.NewPIDFile()
+	if !pidFile.IsRunning() {
+		return
+	}
+	pid, err := pidFile.ReadPID()
+	if err != nil {
+		return
+	}
+	if err := internal.ReloadProcess(pid); err != nil {
+		fmt.Printf("Warning: Failed to reload the daemon: %v\n", err)
+		fmt.Println("  Run: kubectx-timeout reload")
+		return
+	}
+	fmt.Printf("✓ Daemon reloaded (PID: %d)\n", pid)
+}
+
 func cmdDaemon() {
 	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
 	defaultConfigPath := internal.GetConfigPath()
@@ -154,46 +575,186 @@ func cmdDaemon() {
 
 	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
 	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	foreground := fs.Bool("foreground", false, "Log to the terminal in a readable format instead of to daemon.log_file")
+	debug := fs.Bool("debug", false, "Log at debug level whatever daemon.log_level says (implies --foreground)")
+	dryRun := fs.Bool("dry-run", false, "Log the context switches the daemon would make without making them")
+	checkInterval := fs.Duration("check-interval", 0, "Check at least this often, overriding timeout.check_interval (e.g. 5s)")
 
-	if err := fs.Parse(os.Args[2:]); err != nil {
+	// "daemon run" reads better next to the daemon-* service commands
+	args := os.Args[2:]
+	if len(args) > 0 && args[0] == "run" {
+		args = args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
 		log.Fatalf("Failed to parse flags: %v", err)
 	}
+	if *checkInterval < 0 {
+		fatalf(exitUsage, "Invalid --check-interval %v: must be positive", *checkInterval)
+	}
+
+	var opts []internal.DaemonOption
+	if *foreground || *debug {
+		opts = append(opts, internal.WithForeground(*debug))
+	}
+	if *dryRun {
+		opts = append(opts, internal.WithDryRun())
+	}
+	if *checkInterval > 0 {
+		opts = append(opts, internal.WithCheckInterval(*checkInterval))
+	}
 
 	// Create daemon
-	daemon, err := internal.NewDaemon(*configPath, *statePath)
+	daemon, err := internal.NewDaemon(*configPath, *statePath, opts...)
 	if err != nil {
-		log.Fatalf("Failed to create daemon: %v", err)
+		fatalf(exitCodeFor(err), "Failed to create daemon: %v", err)
 	}
 
 	// Run daemon
 	if err := daemon.Run(); err != nil {
-		log.Fatalf("Daemon exited with error: %v", err)
+		fatalf(exitCodeFor(err), "Daemon exited with error: %v", err)
 	}
 }
 
 func cmdInit() {
 	defaultConfigPath := internal.GetConfigPath()
 
+	var opts initOptions
 	fs := flag.NewFlagSet("init", flag.ExitOnError)
 	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+	fs.StringVar(&opts.defaultContext, "default-context", "", "Context to switch to after a timeout; skips the interactive questions")
+	fs.StringVar(&opts.preset, "preset", "", "Start from a timeout preset: "+internal.PresetNames())
+	fs.DurationVar(&opts.timeout, "timeout", 0, "Default timeout for all contexts (e.g. 30m); overrides the preset's")
+	fs.Var(&opts.contexts, "context", "Per-context timeout as name=duration (e.g. prod=5m); may be repeated")
+	fs.BoolVar(&opts.force, "force", false, "Overwrite an existing configuration file")
+	fs.BoolVar(&opts.quiet, "quiet", false, "Print nothing on success (requires --default-context)")
 	if err := fs.Parse(os.Args[2:]); err != nil {
 		log.Fatalf("Failed to parse flags: %v", err)
 	}
+	if opts.quiet && opts.defaultContext == "" {
+		fatalf(exitUsage, "--quiet requires --default-context, since init would otherwise ask questions")
+	}
+	if opts.timeout < 0 {
+		fatalf(exitUsage, "Invalid --timeout %v: must be positive", opts.timeout)
+	}
 
-	if err := initializeConfig(*configPath); err != nil {
-		log.Fatalf("Failed to initialize configuration: %v", err)
+	if err := initializeConfig(*configPath, opts); err != nil {
+		fatalf(exitCodeFor(err), "Failed to initialize configuration: %v", err)
+	}
+	if opts.quiet {
+		return
 	}
 	fmt.Println("\n✓ Configuration initialized successfully")
 	fmt.Printf("  Config file: %s\n", *configPath)
 	fmt.Println("\nNext steps:")
 	fmt.Println("  1. Review and customize the configuration file")
 	fmt.Println("  2. Detect your shell: kubectx-timeout install-shell --detect")
-	fmt.Println("  3. Install shell integration: kubectx-timeout install-shell <bash|zsh|fish>")
+	fmt.Println("  3. Install shell integration: kubectx-timeout install-shell <bash|zsh|fish|powershell>")
 	fmt.Println("  4. Restart your shell or source your profile file")
 }
 
-// initializeConfig creates a default configuration file
-func initializeConfig(configPath string) error {
+func cmdMigrate() {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "List the files to move without moving them")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	migrator := internal.NewMigrator()
+	var plan []internal.Migration
+	var err error
+	if *dryRun {
+		plan, err = migrator.Plan()
+	} else {
+		plan, err = migrator.Migrate()
+	}
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to migrate: %v", err)
+	}
+
+	if len(plan) == 0 {
+		fmt.Printf("✓ Nothing to migrate from %s\n", internal.GetLegacyDir())
+		return
+	}
+
+	verb := "Moved"
+	if *dryRun {
+		verb = "Would move"
+	}
+	replacements := map[string]string{}
+	for _, migration := range plan {
+		if migration.Conflict {
+			fmt.Printf("  ⚠ Kept %s: %s already exists\n", migration.From, migration.To)
+			continue
+		}
+		fmt.Printf("  %s %s → %s\n", verb, migration.From, migration.To)
+		replacements[migration.From] = migration.To
+	}
+
+	// The launchd plist of an old install points at the old log files
+	if runtime.GOOS == "darwin" && len(replacements) > 0 && !*dryRun {
+		manager, err := internal.NewLaunchdManager("")
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to check the launchd service: %v", err)
+		}
+		updated, err := manager.ReplacePlistPaths(replacements)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to update %s: %v", manager.GetPlistPath(), err)
+		}
+		if updated {
+			fmt.Printf("  Updated %s\n", manager.GetPlistPath())
+		}
+	}
+
+	if !*dryRun {
+		fmt.Println("\n✓ Migration complete")
+	}
+}
+
+// initOptions are init's flags. With a default context, init writes the
+// config from the flags alone, without prompts or kubectl, for dotfile
+// installers and configuration management.
+type initOptions struct {
+	defaultContext string
+	preset         string
+	timeout        time.Duration
+	contexts       contextTimeoutsFlag
+	force          bool
+	quiet          bool
+}
+
+// contextTimeoutsFlag collects repeated --context name=duration flags
+type contextTimeoutsFlag map[string]time.Duration
+
+func (f *contextTimeoutsFlag) String() string {
+	var parts []string
+	for _, name := range slices.Sorted(maps.Keys(*f)) {
+		parts = append(parts, fmt.Sprintf("%s=%s", name, (*f)[name]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *contextTimeoutsFlag) Set(value string) error {
+	i := strings.LastIndex(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected name=duration, got %q", value)
+	}
+	timeout, err := time.ParseDuration(value[i+1:])
+	if err != nil {
+		return fmt.Errorf("invalid timeout for %s: %w", value[:i], err)
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("timeout for %s must be positive", value[:i])
+	}
+	if *f == nil {
+		*f = contextTimeoutsFlag{}
+	}
+	(*f)[value[:i]] = timeout
+	return nil
+}
+
+// initializeConfig creates a configuration file, from the flags if they
+// name a default context and by asking otherwise
+func initializeConfig(configPath string, opts initOptions) error {
 	// Expand ~ to home directory
 	if len(configPath) > 0 && configPath[0] == '~' {
 		home, err := os.UserHomeDir()
@@ -204,14 +765,34 @@ func initializeConfig(configPath string) error {
 	}
 
 	// Check if config already exists
-	if _, err := os.Stat(configPath); err == nil {
-		return fmt.Errorf("configuration file already exists at %s", configPath)
+	if _, err := os.Stat(configPath); err == nil && !opts.force {
+		return internal.MarkFailure(fmt.Errorf("configuration file already exists at %s (use --force to overwrite)", configPath), internal.ErrAlreadyInstalled)
+	}
+
+	config := internal.DefaultConfig()
+	if opts.preset != "" {
+		preset, ok := internal.LookupPreset(opts.preset)
+		if !ok {
+			return fmt.Errorf("unknown preset '%s' (must be one of %s)", opts.preset, internal.PresetNames())
+		}
+		preset.Apply(config)
+	}
+	if opts.timeout > 0 {
+		config.Timeout.Default = opts.timeout
+	}
+	for name, timeout := range opts.contexts {
+		if config.Contexts == nil {
+			config.Contexts = map[string]internal.Context{}
+		}
+		config.Contexts[name] = internal.Context{Timeout: timeout}
 	}
 
-	// Create config directory
-	configDir := filepath.Dir(configPath)
-	if err := os.MkdirAll(configDir, 0750); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+	if opts.defaultContext != "" {
+		config.DefaultContext = opts.defaultContext
+		if err := config.Validate(); err != nil {
+			return err
+		}
+		return writeInitConfig(configPath, config, opts.quiet)
 	}
 
 	// Get available contexts
@@ -220,75 +801,26 @@ func initializeConfig(configPath string) error {
 		return fmt.Errorf("failed to get available contexts: %w", err)
 	}
 
-	if len(contexts) == 0 {
-		return fmt.Errorf("no kubectl contexts available - please configure kubectl first")
-	}
+	// Get current context for reference
+	current, _ := internal.GetCurrentContext()
 
-	// Show available contexts
-	fmt.Println("Available kubectl contexts:")
-	for i, ctx := range contexts {
-		fmt.Printf("  %d. %s\n", i+1, ctx)
+	// Ask for the default context and timeouts
+	if err := internal.NewInitWizard(os.Stdin, os.Stdout).Run(config, contexts, current); err != nil {
+		return err
 	}
 
-	// Get current context as default suggestion
-	current, err := internal.GetCurrentContext()
-	if err == nil && current != "" {
-		fmt.Printf("\nCurrent context: %s\n", current)
-	}
+	return writeInitConfig(configPath, config, false)
+}
 
-	// Create default config with a valid context
-	config := internal.DefaultConfig()
-	if len(contexts) > 0 {
-		config.DefaultContext = contexts[0]
+// writeInitConfig writes the configuration init created
+func writeInitConfig(configPath string, config *internal.Config, quiet bool) error {
+	if err := internal.SaveConfig(configPath, config); err != nil {
+		return err
 	}
 
-	// Save config (would need a SaveConfig function in internal package)
-	// For now, just create a basic YAML file
-	configContent := fmt.Sprintf(`# kubectx-timeout configuration
-timeout:
-  default: 30m          # Default timeout for all contexts
-  check_interval: 30s   # How often to check for inactivity
-
-default_context: %s    # Context to switch to after timeout
-
-# Context-specific timeouts (optional)
-contexts:
-  # production:
-  #   timeout: 5m
-
-daemon:
-  enabled: true
-  log_level: info
-  log_file: daemon.log
-  log_max_size: 10
-  log_max_backups: 5
-
-notifications:
-  enabled: true
-  method: both  # terminal, macos, or both
-
-safety:
-  check_active_kubectl: true
-  validate_default_context: true
-  # never_switch_from:
-  #   - production
-  # never_switch_to:
-  #   - production
-
-state_file: state.json
-
-shell:
-  generate_wrapper: true
-  shells:
-    - bash
-    - zsh
-`, config.DefaultContext)
-
-	if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	if !quiet {
+		fmt.Printf("\nConfiguration file created at: %s\n", configPath)
 	}
-
-	fmt.Printf("\nConfiguration file created at: %s\n", configPath)
 	return nil
 }
 
@@ -307,6 +839,9 @@ func cmdInstallShell() {
 	noReload := fs.Bool("no-reload", false, "Don't offer to reload shell")
 	binaryPath := fs.String("binary", defaultBinaryPath, "Path to kubectx-timeout binary")
 	detectShell := fs.Bool("detect", false, "Detect and suggest shell instead of installing")
+	configPath := fs.String("config", internal.GetConfigPath(), "Path to configuration file (for shell.wrap_commands)")
+	mode := fs.String("mode", internal.IntegrationModeWrapper, "Integration mode: wrapper (shell functions) or hook (preexec hooks, keeps your own kubectl functions and aliases)")
+	completion := fs.Bool("completion", false, "Also load tab completion for kubectx-timeout")
 
 	if err := fs.Parse(os.Args[2:]); err != nil {
 		log.Fatalf("Failed to parse flags: %v", err)
@@ -316,12 +851,12 @@ func cmdInstallShell() {
 	if *detectShell {
 		detected, err := internal.DetectShell()
 		if err != nil {
-			log.Fatalf("Failed to detect shell: %v", err)
+			fatalf(exitCodeFor(err), "Failed to detect shell: %v", err)
 		}
 
 		profilePath, err := internal.GetShellProfilePath(detected)
 		if err != nil {
-			log.Fatalf("Failed to get shell profile path: %v", err)
+			fatalf(exitCodeFor(err), "Failed to get shell profile path: %v", err)
 		}
 
 		// Check if profile exists
@@ -349,25 +884,30 @@ func cmdInstallShell() {
 		fmt.Fprintf(os.Stderr, "Error: Shell argument is required\n\n")
 		fmt.Fprintf(os.Stderr, "Usage:\n")
 		fmt.Fprintf(os.Stderr, "  kubectx-timeout install-shell <shell>\n\n")
-		fmt.Fprintf(os.Stderr, "Supported shells: bash, zsh, fish\n\n")
+		fmt.Fprintf(os.Stderr, "Supported shells: bash, zsh, fish, powershell\n\n")
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  kubectx-timeout install-shell bash\n")
 		fmt.Fprintf(os.Stderr, "  kubectx-timeout install-shell zsh\n")
-		fmt.Fprintf(os.Stderr, "  kubectx-timeout install-shell fish\n\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout install-shell fish\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout install-shell powershell\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout install-shell --mode hook zsh\n\n")
 		fmt.Fprintf(os.Stderr, "To detect your current shell:\n")
 		fmt.Fprintf(os.Stderr, "  kubectx-timeout install-shell --detect\n")
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	targetShell := args[0]
 	if !isValidShellArg(targetShell) {
-		log.Fatalf("Unsupported shell: %s\nSupported shells: bash, zsh, fish", targetShell)
+		fatalf(exitUsage, "Unsupported shell: %s\nSupported shells: bash, zsh, fish, powershell", targetShell)
+	}
+	if *mode != internal.IntegrationModeWrapper && *mode != internal.IntegrationModeHook {
+		fatalf(exitUsage, "Unsupported mode: %s\nSupported modes: wrapper, hook", *mode)
 	}
 
 	// Get profile path
 	profilePath, err := internal.GetShellProfilePath(targetShell)
 	if err != nil {
-		log.Fatalf("Failed to get shell profile path: %v", err)
+		fatalf(exitCodeFor(err), "Failed to get shell profile path: %v", err)
 	}
 
 	// Validate profile path - warn if it doesn't exist but don't fail
@@ -383,29 +923,104 @@ func cmdInstallShell() {
 	}
 	fmt.Printf("Binary path: %s\n", *binaryPath)
 
-	// Check if already installed
+	// The wrapped commands come from the config; a broken config shouldn't
+	// block installing the integration
+	wrapCommands := internal.DefaultWrapCommands
+	var extraAliases []string
+	var options internal.ShellIntegrationOptions
+	var keepBackups int
+	if config, err := internal.LoadConfig(*configPath); err != nil {
+		fmt.Printf("Warning: Failed to load config, wrapping the default commands: %v\n", err)
+	} else {
+		if config.Shell.WrapCommands != nil {
+			wrapCommands = config.Shell.WrapCommands
+		}
+		extraAliases = config.Shell.ExtraAliases
+		options.RemainingTime = config.Shell.RemainingTime
+		keepBackups = config.Shell.ProfileBackups
+	}
+	fmt.Printf("Mode: %s\n", *mode)
+	fmt.Printf("Tracked commands: %s\n", strings.Join(wrapCommands, ", "))
+
+	aliases, err := shellAliases(profilePath, wrapCommands, extraAliases)
+	if err != nil {
+		fmt.Printf("Warning: Failed to detect aliases: %v\n", err)
+	}
+	if len(aliases) > 0 {
+		names := make([]string, 0, len(aliases))
+		for _, alias := range aliases {
+			names = append(names, fmt.Sprintf("%s (%s)", alias.Name, alias.Command))
+		}
+		fmt.Printf("Tracked aliases: %s\n", strings.Join(names, ", "))
+	}
+
+	// Check if already installed. An integration that sources the
+	// integration file is updated by rewriting the file.
+	integrationPath := internal.GetIntegrationPath(targetShell)
 	installed, err := internal.IsIntegrationInstalled(profilePath)
 	if err != nil {
-		log.Fatalf("Failed to check installation status: %v", err)
+		fatalf(exitCodeFor(err), "Failed to check installation status: %v", err)
 	}
 	if installed {
-		fmt.Println("\n✓ Shell integration is already installed")
-		fmt.Printf("  To reinstall, first run: kubectx-timeout uninstall-shell %s\n", targetShell)
-		return
+		sourced, err := internal.IsIntegrationFileSourced(profilePath, integrationPath)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to check installation status: %v", err)
+		}
+		if !sourced {
+			fmt.Println("\n✓ Shell integration is already installed in your profile")
+			fmt.Printf("  To move it to %s, first run: kubectx-timeout uninstall-shell %s\n", integrationPath, targetShell)
+			os.Exit(exitAlreadyInstalled)
+		}
 	}
 
 	// Get integration code
-	integrationCode, err := internal.GetShellIntegrationCode(targetShell, *binaryPath)
+	var integrationCode string
+	if *mode == internal.IntegrationModeHook {
+		integrationCode, err = internal.GetShellHookCode(targetShell, *binaryPath, wrapCommands, aliases)
+	} else {
+		integrationCode, err = internal.GetShellIntegrationCodeWithOptions(targetShell, *binaryPath, wrapCommands, aliases, options)
+	}
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to generate integration code: %v", err)
+	}
+	if *completion {
+		script, err := internal.GetCompletionScript(targetShell, "kubectx-timeout", completionCommands)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to generate completion: %v", err)
+		}
+		// With the rest of the integration, inside its markers
+		integrationCode = strings.Replace(integrationCode, internal.IntegrationEndMarker,
+			"\n"+script+internal.IntegrationEndMarker, 1)
+	}
+
+	// bash has no preexec hook of its own
+	if *mode == internal.IntegrationModeHook && targetShell == "bash" {
+		// #nosec G304 -- profilePath is constructed from user home dir and known profile names
+		if content, err := os.ReadFile(profilePath); err != nil || !strings.Contains(string(content), "bash-preexec") {
+			fmt.Println("\nNote: Hook mode in bash requires bash-preexec, which wasn't found in your profile")
+			fmt.Println("  See: https://github.com/rcaloras/bash-preexec")
+		}
+	}
+
+	sourceCode, err := internal.GetIntegrationSourceCode(targetShell, integrationPath)
 	if err != nil {
-		log.Fatalf("Failed to generate integration code: %v", err)
+		fatalf(exitCodeFor(err), "Failed to generate integration code: %v", err)
 	}
 
 	// Show preview
 	fmt.Println("\n" + strings.Repeat("=", 60))
-	fmt.Println("The following will be added to your shell profile:")
+	fmt.Printf("The following will be written to %s:\n", integrationPath)
 	fmt.Println(strings.Repeat("=", 60))
 	fmt.Println(integrationCode)
 	fmt.Println(strings.Repeat("=", 60))
+	if installed {
+		fmt.Println("Your shell profile already sources it and won't be changed")
+	} else {
+		fmt.Println("And sourced from your shell profile with:")
+		fmt.Println(strings.Repeat("=", 60))
+		fmt.Println(sourceCode)
+		fmt.Println(strings.Repeat("=", 60))
+	}
 
 	// Confirm unless --yes flag is set
 	if !*noConfirm {
@@ -413,7 +1028,7 @@ func cmdInstallShell() {
 		reader := bufio.NewReader(os.Stdin)
 		response, err := reader.ReadString('\n')
 		if err != nil {
-			log.Fatalf("Failed to read input: %v", err)
+			fatalf(exitCodeFor(err), "Failed to read input: %v", err)
 		}
 		response = strings.TrimSpace(strings.ToLower(response))
 		if response != "y" && response != "yes" {
@@ -424,14 +1039,22 @@ func cmdInstallShell() {
 
 	// Install integration
 	fmt.Println("\nInstalling shell integration...")
-	if err := internal.InstallIntegration(profilePath, integrationCode); err != nil {
-		log.Fatalf("Failed to install integration: %v", err)
+	if err := internal.InstallIntegrationFile(integrationPath, integrationCode); err != nil {
+		fatalf(exitCodeFor(err), "Failed to install integration: %v", err)
 	}
+	fmt.Printf("✓ Integration written to: %s\n", integrationPath)
 
-	// Create backup notice
-	backupPath := profilePath + ".kubectx-timeout.backup"
-	fmt.Printf("✓ Backup created: %s\n", backupPath)
-	fmt.Printf("✓ Integration installed to: %s\n", profilePath)
+	if !installed {
+		backupPath, err := internal.InstallIntegration(profilePath, sourceCode, keepBackups)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to install integration: %v", err)
+		}
+
+		if backupPath != "" {
+			fmt.Printf("✓ Backup created: %s\n", backupPath)
+		}
+		fmt.Printf("✓ Integration sourced from: %s\n", profilePath)
+	}
 
 	// Verify installation
 	fmt.Println("\nVerifying installation...")
@@ -462,6 +1085,8 @@ func cmdInstallShell() {
 			fmt.Println("  source ~/.zshrc")
 		case "fish":
 			fmt.Println("  source ~/.config/fish/config.fish")
+		case "powershell":
+			fmt.Println("  . $PROFILE")
 		}
 		fmt.Println("  Or: Start a new shell")
 		fmt.Println("\nNote: The integration will be active in all new shells automatically")
@@ -483,7 +1108,7 @@ func cmdUninstallShell() {
 	if *detectShell {
 		detected, err := internal.DetectShell()
 		if err != nil {
-			log.Fatalf("Failed to detect shell: %v", err)
+			fatalf(exitCodeFor(err), "Failed to detect shell: %v", err)
 		}
 		fmt.Printf("Detected shell: %s\n", detected)
 		fmt.Printf("\nTo uninstall shell integration, run:\n")
@@ -498,25 +1123,25 @@ func cmdUninstallShell() {
 		fmt.Fprintf(os.Stderr, "Error: Shell argument is required\n\n")
 		fmt.Fprintf(os.Stderr, "Usage:\n")
 		fmt.Fprintf(os.Stderr, "  kubectx-timeout uninstall-shell <shell>\n\n")
-		fmt.Fprintf(os.Stderr, "Supported shells: bash, zsh, fish\n\n")
+		fmt.Fprintf(os.Stderr, "Supported shells: bash, zsh, fish, powershell\n\n")
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  kubectx-timeout uninstall-shell bash\n")
 		fmt.Fprintf(os.Stderr, "  kubectx-timeout uninstall-shell zsh\n")
 		fmt.Fprintf(os.Stderr, "  kubectx-timeout uninstall-shell fish\n\n")
 		fmt.Fprintf(os.Stderr, "To detect your current shell:\n")
 		fmt.Fprintf(os.Stderr, "  kubectx-timeout uninstall-shell --detect\n")
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	targetShell := args[0]
 	if !isValidShellArg(targetShell) {
-		log.Fatalf("Unsupported shell: %s\nSupported shells: bash, zsh, fish", targetShell)
+		fatalf(exitUsage, "Unsupported shell: %s\nSupported shells: bash, zsh, fish, powershell", targetShell)
 	}
 
 	// Get profile path
 	profilePath, err := internal.GetShellProfilePath(targetShell)
 	if err != nil {
-		log.Fatalf("Failed to get shell profile path: %v", err)
+		fatalf(exitCodeFor(err), "Failed to get shell profile path: %v", err)
 	}
 
 	fmt.Printf("Shell profile: %s\n", profilePath)
@@ -524,7 +1149,7 @@ func cmdUninstallShell() {
 	// Check if installed
 	installed, err := internal.IsIntegrationInstalled(profilePath)
 	if err != nil {
-		log.Fatalf("Failed to check installation status: %v", err)
+		fatalf(exitCodeFor(err), "Failed to check installation status: %v", err)
 	}
 	if !installed {
 		fmt.Println("\n✓ Shell integration is not installed (nothing to remove)")
@@ -537,7 +1162,7 @@ func cmdUninstallShell() {
 		reader := bufio.NewReader(os.Stdin)
 		response, err := reader.ReadString('\n')
 		if err != nil {
-			log.Fatalf("Failed to read input: %v", err)
+			fatalf(exitCodeFor(err), "Failed to read input: %v", err)
 		}
 		response = strings.TrimSpace(strings.ToLower(response))
 		if response != "y" && response != "yes" {
@@ -548,54 +1173,202 @@ func cmdUninstallShell() {
 
 	// Uninstall integration
 	fmt.Println("\nRemoving shell integration...")
-	if err := internal.UninstallIntegration(profilePath); err != nil {
-		log.Fatalf("Failed to uninstall integration: %v", err)
+	backupPath, err := internal.UninstallIntegration(profilePath, profileBackups(internal.GetConfigPath()))
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to uninstall integration: %v", err)
 	}
 
-	// Create backup notice
-	backupPath := profilePath + ".kubectx-timeout.backup"
-	fmt.Printf("✓ Backup created: %s\n", backupPath)
+	if backupPath != "" {
+		fmt.Printf("✓ Backup created: %s\n", backupPath)
+	}
 	fmt.Printf("✓ Integration removed from: %s\n", profilePath)
 
+	if err := internal.RemoveIntegrationFile(internal.GetIntegrationPath(targetShell)); err != nil {
+		fatalf(exitCodeFor(err), "Failed to uninstall integration: %v", err)
+	}
+
 	fmt.Println("\n✓ Uninstallation complete!")
 	fmt.Println("  Restart your shell for changes to take effect")
 }
 
-func cmdRecordActivity() {
-	defaultStatePath := internal.GetStatePath()
-	defaultConfigPath := internal.GetConfigPath()
-
-	fs := flag.NewFlagSet("record-activity", flag.ExitOnError)
-	statePath := fs.String("state", defaultStatePath, "Path to state file")
-	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+func cmdRestoreProfile() {
+	fs := flag.NewFlagSet("restore-profile", flag.ExitOnError)
+	configPath := fs.String("config", internal.GetConfigPath(), "Path to configuration file")
+	list := fs.Bool("list", false, "List the profile's backups instead of restoring one")
+	backup := fs.String("backup", "", "Backup to restore, by its number in --list or its path (default: the newest)")
+	noConfirm := fs.Bool("yes", false, "Skip confirmation prompts")
 	if err := fs.Parse(os.Args[2:]); err != nil {
 		log.Fatalf("Failed to parse flags: %v", err)
 	}
 
-	// Create activity tracker
-	tracker, err := internal.NewActivityTracker(*statePath, *configPath)
-	if err != nil {
-		// Silent failure - don't break kubectl workflow
-		// Error is logged but we exit 0
-		log.Printf("Warning: failed to create activity tracker: %v", err)
-		return
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: kubectx-timeout restore-profile [--list] [--backup <number|path>] [--yes] <shell>\n")
+		os.Exit(exitUsage)
 	}
-
-	// Record activity
-	if err := tracker.RecordActivity(); err != nil {
-		// Silent failure - don't break kubectl workflow
-		// Error is logged but we exit 0
-		log.Printf("Warning: failed to record activity: %v", err)
+	targetShell := fs.Arg(0)
+	if !isValidShellArg(targetShell) {
+		fatalf(exitUsage, "Unsupported shell: %s\nSupported shells: bash, zsh, fish, powershell", targetShell)
 	}
+
+	profilePath, err := internal.GetShellProfilePath(targetShell)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to get shell profile path: %v", err)
+	}
+	backups, err := internal.ListProfileBackups(profilePath)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to list backups: %v", err)
+	}
+	if len(backups) == 0 {
+		fatalf(exitFailure, "No backups of %s", profilePath)
+	}
+
+	if *list {
+		fmt.Printf("Backups of %s, newest first:\n", profilePath)
+		for i, b := range backups {
+			note := ""
+			if b.Legacy {
+				note = " (from an earlier version)"
+			}
+			fmt.Printf("  %2d  %s  %s%s\n", i+1, b.Time.Format("2006-01-02 15:04:05"), b.Path, note)
+		}
+		return
+	}
+
+	restore := backups[0]
+	if *backup != "" {
+		if n, err := strconv.Atoi(*backup); err == nil {
+			if n < 1 || n > len(backups) {
+				fatalf(exitUsage, "No backup %d: %s has %d (see --list)", n, profilePath, len(backups))
+			}
+			restore = backups[n-1]
+		} else {
+			path, err := filepath.Abs(*backup)
+			if err != nil {
+				fatalf(exitUsage, "Invalid --backup %s: %v", *backup, err)
+			}
+			i := slices.IndexFunc(backups, func(b internal.ProfileBackup) bool { return b.Path == path })
+			if i < 0 {
+				fatalf(exitUsage, "%s is not a backup of %s (see --list)", *backup, profilePath)
+			}
+			restore = backups[i]
+		}
+	}
+
+	if !*noConfirm {
+		fmt.Printf("Replace %s with the backup from %s (%s)? [y/N]: ", profilePath, restore.Time.Format("2006-01-02 15:04:05"), restore.Path)
+		reader := bufio.NewReader(os.Stdin)
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to read input: %v", err)
+		}
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Restore cancelled")
+			return
+		}
+	}
+
+	current, err := internal.RestoreProfile(profilePath, restore.Path, profileBackups(*configPath))
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to restore profile: %v", err)
+	}
+	if current != "" {
+		fmt.Printf("✓ Backup created: %s\n", current)
+	}
+	fmt.Printf("✓ Restored %s from %s\n", profilePath, restore.Path)
+	fmt.Println("  Restart your shell for changes to take effect")
 }
 
-func isValidShellArg(shell string) bool {
-	switch shell {
-	case "bash", "zsh", "fish":
-		return true
-	default:
-		return false
+func cmdRecordActivity() {
+	defaultStatePath := internal.GetStatePath()
+	defaultConfigPath := internal.GetConfigPath()
+
+	fs := flag.NewFlagSet("record-activity", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+	whilePID := fs.Int("while-pid", 0, "Keep recording activity until the process with this PID exits (for k9s, or with --args only for long-running commands such as logs -f)")
+	interval := fs.Duration("interval", time.Minute, "How often to record activity with --while-pid")
+	commandArgs := fs.String("args", "", "Arguments of the wrapped command (e.g. \"get pods\"), used only to tell reads from writes and long-running commands")
+	credentials := fs.Bool("credentials", false, "The wrapped command is a cloud CLI (aws, gcloud, az); record activity only if --args fetch cluster credentials")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	args := strings.Fields(*commandArgs)
+	if *credentials {
+		if !internal.IsCredentialCommand(args) {
+			return
+		}
+		// Fetching credentials is activity in the new context, not a kubectl command
+		args = nil
+	}
+
+	// Create activity tracker
+	tracker, err := internal.NewActivityTracker(*statePath, *configPath)
+	if err != nil {
+		// Silent failure - don't break kubectl workflow
+		// Error is logged but we exit 0
+		log.Printf("Warning: failed to create activity tracker: %v", err)
+		return
+	}
+
+	// The wrapper kills a heartbeat when its command exits, which may be
+	// before a short command's activity is recorded, so the signal only
+	// stops further beats
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+
+	if *whilePID > 0 && (len(args) == 0 || internal.ParseKubectlCommand(args).IsLongRunning()) {
+		if err := tracker.Heartbeat(ctx, *whilePID, *interval, args); err != nil {
+			log.Printf("Warning: failed to record activity: %v", err)
+		}
+		return
+	}
+
+	// Record activity
+	if err := tracker.RecordCommand(args); err != nil {
+		// Silent failure - don't break kubectl workflow
+		// Error is logged but we exit 0
+		log.Printf("Warning: failed to record activity: %v", err)
+	}
+}
+
+func cmdSwitchNotice() {
+	fs := flag.NewFlagSet("switch-notice", flag.ExitOnError)
+	statePath := fs.String("state", internal.GetStatePath(), "Path to state file")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	// Print to stderr, so the notice never ends up in the output of the
+	// command about to run. Failures are silent, like record-activity's.
+	notice, err := internal.TakeSwitchNotice(internal.SwitchNoticePathForState(*statePath))
+	if err != nil || notice == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "kubectx-timeout: %s\n", notice.Message(time.Now()))
+}
+
+// shellAliases returns the aliases to track: those detected in the profile,
+// overridden by the configured extra aliases of the same name
+func shellAliases(profilePath string, commands []string, extra []string) ([]internal.ShellAlias, error) {
+	configured, err := internal.ParseShellAliases(extra)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shell.extra_aliases: %w", err)
+	}
+
+	detected, err := internal.DetectShellAliases(profilePath, commands)
+	aliases := make([]internal.ShellAlias, 0, len(detected)+len(configured))
+	for _, alias := range detected {
+		if !slices.ContainsFunc(configured, func(c internal.ShellAlias) bool { return c.Name == alias.Name }) {
+			aliases = append(aliases, alias)
+		}
 	}
+	return append(aliases, configured...), err
+}
+
+func isValidShellArg(shell string) bool {
+	return slices.Contains(internal.SupportedShells, shell)
 }
 
 func cmdStatus() {
@@ -612,32 +1385,26 @@ func cmdStatus() {
 	// Check daemon status
 	pidFile := internal.NewPIDFile()
 	pid, err := pidFile.ReadPID()
-	running := false
-	if err == nil {
-		// Check if process is actually running
-		process, err := os.FindProcess(pid)
-		if err == nil {
-			err = process.Signal(syscall.Signal(0))
-			running = (err == nil)
-		}
-	}
+	running := err == nil && internal.ProcessExists(pid)
 
 	// Load configuration
 	config, err := internal.LoadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatalf(exitCodeFor(err), "Failed to load config: %v", err)
 	}
 
 	// Load state
-	stateManager, err := internal.NewStateManager(*statePath)
+	stateManager, err := internal.OpenStateManager(*statePath, config)
 	if err != nil {
-		log.Fatalf("Failed to create state manager: %v", err)
+		fatalf(exitCodeFor(err), "Failed to create state manager: %v", err)
 	}
 
-	lastActivity, lastContext, err := stateManager.GetLastActivity()
+	state, err := stateManager.Load()
 	if err != nil {
-		log.Fatalf("Failed to get last activity: %v", err)
+		fatalf(exitCodeFor(err), "Failed to get last activity: %v", err)
 	}
+	lastActivity, lastContext := state.LastActivity, state.CurrentContext
+	config = withActiveProfile(config, stateManager)
 
 	// Get current context
 	currentContext, err := internal.GetCurrentContext()
@@ -657,20 +1424,36 @@ func cmdStatus() {
 		fmt.Println("Daemon:           Not running")
 	}
 
+	// The daemon's own view, when it can be asked
+	socketPath := internal.ControlSocketPathForState(*statePath)
+	if resp, err := internal.SendControlRequest(socketPath, internal.ControlRequest{Command: internal.ControlStatus}); err == nil && resp.Status != nil {
+		fmt.Printf("Daemon State:     %s\n", resp.Status.State)
+		if resp.Status.DegradedReason != "" {
+			fmt.Printf("Degraded Reason:  %s\n", resp.Status.DegradedReason)
+		}
+	}
+
+	if profile := state.ActiveProfile(); profile.ActiveAt(time.Now()) {
+		fmt.Printf("Profile:          %s %s\n", profile.Name, describeProfileUntil(profile, time.Now()))
+	}
+
 	// Context information
-	fmt.Printf("Current Context:  %s\n", currentContext)
-	fmt.Printf("Default Context:  %s\n", config.DefaultContext)
+	fmt.Printf("Current Context:  %s\n", contextLabel(config, currentContext))
+	if state.CurrentNamespace != "" && lastContext == currentContext {
+		fmt.Printf("Namespace:        %s\n", state.CurrentNamespace)
+	}
+	fmt.Printf("Default Context:  %s\n", contextLabel(config, config.GetDefaultContextFor(currentContext)))
 
 	// Activity information
 	if !lastActivity.IsZero() {
-		timeSince, _ := stateManager.TimeSinceLastActivity()
+		timeSince := time.Since(lastActivity)
 		timeout := config.GetTimeoutForContext(currentContext)
 		remaining := timeout - timeSince
 
 		fmt.Printf("Last Activity:    %s (%s ago)\n",
 			lastActivity.Format("2006-01-02 15:04:05"),
 			timeSince.Round(1*time.Second))
-		fmt.Printf("Last Context:     %s\n", lastContext)
+		fmt.Printf("Last Context:     %s\n", contextLabel(config, lastContext))
 		fmt.Printf("Timeout:          %s\n", timeout)
 
 		if remaining > 0 {
@@ -683,6 +1466,46 @@ func cmdStatus() {
 		fmt.Println("Last Activity:    No activity recorded")
 	}
 
+	if extendedUntil := state.ExtendedUntil; time.Now().Before(extendedUntil) {
+		fmt.Printf("Extended Until:   %s (%s left)\n",
+			extendedUntil.Format("2006-01-02 15:04:05"),
+			time.Until(extendedUntil).Round(1*time.Second))
+	}
+
+	if pending := state.PendingSwitch(); !pending.IsZero() {
+		fmt.Printf("Pending Switch:   to %s at %s (cancel with: kubectx-timeout cancel-switch)\n",
+			quoteContext(config, pending.To), pending.At.Format("2006-01-02 15:04:05"))
+	}
+
+	if paused := state.ActivePauses(time.Now()); len(paused) > 0 {
+		contexts := make([]string, 0, len(paused))
+		for context := range paused {
+			contexts = append(contexts, context)
+		}
+		sort.Strings(contexts)
+
+		fmt.Println()
+		fmt.Println("Paused Contexts:")
+		for _, context := range contexts {
+			fmt.Printf("  %s until %s (%s left)\n", contextLabel(config, context),
+				paused[context].Format("2006-01-02 15:04:05"),
+				time.Until(paused[context]).Round(1*time.Second))
+		}
+	}
+
+	// Expired kubeconfig credentials
+	now := time.Now()
+	if stale, err := internal.FindStaleCredentials(internal.GetKubeconfigPaths(), now); err != nil {
+		fmt.Printf("\nWarning: Failed to check kubeconfig credentials: %v\n", err)
+	} else if len(stale) > 0 {
+		fmt.Println()
+		fmt.Println("Stale Credentials:")
+		for _, cred := range stale {
+			fmt.Printf("  ⚠ %s\n", cred.Describe(now))
+		}
+		fmt.Println("  Remove them with: kubectx-timeout prune-contexts")
+	}
+
 	// Configuration
 	fmt.Println()
 	fmt.Printf("Config File:      %s\n", *configPath)
@@ -694,22 +1517,15 @@ func cmdStart() {
 	// Check if already running
 	pidFile := internal.NewPIDFile()
 	pid, err := pidFile.ReadPID()
-	if err == nil {
-		// Check if process is actually running
-		process, err := os.FindProcess(pid)
-		if err == nil {
-			err = process.Signal(syscall.Signal(0))
-			if err == nil {
-				fmt.Printf("Daemon is already running (PID: %d)\n", pid)
-				os.Exit(0)
-			}
-		}
+	if err == nil && internal.ProcessExists(pid) {
+		fmt.Printf("Daemon is already running (PID: %d)\n", pid)
+		os.Exit(0)
 	}
 
 	// Get binary path
 	binPath, err := os.Executable()
 	if err != nil {
-		log.Fatalf("Failed to get executable path: %v", err)
+		fatalf(exitCodeFor(err), "Failed to get executable path: %v", err)
 	}
 
 	// Start daemon in background
@@ -727,7 +1543,7 @@ func cmdStart() {
 	cmd.Stdin = nil
 
 	if err := cmd.Start(); err != nil {
-		log.Fatalf("Failed to start daemon: %v", err)
+		fatalf(exitCodeFor(err), "Failed to start daemon: %v", err)
 	}
 
 	// Wait a moment to verify it started
@@ -741,19 +1557,11 @@ func cmdStart() {
 	}
 
 	// Check if process is actually running
-	process, err := os.FindProcess(pid)
-	if err == nil {
-		err = process.Signal(syscall.Signal(0))
-		if err == nil {
-			fmt.Printf("✓ Daemon started successfully (PID: %d)\n", pid)
-		} else {
-			fmt.Println("✗ Daemon failed to start (process not running)")
-			os.Exit(1)
-		}
-	} else {
-		fmt.Println("✗ Daemon failed to start (process not found)")
+	if !internal.ProcessExists(pid) {
+		fmt.Println("✗ Daemon failed to start (process not running)")
 		os.Exit(1)
 	}
+	fmt.Printf("✓ Daemon started successfully (PID: %d)\n", pid)
 }
 
 func cmdStop() {
@@ -764,24 +1572,17 @@ func cmdStop() {
 		os.Exit(0)
 	}
 
-	// Check if process is actually running
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		fmt.Println("Daemon is not running")
-		os.Exit(0)
-	}
-
-	err = process.Signal(syscall.Signal(0))
-	if err != nil {
+	// Also guards against signaling an unrelated process that reused the PID
+	if !pidFile.IsRunning() {
 		fmt.Println("Daemon is not running (stale PID file)")
-		_ = pidFile.Release() // Clean up stale PID file
+		_, _ = pidFile.RemoveStale() // Clean up stale PID file
 		os.Exit(0)
 	}
 
 	// Send SIGTERM to daemon
 	fmt.Printf("Stopping daemon (PID: %d)...\n", pid)
-	if err := process.Signal(syscall.SIGTERM); err != nil {
-		log.Fatalf("Failed to send SIGTERM: %v", err)
+	if err := internal.StopProcess(pid); err != nil {
+		fatalf(exitCodeFor(err), "Failed to stop daemon: %v", err)
 	}
 
 	// Wait for process to exit (with timeout)
@@ -797,8 +1598,7 @@ func cmdStop() {
 			os.Exit(1)
 		case <-ticker.C:
 			// Check if process is still running
-			err := process.Signal(syscall.Signal(0))
-			if err != nil {
+			if !internal.ProcessExists(pid) {
 				fmt.Println("✓ Daemon stopped successfully")
 				return
 			}
@@ -807,190 +1607,1677 @@ func cmdStop() {
 }
 
 func cmdReload() {
+	// Prefer the control socket, which reports whether the reload worked
+	resp, err := internal.SendControlRequest(internal.GetControlSocketPath(), internal.ControlRequest{Command: internal.ControlReload})
+	if err == nil {
+		fmt.Println("✓ Configuration reloaded")
+		if resp.Status != nil {
+			fmt.Printf("  Default context: %s\n", resp.Status.DefaultContext)
+		}
+		return
+	}
+	if !errors.Is(err, internal.ErrControlUnavailable) {
+		fatalf(exitCodeFor(err), "Failed to reload configuration: %v", err)
+	}
+
 	pidFile := internal.NewPIDFile()
 	pid, err := pidFile.ReadPID()
 	if err != nil {
 		fmt.Println("Daemon is not running (no PID file)")
 		fmt.Println("Start it with: kubectx-timeout start")
-		os.Exit(1)
+		os.Exit(exitDaemonNotRunning)
 	}
 
-	// Check if process is actually running
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		fmt.Println("Daemon is not running")
+	// Also guards against signaling an unrelated process that reused the PID
+	if !pidFile.IsRunning() {
+		fmt.Println("Daemon is not running (stale PID file)")
 		fmt.Println("Start it with: kubectx-timeout start")
-		os.Exit(1)
+		_, _ = pidFile.RemoveStale() // Clean up stale PID file
+		os.Exit(exitDaemonNotRunning)
 	}
 
-	err = process.Signal(syscall.Signal(0))
-	if err != nil {
-		fmt.Println("Daemon is not running (stale PID file)")
-		fmt.Println("Start it with: kubectx-timeout start")
-		_ = pidFile.Release() // Clean up stale PID file
-		os.Exit(1)
+	// A signal can't report whether the daemon accepted the config, so
+	// validate it here and fail the way the control socket would. A missing
+	// file would otherwise load the defaults, which the daemon refuses too.
+	configPath := internal.GetConfigPath()
+	if _, err := os.Stat(configPath); err != nil {
+		fatalf(exitConfig, "Failed to reload configuration: %v", err)
+	}
+	if _, err := internal.LoadConfig(configPath); err != nil {
+		fatalf(exitCodeFor(err), "Failed to reload configuration: %v", err)
 	}
 
 	fmt.Printf("Reloading daemon configuration (PID: %d)...\n", pid)
-	if err := process.Signal(syscall.SIGHUP); err != nil {
-		log.Fatalf("Failed to send SIGHUP: %v", err)
+	if err := internal.ReloadProcess(pid); err != nil {
+		fatalf(exitCodeFor(err), "Failed to reload configuration: %v", err)
 	}
 
 	fmt.Println("✓ Reload signal sent successfully")
 	fmt.Println("  Check daemon logs to confirm configuration reloaded")
 }
 
-func cmdReset() {
-	defaultStatePath := internal.GetStatePath()
-	defaultConfigPath := internal.GetConfigPath()
-
-	fs := flag.NewFlagSet("reset", flag.ExitOnError)
-	statePath := fs.String("state", defaultStatePath, "Path to state file")
-	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+func cmdContexts() {
+	fs := flag.NewFlagSet("contexts", flag.ExitOnError)
+	configPath := fs.String("config", internal.GetConfigPath(), "Path to configuration file")
+	jsonOutput := fs.Bool("json", false, "Print the contexts as JSON")
 	if err := fs.Parse(os.Args[2:]); err != nil {
 		log.Fatalf("Failed to parse flags: %v", err)
 	}
 
-	// Get current context
-	currentContext, err := internal.GetCurrentContext()
+	config, err := internal.LoadConfig(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to get current context: %v", err)
+		fatalf(exitCodeFor(err), "Failed to load config: %v", err)
 	}
-
-	// Create activity tracker and record activity
-	tracker, err := internal.NewActivityTracker(*statePath, *configPath)
+	// Show the timeouts of the profile in use, as the daemon applies them
+	if stateManager, err := internal.OpenStateManager(internal.GetStatePath(), config); err == nil {
+		config = withActiveProfile(config, stateManager)
+	}
+	contexts, err := internal.GetAvailableContexts()
 	if err != nil {
-		log.Fatalf("Failed to create activity tracker: %v", err)
+		fatalf(exitCodeFor(err), "Failed to get available contexts: %v", err)
 	}
+	current, _ := internal.GetCurrentContext()
+
+	policies := internal.ListContextPolicies(config, contexts, current, time.Now())
 
-	if err := tracker.RecordActivity(); err != nil {
-		log.Fatalf("Failed to reset activity timer: %v", err)
+	if *jsonOutput {
+		data, err := json.MarshalIndent(policies, "", "  ")
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to encode contexts: %v", err)
+		}
+		fmt.Println(string(data))
+		return
 	}
 
-	fmt.Printf("✓ Activity timer reset for context '%s'\n", currentContext)
-	fmt.Println("  Timeout period has been reset to 0")
-}
+	width, targetWidth := len("CONTEXT"), len("SWITCHES TO")
+	for _, p := range policies {
+		width = max(width, len(contextLabel(config, p.Context)))
+		targetWidth = max(targetWidth, len(contextLabel(config, p.SwitchesTo)))
+	}
+	fmt.Printf("  %-*s  %-9s  %-*s  %s\n", width, "CONTEXT", "TIMEOUT", targetWidth, "SWITCHES TO", "FLAGS")
+	for _, p := range policies {
+		marker := " "
+		if p.Current {
+			marker = "▶"
+		}
+		timeout, target := "-", "-"
+		if p.SwitchesTo != "" {
+			timeout, target = p.Timeout.String(), contextLabel(config, p.SwitchesTo)
+		}
 
-func cmdUninstall() {
-	// Detect the current binary path
-	defaultBinaryPath := "/usr/local/bin/kubectx-timeout" // fallback default
-	if execPath, err := os.Executable(); err == nil {
-		// Get absolute path
-		if absPath, err := filepath.Abs(execPath); err == nil {
-			defaultBinaryPath = absPath
+		var flags []string
+		if p.DefaultTarget {
+			flags = append(flags, "default target")
 		}
+		if p.NeverSwitchFrom {
+			flags = append(flags, "never switch from")
+		}
+		if p.NeverSwitchTo {
+			flags = append(flags, "never switch to")
+		}
+		if p.Missing {
+			flags = append(flags, "not in kubeconfig")
+		}
+		line := fmt.Sprintf("%s %-*s  %-9s  %-*s  %s", marker, width, contextLabel(config, p.Context), timeout, targetWidth, target, strings.Join(flags, ", "))
+		fmt.Println(strings.TrimRight(line, " "))
 	}
+}
 
-	fs := flag.NewFlagSet("uninstall", flag.ExitOnError)
-	all := fs.Bool("all", false, "Remove everything including binary")
-	keepConfig := fs.Bool("keep-config", false, "Keep configuration and state files")
-	keepBinary := fs.Bool("keep-binary", false, "Keep the binary (remove everything else)")
-	yes := fs.Bool("yes", false, "Skip confirmation prompts")
-	allShells := fs.Bool("all-shells", false, "Remove from all shell profiles (bash, zsh, fish)")
-	binaryPath := fs.String("binary", defaultBinaryPath, "Path to binary to remove")
+func cmdWhy() {
+	defaultStatePath := internal.GetStatePath()
+	defaultConfigPath := internal.GetConfigPath()
 
+	fs := flag.NewFlagSet("why", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+	jsonOutput := fs.Bool("json", false, "Print the decision trace as JSON")
 	if err := fs.Parse(os.Args[2:]); err != nil {
 		log.Fatalf("Failed to parse flags: %v", err)
 	}
 
-	// Show what will be removed
-	fmt.Println("kubectx-timeout Uninstallation")
-	fmt.Println(strings.Repeat("=", 60))
-	fmt.Println("\nThe following will be removed:")
-
-	// Check what's installed
-	daemonRunning, _ := internal.CheckDaemonStatus()
-	if daemonRunning {
-		fmt.Println("  - Daemon (will be stopped)")
-	}
-
-	plistPath, _ := internal.GetLaunchdPlistPath()
-	if plistPath != "" {
-		if _, err := os.Stat(plistPath); err == nil {
-			fmt.Println("  - Launchd configuration")
-		}
+	config, err := internal.LoadConfig(*configPath)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to load config: %v", err)
 	}
 
-	installedShells, _ := internal.GetInstalledShells()
-	if len(installedShells) > 0 {
-		fmt.Printf("  - Shell integration (%s)\n", strings.Join(installedShells, ", "))
+	stateManager, err := internal.OpenStateManager(*statePath, config)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to create state manager: %v", err)
 	}
 
-	configDir := internal.GetConfigDir()
-	stateDir := internal.GetStateDir()
-	hasConfig := false
-	hasState := false
+	config = withActiveProfile(config, stateManager)
 
-	if _, err := os.Stat(configDir); err == nil {
-		hasConfig = true
-	}
-	if _, err := os.Stat(stateDir); err == nil {
-		hasState = true
+	switcher := newSwitcher(config)
+	in, err := internal.GatherPolicyInputs(config, stateManager, switcher, time.Now())
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to gather policy inputs (the daemon can't check the timeout either): %v", err)
 	}
 
-	if !*keepConfig {
-		if hasConfig {
-			fmt.Printf("  - Configuration files (%s)\n", configDir)
-		}
-		if hasState {
-			fmt.Printf("  - State files (%s)\n", stateDir)
+	// Expired credentials don't change the decision but often explain failures
+	if stale, err := internal.FindStaleCredentials(internal.GetKubeconfigPaths(), in.Now); err == nil {
+		for _, cred := range stale {
+			if cred.Context == in.CurrentContext || cred.Context == in.DefaultContext {
+				in.StaleCredentials = append(in.StaleCredentials, cred.Describe(in.Now))
+			}
 		}
 	}
 
-	removeBinary := *all || !*keepBinary
-
-	if removeBinary {
-		if _, err := os.Stat(*binaryPath); err == nil {
-			fmt.Printf("  - Binary (%s)\n", *binaryPath)
+	// Like the daemon, only look for running tools once a switch is due
+	decision := internal.EvaluatePolicy(in)
+	processesChecked := false
+	if decision.Due() && config.Safety.CheckActiveKubectl {
+		if processes, err := internal.FindActiveKubeProcesses(); err == nil {
+			processesChecked = true
+			for _, p := range processes {
+				if p.UsesContext(in.CurrentContext) {
+					in.ActiveProcesses = append(in.ActiveProcesses, p.String())
+				}
+			}
+			decision = internal.EvaluatePolicy(in)
 		}
 	}
 
-	if *keepConfig {
-		fmt.Println("\nConfiguration and state files will be kept")
-	}
-	if *keepBinary {
-		fmt.Println("Binary will be kept")
-	}
+	daemonRunning := internal.NewPIDFile().IsRunning()
 
-	// Confirm unless --yes flag is set
-	if !*yes {
-		fmt.Print("\nDo you want to proceed with uninstallation? [y/N]: ")
-		reader := bufio.NewReader(os.Stdin)
-		response, err := reader.ReadString('\n')
+	if *jsonOutput {
+		trace := struct {
+			DaemonRunning bool                    `json:"daemon_running"`
+			Inputs        internal.PolicyInputs   `json:"inputs"`
+			Decision      internal.PolicyDecision `json:"decision"`
+		}{daemonRunning, in, decision}
+
+		data, err := json.MarshalIndent(trace, "", "  ")
 		if err != nil {
-			log.Fatalf("Failed to read input: %v", err)
-		}
-		response = strings.TrimSpace(strings.ToLower(response))
-		if response != "y" && response != "yes" {
-			fmt.Println("Uninstallation cancelled")
-			return
+			fatalf(exitCodeFor(err), "Failed to encode decision: %v", err)
 		}
+		fmt.Println(string(data))
+		return
 	}
 
-	// Perform uninstallation
-	fmt.Println("\nUninstalling kubectx-timeout...")
-
-	opts := internal.UninstallOptions{
-		KeepConfig:  *keepConfig,
-		KeepBinary:  !removeBinary,
-		Force:       *yes,
-		AllShells:   *allShells,
-		TargetShell: "",
-		BinaryPath:  *binaryPath,
+	fmt.Printf("Decision: %s\n", decision.Action)
+	for _, reason := range decision.Reasons {
+		fmt.Printf("  - %s\n", reason)
+	}
+	if !daemonRunning {
+		fmt.Println("  - the daemon is not running, so nothing will be switched")
 	}
 
-	// If specific shell is provided as argument
-	args := fs.Args()
-	if len(args) > 0 {
-		opts.TargetShell = args[0]
-		if !isValidShellArg(opts.TargetShell) {
-			log.Fatalf("Unsupported shell: %s\nSupported shells: bash, zsh, fish", opts.TargetShell)
-		}
+	fmt.Println()
+	printPolicyInputs(in, processesChecked)
+	if daemonRunning {
+		fmt.Println("  Daemon:            running")
+	} else {
+		fmt.Println("  Daemon:            not running")
+	}
+}
+
+func cmdSimulate() {
+	defaultStatePath := internal.GetStatePath()
+	defaultConfigPath := internal.GetConfigPath()
+
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file, for extensions and paused contexts")
+	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+	contextName := fs.String("context", "", "Context to simulate (default: the current context)")
+	idle := fs.Duration("idle", 0, "How long the context has been idle (e.g. 42m)")
+	at := fs.String("at", "", "When to simulate, for work-hours schedules: HH:MM today, or an RFC 3339 time (default: now)")
+	processes := fs.String("processes", "", "Comma-separated Kubernetes tools to assume are running, or 'none' (default: the ones running now)")
+	jsonOutput := fs.Bool("json", false, "Print the decision trace as JSON")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+	if *idle < 0 {
+		fatalf(exitUsage, "Invalid --idle %v: must not be negative", *idle)
+	}
+
+	config, err := internal.LoadConfig(*configPath)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to load config: %v", err)
+	}
+
+	sim := internal.Simulation{Context: config.ResolveContext(*contextName), Idle: *idle}
+	if sim.Context == "" {
+		if sim.Context, err = internal.GetCurrentContext(); err != nil {
+			fatalf(exitCodeFor(err), "Failed to get current context (name one with --context): %v", err)
+		}
+	}
+	if sim.At, err = parseSimulationTime(*at, time.Now()); err != nil {
+		fatalf(exitUsage, "Invalid --at: %v", err)
+	}
+	switch *processes {
+	case "":
+	case "none":
+		sim.ActiveProcesses = []string{}
+	default:
+		sim.ActiveProcesses = strings.Split(*processes, ",")
+	}
+
+	// Without a state file there are no extensions or pauses to consider
+	var store internal.StateStore
+	if _, err := os.Stat(*statePath); err == nil {
+		if store, err = internal.OpenStateManager(*statePath, config); err != nil {
+			fatalf(exitCodeFor(err), "Failed to create state manager: %v", err)
+		}
+		config = withActiveProfile(config, store)
+	}
+
+	in, decision, err := internal.SimulatePolicy(config, store, sim)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to simulate the timeout policy: %v", err)
+	}
+
+	if *jsonOutput {
+		trace := struct {
+			Inputs   internal.PolicyInputs   `json:"inputs"`
+			Decision internal.PolicyDecision `json:"decision"`
+		}{in, decision}
+
+		data, err := json.MarshalIndent(trace, "", "  ")
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to encode decision: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("Simulated decision: %s\n", decision.Action)
+	for _, reason := range decision.Reasons {
+		fmt.Printf("  - %s\n", reason)
+	}
+	fmt.Println()
+	printPolicyInputs(in, decision.Due() && config.Safety.CheckActiveKubectl)
+}
+
+// parseSimulationTime parses simulate's --at: a time of day today, a date
+// and time, or an RFC 3339 timestamp. An empty value is the zero time.
+func parseSimulationTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("15:04", value, time.Local); err == nil {
+		year, month, day := now.Date()
+		return time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, time.Local), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time of day (15:04), date and time (2006-01-02 15:04), or RFC 3339 time", value)
+}
+
+// printPolicyInputs prints what a timeout policy decision was based on, for
+// why and simulate
+func printPolicyInputs(in internal.PolicyInputs, processesChecked bool) {
+	optional := func(t time.Time) string {
+		if t.IsZero() || !in.Now.Before(t) {
+			return "-"
+		}
+		return fmt.Sprintf("%s (%s left)", t.Format("2006-01-02 15:04:05"), t.Sub(in.Now).Round(time.Second))
+	}
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	fmt.Println("Inputs:")
+	fmt.Printf("  Current Context:   %s\n", in.CurrentContext)
+	fmt.Printf("  Default Context:   %s\n", in.DefaultContext)
+	if in.LastActivity.IsZero() {
+		fmt.Println("  Last Activity:     No activity recorded")
+	} else {
+		fmt.Printf("  Last Activity:     %s (%s ago)\n",
+			in.LastActivity.Format("2006-01-02 15:04:05"), in.Idle().Round(time.Second))
+	}
+	if in.AfterHours {
+		fmt.Printf("  Timeout:           %s (after hours)\n", in.Timeout)
+	} else {
+		fmt.Printf("  Timeout:           %s\n", in.Timeout)
+	}
+	if in.WriteTimeout > 0 {
+		fmt.Printf("  Write Timeout:     %s (last write: %s)\n", in.WriteTimeout, optional(in.LastWrite))
+	}
+	if in.GracePeriod > 0 {
+		fmt.Printf("  Grace Period:      %s\n", in.GracePeriod)
+	} else {
+		fmt.Println("  Grace Period:      none")
+	}
+	fmt.Printf("  Never Switch From: %s\n", yesNo(in.NeverSwitchFrom))
+	fmt.Printf("  Default Forbidden: %s\n", yesNo(in.DefaultForbidden))
+	fmt.Printf("  Extended Until:    %s\n", optional(in.ExtendedUntil))
+	fmt.Printf("  Paused Until:      %s\n", optional(in.PausedUntil))
+	if in.Pending.IsZero() {
+		fmt.Println("  Pending Switch:    -")
+	} else {
+		fmt.Printf("  Pending Switch:    '%s' to '%s' at %s\n",
+			in.Pending.From, in.Pending.To, in.Pending.At.Format("2006-01-02 15:04:05"))
+	}
+	switch {
+	case !processesChecked:
+		fmt.Println("  Running Tools:     not checked")
+	case len(in.ActiveProcesses) == 0:
+		fmt.Println("  Running Tools:     none")
+	default:
+		fmt.Printf("  Running Tools:     %s\n", strings.Join(in.ActiveProcesses, ", "))
+	}
+	if in.MaxDefer > 0 {
+		fmt.Printf("  Max Defer:         %s\n", in.MaxDefer)
+	}
+}
+
+func cmdReset() {
+	defaultStatePath := internal.GetStatePath()
+	defaultConfigPath := internal.GetConfigPath()
+
+	fs := flag.NewFlagSet("reset", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	// Get current context
+	currentContext, err := internal.GetCurrentContext()
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to get current context: %v", err)
+	}
+
+	// Ask the daemon if it's running, otherwise update the state file directly
+	_, err = internal.SendControlRequest(internal.ControlSocketPathForState(*statePath),
+		internal.ControlRequest{Command: internal.ControlReset, Context: currentContext})
+	switch {
+	case err == nil:
+	case !errors.Is(err, internal.ErrControlUnavailable):
+		fatalf(exitCodeFor(err), "Failed to reset activity timer: %v", err)
+	default:
+		tracker, err := internal.NewActivityTracker(*statePath, *configPath)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to create activity tracker: %v", err)
+		}
+
+		if err := tracker.RecordActivity(); err != nil {
+			fatalf(exitCodeFor(err), "Failed to reset activity timer: %v", err)
+		}
+	}
+
+	fmt.Printf("✓ Activity timer reset for context '%s'\n", currentContext)
+	fmt.Println("  Timeout period has been reset to 0")
+}
+
+func cmdExtend() {
+	defaultStatePath := internal.GetStatePath()
+
+	fs := flag.NewFlagSet("extend", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	args := fs.Args()
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: Duration argument is required\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout extend <duration>\n\n")
+		fmt.Fprintf(os.Stderr, "Examples:\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout extend 30m\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout extend 2h\n")
+		os.Exit(exitUsage)
+	}
+
+	duration, err := time.ParseDuration(args[0])
+	if err != nil {
+		fatalf(exitUsage, "Invalid duration %q: %v", args[0], err)
+	}
+
+	// Ask the daemon if it's running, otherwise update the state file directly
+	var until time.Time
+	resp, err := internal.SendControlRequest(internal.ControlSocketPathForState(*statePath),
+		internal.ControlRequest{Command: internal.ControlPause, Duration: duration.String()})
+	switch {
+	case err == nil:
+		until = *resp.Until
+	case !errors.Is(err, internal.ErrControlUnavailable):
+		fatalf(exitCodeFor(err), "Failed to extend deadline: %v", err)
+	default:
+		stateManager, err := internal.OpenStateManager(*statePath, stateConfig())
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to create state manager: %v", err)
+		}
+
+		until, err = internal.ExtendDeadline(stateManager, duration)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to extend deadline: %v", err)
+		}
+	}
+
+	fmt.Printf("✓ Timeout switching suppressed until %s\n", until.Format("2006-01-02 15:04:05"))
+}
+
+func cmdPauseContext() {
+	defaultStatePath := internal.GetStatePath()
+
+	fs := flag.NewFlagSet("pause-context", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	clearPause := fs.Bool("clear", false, "End the context's pause early")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	args := fs.Args()
+	if (*clearPause && len(args) != 1) || (!*clearPause && len(args) != 2) {
+		fmt.Fprintf(os.Stderr, "Error: Context name and duration arguments are required\n\n")
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout pause-context <name> <duration>\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout pause-context --clear <name>\n\n")
+		fmt.Fprintf(os.Stderr, "Examples:\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout pause-context prod-eu 4h\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout pause-context --clear prod-eu\n")
+		os.Exit(exitUsage)
+	}
+	config := stateConfig()
+	contextName := args[0]
+	if config != nil {
+		contextName = config.ResolveContext(contextName)
+	}
+	socketPath := internal.ControlSocketPathForState(*statePath)
+
+	stateManager, err := internal.OpenStateManager(*statePath, config)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to create state manager: %v", err)
+	}
+
+	if *clearPause {
+		// Ask the daemon if it's running, otherwise update the state file directly
+		var paused bool
+		resp, err := internal.SendControlRequest(socketPath,
+			internal.ControlRequest{Command: internal.ControlResume, Context: contextName})
+		switch {
+		case err == nil:
+			paused = resp.Changed
+		case !errors.Is(err, internal.ErrControlUnavailable):
+			fatalf(exitCodeFor(err), "Failed to clear pause: %v", err)
+		default:
+			paused, err = internal.ResumeContext(stateManager, contextName)
+			if err != nil {
+				fatalf(exitCodeFor(err), "Failed to clear pause: %v", err)
+			}
+		}
+		if !paused {
+			fmt.Printf("Context %s is not paused\n", quoteContext(config, contextName))
+			return
+		}
+		fmt.Printf("✓ Pause cleared for context %s\n", quoteContext(config, contextName))
+		return
+	}
+
+	duration, err := time.ParseDuration(args[1])
+	if err != nil {
+		fatalf(exitUsage, "Invalid duration %q: %v", args[1], err)
+	}
+
+	// A typo would silently pause nothing, so check the name against kubeconfig
+	if contexts, err := internal.GetAvailableContexts(); err == nil && !slices.Contains(contexts, contextName) {
+		fmt.Printf("Warning: context '%s' not found in kubeconfig\n", contextName)
+	}
+
+	var until time.Time
+	resp, err := internal.SendControlRequest(socketPath,
+		internal.ControlRequest{Command: internal.ControlPause, Context: contextName, Duration: duration.String()})
+	switch {
+	case err == nil:
+		until = *resp.Until
+	case !errors.Is(err, internal.ErrControlUnavailable):
+		fatalf(exitCodeFor(err), "Failed to pause context: %v", err)
+	default:
+		until, err = internal.PauseContext(stateManager, contextName, duration)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to pause context: %v", err)
+		}
+	}
+
+	fmt.Printf("✓ Timeout switching away from %s suppressed until %s\n", quoteContext(config, contextName), until.Format("2006-01-02 15:04:05"))
+	fmt.Println("  Other contexts keep their timeouts")
+}
+
+func cmdCancelSwitch() {
+	defaultStatePath := internal.GetStatePath()
+
+	fs := flag.NewFlagSet("cancel-switch", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	// Ask the daemon if it's running, otherwise update the state file directly
+	var pending internal.PendingSwitch
+	resp, err := internal.SendControlRequest(internal.ControlSocketPathForState(*statePath),
+		internal.ControlRequest{Command: internal.ControlCancelSwitch})
+	switch {
+	case err == nil:
+		if resp.Changed {
+			pending = internal.PendingSwitch{From: resp.FromContext, To: resp.ToContext}
+		}
+	case !errors.Is(err, internal.ErrControlUnavailable):
+		fatalf(exitCodeFor(err), "Failed to cancel switch: %v", err)
+	default:
+		config := stateConfig()
+		stateManager, err := internal.OpenStateManager(*statePath, config)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to create state manager: %v", err)
+		}
+
+		pending, err = internal.CancelPendingSwitch(stateManager)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to cancel switch: %v", err)
+		}
+
+		if !pending.IsZero() {
+			if err := appendHistory(*statePath, config, internal.HistoryEvent{
+				Type:    internal.HistoryActivity,
+				Context: pending.From,
+				Reason:  "switch canceled",
+			}); err != nil {
+				fmt.Printf("Warning: Failed to record history: %v\n", err)
+			}
+		}
+	}
+
+	if pending.IsZero() {
+		fmt.Println("No context switch is pending")
+		return
+	}
+
+	fmt.Printf("✓ Canceled switch from '%s' to '%s'\n", pending.From, pending.To)
+	fmt.Println("  Activity timer reset, the timeout starts over")
+}
+
+func cmdProfile() {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: kubectx-timeout profile <list|use|clear> [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Subcommands:\n")
+		fmt.Fprintf(os.Stderr, "  list                   List the configured profiles, marking the one in use\n")
+		fmt.Fprintf(os.Stderr, "  use <name> [--for D]   Use a profile's timeouts, until cleared or for a duration\n")
+		fmt.Fprintf(os.Stderr, "  clear                  Go back to the timeouts without a profile\n\n")
+		fmt.Fprintf(os.Stderr, "Examples:\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout profile use oncall --for 8h\n")
+		fmt.Fprintf(os.Stderr, "  kubectx-timeout profile clear\n")
+		os.Exit(exitUsage)
+	}
+
+	subcommand, args := "list", os.Args[2:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		subcommand, args = args[0], args[1:]
+	}
+
+	switch subcommand {
+	case "list":
+		cmdProfileList(args)
+	case "use":
+		cmdProfileUse(args)
+	case "clear":
+		cmdProfileClear(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown profile subcommand: %s\n\n", subcommand)
+		usage()
+	}
+}
+
+func cmdProfileList(args []string) {
+	fs := flag.NewFlagSet("profile list", flag.ExitOnError)
+	statePath := fs.String("state", internal.GetStatePath(), "Path to state file")
+	configPath := fs.String("config", internal.GetConfigPath(), "Path to configuration file")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	config, err := internal.LoadConfig(*configPath)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to load config: %v", err)
+	}
+	if len(config.Profiles) == 0 {
+		fmt.Printf("No profiles configured; add them under profiles: in %s\n", *configPath)
+		return
+	}
+
+	var active internal.ActiveProfile
+	if stateManager, err := internal.OpenStateManager(*statePath, config); err == nil {
+		if state, err := stateManager.Load(); err == nil {
+			active = state.ActiveProfile()
+		}
+	}
+	now := time.Now()
+
+	names := config.ProfileNames()
+	width := 0
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	for _, name := range names {
+		marker := " "
+		if name == active.Name && active.ActiveAt(now) {
+			marker = "▶"
+		}
+		line := fmt.Sprintf("%s %-*s  %s", marker, width, name, config.Profiles[name].Description)
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	if active.ActiveAt(now) {
+		fmt.Printf("\nUsing '%s' %s\n", active.Name, describeProfileUntil(active, now))
+	}
+}
+
+func cmdProfileUse(args []string) {
+	fs := flag.NewFlagSet("profile use", flag.ExitOnError)
+	statePath := fs.String("state", internal.GetStatePath(), "Path to state file")
+	configPath := fs.String("config", internal.GetConfigPath(), "Path to configuration file")
+	duration := fs.Duration("for", 0, "Go back to the timeouts without a profile after this long (default: until cleared)")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	// Allow flags after the name too, as in "profile use oncall --for 8h"
+	name := fs.Arg(0)
+	if fs.NArg() > 0 {
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			log.Fatalf("Failed to parse flags: %v", err)
+		}
+	}
+	if name == "" || fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Usage: kubectx-timeout profile use <name> [--for <duration>]\n")
+		os.Exit(exitUsage)
+	}
+	if *duration < 0 {
+		fatalf(exitUsage, "Invalid --for %v: must not be negative", *duration)
+	}
+
+	req := internal.ControlRequest{Command: internal.ControlProfile, Profile: name}
+	if *duration > 0 {
+		req.Duration = duration.String()
+	}
+
+	// Ask the daemon if it's running, otherwise update the state file directly
+	profile := internal.ActiveProfile{Name: name}
+	resp, err := internal.SendControlRequest(internal.ControlSocketPathForState(*statePath), req)
+	switch {
+	case err == nil:
+		if resp.Until != nil {
+			profile.Until = *resp.Until
+		}
+	case !errors.Is(err, internal.ErrControlUnavailable):
+		fatalf(exitCodeFor(err), "Failed to use profile: %v", err)
+	default:
+		config, err := internal.LoadConfig(*configPath)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to load config: %v", err)
+		}
+		if _, err := config.WithProfile(name); err != nil {
+			fatalf(exitUsage, "Failed to use profile: %v", err)
+		}
+		stateManager, err := internal.OpenStateManager(*statePath, config)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to create state manager: %v", err)
+		}
+
+		if *duration > 0 {
+			profile.Until = time.Now().Add(*duration)
+		}
+		if err := internal.SetProfile(stateManager, profile); err != nil {
+			fatalf(exitCodeFor(err), "Failed to use profile: %v", err)
+		}
+	}
+
+	fmt.Printf("✓ Using profile '%s' %s\n", name, describeProfileUntil(profile, time.Now()))
+}
+
+func cmdProfileClear(args []string) {
+	fs := flag.NewFlagSet("profile clear", flag.ExitOnError)
+	statePath := fs.String("state", internal.GetStatePath(), "Path to state file")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	// Ask the daemon if it's running, otherwise update the state file directly
+	_, err := internal.SendControlRequest(internal.ControlSocketPathForState(*statePath),
+		internal.ControlRequest{Command: internal.ControlProfile})
+	switch {
+	case err == nil:
+	case !errors.Is(err, internal.ErrControlUnavailable):
+		fatalf(exitCodeFor(err), "Failed to clear profile: %v", err)
+	default:
+		stateManager, err := internal.OpenStateManager(*statePath, stateConfig())
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to create state manager: %v", err)
+		}
+		if err := internal.SetProfile(stateManager, internal.ActiveProfile{}); err != nil {
+			fatalf(exitCodeFor(err), "Failed to clear profile: %v", err)
+		}
+	}
+
+	fmt.Println("✓ Profile cleared, the timeouts without a profile apply")
+}
+
+// describeProfileUntil says how long a profile in use lasts
+func describeProfileUntil(profile internal.ActiveProfile, now time.Time) string {
+	if profile.Until.IsZero() {
+		return "until cleared with: kubectx-timeout profile clear"
+	}
+	return fmt.Sprintf("until %s (%s left)", profile.Until.Format("2006-01-02 15:04:05"),
+		profile.Until.Sub(now).Round(time.Second))
+}
+
+// newSwitcher returns a context switcher set up as the daemon's is, with
+// config's kube_client, kubectl_timeout, and retry policy
+func newSwitcher(config *internal.Config) *internal.ContextSwitcher {
+	internal.SetKubectlTimeout(config.KubectlTimeout)
+	switcher := internal.NewContextSwitcherWithClient(nil, internal.NewKubeClient(config.KubeClient, ""))
+	switcher.SetRetryPolicy(config.Switcher)
+	return switcher
+}
+
+// withActiveProfile applies the profile chosen with the profile command to
+// config, as the daemon does
+func withActiveProfile(config *internal.Config, store internal.StateStore) *internal.Config {
+	if store == nil {
+		return config
+	}
+	state, err := store.Load()
+	if err != nil {
+		return config
+	}
+	withProfile, err := config.WithActiveProfile(state.ActiveProfile(), time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring profile: %v\n", err)
+		return config
+	}
+	return withProfile
+}
+
+func cmdSwitchNow() {
+	defaultStatePath := internal.GetStatePath()
+	defaultConfigPath := internal.GetConfigPath()
+
+	fs := flag.NewFlagSet("switch-now", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	// Let a running daemon switch, so it can't race with a timeout check
+	resp, err := internal.SendControlRequest(internal.ControlSocketPathForState(*statePath),
+		internal.ControlRequest{Command: internal.ControlForceSwitch})
+	switch {
+	case err == nil:
+		labels := labelConfig(*configPath)
+		if !resp.Changed {
+			fmt.Printf("Already on default context %s\n", quoteContext(labels, resp.ToContext))
+			return
+		}
+		fmt.Printf("✓ Switched from %s to %s\n", quoteContext(labels, resp.FromContext), quoteContext(labels, resp.ToContext))
+		return
+	case !errors.Is(err, internal.ErrControlUnavailable):
+		fatalf(exitCodeFor(err), "Failed to switch context: %v", err)
+	}
+
+	config, err := internal.LoadConfig(*configPath)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to load config: %v", err)
+	}
+
+	switcher := newSwitcher(config)
+	currentContext, err := switcher.CurrentContext()
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to get current context: %v", err)
+	}
+	defaultContext := config.GetDefaultContextFor(currentContext)
+	if currentContext == defaultContext {
+		fmt.Printf("Already on default context %s\n", quoteContext(config, defaultContext))
+		return
+	}
+
+	event := internal.SwitchEvent{
+		FromContext: currentContext,
+		ToContext:   defaultContext,
+		Reason:      internal.SwitchNowReason,
+	}
+	for _, err := range config.Hooks.Run(internal.HookPreSwitch, event) {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if err := switcher.SwitchContextSafe(defaultContext, config.IsNeverSwitchTo); err != nil {
+		event.Error = err.Error()
+		for _, err := range config.Hooks.Run(internal.HookOnSwitchFailure, event) {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		fatalf(exitCodeFor(err), "Failed to switch context: %v", err)
+	}
+
+	stateManager, err := internal.OpenStateManager(*statePath, config)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to create state manager: %v", err)
+	}
+	if err := internal.RecordActivity(stateManager, defaultContext); err != nil {
+		fmt.Printf("Warning: Failed to record activity: %v\n", err)
+	}
+	if err := internal.ClearPendingSwitch(stateManager); err != nil {
+		fmt.Printf("Warning: Failed to clear pending switch: %v\n", err)
+	}
+	if err := appendHistory(*statePath, config, internal.HistoryEvent{
+		Type:        internal.HistorySwitch,
+		Context:     defaultContext,
+		FromContext: currentContext,
+		Reason:      internal.SwitchNowReason,
+	}); err != nil {
+		fmt.Printf("Warning: Failed to record history: %v\n", err)
+	}
+
+	for _, err := range config.Hooks.Run(internal.HookPostSwitch, event) {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	notifier := internal.NewNotifier(config.Notifications)
+	if err := notifier.NotifySwitch(event); err != nil {
+		fmt.Printf("Warning: Failed to send notification: %v\n", err)
+	}
+
+	fmt.Printf("✓ Switched from %s to %s\n", quoteContext(config, currentContext), quoteContext(config, defaultContext))
+}
+
+func cmdUndo() {
+	defaultStatePath := internal.GetStatePath()
+	defaultConfigPath := internal.GetConfigPath()
+
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	// Let a running daemon switch back, so it can't race with a timeout check
+	resp, err := internal.SendControlRequest(internal.ControlSocketPathForState(*statePath),
+		internal.ControlRequest{Command: internal.ControlSwitchBack})
+	switch {
+	case err == nil:
+		printUndone(labelConfig(*configPath), resp.FromContext, resp.ToContext, resp.Changed)
+		return
+	case !errors.Is(err, internal.ErrControlUnavailable):
+		fatalf(exitCodeFor(err), "Cannot undo: %v", err)
+	}
+
+	config, err := internal.LoadConfig(*configPath)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to load config: %v", err)
+	}
+	stateManager, err := internal.OpenStateManager(*statePath, config)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to create state manager: %v", err)
+	}
+
+	state, err := stateManager.Load()
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to read state: %v", err)
+	}
+	last := state.LastSwitch()
+	if err := last.CheckUndo(config.Timeout.UndoWindow, time.Now()); err != nil {
+		fatalf(exitFailure, "Cannot undo: %v", err)
+	}
+
+	switcher := newSwitcher(config)
+	currentContext, err := switcher.CurrentContext()
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to get current context: %v", err)
+	}
+
+	changed := currentContext != last.From
+	if changed {
+		event := internal.SwitchEvent{
+			FromContext: currentContext,
+			ToContext:   last.From,
+			Reason:      internal.SwitchBackReason,
+		}
+		for _, err := range config.Hooks.Run(internal.HookPreSwitch, event) {
+			fmt.Printf("Warning: %v\n", err)
+		}
+
+		if err := switcher.SwitchContextSafe(last.From, config.IsNeverSwitchTo); err != nil {
+			event.Error = err.Error()
+			for _, err := range config.Hooks.Run(internal.HookOnSwitchFailure, event) {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			fatalf(exitCodeFor(err), "Failed to switch context: %v", err)
+		}
+
+		if err := appendHistory(*statePath, config, internal.HistoryEvent{
+			Type:        internal.HistorySwitch,
+			Context:     last.From,
+			FromContext: currentContext,
+			Reason:      internal.SwitchBackReason,
+		}); err != nil {
+			fmt.Printf("Warning: Failed to record history: %v\n", err)
+		}
+
+		for _, err := range config.Hooks.Run(internal.HookPostSwitch, event) {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	// Reset the timer, so the context isn't switched away from again at once
+	if err := internal.RecordActivity(stateManager, last.From); err != nil {
+		fmt.Printf("Warning: Failed to record activity: %v\n", err)
+	}
+	if err := internal.SetLastSwitch(stateManager, internal.LastSwitch{}); err != nil {
+		fmt.Printf("Warning: Failed to forget undone switch: %v\n", err)
+	}
+	if err := internal.RemoveSwitchNotice(internal.SwitchNoticePathForState(*statePath)); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	printUndone(config, currentContext, last.From, changed)
+}
+
+// printUndone reports the result of undo, labeling contexts with the
+// aliases in config if it isn't nil
+func printUndone(config *internal.Config, fromContext, toContext string, changed bool) {
+	if changed {
+		fmt.Printf("✓ Switched back from %s to %s\n", quoteContext(config, fromContext), quoteContext(config, toContext))
+	} else {
+		fmt.Printf("Already on %s\n", quoteContext(config, toContext))
+	}
+	fmt.Println("  Activity timer reset, the timeout starts over")
+}
+
+func cmdHistory() {
+	if len(os.Args) > 2 && os.Args[2] == "prune" {
+		cmdHistoryPrune(os.Args[3:])
+		return
+	}
+
+	defaultStatePath := internal.GetStatePath()
+
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	contextName := fs.String("context", "", "Only show events in or switching away from this context")
+	eventType := fs.String("type", "", "Only show events of this type (activity, context_change, switch)")
+	since := fs.String("since", "", "Only show events after this time (e.g. 24h ago, 2026-01-02, or RFC 3339)")
+	until := fs.String("until", "", "Only show events before this time (same formats as --since)")
+	limit := fs.Int("limit", 0, "Only show the most recent events (0 for all)")
+	jsonOutput := fs.Bool("json", false, "Output events as JSON lines")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	config := stateConfig()
+	if config != nil {
+		*contextName = config.ResolveContext(*contextName)
+	}
+
+	filter := internal.HistoryFilter{Context: *contextName, Type: *eventType}
+	switch *eventType {
+	case "", internal.HistoryActivity, internal.HistoryContextChange, internal.HistorySwitch:
+	default:
+		fatalf(exitUsage, "Invalid --type %q: must be activity, context_change, or switch", *eventType)
+	}
+
+	now := time.Now()
+	var err error
+	if filter.Since, err = parseHistoryTime(*since, now); err != nil {
+		fatalf(exitUsage, "Invalid --since: %v", err)
+	}
+	if filter.Until, err = parseHistoryTime(*until, now); err != nil {
+		fatalf(exitUsage, "Invalid --until: %v", err)
+	}
+
+	history, err := internal.OpenHistory(*statePath, config)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to open history: %v", err)
+	}
+	events, err := history.Read(filter)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to read history: %v", err)
+	}
+	if *limit > 0 && len(events) > *limit {
+		events = events[len(events)-*limit:]
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				fatalf(exitCodeFor(err), "Failed to encode event: %v", err)
+			}
+		}
+		return
+	}
+
+	if len(events) == 0 {
+		fmt.Println("No matching history")
+		return
+	}
+
+	for _, event := range events {
+		line := fmt.Sprintf("%s  %-14s  ", event.Time.Local().Format("2006-01-02 15:04:05"), event.Type)
+		if event.FromContext != "" {
+			line += fmt.Sprintf("%s → %s", event.FromContext, event.Context)
+		} else {
+			line += event.Context
+		}
+		if event.Verb != "" {
+			line += fmt.Sprintf(": %s", strings.TrimSpace(event.Verb+" "+event.Resource))
+		}
+		if event.Reason != "" {
+			line += fmt.Sprintf(" (%s)", event.Reason)
+		}
+		if event.Kubeconfig != "" {
+			line += fmt.Sprintf(" [KUBECONFIG=%s]", event.Kubeconfig)
+		}
+		fmt.Println(line)
+	}
+}
+
+// stateConfig loads the default config file for commands that open the
+// state file or history log, which state.encrypt may encrypt, without a
+// --config flag of their own. A config that can't be loaded is nil, which
+// reads and writes them unencrypted.
+func stateConfig() *internal.Config {
+	config, err := internal.LoadConfig(internal.GetConfigPath())
+	if err != nil {
+		return nil
+	}
+	return config
+}
+
+// profileBackups returns how many shell profile backups the configuration
+// keeps, or 0, the default, if it can't be read
+func profileBackups(path string) int {
+	config, err := internal.LoadConfig(path)
+	if err != nil {
+		return 0
+	}
+	return config.Shell.ProfileBackups
+}
+
+// labelConfig reads the configuration only to label contexts with their
+// aliases, returning nil if it can't be read
+func labelConfig(path string) *internal.Config {
+	config, err := internal.ReadConfig(path)
+	if err != nil {
+		return nil
+	}
+	return config
+}
+
+// contextLabel names a context along with its alias, if it has one, such
+// as "gke_acme_us-central1_prod (p)"
+func contextLabel(config *internal.Config, name string) string {
+	if config != nil {
+		if alias := config.ContextAlias(name); alias != "" {
+			return fmt.Sprintf("%s (%s)", name, alias)
+		}
+	}
+	return name
+}
+
+// quoteContext quotes a context name for messages, followed by its alias
+// if it has one
+func quoteContext(config *internal.Config, name string) string {
+	if config != nil {
+		if alias := config.ContextAlias(name); alias != "" {
+			return fmt.Sprintf("'%s' (%s)", name, alias)
+		}
+	}
+	return "'" + name + "'"
+}
+
+// appendHistory adds an event to the history log kept beside the state file
+func appendHistory(statePath string, config *internal.Config, event internal.HistoryEvent) error {
+	history, err := internal.OpenHistory(statePath, config)
+	if err != nil {
+		return err
+	}
+	return history.Append(event)
+}
+
+// cmdHistoryPrune compacts the history log to the configured retention,
+// or the one given by flags
+func cmdHistoryPrune(args []string) {
+	fs := flag.NewFlagSet("history prune", flag.ExitOnError)
+	statePath := fs.String("state", internal.GetStatePath(), "Path to state file")
+	configPath := fs.String("config", internal.GetConfigPath(), "Path to configuration file")
+	maxEntries := fs.Int("max-entries", -1, "Keep only this many of the most recent events (default history.max_entries)")
+	maxAge := fs.Duration("max-age", -1, "Remove events older than this, e.g. 720h (default history.max_age)")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	config, configErr := internal.LoadConfig(*configPath)
+	retention := internal.HistoryConfig{MaxEntries: *maxEntries, MaxAge: *maxAge}
+	if *maxEntries < 0 || *maxAge < 0 {
+		if configErr != nil {
+			fatalf(exitCodeFor(configErr), "Failed to load config: %v", configErr)
+		}
+		if *maxEntries < 0 {
+			retention.MaxEntries = config.History.MaxEntries
+		}
+		if *maxAge < 0 {
+			retention.MaxAge = config.History.MaxAge
+		}
+	}
+	if retention.MaxEntries == 0 && retention.MaxAge == 0 {
+		fmt.Fprintln(os.Stderr, "No history retention configured: set history.max_entries or history.max_age, or pass --max-entries or --max-age")
+		os.Exit(exitConfig)
+	}
+
+	history, err := internal.OpenHistory(*statePath, config)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to open history: %v", err)
+	}
+	removed, err := history.Prune(retention, time.Now())
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to prune history: %v", err)
+	}
+	fmt.Printf("Removed %d history events\n", removed)
+}
+
+func cmdStats() {
+	defaultStatePath := internal.GetStatePath()
+
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	since := fs.String("since", "168h", "Start of the window (e.g. 24h ago, 2026-01-02, or RFC 3339)")
+	until := fs.String("until", "", "End of the window, now if unset (same formats as --since)")
+	top := fs.Int("top", 10, "Number of contexts and commands to list (0 for all)")
+	jsonOutput := fs.Bool("json", false, "Output stats as JSON")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	now := time.Now()
+	sinceTime, err := parseHistoryTime(*since, now)
+	if err != nil {
+		fatalf(exitUsage, "Invalid --since: %v", err)
+	}
+	untilTime, err := parseHistoryTime(*until, now)
+	if err != nil {
+		fatalf(exitUsage, "Invalid --until: %v", err)
+	}
+	if untilTime.IsZero() {
+		untilTime = now
+	}
+	if !untilTime.After(sinceTime) {
+		fatalf(exitUsage, "Invalid window: --since must be before --until")
+	}
+
+	// Earlier events are needed to know which context was current at the start
+	history, err := internal.OpenHistory(*statePath, stateConfig())
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to open history: %v", err)
+	}
+	events, err := history.Read(internal.HistoryFilter{Until: untilTime})
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to read history: %v", err)
+	}
+
+	stats := internal.ComputeStats(events, sinceTime, untilTime)
+	if *top > 0 && len(stats.Contexts) > *top {
+		stats.Contexts = stats.Contexts[:*top]
+	}
+	if *top > 0 && len(stats.Commands) > *top {
+		stats.Commands = stats.Commands[:*top]
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to encode stats: %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Println("kubectx-timeout Usage")
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("Window:           %s to %s\n",
+		sinceTime.Format("2006-01-02 15:04"), untilTime.Format("2006-01-02 15:04"))
+
+	if len(stats.Contexts) == 0 {
+		fmt.Println("No recorded history in this window")
+		return
+	}
+
+	fmt.Printf("Auto Switches:    %d\n", stats.AutoSwitches)
+	fmt.Printf("Manual Switches:  %d\n", stats.ManualSwitches)
+	if stats.AutoSwitches > 0 {
+		fmt.Printf("Avg Idle Before:  %v\n", stats.AverageIdle.Round(time.Second))
+	}
+
+	fmt.Println()
+	fmt.Printf("%-30s %12s %10s %14s\n", "CONTEXT", "TIME", "ACTIVITY", "AUTO SWITCHES")
+	for _, c := range stats.Contexts {
+		fmt.Printf("%-30s %12v %10d %14d\n", c.Context, c.Time.Round(time.Minute), c.Activity, c.AutoSwitches)
+	}
+
+	// Only recorded with tracking.metadata on
+	if len(stats.Commands) > 0 {
+		fmt.Println()
+		fmt.Printf("%-30s %12s\n", "COMMAND", "COUNT")
+		for _, c := range stats.Commands {
+			fmt.Printf("%-30s %12d\n", c.Command, c.Count)
+		}
+	}
+}
+
+// parseHistoryTime parses a history time bound: a duration before now, a
+// date, or an RFC 3339 timestamp. An empty value is the zero time.
+func parseHistoryTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a duration, date (2006-01-02), or RFC 3339 time", value)
+}
+
+func cmdLogs() {
+	defaultStatePath := internal.GetStatePath()
+	defaultConfigPath := internal.GetConfigPath()
+
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	statePath := fs.String("state", defaultStatePath, "Path to state file")
+	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+	follow := fs.Bool("f", false, "Keep printing new output as it is written")
+	fs.BoolVar(follow, "follow", false, "Same as -f")
+	lines := fs.Int("n", 100, "Number of lines to print from the end of each file")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	config, err := internal.LoadConfig(*configPath)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to load config: %v", err)
+	}
+
+	paths := internal.DaemonLogPaths(config, filepath.Dir(*statePath))
+	var found []string
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		}
+	}
+
+	out := &logOutput{headers: len(found) > 1}
+	offsets := make(map[string]int64)
+	for _, path := range found {
+		tail, size, err := internal.TailLines(path, *lines)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to read log: %v", err)
+		}
+		offsets[path] = size
+		if len(tail) > 0 {
+			fmt.Fprint(out.forFile(path), strings.Join(tail, "\n")+"\n")
+		}
+	}
+
+	if len(found) == 0 {
+		fmt.Println("No daemon logs found. Looked for:")
+		for _, path := range paths {
+			fmt.Printf("  %s\n", path)
+		}
+		if !*follow {
+			return
+		}
+		// Wait for the daemon to start writing its main log
+		found = paths[:1]
+	}
+	if !*follow {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for _, path := range found {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := internal.FollowFile(ctx, path, offsets[path], out.forFile(path)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// logOutput prints output from log files to stdout. With headers set, a
+// tail-style "==> path <==" header marks each change of file.
+type logOutput struct {
+	headers bool
+
+	mu   sync.Mutex
+	last string
+}
+
+// forFile returns a writer for one file's output
+func (o *logOutput) forFile(path string) io.Writer {
+	return logFileWriter{output: o, path: path}
+}
+
+type logFileWriter struct {
+	output *logOutput
+	path   string
+}
+
+func (w logFileWriter) Write(p []byte) (int, error) {
+	w.output.mu.Lock()
+	defer w.output.mu.Unlock()
+
+	if w.output.headers && w.output.last != w.path {
+		if w.output.last != "" {
+			fmt.Println()
+		}
+		fmt.Printf("==> %s <==\n", w.path)
+		w.output.last = w.path
+	}
+	return os.Stdout.Write(p)
+}
+
+// cmdPrompt runs on every prompt, so it only reads the status summary the
+// daemon keeps up to date: no config, state file, or kubectl
+func cmdPrompt() {
+	fs := flag.NewFlagSet("prompt", flag.ExitOnError)
+	statePath := fs.String("state", internal.GetStatePath(), "Path to state file")
+	warnAt := fs.Duration("warn", 10*time.Minute, "Remaining time below which the segment turns yellow")
+	criticalAt := fs.Duration("critical", 2*time.Minute, "Remaining time below which the segment turns red")
+	color := fs.String("color", internal.PromptColorNever, "Coloring: never, ansi, bash (for PS1), or zsh (for PROMPT)")
+	leftBelow := fs.Duration("left-below", 0, "Print only a line such as \"[prod: 7m left]\", once less than this remains (for shell.remaining_time)")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+	if !internal.ValidPromptColor(*color) {
+		fatalf(exitUsage, "Invalid --color %q: must be never, ansi, bash, or zsh", *color)
+	}
+
+	// A missing or unreadable summary means the daemon isn't running; print
+	// nothing rather than clutter the prompt with an error
+	summary, err := internal.ReadStatusSummary(internal.StatusSummaryPathForState(*statePath))
+	if err != nil {
+		return
+	}
+
+	if *leftBelow > 0 {
+		if text := internal.RemainingNotice(summary, time.Now(), *leftBelow); text != "" {
+			fmt.Println(text)
+		}
+		return
+	}
+
+	text, level := internal.PromptSegment(summary, time.Now(), internal.PromptOptions{
+		WarnAt:     *warnAt,
+		CriticalAt: *criticalAt,
+	})
+	if text != "" {
+		fmt.Print(internal.ColorPrompt(text, level, *color))
+	}
+}
+
+func cmdMenubar() {
+	fs := flag.NewFlagSet("menubar", flag.ExitOnError)
+	statePath := fs.String("state", internal.GetStatePath(), "Path to state file")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	if err := internal.RunMenuBar(internal.ControlSocketPathForState(*statePath)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCodeFor(err))
+	}
+}
+
+func cmdUI() {
+	fs := flag.NewFlagSet("ui", flag.ExitOnError)
+	configPath := fs.String("config", internal.GetConfigPath(), "Path to configuration file")
+	statePath := fs.String("state", internal.GetStatePath(), "Path to state file")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	if err := internal.RunDashboard(*configPath, *statePath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCodeFor(err))
+	}
+}
+
+func cmdPruneContexts() {
+	defaultConfigPath := internal.GetConfigPath()
+
+	fs := flag.NewFlagSet("prune-contexts", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfigPath, "Path to configuration file")
+	dryRun := fs.Bool("dry-run", false, "List contexts with expired credentials without removing them")
+	yes := fs.Bool("yes", false, "Skip confirmation prompts")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	now := time.Now()
+	stale, err := internal.FindStaleCredentials(internal.GetKubeconfigPaths(), now)
+	if err != nil {
+		fatalf(exitCodeFor(err), "Failed to check kubeconfig credentials: %v", err)
+	}
+
+	// Never remove the context in use or the safe contexts the daemon switches to
+	protected := map[string]bool{}
+	if currentContext, err := internal.GetCurrentContext(); err == nil {
+		protected[currentContext] = true
+	}
+	if config, err := internal.LoadConfig(*configPath); err == nil {
+		protected[config.DefaultContext] = true
+		for _, ctx := range config.Contexts {
+			if ctx.DefaultContext != "" {
+				protected[ctx.DefaultContext] = true
+			}
+		}
+	}
+
+	var contexts []string
+	seen := map[string]bool{}
+	for _, cred := range stale {
+		fmt.Printf("  ⚠ %s\n", cred.Describe(now))
+		if seen[cred.Context] {
+			continue
+		}
+		seen[cred.Context] = true
+		if protected[cred.Context] {
+			fmt.Printf("    Keeping '%s' (current or default context)\n", cred.Context)
+			continue
+		}
+		contexts = append(contexts, cred.Context)
+	}
+
+	if len(contexts) == 0 {
+		fmt.Println("✓ No contexts to prune")
+		return
+	}
+
+	if *dryRun {
+		fmt.Printf("\nWould remove %d context(s): %s\n", len(contexts), strings.Join(contexts, ", "))
+		return
+	}
+
+	if !*yes {
+		fmt.Printf("\nRemove %d context(s) from your kubeconfig: %s? [y/N]: ", len(contexts), strings.Join(contexts, ", "))
+		reader := bufio.NewReader(os.Stdin)
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to read input: %v", err)
+		}
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Pruning cancelled")
+			return
+		}
+	}
+
+	failed := false
+	for _, contextName := range contexts {
+		if err := internal.DeleteContext(contextName); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			failed = true
+			continue
+		}
+		fmt.Printf("✓ Removed context '%s'\n", contextName)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func cmdUninstall() {
+	// Detect the current binary path
+	defaultBinaryPath := "/usr/local/bin/kubectx-timeout" // fallback default
+	if execPath, err := os.Executable(); err == nil {
+		// Get absolute path
+		if absPath, err := filepath.Abs(execPath); err == nil {
+			defaultBinaryPath = absPath
+		}
+	}
+
+	fs := flag.NewFlagSet("uninstall", flag.ExitOnError)
+	all := fs.Bool("all", false, "Remove everything including binary")
+	keepConfig := fs.Bool("keep-config", false, "Keep configuration and state files")
+	keepBinary := fs.Bool("keep-binary", false, "Keep the binary (remove everything else)")
+	yes := fs.Bool("yes", false, "Skip confirmation prompts")
+	allShells := fs.Bool("all-shells", false, "Remove from all shell profiles (bash, zsh, fish, powershell)")
+	binaryPath := fs.String("binary", defaultBinaryPath, "Path to binary to remove")
+
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	// A specific shell can be provided as argument; reject a bad one before
+	// asking for confirmation
+	targetShell := ""
+	if args := fs.Args(); len(args) > 0 {
+		targetShell = args[0]
+		if !isValidShellArg(targetShell) {
+			fatalf(exitUsage, "Unsupported shell: %s\nSupported shells: bash, zsh, fish, powershell", targetShell)
+		}
+	}
+
+	// Show what will be removed
+	fmt.Println("kubectx-timeout Uninstallation")
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println("\nThe following will be removed:")
+
+	// Check what's installed
+	daemonRunning, _ := internal.CheckDaemonStatus()
+	if daemonRunning {
+		fmt.Println("  - Daemon (will be stopped)")
+	}
+
+	plistPath, _ := internal.GetLaunchdPlistPath()
+	if plistPath != "" {
+		if _, err := os.Stat(plistPath); err == nil {
+			fmt.Println("  - Launchd configuration")
+		}
+	}
+
+	if runtime.GOOS == "linux" {
+		if unitPath, err := internal.GetSystemdUnitPath(); err == nil {
+			if _, err := os.Stat(unitPath); err == nil {
+				fmt.Println("  - Systemd user unit")
+			}
+		}
+	}
+
+	installedShells, _ := internal.GetInstalledShells()
+	if targetShell != "" {
+		if slices.Contains(installedShells, targetShell) {
+			installedShells = []string{targetShell}
+		} else {
+			installedShells = nil
+		}
+	}
+	if len(installedShells) > 0 {
+		fmt.Printf("  - Shell integration (%s)\n", strings.Join(installedShells, ", "))
+	}
+
+	configDir := internal.GetConfigDir()
+	stateDir := internal.GetStateDir()
+	hasConfig := false
+	hasState := false
+
+	if _, err := os.Stat(configDir); err == nil {
+		hasConfig = true
+	}
+	if _, err := os.Stat(stateDir); err == nil {
+		hasState = true
+	}
+
+	if !*keepConfig {
+		if hasConfig {
+			fmt.Printf("  - Configuration files (%s)\n", configDir)
+		}
+		if hasState {
+			fmt.Printf("  - State files (%s)\n", stateDir)
+		}
+	}
+
+	removeBinary := *all || !*keepBinary
+
+	if removeBinary {
+		if _, err := os.Stat(*binaryPath); err == nil {
+			fmt.Printf("  - Binary (%s)\n", *binaryPath)
+		}
+	}
+
+	if *keepConfig {
+		fmt.Println("\nConfiguration and state files will be kept")
+	}
+	if *keepBinary {
+		fmt.Println("Binary will be kept")
+	}
+
+	// Confirm unless --yes flag is set
+	if !*yes {
+		fmt.Print("\nDo you want to proceed with uninstallation? [y/N]: ")
+		reader := bufio.NewReader(os.Stdin)
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to read input: %v", err)
+		}
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Uninstallation cancelled")
+			return
+		}
+	}
+
+	// Perform uninstallation
+	fmt.Println("\nUninstalling kubectx-timeout...")
+
+	opts := internal.UninstallOptions{
+		KeepConfig:     *keepConfig,
+		KeepBinary:     !removeBinary,
+		Force:          *yes,
+		AllShells:      *allShells,
+		TargetShell:    targetShell,
+		BinaryPath:     *binaryPath,
+		ProfileBackups: profileBackups(internal.GetConfigPath()),
 	}
 
 	result, err := internal.Uninstall(opts)
 	if err != nil {
-		log.Fatalf("Uninstallation failed: %v", err)
+		fatalf(exitCodeFor(err), "Uninstallation failed: %v", err)
 	}
 
 	// Show results
@@ -2,11 +2,17 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/mrf/kubectx-timeout/internal"
 )
 
 // TestInstallShellDetectFlag tests the --detect flag for install-shell command
@@ -456,6 +462,183 @@ func TestHelpCommand(t *testing.T) {
 	}
 }
 
+// TestConfigValidateCommand tests that config validate reports every problem
+func TestConfigValidateCommand(t *testing.T) {
+	binPath := buildTestBinary(t)
+	defer os.Remove(binPath)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "default_context: local\ntimeout:\n  default: -1m\ndaemon:\n  log_level: loud\n"
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cmd := exec.Command(binPath, "config", "validate", "--skip-contexts", configPath)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err == nil {
+		t.Fatal("Expected config validate to fail for an invalid config")
+	}
+
+	output := stdout.String()
+	for _, expected := range []string{"timeout.default must be positive", "daemon.log_level must be one of", "2 problems found"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+
+	// config show prints the effective config even when it's invalid
+	cmd = exec.Command(binPath, "config", "show", "--json", "--config", configPath)
+	stdout.Reset()
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("config show failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), `"check_interval": "30s"`) {
+		t.Errorf("Expected defaults in config show output, got:\n%s", stdout.String())
+	}
+}
+
+// TestInitNonInteractive tests that init writes the config from flags
+// alone, and overwrites only with --force
+func TestInitNonInteractive(t *testing.T) {
+	binPath := buildTestBinary(t)
+	defer os.Remove(binPath)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	args := []string{"init", "--config", configPath, "--default-context", "local", "--timeout", "20m", "--context", "prod=5m", "--context", "staging=10m", "--quiet"}
+
+	cmd := exec.Command(binPath, args...)
+	cmd.Stdin = nil
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("init failed: %v\n%s", err, output)
+	}
+	if len(output) != 0 {
+		t.Errorf("Expected no output with --quiet, got:\n%s", output)
+	}
+
+	config, err := internal.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.DefaultContext != "local" || config.Timeout.Default != 20*time.Minute {
+		t.Errorf("Loaded default_context %q and timeout %v", config.DefaultContext, config.Timeout.Default)
+	}
+	if config.GetTimeoutForContext("prod") != 5*time.Minute || config.GetTimeoutForContext("staging") != 10*time.Minute {
+		t.Errorf("Expected per-context timeouts, got %+v", config.Contexts)
+	}
+
+	// An existing config is kept without --force
+	cmd = exec.Command(binPath, args...)
+	if output, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(output), "already exists") {
+		t.Errorf("Expected init to refuse to overwrite, got err %v:\n%s", err, output)
+	}
+
+	cmd = exec.Command(binPath, "init", "--config", configPath, "--default-context", "minikube", "--force", "--quiet")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("init --force failed: %v\n%s", err, output)
+	}
+	config, err = internal.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.DefaultContext != "minikube" || len(config.Contexts) != 0 {
+		t.Errorf("Expected the config to be replaced, got default_context %q and contexts %+v", config.DefaultContext, config.Contexts)
+	}
+}
+
+// TestConfigEdit tests that config edit saves a valid edit, and that an
+// invalid one never reaches the config file
+func TestConfigEdit(t *testing.T) {
+	binPath := buildTestBinary(t)
+	defer os.Remove(binPath)
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	original := "default_context: local\ntimeout:\n  default: 30m\n"
+	if err := os.WriteFile(configPath, []byte(original), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	// An "editor" that replaces the default timeout
+	editor := func(timeout string) string {
+		script := filepath.Join(dir, "edit-"+timeout+".sh")
+		content := "#!/bin/sh\nsed -i.bak 's/default: 30m/default: " + timeout + "/' \"$1\"\n"
+		if err := os.WriteFile(script, []byte(content), 0700); err != nil {
+			t.Fatalf("Failed to write editor: %v", err)
+		}
+		return script
+	}
+
+	cmd := exec.Command(binPath, "config", "edit", "--config", configPath)
+	cmd.Env = append(os.Environ(), "VISUAL=", "EDITOR="+editor("0s"), "XDG_STATE_HOME="+dir)
+	cmd.Stdin = strings.NewReader("n\n")
+	output, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(output), "Changes discarded") {
+		t.Errorf("Expected an invalid edit to be refused, got err %v:\n%s", err, output)
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != original {
+		t.Errorf("Expected the config unchanged, got:\n%s", data)
+	}
+
+	cmd = exec.Command(binPath, "config", "edit", "--config", configPath)
+	cmd.Env = append(os.Environ(), "VISUAL=", "EDITOR="+editor("45m"), "XDG_STATE_HOME="+dir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("config edit failed: %v\n%s", err, output)
+	}
+	config, err := internal.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Timeout.Default != 45*time.Minute {
+		t.Errorf("Expected the edit saved, got timeout %v", config.Timeout.Default)
+	}
+}
+
+// TestKubectlPlugin tests that the binary acts as a kubectl plugin when
+// named kubectl-ctx_timeout
+func TestKubectlPlugin(t *testing.T) {
+	binPath := buildTestBinary(t)
+	defer os.Remove(binPath)
+
+	pluginPath := filepath.Join(filepath.Dir(binPath), pluginName)
+	if err := os.Link(binPath, pluginPath); err != nil {
+		t.Fatalf("Failed to link plugin: %v", err)
+	}
+	defer os.Remove(pluginPath)
+
+	cmd := exec.Command(pluginPath, "help")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("plugin help failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "kubectl ctx-timeout <command>") {
+		t.Errorf("Expected plugin usage, got:\n%s", output)
+	}
+
+	// Without a daemon, pause updates the state file directly
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	cmd = exec.Command(pluginPath, "pause", "--state", statePath, "prod-eu", "1h")
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("plugin pause failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "switching away from 'prod-eu' suppressed") {
+		t.Errorf("Expected the context to be paused, got:\n%s", output)
+	}
+
+	// Other commands are kubectx-timeout's
+	cmd = exec.Command(pluginPath, "extend", "--state", statePath, "30m")
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("plugin extend failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "Timeout switching suppressed until") {
+		t.Errorf("Expected the deadline to be extended, got:\n%s", output)
+	}
+}
+
 // buildTestBinary builds the binary for testing and returns the path
 func buildTestBinary(t *testing.T) string {
 	t.Helper()
@@ -493,3 +676,218 @@ func mustGetCurrentFile(t *testing.T) string {
 
 	return "."
 }
+
+// TestCompletionFlags checks the completion table against each command's
+// flag set, so a new flag can't be left out of shell completion
+func TestCompletionFlags(t *testing.T) {
+	binPath := buildTestBinary(t)
+	defer os.Remove(binPath)
+
+	usage, err := exec.Command(binPath, "help").Output()
+	if err != nil {
+		t.Fatalf("help command failed: %v", err)
+	}
+
+	// "  -name type" for a flag that takes a value, "  -name" for a boolean
+	flagLine := regexp.MustCompile(`(?m)^  -([\w-]+)( \w+)?`)
+
+	check := func(args []string, command internal.CompletionCommand) {
+		if len(command.Flags) == 0 {
+			return
+		}
+		cmd := exec.Command(binPath, append(args, "-h")...)
+		cmd.Env = append(os.Environ(), "HOME="+t.TempDir())
+		output, _ := cmd.CombinedOutput()
+
+		want := make(map[string]bool)
+		for _, match := range flagLine.FindAllStringSubmatch(string(output), -1) {
+			want[match[1]] = match[2] != ""
+		}
+		got := make(map[string]bool)
+		for _, f := range command.Flags {
+			got[f.Name] = f.Values != ""
+		}
+		for name, takesValue := range want {
+			if _, ok := got[name]; !ok {
+				t.Errorf("%s: flag --%s is missing from completion", strings.Join(args, " "), name)
+			} else if got[name] != takesValue {
+				t.Errorf("%s: flag --%s takes a value = %v, completion says %v", strings.Join(args, " "), name, takesValue, got[name])
+			}
+		}
+		for name := range got {
+			if _, ok := want[name]; !ok {
+				t.Errorf("%s: completion has --%s, which the command doesn't accept", strings.Join(args, " "), name)
+			}
+		}
+	}
+
+	for _, command := range completionCommands {
+		if !strings.Contains(string(usage), "  "+command.Name+" ") {
+			t.Errorf("completion has command %q, which help doesn't list", command.Name)
+		}
+		check([]string{command.Name}, command)
+		for _, sub := range command.Subcommands {
+			check([]string{command.Name, sub.Name}, sub)
+		}
+	}
+}
+
+// TestCompletionCommand tests that completion prints each shell's
+// definitions and rejects other shells
+func TestCompletionCommand(t *testing.T) {
+	binPath := buildTestBinary(t)
+	defer os.Remove(binPath)
+
+	for shell, want := range map[string]string{
+		"bash": "complete -F _kubectx_timeout kubectx-timeout",
+		"zsh":  "#compdef kubectx-timeout",
+		"fish": "complete -c kubectx-timeout",
+	} {
+		output, err := exec.Command(binPath, "completion", shell).Output()
+		if err != nil {
+			t.Fatalf("completion %s failed: %v", shell, err)
+		}
+		if !strings.Contains(string(output), want) {
+			t.Errorf("completion %s = %q, want it to contain %q", shell, output, want)
+		}
+	}
+
+	if err := exec.Command(binPath, "completion", "tcsh").Run(); err == nil {
+		t.Error("completion tcsh succeeded, want an error")
+	}
+}
+
+// TestExitCodes tests that each type of failure exits with its own code
+func TestExitCodes(t *testing.T) {
+	binPath := buildTestBinary(t)
+	defer os.Remove(binPath)
+
+	// An isolated home, and a kubectl that always fails
+	home := t.TempDir()
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "kubectl"), []byte("#!/bin/sh\necho 'connection refused' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake kubectl: %v", err)
+	}
+	env := append(os.Environ(),
+		"HOME="+home,
+		"XDG_CONFIG_HOME="+filepath.Join(home, ".config"),
+		"XDG_STATE_HOME="+filepath.Join(home, ".local", "state"),
+		"PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	invalidConfig := filepath.Join(home, "invalid.yaml")
+	if err := os.WriteFile(invalidConfig, []byte("default_context: prod\nsafety:\n  never_switch_to: [prod]\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	existingConfig := filepath.Join(home, "existing.yaml")
+	if err := os.WriteFile(existingConfig, []byte("default_context: local\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"unknown command", []string{"frobnicate"}, exitUsage},
+		{"bad flag", []string{"status", "--frobnicate"}, exitUsage},
+		{"missing argument", []string{"extend"}, exitUsage},
+		{"invalid config", []string{"config", "validate", "--skip-contexts", invalidConfig}, exitConfig},
+		{"invalid config for a command", []string{"contexts", "--config", invalidConfig}, exitConfig},
+		{"daemon not running", []string{"reload"}, exitDaemonNotRunning},
+		{"already installed", []string{"init", "--config", existingConfig, "--default-context", "local", "--quiet"}, exitAlreadyInstalled},
+		{"kubectl failure", []string{"switch-now"}, exitKubectl},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(binPath, tt.args...)
+			cmd.Env = env
+			output, err := cmd.CombinedOutput()
+			code := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("Failed to run %v: %v", tt.args, err)
+			}
+			if code != tt.want {
+				t.Errorf("%v exited with %d, want %d:\n%s", tt.args, code, tt.want, output)
+			}
+		})
+	}
+}
+
+// TestSwitchNowNativeClient tests that switch-now without a daemon uses the
+// configured kube_client rather than always running kubectl
+func TestSwitchNowNativeClient(t *testing.T) {
+	binPath := buildTestBinary(t)
+	defer os.Remove(binPath)
+
+	// An isolated home, and a kubectl that always fails
+	home := t.TempDir()
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "kubectl"), []byte("#!/bin/sh\necho 'connection refused' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake kubectl: %v", err)
+	}
+	kubeconfig := filepath.Join(home, "kubeconfig")
+	content := `apiVersion: v1
+kind: Config
+clusters:
+- name: c
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: u
+  user: {}
+contexts:
+- name: local
+  context: {cluster: c, user: u}
+- name: prod
+  context: {cluster: c, user: u}
+current-context: prod
+`
+	if err := os.WriteFile(kubeconfig, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+	configPath := filepath.Join(home, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("default_context: local\nkube_client: native\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cmd := exec.Command(binPath, "switch-now", "--config", configPath)
+	cmd.Env = append(os.Environ(),
+		"HOME="+home,
+		"KUBECONFIG="+kubeconfig,
+		"XDG_CONFIG_HOME="+filepath.Join(home, ".config"),
+		"XDG_STATE_HOME="+filepath.Join(home, ".local", "state"),
+		"PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("switch-now failed: %v\n%s", err, output)
+	}
+
+	data, err := os.ReadFile(kubeconfig)
+	if err != nil {
+		t.Fatalf("Failed to read kubeconfig: %v", err)
+	}
+	if !strings.Contains(string(data), "current-context: local") {
+		t.Errorf("Expected the kubeconfig switched to local, got:\n%s", data)
+	}
+}
+
+func TestExitCodeFor(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("disk full"), exitFailure},
+		{fmt.Errorf("failed to load: %w", internal.MarkFailure(errors.New("bad yaml"), internal.ErrConfigInvalid)), exitConfig},
+		{fmt.Errorf("%w: connection refused", internal.ErrControlUnavailable), exitDaemonNotRunning},
+		{internal.MarkFailure(errors.New("daemon is already installed"), internal.ErrAlreadyInstalled), exitAlreadyInstalled},
+		{internal.MarkFailure(errors.New("in never_switch_to"), internal.ErrSwitchRefused), exitSwitchRefused},
+		{internal.MarkFailure(errors.New("exit status 1"), internal.ErrKubectlFailed), exitKubectl},
+	}
+	for _, tt := range tests {
+		if got := exitCodeFor(tt.err); got != tt.want {
+			t.Errorf("exitCodeFor(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
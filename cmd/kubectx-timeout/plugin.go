@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mrf/kubectx-timeout/internal"
+)
+
+// pluginName is the binary name kubectl runs for "kubectl ctx-timeout".
+// The same binary installed (or linked) under this name acts as the plugin.
+const pluginName = "kubectl-ctx_timeout"
+
+// isPlugin reports whether the binary was run as the kubectl plugin
+func isPlugin() bool {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return name == pluginName
+}
+
+// pluginMain runs a kubectl plugin command. The plugin adds a shorter
+// pause, which defaults to the current context, and otherwise runs the
+// same commands as kubectx-timeout.
+func pluginMain() {
+	if len(os.Args) < 2 {
+		printPluginUsage()
+		os.Exit(exitUsage)
+	}
+
+	switch os.Args[1] {
+	case "pause":
+		pluginPause()
+	case "help", "-h", "--help":
+		printPluginUsage()
+	default:
+		// Every other command is the same as kubectx-timeout's
+		run(os.Args[1])
+	}
+}
+
+// pluginPause runs pause-context, for the current context if none is named
+func pluginPause() {
+	fs := flag.NewFlagSet("pause", flag.ExitOnError)
+	statePath := fs.String("state", internal.GetStatePath(), "Path to state file")
+	clearPause := fs.Bool("clear", false, "End the context's pause early")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	args := fs.Args()
+	if (*clearPause && len(args) == 0) || (!*clearPause && len(args) == 1) {
+		currentContext, err := internal.GetCurrentContext()
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to get current context: %v", err)
+		}
+		args = append([]string{currentContext}, args...)
+	}
+
+	pauseArgs := []string{"--state", *statePath}
+	if *clearPause {
+		pauseArgs = append(pauseArgs, "--clear")
+	}
+	os.Args = append([]string{os.Args[0], "pause-context"}, append(pauseArgs, args...)...)
+	cmdPauseContext()
+}
+
+func printPluginUsage() {
+	fmt.Printf(`kubectl ctx-timeout (kubectx-timeout version %s)
+
+Usage:
+  kubectl ctx-timeout <command> [options]
+
+Commands:
+  status               Show daemon status and timeout information
+  pause [name] <duration>
+                       Suppress timeout switching away from a context
+                       (the current one if no name is given)
+  pause --clear [name] End a context's pause early
+  extend <duration>    Suppress timeout switching for every context
+  switch-now           Switch to the default context now
+  undo                 Switch back after an automatic switch
+  profile use <name>   Use a named set of timeouts, e.g. while on call
+  help                 Show this help message
+
+Every other kubectx-timeout command works too; see kubectx-timeout help.
+
+Examples:
+  kubectl ctx-timeout status
+  kubectl ctx-timeout pause 2h
+  kubectl ctx-timeout pause prod-eu 4h
+  kubectl ctx-timeout extend 30m
+`, version)
+}
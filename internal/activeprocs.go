@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// activeKubeTools are the executables whose running processes defer a
+// context switch when check_active_kubectl is enabled. Switching underneath
+// them could redirect a long-running session to a different cluster.
+var activeKubeTools = map[string]bool{
+	"kubectl": true,
+	"k9s":     true,
+	"helm":    true,
+}
+
+// ActiveProcess is a running Kubernetes tool that should defer a context switch
+type ActiveProcess struct {
+	PID         int
+	Description string // Tool and subcommand, e.g. "kubectl port-forward"
+
+	// Context is the context named with --context, or "" if the process
+	// uses the kubeconfig's current context
+	Context string
+}
+
+// UsesContext reports whether the process is working in the given context:
+// the current context, unless it named another one with --context
+func (p ActiveProcess) UsesContext(context string) bool {
+	return p.Context == "" || p.Context == context
+}
+
+// String returns a human-readable description for logs
+func (p ActiveProcess) String() string {
+	return fmt.Sprintf("%s (PID %d)", p.Description, p.PID)
+}
+
+// FindActiveKubeProcesses returns the running kubectl, k9s, and helm
+// processes, including long-lived kubectl sessions such as port-forward,
+// logs -f, and exec
+func FindActiveKubeProcesses() ([]ActiveProcess, error) {
+	// -A/-ax lists processes from every terminal; "pid=,args=" omits headers
+	// and works with both BSD (macOS) and procps (Linux) ps
+	// #nosec G204 -- command and arguments are hardcoded, not user input
+	output, err := exec.Command("ps", "-axo", "pid=,args=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	return parseProcessList(string(output), os.Getpid()), nil
+}
+
+// parseProcessList extracts Kubernetes tool processes from "pid args" lines,
+// skipping the given PID (the daemon itself)
+func parseProcessList(output string, selfPID int) []ActiveProcess {
+	var processes []ActiveProcess
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil || pid == selfPID {
+			continue
+		}
+
+		tool := filepath.Base(fields[1])
+		if !activeKubeTools[tool] {
+			continue
+		}
+
+		processes = append(processes, ActiveProcess{
+			PID:         pid,
+			Description: describeKubeCommand(tool, fields[2:]),
+			Context:     contextFlag(fields[2:]),
+		})
+	}
+
+	return processes
+}
+
+// contextFlag returns the value of a --context flag in a command's
+// arguments, or "" if there is none
+func contextFlag(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "--context="); ok {
+			return value
+		}
+		if arg == "--context" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// kubeSubcommands are the subcommands recognized when describing a process.
+// Only these words are ever logged, so flag values such as tokens passed on
+// the command line can't leak into the daemon log.
+var kubeSubcommands = map[string]map[string]bool{
+	"kubectl": {
+		"annotate": true, "apply": true, "attach": true, "auth": true, "cp": true,
+		"create": true, "debug": true, "delete": true, "describe": true, "diff": true,
+		"drain": true, "edit": true, "exec": true, "get": true, "label": true,
+		"logs": true, "patch": true, "port-forward": true, "proxy": true, "replace": true,
+		"rollout": true, "run": true, "scale": true, "top": true, "wait": true,
+	},
+	"helm": {
+		"install": true, "list": true, "rollback": true, "status": true, "template": true,
+		"test": true, "uninstall": true, "upgrade": true,
+	},
+}
+
+// describeKubeCommand summarizes a command as the tool and its subcommand,
+// e.g. "kubectl port-forward" or "kubectl logs -f"
+func describeKubeCommand(tool string, args []string) string {
+	subcommand := ""
+	follow := false
+	for _, arg := range args {
+		if arg == "-f" || arg == "--follow" || arg == "--follow=true" {
+			follow = true
+		}
+		if subcommand == "" && kubeSubcommands[tool][arg] {
+			subcommand = arg
+		}
+	}
+
+	if subcommand == "" {
+		return tool
+	}
+
+	// Following logs is a long-lived session worth calling out
+	if tool == "kubectl" && subcommand == "logs" && follow {
+		return "kubectl logs -f"
+	}
+
+	return tool + " " + subcommand
+}
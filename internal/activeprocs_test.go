@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProcessList(t *testing.T) {
+	output := `    1 /sbin/init
+  100 -zsh
+  200 kubectl port-forward svc/api 8080:80
+  201 /usr/local/bin/kubectl -n prod logs -f deploy/api
+  202 k9s --context prod
+  203 helm upgrade --install api ./chart
+  204 vim kubectl.yaml
+  205 kubectx-timeout daemon
+  206 kubectl-neat
+  300 kubectl get pods
+`
+	processes := parseProcessList(output, 300)
+
+	expected := []ActiveProcess{
+		{PID: 200, Description: "kubectl port-forward"},
+		{PID: 201, Description: "kubectl logs -f"},
+		{PID: 202, Description: "k9s", Context: "prod"},
+		{PID: 203, Description: "helm upgrade"},
+	}
+	if len(processes) != len(expected) {
+		t.Fatalf("Expected %d processes, got %d: %v", len(expected), len(processes), processes)
+	}
+	for i, p := range processes {
+		if p != expected[i] {
+			t.Errorf("Process %d: expected %+v, got %+v", i, expected[i], p)
+		}
+	}
+}
+
+func TestDescribeKubeCommand(t *testing.T) {
+	tests := []struct {
+		tool     string
+		args     string
+		expected string
+	}{
+		{"kubectl", "exec -it api-0 -- sh", "kubectl exec"},
+		{"kubectl", "logs api-0", "kubectl logs"},
+		{"kubectl", "logs --follow api-0", "kubectl logs -f"},
+		{"kubectl", "--context prod -n default port-forward svc/api 8080", "kubectl port-forward"},
+		{"kubectl", "", "kubectl"},
+		{"helm", "--kube-context prod install api ./chart", "helm install"},
+		{"k9s", "-n default", "k9s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := describeKubeCommand(tt.tool, strings.Fields(tt.args)); got != tt.expected {
+				t.Errorf("describeKubeCommand(%q, %q) = %q, want %q", tt.tool, tt.args, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDescribeKubeCommandOmitsFlagValues(t *testing.T) {
+	// Flag values can't be told apart from arguments, so only known
+	// subcommand words may appear in the description
+	args := strings.Fields("--token s3cr3t-t0ken --server https://10.0.0.1 get secrets")
+	description := describeKubeCommand("kubectl", args)
+
+	for _, leaked := range []string{"s3cr3t", "10.0.0.1", "secrets"} {
+		if strings.Contains(description, leaked) {
+			t.Errorf("Description %q leaks %q", description, leaked)
+		}
+	}
+	if description != "kubectl get" {
+		t.Errorf("Expected 'kubectl get', got %q", description)
+	}
+}
+
+func TestFindActiveKubeProcesses(t *testing.T) {
+	// The test binary itself is never reported
+	processes, err := FindActiveKubeProcesses()
+	if err != nil {
+		t.Skipf("ps not available: %v", err)
+	}
+	for _, p := range processes {
+		if p.PID == os.Getpid() {
+			t.Errorf("Daemon's own process should not be reported: %v", p)
+		}
+	}
+}
+
+func TestDaemonDefersSwitchForActiveProcesses(t *testing.T) {
+	tmpDir := t.TempDir()
+	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
+	defer restoreKubeconfig()
+
+	if _, err := GetCurrentContext(); err != nil {
+		t.Skipf("Skipping test: kubectl not available or not working: %v", err)
+	}
+
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	statePath := filepath.Join(tmpDir, "state.json")
+	configContent := `timeout:
+  default: 1m
+  check_interval: 1s
+default_context: test-stage
+notifications:
+  enabled: false
+safety:
+  check_active_kubectl: true
+  validate_default_context: false
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	d, err := NewDaemonWithPIDFile(configPath, statePath, NewPIDFileWithPath(filepath.Join(tmpDir, "daemon.pid")))
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	var logs bytes.Buffer
+	d.logger = NewLogger(&logs, LogFormatText, slog.LevelDebug)
+	d.switcher = NewContextSwitcher(d.logger)
+
+	// Timeout expired an hour ago
+	if err := d.stateManager.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "test-default"}); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	active := []ActiveProcess{{PID: 4711, Description: "kubectl port-forward"}}
+	d.findActiveProcesses = func() ([]ActiveProcess, error) { return active, nil }
+
+	// Repeated checks defer the switch and log once
+	for i := 0; i < 3; i++ {
+		if err := d.checkTimeout(); err != nil {
+			t.Fatalf("checkTimeout failed: %v", err)
+		}
+	}
+	if current, _ := GetCurrentContext(); current != "test-default" {
+		t.Fatalf("Expected switch to be deferred, but context is %q", current)
+	}
+	if count := strings.Count(logs.String(), "kubectl port-forward (PID 4711)"); count != 1 {
+		t.Errorf("Expected deferral to be logged once, got %d:\n%s", count, logs.String())
+	}
+
+	summary := d.buildStatusSummary(time.Now())
+	if summary.State != SummaryStateDeferred || len(summary.DeferredBy) != 1 {
+		t.Errorf("Expected deferred summary, got %+v", summary)
+	}
+
+	// Once the tools exit, the switch goes ahead
+	active = nil
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout failed: %v", err)
+	}
+	if current, _ := GetCurrentContext(); current != "test-stage" {
+		t.Errorf("Expected switch to test-stage after tools exited, got %q", current)
+	}
+}
+
+func TestDaemonSwitchesWhenProcessListingFails(t *testing.T) {
+	var logs bytes.Buffer
+	d := &Daemon{
+		logger: NewLogger(&logs, LogFormatText, slog.LevelDebug),
+		findActiveProcesses: func() ([]ActiveProcess, error) {
+			return nil, fmt.Errorf("failed to list processes: %w", errors.New("ps: not found"))
+		},
+	}
+
+	if found := d.activeProcesses("prod"); len(found) > 0 {
+		t.Error("Expected a failed process listing not to defer the switch")
+	}
+	if !strings.Contains(logs.String(), "switching anyway") {
+		t.Errorf("Expected a warning, got:\n%s", logs.String())
+	}
+}
+
+func TestDaemonIgnoresProcessesInOtherContexts(t *testing.T) {
+	d := &Daemon{
+		logger: NewLogger(io.Discard, LogFormatText, slog.LevelDebug),
+		findActiveProcesses: func() ([]ActiveProcess, error) {
+			return []ActiveProcess{
+				{PID: 200, Description: "kubectl port-forward", Context: "staging"},
+				{PID: 201, Description: "kubectl exec", Context: "prod"},
+				{PID: 202, Description: "kubectl proxy"},
+			}, nil
+		},
+	}
+
+	found := d.activeProcesses("prod")
+	want := []string{"kubectl exec (PID 201)", "kubectl proxy (PID 202)"}
+	if strings.Join(found, ", ") != strings.Join(want, ", ") {
+		t.Errorf("activeProcesses(prod) = %v, want %v", found, want)
+	}
+	if d.deferredSince.IsZero() {
+		t.Error("Expected the deferral's start to be recorded")
+	}
+}
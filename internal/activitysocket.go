@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// activitySocketFileName is the activity socket's name within the state
+// directory
+const activitySocketFileName = "activity.sock"
+
+// activitySendTimeout bounds sending one activity message. A daemon too
+// busy to take it is bypassed by writing the state file directly.
+const activitySendTimeout = time.Second
+
+// maxActivityMessageSize limits one activity message, which fits in the
+// smallest datagram macOS allows by default
+const maxActivityMessageSize = 2048
+
+// activityFlushDelay is how long the daemon gathers activity messages
+// before writing them to the state file together
+const activityFlushDelay = time.Second
+
+// ErrActivityUnavailable is returned when the daemon's activity socket
+// can't be reached, typically because the daemon isn't running. Callers
+// fall back to writing the state file themselves.
+var ErrActivityUnavailable = errors.New("daemon activity socket unavailable")
+
+// ActivityMessage is kubectl activity sent to the daemon by the shell
+// integration, as a single datagram of JSON, instead of each command
+// rewriting the state file. There is no reply.
+type ActivityMessage struct {
+	Context   string `json:"context"`
+	Namespace string `json:"namespace,omitempty"`
+	Write     bool   `json:"write,omitempty"`
+
+	// Kubeconfig is the shell's own KUBECONFIG, for per-shell sessions,
+	// and Project the project configuration for the command's directory
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	Project    string `json:"project,omitempty"`
+
+	// Verb and Resource are the command's metadata for the history log,
+	// already redacted to tracking.metadata
+	Verb     string `json:"verb,omitempty"`
+	Resource string `json:"resource,omitempty"`
+}
+
+// receivedActivity is an activity message waiting to be written
+type receivedActivity struct {
+	ActivityMessage
+	at time.Time
+}
+
+// ActivitySocketPathForState returns the activity socket path of a daemon
+// using the given state file, which is kept alongside it
+func ActivitySocketPathForState(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), activitySocketFileName)
+}
+
+// SendActivity sends activity to the daemon listening at socketPath. It
+// returns an error wrapping ErrActivityUnavailable if the message couldn't
+// be delivered, in which case nothing was recorded.
+func SendActivity(socketPath string, msg ActivityMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal activity: %w", err)
+	}
+	if len(data) > maxActivityMessageSize {
+		return fmt.Errorf("%w: activity message too large", ErrActivityUnavailable)
+	}
+
+	conn, err := net.DialTimeout("unixgram", socketPath, activitySendTimeout)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrActivityUnavailable, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(activitySendTimeout)); err != nil {
+		return fmt.Errorf("%w: %w", ErrActivityUnavailable, err)
+	}
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("%w: %w", ErrActivityUnavailable, err)
+	}
+	return nil
+}
+
+// listenActivity starts receiving activity on the activity socket. Only a
+// daemon using the state file the CLI writes listens, since the activity
+// would otherwise be recorded somewhere the CLI doesn't look.
+func (d *Daemon) listenActivity() error {
+	if _, ok := d.stateManager.(*StateManager); !ok || d.activityPath == "" {
+		return nil
+	}
+
+	if err := os.Remove(d.activityPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale activity socket: %w", err)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: d.activityPath, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to listen on activity socket: %w", err)
+	}
+
+	// Only the user may send activity
+	if err := os.Chmod(d.activityPath, 0600); err != nil {
+		_ = conn.Close()
+		_ = os.Remove(d.activityPath)
+		return fmt.Errorf("failed to set activity socket permissions: %w", err)
+	}
+
+	d.controlMu.Lock()
+	d.activityConn = conn
+	d.controlMu.Unlock()
+
+	go d.serveActivity(conn)
+	return nil
+}
+
+// closeActivity stops receiving activity, removes the socket, and writes
+// any activity still waiting. It is safe to call more than once.
+func (d *Daemon) closeActivity() {
+	d.controlMu.Lock()
+	conn := d.activityConn
+	d.activityConn = nil
+	d.controlMu.Unlock()
+
+	if conn == nil {
+		return
+	}
+
+	// Unlike a stream listener, a datagram socket leaves its file behind
+	if err := conn.Close(); err != nil {
+		d.logger.Warn("Failed to close activity socket", "error", err)
+	}
+	if err := os.Remove(d.activityPath); err != nil && !os.IsNotExist(err) {
+		d.logger.Warn("Failed to remove activity socket", "error", err)
+	}
+
+	// Shutdown closes the socket while a check may still be running, and
+	// mustn't wait for it here
+	d.flushActivityLocked()
+}
+
+// serveActivity receives activity messages until the socket is closed
+func (d *Daemon) serveActivity(conn *net.UnixConn) {
+	buf := make([]byte, maxActivityMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				d.logger.Warn("Activity socket stopped receiving", "error", err)
+			}
+			return
+		}
+
+		var msg ActivityMessage
+		if err := json.Unmarshal(buf[:n], &msg); err != nil || msg.Context == "" {
+			d.logger.Debug("Ignoring invalid activity message", "error", err)
+			continue
+		}
+		d.queueActivity(receivedActivity{ActivityMessage: msg, at: time.Now()})
+	}
+}
+
+// queueActivity holds activity until the next flush, which is scheduled
+// activityFlushDelay after the first message since the last one
+func (d *Daemon) queueActivity(activity receivedActivity) {
+	d.activityMu.Lock()
+	defer d.activityMu.Unlock()
+
+	d.pendingActivity = append(d.pendingActivity, activity)
+	if len(d.pendingActivity) == 1 {
+		time.AfterFunc(activityFlushDelay, d.flushActivity)
+	}
+}
+
+// flushActivity writes the activity waiting to be written under checkMu,
+// like the control commands, so the two never interleave
+func (d *Daemon) flushActivity() {
+	d.checkMu.Lock()
+	defer d.checkMu.Unlock()
+
+	d.flushActivityLocked()
+}
+
+// flushActivityLocked writes the activity received since the last flush
+// with one write of the state file, as the CLI would have message by
+// message. Each per-shell session keeps only its latest activity, and every
+// message is added to the history log. Callers hold checkMu, except at
+// shutdown, where the state file's own locking is enough.
+func (d *Daemon) flushActivityLocked() {
+	d.activityMu.Lock()
+	defer d.activityMu.Unlock()
+
+	pending := d.pendingActivity
+	d.pendingActivity = nil
+	if len(pending) == 0 {
+		return
+	}
+
+	sm, ok := d.stateManager.(*StateManager)
+	if !ok {
+		return
+	}
+
+	activities := make([]Activity, 0, len(pending))
+	sessions := make(map[string]ActivityMessage)
+	for _, a := range pending {
+		activities = append(activities, Activity{Context: a.Context, Namespace: a.Namespace, Write: a.Write, At: a.at})
+		if a.Kubeconfig != "" {
+			sessions[a.Kubeconfig] = a.ActivityMessage
+		}
+	}
+	if err := RecordActivities(sm, activities); err != nil {
+		d.logger.Warn("Failed to record activity", "error", err)
+	}
+	for _, kubeconfig := range slices.Sorted(maps.Keys(sessions)) {
+		session := sessions[kubeconfig]
+		if err := RecordSessionActivity(sm, kubeconfig, session.Context, session.Project); err != nil {
+			d.logger.Warn("Failed to record session activity", "kubeconfig", kubeconfig, "error", err)
+		}
+	}
+
+	for _, a := range pending {
+		_ = d.history.Append(HistoryEvent{
+			Time:       a.at,
+			Type:       HistoryActivity,
+			Context:    a.Context,
+			Kubeconfig: a.Kubeconfig,
+			Verb:       a.Verb,
+			Resource:   a.Resource,
+		})
+	}
+	d.logger.Debug("Recorded activity from the activity socket", "messages", len(pending))
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newActivityTestDaemon starts the activity socket of a daemon using a
+// fake switcher and a state file in a short temporary directory, since
+// socket paths are limited to about 100 bytes
+func newActivityTestDaemon(t *testing.T) (*Daemon, *StateManager) {
+	t.Helper()
+
+	d := newFakeDaemon(t, &fakeSwitcher{current: "production"}, &fakeStateStore{})
+
+	dir, err := os.MkdirTemp("", "kctx")
+	if err != nil {
+		t.Fatalf("Failed to create socket directory: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	sm, err := NewStateManager(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("NewStateManager() error = %v", err)
+	}
+	d.stateManager = sm
+	d.history = NewHistory(HistoryPathForState(sm.path))
+	d.activityPath = ActivitySocketPathForState(sm.path)
+	if err := d.listenActivity(); err != nil {
+		t.Fatalf("listenActivity() error = %v", err)
+	}
+	t.Cleanup(d.closeActivity)
+
+	return d, sm
+}
+
+// waitForPendingActivity waits until the daemon has received n messages
+func waitForPendingActivity(t *testing.T, d *Daemon, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		d.activityMu.Lock()
+		got := len(d.pendingActivity)
+		d.activityMu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d activity messages", n)
+}
+
+func TestActivitySocket(t *testing.T) {
+	d, sm := newActivityTestDaemon(t)
+
+	info, err := os.Stat(d.activityPath)
+	if err != nil {
+		t.Fatalf("Activity socket not created: %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a socket with mode 0600, got %v", info.Mode())
+	}
+
+	messages := []ActivityMessage{
+		{Context: "production", Namespace: "web", Write: true, Verb: "apply"},
+		{Context: "staging", Kubeconfig: "/tmp/kubie-1.yaml", Verb: "get", Resource: "pods"},
+	}
+	for _, msg := range messages {
+		if err := SendActivity(d.activityPath, msg); err != nil {
+			t.Fatalf("SendActivity() error = %v", err)
+		}
+	}
+	waitForPendingActivity(t, d, len(messages))
+	d.flushActivity()
+
+	state, err := sm.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if state.CurrentContext != "staging" || time.Since(state.LastActivity) > time.Minute {
+		t.Errorf("Expected recent activity in 'staging', got %v in %q", state.LastActivity, state.CurrentContext)
+	}
+	if state.LastWriteActivity.IsZero() {
+		t.Error("Expected the write to be recorded")
+	}
+	if session := state.Sessions["/tmp/kubie-1.yaml"]; session.CurrentContext != "staging" {
+		t.Errorf("Expected the session recorded in 'staging', got %+v", state.Sessions)
+	}
+
+	events, err := d.history.Read(HistoryFilter{Type: HistoryActivity})
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(events) != 2 || events[0].Verb != "apply" || events[1].Resource != "pods" {
+		t.Errorf("Expected both commands in the history, got %+v", events)
+	}
+
+	// Closing removes the socket, so senders fall back to the state file
+	d.closeActivity()
+	if _, err := os.Stat(d.activityPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the activity socket removed, got %v", err)
+	}
+	if err := SendActivity(d.activityPath, messages[0]); !errors.Is(err, ErrActivityUnavailable) {
+		t.Errorf("SendActivity() after close error = %v, want %v", err, ErrActivityUnavailable)
+	}
+}
+
+func TestActivityTrackerSendsToDaemon(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	d, sm := newActivityTestDaemon(t)
+
+	tracker, err := NewActivityTracker(sm.path, "")
+	if err != nil {
+		t.Fatalf("NewActivityTracker() error = %v", err)
+	}
+	if err := tracker.RecordActivity(); err != nil {
+		t.Fatalf("RecordActivity() error = %v", err)
+	}
+
+	// The daemon records it, not the tracker
+	if lastActivity, _, _ := LastActivity(sm); !lastActivity.IsZero() {
+		t.Errorf("Expected the state file untouched before the daemon writes, got %v", lastActivity)
+	}
+	waitForPendingActivity(t, d, 1)
+	d.flushActivity()
+	if lastActivity, _, _ := LastActivity(sm); time.Since(lastActivity) > time.Minute {
+		t.Errorf("Expected recent activity after the daemon writes, got %v", lastActivity)
+	}
+
+	// Without the daemon the tracker writes the state file itself
+	d.closeActivity()
+	if err := sm.Save(&State{}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := tracker.RecordActivity(); err != nil {
+		t.Fatalf("RecordActivity() error = %v", err)
+	}
+	if lastActivity, _, _ := LastActivity(sm); time.Since(lastActivity) > time.Minute {
+		t.Errorf("Expected activity written directly without the daemon, got %v", lastActivity)
+	}
+}
+
+func TestStateManagerRecordActivities(t *testing.T) {
+	sm, err := NewStateManager(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("NewStateManager() error = %v", err)
+	}
+
+	write := time.Now().Add(-time.Minute).Truncate(time.Second)
+	read := write.Add(30 * time.Second)
+	err = RecordActivities(sm, []Activity{
+		{Context: "production", Namespace: "web", Write: true, At: write},
+		{Context: "production", At: read},
+	})
+	if err != nil {
+		t.Fatalf("RecordActivities() error = %v", err)
+	}
+
+	state, err := sm.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !state.LastActivity.Equal(read) || !state.LastWriteActivity.Equal(write) {
+		t.Errorf("Expected activity at %v and write at %v, got %v and %v", read, write, state.LastActivity, state.LastWriteActivity)
+	}
+	if state.CurrentNamespace != "web" {
+		t.Errorf("Expected the namespace kept for the same context, got %q", state.CurrentNamespace)
+	}
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// illegalCacheDirChars matches the characters kubectl replaces with "_" when
+// deriving a discovery cache directory from a server URL (mirrors
+// computeDiscoverCacheDir in k8s.io/cli-runtime)
+var illegalCacheDirChars = regexp.MustCompile(`[^(\w/.)]`)
+
+// GetContextServer returns the API server URL of the cluster used by a context
+func GetContextServer(contextName string) (string, error) {
+	// The context name is passed as a single argument, not through a shell
+	output, err := runKubectl("", "config", "view", "--minify",
+		"--context="+contextName, "-o", "jsonpath={.clusters[0].cluster.server}")
+	if err != nil {
+		return "", fmt.Errorf("failed to get server for context '%s': %w", contextName, err)
+	}
+
+	server := strings.TrimSpace(string(output))
+	if server == "" {
+		return "", fmt.Errorf("no cluster server found for context '%s'", contextName)
+	}
+
+	return server, nil
+}
+
+// discoveryCacheDir returns the directory kubectl uses to cache API discovery
+// for the given server, e.g. ~/.kube/cache/discovery/10.0.0.1_6443
+func discoveryCacheDir(cacheDir, server string) (string, error) {
+	schemeless := strings.Replace(strings.Replace(server, "https://", "", 1), "http://", "", 1)
+	safeHost := illegalCacheDirChars.ReplaceAllString(schemeless, "_")
+
+	discoveryDir := filepath.Join(cacheDir, "discovery")
+	dir := filepath.Join(discoveryDir, safeHost)
+
+	// A crafted server URL could contain ".." - never leave the discovery cache
+	rel, err := filepath.Rel(discoveryDir, dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to clear cache for server %q: resolves outside %s", server, discoveryDir)
+	}
+
+	return dir, nil
+}
+
+// httpCacheDirs returns the directories kubectl uses for its HTTP cache:
+// <cache dir>/http, and ~/.kube/http-cache for older kubectl versions
+func httpCacheDirs(cacheDir string) []string {
+	dirs := []string{filepath.Join(cacheDir, "http")}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".kube", "http-cache"))
+	}
+	return dirs
+}
+
+// ClearServerCache removes kubectl's cached discovery data for a cluster
+// server, and optionally the whole HTTP cache. It returns the directories
+// that were removed.
+func ClearServerCache(cacheDir, server string, includeHTTP bool) ([]string, error) {
+	dir, err := discoveryCacheDir(cacheDir, server)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{dir}
+	if includeHTTP {
+		dirs = append(dirs, httpCacheDirs(cacheDir)...)
+	}
+
+	var removed []string
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return removed, fmt.Errorf("failed to remove cache directory %s: %w", dir, err)
+		}
+		removed = append(removed, dir)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscoveryCacheDir(t *testing.T) {
+	cacheDir := filepath.Join("home", ".kube", "cache")
+
+	tests := []struct {
+		name   string
+		server string
+		want   string
+	}{
+		{
+			name:   "https with port",
+			server: "https://10.0.0.1:6443",
+			want:   "10.0.0.1_6443",
+		},
+		{
+			name:   "hostname without port",
+			server: "https://api.prod.example.com",
+			want:   "api.prod.example.com",
+		},
+		{
+			name:   "http scheme",
+			server: "http://localhost:8080",
+			want:   "localhost_8080",
+		},
+		{
+			name:   "path kept, dashes replaced",
+			server: "https://rancher.example.com/k8s/clusters/c-abc",
+			want:   "rancher.example.com/k8s/clusters/c_abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := discoveryCacheDir(cacheDir, tt.server)
+			if err != nil {
+				t.Fatalf("discoveryCacheDir() error = %v", err)
+			}
+			want := filepath.Join(cacheDir, "discovery", tt.want)
+			if got != want {
+				t.Errorf("discoveryCacheDir(%q) = %q, want %q", tt.server, got, want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryCacheDirRejectsTraversal(t *testing.T) {
+	cacheDir := filepath.Join("home", ".kube", "cache")
+
+	for _, server := range []string{"https://..", "https://../..", "https://../../etc", ""} {
+		if dir, err := discoveryCacheDir(cacheDir, server); err == nil {
+			t.Errorf("discoveryCacheDir(%q) = %q, want error", server, dir)
+		}
+	}
+}
+
+func TestClearServerCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cacheDir := filepath.Join(home, ".kube", "cache")
+
+	prodDir := filepath.Join(cacheDir, "discovery", "10.0.0.1_6443")
+	devDir := filepath.Join(cacheDir, "discovery", "10.0.0.2_6443")
+	httpDir := filepath.Join(cacheDir, "http")
+	for _, dir := range []string{prodDir, devDir, httpDir} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "servergroups.json"), []byte("{}"), 0600); err != nil {
+			t.Fatalf("Failed to write cache file: %v", err)
+		}
+	}
+
+	removed, err := ClearServerCache(cacheDir, "https://10.0.0.1:6443", false)
+	if err != nil {
+		t.Fatalf("ClearServerCache() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0] != prodDir {
+		t.Errorf("ClearServerCache() removed = %v, want [%s]", removed, prodDir)
+	}
+
+	if _, err := os.Stat(prodDir); !os.IsNotExist(err) {
+		t.Error("Discovery cache for the cleared server should be removed")
+	}
+	if _, err := os.Stat(devDir); err != nil {
+		t.Error("Discovery cache for other servers should be kept")
+	}
+	if _, err := os.Stat(httpDir); err != nil {
+		t.Error("HTTP cache should be kept when not requested")
+	}
+
+	// Clearing again is a no-op
+	removed, err = ClearServerCache(cacheDir, "https://10.0.0.1:6443", false)
+	if err != nil {
+		t.Fatalf("ClearServerCache() second call error = %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("ClearServerCache() second call removed = %v, want none", removed)
+	}
+}
+
+func TestClearServerCacheWithHTTPCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cacheDir := filepath.Join(home, ".kube", "cache")
+
+	httpDir := filepath.Join(cacheDir, "http")
+	legacyHTTPDir := filepath.Join(home, ".kube", "http-cache")
+	for _, dir := range []string{httpDir, legacyHTTPDir} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+
+	// The discovery cache doesn't exist - only the HTTP caches are removed
+	removed, err := ClearServerCache(cacheDir, "https://10.0.0.1:6443", true)
+	if err != nil {
+		t.Fatalf("ClearServerCache() error = %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("ClearServerCache() removed = %v, want both HTTP caches", removed)
+	}
+
+	for _, dir := range []string{httpDir, legacyHTTPDir} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", dir)
+		}
+	}
+}
+
+func TestGetContextServer(t *testing.T) {
+	tmpDir := t.TempDir()
+	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
+	defer restoreKubeconfig()
+
+	server, err := GetContextServer("test-prod")
+	if err != nil {
+		t.Skipf("kubectl config view not available: %v", err)
+	}
+	if server != "https://fake-cluster-1.example.com" {
+		t.Errorf("GetContextServer() = %q, want %q", server, "https://fake-cluster-1.example.com")
+	}
+}
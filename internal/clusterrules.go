@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"maps"
+	"net/url"
+	"slices"
+)
+
+// ContextServers returns the API server URL of each context's cluster. The
+// kubeconfig files are merged the way kubectl merges them: the first file to
+// define a context or cluster wins.
+func ContextServers(kubeconfigPaths []string) (map[string]string, error) {
+	contextClusters := make(map[string]string)
+	servers := make(map[string]string)
+
+	err := forEachKubeconfig(kubeconfigPaths, func(_ string, file kubeconfigFile) {
+		for _, ctx := range file.Contexts {
+			if _, ok := contextClusters[ctx.Name]; !ok {
+				contextClusters[ctx.Name] = ctx.Context.Cluster
+			}
+		}
+		for _, cluster := range file.Clusters {
+			if _, ok := servers[cluster.Name]; !ok {
+				servers[cluster.Name] = cluster.Cluster.Server
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contextServers := make(map[string]string, len(contextClusters))
+	for contextName, cluster := range contextClusters {
+		if server := servers[cluster]; server != "" {
+			contextServers[contextName] = server
+		}
+	}
+	return contextServers, nil
+}
+
+// MatchServerPattern reports whether a cluster server URL matches a rule,
+// which may be an exact URL, a glob, or a /regex/ like context rules. A
+// rule without a port also matches the server on any port, so
+// https://*.prod.example.com matches https://api.prod.example.com:6443.
+func MatchServerPattern(pattern, server string) bool {
+	if MatchContextPattern(pattern, server) {
+		return true
+	}
+	u, err := url.Parse(server)
+	if err != nil || u.Port() == "" {
+		return false
+	}
+	u.Host = u.Hostname()
+	return MatchContextPattern(pattern, u.String())
+}
+
+// serverFor returns the API server URL of a context's cluster in the
+// kubeconfig, or "" if it can't be read
+func (c *Config) serverFor(contextName string) string {
+	if c.contextServer != nil {
+		return c.contextServer(contextName)
+	}
+	servers, err := ContextServers(GetKubeconfigPaths())
+	if err != nil {
+		return ""
+	}
+	return servers[contextName]
+}
+
+// clusterSettings returns the clusters entry for a context's cluster
+// server: the entry naming the URL exactly, or else the first pattern in
+// alphabetical order that matches it
+func (c *Config) clusterSettings(contextName string) (Context, bool) {
+	if len(c.Clusters) == 0 {
+		return Context{}, false
+	}
+	server := c.serverFor(contextName)
+	if server == "" {
+		return Context{}, false
+	}
+
+	if cluster, ok := c.Clusters[server]; ok {
+		return cluster, true
+	}
+	for _, pattern := range slices.Sorted(maps.Keys(c.Clusters)) {
+		if MatchServerPattern(pattern, server) {
+			return c.Clusters[pattern], true
+		}
+	}
+	return Context{}, false
+}
+
+// matchesServerPatterns reports whether a context's cluster server matches
+// any of the rules
+func (c *Config) matchesServerPatterns(patterns []string, contextName string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	server := c.serverFor(contextName)
+	return server != "" && slices.ContainsFunc(patterns, func(pattern string) bool {
+		return MatchServerPattern(pattern, server)
+	})
+}
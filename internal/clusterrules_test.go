@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextServers(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work.yaml")
+	personal := filepath.Join(dir, "personal.yaml")
+	files := map[string]string{
+		work: `contexts:
+  - name: team-a-prod
+    context: {cluster: prod}
+  - name: team-b
+    context: {cluster: prod}
+clusters:
+  - name: prod
+    cluster: {server: "https://api.prod.example.com:6443"}
+`,
+		personal: `contexts:
+  - name: homelab
+    context: {cluster: pi}
+  - name: team-b
+    context: {cluster: pi}
+clusters:
+  - name: pi
+    cluster: {server: "https://192.168.1.10:6443"}
+  - name: prod
+    cluster: {server: "https://shadowed.example.com"}
+`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write kubeconfig: %v", err)
+		}
+	}
+
+	servers, err := ContextServers([]string{work, personal, filepath.Join(dir, "missing.yaml")})
+	if err != nil {
+		t.Fatalf("ContextServers() error = %v", err)
+	}
+	want := map[string]string{
+		"team-a-prod": "https://api.prod.example.com:6443",
+		"team-b":      "https://api.prod.example.com:6443", // The first file wins
+		"homelab":     "https://192.168.1.10:6443",
+	}
+	if len(servers) != len(want) {
+		t.Errorf("ContextServers() = %v, want %v", servers, want)
+	}
+	for name, server := range want {
+		if servers[name] != server {
+			t.Errorf("server of %s = %q, want %q", name, servers[name], server)
+		}
+	}
+}
+
+func TestMatchServerPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		server  string
+		want    bool
+	}{
+		{"https://*.prod.example.com", "https://api.prod.example.com", true},
+		{"https://*.prod.example.com", "https://api.prod.example.com:6443", true},
+		{"https://*.prod.example.com:443", "https://api.prod.example.com:6443", false},
+		{"https://*.prod.example.com", "https://api.staging.example.com", false},
+		{"/\\.prod\\./", "https://10.0.0.1.prod.internal:443", true},
+		{"https://rancher.example.com/k8s/clusters/*", "https://rancher.example.com/k8s/clusters/c-m-4x2", true},
+	}
+	for _, tt := range tests {
+		if got := MatchServerPattern(tt.pattern, tt.server); got != tt.want {
+			t.Errorf("MatchServerPattern(%q, %q) = %v, want %v", tt.pattern, tt.server, got, tt.want)
+		}
+	}
+}
+
+func TestConfigClusterRules(t *testing.T) {
+	config := DefaultConfig()
+	config.DefaultContext = "local"
+	config.Contexts = map[string]Context{"team-a-prod": {Timeout: 2 * time.Minute}}
+	config.Clusters = map[string]Context{
+		"https://*.prod.example.com": {Timeout: 5 * time.Minute, DefaultContext: "staging"},
+	}
+	config.Safety.NeverSwitchToClusters = []string{"https://*.prod.example.com"}
+	config.contextServer = func(contextName string) string {
+		return map[string]string{
+			"team-a-prod": "https://api.prod.example.com:6443",
+			"team-b":      "https://api.prod.example.com:6443",
+			"local":       "https://127.0.0.1:6443",
+		}[contextName]
+	}
+
+	if got := config.GetTimeoutForContext("team-b"); got != 5*time.Minute {
+		t.Errorf("Expected the cluster's timeout whatever the context is called, got %v", got)
+	}
+	if got := config.GetDefaultContextFor("team-b"); got != "staging" {
+		t.Errorf("Expected the cluster's default_context, got %q", got)
+	}
+	if got := config.GetTimeoutForContext("team-a-prod"); got != 2*time.Minute {
+		t.Errorf("Expected a contexts entry to win over a cluster rule, got %v", got)
+	}
+	if got := config.GetTimeoutForContext("local"); got != config.Timeout.Default {
+		t.Errorf("Expected the default timeout for other clusters, got %v", got)
+	}
+	if !config.IsNeverSwitchTo("team-b") || config.IsNeverSwitchTo("local") {
+		t.Error("Expected never_switch_to_clusters to match contexts by server")
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	config.Clusters["https://[prod"] = Context{Timeout: time.Minute}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "clusters pattern") {
+		t.Errorf("Validate() error = %v, want one about the clusters pattern", err)
+	}
+}
@@ -0,0 +1,354 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Value kinds for CompletionFlag.Values and CompletionCommand.Args. Any
+// other non-empty value is a space-separated list of words to offer.
+const (
+	// CompleteAny takes a value, but has nothing to suggest for it
+	CompleteAny = "@any"
+	// CompleteFiles completes file paths
+	CompleteFiles = "@files"
+	// CompleteContexts completes the kubeconfig's context names
+	CompleteContexts = "@contexts"
+)
+
+// CompletionFlag is a flag of a command, for shell completion
+type CompletionFlag struct {
+	Name string
+	// Values is how to complete the flag's value, or "" for a boolean flag
+	Values string
+}
+
+// CompletionCommand is a command, with its flags and subcommands, for shell
+// completion
+type CompletionCommand struct {
+	Name        string
+	Description string
+	Flags       []CompletionFlag
+	// Args is how to complete positional arguments, or "" for none
+	Args        string
+	Subcommands []CompletionCommand
+}
+
+// completionContextsCommand lists context names. It runs kubectl with
+// "command" so the shell integration's wrapper doesn't record a tab press
+// as activity.
+const completionContextsCommand = "command kubectl config get-contexts -o name 2>/dev/null"
+
+// GetCompletionScript returns the completion definitions of program's
+// commands for shell. Context names are looked up each time they are
+// completed, so they follow the kubeconfig.
+func GetCompletionScript(shell, program string, commands []CompletionCommand) (string, error) {
+	switch shell {
+	case ShellBash:
+		return bashCompletion(program, commands), nil
+	case ShellZsh:
+		return zshCompletion(program, commands), nil
+	case ShellFish:
+		return fishCompletion(program, commands), nil
+	}
+	return "", fmt.Errorf("unsupported shell: %s", shell)
+}
+
+// flagSpelling returns how a flag is written: -f for one letter, --name
+// otherwise. Go's flag package accepts both - and --.
+func flagSpelling(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// completionWords returns the flags of a command and, if they are offered
+// too, the names of its subcommands
+func completionWords(command CompletionCommand, withSubcommands bool) []string {
+	var words []string
+	if withSubcommands {
+		for _, sub := range command.Subcommands {
+			words = append(words, sub.Name)
+		}
+	}
+	for _, f := range command.Flags {
+		words = append(words, flagSpelling(f.Name))
+	}
+	return words
+}
+
+// completionLeaves calls fn with each command and subcommand under its full
+// name, such as "config show"
+func completionLeaves(commands []CompletionCommand, fn func(name string, command CompletionCommand)) {
+	for _, command := range commands {
+		fn(command.Name, command)
+		for _, sub := range command.Subcommands {
+			fn(command.Name+" "+sub.Name, sub)
+		}
+	}
+}
+
+// completionFunctionName turns program into a shell function name
+func completionFunctionName(program string) string {
+	return "_" + strings.NewReplacer("-", "_", ".", "_").Replace(program)
+}
+
+// fishQuote single-quotes s for fish, which escapes quotes with a backslash
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func bashCompletion(program string, commands []CompletionCommand) string {
+	fn := completionFunctionName(program)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", program)
+	fmt.Fprintf(&b, "%s_contexts() {\n    %s\n}\n\n", fn, completionContextsCommand)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("    local cmd=\"${COMP_WORDS[1]}\" words=\"\"\n")
+	b.WriteString("    COMPREPLY=()\n\n")
+
+	var names []string
+	for _, command := range commands {
+		names = append(names, command.Name)
+	}
+	b.WriteString("    if [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(names, " ")))
+	b.WriteString("        return\n    fi\n\n")
+
+	// A subcommand, like "config show", completes as a command of its own
+	var subPatterns []string
+	for _, command := range commands {
+		for _, sub := range command.Subcommands {
+			subPatterns = append(subPatterns, shellQuote(command.Name+" "+sub.Name))
+		}
+	}
+	if len(subPatterns) > 0 {
+		b.WriteString("    case \"$cmd ${COMP_WORDS[2]}\" in\n")
+		fmt.Fprintf(&b, "        %s)\n", strings.Join(subPatterns, "|"))
+		b.WriteString("            [[ $COMP_CWORD -gt 2 ]] && cmd=\"$cmd ${COMP_WORDS[2]}\" ;;\n")
+		b.WriteString("    esac\n\n")
+	}
+
+	// Flag values
+	b.WriteString("    case \"$cmd $prev\" in\n")
+	completionLeaves(commands, func(name string, command CompletionCommand) {
+		for _, f := range command.Flags {
+			if f.Values == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "        %s|%s)\n", shellQuote(name+" -"+f.Name), shellQuote(name+" --"+f.Name))
+			fmt.Fprintf(&b, "            %s\n            return ;;\n", bashValues(fn, f.Values))
+		}
+	})
+	b.WriteString("    esac\n\n")
+
+	// Flags, subcommands, and arguments
+	b.WriteString("    case \"$cmd\" in\n")
+	completionLeaves(commands, func(name string, command CompletionCommand) {
+		words := completionWords(command, false)
+		if len(words) == 0 && len(command.Subcommands) == 0 && command.Args == "" {
+			return
+		}
+		fmt.Fprintf(&b, "        %s)\n", shellQuote(name))
+		fmt.Fprintf(&b, "            words=%s\n", shellQuote(strings.Join(words, " ")))
+		if len(command.Subcommands) > 0 {
+			fmt.Fprintf(&b, "            [[ $COMP_CWORD -eq 2 ]] && words=%s\n", shellQuote(strings.Join(completionWords(command, true), " ")))
+		}
+		if command.Args != "" {
+			fmt.Fprintf(&b, "            %s\n", bashValues(fn, command.Args))
+		}
+		b.WriteString("            ;;\n")
+	})
+	b.WriteString("    esac\n")
+	b.WriteString("    COMPREPLY+=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -F %s %s\n", fn, program)
+	return b.String()
+}
+
+// bashValues returns bash that completes one of the value kinds
+func bashValues(fn, values string) string {
+	switch values {
+	case CompleteAny:
+		return ":"
+	case CompleteFiles:
+		return "COMPREPLY=($(compgen -f -- \"$cur\"))"
+	case CompleteContexts:
+		return fmt.Sprintf("COMPREPLY=($(compgen -W \"$(%s_contexts)\" -- \"$cur\"))", fn)
+	}
+	return fmt.Sprintf("COMPREPLY=($(compgen -W %s -- \"$cur\"))", shellQuote(values))
+}
+
+func zshCompletion(program string, commands []CompletionCommand) string {
+	fn := completionFunctionName(program)
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n# zsh completion for %s\n\n", program, program)
+	fmt.Fprintf(&b, "%s_contexts() {\n", fn)
+	b.WriteString("    local -a contexts\n")
+	fmt.Fprintf(&b, "    contexts=(${(f)\"$(%s)\"})\n", completionContextsCommand)
+	b.WriteString("    compadd -a contexts\n}\n\n")
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cmd=$words[2] prev=$words[CURRENT-1]\n\n")
+
+	b.WriteString("    if (( CURRENT == 2 )); then\n")
+	b.WriteString("        local -a commands\n        commands=(\n")
+	for _, command := range commands {
+		fmt.Fprintf(&b, "            %s\n", shellQuote(zshDescribe(command)))
+	}
+	fmt.Fprintf(&b, "        )\n        _describe -t commands %s commands\n", shellQuote(program+" command"))
+	b.WriteString("        return\n    fi\n\n")
+
+	var subPatterns []string
+	for _, command := range commands {
+		for _, sub := range command.Subcommands {
+			subPatterns = append(subPatterns, shellQuote(command.Name+" "+sub.Name))
+		}
+	}
+	if len(subPatterns) > 0 {
+		b.WriteString("    case \"$cmd $words[3]\" in\n")
+		fmt.Fprintf(&b, "        %s)\n", strings.Join(subPatterns, "|"))
+		b.WriteString("            (( CURRENT > 3 )) && cmd=\"$cmd $words[3]\" ;;\n")
+		b.WriteString("    esac\n\n")
+	}
+
+	b.WriteString("    case \"$cmd $prev\" in\n")
+	completionLeaves(commands, func(name string, command CompletionCommand) {
+		for _, f := range command.Flags {
+			if f.Values == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "        %s|%s)\n", shellQuote(name+" -"+f.Name), shellQuote(name+" --"+f.Name))
+			fmt.Fprintf(&b, "            %s\n            return ;;\n", zshValues(fn, f.Values))
+		}
+	})
+	b.WriteString("    esac\n\n")
+
+	b.WriteString("    case \"$cmd\" in\n")
+	completionLeaves(commands, func(name string, command CompletionCommand) {
+		words := completionWords(command, false)
+		if len(words) == 0 && len(command.Subcommands) == 0 && command.Args == "" {
+			return
+		}
+		fmt.Fprintf(&b, "        %s)\n", shellQuote(name))
+		if len(command.Subcommands) > 0 {
+			b.WriteString("            if (( CURRENT == 3 )); then\n")
+			b.WriteString("                local -a subcommands\n                subcommands=(\n")
+			for _, sub := range command.Subcommands {
+				fmt.Fprintf(&b, "                    %s\n", shellQuote(zshDescribe(sub)))
+			}
+			fmt.Fprintf(&b, "                )\n                _describe -t commands %s subcommands\n", shellQuote(name+" subcommand"))
+			b.WriteString("            fi\n")
+		}
+		if len(words) > 0 {
+			fmt.Fprintf(&b, "            compadd -- %s\n", strings.Join(words, " "))
+		}
+		if command.Args != "" {
+			fmt.Fprintf(&b, "            %s\n", zshValues(fn, command.Args))
+		}
+		b.WriteString("            ;;\n")
+	})
+	b.WriteString("    esac\n}\n\n")
+
+	// Loaded from $fpath, the file is the completion function's body;
+	// sourced, it registers the function
+	fmt.Fprintf(&b, "if [[ \"$funcstack[1]\" == %s ]]; then\n", shellQuote(fn))
+	fmt.Fprintf(&b, "    %s \"$@\"\n", fn)
+	b.WriteString("elif (( $+functions[compdef] )); then\n")
+	fmt.Fprintf(&b, "    compdef %s %s\n", fn, program)
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// zshDescribe returns a command as a _describe entry
+func zshDescribe(command CompletionCommand) string {
+	return command.Name + ":" + strings.ReplaceAll(command.Description, ":", `\:`)
+}
+
+// zshValues returns zsh that completes one of the value kinds
+func zshValues(fn, values string) string {
+	switch values {
+	case CompleteAny:
+		return ":"
+	case CompleteFiles:
+		return "_files"
+	case CompleteContexts:
+		return fn + "_contexts"
+	}
+	return "compadd -- " + values
+}
+
+func fishCompletion(program string, commands []CompletionCommand) string {
+	fn := "_" + completionFunctionName(program)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", program)
+	fmt.Fprintf(&b, "function %s_contexts\n    %s\nend\n\n", fn, completionContextsCommand)
+
+	complete := "complete -c " + program
+	fmt.Fprintf(&b, "%s -f\n", complete)
+	for _, command := range commands {
+		fmt.Fprintf(&b, "%s -n __fish_use_subcommand -a %s -d %s\n", complete, command.Name, fishQuote(command.Description))
+	}
+
+	for _, command := range commands {
+		b.WriteString("\n")
+		condition := "__fish_seen_subcommand_from " + command.Name
+		if len(command.Subcommands) > 0 {
+			var subs []string
+			for _, sub := range command.Subcommands {
+				subs = append(subs, sub.Name)
+			}
+			// The command's own flags and its subcommands, until one is given
+			condition += "; and not __fish_seen_subcommand_from " + strings.Join(subs, " ")
+			for _, sub := range command.Subcommands {
+				fmt.Fprintf(&b, "%s -n %s -a %s -d %s\n", complete, fishQuote(condition), sub.Name, fishQuote(sub.Description))
+			}
+		}
+		writeFishCommand(&b, complete, fn, condition, command)
+		for _, sub := range command.Subcommands {
+			subCondition := "__fish_seen_subcommand_from " + command.Name + "; and __fish_seen_subcommand_from " + sub.Name
+			writeFishCommand(&b, complete, fn, subCondition, sub)
+		}
+	}
+	return b.String()
+}
+
+// writeFishCommand writes the completions of one command's flags and
+// arguments, offered while condition holds
+func writeFishCommand(b *strings.Builder, complete, fn, condition string, command CompletionCommand) {
+	for _, f := range command.Flags {
+		option := "-l " + f.Name
+		if len(f.Name) == 1 {
+			option = "-s " + f.Name
+		}
+		fmt.Fprintf(b, "%s -n %s %s%s\n", complete, fishQuote(condition), option, fishValues(fn, f.Values, true))
+	}
+	if command.Args != "" && command.Args != CompleteAny {
+		fmt.Fprintf(b, "%s -n %s%s\n", complete, fishQuote(condition), fishValues(fn, command.Args, false))
+	}
+}
+
+// fishValues returns the options of a fish complete command that complete
+// one of the value kinds. A flag's value is required, so fish doesn't offer
+// other flags in its place.
+func fishValues(fn, values string, flag bool) string {
+	required := ""
+	if flag {
+		required = " -x"
+	}
+	switch values {
+	case "":
+		return ""
+	case CompleteAny:
+		return required
+	case CompleteFiles:
+		if flag {
+			return " -r -F"
+		}
+		return " -F"
+	case CompleteContexts:
+		return required + " -a " + fishQuote("("+fn+"_contexts)")
+	}
+	return required + " -a " + fishQuote(values)
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testCompletionCommands = []CompletionCommand{
+	{Name: "version", Description: "Show version information"},
+	{Name: "config", Description: "Change the configuration", Subcommands: []CompletionCommand{
+		{Name: "show", Description: "Print it: all of it", Flags: []CompletionFlag{{Name: "json"}, {Name: "config", Values: CompleteFiles}}},
+	}},
+	{Name: "pause-context", Description: "Don't switch away from one context", Flags: []CompletionFlag{{Name: "clear"}}, Args: CompleteContexts},
+	{Name: "logs", Description: "Print the logs", Flags: []CompletionFlag{{Name: "f"}, {Name: "n", Values: CompleteAny}}},
+	{Name: "prompt", Description: "Print a prompt segment", Flags: []CompletionFlag{{Name: "color", Values: "never ansi"}}},
+}
+
+func TestGetCompletionScript(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  []string
+	}{
+		{ShellBash, []string{
+			"complete -F _kubectx_timeout kubectx-timeout",
+			"command kubectl config get-contexts -o name",
+			`'config show')`,
+			`'prompt -color'|'prompt --color')`,
+			`words='--clear'`,
+			`words='-f -n'`,
+		}},
+		{ShellZsh, []string{
+			"#compdef kubectx-timeout",
+			"compdef _kubectx_timeout kubectx-timeout",
+			"command kubectl config get-contexts -o name",
+			`'pause-context:Don'\''t switch away from one context'`,
+			`'show:Print it\: all of it'`,
+			"compadd -- never ansi",
+		}},
+		{ShellFish, []string{
+			"function __kubectx_timeout_contexts",
+			"command kubectl config get-contexts -o name",
+			`complete -c kubectx-timeout -n __fish_use_subcommand -a pause-context -d 'Don\'t switch away from one context'`,
+			`complete -c kubectx-timeout -n '__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from show' -a show`,
+			`complete -c kubectx-timeout -n '__fish_seen_subcommand_from config; and __fish_seen_subcommand_from show' -l config -r -F`,
+			`complete -c kubectx-timeout -n '__fish_seen_subcommand_from pause-context' -a '(__kubectx_timeout_contexts)'`,
+			`complete -c kubectx-timeout -n '__fish_seen_subcommand_from logs' -s f`,
+			`complete -c kubectx-timeout -n '__fish_seen_subcommand_from prompt' -l color -x -a 'never ansi'`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			script, err := GetCompletionScript(tt.shell, "kubectx-timeout", testCompletionCommands)
+			if err != nil {
+				t.Fatalf("GetCompletionScript() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(script, want) {
+					t.Errorf("script missing %q:\n%s", want, script)
+				}
+			}
+		})
+	}
+
+	if _, err := GetCompletionScript("tcsh", "kubectx-timeout", testCompletionCommands); err == nil {
+		t.Error("GetCompletionScript(tcsh) succeeded, want an error")
+	}
+}
+
+// TestBashCompletion runs the bash completion function the way bash would on
+// a tab press
+func TestBashCompletion(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("Skipping test: bash not found in PATH")
+	}
+
+	tmpDir := t.TempDir()
+	script, err := GetCompletionScript(ShellBash, "kubectx-timeout", testCompletionCommands)
+	if err != nil {
+		t.Fatalf("GetCompletionScript() error = %v", err)
+	}
+	scriptPath := filepath.Join(tmpDir, "completion.bash")
+	if err := os.WriteFile(scriptPath, []byte(script), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A kubectl with two contexts, and a wrapper function that must not be
+	// called, since it would record activity
+	mockKubectl := "#!/bin/sh\nprintf 'dev\\nprod-eu\\n'\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "kubectl"), []byte(mockKubectl), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"pa"}, "pause-context"},
+		{[]string{"config", ""}, "show"},
+		{[]string{"config", "show", "--"}, "--json --config"},
+		{[]string{"pause-context", ""}, "dev prod-eu --clear"},
+		{[]string{"pause-context", "p"}, "prod-eu"},
+		{[]string{"prompt", "--color", ""}, "never ansi"},
+		{[]string{"logs", "-n", ""}, ""},
+		{[]string{"version", ""}, ""},
+	}
+	for _, tt := range tests {
+		words := append([]string{"kubectx-timeout"}, tt.words...)
+		quoted := make([]string, len(words))
+		for i, word := range words {
+			quoted[i] = shellQuote(word)
+		}
+		test := "kubectl() { echo wrapper-called; }\n" +
+			"source " + shellQuote(scriptPath) + "\n" +
+			"COMP_WORDS=(" + strings.Join(quoted, " ") + ")\n" +
+			"COMP_CWORD=$((${#COMP_WORDS[@]} - 1))\n" +
+			"_kubectx_timeout\n" +
+			`echo "${COMPREPLY[*]}"` + "\n"
+
+		cmd := exec.Command("bash", "--norc", "--noprofile", "-c", test)
+		cmd.Env = append(os.Environ(), "PATH="+tmpDir+":"+os.Getenv("PATH"))
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("completing %q failed: %v\n%s", tt.words, err, output)
+		}
+		if got := strings.TrimSpace(string(output)); got != tt.want {
+			t.Errorf("completing %q = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
@@ -5,50 +5,131 @@ package internal
 
 import (
 	"fmt"
+	"maps"
 	"os"
-	"path/filepath"
-	"strings"
+	"regexp"
+	"runtime"
+	"slices"
 	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
 const (
 	// ConfigureMePlaceholder is the default placeholder for unconfigured context
 	ConfigureMePlaceholder = "CONFIGURE_ME"
+
+	// DefaultUndoWindow is how long after an automatic switch undo works,
+	// unless timeout.undo_window says otherwise
+	DefaultUndoWindow = 10 * time.Minute
 )
 
+// namespacePattern matches a valid Kubernetes namespace name (an RFC 1123
+// label)
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
 // Config represents the kubectx-timeout configuration
 type Config struct {
+	// Preset names a TimeoutPreset that supplies timeout.default and a
+	// timeout for production-like contexts; settings in the file override it
+	Preset string `yaml:"preset,omitempty"`
+
+	// Include lists configuration files, or globs of them, to layer beneath
+	// this one, such as an organization's base policy
+	Include []string `yaml:"include,omitempty"`
+
+	// Aliases are short names for contexts, usable wherever the
+	// configuration or a command takes a context name
+	Aliases map[string]string `yaml:"aliases,omitempty"`
+
 	Timeout        TimeoutConfig      `yaml:"timeout"`
 	DefaultContext string             `yaml:"default_context"`
 	Contexts       map[string]Context `yaml:"contexts,omitempty"`
+	Clusters       map[string]Context `yaml:"clusters,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles,omitempty"`
+	Kubeconfigs    []KubeconfigPolicy `yaml:"kubeconfigs,omitempty"`
 	Daemon         DaemonConfig       `yaml:"daemon"`
 	Notifications  NotificationConfig `yaml:"notifications"`
 	Safety         SafetyConfig       `yaml:"safety"`
+	Switcher       SwitcherConfig     `yaml:"switcher,omitempty"`
+	CacheCleanup   CacheCleanupConfig `yaml:"cache_cleanup,omitempty"`
+	Schedule       ScheduleConfig     `yaml:"schedule,omitempty"`
+	Hooks          HooksConfig        `yaml:"hooks,omitempty"`
+	Tracking       TrackingConfig     `yaml:"tracking,omitempty"`
+	History        HistoryConfig      `yaml:"history,omitempty"`
+	State          StateConfig        `yaml:"state,omitempty"`
 	StateFile      string             `yaml:"state_file"`
 	Shell          ShellConfig        `yaml:"shell"`
+
+	// KubeClient is how kubeconfigs are read and changed: kubectl (the
+	// default) or native, which edits the files directly
+	KubeClient string `yaml:"kube_client,omitempty"`
+
+	// KubectlTimeout bounds each kubectl command the daemon runs, so a hung
+	// auth plugin can't stall it; zero uses DefaultKubectlTimeout
+	KubectlTimeout time.Duration `yaml:"kubectl_timeout,omitempty"`
+
+	// contextServer looks up the cluster server of a context for clusters
+	// rules; nil reads it from the kubeconfig
+	contextServer func(contextName string) string
+
+	// project is the project configuration applied by WithProject, if any
+	project *ProjectConfig
+
+	// policy is the organization policy enforced on the configuration, if
+	// one is installed
+	policy *OrgPolicy
 }
 
 // TimeoutConfig holds global timeout settings
 type TimeoutConfig struct {
 	Default       time.Duration `yaml:"default"`
 	CheckInterval time.Duration `yaml:"check_interval"`
+
+	// GracePeriod delays a due switch so it can be canceled with the
+	// cancel-switch command. Zero switches immediately.
+	GracePeriod time.Duration `yaml:"grace_period,omitempty"`
+
+	// WriteCommands is the timeout after a kubectl command that changes the
+	// cluster, such as apply or delete, when it is longer than the
+	// context's timeout. Zero treats writes like any other activity.
+	WriteCommands time.Duration `yaml:"write_commands,omitempty"`
+
+	// OnWake is what the daemon does after the system wakes from sleep:
+	// reset, switch, or evaluate (the default)
+	OnWake string `yaml:"on_wake,omitempty"`
+
+	// ResetNamespace is the namespace the daemon sets on a context it
+	// switches away from, so returning to it later doesn't start out in,
+	// say, kube-system. Empty leaves namespaces alone.
+	ResetNamespace string `yaml:"reset_namespace,omitempty"`
+
+	// UndoWindow is how long after an automatic switch the undo command
+	// can still switch back. Zero turns undo off.
+	UndoWindow time.Duration `yaml:"undo_window"`
 }
 
 // Context holds context-specific timeout settings
 type Context struct {
-	Timeout       time.Duration `yaml:"timeout"`
+	Timeout       time.Duration `yaml:"timeout,omitempty"`
 	ConfirmSwitch bool          `yaml:"confirm_switch,omitempty"`
+
+	// DefaultContext overrides the global default_context as the context
+	// to switch to when this one times out
+	DefaultContext string `yaml:"default_context,omitempty"`
+
+	// RevokeCredentials deletes the cached tokens of the context's exec
+	// credential plugin after switching away from it, so the next command
+	// has to log in again
+	RevokeCredentials bool `yaml:"revoke_credentials,omitempty"`
 }
 
 // DaemonConfig holds daemon behavior settings
 type DaemonConfig struct {
 	Enabled       bool   `yaml:"enabled"`
 	LogLevel      string `yaml:"log_level"`
-	LogFile       string `yaml:"log_file"`
-	LogMaxSize    int    `yaml:"log_max_size"`
-	LogMaxBackups int    `yaml:"log_max_backups"`
+	LogFormat     string `yaml:"log_format,omitempty"` // text, json, or console
+	LogFile       string `yaml:"log_file"`             // relative to the state directory; empty logs to stdout
+	LogMaxSize    int    `yaml:"log_max_size"`         // MB before rotation, 0 to never rotate
+	LogMaxBackups int    `yaml:"log_max_backups"`      // rotated files kept
 }
 
 // NotificationConfig holds notification settings
@@ -56,6 +137,19 @@ type NotificationConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	Method  string `yaml:"method"`
 	Message string `yaml:"message,omitempty"`
+
+	// Desktop is the desktop notification backend: macos, linux, or
+	// windows. Empty picks the one for this platform.
+	Desktop string `yaml:"desktop,omitempty"`
+
+	// QuietHours and, while RespectFocus is set, a macOS Focus hold back
+	// desktop and terminal notifications, which are only logged instead
+	QuietHours   QuietHoursConfig `yaml:"quiet_hours,omitempty"`
+	RespectFocus bool             `yaml:"respect_focus"`
+
+	// Webhooks and Slack are sent every switch, even when Enabled is false
+	Webhooks WebhookConfig `yaml:"webhooks,omitempty"`
+	Slack    SlackConfig   `yaml:"slack,omitempty"`
 }
 
 // SafetyConfig holds safety feature settings
@@ -64,12 +158,84 @@ type SafetyConfig struct {
 	NeverSwitchFrom        []string `yaml:"never_switch_from,omitempty"`
 	NeverSwitchTo          []string `yaml:"never_switch_to,omitempty"`
 	ValidateDefaultContext bool     `yaml:"validate_default_context"`
+
+	// NeverSwitchFromClusters and NeverSwitchToClusters extend
+	// never_switch_from and never_switch_to to contexts of clusters whose
+	// API server URL matches, whatever the context is called
+	NeverSwitchFromClusters []string `yaml:"never_switch_from_clusters,omitempty"`
+	NeverSwitchToClusters   []string `yaml:"never_switch_to_clusters,omitempty"`
+
+	// SwitchOnLock switches to the default context as soon as the screen
+	// is locked, instead of waiting out the timeout
+	SwitchOnLock bool `yaml:"switch_on_lock,omitempty"`
+
+	// MaxDefer caps how long running Kubernetes tools defer a switch
+	// (check_active_kubectl), so a forgotten port-forward doesn't keep a
+	// context forever. Zero defers for as long as they run.
+	MaxDefer time.Duration `yaml:"max_defer,omitempty"`
+}
+
+// CacheCleanupConfig holds settings for clearing kubectl's caches after
+// switching away from a context
+type CacheCleanupConfig struct {
+	// Contexts whose cluster's discovery cache is cleared after the daemon
+	// switches away from them. Entries may be glob patterns (e.g. "prod-*").
+	Contexts []string `yaml:"contexts,omitempty"`
+
+	// HTTPCache also clears kubectl's HTTP cache. It is keyed by hashed
+	// URLs rather than cluster, so it is removed entirely.
+	HTTPCache bool `yaml:"http_cache,omitempty"`
+}
+
+// TrackingConfig holds settings for what is recorded about kubectl activity
+type TrackingConfig struct {
+	// Metadata is how much of each command the history log keeps: off,
+	// verb, or verb+resource. Names, flags, and values are never kept.
+	Metadata string `yaml:"metadata,omitempty"`
+}
+
+// HistoryConfig holds the history log's retention. The log is compacted to
+// it when the daemon starts and by the history prune command; zero fields
+// keep everything up to the log's size limit.
+type HistoryConfig struct {
+	// MaxEntries is how many of the most recent events are kept
+	MaxEntries int `yaml:"max_entries,omitempty"`
+	// MaxAge is how long events are kept
+	MaxAge time.Duration `yaml:"max_age,omitempty"`
+}
+
+// StateConfig holds settings for the state file and history log
+type StateConfig struct {
+	// Encrypt encrypts the state file and history log at rest, since
+	// context names can reveal customers and clusters
+	Encrypt bool `yaml:"encrypt,omitempty"`
+	// KeyFile holds a passphrase the key is derived from. Empty keeps a
+	// generated key in the macOS Keychain.
+	KeyFile string `yaml:"key_file,omitempty"`
 }
 
 // ShellConfig holds shell integration settings
 type ShellConfig struct {
 	GenerateWrapper bool     `yaml:"generate_wrapper"`
 	Shells          []string `yaml:"shells"`
+
+	// WrapCommands are the commands install-shell wraps to record activity
+	WrapCommands []string `yaml:"wrap_commands,omitempty"`
+
+	// ExtraAliases are name=command aliases to track alongside the ones
+	// install-shell detects in the profile
+	ExtraAliases []string `yaml:"extra_aliases,omitempty"`
+
+	// RemainingTime makes the wrappers print the time left, such as
+	// "[prod: 7m left]", after each command once less than this remains.
+	// Zero (the default) never prints it. The integration has to be
+	// reinstalled after changing it.
+	RemainingTime time.Duration `yaml:"remaining_time,omitempty"`
+
+	// ProfileBackups is how many timestamped backups of each shell profile
+	// install-shell, uninstall-shell, and restore-profile keep; zero keeps
+	// DefaultProfileBackups
+	ProfileBackups int `yaml:"profile_backups,omitempty"`
 }
 
 // DefaultConfig returns a configuration with sensible defaults
@@ -81,27 +247,39 @@ func DefaultConfig() *Config {
 		Timeout: TimeoutConfig{
 			Default:       30 * time.Minute,
 			CheckInterval: 30 * time.Second,
+			OnWake:        OnWakeEvaluate,
+			UndoWindow:    DefaultUndoWindow,
 		},
 		DefaultContext: defaultCtx,
 		Daemon: DaemonConfig{
 			Enabled:       true,
 			LogLevel:      "info",
+			LogFormat:     LogFormatText,
 			LogFile:       "daemon.log",
 			LogMaxSize:    10,
 			LogMaxBackups: 5,
 		},
 		Notifications: NotificationConfig{
-			Enabled: true,
-			Method:  "both",
+			Enabled:      true,
+			Method:       "both",
+			RespectFocus: true,
+			Webhooks: WebhookConfig{
+				Timeout: DefaultWebhookTimeout,
+				Retries: DefaultWebhookRetries,
+			},
 		},
 		Safety: SafetyConfig{
 			CheckActiveKubectl:     true,
 			ValidateDefaultContext: true,
 		},
+		Tracking: TrackingConfig{
+			Metadata: MetadataOff,
+		},
 		StateFile: "state.json",
 		Shell: ShellConfig{
 			GenerateWrapper: true,
 			Shells:          []string{"bash", "zsh"},
+			WrapCommands:    slices.Clone(DefaultWrapCommands),
 		},
 	}
 }
@@ -114,113 +292,205 @@ func detectSafeDefaultContext() string {
 		return ConfigureMePlaceholder
 	}
 
-	// Patterns that indicate a safe/dev context (in priority order)
-	safePatterns := []string{
-		"local",
-		"docker-desktop",
-		"minikube",
-		"kind-",
-		"dev",
-		"development",
-		"test",
-	}
-
-	// Patterns that indicate dangerous/production contexts
-	dangerousPatterns := []string{
-		"prod",
-		"production",
-		"stage",
-		"staging",
-		"prd",
-	}
-
-	// First pass: look for explicitly safe contexts
-	for _, pattern := range safePatterns {
-		for _, ctx := range contexts {
-			ctxLower := strings.ToLower(ctx)
-			// Check if context name contains the safe pattern
-			if strings.Contains(ctxLower, pattern) {
-				// But make sure it doesn't also contain a dangerous pattern
-				isDangerous := false
-				for _, danger := range dangerousPatterns {
-					if strings.Contains(ctxLower, danger) {
-						isDangerous = true
-						break
-					}
-				}
-				if !isDangerous {
-					return ctx
-				}
-			}
-		}
+	if ctx := PickSafeDefaultContext(contexts); ctx != "" {
+		return ctx
 	}
 
 	// No obviously safe context found - require configuration
-	return "CONFIGURE_ME"
+	return ConfigureMePlaceholder
 }
 
-// LoadConfig loads configuration from the specified file path
-// If the file doesn't exist, returns default configuration
-// If the file exists but is invalid, returns an error
+// LoadConfig loads configuration from the specified file path, with
+// environment variable overrides layered on top
+// If neither the file nor any override exists, returns default configuration
+// If the result is invalid, returns an error
 func LoadConfig(path string) (*Config, error) {
+	config, isDefault, err := readConfig(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if isDefault {
+		return config, nil
+	}
+
+	// Validate the configuration
+	if err := config.Validate(); err != nil {
+		return nil, MarkFailure(fmt.Errorf("invalid configuration: %w", err), ErrConfigInvalid)
+	}
+
+	return config, nil
+}
+
+// ReadConfig loads configuration like LoadConfig, but without validating it,
+// so that all of its problems can be reported
+func ReadConfig(path string) (*Config, error) {
+	config, _, err := readConfig(path, nil)
+	return config, err
+}
+
+// ReadConfigSources loads configuration like ReadConfig, and also returns
+// where each value that isn't a default was set
+func ReadConfigSources(path string) (*Config, ConfigSources, error) {
+	sources := make(ConfigSources)
+	config, _, err := readConfig(path, sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, sources, nil
+}
+
+// readConfig loads the configuration, with the organization policy enforced
+// on it, and reports whether it is just the defaults, with no file or
+// environment overrides. If sources isn't nil,
+// where each value was set is recorded in it.
+func readConfig(path string, sources ConfigSources) (*Config, bool, error) {
+	config, isDefault, err := readConfigFile(path, sources)
+	if err != nil {
+		return nil, false, MarkFailure(err, ErrConfigInvalid)
+	}
+
+	overrides, err := applyEnvOverrides(config, sources)
+	if err != nil {
+		return nil, false, MarkFailure(err, ErrConfigInvalid)
+	}
+	if err := config.resolveAliases(sources); err != nil {
+		return nil, false, MarkFailure(err, ErrConfigInvalid)
+	}
+
+	// The policy goes last so that nothing the user sets can loosen it
+	policy, err := LoadOrgPolicy(orgPolicyPath())
+	if err != nil {
+		return nil, false, MarkFailure(err, ErrConfigInvalid)
+	}
+	config.applyOrgPolicy(policy, sources)
+
+	return config, isDefault && overrides == 0, nil
+}
+
+// readConfigFile loads the configuration file, layered over the files it
+// includes, over the defaults, reporting whether the file was missing
+func readConfigFile(path string, sources ConfigSources) (*Config, bool, error) {
 	// Expand ~ to home directory
-	if len(path) > 0 && path[0] == '~' {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
-		}
-		path = filepath.Join(home, path[1:])
+	path, err := expandConfigPath(path)
+	if err != nil {
+		return nil, false, err
 	}
 
 	// Check if file exists
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		// File doesn't exist, return default config
-		return DefaultConfig(), nil
+		legacy, ok := legacyConfigPath()
+		if !ok || path != GetConfigPath() {
+			// File doesn't exist, return default config
+			return DefaultConfig(), true, nil
+		}
+
+		// Older releases kept the config in ~/.kubectx-timeout; keep using
+		// it rather than silently falling back to the defaults
+		legacyConfigWarning.Do(func() {
+			fmt.Fprintf(os.Stderr, "Warning: using legacy config %s; run 'kubectx-timeout migrate' to move it to %s\n", legacy, path)
+		})
+		path = legacy
 	}
 
 	// Read file
 	// #nosec G304 -- path is a configuration file path provided by user/system
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, false, fmt.Errorf("failed to read config file: %w", err)
 	}
-
-	// Start with default config and unmarshal on top of it
-	// This ensures any missing fields get default values
-	config := DefaultConfig()
-	if err := yaml.Unmarshal(data, config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	if data, err = layerConfigFiles(path, data, sources); err != nil {
+		return nil, false, err
 	}
 
-	// Validate the configuration
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+	config, err := parseConfig(data)
+	if err != nil {
+		return nil, false, err
 	}
 
-	return config, nil
+	return config, false, nil
 }
 
-// Validate checks if the configuration is valid
+// Validate checks if the configuration is valid, returning the first problem
 func (c *Config) Validate() error {
-	// Check required fields
-	if c.DefaultContext == "" {
-		return fmt.Errorf("default_context is required")
+	if errs := c.ValidationErrors(); len(errs) > 0 {
+		return errs[0]
 	}
+	return nil
+}
+
+// ValidationErrors checks the configuration and returns every problem found,
+// rather than stopping at the first like Validate
+func (c *Config) ValidationErrors() []error {
+	var errs []error
 
-	// Check if default context needs to be configured
-	if c.DefaultContext == ConfigureMePlaceholder {
-		return fmt.Errorf("default_context must be configured - run 'kubectx-timeout init' to set up")
+	if c.Preset != "" {
+		if _, ok := LookupPreset(c.Preset); !ok {
+			errs = append(errs, fmt.Errorf("unknown preset '%s' (must be one of %s)", c.Preset, PresetNames()))
+		}
+	}
+
+	// Check required fields
+	if c.DefaultContext == "" {
+		errs = append(errs, fmt.Errorf("default_context is required"))
+	} else if c.DefaultContext == ConfigureMePlaceholder {
+		// Check if default context needs to be configured
+		errs = append(errs, fmt.Errorf("default_context must be configured - run 'kubectx-timeout init' to set up"))
 	}
 
 	// Validate timeout durations
 	if c.Timeout.Default <= 0 {
-		return fmt.Errorf("timeout.default must be positive")
+		errs = append(errs, fmt.Errorf("timeout.default must be positive"))
 	}
 	if c.Timeout.CheckInterval <= 0 {
-		return fmt.Errorf("timeout.check_interval must be positive")
+		errs = append(errs, fmt.Errorf("timeout.check_interval must be positive"))
+	} else if c.Timeout.Default > 0 && c.Timeout.CheckInterval > c.Timeout.Default {
+		errs = append(errs, fmt.Errorf("timeout.check_interval must be less than timeout.default"))
+	}
+	switch c.KubeClient {
+	case "", KubeClientKubectl, KubeClientNative:
+	default:
+		errs = append(errs, fmt.Errorf("kube_client must be one of: kubectl, native"))
+	}
+	if c.KubectlTimeout < 0 {
+		errs = append(errs, fmt.Errorf("kubectl_timeout must not be negative"))
+	}
+	if c.Timeout.GracePeriod < 0 {
+		errs = append(errs, fmt.Errorf("timeout.grace_period must not be negative"))
 	}
-	if c.Timeout.CheckInterval > c.Timeout.Default {
-		return fmt.Errorf("timeout.check_interval must be less than timeout.default")
+	if c.Timeout.WriteCommands < 0 {
+		errs = append(errs, fmt.Errorf("timeout.write_commands must not be negative"))
+	}
+	if c.Timeout.UndoWindow < 0 {
+		errs = append(errs, fmt.Errorf("timeout.undo_window must not be negative"))
+	}
+	switch c.Timeout.OnWake {
+	case "", OnWakeReset, OnWakeSwitch, OnWakeEvaluate:
+	default:
+		errs = append(errs, fmt.Errorf("timeout.on_wake must be one of: reset, switch, evaluate"))
+	}
+	if c.Timeout.ResetNamespace != "" && !namespacePattern.MatchString(c.Timeout.ResetNamespace) {
+		errs = append(errs, fmt.Errorf("timeout.reset_namespace %q is not a valid namespace name", c.Timeout.ResetNamespace))
+	}
+
+	if c.Safety.MaxDefer < 0 {
+		errs = append(errs, fmt.Errorf("safety.max_defer must not be negative"))
+	}
+
+	switch c.Tracking.Metadata {
+	case "", MetadataOff, MetadataVerb, MetadataVerbResource:
+	default:
+		errs = append(errs, fmt.Errorf("tracking.metadata must be one of: off, verb, verb+resource"))
+	}
+
+	if c.State.Encrypt && c.State.KeyFile == "" && runtime.GOOS != "darwin" {
+		errs = append(errs, fmt.Errorf("state.key_file is required for state.encrypt outside macOS"))
+	}
+
+	if c.History.MaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("history.max_entries must not be negative"))
+	}
+	if c.History.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("history.max_age must not be negative"))
 	}
 
 	// Validate log level
@@ -231,44 +501,302 @@ func (c *Config) Validate() error {
 		"error": true,
 	}
 	if !validLogLevels[c.Daemon.LogLevel] {
-		return fmt.Errorf("daemon.log_level must be one of: debug, info, warn, error")
+		errs = append(errs, fmt.Errorf("daemon.log_level must be one of: debug, info, warn, error"))
+	}
+	switch c.Daemon.LogFormat {
+	case "", LogFormatText, LogFormatJSON, LogFormatConsole:
+	default:
+		errs = append(errs, fmt.Errorf("daemon.log_format must be one of: text, json, console"))
+	}
+	if c.Daemon.LogMaxSize < 0 {
+		errs = append(errs, fmt.Errorf("daemon.log_max_size must not be negative"))
+	}
+	if c.Daemon.LogMaxBackups < 0 {
+		errs = append(errs, fmt.Errorf("daemon.log_max_backups must not be negative"))
 	}
 
 	// Validate notification method
 	validMethods := map[string]bool{
-		"terminal": true,
-		"macos":    true,
-		"both":     true,
+		NotificationMethodTerminal: true,
+		NotificationMethodDesktop:  true,
+		NotificationMethodMacOS:    true,
+		NotificationMethodBoth:     true,
 	}
 	if !validMethods[c.Notifications.Method] {
-		return fmt.Errorf("notifications.method must be one of: terminal, macos, both")
+		errs = append(errs, fmt.Errorf("notifications.method must be one of: terminal, desktop, both"))
 	}
+	if c.Notifications.Desktop != "" && !slices.Contains(DesktopBackends, c.Notifications.Desktop) {
+		errs = append(errs, fmt.Errorf("notifications.desktop must be one of: macos, linux, windows"))
+	}
+	errs = append(errs, c.Notifications.QuietHours.validationErrors()...)
 
-	// Validate context-specific timeouts
-	for name, ctx := range c.Contexts {
-		if ctx.Timeout <= 0 {
-			return fmt.Errorf("timeout for context '%s' must be positive", name)
+	// Validate the notification message template
+	if c.Notifications.Message != "" {
+		sample := SwitchEvent{FromContext: "production", ToContext: c.DefaultContext, Reason: "inactive for 30m0s"}
+		if _, err := RenderSwitchMessage(c.Notifications.Message, sample); err != nil {
+			errs = append(errs, fmt.Errorf("invalid notifications.message: %w", err))
+		}
+	}
+
+	// Validate context-specific settings, in a stable order. The timeout
+	// may be left out when only the default context is overridden.
+	for _, name := range slices.Sorted(maps.Keys(c.Contexts)) {
+		ctx := c.Contexts[name]
+		if err := ValidateContextPattern(name); err != nil {
+			errs = append(errs, fmt.Errorf("invalid contexts pattern '%s': %w", name, err))
+		}
+		if ctx.Timeout < 0 || (ctx.Timeout == 0 && ctx.DefaultContext == "" && !ctx.RevokeCredentials) {
+			errs = append(errs, fmt.Errorf("timeout for context '%s' must be positive", name))
+		}
+		if ctx.DefaultContext == "" {
+			continue
+		}
+		if MatchContextPattern(name, ctx.DefaultContext) {
+			errs = append(errs, fmt.Errorf("default_context for context '%s' must be a different context", name))
+		} else if c.defaultContextCycle(name) {
+			errs = append(errs, fmt.Errorf("default_context for context '%s' switches back to itself", name))
+		}
+		if c.Safety.ValidateDefaultContext && c.IsNeverSwitchTo(ctx.DefaultContext) {
+			errs = append(errs, fmt.Errorf("default_context '%s' for context '%s' is in never_switch_to list", ctx.DefaultContext, name))
 		}
 	}
 
+	// Cluster rules take the same settings, keyed by server URL
+	for _, server := range slices.Sorted(maps.Keys(c.Clusters)) {
+		cluster := c.Clusters[server]
+		if err := ValidateContextPattern(server); err != nil {
+			errs = append(errs, fmt.Errorf("invalid clusters pattern '%s': %w", server, err))
+		}
+		if cluster.Timeout < 0 || (cluster.Timeout == 0 && cluster.DefaultContext == "" && !cluster.RevokeCredentials) {
+			errs = append(errs, fmt.Errorf("timeout for cluster '%s' must be positive", server))
+		}
+		if cluster.DefaultContext != "" && c.Safety.ValidateDefaultContext && c.IsNeverSwitchTo(cluster.DefaultContext) {
+			errs = append(errs, fmt.Errorf("default_context '%s' for cluster '%s' is in never_switch_to list", cluster.DefaultContext, server))
+		}
+	}
+
+	// Validate context patterns in the safety and cache cleanup lists, and
+	// server patterns in the safety cluster lists
+	patternLists := []struct {
+		field    string
+		patterns []string
+	}{
+		{"safety.never_switch_from", c.Safety.NeverSwitchFrom},
+		{"safety.never_switch_to", c.Safety.NeverSwitchTo},
+		{"safety.never_switch_from_clusters", c.Safety.NeverSwitchFromClusters},
+		{"safety.never_switch_to_clusters", c.Safety.NeverSwitchToClusters},
+		{"cache_cleanup.contexts", c.CacheCleanup.Contexts},
+	}
+	for _, list := range patternLists {
+		for _, pattern := range list.patterns {
+			if err := ValidateContextPattern(pattern); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s pattern '%s': %w", list.field, pattern, err))
+			}
+		}
+	}
+
+	errs = append(errs, c.aliasValidationErrors()...)
+	errs = append(errs, c.Notifications.Webhooks.validationErrors()...)
+	errs = append(errs, c.Notifications.Slack.validationErrors()...)
+	errs = append(errs, c.Schedule.validationErrors()...)
+	errs = append(errs, c.Hooks.validationErrors()...)
+	errs = append(errs, c.Switcher.validationErrors()...)
+
+	// Validate the commands to wrap, unless left unset
+	if c.Shell.WrapCommands != nil {
+		if err := ValidateWrapCommands(c.Shell.WrapCommands); err != nil {
+			errs = append(errs, fmt.Errorf("invalid shell.wrap_commands: %w", err))
+		}
+	}
+	if _, err := ParseShellAliases(c.Shell.ExtraAliases); err != nil {
+		errs = append(errs, fmt.Errorf("invalid shell.extra_aliases: %w", err))
+	}
+	if c.Shell.RemainingTime < 0 {
+		errs = append(errs, fmt.Errorf("shell.remaining_time must not be negative"))
+	}
+	if c.Shell.ProfileBackups < 0 {
+		errs = append(errs, fmt.Errorf("shell.profile_backups must not be negative"))
+	}
+
 	// Check for conflicts in safety settings
-	if c.Safety.ValidateDefaultContext {
-		for _, ctx := range c.Safety.NeverSwitchTo {
-			if ctx == c.DefaultContext {
-				return fmt.Errorf("default_context '%s' is in never_switch_to list", c.DefaultContext)
+	if c.Safety.ValidateDefaultContext && c.IsNeverSwitchTo(c.DefaultContext) {
+		errs = append(errs, fmt.Errorf("default_context '%s' is in never_switch_to list", c.DefaultContext))
+	}
+
+	errs = append(errs, c.profileValidationErrors(errs)...)
+	errs = append(errs, c.kubeconfigValidationErrors(errs)...)
+
+	return errs
+}
+
+// CheckContexts returns a problem for each context the configuration names
+// that isn't among the available kubeconfig contexts. Patterns must match at
+// least one context.
+func (c *Config) CheckContexts(available []string) []error {
+	var errs []error
+	missing := func(field, name string) {
+		if IsContextPattern(name) {
+			if !slices.ContainsFunc(available, func(ctx string) bool { return MatchContextPattern(name, ctx) }) {
+				errs = append(errs, fmt.Errorf("%s: pattern '%s' matches no context in kubeconfig", field, name))
 			}
+		} else if !slices.Contains(available, name) {
+			errs = append(errs, fmt.Errorf("%s: context '%s' not found in kubeconfig", field, name))
 		}
 	}
 
-	return nil
+	if c.DefaultContext != "" && c.DefaultContext != ConfigureMePlaceholder {
+		missing("default_context", c.DefaultContext)
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Contexts)) {
+		missing("contexts", name)
+		if target := c.Contexts[name].DefaultContext; target != "" {
+			missing(fmt.Sprintf("contexts.%s.default_context", name), target)
+		}
+	}
+	for _, name := range c.Safety.NeverSwitchFrom {
+		missing("safety.never_switch_from", name)
+	}
+	for _, name := range c.Safety.NeverSwitchTo {
+		missing("safety.never_switch_to", name)
+	}
+	for _, pattern := range c.CacheCleanup.Contexts {
+		missing("cache_cleanup.contexts", pattern)
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Schedule.AfterHours.Contexts)) {
+		missing("schedule.after_hours.contexts", name)
+	}
+	for _, alias := range slices.Sorted(maps.Keys(c.Aliases)) {
+		missing("aliases."+alias, c.Aliases[alias])
+	}
+
+	return errs
+}
+
+// contextSettings returns the contexts entry for a context: the entry named
+// exactly, or else the first pattern in alphabetical order that matches it.
+// Contexts no entry matches fall back to the clusters entry for their
+// cluster's server.
+func (c *Config) contextSettings(contextName string) (Context, bool) {
+	if ctx, ok := c.Contexts[contextName]; ok {
+		return ctx, true
+	}
+	for _, pattern := range slices.Sorted(maps.Keys(c.Contexts)) {
+		if IsContextPattern(pattern) && MatchContextPattern(pattern, contextName) {
+			return c.Contexts[pattern], true
+		}
+	}
+	return c.clusterSettings(contextName)
 }
 
 // GetTimeoutForContext returns the timeout duration for a specific context
-// If the context has a specific timeout configured, returns that
+// If the context has a specific timeout configured, or matches a pattern
+// that does, returns that
 // Otherwise returns the default timeout
 func (c *Config) GetTimeoutForContext(contextName string) time.Duration {
-	if ctx, ok := c.Contexts[contextName]; ok {
+	return c.GetTimeoutForContextAt(contextName, time.Now())
+}
+
+// GetTimeoutForContextAt returns the timeout for a context at the given
+// time. Outside the schedule's work hours, after-hours timeouts take
+// precedence over the context's usual timeout, and the after-hours default
+// over timeout.default. A preset's production timeout applies to
+// production-like contexts without an entry of their own. A project
+// configuration's timeout applies if it is shorter, and the organization
+// policy caps the result.
+func (c *Config) GetTimeoutForContextAt(contextName string, now time.Time) time.Duration {
+	timeout := c.configuredTimeoutAt(contextName, now)
+	if d, ok := c.project.timeoutFor(contextName); ok && d < timeout {
+		timeout = d
+	}
+	if d, ok := c.policy.maxTimeoutFor(contextName); ok && d < timeout {
+		timeout = d
+	}
+	return timeout
+}
+
+// configuredTimeoutAt returns the timeout for a context at the given time
+// from the configuration alone, leaving out any project configuration
+func (c *Config) configuredTimeoutAt(contextName string, now time.Time) time.Duration {
+	afterHours := c.IsAfterHours(now)
+	if afterHours {
+		if d, ok := c.Schedule.afterHoursTimeout(contextName); ok {
+			return d
+		}
+	}
+	if ctx, ok := c.contextSettings(contextName); ok && ctx.Timeout > 0 {
 		return ctx.Timeout
 	}
+	if d, ok := c.presetTimeout(contextName); ok {
+		return d
+	}
+	if afterHours && c.Schedule.AfterHours.Default > 0 {
+		return c.Schedule.AfterHours.Default
+	}
 	return c.Timeout.Default
 }
+
+// IsAfterHours reports whether after-hours timeouts apply at the given time
+func (c *Config) IsAfterHours(now time.Time) bool {
+	return c.Schedule.Enabled() && !c.Schedule.InWorkHours(now)
+}
+
+// GetDefaultContextFor returns the context to switch to when the given
+// context times out: its own default_context if set, otherwise the global one.
+// A project configuration's default context takes precedence over both, if
+// the configuration switches to it anyway.
+func (c *Config) GetDefaultContextFor(contextName string) string {
+	if defaultContext, ok := c.project.defaultContextFor(contextName); ok && c.isSwitchTarget(defaultContext) {
+		return defaultContext
+	}
+	if ctx, ok := c.contextSettings(contextName); ok && ctx.DefaultContext != "" {
+		return ctx.DefaultContext
+	}
+	return c.DefaultContext
+}
+
+// defaultContextCycle reports whether following per-context default
+// contexts from the given contexts entry leads back to it, so timeouts would
+// keep switching between the same contexts
+func (c *Config) defaultContextCycle(contextName string) bool {
+	seen := map[string]bool{}
+	next := c.Contexts[contextName].DefaultContext
+	for next != "" && next != c.DefaultContext {
+		if MatchContextPattern(contextName, next) {
+			return true
+		}
+		if seen[next] {
+			// A cycle further along, reported for its own contexts
+			return false
+		}
+		seen[next] = true
+		next = c.GetDefaultContextFor(next)
+	}
+	return false
+}
+
+// IsNeverSwitchFrom reports whether the context matches the never_switch_from
+// list, or its cluster the never_switch_from_clusters list
+func (c *Config) IsNeverSwitchFrom(contextName string) bool {
+	return MatchAnyContextPattern(c.Safety.NeverSwitchFrom, contextName) ||
+		c.matchesServerPatterns(c.Safety.NeverSwitchFromClusters, contextName)
+}
+
+// IsNeverSwitchTo reports whether the context matches the never_switch_to
+// list, or its cluster the never_switch_to_clusters list
+func (c *Config) IsNeverSwitchTo(contextName string) bool {
+	return MatchAnyContextPattern(c.Safety.NeverSwitchTo, contextName) ||
+		c.matchesServerPatterns(c.Safety.NeverSwitchToClusters, contextName)
+}
+
+// ShouldRevokeCredentials reports whether the cached credentials of the
+// given context should be deleted after switching away from it
+func (c *Config) ShouldRevokeCredentials(contextName string) bool {
+	ctx, ok := c.contextSettings(contextName)
+	return ok && ctx.RevokeCredentials
+}
+
+// ShouldClearCache reports whether kubectl's caches should be cleared after
+// switching away from the given context
+func (c *Config) ShouldClearCache(contextName string) bool {
+	return MatchAnyContextPattern(c.CacheCleanup.Contexts, contextName)
+}
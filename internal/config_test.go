@@ -3,6 +3,8 @@ package internal
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -97,6 +99,9 @@ contexts:
     timeout: 5m
   dev:
     timeout: 1h
+
+shell:
+  wrap_commands: [kubectl, stern]
 `
 
 	if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
@@ -121,6 +126,11 @@ contexts:
 		t.Errorf("expected log_level to be 'debug', got '%s'", cfg.Daemon.LogLevel)
 	}
 
+	// The configured commands replace the defaults
+	if !slices.Equal(cfg.Shell.WrapCommands, []string{"kubectl", "stern"}) {
+		t.Errorf("expected wrap_commands [kubectl stern], got %v", cfg.Shell.WrapCommands)
+	}
+
 	// Verify context-specific timeouts
 	if ctx, ok := cfg.Contexts["production"]; !ok {
 		t.Error("expected 'production' context to be loaded")
@@ -217,6 +227,19 @@ func TestValidate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "invalid desktop notification backend",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "desktop", Desktop: "growl"},
+			},
+			wantError: true,
+		},
 		{
 			name: "check_interval greater than default",
 			config: &Config{
@@ -230,6 +253,236 @@ func TestValidate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "negative grace period",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+					GracePeriod:   -1 * time.Minute,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+			},
+			wantError: true,
+		},
+		{
+			name: "negative write commands timeout",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+					WriteCommands: -time.Minute,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+			},
+			wantError: true,
+		},
+		{
+			name: "valid reset namespace",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:        30 * time.Minute,
+					CheckInterval:  30 * time.Second,
+					ResetNamespace: "default",
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+			},
+			wantError: false,
+		},
+		{
+			name: "invalid reset namespace",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:        30 * time.Minute,
+					CheckInterval:  30 * time.Second,
+					ResetNamespace: "Kube_System",
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+			},
+			wantError: true,
+		},
+		{
+			name: "valid tracking metadata",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+				Tracking:      TrackingConfig{Metadata: MetadataVerbResource},
+			},
+			wantError: false,
+		},
+		{
+			name: "invalid tracking metadata",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+				Tracking:      TrackingConfig{Metadata: "full"},
+			},
+			wantError: true,
+		},
+		{
+			name: "negative history retention",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+				History:       HistoryConfig{MaxAge: -time.Hour},
+			},
+			wantError: true,
+		},
+		{
+			name: "invalid notification message template",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both", Message: "switched to {{.Context}}"},
+			},
+			wantError: true,
+		},
+		{
+			name: "invalid cache cleanup pattern",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+				CacheCleanup:  CacheCleanupConfig{Contexts: []string{"prod-["}},
+			},
+			wantError: true,
+		},
+		{
+			name: "invalid wrap command",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+				Shell:         ShellConfig{WrapCommands: []string{"kubectl", "rm -rf"}},
+			},
+			wantError: true,
+		},
+		{
+			name: "empty wrap commands",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+				Shell:         ShellConfig{WrapCommands: []string{}},
+			},
+			wantError: true,
+		},
+		{
+			name: "context with only a default context",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Contexts:      map[string]Context{"prod-us": {DefaultContext: "staging-us"}},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+			},
+			wantError: false,
+		},
+		{
+			name: "context default context is itself",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Contexts:      map[string]Context{"prod-us": {DefaultContext: "prod-us"}},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+			},
+			wantError: true,
+		},
+		{
+			name: "context default contexts switch back and forth",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Contexts: map[string]Context{
+					"prod-us":    {DefaultContext: "staging-us"},
+					"staging-us": {DefaultContext: "prod-us"},
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+			},
+			wantError: true,
+		},
+		{
+			name: "context default context in never_switch_to",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Contexts:      map[string]Context{"prod-us": {DefaultContext: "prod-eu"}},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+				Safety: SafetyConfig{
+					NeverSwitchTo:          []string{"prod-eu"},
+					ValidateDefaultContext: true,
+				},
+			},
+			wantError: true,
+		},
+		{
+			name: "invalid extra alias",
+			config: &Config{
+				DefaultContext: "local",
+				Timeout: TimeoutConfig{
+					Default:       30 * time.Minute,
+					CheckInterval: 30 * time.Second,
+				},
+				Daemon:        DaemonConfig{LogLevel: "info"},
+				Notifications: NotificationConfig{Method: "both"},
+				Shell:         ShellConfig{ExtraAliases: []string{"k"}},
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -242,6 +495,79 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidationErrors(t *testing.T) {
+	cfg := &Config{
+		DefaultContext: "local",
+		Timeout:        TimeoutConfig{Default: -time.Minute, CheckInterval: 30 * time.Second},
+		Daemon:         DaemonConfig{LogLevel: "loud", LogFormat: "xml", LogMaxBackups: -1},
+		Notifications:  NotificationConfig{Method: "both"},
+		Contexts:       map[string]Context{"prod": {}, "dev": {}},
+	}
+
+	errs := cfg.ValidationErrors()
+	want := []string{
+		"timeout.default must be positive",
+		"daemon.log_level must be one of: debug, info, warn, error",
+		"daemon.log_format must be one of: text, json, console",
+		"daemon.log_max_backups must not be negative",
+		"timeout for context 'dev' must be positive",
+		"timeout for context 'prod' must be positive",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("ValidationErrors() = %v, want %d problems", errs, len(want))
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("ValidationErrors()[%d] = %q, want %q", i, err, want[i])
+		}
+	}
+
+	// Validate reports the first problem
+	if err := cfg.Validate(); err == nil || err.Error() != want[0] {
+		t.Errorf("Validate() = %v, want %q", err, want[0])
+	}
+}
+
+func TestCheckContexts(t *testing.T) {
+	cfg := &Config{
+		DefaultContext: "local",
+		Contexts:       map[string]Context{"prod": {Timeout: time.Minute, DefaultContext: "staging"}},
+		Safety:         SafetyConfig{NeverSwitchFrom: []string{"prod", "staging"}},
+		CacheCleanup:   CacheCleanupConfig{Contexts: []string{"prod-*", "pr*"}},
+	}
+
+	errs := cfg.CheckContexts([]string{"local", "prod"})
+	if len(errs) != 3 {
+		t.Fatalf("CheckContexts() = %v, want 3 problems", errs)
+	}
+	if !strings.Contains(errs[0].Error(), "contexts.prod.default_context: context 'staging'") ||
+		!strings.Contains(errs[1].Error(), "'staging'") || !strings.Contains(errs[2].Error(), "'prod-*'") {
+		t.Errorf("CheckContexts() = %v, want staging twice and prod-* reported", errs)
+	}
+
+	if errs := cfg.CheckContexts([]string{"local", "prod", "staging", "prod-eu"}); len(errs) != 0 {
+		t.Errorf("CheckContexts() = %v, want none", errs)
+	}
+}
+
+func TestReadConfigSkipsValidation(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("default_context: local\ndaemon:\n  log_level: loud\n"), 0600); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := ReadConfig(configPath)
+	if err != nil {
+		t.Fatalf("ReadConfig() error = %v", err)
+	}
+	if cfg.Daemon.LogLevel != "loud" || cfg.Timeout.Default != 30*time.Minute {
+		t.Errorf("ReadConfig() should apply defaults under the file, got %+v", cfg)
+	}
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Error("LoadConfig() should reject the invalid log level")
+	}
+}
+
 func TestGetTimeoutForContext(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.Contexts = map[string]Context{
@@ -280,3 +606,139 @@ func TestGetTimeoutForContext(t *testing.T) {
 		})
 	}
 }
+
+func TestGetDefaultContextFor(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DefaultContext = "local"
+	cfg.Contexts = map[string]Context{
+		"prod-us": {Timeout: 5 * time.Minute, DefaultContext: "staging-us"},
+		"prod-eu": {DefaultContext: "staging-eu"},
+		"dev":     {Timeout: time.Hour},
+	}
+
+	tests := map[string]string{
+		"prod-us": "staging-us",
+		"prod-eu": "staging-eu",
+		"dev":     "local",
+		"other":   "local",
+	}
+	for contextName, want := range tests {
+		if got := cfg.GetDefaultContextFor(contextName); got != want {
+			t.Errorf("GetDefaultContextFor(%s) = %s, want %s", contextName, got, want)
+		}
+	}
+
+	// A context that only overrides the default context keeps the default timeout
+	if got := cfg.GetTimeoutForContext("prod-eu"); got != cfg.Timeout.Default {
+		t.Errorf("GetTimeoutForContext(prod-eu) = %v, want %v", got, cfg.Timeout.Default)
+	}
+}
+
+func TestContextPatterns(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DefaultContext = "local"
+	cfg.Contexts = map[string]Context{
+		"prod-eu":             {Timeout: 10 * time.Minute},
+		"prod-*":              {Timeout: 5 * time.Minute, DefaultContext: "staging"},
+		"/.*-production$/":    {Timeout: 2 * time.Minute},
+		"/^(dev|sandbox)-/":   {Timeout: 2 * time.Hour},
+		"unrelated-[abc]-ctx": {Timeout: time.Minute},
+	}
+	cfg.Safety.NeverSwitchFrom = []string{"/^admin-/"}
+	cfg.Safety.NeverSwitchTo = []string{"prod-*"}
+
+	// Exact names win over patterns
+	if got := cfg.GetTimeoutForContext("prod-eu"); got != 10*time.Minute {
+		t.Errorf("GetTimeoutForContext(prod-eu) = %v, want 10m", got)
+	}
+	if got := cfg.GetTimeoutForContext("prod-us"); got != 5*time.Minute {
+		t.Errorf("GetTimeoutForContext(prod-us) = %v, want 5m", got)
+	}
+	if got := cfg.GetDefaultContextFor("prod-us"); got != "staging" {
+		t.Errorf("GetDefaultContextFor(prod-us) = %s, want staging", got)
+	}
+	if got := cfg.GetTimeoutForContext("eu-production"); got != 2*time.Minute {
+		t.Errorf("GetTimeoutForContext(eu-production) = %v, want 2m", got)
+	}
+	if got := cfg.GetTimeoutForContext("sandbox-1"); got != 2*time.Hour {
+		t.Errorf("GetTimeoutForContext(sandbox-1) = %v, want 2h", got)
+	}
+	if got := cfg.GetTimeoutForContext("staging"); got != cfg.Timeout.Default {
+		t.Errorf("GetTimeoutForContext(staging) = %v, want the default", got)
+	}
+
+	if !cfg.IsNeverSwitchFrom("admin-prod") || cfg.IsNeverSwitchFrom("prod-admin") {
+		t.Error("IsNeverSwitchFrom() should match the /^admin-/ pattern only")
+	}
+	if !cfg.IsNeverSwitchTo("prod-us") || cfg.IsNeverSwitchTo("production") {
+		t.Error("IsNeverSwitchTo() should match the prod-* pattern only")
+	}
+
+	// A default context that never_switch_to forbids by pattern is rejected
+	cfg.DefaultContext = "prod-local"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() should reject a default context matching never_switch_to")
+	}
+
+	// Patterns are checked against the kubeconfig contexts
+	cfg.DefaultContext = "local"
+	errs := cfg.CheckContexts([]string{"local", "staging", "prod-eu", "prod-us", "admin-1", "eu-production", "dev-1"})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "pattern 'unrelated-[abc]-ctx' matches no context") {
+		t.Errorf("CheckContexts() = %v, want only the unmatched pattern reported", errs)
+	}
+}
+
+func TestValidateContextPatterns(t *testing.T) {
+	base := func() *Config {
+		cfg := DefaultConfig()
+		cfg.DefaultContext = "local"
+		return cfg
+	}
+
+	cfg := base()
+	cfg.Contexts = map[string]Context{"/(prod/": {Timeout: time.Minute}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid contexts pattern") {
+		t.Errorf("Validate() = %v, want an invalid contexts pattern", err)
+	}
+
+	cfg = base()
+	cfg.Safety.NeverSwitchFrom = []string{"prod-["}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid safety.never_switch_from pattern") {
+		t.Errorf("Validate() = %v, want an invalid never_switch_from pattern", err)
+	}
+
+	// A pattern's default context must not match the pattern itself
+	cfg = base()
+	cfg.Contexts = map[string]Context{"*-us": {DefaultContext: "staging-us"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() should reject a default context matching its own pattern")
+	}
+}
+
+func TestShouldClearCache(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CacheCleanup.Contexts = []string{"production", "prod-*", "/-live$/"}
+
+	tests := []struct {
+		contextName string
+		want        bool
+	}{
+		{contextName: "production", want: true},
+		{contextName: "prod-eu", want: true},
+		{contextName: "staging", want: false},
+		{contextName: "production-old", want: false},
+		{contextName: "eu-live", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contextName, func(t *testing.T) {
+			if got := cfg.ShouldClearCache(tt.contextName); got != tt.want {
+				t.Errorf("ShouldClearCache(%s) = %v, want %v", tt.contextName, got, tt.want)
+			}
+		})
+	}
+
+	if DefaultConfig().ShouldClearCache("production") {
+		t.Error("ShouldClearCache() should be false when no contexts are configured")
+	}
+}
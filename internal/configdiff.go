@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configSecretKeys are never written to the log or shown, only reported as
+// set or changed. Webhook URLs often embed a token, as Slack's do.
+var configSecretKeys = map[string]bool{
+	"notifications.slack.token":   true,
+	"notifications.webhooks.urls": true,
+}
+
+// ConfigChange is one key that differs between two configurations
+type ConfigChange struct {
+	// Key is the dotted path of the setting, e.g. timeout.default or
+	// contexts.prod-eu.timeout
+	Key string
+	// Old and New are the values as written in YAML, or "" when the key is
+	// unset on that side
+	Old string
+	New string
+}
+
+// DiffConfigs returns the settings that differ between two configurations,
+// sorted by key. Secrets are reported as changed without their values.
+func DiffConfigs(before, after *Config) ([]ConfigChange, error) {
+	oldKeys, err := flattenConfig(before)
+	if err != nil {
+		return nil, err
+	}
+	newKeys, err := flattenConfig(after)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := slices.Collect(maps.Keys(oldKeys))
+	for key := range newKeys {
+		if _, ok := oldKeys[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var changes []ConfigChange
+	for _, key := range keys {
+		if oldKeys[key] == newKeys[key] {
+			continue
+		}
+		change := ConfigChange{Key: key, Old: oldKeys[key], New: newKeys[key]}
+		if configSecretKeys[key] {
+			change.Old, change.New = redactSecret(change.Old), redactSecret(change.New)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// redactSecret hides a secret's value, keeping whether it is set
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "REDACTED"
+}
+
+// redactConfigNode hides the values of secrets in an encoded configuration,
+// keeping whether each is set and how many entries a list has
+func redactConfigNode(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			redactConfigNode(child, path)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyPath := joinKeyPath(path, node.Content[i].Value)
+			if configSecretKeys[keyPath] {
+				redactNodeValues(node.Content[i+1])
+				continue
+			}
+			redactConfigNode(node.Content[i+1], keyPath)
+		}
+	}
+}
+
+// redactNodeValues replaces every scalar under node with redactSecret's
+func redactNodeValues(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode {
+		node.Value = redactSecret(node.Value)
+		node.Tag = "!!str"
+		return
+	}
+	for _, child := range node.Content {
+		redactNodeValues(child)
+	}
+}
+
+// flattenConfig returns each setting of a configuration by dotted path, as
+// it would be written in YAML. Lists are kept whole, as "[a, b]".
+func flattenConfig(config *Config) (map[string]string, error) {
+	var doc yaml.Node
+	if err := doc.Encode(config); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	keys := make(map[string]string)
+	flattenYAMLNode(&doc, "", keys)
+	return keys, nil
+}
+
+// flattenYAMLNode adds the scalars and lists under node to keys
+func flattenYAMLNode(node *yaml.Node, path string, keys map[string]string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			flattenYAMLNode(child, path, keys)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyPath := node.Content[i].Value
+			if path != "" {
+				keyPath = path + "." + keyPath
+			}
+			flattenYAMLNode(node.Content[i+1], keyPath, keys)
+		}
+	case yaml.SequenceNode:
+		items := make([]string, len(node.Content))
+		for i, item := range node.Content {
+			items[i] = item.Value
+		}
+		keys[path] = "[" + strings.Join(items, ", ") + "]"
+	case yaml.ScalarNode:
+		keys[path] = node.Value
+	}
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	before := DefaultConfig()
+	before.DefaultContext = "local"
+	before.Contexts = map[string]Context{"prod-eu": {Timeout: 5 * time.Minute}}
+	before.Notifications.Slack.Token = "xoxb-old"
+	before.Notifications.Webhooks.URLs = []string{"https://hooks.slack.com/services/T0/B0/old"}
+
+	after := DefaultConfig()
+	after.DefaultContext = "staging"
+	after.Timeout.Default = 45 * time.Minute
+	after.Contexts = map[string]Context{"prod-eu": {Timeout: 5 * time.Minute}, "prod-us": {Timeout: 10 * time.Minute}}
+	after.Safety.NeverSwitchTo = []string{"prod-eu", "prod-us"}
+	after.Notifications.Slack.Token = "xoxb-new"
+	after.Notifications.Webhooks.URLs = []string{"https://hooks.slack.com/services/T0/B0/new"}
+
+	changes, err := DiffConfigs(before, after)
+	if err != nil {
+		t.Fatalf("DiffConfigs() error = %v", err)
+	}
+	want := []ConfigChange{
+		{Key: "contexts.prod-us.timeout", New: "10m0s"},
+		{Key: "default_context", Old: "local", New: "staging"},
+		{Key: "notifications.slack.token", Old: "REDACTED", New: "REDACTED"},
+		{Key: "notifications.webhooks.urls", Old: "REDACTED", New: "REDACTED"},
+		{Key: "safety.never_switch_to", New: "[prod-eu, prod-us]"},
+		{Key: "timeout.default", Old: "30m0s", New: "45m0s"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("DiffConfigs() = %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+
+	if changes, _ := DiffConfigs(after, after); len(changes) != 0 {
+		t.Errorf("Expected no changes between the same configuration, got %+v", changes)
+	}
+}
+
+func TestDaemonLogsConfigChanges(t *testing.T) {
+	d := newFakeDaemon(t, &fakeSwitcher{current: "production"}, &fakeStateStore{})
+	var logs bytes.Buffer
+	d.logger = NewLogger(&logs, LogFormatText, slog.LevelInfo)
+
+	content := `
+timeout:
+  default: 20m
+  check_interval: 1s
+default_context: local
+notifications:
+  enabled: false
+safety:
+  check_active_kubectl: false
+  validate_default_context: false
+  never_switch_to: [production]
+`
+	if err := os.WriteFile(d.configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	for _, want := range []string{
+		`key=timeout.default from=10m0s to=20m0s`,
+		`key=safety.never_switch_to from="" to=[production]`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Expected %q in the log:\n%s", want, logs.String())
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseConfig decodes a configuration file over the defaults, so missing
+// fields get default values, with the preset it names applied first so the
+// file's own settings override it
+func parseConfig(data []byte) (*Config, error) {
+	config := DefaultConfig()
+
+	// A preset replaces the defaults, so the file's own settings override it
+	var preset struct {
+		Name string `yaml:"preset"`
+	}
+	if err := yaml.Unmarshal(data, &preset); err == nil {
+		if p, ok := LookupPreset(preset.Name); ok {
+			p.Apply(config)
+		}
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return config, nil
+}
+
+// ValidateConfigData checks the contents of a configuration file before
+// it is written, returning every problem found. Environment overrides are
+// left out, since they aren't part of the file.
+func ValidateConfigData(data []byte) []error {
+	config, err := parseConfig(data)
+	if err != nil {
+		return []error{err}
+	}
+	if err := config.resolveAliases(nil); err != nil {
+		return []error{err}
+	}
+	return config.ValidationErrors()
+}
+
+// ValidateConfigFileData checks the contents of the configuration file at
+// path before it is written, like ValidateConfigData but layered over the
+// files it includes
+func ValidateConfigFileData(path string, data []byte) []error {
+	path, err := expandConfigPath(path)
+	if err != nil {
+		return []error{err}
+	}
+	layered, err := layerConfigFiles(path, data, nil)
+	if err != nil {
+		return []error{err}
+	}
+	return ValidateConfigData(layered)
+}
+
+// ReplaceConfigFile validates data as the configuration file at path and,
+// if it is valid, writes it in place of the current file
+func ReplaceConfigFile(path string, data []byte) error {
+	if errs := ValidateConfigFileData(path, data); len(errs) > 0 {
+		return MarkFailure(fmt.Errorf("invalid configuration: %w", errors.Join(errs...)), ErrConfigInvalid)
+	}
+	return writeConfigFile(path, data)
+}
+
+// SetConfigValue sets the key at a dotted path, such as timeout.default or
+// contexts.prod-eu.timeout, in the configuration file at path. The file's
+// comments and layout are kept, and nothing is written unless the result
+// is valid. Lists take comma-separated values. A missing file is created
+// from the defaults.
+func SetConfigValue(path, key, value string) error {
+	path, err := expandConfigPath(path)
+	if err != nil {
+		return err
+	}
+
+	keyPath := strings.Split(key, ".")
+	fieldType, keyPath, err := configKeyType(reflect.TypeOf(Config{}), keyPath)
+	if err != nil {
+		return MarkFailure(fmt.Errorf("unknown configuration key '%s'", key), ErrConfigInvalid)
+	}
+	valueNode, err := configValueNode(fieldType, value)
+	if err != nil {
+		return MarkFailure(fmt.Errorf("invalid value for %s: %w", key, err), ErrConfigInvalid)
+	}
+
+	// #nosec G304 -- path is the user's configuration file
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		data, err = MarshalConfig(DefaultConfig())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if doc.Kind == 0 {
+		// An empty file
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("failed to parse config file: expected a mapping at the top level")
+	}
+	if err := setYAMLPath(doc.Content[0], keyPath, valueNode); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+
+	return ReplaceConfigFile(path, buf.Bytes())
+}
+
+// configKeyType returns the type of the configuration field at a key path,
+// following yaml tags. Map keys may contain dots, like server URLs under
+// clusters, so the path is returned regrouped with each map key as one
+// element.
+func configKeyType(t reflect.Type, keyPath []string) (reflect.Type, []string, error) {
+	if len(keyPath) == 0 {
+		return t, nil, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if tag == "" || tag == "-" || tag != keyPath[0] {
+				continue
+			}
+			fieldType, rest, err := configKeyType(t.Field(i).Type, keyPath[1:])
+			if err != nil {
+				return nil, nil, err
+			}
+			return fieldType, append([]string{tag}, rest...), nil
+		}
+
+	case reflect.Map:
+		// The shortest key that leaves a valid path into the value
+		for i := 1; i <= len(keyPath); i++ {
+			fieldType, rest, err := configKeyType(t.Elem(), keyPath[i:])
+			if err == nil {
+				return fieldType, append([]string{strings.Join(keyPath[:i], ".")}, rest...), nil
+			}
+		}
+	}
+
+	return nil, nil, fmt.Errorf("no such key")
+}
+
+// configValueNode parses a value given on the command line into a YAML node
+// for a field of the given type
+func configValueNode(t reflect.Type, value string) (*yaml.Node, error) {
+	switch {
+	case t == durationType:
+		if _, err := time.ParseDuration(value); err != nil {
+			return nil, err
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+
+	case t.Kind() == reflect.String:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+
+	case t.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(b)}, nil
+
+	case t.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(n)}, nil
+
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range splitEnvList(value) {
+			list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+		}
+		return list, nil
+	}
+
+	return nil, fmt.Errorf("can't be set from the command line; use config edit")
+}
+
+// setYAMLPath sets the value at a key path in a mapping node, creating the
+// mappings along the way. A replaced value keeps its line comment.
+func setYAMLPath(node *yaml.Node, keyPath []string, value *yaml.Node) error {
+	for i, key := range keyPath {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping", strings.Join(keyPath[:i], "."))
+		}
+
+		last := i == len(keyPath)-1
+		child := yamlMappingValue(node, key)
+		if child == nil {
+			child = value
+			if !last {
+				child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+		} else if last {
+			value.LineComment = child.LineComment
+			*child = *value
+		} else if child.Kind == yaml.ScalarNode && child.Tag == "!!null" {
+			// An empty section, like "contexts:" with nothing under it
+			*child = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		node = child
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetConfigValue(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `# My settings
+timeout:
+  default: 30m # Long enough for a deploy
+default_context: local
+contexts:
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	sets := [][2]string{
+		{"timeout.default", "45m"},
+		{"contexts.prod-eu.timeout", "5m"},
+		{"clusters.https://*.prod.example.com.timeout", "10m"},
+		{"safety.never_switch_to", "prod-eu, prod-us"},
+		{"notifications.enabled", "false"},
+	}
+	for _, set := range sets {
+		if err := SetConfigValue(configPath, set[0], set[1]); err != nil {
+			t.Fatalf("SetConfigValue(%q, %q) error = %v", set[0], set[1], err)
+		}
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	for _, want := range []string{"# My settings", "default: 45m # Long enough for a deploy"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %q kept in the file:\n%s", want, data)
+		}
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.Timeout.Default != 45*time.Minute || config.Contexts["prod-eu"].Timeout != 5*time.Minute {
+		t.Errorf("Expected the timeouts set, got %v and %+v", config.Timeout.Default, config.Contexts)
+	}
+	if config.Clusters["https://*.prod.example.com"].Timeout != 10*time.Minute {
+		t.Errorf("Expected a clusters key containing dots, got %+v", config.Clusters)
+	}
+	if strings.Join(config.Safety.NeverSwitchTo, ",") != "prod-eu,prod-us" || config.Notifications.Enabled {
+		t.Errorf("Expected the list and bool set, got %v and %v", config.Safety.NeverSwitchTo, config.Notifications.Enabled)
+	}
+
+	// Nothing is written unless the key, value, and result are valid
+	for _, set := range [][2]string{
+		{"timeout.defualt", "1m"},
+		{"timeout.default", "soon"},
+		{"timeout.default", "-5m"},
+		{"contexts.prod-eu", "5m"},
+	} {
+		if err := SetConfigValue(configPath, set[0], set[1]); err == nil {
+			t.Errorf("SetConfigValue(%q, %q) should fail", set[0], set[1])
+		}
+	}
+	if after, _ := os.ReadFile(configPath); string(after) != string(data) {
+		t.Errorf("Expected the file unchanged after failed sets:\n%s", after)
+	}
+}
+
+func TestReplaceConfigFile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ReplaceConfigFile(configPath, []byte("timeout:\n  default: 0s\n")); err == nil {
+		t.Error("Expected an invalid configuration to be refused")
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing written, got %v", err)
+	}
+
+	if err := ReplaceConfigFile(configPath, []byte("default_context: local\n")); err != nil {
+		t.Fatalf("ReplaceConfigFile() error = %v", err)
+	}
+	if config, err := LoadConfig(configPath); err != nil || config.DefaultContext != "local" {
+		t.Errorf("LoadConfig() = %+v, %v", config, err)
+	}
+}
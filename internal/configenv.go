@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigEnvPrefix prefixes the environment variables that override
+// configuration keys
+const ConfigEnvPrefix = "KUBECTX_TIMEOUT_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ConfigEnvVar returns the environment variable that overrides the
+// configuration key at the given YAML path. Keys in the timeout section
+// drop the section name, so timeout.default is KUBECTX_TIMEOUT_DEFAULT.
+func ConfigEnvVar(keyPath ...string) string {
+	if len(keyPath) > 1 && keyPath[0] == "timeout" {
+		keyPath = keyPath[1:]
+	}
+	return ConfigEnvPrefix + strings.ToUpper(strings.Join(keyPath, "_"))
+}
+
+// applyEnvOverrides layers environment variables over the configuration,
+// one per key, such as KUBECTX_TIMEOUT_DAEMON_LOG_LEVEL for
+// daemon.log_level. Lists are comma-separated, and contexts takes
+// name=duration pairs. Empty variables are ignored. It returns how many
+// keys were overridden, recording each in sources if that isn't nil.
+func applyEnvOverrides(config *Config, sources ConfigSources) (int, error) {
+	return applyEnvOverridesTo(reflect.ValueOf(config).Elem(), nil, sources)
+}
+
+func applyEnvOverridesTo(v reflect.Value, keyPath []string, sources ConfigSources) (int, error) {
+	overrides := 0
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" || (keyPath == nil && tag == includeKey) {
+			// Includes are resolved while the file is read
+			continue
+		}
+		field := v.Field(i)
+		fieldPath := append(keyPath[:len(keyPath):len(keyPath)], tag)
+
+		if field.Kind() == reflect.Struct {
+			n, err := applyEnvOverridesTo(field, fieldPath, sources)
+			if err != nil {
+				return 0, err
+			}
+			overrides += n
+			continue
+		}
+
+		name := ConfigEnvVar(fieldPath...)
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if err := setFromEnv(field, value); err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		if sources != nil {
+			sources[strings.Join(fieldPath, ".")] = "$" + name
+		}
+		overrides++
+	}
+
+	return overrides, nil
+}
+
+// setFromEnv parses an environment variable's value into a config field
+func setFromEnv(field reflect.Value, value string) error {
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		field.Set(reflect.ValueOf(splitEnvList(value)))
+
+	case field.Type() == reflect.TypeOf(map[string]Context{}):
+		// Per-context timeouts as name=duration pairs, keeping other
+		// settings of contexts already configured
+		contexts := make(map[string]Context, field.Len())
+		for _, key := range field.MapKeys() {
+			contexts[key.String()] = field.MapIndex(key).Interface().(Context)
+		}
+		for _, entry := range splitEnvList(value) {
+			name, timeout, ok := strings.Cut(entry, "=")
+			if !ok {
+				return fmt.Errorf("expected name=duration, got %q", entry)
+			}
+			d, err := time.ParseDuration(timeout)
+			if err != nil {
+				return err
+			}
+			ctx := contexts[name]
+			ctx.Timeout = d
+			contexts[name] = ctx
+		}
+		field.Set(reflect.ValueOf(contexts))
+
+	case field.Type() == reflect.TypeOf(map[string]time.Duration{}):
+		// Timeouts as name=duration pairs, added to those already configured
+		timeouts := make(map[string]time.Duration, field.Len())
+		for _, key := range field.MapKeys() {
+			timeouts[key.String()] = time.Duration(field.MapIndex(key).Int())
+		}
+		for _, entry := range splitEnvList(value) {
+			name, timeout, ok := strings.Cut(entry, "=")
+			if !ok {
+				return fmt.Errorf("expected name=duration, got %q", entry)
+			}
+			d, err := time.ParseDuration(timeout)
+			if err != nil {
+				return err
+			}
+			timeouts[name] = d
+		}
+		field.Set(reflect.ValueOf(timeouts))
+
+	case field.Type() == reflect.TypeOf(map[string]string{}):
+		// Aliases as alias=context pairs, added to those already configured
+		aliases := make(map[string]string, field.Len())
+		for _, key := range field.MapKeys() {
+			aliases[key.String()] = field.MapIndex(key).String()
+		}
+		for _, entry := range splitEnvList(value) {
+			alias, context, ok := strings.Cut(entry, "=")
+			if !ok {
+				return fmt.Errorf("expected alias=context, got %q", entry)
+			}
+			aliases[alias] = context
+		}
+		field.Set(reflect.ValueOf(aliases))
+
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+// splitEnvList splits a comma-separated list, dropping blank entries
+func splitEnvList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConfigEnvVar(t *testing.T) {
+	tests := []struct {
+		keyPath []string
+		want    string
+	}{
+		{[]string{"timeout", "default"}, "KUBECTX_TIMEOUT_DEFAULT"},
+		{[]string{"timeout", "check_interval"}, "KUBECTX_TIMEOUT_CHECK_INTERVAL"},
+		{[]string{"default_context"}, "KUBECTX_TIMEOUT_DEFAULT_CONTEXT"},
+		{[]string{"daemon", "log_level"}, "KUBECTX_TIMEOUT_DAEMON_LOG_LEVEL"},
+		{[]string{"cache_cleanup", "http_cache"}, "KUBECTX_TIMEOUT_CACHE_CLEANUP_HTTP_CACHE"},
+	}
+
+	for _, tt := range tests {
+		if got := ConfigEnvVar(tt.keyPath...); got != tt.want {
+			t.Errorf("ConfigEnvVar(%v) = %s, want %s", tt.keyPath, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `default_context: local
+timeout:
+  default: 30m
+contexts:
+  prod:
+    timeout: 5m
+    confirm_switch: true
+daemon:
+  log_level: info
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	t.Setenv("KUBECTX_TIMEOUT_DEFAULT", "45m")
+	t.Setenv("KUBECTX_TIMEOUT_DEFAULT_CONTEXT", "minikube")
+	t.Setenv("KUBECTX_TIMEOUT_CHECK_INTERVAL", "10s")
+	t.Setenv("KUBECTX_TIMEOUT_DAEMON_LOG_LEVEL", "debug")
+	t.Setenv("KUBECTX_TIMEOUT_DAEMON_LOG_MAX_SIZE", "20")
+	t.Setenv("KUBECTX_TIMEOUT_NOTIFICATIONS_ENABLED", "false")
+	t.Setenv("KUBECTX_TIMEOUT_SAFETY_NEVER_SWITCH_FROM", "prod, prod-eu,")
+	t.Setenv("KUBECTX_TIMEOUT_CONTEXTS", "prod=10m,staging=15m")
+	t.Setenv("KUBECTX_TIMEOUT_SCHEDULE_AFTER_HOURS_CONTEXTS", "prod=5m")
+	t.Setenv("KUBECTX_TIMEOUT_STATE_FILE", "")
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if cfg.Timeout.Default != 45*time.Minute || cfg.Timeout.CheckInterval != 10*time.Second {
+		t.Errorf("Timeout = %+v, want 45m and 10s", cfg.Timeout)
+	}
+	if cfg.DefaultContext != "minikube" {
+		t.Errorf("DefaultContext = %s, want minikube", cfg.DefaultContext)
+	}
+	if cfg.Daemon.LogLevel != "debug" || cfg.Daemon.LogMaxSize != 20 {
+		t.Errorf("Daemon = %+v, want debug and 20", cfg.Daemon)
+	}
+	if cfg.Notifications.Enabled {
+		t.Error("Notifications.Enabled should be overridden to false")
+	}
+	if !slices.Equal(cfg.Safety.NeverSwitchFrom, []string{"prod", "prod-eu"}) {
+		t.Errorf("NeverSwitchFrom = %v, want [prod prod-eu]", cfg.Safety.NeverSwitchFrom)
+	}
+	// Overriding a context's timeout keeps its other settings
+	if prod := cfg.Contexts["prod"]; prod.Timeout != 10*time.Minute || !prod.ConfirmSwitch {
+		t.Errorf("Contexts[prod] = %+v, want 10m with confirm_switch", prod)
+	}
+	if cfg.Contexts["staging"].Timeout != 15*time.Minute {
+		t.Errorf("Contexts[staging] = %+v, want 15m", cfg.Contexts["staging"])
+	}
+	if cfg.Schedule.AfterHours.Contexts["prod"] != 5*time.Minute {
+		t.Errorf("Schedule.AfterHours.Contexts = %v, want prod=5m", cfg.Schedule.AfterHours.Contexts)
+	}
+	// Empty variables don't override
+	if cfg.StateFile != "state.json" {
+		t.Errorf("StateFile = %s, want the default", cfg.StateFile)
+	}
+}
+
+func TestLoadConfigEnvOverridesInvalid(t *testing.T) {
+	missingPath := filepath.Join(t.TempDir(), "missing.yaml")
+
+	t.Setenv("KUBECTX_TIMEOUT_DEFAULT", "soon")
+	if _, err := LoadConfig(missingPath); err == nil {
+		t.Error("Expected an error for an unparseable duration")
+	}
+
+	// Overrides are validated even without a config file
+	t.Setenv("KUBECTX_TIMEOUT_DEFAULT", "30m")
+	t.Setenv("KUBECTX_TIMEOUT_DEFAULT_CONTEXT", "local")
+	t.Setenv("KUBECTX_TIMEOUT_DAEMON_LOG_LEVEL", "loud")
+	if _, err := LoadConfig(missingPath); err == nil {
+		t.Error("Expected an error for an invalid log level")
+	}
+
+	t.Setenv("KUBECTX_TIMEOUT_DAEMON_LOG_LEVEL", "")
+	t.Setenv("KUBECTX_TIMEOUT_CONTEXTS", "prod")
+	if _, err := LoadConfig(missingPath); err == nil {
+		t.Error("Expected an error for a context without a timeout")
+	}
+}
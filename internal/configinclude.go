@@ -0,0 +1,331 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includeKey is the configuration key listing the files a configuration
+// file includes
+const includeKey = "include"
+
+// ConfigSources maps the dotted key path of each configuration value that
+// doesn't come from the defaults, such as timeout.default, to where it was
+// set: a configuration file, or an environment variable as $NAME
+type ConfigSources map[string]string
+
+// Source returns where the value at a key path was set, or "" if it has its
+// default. A value inside a map or section set as a whole, such as
+// contexts from the environment, comes from where that was set.
+func (s ConfigSources) Source(keyPath string) string {
+	for {
+		if source, ok := s[keyPath]; ok {
+			return source
+		}
+		i := strings.LastIndex(keyPath, ".")
+		if i < 0 {
+			return ""
+		}
+		keyPath = keyPath[:i]
+	}
+}
+
+// set records the source of every value in node, at keyPath, replacing
+// those of the values it takes the place of
+func (s ConfigSources) set(keyPath string, node *yaml.Node, source string) {
+	if s == nil {
+		return
+	}
+	for key := range s {
+		if key == keyPath || strings.HasPrefix(key, keyPath+".") {
+			delete(s, key)
+		}
+	}
+	s.record(keyPath, node, source)
+}
+
+// record records the source of every value in node, at keyPath
+func (s ConfigSources) record(keyPath string, node *yaml.Node, source string) {
+	if s == nil {
+		return
+	}
+	if node.Kind != yaml.MappingNode || len(node.Content) == 0 {
+		s[keyPath] = source
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		s.record(joinKeyPath(keyPath, node.Content[i].Value), node.Content[i+1], source)
+	}
+}
+
+// joinKeyPath appends key to a dotted key path
+func joinKeyPath(keyPath, key string) string {
+	if keyPath == "" {
+		return key
+	}
+	return keyPath + "." + key
+}
+
+// ConfigIncludes returns the files the configuration file at path includes,
+// in the order they are layered. It returns nil if the file doesn't exist
+// or includes nothing.
+func ConfigIncludes(path string) ([]string, error) {
+	path, err := expandConfigPath(path)
+	if err != nil {
+		return nil, err
+	}
+	// #nosec G304 -- path is the user's configuration file
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	root, err := configMapping(data)
+	if err != nil || root == nil {
+		return nil, err
+	}
+	patterns, err := includePatterns(root)
+	if err != nil {
+		return nil, err
+	}
+	return resolveIncludes(path, patterns)
+}
+
+// layerConfigFiles returns the contents of the configuration file at path
+// layered over the files it includes. The included files are applied in the
+// order listed, with the files a pattern matches in lexical order, and the
+// file itself last, so that each overrides the ones before it. Mappings are
+// merged key by key, and anything else, lists included, replaces what came
+// before. If sources isn't nil, the file each value came from is recorded
+// in it.
+func layerConfigFiles(path string, data []byte, sources ConfigSources) ([]byte, error) {
+	root, err := configMapping(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if root == nil {
+		return data, nil
+	}
+
+	patterns, err := includePatterns(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		sources.record("", root, path)
+		return data, nil
+	}
+	files, err := resolveIncludes(path, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, file := range files {
+		// #nosec G304 -- file is included by the user's configuration file
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read included config: %w", err)
+		}
+		layer, err := configMapping(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse included config %s: %w", file, err)
+		}
+		if layer == nil {
+			continue
+		}
+		if mappingValue(layer, includeKey) != nil {
+			return nil, fmt.Errorf("included config %s: include is only allowed in the main configuration file", file)
+		}
+		mergeConfigNode(merged, layer, "", file, sources)
+	}
+	mergeConfigNode(merged, root, "", path, sources)
+
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge included config: %w", err)
+	}
+	return out, nil
+}
+
+// configMapping parses a configuration file's top-level mapping. It returns
+// nil for an empty file.
+func configMapping(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected a mapping at the top level")
+	}
+	return doc.Content[0], nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// includePatterns returns the paths and globs a configuration file
+// includes: a list, or a single one
+func includePatterns(root *yaml.Node) ([]string, error) {
+	value := mappingValue(root, includeKey)
+	if value == nil {
+		return nil, nil
+	}
+
+	var patterns []string
+	switch value.Kind {
+	case yaml.ScalarNode:
+		if value.Tag != "!!null" {
+			patterns = []string{value.Value}
+		}
+	case yaml.SequenceNode:
+		if err := value.Decode(&patterns); err != nil {
+			return nil, fmt.Errorf("include must be a list of paths: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("include must be a list of paths")
+	}
+	return patterns, nil
+}
+
+// resolveIncludes returns the files matching the include patterns of the
+// configuration file at path, once each and never the file itself.
+// Relative patterns are relative to the file's directory. A pattern
+// without wildcards must name a file that exists; one with them may match
+// nothing, so an empty conf.d is fine.
+func resolveIncludes(path string, patterns []string) ([]string, error) {
+	self, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config path: %w", err)
+	}
+
+	var files []string
+	for _, pattern := range patterns {
+		expanded, err := expandConfigPath(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !filepath.IsAbs(expanded) {
+			expanded = filepath.Join(filepath.Dir(self), expanded)
+		}
+
+		matches, err := filepath.Glob(expanded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("included config %s not found", expanded)
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if match != self && !slices.Contains(files, match) {
+				files = append(files, match)
+			}
+		}
+	}
+	return files, nil
+}
+
+// mergeConfigNode layers the mapping src over dst, recording where each
+// value it sets came from
+func mergeConfigNode(dst, src *yaml.Node, keyPath, source string, sources ConfigSources) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		valuePath := joinKeyPath(keyPath, key.Value)
+
+		existing := mappingValue(dst, key.Value)
+		switch {
+		case existing != nil && existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeConfigNode(existing, value, valuePath, source, sources)
+			continue
+		case existing != nil:
+			*existing = *value
+		default:
+			dst.Content = append(dst.Content, key, value)
+		}
+		sources.set(valuePath, value, source)
+	}
+}
+
+// MarshalConfigSources encodes a configuration as YAML, noting after each
+// value that doesn't come from the defaults where it was set. Secrets, such
+// as the Slack token and webhook URLs, are redacted.
+func MarshalConfigSources(config *Config, sources ConfigSources) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(config); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	redactConfigNode(&doc, "")
+	annotateConfigSources(&doc, "", sources)
+
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	return []byte(buf.String()), nil
+}
+
+// annotateConfigSources attaches the source of each value as a comment
+func annotateConfigSources(node *yaml.Node, keyPath string, sources ConfigSources) {
+	if node.Kind != yaml.MappingNode {
+		for _, child := range node.Content {
+			annotateConfigSources(child, keyPath, sources)
+		}
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		valuePath := joinKeyPath(keyPath, key.Value)
+		if value.Kind == yaml.MappingNode && len(value.Content) > 0 {
+			annotateConfigSources(value, valuePath, sources)
+			continue
+		}
+		source := sources.Source(valuePath)
+		if source == "" {
+			continue
+		}
+		if value.Kind == yaml.ScalarNode {
+			value.LineComment = "from " + displayConfigSource(source)
+		} else {
+			key.LineComment = "from " + displayConfigSource(source)
+		}
+	}
+}
+
+// displayConfigSource shortens a source in the home directory to ~/...
+func displayConfigSource(source string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return source
+	}
+	if rel, err := filepath.Rel(home, source); err == nil && filepath.IsAbs(source) && !strings.HasPrefix(rel, "..") {
+		return filepath.Join("~", rel)
+	}
+	return source
+}
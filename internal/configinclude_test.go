@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFiles writes files, keyed by path relative to dir
+func writeConfigFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoadConfigIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{
+		"conf.d/10-org.yaml": `timeout:
+  default: 20m
+  check_interval: 10s
+default_context: org-sandbox
+contexts:
+  prod:
+    timeout: 5m
+    revoke_credentials: true
+safety:
+  never_switch_to: [prod-*, live-*]
+`,
+		"conf.d/20-team.yaml": `default_context: team-sandbox
+contexts:
+  staging:
+    timeout: 30m
+`,
+		"conf.d/notes.txt": "not: [config",
+		"config.yaml": `include:
+  - conf.d/*.yaml
+timeout:
+  default: 45m
+contexts:
+  prod:
+    timeout: 3m
+safety:
+  never_switch_to: [prod-eu]
+`,
+	})
+	configPath := filepath.Join(dir, "config.yaml")
+
+	config, sources, err := ReadConfigSources(configPath)
+	if err != nil {
+		t.Fatalf("ReadConfigSources() error = %v", err)
+	}
+
+	// The file itself overrides what it includes, and later includes
+	// override earlier ones
+	if config.Timeout.Default != 45*time.Minute || config.Timeout.CheckInterval != 10*time.Second {
+		t.Errorf("Timeout = %+v, want default 45m from the file and check_interval 10s from the org", config.Timeout)
+	}
+	if config.DefaultContext != "team-sandbox" {
+		t.Errorf("DefaultContext = %q, want team-sandbox", config.DefaultContext)
+	}
+
+	// Mappings are merged key by key
+	if prod := config.Contexts["prod"]; prod.Timeout != 3*time.Minute || !prod.RevokeCredentials {
+		t.Errorf("contexts.prod = %+v, want the file's timeout and the org's revoke_credentials", prod)
+	}
+	if _, ok := config.Contexts["staging"]; !ok {
+		t.Error("Expected contexts.staging from the team's file")
+	}
+
+	// Lists are replaced
+	if !slices.Equal(config.Safety.NeverSwitchTo, []string{"prod-eu"}) {
+		t.Errorf("NeverSwitchTo = %v, want [prod-eu]", config.Safety.NeverSwitchTo)
+	}
+
+	org := filepath.Join(dir, "conf.d", "10-org.yaml")
+	team := filepath.Join(dir, "conf.d", "20-team.yaml")
+	for key, want := range map[string]string{
+		"timeout.default":                  configPath,
+		"timeout.check_interval":           org,
+		"default_context":                  team,
+		"contexts.prod.timeout":            configPath,
+		"contexts.prod.revoke_credentials": org,
+		"safety.never_switch_to":           configPath,
+		"daemon.log_level":                 "",
+	} {
+		if got := sources.Source(key); got != want {
+			t.Errorf("Source(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	includes, err := ConfigIncludes(configPath)
+	if err != nil || !slices.Equal(includes, []string{org, team}) {
+		t.Errorf("ConfigIncludes() = %v, %v, want [%s %s]", includes, err, org, team)
+	}
+
+	// config show notes each value's source
+	data, err := MarshalConfigSources(config, sources)
+	if err != nil {
+		t.Fatalf("MarshalConfigSources() error = %v", err)
+	}
+	if !strings.Contains(string(data), "check_interval: 10s # from "+org) {
+		t.Errorf("MarshalConfigSources() = %s, want check_interval noted as from %s", data, org)
+	}
+}
+
+func TestMarshalConfigSourcesRedactsSecrets(t *testing.T) {
+	config := DefaultConfig()
+	config.Notifications.Slack.Token = "xoxb-secret"
+	config.Notifications.Webhooks.URLs = []string{"https://hooks.slack.com/services/T0/B0/secret", "https://example.com/hook?token=secret"}
+
+	data, err := MarshalConfigSources(config, ConfigSources{})
+	if err != nil {
+		t.Fatalf("MarshalConfigSources() error = %v", err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("MarshalConfigSources() = %s, want secrets redacted", data)
+	}
+	if strings.Count(string(data), "REDACTED") != 3 {
+		t.Errorf("MarshalConfigSources() = %s, want the token and both URLs redacted", data)
+	}
+	if config.Notifications.Slack.Token != "xoxb-secret" {
+		t.Error("MarshalConfigSources() should leave the configuration alone")
+	}
+}
+
+func TestLoadConfigIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing file",
+			files:   map[string]string{"config.yaml": "include: [base.yaml]\n"},
+			wantErr: "not found",
+		},
+		{
+			name: "nested include",
+			files: map[string]string{
+				"config.yaml": "include: base.yaml\n",
+				"base.yaml":   "include: [other.yaml]\n",
+			},
+			wantErr: "only allowed in the main configuration file",
+		},
+		{
+			name: "invalid included file",
+			files: map[string]string{
+				"config.yaml": "include: [base.yaml]\n",
+				"base.yaml":   "timeout: [unclosed\n",
+			},
+			wantErr: "failed to parse included config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeConfigFiles(t, dir, tt.files)
+			_, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadConfig() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A glob may match nothing, such as an empty conf.d
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{"config.yaml": "include: [conf.d/*.yaml]\ndefault_context: local\n"})
+	if _, err := LoadConfig(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Errorf("LoadConfig() with an empty include glob error = %v", err)
+	}
+}
+
+func TestValidateConfigFileData(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFiles(t, dir, map[string]string{"base.yaml": "default_context: local\n"})
+	configPath := filepath.Join(dir, "config.yaml")
+
+	// The default context comes from the included file
+	data := []byte("include: [base.yaml]\ntimeout:\n  default: 15m\n")
+	if errs := ValidateConfigFileData(configPath, data); len(errs) > 0 {
+		t.Errorf("ValidateConfigFileData() = %v, want no problems", errs)
+	}
+	if errs := ValidateConfigData(data); len(errs) == 0 {
+		t.Error("ValidateConfigData() without the included file = no problems, want default_context required")
+	}
+}
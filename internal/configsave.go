@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileHeader starts every file SaveConfig writes
+const configFileHeader = "kubectx-timeout configuration"
+
+// configHeadComments are written above keys, by dotted path
+var configHeadComments = map[string]string{
+	"include":                   "Files layered beneath this one, in order; settings here override them",
+	"aliases":                   "Short names for contexts, usable anywhere a context is named",
+	"contexts":                  "Context-specific timeouts; a context may also set default_context to\nswitch somewhere other than the global default",
+	"clusters":                  "Settings like contexts, keyed by cluster API server URL, for contexts\nno contexts entry matches",
+	"profiles":                  "Named sets of timeouts, chosen with: kubectx-timeout profile use NAME",
+	"kubeconfigs":               "Kubeconfig files the daemon times out alongside the default, each\nwith its own settings",
+	"daemon":                    "Daemon behavior",
+	"notifications":             "How you're told about switches",
+	"safety":                    "Safety checks before switching",
+	"notifications.quiet_hours": "Times notifications are only logged; switches still happen",
+	"safety.never_switch_to":    "Contexts a timeout never switches into",
+	"shell":                     "Shell integration, installed with install-shell",
+	"shell.wrap_commands":       "Commands install-shell wraps to record activity",
+}
+
+// configLineComments are written after values, by dotted path
+var configLineComments = map[string]string{
+	"preset":                      "paranoid, standard, or relaxed; settings below override it",
+	"timeout.default":             "Default timeout for all contexts",
+	"timeout.check_interval":      "How often to retry while a switch is deferred or failing",
+	"timeout.write_commands":      "Timeout after apply, delete, and other writes, if longer",
+	"timeout.on_wake":             "After sleep: evaluate, reset, or switch",
+	"timeout.reset_namespace":     "Namespace set on contexts switched away from",
+	"timeout.undo_window":         "How long undo can switch back after a switch, 0 for never",
+	"default_context":             "Context to switch to after timeout",
+	"daemon.log_format":           "text, json, or console",
+	"daemon.log_file":             "Relative to the state directory; empty logs to stdout",
+	"daemon.log_max_size":         "MB before rotation, 0 to never rotate",
+	"daemon.log_max_backups":      "Rotated files kept",
+	"notifications.method":        "terminal, desktop, or both",
+	"notifications.desktop":       "macos, linux, or windows; empty for this platform's",
+	"notifications.respect_focus": "Only log notifications while a macOS Focus is on",
+	"safety.max_defer":            "Longest running tools defer a switch, 0 for no limit",
+	"switcher.max_retries":        "Attempts per switch, 0 for 3",
+	"switcher.retry_delay":        "Wait before the first retry, 0 for 1s",
+	"switcher.backoff":            "fixed or exponential",
+	"switcher.max_retry_delay":    "Longest exponential wait, 0 for 30s",
+	"switcher.jitter":             "Randomize each wait between half and all of it",
+	"tracking.metadata":           "Command details in the history: off, verb, or verb+resource",
+	"history.max_entries":         "Most recent events kept in the history, 0 for no limit",
+	"history.max_age":             "How long history is kept, 0 for no limit",
+	"state.encrypt":               "Encrypt the state file and history at rest",
+	"state.key_file":              "Passphrase file for the key; empty uses the macOS Keychain",
+	"state_file":                  "Relative to the state directory",
+	"shell.remaining_time":        "Print [context: 7m left] after commands below this, 0 never",
+	"shell.profile_backups":       "Backups kept of each shell profile, 0 for 10",
+	"kube_client":                 "kubectl, or native to edit kubeconfig files directly",
+	"kubectl_timeout":             "How long each kubectl command may run, 0 for 10s",
+}
+
+// MarshalConfig encodes a configuration as YAML, with comments explaining
+// the main settings
+func MarshalConfig(config *Config) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(config); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	commentConfigNode(&doc, "")
+	doc.HeadComment = configFileHeader
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// commentConfigNode attaches comments to the keys of a mapping node and
+// the mappings within it. Keys under contexts and clusters are context
+// names and server URLs, which get no comments.
+func commentConfigNode(node *yaml.Node, path string) {
+	if node.Kind != yaml.MappingNode {
+		for _, child := range node.Content {
+			commentConfigNode(child, path)
+		}
+		return
+	}
+	if path == "contexts" || path == "clusters" {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		keyPath := key.Value
+		if path != "" {
+			keyPath = path + "." + key.Value
+		}
+		if comment, ok := configHeadComments[keyPath]; ok {
+			key.HeadComment = comment
+		}
+		if comment, ok := configLineComments[keyPath]; ok {
+			value.LineComment = comment
+		}
+		commentConfigNode(value, keyPath)
+	}
+}
+
+// SaveConfig writes a configuration file that LoadConfig reads back as the
+// same configuration. The file is replaced atomically and readable only by
+// the user, since it may hold a Slack token.
+func SaveConfig(path string, config *Config) error {
+	path, err := expandConfigPath(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := MarshalConfig(config)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(path, data)
+}
+
+// writeConfigFile replaces the configuration file at path with data
+func writeConfigFile(path string, data []byte) error {
+	path, err := expandConfigPath(path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	// Write to a temporary file in the same directory, then rename, so
+	// the daemon's config watcher never sees a partially written file
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath) // Don't leave a partial write behind
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename config file: %w", err)
+	}
+
+	return nil
+}
+
+// expandConfigPath expands a leading ~ to the home directory
+func expandConfigPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveConfigRoundTrip(t *testing.T) {
+	config := DefaultConfig()
+	config.DefaultContext = "minikube"
+	config.Timeout.Default = 45 * time.Minute
+	config.Timeout.GracePeriod = time.Minute
+	config.Contexts = map[string]Context{
+		"arn:aws:eks:us-east-1:123:cluster/prod": {Timeout: 5 * time.Minute, DefaultContext: "staging"},
+		"staging":                                {Timeout: 15 * time.Minute},
+	}
+	config.Safety.NeverSwitchTo = []string{"arn:aws:eks:us-east-1:123:cluster/prod"}
+	config.Schedule.AfterHours.Default = 10 * time.Minute
+	config.Hooks.PostSwitch = []string{"echo 'switched: $KUBECTX_TIMEOUT_TO'"}
+
+	path := filepath.Join(t.TempDir(), "nested", "config.yaml")
+	if err := SaveConfig(path, config); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("config file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, config) {
+		t.Errorf("loaded config differs:\ngot  %+v\nwant %+v", loaded, config)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	for _, want := range []string{
+		"# kubectx-timeout configuration",
+		"default: 45m0s # Default timeout for all contexts",
+		"# Contexts a timeout never switches into",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config file missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestSaveConfigReplacesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("default_context: old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.DefaultContext = "new"
+	if err := SaveConfig(path, config); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if loaded.DefaultContext != "new" {
+		t.Errorf("DefaultContext = %q, want new", loaded.DefaultContext)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the config file, found %d entries", len(entries))
+	}
+}
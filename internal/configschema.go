@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ConfigSchemaID identifies the schema ConfigSchema returns
+const ConfigSchemaID = "https://github.com/mrf/kubectx-timeout/config.schema.json"
+
+// durationPattern matches a Go duration such as 30s, 1h30m, or 0
+const durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// clockRangePattern matches a HH:MM-HH:MM range
+const clockRangePattern = `^([01][0-9]|2[0-3]):[0-5][0-9]-([01][0-9]|2[0-3]):[0-5][0-9]$`
+
+// configSchemaEnums are the values allowed for keys that take one of a few,
+// by dotted path. For a list, they are the values allowed for its items.
+var configSchemaEnums = map[string][]string{
+	"preset":                         presetNameList(),
+	"timeout.on_wake":                {OnWakeEvaluate, OnWakeReset, OnWakeSwitch},
+	"daemon.log_level":               {"debug", "info", "warn", "error"},
+	"daemon.log_format":              {LogFormatText, LogFormatJSON, LogFormatConsole},
+	"notifications.method":           {NotificationMethodTerminal, NotificationMethodDesktop, NotificationMethodBoth, NotificationMethodMacOS},
+	"notifications.desktop":          DesktopBackends,
+	"notifications.quiet_hours.days": allWeekdays,
+	"switcher.backoff":               {BackoffFixed, BackoffExponential},
+	"tracking.metadata":              {MetadataOff, MetadataVerb, MetadataVerbResource},
+	"schedule.work_days":             allWeekdays,
+	"shell.shells":                   SupportedShells,
+	"kube_client":                    {KubeClientKubectl, KubeClientNative},
+}
+
+// configSchemaPatterns are regular expressions string values must match,
+// by dotted path
+var configSchemaPatterns = map[string]string{
+	"timeout.reset_namespace":         namespacePattern.String(),
+	"notifications.quiet_hours.hours": clockRangePattern,
+	"schedule.work_hours":             clockRangePattern,
+}
+
+// jsonSchema is the subset of JSON Schema that describes the configuration
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	PropertyNames        *jsonSchema            `json:"propertyNames,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+}
+
+// ConfigSchema returns a JSON Schema for the configuration file, derived
+// from Config, for editors to validate and complete config.yaml with.
+// Durations are Go duration strings, keys that take one of a few values
+// list them, and sections reject keys they don't have, so typos show up.
+func ConfigSchema() ([]byte, error) {
+	schema := schemaForType(reflect.TypeOf(Config{}), "")
+	schema.Schema = "https://json-schema.org/draft/2020-12/schema"
+	schema.ID = ConfigSchemaID
+	schema.Title = configFileHeader
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaForType describes a configuration value of type t at the dotted
+// keyPath
+func schemaForType(t reflect.Type, keyPath string) *jsonSchema {
+	schema := &jsonSchema{Description: schemaDescription(keyPath)}
+
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		schema.Type = "string"
+		schema.Pattern = durationPattern
+		return schema
+	case t.Kind() == reflect.Struct:
+		schema.Type = "object"
+		schema.Properties = make(map[string]*jsonSchema)
+		schema.AdditionalProperties = false
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			schema.Properties[name] = schemaForType(field.Type, joinKeyPath(keyPath, name))
+		}
+		return schema
+	case t.Kind() == reflect.Map:
+		// Keys are context names, patterns, server URLs, or profile names
+		schema.Type = "object"
+		schema.AdditionalProperties = schemaForType(t.Elem(), keyPath+".*")
+		switch keyPath {
+		case "profiles":
+			schema.PropertyNames = &jsonSchema{Pattern: profileNamePattern.String()}
+		case "aliases":
+			schema.PropertyNames = &jsonSchema{Pattern: aliasNamePattern.String()}
+		}
+		return schema
+	case t.Kind() == reflect.Slice:
+		schema.Type = "array"
+		schema.Items = schemaForType(t.Elem(), keyPath+"[]")
+		schema.Items.Description = ""
+		schema.Items.Enum = configSchemaEnums[keyPath]
+		if keyPath == includeKey {
+			// include takes a single path as well as a list
+			list := &jsonSchema{Type: schema.Type, Items: schema.Items}
+			return &jsonSchema{Description: schema.Description, AnyOf: []*jsonSchema{{Type: "string"}, list}}
+		}
+		return schema
+	case t.Kind() == reflect.Bool:
+		schema.Type = "boolean"
+	case t.Kind() == reflect.Int:
+		schema.Type = "integer"
+		schema.Minimum = new(int)
+	case t.Kind() == reflect.String:
+		schema.Type = "string"
+		schema.Enum = configSchemaEnums[keyPath]
+		schema.Pattern = configSchemaPatterns[keyPath]
+	}
+	return schema
+}
+
+// schemaDescription returns the comment config files get for a key, if any
+func schemaDescription(keyPath string) string {
+	if comment, ok := configLineComments[keyPath]; ok {
+		return comment
+	}
+	return strings.ReplaceAll(configHeadComments[keyPath], "\n", " ")
+}
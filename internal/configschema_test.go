@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkSchema reports where a YAML node doesn't match the schema, for the
+// parts of JSON Schema that ConfigSchema uses
+func checkSchema(schema *jsonSchema, node *yaml.Node, keyPath string) []string {
+	if len(schema.AnyOf) > 0 {
+		for _, option := range schema.AnyOf {
+			if len(checkSchema(option, node, keyPath)) == 0 {
+				return nil
+			}
+		}
+		return []string{keyPath + ": matches none of its forms"}
+	}
+
+	var problems []string
+	switch schema.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			return []string{keyPath + ": want a mapping"}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			child, ok := schema.Properties[key]
+			if !ok {
+				child, ok = schema.AdditionalProperties.(*jsonSchema)
+			}
+			if !ok {
+				problems = append(problems, joinKeyPath(keyPath, key)+": unknown key")
+				continue
+			}
+			problems = append(problems, checkSchema(child, value, joinKeyPath(keyPath, key))...)
+		}
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			return []string{keyPath + ": want a list"}
+		}
+		for _, item := range node.Content {
+			problems = append(problems, checkSchema(schema.Items, item, keyPath+"[]")...)
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			return []string{keyPath + ": want a " + schema.Type}
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, node.Value) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not one of %v", keyPath, node.Value, schema.Enum))
+		}
+		if schema.Pattern != "" && !regexp.MustCompile(schema.Pattern).MatchString(node.Value) {
+			problems = append(problems, fmt.Sprintf("%s: %q doesn't match %s", keyPath, node.Value, schema.Pattern))
+		}
+	}
+	return problems
+}
+
+func TestConfigSchema(t *testing.T) {
+	data, err := ConfigSchema()
+	if err != nil {
+		t.Fatalf("ConfigSchema() error = %v", err)
+	}
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("ConfigSchema() is not valid JSON: %v", err)
+	}
+	// Decoded, additionalProperties is false or a map; use the Go schema
+	// for checking
+	root := schemaForType(reflect.TypeOf(Config{}), "")
+
+	if got := root.Properties["daemon"].Properties["log_level"].Enum; !slices.Equal(got, []string{"debug", "info", "warn", "error"}) {
+		t.Errorf("daemon.log_level enum = %v", got)
+	}
+	if got := root.Properties["notifications"].Properties["method"].Enum; !slices.Contains(got, NotificationMethodDesktop) {
+		t.Errorf("notifications.method enum = %v, want it to include desktop", got)
+	}
+	if got := root.Properties["timeout"].Properties["default"].Pattern; got != durationPattern {
+		t.Errorf("timeout.default pattern = %q, want the duration pattern", got)
+	}
+
+	durations := regexp.MustCompile(durationPattern)
+	for _, d := range []string{"30s", "1h30m", "0", "1.5h", "250ms"} {
+		if !durations.MatchString(d) {
+			t.Errorf("Expected duration pattern to match %q", d)
+		}
+	}
+	for _, d := range []string{"30", "1 hour", "5x", ""} {
+		if durations.MatchString(d) {
+			t.Errorf("Expected duration pattern not to match %q", d)
+		}
+	}
+
+	// The configuration files the project ships, and one SaveConfig
+	// writes, must match the schema
+	saved, err := MarshalConfig(DefaultConfig())
+	if err != nil {
+		t.Fatalf("MarshalConfig() error = %v", err)
+	}
+	files := map[string][]byte{"MarshalConfig": saved}
+	for _, name := range []string{"config.example.yaml", "config.minimal.yaml"} {
+		data, err := os.ReadFile("../examples/" + name)
+		if err != nil {
+			t.Fatalf("Failed to read example: %v", err)
+		}
+		files[name] = data
+	}
+	for name, data := range files {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			t.Fatalf("%s: failed to parse: %v", name, err)
+		}
+		for _, problem := range checkSchema(root, doc.Content[0], "") {
+			t.Errorf("%s: %s", name, problem)
+		}
+	}
+
+	// A typo or a bad value doesn't
+	var typo yaml.Node
+	if err := yaml.Unmarshal([]byte("timeout:\n  defualt: 30m\ndaemon:\n  log_level: verbose\n"), &typo); err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if problems := checkSchema(root, typo.Content[0], ""); len(problems) != 2 {
+		t.Errorf("checkSchema() of a config with a typo and a bad value = %v, want 2 problems", problems)
+	}
+}
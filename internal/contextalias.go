@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// aliasNamePattern matches a valid alias, which is typed on the command line
+// and must not look like a context pattern
+var aliasNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ResolveContext returns the context an alias stands for, or the name
+// itself if it isn't an alias
+func (c *Config) ResolveContext(name string) string {
+	if target, ok := c.Aliases[name]; ok {
+		return target
+	}
+	return name
+}
+
+// ContextAlias returns the alias of a context, the first in alphabetical
+// order if it has several, or "" if it has none
+func (c *Config) ContextAlias(contextName string) string {
+	for _, alias := range slices.Sorted(maps.Keys(c.Aliases)) {
+		if c.Aliases[alias] == contextName {
+			return alias
+		}
+	}
+	return ""
+}
+
+// resolveAliases replaces aliases in the settings that name contexts with
+// the contexts they stand for, so the rest of the program only sees real
+// context names. Patterns are left alone. Keys that were renamed keep
+// their sources. It returns an error if an alias and the context it stands
+// for are both keys of the same map.
+func (c *Config) resolveAliases(sources ConfigSources) error {
+	if len(c.Aliases) == 0 {
+		return nil
+	}
+
+	c.DefaultContext = c.ResolveContext(c.DefaultContext)
+	for _, list := range []*[]string{&c.Safety.NeverSwitchFrom, &c.Safety.NeverSwitchTo, &c.CacheCleanup.Contexts} {
+		*list = c.resolveContextList(*list)
+	}
+
+	contexts, err := resolveContextKeys(c, c.Contexts, "contexts", sources)
+	if err != nil {
+		return err
+	}
+	c.Contexts = c.resolveDefaultContexts(contexts)
+	c.Clusters = c.resolveDefaultContexts(c.Clusters)
+
+	if c.Schedule.AfterHours.Contexts, err = resolveContextKeys(c, c.Schedule.AfterHours.Contexts, "schedule.after_hours.contexts", sources); err != nil {
+		return err
+	}
+
+	if len(c.Profiles) > 0 {
+		profiles := make(map[string]Profile, len(c.Profiles))
+		for name, profile := range c.Profiles {
+			profile.DefaultContext = c.ResolveContext(profile.DefaultContext)
+			contexts, err := resolveContextKeys(c, profile.Contexts, joinKeyPath("profiles", name)+".contexts", sources)
+			if err != nil {
+				return err
+			}
+			profile.Contexts = c.resolveDefaultContexts(contexts)
+			profiles[name] = profile
+		}
+		c.Profiles = profiles
+	}
+
+	if len(c.Kubeconfigs) > 0 {
+		kubeconfigs := make([]KubeconfigPolicy, len(c.Kubeconfigs))
+		for i, policy := range c.Kubeconfigs {
+			policy.DefaultContext = c.ResolveContext(policy.DefaultContext)
+			contexts, err := resolveContextKeys(c, policy.Contexts, fmt.Sprintf("kubeconfigs[%d].contexts", i), sources)
+			if err != nil {
+				return err
+			}
+			policy.Contexts = c.resolveDefaultContexts(contexts)
+			kubeconfigs[i] = policy
+		}
+		c.Kubeconfigs = kubeconfigs
+	}
+
+	return nil
+}
+
+// resolveContextList returns a list of context names and patterns with
+// aliases replaced
+func (c *Config) resolveContextList(names []string) []string {
+	if names == nil {
+		return nil
+	}
+	resolved := make([]string, len(names))
+	for i, name := range names {
+		resolved[i] = c.ResolveContext(name)
+	}
+	return resolved
+}
+
+// resolveDefaultContexts returns per-context settings with aliases in their
+// default contexts replaced
+func (c *Config) resolveDefaultContexts(contexts map[string]Context) map[string]Context {
+	if contexts == nil {
+		return nil
+	}
+	resolved := make(map[string]Context, len(contexts))
+	for name, ctx := range contexts {
+		ctx.DefaultContext = c.ResolveContext(ctx.DefaultContext)
+		resolved[name] = ctx
+	}
+	return resolved
+}
+
+// resolveContextKeys returns a map keyed by context name with aliases
+// replaced, moving the sources of renamed keys under keyPath along
+func resolveContextKeys[V any](c *Config, m map[string]V, keyPath string, sources ConfigSources) (map[string]V, error) {
+	if m == nil {
+		return nil, nil
+	}
+	resolved := make(map[string]V, len(m))
+	for _, name := range slices.Sorted(maps.Keys(m)) {
+		target := c.ResolveContext(name)
+		if _, ok := m[target]; ok && target != name {
+			return nil, fmt.Errorf("%s: '%s' is an alias of '%s', which is also listed", keyPath, name, target)
+		}
+		resolved[target] = m[name]
+		if target != name {
+			sources.rename(joinKeyPath(keyPath, name), joinKeyPath(keyPath, target))
+		}
+	}
+	return resolved, nil
+}
+
+// rename moves the sources recorded at a key path, and beneath it, to
+// another
+func (s ConfigSources) rename(from, to string) {
+	moved := make(map[string]string)
+	for key, source := range s {
+		if key == from || strings.HasPrefix(key, from+".") {
+			delete(s, key)
+			moved[to+strings.TrimPrefix(key, from)] = source
+		}
+	}
+	maps.Copy(s, moved)
+}
+
+// aliasValidationErrors checks the aliases
+func (c *Config) aliasValidationErrors() []error {
+	var errs []error
+
+	for _, alias := range slices.Sorted(maps.Keys(c.Aliases)) {
+		target := c.Aliases[alias]
+		switch {
+		case !aliasNamePattern.MatchString(alias):
+			errs = append(errs, fmt.Errorf("invalid alias '%s': use letters, digits, '.', '_', and '-'", alias))
+		case target == "":
+			errs = append(errs, fmt.Errorf("aliases.%s must name a context", alias))
+		case IsContextPattern(target):
+			errs = append(errs, fmt.Errorf("aliases.%s must name a context, not a pattern", alias))
+		case target == alias:
+			errs = append(errs, fmt.Errorf("aliases.%s must name a different context", alias))
+		default:
+			if _, ok := c.Aliases[target]; ok {
+				errs = append(errs, fmt.Errorf("aliases.%s names another alias, '%s'; name the context instead", alias, target))
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigAliases(t *testing.T) {
+	t.Setenv(ConfigEnvVar("aliases"), "")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `aliases:
+  p: gke_acme_us-central1_prod
+  s: gke_acme_us-central1_staging
+  d: docker-desktop
+timeout:
+  default: 30m
+  check_interval: 30s
+default_context: d
+contexts:
+  p:
+    timeout: 5m
+    default_context: s
+  dev-*:
+    timeout: 1h
+safety:
+  never_switch_to:
+    - p
+    - prod-*
+profiles:
+  oncall:
+    contexts:
+      p:
+        timeout: 1h
+schedule:
+  after_hours:
+    contexts:
+      p: 2m
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, sources, err := ReadConfigSources(path)
+	if err != nil {
+		t.Fatalf("ReadConfigSources() error = %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	const prod = "gke_acme_us-central1_prod"
+	if config.DefaultContext != "docker-desktop" {
+		t.Errorf("DefaultContext = %q, want docker-desktop", config.DefaultContext)
+	}
+	if got := config.GetTimeoutForContext(prod); got != 5*time.Minute {
+		t.Errorf("GetTimeoutForContext(%s) = %v, want 5m", prod, got)
+	}
+	if got := config.GetDefaultContextFor(prod); got != "gke_acme_us-central1_staging" {
+		t.Errorf("GetDefaultContextFor(%s) = %q, want the staging context", prod, got)
+	}
+	if want := []string{prod, "prod-*"}; !slices.Equal(config.Safety.NeverSwitchTo, want) {
+		t.Errorf("NeverSwitchTo = %v, want %v", config.Safety.NeverSwitchTo, want)
+	}
+	if _, ok := config.Schedule.AfterHours.Contexts[prod]; !ok {
+		t.Errorf("AfterHours.Contexts = %v, want an entry for %s", config.Schedule.AfterHours.Contexts, prod)
+	}
+	oncall, err := config.WithProfile("oncall")
+	if err != nil {
+		t.Fatalf("WithProfile() error = %v", err)
+	}
+	if got := oncall.GetTimeoutForContext(prod); got != time.Hour {
+		t.Errorf("GetTimeoutForContext(%s) with profile = %v, want 1h", prod, got)
+	}
+
+	// Renamed keys keep their sources
+	if got := sources.Source("contexts." + prod + ".timeout"); got != path {
+		t.Errorf("Source(contexts.%s.timeout) = %q, want %q", prod, got, path)
+	}
+
+	if got := config.ResolveContext("p"); got != prod {
+		t.Errorf("ResolveContext(p) = %q, want %q", got, prod)
+	}
+	if got := config.ResolveContext("dev"); got != "dev" {
+		t.Errorf("ResolveContext(dev) = %q, want dev", got)
+	}
+	if got := config.ContextAlias(prod); got != "p" {
+		t.Errorf("ContextAlias(%s) = %q, want p", prod, got)
+	}
+	if got := config.ContextAlias("dev"); got != "" {
+		t.Errorf("ContextAlias(dev) = %q, want none", got)
+	}
+
+	// Aliases from the environment apply too
+	t.Setenv(ConfigEnvVar("aliases"), "dev=dev-cluster-1")
+	t.Setenv(ConfigEnvVar("default_context"), "dev")
+	config, err = ReadConfig(path)
+	if err != nil {
+		t.Fatalf("ReadConfig() error = %v", err)
+	}
+	if config.DefaultContext != "dev-cluster-1" {
+		t.Errorf("DefaultContext with env alias = %q, want dev-cluster-1", config.DefaultContext)
+	}
+}
+
+func TestLoadConfigAliasConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `aliases:
+  p: prod
+default_context: dev
+contexts:
+  p:
+    timeout: 5m
+  prod:
+    timeout: 10m
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, err := ReadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "'p' is an alias of 'prod', which is also listed") {
+		t.Fatalf("ReadConfig() error = %v, want the alias conflict", err)
+	}
+}
+
+func TestAliasValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases map[string]string
+		wantErr string
+	}{
+		{name: "valid", aliases: map[string]string{"p": "prod", "s": "staging"}},
+		{name: "invalid name", aliases: map[string]string{"p*": "prod"}, wantErr: "invalid alias 'p*'"},
+		{name: "empty target", aliases: map[string]string{"p": ""}, wantErr: "aliases.p must name a context"},
+		{name: "pattern target", aliases: map[string]string{"p": "prod-*"}, wantErr: "not a pattern"},
+		{name: "itself", aliases: map[string]string{"p": "p"}, wantErr: "must name a different context"},
+		{name: "chained", aliases: map[string]string{"p": "q", "q": "prod"}, wantErr: "names another alias, 'q'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.DefaultContext = "dev"
+			config.Aliases = tt.aliases
+
+			errs := config.ValidationErrors()
+			if tt.wantErr == "" {
+				if len(errs) > 0 {
+					t.Fatalf("ValidationErrors() = %v, want none", errs)
+				}
+				return
+			}
+			if !slices.ContainsFunc(errs, func(err error) bool { return strings.Contains(err.Error(), tt.wantErr) }) {
+				t.Errorf("ValidationErrors() = %v, want one containing %q", errs, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// contextRegexps caches compiled regex patterns, which are matched on every
+// timeout check
+var contextRegexps sync.Map
+
+// IsContextPattern reports whether a context rule is a pattern rather than
+// an exact context name: a regular expression between slashes, such as
+// /.*-production$/, or a glob using *, ?, or [...], such as prod-*
+func IsContextPattern(pattern string) bool {
+	_, isRegexp := contextRegexpSource(pattern)
+	return isRegexp || strings.ContainsAny(pattern, `*?[\`)
+}
+
+// contextRegexpSource returns the expression of a /regex/ pattern
+func contextRegexpSource(pattern string) (string, bool) {
+	if len(pattern) < 2 || !strings.HasPrefix(pattern, "/") || !strings.HasSuffix(pattern, "/") {
+		return "", false
+	}
+	return pattern[1 : len(pattern)-1], true
+}
+
+// compileContextRegexp compiles a regex pattern once and caches it
+func compileContextRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := contextRegexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	contextRegexps.Store(expr, re)
+	return re, nil
+}
+
+// ValidateContextPattern returns an error if a context pattern is malformed
+func ValidateContextPattern(pattern string) error {
+	if expr, ok := contextRegexpSource(pattern); ok {
+		if _, err := compileContextRegexp(expr); err != nil {
+			return fmt.Errorf("invalid regular expression: %w", err)
+		}
+		return nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MatchContextPattern reports whether a context name matches a rule, which
+// may be an exact name, a glob, or a /regex/. Regular expressions are not
+// anchored, so use ^ and $ to match whole names. Malformed patterns match
+// nothing.
+func MatchContextPattern(pattern, contextName string) bool {
+	if expr, ok := contextRegexpSource(pattern); ok {
+		re, err := compileContextRegexp(expr)
+		return err == nil && re.MatchString(contextName)
+	}
+	matched, err := path.Match(pattern, contextName)
+	return err == nil && matched
+}
+
+// MatchAnyContextPattern reports whether a context name matches any of the
+// rules
+func MatchAnyContextPattern(patterns []string, contextName string) bool {
+	for _, pattern := range patterns {
+		if MatchContextPattern(pattern, contextName) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package internal
+
+import "testing"
+
+func TestMatchContextPattern(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		contextName string
+		want        bool
+	}{
+		{"production", "production", true},
+		{"production", "production-eu", false},
+		{"prod-*", "prod-eu", true},
+		{"prod-*", "production", false},
+		{"prod-?", "prod-1", true},
+		{"/.*-production$/", "eu-production", true},
+		{"/.*-production$/", "eu-production-old", false},
+		{"/prod/", "my-prod-cluster", true},
+		{"/^prod$/", "production", false},
+		{"/[/", "[", false},
+		{"/", "/", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchContextPattern(tt.pattern, tt.contextName); got != tt.want {
+			t.Errorf("MatchContextPattern(%q, %q) = %v, want %v", tt.pattern, tt.contextName, got, tt.want)
+		}
+	}
+}
+
+func TestIsContextPattern(t *testing.T) {
+	tests := map[string]bool{
+		"production":            false,
+		"arn:aws:eks:cluster/x": false,
+		"prod-*":                true,
+		"prod-[12]":             true,
+		"/-production$/":        true,
+		"/":                     false,
+	}
+
+	for pattern, want := range tests {
+		if got := IsContextPattern(pattern); got != want {
+			t.Errorf("IsContextPattern(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
+
+func TestValidateContextPattern(t *testing.T) {
+	for _, pattern := range []string{"production", "prod-*", "/.*-production$/"} {
+		if err := ValidateContextPattern(pattern); err != nil {
+			t.Errorf("ValidateContextPattern(%q) error = %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"prod-[", "/(prod/"} {
+		if err := ValidateContextPattern(pattern); err == nil {
+			t.Errorf("ValidateContextPattern(%q) should fail", pattern)
+		}
+	}
+}
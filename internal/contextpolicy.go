@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ContextPolicy is the effective timeout policy of one context, as the
+// contexts command lists it
+type ContextPolicy struct {
+	Context string `json:"context"`
+	Current bool   `json:"current"`
+
+	// Alias is the context's alias, if it has one
+	Alias string `json:"alias,omitempty"`
+
+	// Timeout is how long the context may sit idle, at the time the policy
+	// was listed. It is zero for contexts a timeout never switches away
+	// from: the default target itself, and never_switch_from contexts.
+	Timeout time.Duration `json:"-"`
+
+	// SwitchesTo is the context a timeout switches to, or "" if none
+	SwitchesTo string `json:"switches_to"`
+
+	// DefaultTarget is set for contexts some timeout switches to
+	DefaultTarget   bool `json:"default_target"`
+	NeverSwitchFrom bool `json:"never_switch_from"`
+	NeverSwitchTo   bool `json:"never_switch_to"`
+
+	// Missing is set for contexts the configuration names that aren't in
+	// kubeconfig
+	Missing bool `json:"missing"`
+}
+
+// MarshalJSON encodes the timeout as seconds
+func (p ContextPolicy) MarshalJSON() ([]byte, error) {
+	type policy ContextPolicy
+	return json.Marshal(struct {
+		policy
+		TimeoutSeconds int64 `json:"timeout_seconds"`
+	}{policy(p), int64(p.Timeout / time.Second)})
+}
+
+// ListContextPolicies returns the effective policy of every kubeconfig
+// context, and of contexts the configuration names exactly that kubeconfig
+// lacks, sorted by name
+func ListContextPolicies(config *Config, contexts []string, current string, now time.Time) []ContextPolicy {
+	names := slices.Clone(contexts)
+	named := []string{config.DefaultContext}
+	named = append(named, slices.Collect(maps.Keys(config.Contexts))...)
+	named = append(named, config.Safety.NeverSwitchFrom...)
+	named = append(named, config.Safety.NeverSwitchTo...)
+	for _, name := range named {
+		if name != "" && name != ConfigureMePlaceholder && !IsContextPattern(name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	targets := map[string]bool{}
+	for _, name := range names {
+		targets[config.GetDefaultContextFor(name)] = true
+	}
+
+	policies := make([]ContextPolicy, 0, len(names))
+	for _, name := range names {
+		policy := ContextPolicy{
+			Context:         name,
+			Current:         name == current,
+			Alias:           config.ContextAlias(name),
+			DefaultTarget:   targets[name],
+			NeverSwitchFrom: config.IsNeverSwitchFrom(name),
+			NeverSwitchTo:   config.IsNeverSwitchTo(name),
+			Missing:         !slices.Contains(contexts, name),
+		}
+		if target := config.GetDefaultContextFor(name); target != name && !policy.NeverSwitchFrom {
+			policy.SwitchesTo = target
+			policy.Timeout = config.GetTimeoutForContextAt(name, now)
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
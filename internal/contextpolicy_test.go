@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListContextPolicies(t *testing.T) {
+	config := DefaultConfig()
+	config.DefaultContext = "local"
+	config.Contexts = map[string]Context{
+		"prod-*":  {Timeout: 5 * time.Minute},
+		"prod-us": {Timeout: 10 * time.Minute, DefaultContext: "staging"},
+		"retired": {Timeout: time.Minute},
+	}
+	config.Safety.NeverSwitchFrom = []string{"ops"}
+	config.Safety.NeverSwitchTo = []string{"prod-*"}
+
+	policies := ListContextPolicies(config, []string{"prod-us", "local", "prod-eu", "ops", "staging"}, "prod-eu", time.Now())
+	byName := map[string]ContextPolicy{}
+	var names []string
+	for _, p := range policies {
+		byName[p.Context] = p
+		names = append(names, p.Context)
+	}
+	if got := strings.Join(names, " "); got != "local ops prod-eu prod-us retired staging" {
+		t.Fatalf("Expected every context sorted, with configured ones kubeconfig lacks, got %s", got)
+	}
+
+	tests := []struct {
+		name string
+		want ContextPolicy
+	}{
+		{"local", ContextPolicy{Context: "local", DefaultTarget: true}},
+		{"ops", ContextPolicy{Context: "ops", NeverSwitchFrom: true}},
+		{"prod-eu", ContextPolicy{Context: "prod-eu", Current: true, Timeout: 5 * time.Minute, SwitchesTo: "local", NeverSwitchTo: true}},
+		{"prod-us", ContextPolicy{Context: "prod-us", Timeout: 10 * time.Minute, SwitchesTo: "staging", NeverSwitchTo: true}},
+		{"retired", ContextPolicy{Context: "retired", Timeout: time.Minute, SwitchesTo: "local", Missing: true}},
+		{"staging", ContextPolicy{Context: "staging", Timeout: 30 * time.Minute, SwitchesTo: "local", DefaultTarget: true}},
+	}
+	for _, tt := range tests {
+		if got := byName[tt.name]; got != tt.want {
+			t.Errorf("policy of %s = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	data, err := json.Marshal(byName["prod-eu"])
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"timeout_seconds":300`) {
+		t.Errorf("Expected the timeout in seconds, got %s", data)
+	}
+}
@@ -0,0 +1,534 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// controlSocketFileName is the control socket's name within the state directory
+const controlSocketFileName = "daemon.sock"
+
+// controlTimeout bounds a single control request, from connecting to reading
+// the response. Requests wait for an in-flight check, which may be switching.
+const controlTimeout = 15 * time.Second
+
+// maxControlRequestSize limits how much the daemon reads from a client
+const maxControlRequestSize = 64 * 1024
+
+// Control commands
+const (
+	// ControlStatus returns the daemon's status summary
+	ControlStatus = "status"
+	// ControlPause suppresses timeout switching for Duration, for Context
+	// only if set (like pause-context) or for every context (like extend)
+	ControlPause = "pause"
+	// ControlResume ends a pause early, of Context if set or the extension
+	// otherwise
+	ControlResume = "resume"
+	// ControlReload reloads the configuration file
+	ControlReload = "reload"
+	// ControlForceSwitch switches to the default context right away
+	ControlForceSwitch = "force-switch"
+	// ControlSwitchBack switches back to Context, which the last switch left, or
+	// undoes the last automatic switch if Context is empty (like undo)
+	ControlSwitchBack = "switch-back"
+	// ControlProfile applies Profile for Duration, or until cleared if
+	// Duration is empty; an empty Profile clears it
+	ControlProfile = "profile"
+	// ControlCancelSwitch cancels the switch waiting out the grace period
+	// and resets the timer of the context it would have switched away from
+	ControlCancelSwitch = "cancel-switch"
+	// ControlReset resets the activity timer of Context, or of the current
+	// context if Context is empty
+	ControlReset = "reset"
+)
+
+// SwitchNowReason is the notification reason for switches requested with
+// the switch-now command
+const SwitchNowReason = "requested with switch-now"
+
+// SwitchBackReason is the history reason for switching back after a switch
+const SwitchBackReason = "switched back"
+
+// ErrControlUnavailable is returned when the daemon's control socket can't
+// be reached, typically because the daemon isn't running. Callers can fall
+// back to updating the state file directly.
+var ErrControlUnavailable = errors.New("daemon control socket unavailable")
+
+// ControlRequest is a command sent to the daemon over the control socket.
+// Each connection carries one request and one response, both encoded as a
+// single line of JSON.
+type ControlRequest struct {
+	Command  string `json:"command"`
+	Context  string `json:"context,omitempty"`
+	Duration string `json:"duration,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+}
+
+// ControlResponse is the daemon's reply to a ControlRequest
+type ControlResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+
+	// Failure names the failure type of Error, such as switch_refused, so
+	// the client can exit with its code
+	Failure string `json:"failure,omitempty"`
+
+	// Status is set for status requests, and for every other successful
+	// request to reflect its effect
+	Status *StatusSummary `json:"status,omitempty"`
+
+	// Until is when a pause ends, for pause requests, and when a profile
+	// ends, for profile requests with a duration
+	Until *time.Time `json:"until,omitempty"`
+
+	// Changed reports whether a resume ended a pause, a force-switch or
+	// switch-back switched, or a cancel-switch canceled a switch, as any of
+	// them may have nothing to do
+	Changed bool `json:"changed,omitempty"`
+
+	// FromContext and ToContext describe a force-switch, switch-back, or
+	// canceled switch; reset sets FromContext to the context it reset
+	FromContext string `json:"from_context,omitempty"`
+	ToContext   string `json:"to_context,omitempty"`
+}
+
+// GetControlSocketPath returns the path to the daemon's control socket
+func GetControlSocketPath() string {
+	return ControlSocketPathForState(GetStatePath())
+}
+
+// ControlSocketPathForState returns the control socket path of a daemon
+// using the given state file, which is kept alongside it
+func ControlSocketPathForState(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), controlSocketFileName)
+}
+
+// SendControlRequest sends a request to the daemon listening at socketPath
+// and returns its response. It returns an error wrapping
+// ErrControlUnavailable if no daemon is listening, and an error with the
+// daemon's message if the daemon rejected the request.
+func SendControlRequest(socketPath string, req ControlRequest) (*ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", socketPath, controlTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrControlUnavailable, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(controlTimeout)); err != nil {
+		return nil, fmt.Errorf("failed to set control socket deadline: %w", err)
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send control request: %w", err)
+	}
+
+	var resp ControlResponse
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read control response: %w", err)
+	}
+
+	if !resp.OK {
+		err := fmt.Errorf("daemon rejected %s request: %s", req.Command, resp.Error)
+		if kind, ok := failureTypes[resp.Failure]; ok {
+			err = MarkFailure(err, kind)
+		}
+		return &resp, err
+	}
+
+	return &resp, nil
+}
+
+// listenControl starts accepting control requests on the control socket.
+// Any existing socket file is stale, since the caller holds the PID file.
+func (d *Daemon) listenControl() error {
+	if d.controlPath == "" {
+		return nil
+	}
+
+	if err := os.Remove(d.controlPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale control socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", d.controlPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+
+	// Only the user may connect; the state directory is private too. On
+	// Windows the socket inherits the state directory's ACL instead.
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(d.controlPath, 0600); err != nil {
+			_ = listener.Close()
+			return fmt.Errorf("failed to set control socket permissions: %w", err)
+		}
+	}
+
+	d.controlMu.Lock()
+	d.controlListener = listener
+	d.controlMu.Unlock()
+
+	go d.serveControl(listener)
+	return nil
+}
+
+// closeControl stops accepting control requests and removes the socket.
+// It is safe to call more than once.
+func (d *Daemon) closeControl() {
+	d.controlMu.Lock()
+	listener := d.controlListener
+	d.controlListener = nil
+	d.controlMu.Unlock()
+
+	if listener == nil {
+		return
+	}
+
+	// Closing a unix listener created by Listen also removes the socket file
+	if err := listener.Close(); err != nil {
+		d.logger.Warn("Failed to close control socket", "error", err)
+	}
+}
+
+// serveControl handles connections until the listener is closed
+func (d *Daemon) serveControl(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				d.logger.Warn("Control socket stopped accepting requests", "error", err)
+			}
+			return
+		}
+		go d.handleControlConn(conn)
+	}
+}
+
+// handleControlConn reads one request from conn and writes the response
+func (d *Daemon) handleControlConn(conn net.Conn) {
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(controlTimeout)); err != nil {
+		return
+	}
+
+	var resp ControlResponse
+	var req ControlRequest
+	if err := json.NewDecoder(io.LimitReader(conn, maxControlRequestSize)).Decode(&req); err != nil {
+		resp = ControlResponse{Error: fmt.Sprintf("invalid request: %v", err)}
+	} else {
+		resp = d.handleControlRequest(req)
+	}
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		d.logger.Warn("Failed to send control response", "error", err)
+	}
+}
+
+// handleControlRequest carries out a control request. Requests that change
+// state run under checkMu, so they never interleave with a timeout check.
+func (d *Daemon) handleControlRequest(req ControlRequest) ControlResponse {
+	if req.Command == ControlReload {
+		// Reloading swaps the config under configMu and needs no check lock
+		if err := d.reload(); err != nil {
+			return ControlResponse{Error: err.Error(), Failure: failureType(err)}
+		}
+		d.logger.Info("Configuration reloaded via control socket")
+		return ControlResponse{OK: true, Status: d.buildStatusSummary(time.Now())}
+	}
+
+	d.checkMu.Lock()
+	defer d.checkMu.Unlock()
+
+	if d.ctx.Err() != nil {
+		return ControlResponse{Error: "daemon is shutting down"}
+	}
+
+	var resp ControlResponse
+	var err error
+	switch req.Command {
+	case ControlStatus:
+		resp.OK = true
+	case ControlPause:
+		resp, err = d.controlPause(req)
+	case ControlResume:
+		resp, err = d.controlResume(req)
+	case ControlForceSwitch:
+		resp, err = d.controlForceSwitch()
+	case ControlSwitchBack:
+		resp, err = d.controlSwitchBack(req)
+	case ControlProfile:
+		resp, err = d.controlProfile(req)
+	case ControlCancelSwitch:
+		resp, err = d.controlCancelSwitch()
+	case ControlReset:
+		resp, err = d.controlReset(req)
+	default:
+		err = fmt.Errorf("unknown command %q", req.Command)
+	}
+	if err != nil {
+		return ControlResponse{Error: err.Error(), Failure: failureType(err)}
+	}
+
+	if req.Command != ControlStatus {
+		d.publishStatusSummary(false)
+	}
+	resp.Status = d.buildStatusSummary(time.Now())
+	return resp
+}
+
+// controlPause pauses one context, or extends the deadline of all of them
+func (d *Daemon) controlPause(req ControlRequest) (ControlResponse, error) {
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil {
+		return ControlResponse{}, fmt.Errorf("invalid duration %q: %w", req.Duration, err)
+	}
+	if duration <= 0 {
+		return ControlResponse{}, fmt.Errorf("duration must be positive")
+	}
+
+	var until time.Time
+	if req.Context != "" {
+		until, err = PauseContext(d.stateManager, req.Context, duration)
+		if err == nil {
+			d.logger.Info("Context paused", "context", req.Context, "until", until.Format(time.RFC3339))
+		}
+	} else {
+		until, err = ExtendDeadline(d.stateManager, duration)
+		if err == nil {
+			d.logger.Info("Timeout switching suppressed", "until", until.Format(time.RFC3339))
+		}
+	}
+	if err != nil {
+		return ControlResponse{}, err
+	}
+
+	return ControlResponse{OK: true, Until: &until}, nil
+}
+
+// controlResume ends a context's pause, or the deadline extension
+func (d *Daemon) controlResume(req ControlRequest) (ControlResponse, error) {
+	var changed bool
+	var err error
+	if req.Context != "" {
+		changed, err = ResumeContext(d.stateManager, req.Context)
+		if err == nil && changed {
+			d.logger.Info("Context resumed", "context", req.Context)
+		}
+	} else {
+		changed, err = ClearExtension(d.stateManager)
+		if err == nil && changed {
+			d.logger.Info("Deadline extension cleared")
+		}
+	}
+	if err != nil {
+		return ControlResponse{}, err
+	}
+
+	return ControlResponse{OK: true, Changed: changed}, nil
+}
+
+// controlCancelSwitch cancels the pending switch, resetting the timer of
+// the context it would have switched away from
+func (d *Daemon) controlCancelSwitch() (ControlResponse, error) {
+	pending, err := CancelPendingSwitch(d.stateManager)
+	if err != nil {
+		return ControlResponse{}, fmt.Errorf("failed to cancel switch: %w", err)
+	}
+	if pending.IsZero() {
+		return ControlResponse{OK: true}, nil
+	}
+
+	d.logger.Info("Pending switch canceled", "context", pending.From, "to", pending.To)
+	d.recordHistory(HistoryEvent{Type: HistoryActivity, Context: pending.From, Reason: "switch canceled"})
+	d.requestCheck()
+	return ControlResponse{OK: true, Changed: true, FromContext: pending.From, ToContext: pending.To}, nil
+}
+
+// controlReset resets the activity timer as if kubectl had just been used
+// in the context
+func (d *Daemon) controlReset(req ControlRequest) (ControlResponse, error) {
+	context := req.Context
+	if context == "" {
+		current, err := d.switcher.CurrentContext()
+		if err != nil {
+			return ControlResponse{}, fmt.Errorf("%w: %w", errContextUnavailable, err)
+		}
+		context = current
+	}
+
+	// Activity still buffered from before the reset mustn't be written over it
+	d.flushActivityLocked()
+	if err := RecordActivity(d.stateManager, context); err != nil {
+		return ControlResponse{}, fmt.Errorf("failed to reset activity timer: %w", err)
+	}
+
+	d.logger.Info("Activity timer reset", "context", context)
+	d.recordHistory(HistoryEvent{Type: HistoryActivity, Context: context, Reason: "timer reset"})
+	d.requestCheck()
+	return ControlResponse{OK: true, FromContext: context}, nil
+}
+
+// controlForceSwitch switches to the default context without waiting for the
+// timeout. The user asked for it, so exemptions and pauses don't apply, but
+// never_switch_to and never_switch_to_clusters are still enforced.
+func (d *Daemon) controlForceSwitch() (ControlResponse, error) {
+	config := d.currentConfig()
+
+	currentContext, err := d.switcher.CurrentContext()
+	if err != nil {
+		return ControlResponse{}, fmt.Errorf("%w: %w", errContextUnavailable, err)
+	}
+
+	defaultContext := config.GetDefaultContextFor(currentContext)
+	resp := ControlResponse{OK: true, FromContext: currentContext, ToContext: defaultContext}
+	if currentContext == defaultContext {
+		return resp, nil
+	}
+
+	if err := d.switchContext(config, currentContext, defaultContext, SwitchNowReason); err != nil {
+		return ControlResponse{}, fmt.Errorf("%w: %w", errSwitchFailed, err)
+	}
+	if err := ClearPendingSwitch(d.stateManager); err != nil {
+		d.logger.Warn("Failed to clear pending switch", "error", err)
+	}
+
+	d.notifySwitch(SwitchEvent{
+		FromContext: currentContext,
+		ToContext:   defaultContext,
+		Reason:      SwitchNowReason,
+	})
+
+	resp.Changed = true
+	return resp, nil
+}
+
+// controlSwitchBack switches back to the context the last switch left,
+// resetting its timer, or to the one the last automatic switch left while it
+// can still be undone. As with switch-now, exemptions and pauses don't apply,
+// but never_switch_to and never_switch_to_clusters are still enforced.
+func (d *Daemon) controlSwitchBack(req ControlRequest) (ControlResponse, error) {
+	config := d.currentConfig()
+
+	state, err := d.stateManager.Load()
+	if err != nil {
+		return ControlResponse{}, fmt.Errorf("failed to read last switch: %w", err)
+	}
+	last := state.LastSwitch()
+	target := req.Context
+	if target == "" {
+		if err := last.CheckUndo(config.Timeout.UndoWindow, time.Now()); err != nil {
+			return ControlResponse{}, err
+		}
+		target = last.From
+	} else if err := d.checkSwitchBack(target); err != nil {
+		return ControlResponse{}, err
+	}
+
+	currentContext, err := d.switcher.CurrentContext()
+	if err != nil {
+		return ControlResponse{}, fmt.Errorf("%w: %w", errContextUnavailable, err)
+	}
+
+	resp := ControlResponse{OK: true, FromContext: currentContext, ToContext: target}
+	if currentContext != target {
+		if err := d.switchContext(config, currentContext, target, SwitchBackReason); err != nil {
+			return ControlResponse{}, fmt.Errorf("%w: %w", errSwitchFailed, err)
+		}
+		if err := ClearPendingSwitch(d.stateManager); err != nil {
+			d.logger.Warn("Failed to clear pending switch", "error", err)
+		}
+		resp.Changed = true
+	} else if err := RecordActivity(d.stateManager, target); err != nil {
+		// Switching records activity; staying put has to reset the timer too
+		d.logger.Warn("Failed to record activity", "context", target, "error", err)
+	}
+
+	// Switching back undoes the last switch, so undo can't do it again
+	if !last.IsZero() && last.From == target {
+		if err := SetLastSwitch(d.stateManager, LastSwitch{}); err != nil {
+			d.logger.Warn("Failed to forget undone switch", "error", err)
+		}
+		if d.noticePath != "" {
+			if err := RemoveSwitchNotice(d.noticePath); err != nil {
+				d.logger.Warn("Failed to remove switch notice", "error", err)
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// checkSwitchBack refuses to switch back to a context unless it is the one
+// the last switch in the default kubeconfig left, as recorded in the history
+// log, so the request can't be used to switch to any context
+func (d *Daemon) checkSwitchBack(target string) error {
+	events, err := d.history.Read(HistoryFilter{Type: HistorySwitch})
+	if err != nil {
+		return fmt.Errorf("failed to read switch history: %w", err)
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Kubeconfig != "" {
+			continue // A kubeconfig session's switch
+		}
+		if events[i].FromContext != target {
+			return fmt.Errorf("cannot switch back to '%s': the last switch left '%s'", target, events[i].FromContext)
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot switch back to '%s': no switch has been made", target)
+}
+
+// controlProfile applies a profile, or clears it, and records the choice in
+// the state file so it survives a restart
+func (d *Daemon) controlProfile(req ControlRequest) (ControlResponse, error) {
+	profile := ActiveProfile{Name: req.Profile}
+	if req.Duration != "" {
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return ControlResponse{}, fmt.Errorf("invalid duration %q: %w", req.Duration, err)
+		}
+		if duration <= 0 {
+			return ControlResponse{}, fmt.Errorf("duration must be positive")
+		}
+		if profile.Name == "" {
+			return ControlResponse{}, fmt.Errorf("a duration needs a profile")
+		}
+		profile.Until = time.Now().Add(duration)
+	}
+
+	// Check the profile exists before recording it
+	if profile.Name != "" {
+		if _, err := d.loadedConfig().WithProfile(profile.Name); err != nil {
+			return ControlResponse{}, err
+		}
+	}
+	if err := SetProfile(d.stateManager, profile); err != nil {
+		return ControlResponse{}, fmt.Errorf("failed to save profile: %w", err)
+	}
+	if err := d.setProfile(profile); err != nil {
+		return ControlResponse{}, err
+	}
+
+	resp := ControlResponse{OK: true}
+	if profile.Name == "" {
+		d.logger.Info("Profile cleared")
+	} else if profile.Until.IsZero() {
+		d.logger.Info("Using profile", "profile", profile.Name)
+	} else {
+		d.logger.Info("Using profile", "profile", profile.Name, "until", profile.Until.Format(time.RFC3339))
+		resp.Until = &profile.Until
+	}
+
+	// The profile may bring the current context's deadline forward
+	d.requestCheck()
+	return resp, nil
+}
@@ -0,0 +1,530 @@
+package internal
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newControlTestDaemon starts the control socket of a daemon using fakes.
+// The socket lives in a short temporary directory, since socket paths are
+// limited to about 100 bytes.
+func newControlTestDaemon(t *testing.T, switcher *fakeSwitcher, store *fakeStateStore) *Daemon {
+	t.Helper()
+
+	d := newFakeDaemon(t, switcher, store)
+
+	dir, err := os.MkdirTemp("", "kctx")
+	if err != nil {
+		t.Fatalf("Failed to create socket directory: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	d.controlPath = filepath.Join(dir, controlSocketFileName)
+	if err := d.listenControl(); err != nil {
+		t.Fatalf("listenControl() error = %v", err)
+	}
+	t.Cleanup(d.closeControl)
+
+	return d
+}
+
+func TestControlStatus(t *testing.T) {
+	d := newControlTestDaemon(t, &fakeSwitcher{current: "production"}, &fakeStateStore{})
+
+	resp, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlStatus})
+	if err != nil {
+		t.Fatalf("SendControlRequest() error = %v", err)
+	}
+	if resp.Status == nil || resp.Status.State != SummaryStateActive || resp.Status.Context != "production" {
+		t.Errorf("Expected active status for production, got %+v", resp.Status)
+	}
+}
+
+func TestControlPauseAndResume(t *testing.T) {
+	store := &fakeStateStore{}
+	d := newControlTestDaemon(t, &fakeSwitcher{current: "production"}, store)
+
+	// Pausing a single context
+	resp, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlPause, Context: "production", Duration: "1h"})
+	if err != nil {
+		t.Fatalf("SendControlRequest(pause) error = %v", err)
+	}
+	if resp.Until == nil || resp.Until.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("Expected a pause of about an hour, got %v", resp.Until)
+	}
+	if resp.Status == nil || resp.Status.State != SummaryStatePaused {
+		t.Errorf("Expected paused status, got %+v", resp.Status)
+	}
+
+	resp, err = SendControlRequest(d.controlPath, ControlRequest{Command: ControlResume, Context: "production"})
+	if err != nil {
+		t.Fatalf("SendControlRequest(resume) error = %v", err)
+	}
+	if !resp.Changed || resp.Status.State != SummaryStateActive {
+		t.Errorf("Expected the pause to end, got %+v", resp)
+	}
+
+	resp, err = SendControlRequest(d.controlPath, ControlRequest{Command: ControlResume, Context: "production"})
+	if err != nil {
+		t.Fatalf("SendControlRequest(resume) error = %v", err)
+	}
+	if resp.Changed {
+		t.Error("Expected resuming an unpaused context to change nothing")
+	}
+
+	// Without a context, every context is paused
+	resp, err = SendControlRequest(d.controlPath, ControlRequest{Command: ControlPause, Duration: "30m"})
+	if err != nil {
+		t.Fatalf("SendControlRequest(pause) error = %v", err)
+	}
+	if resp.Status.State != SummaryStateExtended {
+		t.Errorf("Expected extended status, got %q", resp.Status.State)
+	}
+	if until := loadState(t, store).ExtendedUntil; resp.Until == nil || !until.Equal(*resp.Until) {
+		t.Errorf("Expected extension until %v recorded, got %v", resp.Until, until)
+	}
+
+	resp, err = SendControlRequest(d.controlPath, ControlRequest{Command: ControlResume})
+	if err != nil {
+		t.Fatalf("SendControlRequest(resume) error = %v", err)
+	}
+	if !resp.Changed || resp.Status.State != SummaryStateActive {
+		t.Errorf("Expected the extension to end, got %+v", resp)
+	}
+}
+
+func TestControlForceSwitch(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newControlTestDaemon(t, switcher, store)
+
+	// Forcing a switch overrides a pause
+	if _, err := PauseContext(store, "production", time.Hour); err != nil {
+		t.Fatalf("PauseContext() error = %v", err)
+	}
+
+	resp, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlForceSwitch})
+	if err != nil {
+		t.Fatalf("SendControlRequest(force-switch) error = %v", err)
+	}
+	if !resp.Changed || resp.FromContext != "production" || resp.ToContext != "local" {
+		t.Errorf("Expected a switch from production to local, got %+v", resp)
+	}
+	if len(switcher.switches) != 1 || switcher.switches[0] != "local" {
+		t.Errorf("Expected one switch to local, got %v", switcher.switches)
+	}
+
+	// Already on the default context
+	resp, err = SendControlRequest(d.controlPath, ControlRequest{Command: ControlForceSwitch})
+	if err != nil {
+		t.Fatalf("SendControlRequest(force-switch) error = %v", err)
+	}
+	if resp.Changed {
+		t.Error("Expected no switch when already on the default context")
+	}
+
+	// Failures are reported to the client
+	switcher.mu.Lock()
+	switcher.current = "production"
+	switcher.switchErr = MarkFailure(errors.New("exit status 1"), ErrKubectlFailed)
+	switcher.mu.Unlock()
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlForceSwitch}); !errors.Is(err, ErrKubectlFailed) {
+		t.Errorf("Expected the kubectl failure to be reported to the client, got %v", err)
+	}
+}
+
+func TestControlSwitchBack(t *testing.T) {
+	switcher := &fakeSwitcher{current: "local"}
+	store := &fakeStateStore{}
+	d := newControlTestDaemon(t, switcher, store)
+
+	// Only to the context a switch left
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack, Context: "production"}); err == nil {
+		t.Error("Expected switch-back with no switch made to fail")
+	}
+	d.recordHistory(HistoryEvent{Type: HistorySwitch, Context: "local", FromContext: "production"})
+	d.recordHistory(HistoryEvent{Type: HistorySwitch, Context: "local", FromContext: "staging", Kubeconfig: "/tmp/kubie.yaml"})
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack, Context: "staging"}); err == nil {
+		t.Error("Expected switch-back to a context the last switch didn't leave to fail")
+	}
+
+	resp, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack, Context: "production"})
+	if err != nil {
+		t.Fatalf("SendControlRequest(switch-back) error = %v", err)
+	}
+	if !resp.Changed || resp.FromContext != "local" || resp.ToContext != "production" {
+		t.Errorf("Expected a switch from local back to production, got %+v", resp)
+	}
+	if len(switcher.switches) != 1 || switcher.switches[0] != "production" {
+		t.Errorf("Expected one switch to production, got %v", switcher.switches)
+	}
+	if _, ctx, _ := LastActivity(store); ctx != "production" {
+		t.Errorf("Expected activity recorded in production, got %q", ctx)
+	}
+
+	// Switching back was the last switch, so it can't be repeated
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack, Context: "production"}); err == nil {
+		t.Error("Expected a second switch-back to fail")
+	}
+
+	// Already there
+	d.recordHistory(HistoryEvent{Type: HistorySwitch, Context: "local", FromContext: "production"})
+	resp, err = SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack, Context: "production"})
+	if err != nil {
+		t.Fatalf("SendControlRequest(switch-back) error = %v", err)
+	}
+	if resp.Changed {
+		t.Error("Expected no switch when already on the context")
+	}
+
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack}); err == nil {
+		t.Error("Expected switch-back without a context to fail")
+	}
+}
+
+func TestControlCancelSwitch(t *testing.T) {
+	store := &fakeStateStore{}
+	d := newControlTestDaemon(t, &fakeSwitcher{current: "production"}, store)
+
+	resp, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlCancelSwitch})
+	if err != nil {
+		t.Fatalf("SendControlRequest(cancel-switch) error = %v", err)
+	}
+	if resp.Changed {
+		t.Error("Expected nothing to cancel without a pending switch")
+	}
+
+	if err := SetPendingSwitch(store, PendingSwitch{From: "production", To: "local", At: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatalf("SetPendingSwitch() error = %v", err)
+	}
+	resp, err = SendControlRequest(d.controlPath, ControlRequest{Command: ControlCancelSwitch})
+	if err != nil {
+		t.Fatalf("SendControlRequest(cancel-switch) error = %v", err)
+	}
+	if !resp.Changed || resp.FromContext != "production" || resp.ToContext != "local" {
+		t.Errorf("Expected the switch from production to local canceled, got %+v", resp)
+	}
+	state := loadState(t, store)
+	if pending := state.PendingSwitch(); !pending.IsZero() {
+		t.Errorf("Expected no pending switch, got %+v", pending)
+	}
+	if state.CurrentContext != "production" || time.Since(state.LastActivity) > time.Minute {
+		t.Errorf("Expected the timer of production reset, got %v in %q", state.LastActivity, state.CurrentContext)
+	}
+}
+
+func TestControlReset(t *testing.T) {
+	store := &fakeStateStore{}
+	store.state.LastActivity = time.Now().Add(-time.Hour)
+	store.state.CurrentContext = "production"
+	d := newControlTestDaemon(t, &fakeSwitcher{current: "production"}, store)
+
+	resp, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlReset})
+	if err != nil {
+		t.Fatalf("SendControlRequest(reset) error = %v", err)
+	}
+	if resp.FromContext != "production" {
+		t.Errorf("Expected the current context reset, got %q", resp.FromContext)
+	}
+	if lastActivity, ctx, _ := LastActivity(store); ctx != "production" || time.Since(lastActivity) > time.Minute {
+		t.Errorf("Expected recent activity in production, got %v in %q", lastActivity, ctx)
+	}
+
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlReset, Context: "staging"}); err != nil {
+		t.Fatalf("SendControlRequest(reset) error = %v", err)
+	}
+	if _, ctx, _ := LastActivity(store); ctx != "staging" {
+		t.Errorf("Expected activity recorded in staging, got %q", ctx)
+	}
+}
+
+func TestControlUndo(t *testing.T) {
+	switcher := &fakeSwitcher{current: "local"}
+	store := &fakeStateStore{}
+	d := newControlTestDaemon(t, switcher, store)
+
+	// Nothing to undo yet
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack}); err == nil {
+		t.Fatal("Expected undo with no switch made to fail")
+	}
+
+	// Undo switches back to the context the last switch left, once
+	if err := SetLastSwitch(store, LastSwitch{From: "production", To: "local", At: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack})
+	if err != nil {
+		t.Fatalf("SendControlRequest(switch-back) error = %v", err)
+	}
+	if !resp.Changed || resp.ToContext != "production" {
+		t.Errorf("Expected a switch back to production, got %+v", resp)
+	}
+	if last := loadState(t, store).LastSwitch(); !last.IsZero() {
+		t.Errorf("Expected the undone switch forgotten, got %+v", last)
+	}
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack}); err == nil {
+		t.Error("Expected a second undo to fail")
+	}
+
+	// Not once the undo window has passed
+	if err := SetLastSwitch(store, LastSwitch{From: "staging", To: "local", At: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack}); err == nil ||
+		!strings.Contains(err.Error(), "undo window") {
+		t.Errorf("Expected undo past the window to fail, got %v", err)
+	}
+}
+
+func TestNeverSwitchToClustersBlocksSwitches(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	session := filepath.Join(t.TempDir(), "kubie.yaml")
+	if err := os.WriteFile(session, []byte("apiVersion: v1\n"), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	switcher := &fakeKubeconfigSwitcher{
+		fakeSwitcher: &fakeSwitcher{current: "production"},
+		sessions:     map[string]*fakeSwitcher{session: {current: "production"}},
+	}
+	store := &fakeStateStore{}
+	d := newControlTestDaemon(t, switcher.fakeSwitcher, store)
+	d.switcher = switcher
+
+	// The default context, local, is on a cluster switches must never reach
+	config := *d.currentConfig()
+	config.Safety.NeverSwitchToClusters = []string{"https://*.prod.example.com"}
+	config.contextServer = func(contextName string) string {
+		return map[string]string{"local": "https://api.prod.example.com:6443"}[contextName]
+	}
+	d.setConfig(&config)
+
+	now := time.Now()
+	if err := store.Save(&State{LastActivity: now.Add(-time.Hour), CurrentContext: "production"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := d.checkTimeout(); !errors.Is(err, ErrSwitchRefused) {
+		t.Errorf("Expected the timeout switch refused, got %v", err)
+	}
+
+	store.state.Sessions = map[string]KubeconfigSession{
+		session: {LastActivity: now.Add(-time.Hour), CurrentContext: "production"},
+	}
+	d.checkSessions(d.currentConfig(), now)
+
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlForceSwitch}); !errors.Is(err, ErrSwitchRefused) {
+		t.Errorf("Expected force-switch refused, got %v", err)
+	}
+
+	switcher.mu.Lock()
+	switcher.current = "staging"
+	switcher.mu.Unlock()
+	if err := SetLastSwitch(store, LastSwitch{From: "local", To: "staging", At: now.Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlSwitchBack}); !errors.Is(err, ErrSwitchRefused) {
+		t.Errorf("Expected switch-back refused, got %v", err)
+	}
+
+	if got := switcher.switches; len(got) != 0 {
+		t.Errorf("Expected no switch in the default kubeconfig, got %v", got)
+	}
+	if got := switcher.sessions[session].switches; len(got) != 0 {
+		t.Errorf("Expected no switch in the kubeconfig session, got %v", got)
+	}
+}
+
+func TestControlAction(t *testing.T) {
+	store := &fakeStateStore{}
+	d := newControlTestDaemon(t, &fakeSwitcher{current: "production"}, store)
+
+	action := d.controlAction("Snooze 30m", ControlRequest{Command: ControlPause, Duration: snoozeDuration.String()})
+	if action.Label != "Snooze 30m" {
+		t.Errorf("Label = %q, want Snooze 30m", action.Label)
+	}
+	action.Run()
+
+	if until := loadState(t, store).ExtendedUntil; until.Before(time.Now().Add(29 * time.Minute)) {
+		t.Errorf("Expected timeout switching snoozed for 30m, extended until %v", until)
+	}
+}
+
+func TestControlReload(t *testing.T) {
+	d := newControlTestDaemon(t, &fakeSwitcher{current: "production"}, &fakeStateStore{})
+
+	// Reloads read the file the daemon was started with
+	configPath := d.configPath
+	config := "timeout:\n  default: 10m\n  check_interval: 5s\ndefault_context: staging\nnotifications:\n  enabled: false\n"
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	resp, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlReload})
+	if err != nil {
+		t.Fatalf("SendControlRequest(reload) error = %v", err)
+	}
+	if resp.Status == nil || resp.Status.DefaultContext != "staging" {
+		t.Errorf("Expected the reloaded default context, got %+v", resp.Status)
+	}
+
+	// The main loop is told to check against the new timeouts
+	select {
+	case <-d.checkRequested:
+	default:
+		t.Error("Expected a check request for the main loop")
+	}
+
+	// A broken config is reported and the old one kept
+	if err := os.WriteFile(configPath, []byte("timeout: [unclosed"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlReload}); !errors.Is(err, ErrConfigInvalid) {
+		t.Errorf("Expected a configuration error reloading an invalid config, got %v", err)
+	}
+	if got := d.currentConfig().DefaultContext; got != "staging" {
+		t.Errorf("Expected the previous config to remain, got default context %q", got)
+	}
+
+	// So is a missing one, rather than falling back to the defaults
+	if err := os.Remove(configPath); err != nil {
+		t.Fatalf("Failed to remove config: %v", err)
+	}
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlReload}); !errors.Is(err, ErrConfigInvalid) {
+		t.Errorf("Expected a configuration error reloading a missing config, got %v", err)
+	}
+}
+
+func TestControlInvalidRequests(t *testing.T) {
+	d := newControlTestDaemon(t, &fakeSwitcher{current: "production"}, &fakeStateStore{})
+
+	tests := []struct {
+		name    string
+		req     ControlRequest
+		wantErr string
+	}{
+		{name: "unknown command", req: ControlRequest{Command: "self-destruct"}, wantErr: "unknown command"},
+		{name: "invalid duration", req: ControlRequest{Command: ControlPause, Duration: "soon"}, wantErr: "invalid duration"},
+		{name: "non-positive duration", req: ControlRequest{Command: ControlPause, Context: "production", Duration: "0s"}, wantErr: "must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := SendControlRequest(d.controlPath, tt.req)
+			if err == nil || !strings.Contains(resp.Error, tt.wantErr) {
+				t.Errorf("SendControlRequest() = %+v, %v, want error containing %q", resp, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Malformed JSON gets an error response rather than a dropped connection
+	conn, err := net.Dial("unix", d.controlPath)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("{not json\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	buf := make([]byte, 1024)
+	n, _ := conn.Read(buf)
+	if !strings.Contains(string(buf[:n]), "invalid request") {
+		t.Errorf("Expected an invalid request response, got %q", buf[:n])
+	}
+}
+
+func TestControlSocketLifecycle(t *testing.T) {
+	d := newControlTestDaemon(t, &fakeSwitcher{current: "production"}, &fakeStateStore{})
+
+	info, err := os.Stat(d.controlPath)
+	if err != nil {
+		t.Fatalf("Control socket not created: %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a socket with mode 0600, got %v", info.Mode())
+	}
+
+	// Shutting down refuses further requests and removes the socket
+	d.shutdownTimeout = time.Second
+	d.pidFile = NewPIDFileWithPath(filepath.Join(t.TempDir(), "daemon.pid"))
+	d.Shutdown()
+
+	if _, err := os.Stat(d.controlPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the control socket removed, got %v", err)
+	}
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlStatus}); !errors.Is(err, ErrControlUnavailable) {
+		t.Errorf("SendControlRequest() after shutdown error = %v, want %v", err, ErrControlUnavailable)
+	}
+}
+
+func TestControlSocketPathForState(t *testing.T) {
+	got := ControlSocketPathForState("/home/user/.local/state/kubectx-timeout/state.json")
+	if want := "/home/user/.local/state/kubectx-timeout/daemon.sock"; got != want {
+		t.Errorf("ControlSocketPathForState() = %q, want %q", got, want)
+	}
+}
+
+func TestControlProfile(t *testing.T) {
+	store := &fakeStateStore{}
+	d := newControlTestDaemon(t, &fakeSwitcher{current: "production"}, store)
+
+	config := `timeout:
+  default: 10m
+  check_interval: 1s
+default_context: local
+notifications:
+  enabled: false
+profiles:
+  oncall:
+    timeout: 2h
+    contexts:
+      production:
+        timeout: 1h
+`
+	if err := os.WriteFile(d.configPath, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	resp, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlProfile, Profile: "oncall", Duration: "8h"})
+	if err != nil {
+		t.Fatalf("SendControlRequest(profile) error = %v", err)
+	}
+	if resp.Until == nil || resp.Until.Before(time.Now().Add(7*time.Hour)) {
+		t.Errorf("Expected the profile to last about 8 hours, got %v", resp.Until)
+	}
+	if resp.Status == nil || resp.Status.Profile != "oncall" || resp.Status.TimeoutSeconds != int64(time.Hour/time.Second) {
+		t.Errorf("Expected status with the oncall profile's timeout, got %+v", resp.Status)
+	}
+	if got := d.currentConfig().GetTimeoutForContext("staging"); got != 2*time.Hour {
+		t.Errorf("Expected the profile's default timeout of 2h, got %v", got)
+	}
+	if profile := loadState(t, store).ActiveProfile(); profile.Name != "oncall" || !profile.Until.Equal(*resp.Until) {
+		t.Errorf("Expected the profile recorded in state, got %+v", profile)
+	}
+
+	// An unknown profile changes nothing
+	if _, err := SendControlRequest(d.controlPath, ControlRequest{Command: ControlProfile, Profile: "weekend"}); err == nil || !strings.Contains(err.Error(), "unknown profile") {
+		t.Errorf("Expected an unknown profile error, got %v", err)
+	}
+	if profile := loadState(t, store).ActiveProfile(); profile.Name != "oncall" {
+		t.Errorf("Expected the oncall profile to remain, got %+v", profile)
+	}
+
+	resp, err = SendControlRequest(d.controlPath, ControlRequest{Command: ControlProfile})
+	if err != nil {
+		t.Fatalf("SendControlRequest(profile) error = %v", err)
+	}
+	if resp.Status.Profile != "" || d.currentConfig().GetTimeoutForContext("production") != 10*time.Minute {
+		t.Errorf("Expected the profile cleared, got %+v", resp.Status)
+	}
+	if profile := loadState(t, store).ActiveProfile(); profile.Name != "" {
+		t.Errorf("Expected the profile cleared from state, got %+v", profile)
+	}
+}
@@ -0,0 +1,328 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1" // #nosec G505 -- the AWS CLI names SSO cache files by SHA-1
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// kubeconfigExec is the exec credential plugin of a kubeconfig user
+type kubeconfigExec struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+	Env     []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+}
+
+// tokenCacheEntry matches the files kubelogin keeps tokens in, which it
+// names by a hex digest. Anything else in its cache directory is left
+// alone, in case it was pointed at a directory that holds more than its
+// cache.
+var tokenCacheEntry = regexp.MustCompile(`^[0-9a-f]{16,}$`)
+
+// RevokeContextCredentials deletes the tokens the exec credential plugin of
+// a context has cached, so the next kubectl command in it has to log in
+// again. It knows kubelogin (kubectl oidc-login), Azure kubelogin, the AWS
+// CLI (aws eks get-token with an SSO profile), and gke-gcloud-auth-plugin;
+// static credentials and other plugins are left alone. Only the entries for
+// the context's own login are removed, so other contexts stay logged in,
+// except those sharing its AWS SSO session or gcloud account. It returns
+// the files that were removed.
+func RevokeContextCredentials(kubeconfigPaths []string, contextName string) ([]string, error) {
+	contextUsers, users, err := loadKubeconfigUsers(kubeconfigPaths)
+	if err != nil {
+		return nil, err
+	}
+	userName, ok := contextUsers[contextName]
+	if !ok {
+		return nil, fmt.Errorf("context '%s' not found in kubeconfig", contextName)
+	}
+	user, ok := users[userName]
+	if !ok || user.Exec == nil {
+		return nil, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	var removed []string
+	for _, path := range user.tokenCacheFiles(home) {
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, fmt.Errorf("failed to remove cached credentials %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// tokenCacheFiles returns the files the user's exec plugin caches tokens in
+func (u kubeconfigUser) tokenCacheFiles(home string) []string {
+	exec := u.Exec
+	command := filepath.Base(exec.Command)
+	args := exec.Args
+	if command == "kubectl" && len(args) > 0 {
+		// kubectl oidc-login runs the kubectl-oidc_login plugin
+		command, args = "kubectl-"+strings.ReplaceAll(args[0], "-", "_"), args[1:]
+	}
+
+	switch command {
+	case "kubectl-oidc_login":
+		dir := u.resolvePath(execFlag(args, "--token-cache-dir", filepath.Join(home, ".kube", "cache", "oidc-login")))
+		return oidcTokenCacheFiles(dir, execFlag(args, "--oidc-issuer-url", ""), execFlag(args, "--oidc-client-id", ""))
+	case "kubelogin":
+		dir := u.resolvePath(execFlag(args, "--token-cache-dir", filepath.Join(home, ".kube", "cache", "kubelogin")))
+		return azureTokenCacheFiles(dir, args)
+	case "aws":
+		if !containsInOrder(args, []string{"eks", "get-token"}) {
+			return nil
+		}
+		profile := execFlag(args, "--profile", exec.env("AWS_PROFILE"))
+		return awsSSOCacheFiles(home, profile)
+	case "gke-gcloud-auth-plugin":
+		return []string{filepath.Join(home, ".kube", "gke_gcloud_auth_plugin_cache")}
+	}
+	return nil
+}
+
+// env returns the value of a variable for the plugin: set in its exec
+// config, or else inherited from the daemon
+func (e *kubeconfigExec) env(name string) string {
+	for _, v := range e.Env {
+		if v.Name == name {
+			return v.Value
+		}
+	}
+	return os.Getenv(name)
+}
+
+// execFlag returns the value of a flag in plugin arguments, given as
+// "--flag value" or "--flag=value", or def if it isn't set
+func execFlag(args []string, flag, def string) string {
+	for i, arg := range args {
+		if value, ok := strings.CutPrefix(arg, flag+"="); ok {
+			return value
+		}
+		if arg == flag && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return def
+}
+
+// oidcTokenCacheFiles returns the files in kubelogin's token cache holding
+// tokens the issuer granted the client. kubelogin names them by a digest of
+// every setting of the login, so the ID tokens' claims tell them apart.
+func oidcTokenCacheFiles(dir, issuer, clientID string) []string {
+	if issuer == "" || clientID == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !tokenCacheEntry.MatchString(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if cachedTokenGrantedTo(path, issuer, clientID) {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// cachedTokenGrantedTo reports whether the ID token in a kubelogin cache
+// file was issued by issuer for clientID. Files it can't read are left
+// alone.
+func cachedTokenGrantedTo(path, issuer, clientID string) bool {
+	// #nosec G304 -- path is in the plugin's token cache directory
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var cached struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return false
+	}
+
+	parts := strings.Split(cached.IDToken, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return false
+	}
+	var claims struct {
+		Iss string          `json:"iss"`
+		Aud json.RawMessage `json:"aud"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return false
+	}
+	if strings.TrimSuffix(claims.Iss, "/") != strings.TrimSuffix(issuer, "/") {
+		return false
+	}
+
+	// The audience is a single client, or a list of them
+	var audience []string
+	var single string
+	if err := json.Unmarshal(claims.Aud, &single); err == nil {
+		audience = []string{single}
+	} else if err := json.Unmarshal(claims.Aud, &audience); err != nil {
+		return false
+	}
+	return slices.Contains(audience, clientID)
+}
+
+// azureTokenCacheFiles returns the files Azure kubelogin caches the token
+// for the plugin's login in, which it names after the environment, server,
+// client, and tenant
+func azureTokenCacheFiles(dir string, args []string) []string {
+	serverID := execFlag(args, "--server-id", "")
+	clientID := execFlag(args, "--client-id", "")
+	if serverID == "" || clientID == "" {
+		return nil
+	}
+	environment := execFlag(args, "--environment", execFlag(args, "-e", "AzurePublicCloud"))
+	tenantID := execFlag(args, "--tenant-id", execFlag(args, "-t", ""))
+
+	name := strings.Join([]string{environment, serverID, clientID, tenantID}, "-")
+	if filepath.Base(name) != name {
+		return nil
+	}
+	return []string{filepath.Join(dir, name+".json"), filepath.Join(dir, name+"_legacy.json")}
+}
+
+// awsSSOCacheFiles returns the AWS CLI's cached SSO token for a profile and
+// the role credentials it cached for the profile with it. The token is
+// named by the SHA-1 of the profile's sso_session, or of its sso_start_url
+// for profiles configured before SSO sessions, and is shared with the
+// session's other profiles. The role credentials are named by the SHA-1 of
+// the login they came from.
+func awsSSOCacheFiles(home, profile string) []string {
+	settings := awsConfigSection(home, awsProfileSection(profile))
+	login := map[string]string{
+		"startUrl":  settings["sso_start_url"],
+		"roleName":  settings["sso_role_name"],
+		"accountId": settings["sso_account_id"],
+	}
+	key := settings["sso_start_url"]
+	if session := settings["sso_session"]; session != "" {
+		key = session
+		login["startUrl"] = awsConfigSection(home, "sso-session "+session)["sso_start_url"]
+		login["sessionName"] = session
+	}
+	if key == "" {
+		// Not an SSO profile; its keys aren't cached tokens
+		return nil
+	}
+
+	files := []string{filepath.Join(home, ".aws", "sso", "cache", sha1Hex(key)+".json")}
+	if loginKey, err := awsCacheKey(login); err == nil {
+		files = append(files, filepath.Join(home, ".aws", "cli", "cache", sha1Hex(loginKey)+".json"))
+	}
+	return files
+}
+
+// awsCacheKey encodes the arguments of a login as the AWS CLI does to name
+// its cached credentials: JSON with sorted keys and no spaces
+func awsCacheKey(args map[string]string) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(args); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// sha1Hex returns the hex SHA-1 of s, as the AWS CLI names cache files
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s)) // #nosec G401 -- matches the AWS CLI's file names
+	return hex.EncodeToString(sum[:])
+}
+
+// awsProfileSection returns the section of the AWS CLI config file that
+// holds a profile's settings
+func awsProfileSection(profile string) string {
+	if profile == "" || profile == "default" {
+		return "default"
+	}
+	return "profile " + profile
+}
+
+// awsConfigSection returns the settings in a section of the AWS CLI config
+// file ($AWS_CONFIG_FILE or ~/.aws/config)
+func awsConfigSection(home, section string) map[string]string {
+	path := os.Getenv("AWS_CONFIG_FILE")
+	if path == "" {
+		path = filepath.Join(home, ".aws", "config")
+	}
+	// #nosec G304 -- path is the user's AWS CLI config
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	settings := make(map[string]string)
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			inSection = strings.TrimSpace(strings.TrimSuffix(name, "]")) == section
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok && inSection {
+			settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return settings
+}
+
+// revokeCredentials deletes the cached credentials of a context switched
+// away from, if its contexts entry sets revoke_credentials. Failures are
+// logged but never affect the switch.
+func (d *Daemon) revokeCredentials(config *Config, kubeconfigPaths []string, contextName string) {
+	if !config.ShouldRevokeCredentials(contextName) {
+		return
+	}
+
+	removed, err := RevokeContextCredentials(kubeconfigPaths, contextName)
+	for _, path := range removed {
+		d.logger.Info("Revoked cached credentials after switching away", "context", contextName, "file", path)
+	}
+	if err != nil {
+		d.logger.Warn("Failed to revoke cached credentials", "context", contextName, "error", err)
+		return
+	}
+	if len(removed) == 0 {
+		d.logger.Debug("No cached credentials to revoke", "context", contextName)
+	}
+}
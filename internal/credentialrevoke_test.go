@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRevokeContextCredentials(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", "")
+
+	kubeconfig := filepath.Join(home, ".kube", "config")
+	writeFile := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	writeFile(kubeconfig, `apiVersion: v1
+kind: Config
+contexts:
+  - name: oidc-prod
+    context: {cluster: prod, user: oidc}
+  - name: oidc-grafana
+    context: {cluster: prod, user: oidc-grafana}
+  - name: eks-prod
+    context: {cluster: eks, user: eks}
+  - name: aks-prod
+    context: {cluster: aks, user: aks}
+  - name: static
+    context: {cluster: kind, user: admin}
+users:
+  - name: oidc
+    user:
+      exec:
+        command: kubectl
+        args: [oidc-login, get-token, --oidc-issuer-url=https://issuer.example.com, --oidc-client-id=kubernetes]
+  - name: oidc-grafana
+    user:
+      exec:
+        command: kubectl
+        args: [oidc-login, get-token, --oidc-issuer-url=https://issuer.example.com, --oidc-client-id=grafana]
+  - name: eks
+    user:
+      exec:
+        command: aws
+        args: [--region, eu-west-1, eks, get-token, --cluster-name, prod]
+        env:
+          - name: AWS_PROFILE
+            value: prod-admin
+  - name: aks
+    user:
+      exec:
+        command: kubelogin
+        args: [get-token, --environment, AzurePublicCloud, --server-id, 6dae42f8, --client-id, 80faf920, --tenant-id, 72f988bf]
+  - name: admin
+    user:
+      token: secret
+`)
+
+	// kubelogin caches each login's tokens under a digest of its settings
+	idToken := func(claims string) string {
+		return `{"id_token":"eyJhbGciOiJSUzI1NiJ9.` + base64.RawURLEncoding.EncodeToString([]byte(claims)) + `.sig"}`
+	}
+	oidcCache := filepath.Join(home, ".kube", "cache", "oidc-login")
+	oidcToken := filepath.Join(oidcCache, "3b5c2a7e9d0f41e8a6c1")
+	oidcScopedToken := filepath.Join(oidcCache, "5d8e1f0a2b3c4d5e6f70")
+	grafanaToken := filepath.Join(oidcCache, "9f8e7d6c5b4a39281706")
+	oidcNotes := filepath.Join(oidcCache, "README")
+	writeFile(oidcToken, idToken(`{"iss":"https://issuer.example.com","aud":"kubernetes"}`))
+	writeFile(oidcScopedToken, idToken(`{"iss":"https://issuer.example.com/","aud":["kubernetes","audit"]}`))
+	writeFile(grafanaToken, idToken(`{"iss":"https://issuer.example.com","aud":"grafana"}`))
+	writeFile(oidcNotes, "not a token")
+
+	writeFile(filepath.Join(home, ".aws", "config"), `[default]
+region = eu-west-1
+
+[profile prod-admin]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = Admin
+
+[profile prod-readonly]
+sso_session = corp
+sso_account_id = 123456789012
+sso_role_name = ReadOnly
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+`)
+	// sha1("corp")
+	ssoToken := filepath.Join(home, ".aws", "sso", "cache", "ee0bfd2552fbd840c02cc48b6e823320543c450f.json")
+	writeFile(ssoToken, "{}")
+	// sha1 of {"accountId":"123456789012","roleName":"Admin","sessionName":"corp","startUrl":"https://corp.awsapps.com/start"}
+	roleCredentials := filepath.Join(home, ".aws", "cli", "cache", "643437e8f39b8a2baafe7552d5198d79ad56cc38.json")
+	writeFile(roleCredentials, "{}")
+	// The same for the ReadOnly role
+	otherRoleCredentials := filepath.Join(home, ".aws", "cli", "cache", "f2bccfaf0c53963f40d332ceb6d0a2010ecae14a.json")
+	writeFile(otherRoleCredentials, "{}")
+
+	aksCache := filepath.Join(home, ".kube", "cache", "kubelogin")
+	aksToken := filepath.Join(aksCache, "AzurePublicCloud-6dae42f8-80faf920-72f988bf.json")
+	otherAKSToken := filepath.Join(aksCache, "AzurePublicCloud-6dae42f8-04b07795-72f988bf.json")
+	writeFile(aksToken, "{}")
+	writeFile(otherAKSToken, "{}")
+
+	removed, err := RevokeContextCredentials([]string{kubeconfig}, "oidc-prod")
+	if err != nil {
+		t.Fatalf("RevokeContextCredentials() error = %v", err)
+	}
+	if !slices.Equal(removed, []string{oidcToken, oidcScopedToken}) {
+		t.Errorf("Expected only the context's kubelogin tokens removed, got %v", removed)
+	}
+	for _, path := range []string{grafanaToken, oidcNotes} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s kept: %v", path, err)
+		}
+	}
+
+	removed, err = RevokeContextCredentials([]string{kubeconfig}, "eks-prod")
+	if err != nil {
+		t.Fatalf("RevokeContextCredentials() error = %v", err)
+	}
+	if !slices.Equal(removed, []string{ssoToken, roleCredentials}) {
+		t.Errorf("Expected the profile's SSO token and cached role credentials removed, got %v", removed)
+	}
+	if _, err := os.Stat(otherRoleCredentials); err != nil {
+		t.Errorf("Expected another profile's role credentials kept: %v", err)
+	}
+
+	removed, err = RevokeContextCredentials([]string{kubeconfig}, "aks-prod")
+	if err != nil {
+		t.Fatalf("RevokeContextCredentials() error = %v", err)
+	}
+	if !slices.Equal(removed, []string{aksToken}) {
+		t.Errorf("Expected only the context's Azure kubelogin token removed, got %v", removed)
+	}
+	if _, err := os.Stat(otherAKSToken); err != nil {
+		t.Errorf("Expected another client's Azure kubelogin token kept: %v", err)
+	}
+
+	if removed, err := RevokeContextCredentials([]string{kubeconfig}, "static"); err != nil || len(removed) != 0 {
+		t.Errorf("Expected static credentials left alone, got %v, %v", removed, err)
+	}
+	if _, err := RevokeContextCredentials([]string{kubeconfig}, "missing"); err == nil {
+		t.Error("Expected an error for an unknown context")
+	}
+}
+
+func TestConfigShouldRevokeCredentials(t *testing.T) {
+	config := DefaultConfig()
+	config.Contexts = map[string]Context{
+		"prod-*":  {RevokeCredentials: true},
+		"staging": {Timeout: DefaultConfig().Timeout.Default},
+	}
+
+	if !config.ShouldRevokeCredentials("prod-eu") {
+		t.Error("Expected a context matching a revoke_credentials pattern to be revoked")
+	}
+	if config.ShouldRevokeCredentials("staging") || config.ShouldRevokeCredentials("dev") {
+		t.Error("Expected revoke_credentials off by default")
+	}
+}
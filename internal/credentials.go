@@ -0,0 +1,295 @@
+package internal
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// credentialScanInterval is how often the daemon checks the kubeconfig for
+// expired credentials
+const credentialScanInterval = 1 * time.Hour
+
+// Kinds of credentials that can expire
+const (
+	CredentialClientCertificate = "client certificate"
+	CredentialToken             = "token"
+)
+
+// StaleCredential is an expired credential referenced by a kubeconfig context
+type StaleCredential struct {
+	Context   string
+	User      string
+	Kind      string // CredentialClientCertificate or CredentialToken
+	ExpiredAt time.Time
+}
+
+// Describe returns a human-readable description, e.g.
+// "context prod-eu has a client certificate that expired 12d ago"
+func (c StaleCredential) Describe(now time.Time) string {
+	return fmt.Sprintf("context %s has a %s that expired %s ago", c.Context, c.Kind, formatAge(now.Sub(c.ExpiredAt)))
+}
+
+// formatAge rounds a duration to the largest whole unit: days, hours, or minutes
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	}
+}
+
+// kubeconfigFile holds the parts of a kubeconfig needed to find credentials
+// and cluster servers
+type kubeconfigFile struct {
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server string `yaml:"server"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string         `yaml:"name"`
+		User kubeconfigUser `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// kubeconfigUser holds the static credentials of a kubeconfig user. Exec and
+// auth-provider credentials are refreshed by kubectl and never go stale,
+// but the exec plugin's token cache can be revoked.
+type kubeconfigUser struct {
+	ClientCertificate     string          `yaml:"client-certificate"`
+	ClientCertificateData string          `yaml:"client-certificate-data"`
+	Token                 string          `yaml:"token"`
+	TokenFile             string          `yaml:"tokenFile"`
+	Exec                  *kubeconfigExec `yaml:"exec"`
+
+	// dir is the directory of the kubeconfig that defined the user, which
+	// relative file paths are resolved against
+	dir string
+}
+
+// FindStaleCredentials reports contexts whose client certificate or token
+// has expired. The kubeconfig files are merged the way kubectl merges them:
+// the first file to define a context or user wins.
+func FindStaleCredentials(kubeconfigPaths []string, now time.Time) ([]StaleCredential, error) {
+	contextUsers, users, err := loadKubeconfigUsers(kubeconfigPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []StaleCredential
+	for contextName, userName := range contextUsers {
+		user, ok := users[userName]
+		if !ok {
+			continue
+		}
+		for kind, expiry := range credentialExpiries(user) {
+			if now.After(expiry) {
+				stale = append(stale, StaleCredential{
+					Context:   contextName,
+					User:      userName,
+					Kind:      kind,
+					ExpiredAt: expiry,
+				})
+			}
+		}
+	}
+
+	sort.Slice(stale, func(i, j int) bool {
+		if stale[i].Context != stale[j].Context {
+			return stale[i].Context < stale[j].Context
+		}
+		return stale[i].Kind < stale[j].Kind
+	})
+
+	return stale, nil
+}
+
+// loadKubeconfigUsers reads the user of each context and the users by
+// name. The kubeconfig files are merged the way kubectl merges them: the
+// first file to define a context or user wins.
+func loadKubeconfigUsers(kubeconfigPaths []string) (map[string]string, map[string]kubeconfigUser, error) {
+	contextUsers := make(map[string]string)
+	users := make(map[string]kubeconfigUser)
+
+	err := forEachKubeconfig(kubeconfigPaths, func(path string, file kubeconfigFile) {
+		for _, ctx := range file.Contexts {
+			if _, ok := contextUsers[ctx.Name]; !ok {
+				contextUsers[ctx.Name] = ctx.Context.User
+			}
+		}
+		for _, user := range file.Users {
+			if _, ok := users[user.Name]; !ok {
+				user.User.dir = filepath.Dir(path)
+				users[user.Name] = user.User
+			}
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return contextUsers, users, nil
+}
+
+// forEachKubeconfig parses each kubeconfig file in order, skipping files
+// that don't exist
+func forEachKubeconfig(kubeconfigPaths []string, fn func(path string, file kubeconfigFile)) error {
+	for _, path := range kubeconfigPaths {
+		// #nosec G304 -- path comes from KUBECONFIG or the default kubeconfig location
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read kubeconfig %s: %w", path, err)
+		}
+
+		var file kubeconfigFile
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("failed to parse kubeconfig %s: %w", path, err)
+		}
+		fn(path, file)
+	}
+	return nil
+}
+
+// credentialExpiries returns the expiry time of each of a user's credentials
+// that has one. Credentials that can't be read or don't expire are skipped.
+func credentialExpiries(user kubeconfigUser) map[string]time.Time {
+	expiries := make(map[string]time.Time)
+
+	if certPEM := user.clientCertificatePEM(); certPEM != nil {
+		if notAfter, ok := certificateExpiry(certPEM); ok {
+			expiries[CredentialClientCertificate] = notAfter
+		}
+	}
+
+	if token := user.token(); token != "" {
+		if exp, ok := tokenExpiry(token); ok {
+			expiries[CredentialToken] = exp
+		}
+	}
+
+	return expiries
+}
+
+// clientCertificatePEM returns the user's client certificate, inline or from file
+func (u kubeconfigUser) clientCertificatePEM() []byte {
+	if u.ClientCertificateData != "" {
+		data, err := base64.StdEncoding.DecodeString(u.ClientCertificateData)
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+
+	if u.ClientCertificate != "" {
+		// #nosec G304 -- path is the client certificate referenced by the user's kubeconfig
+		data, err := os.ReadFile(u.resolvePath(u.ClientCertificate))
+		if err != nil {
+			return nil
+		}
+		return data
+	}
+
+	return nil
+}
+
+// token returns the user's bearer token, inline or from file
+func (u kubeconfigUser) token() string {
+	if u.Token != "" {
+		return u.Token
+	}
+
+	if u.TokenFile != "" {
+		// #nosec G304 -- path is the token file referenced by the user's kubeconfig
+		data, err := os.ReadFile(u.resolvePath(u.TokenFile))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+
+	return ""
+}
+
+// resolvePath resolves a path relative to the kubeconfig that defined the user
+func (u kubeconfigUser) resolvePath(path string) string {
+	if filepath.IsAbs(path) || u.dir == "" {
+		return path
+	}
+	return filepath.Join(u.dir, path)
+}
+
+// certificateExpiry returns the NotAfter time of the first certificate in a
+// PEM bundle
+func certificateExpiry(data []byte) (time.Time, bool) {
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			return time.Time{}, false
+		}
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return time.Time{}, false
+			}
+			return cert.NotAfter, true
+		}
+		data = rest
+	}
+}
+
+// tokenExpiry returns the "exp" claim of a JWT bearer token. Opaque tokens
+// carry no expiry and report false.
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp *int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(*claims.Exp, 0), true
+}
+
+// DeleteContext removes a context from the kubeconfig. The cluster and user
+// entries are left in place since other contexts may share them.
+func DeleteContext(contextName string) error {
+	// The context name is passed as a single argument, not through a shell
+	if _, err := runKubectl("", "config", "delete-context", contextName); err != nil {
+		return fmt.Errorf("failed to delete context '%s': %w", contextName, err)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCertificatePEM returns a self-signed certificate expiring at notAfter
+func testCertificatePEM(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-user"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// testJWT returns an unsigned JWT with the given expiry
+func testJWT(exp time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"test","exp":%d}`, exp.Unix())))
+	return header + "." + payload + ".signature"
+}
+
+func TestFindStaleCredentials(t *testing.T) {
+	tmpDir := t.TempDir()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	expiredCert := base64.StdEncoding.EncodeToString(testCertificatePEM(t, now.Add(-12*24*time.Hour)))
+	validCert := base64.StdEncoding.EncodeToString(testCertificatePEM(t, now.Add(30*24*time.Hour)))
+
+	// A relative certificate path is resolved against the kubeconfig's directory
+	if err := os.WriteFile(filepath.Join(tmpDir, "staging.crt"), testCertificatePEM(t, now.Add(-2*time.Hour)), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+contexts:
+- context: {cluster: prod, user: prod-user}
+  name: prod-eu
+- context: {cluster: dev, user: dev-user}
+  name: dev
+- context: {cluster: staging, user: staging-user}
+  name: staging
+- context: {cluster: ci, user: ci-user}
+  name: ci
+- context: {cluster: opaque, user: opaque-user}
+  name: opaque
+users:
+- name: prod-user
+  user:
+    client-certificate-data: %s
+- name: dev-user
+  user:
+    client-certificate-data: %s
+    token: %s
+- name: staging-user
+  user:
+    client-certificate: staging.crt
+- name: ci-user
+  user:
+    token: %s
+- name: opaque-user
+  user:
+    token: not-a-jwt
+`, expiredCert, validCert, testJWT(now.Add(time.Hour)), testJWT(now.Add(-3*24*time.Hour)))
+
+	path := filepath.Join(tmpDir, "config")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	stale, err := FindStaleCredentials([]string{path}, now)
+	if err != nil {
+		t.Fatalf("FindStaleCredentials() error = %v", err)
+	}
+
+	want := []string{
+		"context ci has a token that expired 3d ago",
+		"context prod-eu has a client certificate that expired 12d ago",
+		"context staging has a client certificate that expired 2h ago",
+	}
+	if len(stale) != len(want) {
+		t.Fatalf("FindStaleCredentials() found %d, want %d: %+v", len(stale), len(want), stale)
+	}
+	for i, cred := range stale {
+		if got := cred.Describe(now); got != want[i] {
+			t.Errorf("stale[%d] = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestFindStaleCredentialsMergesFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	now := time.Now()
+
+	// The first file to define a user wins, as with kubectl
+	first := fmt.Sprintf(`users:
+- name: shared-user
+  user:
+    token: %s
+`, testJWT(now.Add(time.Hour)))
+	second := fmt.Sprintf(`contexts:
+- context: {cluster: prod, user: shared-user}
+  name: prod
+users:
+- name: shared-user
+  user:
+    token: %s
+`, testJWT(now.Add(-time.Hour)))
+
+	firstPath := filepath.Join(tmpDir, "first")
+	secondPath := filepath.Join(tmpDir, "second")
+	if err := os.WriteFile(firstPath, []byte(first), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+	if err := os.WriteFile(secondPath, []byte(second), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	stale, err := FindStaleCredentials([]string{firstPath, filepath.Join(tmpDir, "missing"), secondPath}, now)
+	if err != nil {
+		t.Fatalf("FindStaleCredentials() error = %v", err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("Expected the first file's valid token to win, got %+v", stale)
+	}
+
+	stale, err = FindStaleCredentials([]string{secondPath, firstPath}, now)
+	if err != nil {
+		t.Fatalf("FindStaleCredentials() error = %v", err)
+	}
+	if len(stale) != 1 || stale[0].Context != "prod" || stale[0].Kind != CredentialToken {
+		t.Errorf("Expected prod's expired token, got %+v", stale)
+	}
+}
+
+func TestFindStaleCredentialsInvalidKubeconfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("contexts: [unclosed"), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	if _, err := FindStaleCredentials([]string{path}, time.Now()); err == nil {
+		t.Error("Expected an error for an unparsable kubeconfig")
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	exp := time.Unix(1700000000, 0)
+	if got, ok := tokenExpiry(testJWT(exp)); !ok || !got.Equal(exp) {
+		t.Errorf("tokenExpiry() = %v, %v, want %v, true", got, ok, exp)
+	}
+
+	noExp := "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"test"}`)) + ".sig"
+	for _, token := range []string{"opaque-token", "a.b.c", noExp} {
+		if _, ok := tokenExpiry(token); ok {
+			t.Errorf("tokenExpiry(%q) should report no expiry", token)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{age: 12*24*time.Hour + 5*time.Hour, want: "12d"},
+		{age: 3*time.Hour + 59*time.Minute, want: "3h"},
+		{age: 42 * time.Minute, want: "42m"},
+	}
+
+	for _, tt := range tests {
+		if got := formatAge(tt.age); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
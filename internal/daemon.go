@@ -3,72 +3,329 @@ package internal
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
+// defaultShutdownTimeout bounds how long Shutdown waits for an in-flight
+// timeout check (including any context switch) to complete
+const defaultShutdownTimeout = 10 * time.Second
+
+// statusSummaryInterval is how often the status summary is refreshed between
+// timeout checks, so widgets pick up context changes promptly
+const statusSummaryInterval = 5 * time.Second
+
+// maxCheckDelay is the longest the daemon sleeps between checks when
+// nothing is due, as a safety net for changes it wasn't told about, such as
+// a kubeconfig edit the watcher missed
+const maxCheckDelay = 10 * time.Minute
+
+// minCheckDelay is the shortest time between scheduled checks
+const minCheckDelay = time.Second
+
+// snoozeDuration is how long the Snooze button on a pending switch
+// notification suppresses timeout switching, like extend 30m
+const snoozeDuration = 30 * time.Minute
+
 // Daemon represents the timeout monitoring daemon
 type Daemon struct {
-	config       *Config
-	stateManager *StateManager
-	switcher     *ContextSwitcher
+	// config and notifier are replaced on reload; access them through
+	// currentConfig and currentNotifier. profile is the profile chosen with
+	// the profile command, and profileConfig config with it applied.
+	configMu      sync.RWMutex
+	config        *Config
+	notifier      *Notifier
+	profile       ActiveProfile
+	profileConfig *Config
+
+	stateManager StateStore
+	switcher     Switcher
+	kubeClient   KubeClient
 	ctx          context.Context
 	cancel       context.CancelFunc
-	logger       *log.Logger
+	logger       *slog.Logger
 	pidFile      *PIDFile
+	degraded     *degradedTracker
+
+	// logLevel filters the default logger, and follows daemon.log_level
+	// across reloads. rootLogger is logger without the daemon's component
+	// field, for the subsystems the daemon starts.
+	logLevel   *slog.LevelVar
+	rootLogger *slog.Logger
+
+	// logFile is the rotating daemon.log_file the default logger writes to,
+	// closed on shutdown
+	logFile *RotatingFile
+
+	// summaryPath is where the status summary for widgets is written
+	summaryPath    string
+	summaryFailing bool
+
+	// heartbeatPath is the liveness file rewritten on every pass through
+	// the main loop; lastCheck and lastCheckErr are the outcome of the last
+	// timeout check it reports, guarded by healthMu
+	heartbeatPath    string
+	heartbeatFailing bool
+	healthMu         sync.Mutex
+	lastCheck        time.Time
+	lastCheckErr     error
+
+	// watchdogs keep the file watchers running, guarded by healthMu
+	watchdogs []*watchdog
+
+	// controlPath is where the daemon listens for control requests from
+	// the CLI; controlListener is set while it is listening
+	controlPath     string
+	controlMu       sync.Mutex
+	controlListener net.Listener
+
+	// activityPath is where the daemon receives activity from the shell
+	// integration; activityConn is set while it is listening, and
+	// pendingActivity holds what has yet to be written to the state file
+	activityPath    string
+	activityConn    *net.UnixConn
+	activityMu      sync.Mutex
+	pendingActivity []receivedActivity
+
+	// noticePath is where the daemon tells the shell integration about a
+	// switch the user hasn't seen yet
+	noticePath string
+
+	// history records switches and detected context changes
+	history *History
+
+	// configPath is the file the configuration is loaded and reloaded from
+	configPath string
+
+	// checkRequested is signaled when something may have changed the next
+	// deadline, such as recorded activity, a config reload, or waking from
+	// sleep, so the main loop checks again instead of sleeping on
+	checkRequested chan struct{}
+
+	// timeoutCheckAt is when the current context's timeout policy needs
+	// checking again, as of the last check; zero if nothing is due
+	timeoutCheckAt time.Time
+
+	// findActiveProcesses lists running Kubernetes tools for the
+	// check_active_kubectl safety option; deferredBy holds the ones
+	// currently deferring a switch, and deferredSince when they started to
+	findActiveProcesses func() ([]ActiveProcess, error)
+	deferredBy          []string
+	deferredSince       time.Time
+
+	// isScreenLocked reads the screen lock state for switch_on_lock
+	isScreenLocked func() (bool, error)
+
+	// staleCredentials holds the expired credentials already logged, so each
+	// is reported once rather than on every scan
+	staleCredentials map[string]bool
+
+	// dryRun logs the switches timeouts would make instead of making them
+	dryRun bool
+
+	// checkInterval overrides timeout.check_interval, across reloads, and
+	// makes the daemon check at least that often; zero keeps the config's
+	checkInterval time.Duration
+
+	// foreground logs to stdout in the console format instead of to
+	// daemon.log_file, and debugLog holds the level at debug whatever
+	// daemon.log_level says
+	foreground bool
+	debugLog   bool
+
+	// checkMu is held for the duration of each timeout check so Shutdown
+	// can wait for an in-flight switch to finish before exiting
+	checkMu         sync.Mutex
+	shutdownTimeout time.Duration
+}
+
+// DaemonOption customizes a daemon created by NewDaemon or NewDaemonWithPIDFile
+type DaemonOption func(*Daemon)
+
+// WithSwitcher makes the daemon read and switch contexts through s instead
+// of running kubectl
+func WithSwitcher(s Switcher) DaemonOption {
+	return func(d *Daemon) {
+		d.switcher = s
+	}
+}
+
+// WithKubeClient makes the daemon's context switcher read and change the
+// kubeconfig through client instead of the one named by kube_client. It
+// has no effect with WithSwitcher.
+func WithKubeClient(client KubeClient) DaemonOption {
+	return func(d *Daemon) {
+		d.kubeClient = client
+	}
+}
+
+// WithStateStore makes the daemon keep activity state in s instead of the
+// state file at statePath. The status summary is still written next to
+// statePath.
+func WithStateStore(s StateStore) DaemonOption {
+	return func(d *Daemon) {
+		d.stateManager = s
+	}
+}
+
+// WithLogger replaces the daemon's logger, which otherwise writes to stdout
+// at the configured daemon.log_level and daemon.log_format
+func WithLogger(logger *slog.Logger) DaemonOption {
+	return func(d *Daemon) {
+		d.logger = logger
+	}
+}
+
+// WithDryRun makes the daemon log the context switches timeouts, screen
+// locks, and kubeconfig sessions would make without making them. Explicit
+// requests such as switch-now still switch.
+func WithDryRun() DaemonOption {
+	return func(d *Daemon) {
+		d.dryRun = true
+	}
+}
+
+// WithCheckInterval replaces timeout.check_interval, even after a reload,
+// and makes the daemon check at least that often instead of sleeping
+// until the next deadline
+func WithCheckInterval(interval time.Duration) DaemonOption {
+	return func(d *Daemon) {
+		d.checkInterval = interval
+	}
+}
+
+// WithForeground makes the daemon log to stdout in the console format
+// rather than to daemon.log_file, at debug level if debug is set. It has
+// no effect with WithLogger.
+func WithForeground(debug bool) DaemonOption {
+	return func(d *Daemon) {
+		d.foreground = true
+		d.debugLog = debug
+	}
 }
 
 // NewDaemon creates a new daemon instance
-func NewDaemon(configPath string, statePath string) (*Daemon, error) {
-	return NewDaemonWithPIDFile(configPath, statePath, nil)
+func NewDaemon(configPath string, statePath string, opts ...DaemonOption) (*Daemon, error) {
+	return NewDaemonWithPIDFile(configPath, statePath, nil, opts...)
 }
 
 // NewDaemonWithPIDFile creates a new daemon instance with a custom PID file
 // If pidFile is nil, uses the default PID file location
-func NewDaemonWithPIDFile(configPath string, statePath string, pidFile *PIDFile) (*Daemon, error) {
+func NewDaemonWithPIDFile(configPath string, statePath string, pidFile *PIDFile, opts ...DaemonOption) (*Daemon, error) {
 	// Load configuration
 	config, err := LoadConfig(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Create state manager
-	sm, err := NewStateManager(statePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create state manager: %w", err)
-	}
-
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Create logger
-	logger := log.New(os.Stdout, "[kubectx-timeout] ", log.LstdFlags)
-
-	// Create context switcher
-	switcher := NewContextSwitcher(logger)
-
 	// Create PID file manager if not provided
 	if pidFile == nil {
 		pidFile = NewPIDFile()
 	}
 
 	daemon := &Daemon{
-		config:       config,
-		stateManager: sm,
-		switcher:     switcher,
-		ctx:          ctx,
-		cancel:       cancel,
-		logger:       logger,
-		pidFile:      pidFile,
+		config:         config,
+		ctx:            ctx,
+		cancel:         cancel,
+		logLevel:       new(slog.LevelVar),
+		pidFile:        pidFile,
+		degraded:       newDegradedTracker(degradedRenotifyInterval),
+		summaryPath:    StatusSummaryPathForState(statePath),
+		heartbeatPath:  HeartbeatPathForState(statePath),
+		controlPath:    ControlSocketPathForState(statePath),
+		activityPath:   ActivitySocketPathForState(statePath),
+		noticePath:     SwitchNoticePathForState(statePath),
+		history:        NewHistory(HistoryPathForState(statePath)),
+		configPath:     configPath,
+		checkRequested: make(chan struct{}, 1),
+
+		findActiveProcesses: FindActiveKubeProcesses,
+		isScreenLocked:      ScreenLocked,
+
+		shutdownTimeout: defaultShutdownTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(daemon)
+	}
+	daemon.applyOverrides(config)
+	daemon.notifier = daemon.newNotifier(config)
+	SetKubectlTimeout(config.KubectlTimeout)
+
+	// Create state manager unless one was injected
+	stateDir := filepath.Dir(statePath)
+	if daemon.stateManager == nil {
+		sm, err := NewStateManager(statePath)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create state manager: %w", err)
+		}
+		stateDir = filepath.Dir(sm.path)
+		daemon.stateManager = sm
+		daemon.summaryPath = StatusSummaryPathForState(sm.path)
+		daemon.heartbeatPath = HeartbeatPathForState(sm.path)
+		daemon.controlPath = ControlSocketPathForState(sm.path)
+		daemon.activityPath = ActivitySocketPathForState(sm.path)
+		daemon.noticePath = SwitchNoticePathForState(sm.path)
+		daemon.history = NewHistory(HistoryPathForState(sm.path))
+
+		// Encrypt the state file at rest if configured. An injected store
+		// is the embedder's to protect.
+		if key, err := stateKeyForConfig(config); err != nil {
+			cancel()
+			return nil, err
+		} else if key != nil {
+			if err := sm.SetEncryptionKey(key); err != nil {
+				cancel()
+				return nil, err
+			}
+			if err := daemon.history.SetEncryptionKey(key); err != nil {
+				cancel()
+				return nil, err
+			}
+		}
+	}
+
+	// Log to the configured file unless a logger was injected
+	var logFileErr error
+	if daemon.logger == nil {
+		logFileErr = daemon.openLog(config, stateDir)
 	}
 
+	// Create context switcher unless one was injected
+	if daemon.switcher == nil {
+		if daemon.kubeClient == nil {
+			daemon.kubeClient = NewKubeClient(config.KubeClient, "")
+		}
+		daemon.switcher = NewContextSwitcherWithClient(daemon.logger, daemon.kubeClient)
+	}
+	if rc, ok := daemon.switcher.(RetryConfigurer); ok {
+		rc.SetRetryPolicy(config.Switcher)
+	}
+	daemon.rootLogger = daemon.logger
+	daemon.logger = daemon.logger.With("component", "daemon")
+	if logFileErr != nil {
+		daemon.logger.Warn("Failed to open log file, logging to stdout instead", "error", logFileErr)
+	}
+
+	daemon.loadProfile()
+
 	// Check if context changed while daemon was down
 	// If so, record fresh activity to prevent immediate timeout
 	if err := daemon.checkContextChangeOnStartup(); err != nil {
-		logger.Printf("Warning: failed to check context change on startup: %v", err)
+		daemon.logger.Warn("Failed to check context change on startup", "error", err)
 		// Don't fail daemon creation, just log warning
 	}
 
@@ -79,18 +336,18 @@ func NewDaemonWithPIDFile(configPath string, statePath string, pidFile *PIDFile)
 // immediate timeout due to stale timestamps while the daemon was not running
 func (d *Daemon) checkContextChangeOnStartup() error {
 	// Get current context
-	currentContext, err := GetCurrentContext()
+	currentContext, err := d.switcher.CurrentContext()
 	if err != nil {
 		// If we can't get current context, skip this check
 		return nil
 	}
 
 	// Get last recorded context and timestamp from state
-	lastActivity, lastContext, err := d.stateManager.GetLastActivity()
+	lastActivity, lastContext, err := LastActivity(d.stateManager)
 	if err != nil {
 		// If we can't load state, record fresh activity
-		d.logger.Printf("No previous state found, recording initial activity for context '%s'", currentContext)
-		if err := d.stateManager.RecordActivity(currentContext); err != nil {
+		d.logger.Info("No previous state found, recording initial activity", "context", currentContext)
+		if err := RecordActivity(d.stateManager, currentContext); err != nil {
 			return fmt.Errorf("failed to record activity: %w", err)
 		}
 		return nil
@@ -98,8 +355,8 @@ func (d *Daemon) checkContextChangeOnStartup() error {
 
 	// Check for zero/uninitialized timestamp (first run or corrupted state)
 	if lastActivity.IsZero() {
-		d.logger.Printf("No previous activity timestamp found, recording initial activity for context '%s'", currentContext)
-		if err := d.stateManager.RecordActivity(currentContext); err != nil {
+		d.logger.Info("No previous activity timestamp found, recording initial activity", "context", currentContext)
+		if err := RecordActivity(d.stateManager, currentContext); err != nil {
 			return fmt.Errorf("failed to record activity: %w", err)
 		}
 		return nil
@@ -107,9 +364,15 @@ func (d *Daemon) checkContextChangeOnStartup() error {
 
 	// Check if context changed while daemon was down
 	if lastContext != "" && lastContext != currentContext {
-		d.logger.Printf("Context changed from '%s' to '%s' while daemon was down, resetting activity timer",
-			lastContext, currentContext)
-		if err := d.stateManager.RecordActivity(currentContext); err != nil {
+		d.logger.Info("Context changed while daemon was down, resetting activity timer",
+			"from", lastContext, "context", currentContext)
+		d.recordHistory(HistoryEvent{
+			Type:        HistoryContextChange,
+			Context:     currentContext,
+			FromContext: lastContext,
+			Reason:      "changed while the daemon was stopped",
+		})
+		if err := RecordActivity(d.stateManager, currentContext); err != nil {
 			return fmt.Errorf("failed to record activity: %w", err)
 		}
 		return nil
@@ -117,12 +380,12 @@ func (d *Daemon) checkContextChangeOnStartup() error {
 
 	// Check if the last activity timestamp is stale (older than timeout)
 	// This prevents immediate timeout when daemon restarts after being down for a while
-	timeout := d.config.GetTimeoutForContext(currentContext)
+	timeout := d.currentConfig().GetTimeoutForContext(currentContext)
 	timeSinceActivity := time.Since(lastActivity)
 	if timeSinceActivity > timeout {
-		d.logger.Printf("Daemon was down for %v (longer than timeout %v), resetting activity timer for context '%s'",
-			timeSinceActivity.Round(time.Second), timeout, currentContext)
-		if err := d.stateManager.RecordActivity(currentContext); err != nil {
+		d.logger.Info("Daemon was down longer than the timeout, resetting activity timer",
+			"context", currentContext, "down_for", timeSinceActivity.Round(time.Second), "timeout", timeout)
+		if err := RecordActivity(d.stateManager, currentContext); err != nil {
 			return fmt.Errorf("failed to record activity: %w", err)
 		}
 	}
@@ -132,163 +395,1238 @@ func (d *Daemon) checkContextChangeOnStartup() error {
 
 // Run starts the daemon main loop
 func (d *Daemon) Run() error {
-	if !d.config.Daemon.Enabled {
-		d.logger.Println("Daemon is disabled in configuration")
+	config := d.currentConfig()
+	if !config.Daemon.Enabled {
+		d.logger.Info("Daemon is disabled in configuration")
 		return nil
 	}
 
+	// Setup signal handling for graceful shutdown and config reload before
+	// acquiring the PID file, so a signal during startup still reaches the
+	// main loop and the PID file is cleaned up
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, daemonSignals...)
+	defer signal.Stop(sigChan)
+
 	// Acquire PID file to ensure single instance
 	if err := d.pidFile.Acquire(); err != nil {
 		return fmt.Errorf("failed to acquire PID file: %w", err)
 	}
-	// Ensure PID file is released on exit
+	// Ensure PID file is released on exit (a no-op if Shutdown already released it)
 	defer d.pidFile.Release()
 
-	d.logger.Printf("Starting kubectx-timeout daemon (PID: %d, check interval: %v, default timeout: %v)",
-		os.Getpid(),
-		d.config.Timeout.CheckInterval,
-		d.config.Timeout.Default)
+	// Accept requests from the CLI; without the socket the CLI falls back to
+	// editing the state file and signaling the daemon
+	if err := d.listenControl(); err != nil {
+		d.logger.Warn("Control socket unavailable", "error", err)
+	}
+	defer d.closeControl()
+
+	// Take activity from the shell integration, which otherwise writes the
+	// state file on every command
+	if err := d.listenActivity(); err != nil {
+		d.logger.Warn("Activity socket unavailable", "error", err)
+	}
+	defer d.closeActivity()
 
-	// Create ticker for periodic checks
-	ticker := time.NewTicker(d.config.Timeout.CheckInterval)
-	defer ticker.Stop()
+	d.logger.Info("Starting kubectx-timeout daemon",
+		"pid", os.Getpid(),
+		"check_interval", config.Timeout.CheckInterval,
+		"default_timeout", config.Timeout.Default)
+	if d.dryRun {
+		d.logger.Warn("Dry run: context switches are logged, not made")
+	}
 
-	// Setup signal handling for graceful shutdown and config reload
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	// Encrypt state and history written before state.encrypt was turned
+	// on, and keep the history log within its retention
+	d.sealStoredData()
+	d.compactHistory(config)
+
+	// Check right away, then sleep until the next deadline unless a check
+	// is requested sooner
+	checkTimer := time.NewTimer(0)
+	defer checkTimer.Stop()
+	nextCheck := time.Now()
+
+	// Publish the status summary right away, then keep it fresh
+	d.refreshStatusSummary()
+	summaryTicker := time.NewTicker(statusSummaryInterval)
+	defer summaryTicker.Stop()
+
+	// Warn about expired kubeconfig credentials now and then periodically
+	d.scanCredentials()
+	credentialTicker := time.NewTicker(credentialScanInterval)
+	defer credentialTicker.Stop()
 
 	// Start kubeconfig file watcher in separate goroutine
 	// This provides backup detection for context switches from any tool
-	watcher, err := NewKubeconfigWatcher(d.stateManager, d.logger, d.ctx)
+	watcher, err := NewKubeconfigWatcher(d.stateManager, d.rootLogger, d.ctx)
 	if err != nil {
-		d.logger.Printf("Warning: failed to create kubeconfig watcher: %v", err)
+		d.logger.Warn("Failed to create kubeconfig watcher", "error", err)
 		// Don't fail daemon startup, just log warning and continue without file monitoring
 	} else {
-		go watcher.Watch()
+		watcher.history = d.history
+		go d.supervise("kubeconfig", watcher.Watch)
 	}
 
-	// Main event loop
+	// Watch the kubeconfig files listed under kubeconfigs as well, each
+	// timed out on its own settings
+	listed := listedKubeconfigs(config)
+	if len(listed) < len(config.ManagedKubeconfigs()) {
+		d.logger.Warn("The daemon's own kubeconfig is listed under kubeconfigs; the top-level settings apply to it")
+	}
+	if sessionSwitcher, ok := d.switcher.(KubeconfigSwitcher); ok && len(listed) > 0 {
+		go d.supervise("kubeconfigs", func() error { return d.watchKubeconfigs(sessionSwitcher, listed) })
+	}
+
+	// Apply edits to the config file without waiting for SIGHUP
+	go d.supervise("config", d.watchConfigFile)
+
+	// Check again when activity is recorded
+	go d.supervise("state", d.watchStateFile)
+
+	// Switch as soon as the screen is locked, if enabled
+	go d.watchScreenLock()
+
+	// Apply the on_wake policy when the system wakes from sleep
+	go d.watchSleep()
+
+	// Main event loop, beating the heartbeat on each pass; the summary
+	// ticker makes one at least every statusSummaryInterval
 	for {
+		d.beat()
 		select {
 		case <-d.ctx.Done():
-			d.logger.Println("Daemon context canceled, shutting down...")
+			d.logger.Info("Daemon context canceled, shutting down")
 			return nil
 
 		case sig := <-sigChan:
 			switch sig {
 			case syscall.SIGINT, syscall.SIGTERM:
-				d.logger.Printf("Received %v signal, shutting down gracefully...", sig)
+				d.logger.Info("Received signal, shutting down gracefully", "signal", sig.String())
 				d.Shutdown()
 				return nil
 
-			case syscall.SIGHUP:
-				d.logger.Println("Received SIGHUP signal, reloading configuration...")
-				if err := d.ReloadConfig(); err != nil {
-					d.logger.Printf("Failed to reload config: %v", err)
+			case reloadSignal:
+				d.logger.Info("Received SIGHUP signal, reloading configuration")
+				if err := d.reload(); err != nil {
+					d.logger.Error("Failed to reload config", "error", err)
 				} else {
-					d.logger.Println("Configuration reloaded successfully")
+					d.logger.Info("Configuration reloaded successfully")
 				}
+
+			case dumpStateSignal:
+				d.dumpState(nextCheck)
+
+			case checkNowSignal:
+				d.logger.Info("Received SIGUSR2 signal, checking the timeout now")
+				nextCheck = time.Now()
+				checkTimer.Reset(0)
+			}
+
+		case <-d.checkRequested:
+			nextCheck = time.Now()
+			checkTimer.Reset(0)
+
+		case <-checkTimer.C:
+			nextCheck = d.runCheck()
+			d.logger.Debug("Next check scheduled", "at", nextCheck.Format(time.RFC3339), "in", time.Until(nextCheck).Round(time.Second))
+			checkTimer.Reset(time.Until(nextCheck))
+
+		case <-summaryTicker.C:
+			d.refreshStatusSummary()
+
+		case <-credentialTicker.C:
+			d.scanCredentials()
+		}
+	}
+}
+
+// dumpState logs, in one record, what the daemon is working from: the
+// current policy decision and its inputs, when it checks next, and the
+// effective configuration, with secrets redacted. It's the SIGUSR1 handler.
+func (d *Daemon) dumpState(nextCheck time.Time) {
+	config := d.currentConfig()
+	now := time.Now()
+	attrs := []any{
+		"pid", os.Getpid(),
+		"next_check", nextCheck.Format(time.RFC3339),
+		"next_check_in", nextCheck.Sub(now).Round(time.Second),
+		"dry_run", d.dryRun,
+	}
+
+	if in, err := GatherPolicyInputs(config, d.stateManager, d.switcher, now); err != nil {
+		attrs = append(attrs, "policy_error", err)
+	} else {
+		decision := EvaluatePolicy(in)
+		policy := []any{
+			"action", decision.Action,
+			"reasons", strings.Join(decision.Reasons, "; "),
+			"context", in.CurrentContext,
+			"default_context", in.DefaultContext,
+			"timeout", in.Timeout,
+		}
+		for _, t := range []struct {
+			key  string
+			time time.Time
+		}{
+			{"last_activity", in.LastActivity},
+			{"switch_at", decision.SwitchAt},
+			{"extended_until", in.ExtendedUntil},
+			{"paused_until", in.PausedUntil},
+			{"pending_at", in.Pending.At},
+		} {
+			if !t.time.IsZero() {
+				policy = append(policy, t.key, t.time.Format(time.RFC3339))
 			}
+		}
+		if len(d.deferredBy) > 0 {
+			policy = append(policy, "deferred_by", strings.Join(d.deferredBy, ", "))
+		}
+		attrs = append(attrs, slog.Group("policy", policy...))
+	}
 
-		case <-ticker.C:
-			// Periodic timeout check
-			if err := d.checkTimeout(); err != nil {
-				d.logger.Printf("Error checking timeout: %v", err)
+	if keys, err := flattenConfig(config); err != nil {
+		attrs = append(attrs, "config_error", err)
+	} else {
+		names := slices.Sorted(maps.Keys(keys))
+		settings := make([]any, 0, 2*len(names))
+		for _, key := range names {
+			value := keys[key]
+			if configSecretKeys[key] {
+				value = redactSecret(value)
 			}
+			settings = append(settings, key, value)
+		}
+		attrs = append(attrs, slog.Group("config", settings...))
+	}
+
+	d.logger.Info("State dump", attrs...)
+}
+
+// runCheck performs a single timeout check unless shutdown has begun, and
+// returns when the next one is due (the zero time after shutdown). The check runs to completion even if
+// Shutdown is called meanwhile, so a context switch is never interrupted
+// halfway.
+func (d *Daemon) runCheck() time.Time {
+	d.checkMu.Lock()
+	defer d.checkMu.Unlock()
+
+	if d.ctx.Err() != nil {
+		return time.Time{}
+	}
+
+	// Activity received but not yet written may put off a switch
+	d.flushActivityLocked()
+
+	config := d.currentConfig()
+	d.handleCheckResult(d.checkTimeout())
+	d.checkSessions(config, time.Now())
+	d.publishStatusSummary(false)
+	return d.nextCheckAt(config, time.Now())
+}
+
+// requestCheck asks the main loop to check now rather than at the next
+// deadline. It never blocks: one pending request is enough.
+func (d *Daemon) requestCheck() {
+	select {
+	case d.checkRequested <- struct{}{}:
+	default:
+	}
+}
+
+// nextCheckAt returns when the daemon should check next: the earliest of
+// the current context's and the kubeconfig sessions' next deadlines, and
+// no later than maxCheckDelay from now as a safety net. Without a state
+// file to watch for activity, with a work-hours schedule changing the
+// timeouts through the day, or with WithCheckInterval, it checks at least
+// every check_interval.
+func (d *Daemon) nextCheckAt(config *Config, now time.Time) time.Time {
+	latest := now.Add(maxCheckDelay)
+	if _, ok := d.stateManager.(*StateManager); !ok || config.Schedule.Enabled() || d.checkInterval > 0 {
+		latest = now.Add(config.Timeout.CheckInterval)
+	}
+
+	next := latest
+	for _, at := range []time.Time{d.timeoutCheckAt, d.nextSessionCheck(config, now)} {
+		if !at.IsZero() && at.Before(next) {
+			next = at
+		}
+	}
+
+	// A deadline that has just passed mustn't spin the loop
+	if earliest := now.Add(minCheckDelay); next.Before(earliest) {
+		next = earliest
+	}
+	return next
+}
+
+// watchStateFile requests a check whenever the state file changes, which
+// is how recorded activity and context changes reach a daemon sleeping
+// until its next deadline
+func (d *Daemon) watchStateFile() error {
+	sm, ok := d.stateManager.(*StateManager)
+	if !ok {
+		// Another store can't be watched; nextCheckAt polls instead
+		<-d.ctx.Done()
+		return nil
+	}
+
+	watch := &fileWatch{
+		paths:  []string{filepath.Clean(sm.path)},
+		label:  "State",
+		logger: d.logger,
+		ctx:    d.ctx,
+		onChange: func() error {
+			d.requestCheck()
+			return nil
+		},
+	}
+	return watch.run()
+}
+
+// scanCredentials logs expired client certificates and tokens in the
+// kubeconfig. Each one is logged when first found, not on every scan.
+func (d *Daemon) scanCredentials() {
+	now := time.Now()
+	stale, err := FindStaleCredentials(GetKubeconfigPaths(), now)
+	if err != nil {
+		d.logger.Warn("Failed to check kubeconfig credentials", "error", err)
+		return
+	}
+
+	found := make(map[string]bool, len(stale))
+	for _, cred := range stale {
+		key := cred.Context + "/" + cred.Kind
+		found[key] = true
+		if !d.staleCredentials[key] {
+			d.logger.Warn(cred.Describe(now)+" (remove it with: kubectx-timeout prune-contexts)", "context", cred.Context)
 		}
 	}
+	d.staleCredentials = found
+}
+
+// refreshStatusSummary rewrites the status summary unless shutdown has begun
+func (d *Daemon) refreshStatusSummary() {
+	d.checkMu.Lock()
+	defer d.checkMu.Unlock()
+
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	d.publishStatusSummary(false)
+}
+
+// buildStatusSummary describes the daemon's current state from the state
+// file alone, without running kubectl
+func (d *Daemon) buildStatusSummary(now time.Time) *StatusSummary {
+	config := d.currentConfig()
+	summary := &StatusSummary{
+		UpdatedAt:      now,
+		DefaultContext: config.DefaultContext,
+		DaemonPID:      os.Getpid(),
+	}
+	if profile, ok := d.activeProfile(now); ok {
+		summary.Profile = profile.Name
+		if !profile.Until.IsZero() {
+			profileUntil := profile.Until
+			summary.ProfileUntil = &profileUntil
+		}
+	}
+
+	state, err := d.stateManager.Load()
+	if err != nil {
+		summary.State = SummaryStateDegraded
+		summary.DegradedReason = classifyError(fmt.Errorf("%w: %w", errStateUnavailable, err))
+		return summary
+	}
+
+	summary.Context = state.CurrentContext
+	summary.ContextAlias = config.ContextAlias(state.CurrentContext)
+	if state.CurrentContext != "" {
+		summary.DefaultContext = config.GetDefaultContextFor(state.CurrentContext)
+	}
+	timeout := config.GetTimeoutForContextAt(state.CurrentContext, now)
+	summary.TimeoutSeconds = int64(timeout / time.Second)
+
+	if d.degraded.Degraded() {
+		summary.State = SummaryStateDegraded
+		summary.DegradedReason = d.degraded.Condition()
+		return summary
+	}
+
+	switch {
+	case state.CurrentContext == "" || state.CurrentContext == summary.DefaultContext:
+		summary.State = SummaryStateDefault
+	case config.IsNeverSwitchFrom(state.CurrentContext):
+		summary.State = SummaryStateExempt
+	case now.Before(state.ExtendedUntil):
+		summary.State = SummaryStateExtended
+		extendedUntil := state.ExtendedUntil
+		summary.ExtendedUntil = &extendedUntil
+	case now.Before(state.PausedContexts[state.CurrentContext]):
+		summary.State = SummaryStatePaused
+		pausedUntil := state.PausedContexts[state.CurrentContext]
+		summary.PausedUntil = &pausedUntil
+	default:
+		summary.State = SummaryStateActive
+		deadline := state.LastActivity.Add(timeout)
+		in := PolicyInputs{LastActivity: state.LastActivity, Timeout: timeout, LastWrite: state.LastWriteActivity, WriteTimeout: config.Timeout.WriteCommands}
+		if writeDeadline, ok := in.writeDeadline(); ok {
+			deadline = writeDeadline
+		}
+		if state.LastActivity.IsZero() {
+			// No activity recorded, the next check will switch
+			deadline = now
+		}
+		remaining := int64(deadline.Sub(now) / time.Second)
+		if remaining < 0 {
+			remaining = 0
+		}
+		summary.Deadline = &deadline
+		summary.RemainingSeconds = &remaining
+
+		if remaining == 0 && len(d.deferredBy) > 0 {
+			summary.State = SummaryStateDeferred
+			summary.DeferredBy = d.deferredBy
+		} else if remaining == 0 && state.PendingSwitchFrom == state.CurrentContext && state.PendingSwitchTo != "" {
+			// Waiting out the grace period; the deadline is the switch itself
+			summary.State = SummaryStatePending
+			switchAt := state.PendingSwitchAt
+			remaining = int64(switchAt.Sub(now) / time.Second)
+			if remaining < 0 {
+				remaining = 0
+			}
+			summary.Deadline = &switchAt
+			summary.RemainingSeconds = &remaining
+		}
+	}
+
+	return summary
+}
+
+// publishStatusSummary writes the status summary, logging only when writing
+// starts or stops failing so a persistent problem doesn't flood the log
+func (d *Daemon) publishStatusSummary(stopped bool) {
+	if d.summaryPath == "" {
+		return
+	}
+
+	summary := d.buildStatusSummary(time.Now())
+	if stopped {
+		summary.State = SummaryStateStopped
+		summary.Deadline = nil
+		summary.RemainingSeconds = nil
+		summary.ExtendedUntil = nil
+		summary.PausedUntil = nil
+		summary.DeferredBy = nil
+		summary.DegradedReason = ""
+	}
+	// Prompts read the summary without the key, so keep the names out of it
+	if d.currentConfig().State.Encrypt {
+		summary.Redact()
+	}
+
+	if err := WriteStatusSummary(d.summaryPath, summary); err != nil {
+		if !d.summaryFailing {
+			d.logger.Warn("Failed to write status summary", "error", err)
+		}
+		d.summaryFailing = true
+		return
+	}
+
+	if d.summaryFailing {
+		d.logger.Info("Status summary writes recovered")
+	}
+	d.summaryFailing = false
 }
 
 // checkTimeout checks if timeout has been exceeded and switches context if needed
 func (d *Daemon) checkTimeout() error {
-	// Get time since last activity
-	timeSince, err := d.stateManager.TimeSinceLastActivity()
-	if err != nil {
-		return fmt.Errorf("failed to get time since last activity: %w", err)
+	// Use one configuration for the whole check, even if it's reloaded meanwhile
+	config := d.currentConfig()
+
+	// A pending switch only survives checks that are still waiting on it
+	keepPending := false
+	defer func() {
+		if !keepPending {
+			d.clearPendingSwitch()
+		}
+	}()
+
+	// Until a decision is made, retry after check_interval
+	d.timeoutCheckAt = time.Now().Add(config.Timeout.CheckInterval)
+
+	// A context changed behind our back starts its own clock
+	if err := d.detectExternalSwitch(); err != nil {
+		return err
 	}
 
-	// Get current context
-	currentContext, err := GetCurrentContext()
+	in, err := GatherPolicyInputs(config, d.stateManager, d.switcher, time.Now())
 	if err != nil {
-		// If we can't get current context, log and continue
-		d.logger.Printf("Warning: failed to get current context: %v", err)
-		return nil
+		return err
+	}
+
+	decision := EvaluatePolicy(in)
+	defer func() { d.timeoutCheckAt = nextTimeoutCheck(config, in, decision) }()
+	if in.NeverSwitchFrom && in.CurrentContext != in.DefaultContext {
+		d.logger.Debug("Current context is in never_switch_from list, skipping timeout check", "context", in.CurrentContext)
+	}
+
+	// Don't switch underneath running kubectl sessions. Listing processes is
+	// costly, so it's only done once a switch is due.
+	if decision.Due() && config.Safety.CheckActiveKubectl {
+		in.ActiveProcesses = d.activeProcesses(in.CurrentContext)
+		in.DeferredSince = d.deferredSince
+		decision = EvaluatePolicy(in)
+	} else {
+		d.deferredBy = nil
 	}
 
-	// Check if context is in never_switch_from list
-	for _, ctx := range d.config.Safety.NeverSwitchFrom {
-		if ctx == currentContext {
-			d.logger.Printf("Current context '%s' is in never_switch_from list, skipping timeout check", currentContext)
+	switch decision.Action {
+	case PolicyActionDefer:
+		keepPending = true
+
+	case PolicyActionGrace:
+		// Give the user a chance to cancel the switch
+		keepPending = true
+		if d.skipSwitch("Dry run: would start the grace period", in, "switch_at", decision.SwitchAt.Format(time.RFC3339)) {
 			return nil
 		}
+		if !in.pendingMatches() {
+			return d.startGracePeriod(config, in, decision)
+		}
+
+	case PolicyActionSwitch:
+		d.logger.Info("Timeout exceeded",
+			"context", in.CurrentContext, "idle", in.Idle().Round(time.Second), "timeout", in.Timeout)
+		if len(in.ActiveProcesses) > 0 {
+			d.logger.Warn("Switching despite running Kubernetes tools, which reached max_defer",
+				"processes", strings.Join(in.ActiveProcesses, ", "), "max_defer", in.MaxDefer)
+		}
+
+		reason := timeoutReason(in)
+		if d.skipSwitch("Dry run: would switch context", in, "reason", reason) {
+			return nil
+		}
+
+		// With a grace period, on_timeout already ran when it started
+		if in.GracePeriod == 0 {
+			d.runHooks(config, HookOnTimeout, SwitchEvent{FromContext: in.CurrentContext, ToContext: in.DefaultContext, Reason: reason})
+		}
+
+		// Trigger context switch
+		if err := d.switchContext(config, in.CurrentContext, in.DefaultContext, reason); err != nil {
+			return fmt.Errorf("%w: %w", errSwitchFailed, err)
+		}
+
+		// The switch happened, so it's no longer pending (not canceled)
+		if err := ClearPendingSwitch(d.stateManager); err != nil {
+			d.logger.Warn("Failed to clear pending switch", "error", err)
+		}
+		d.recordLastSwitch(config, in.CurrentContext, in.DefaultContext)
+
+		d.notifySwitch(SwitchEvent{
+			FromContext: in.CurrentContext,
+			ToContext:   in.DefaultContext,
+			Reason:      reason,
+		}, d.switchBackAction(in.CurrentContext))
 	}
 
-	// If current context is already the default, no need to switch
-	if currentContext == d.config.DefaultContext {
-		return nil
+	return nil
+}
+
+// skipSwitch logs the switch a dry run would make from in.CurrentContext to
+// in.DefaultContext, and reports whether it is a dry run
+func (d *Daemon) skipSwitch(message string, in PolicyInputs, attrs ...any) bool {
+	if !d.dryRun {
+		return false
 	}
+	d.logger.Info(message, append([]any{"context", in.CurrentContext, "to", in.DefaultContext}, attrs...)...)
+	return true
+}
 
-	// Get timeout for current context
-	timeout := d.config.GetTimeoutForContext(currentContext)
+// nextTimeoutCheck returns when a timeout policy decision needs checking
+// again, or the zero time if only new activity or a change in state or
+// config can alter it
+func nextTimeoutCheck(config *Config, in PolicyInputs, decision PolicyDecision) time.Time {
+	switch decision.Action {
+	case PolicyActionWait, PolicyActionGrace:
+		return decision.SwitchAt
 
-	// Check if timeout exceeded
-	if timeSince >= timeout {
-		d.logger.Printf("Timeout exceeded for context '%s' (inactive for %v, timeout is %v)",
-			currentContext, timeSince.Round(time.Second), timeout)
+	case PolicyActionNone:
+		// Extensions and pauses run out on their own
+		for _, until := range []time.Time{in.ExtendedUntil, in.PausedUntil} {
+			if until.After(in.Now) {
+				return until
+			}
+		}
+		return time.Time{}
 
-		// Trigger context switch
-		if err := d.switchContext(currentContext, d.config.DefaultContext); err != nil {
-			return fmt.Errorf("failed to switch context: %w", err)
+	default:
+		// Running tools are polled for, and a failed switch is retried
+		next := in.Now.Add(config.Timeout.CheckInterval)
+		if !decision.SwitchAt.IsZero() && decision.SwitchAt.Before(next) {
+			next = decision.SwitchAt
 		}
+		return next
 	}
+}
 
+// timeoutReason describes why the timeout elapsed, for history and hooks
+func timeoutReason(in PolicyInputs) string {
+	if in.LastActivity.IsZero() {
+		return "no activity recorded"
+	}
+	return fmt.Sprintf("inactive for %v", in.Idle().Round(time.Second))
+}
+
+// startGracePeriod records the switch the policy wants to make once the grace
+// period ends, and tells the user how to cancel it
+func (d *Daemon) startGracePeriod(config *Config, in PolicyInputs, decision PolicyDecision) error {
+	pending := PendingSwitch{
+		From: in.CurrentContext,
+		To:   in.DefaultContext,
+		At:   decision.SwitchAt,
+	}
+	if err := SetPendingSwitch(d.stateManager, pending); err != nil {
+		return fmt.Errorf("%w: failed to record pending switch: %w", errStateUnavailable, err)
+	}
+
+	d.logger.Info("Timeout exceeded, switching after the grace period unless canceled",
+		"context", pending.From, "to", pending.To, "grace_period", in.GracePeriod)
+	d.runHooks(config, HookOnTimeout, SwitchEvent{FromContext: pending.From, ToContext: pending.To, Reason: timeoutReason(in)})
+	d.notifyPendingSwitch(pending, in.GracePeriod)
 	return nil
 }
 
-// switchContext switches from one context to another
-func (d *Daemon) switchContext(fromContext, toContext string) error {
+// clearPendingSwitch forgets a pending switch that no longer applies, for
+// example because kubectl was used again or the context changed
+func (d *Daemon) clearPendingSwitch() {
+	if d.stateManager == nil {
+		return
+	}
+
+	state, err := d.stateManager.Load()
+	if err != nil {
+		return
+	}
+	pending := state.PendingSwitch()
+	if pending.IsZero() {
+		return
+	}
+
+	if err := ClearPendingSwitch(d.stateManager); err != nil {
+		d.logger.Warn("Failed to clear pending switch", "error", err)
+		return
+	}
+	d.logger.Info("Pending switch canceled", "context", pending.From, "to", pending.To)
+}
+
+// activeProcesses returns the running Kubernetes tools that defer a due
+// switch away from currentContext; tools that named another context with
+// --context don't. What it found is logged when it changes, rather than on
+// every check.
+func (d *Daemon) activeProcesses(currentContext string) []string {
+	findActiveProcesses := d.findActiveProcesses
+	if findActiveProcesses == nil {
+		findActiveProcesses = FindActiveKubeProcesses
+	}
+
+	processes, err := findActiveProcesses()
+	if err != nil {
+		// Never let a broken process listing disable the timeout entirely
+		d.logger.Warn("Failed to check for active kubectl processes, switching anyway", "error", err)
+		d.deferredBy = nil
+		return nil
+	}
+
+	processes = slices.DeleteFunc(processes, func(p ActiveProcess) bool { return !p.UsesContext(currentContext) })
+	if len(processes) == 0 {
+		if len(d.deferredBy) > 0 {
+			d.logger.Info("No Kubernetes tools running anymore, proceeding with deferred context switch")
+		}
+		d.deferredBy = nil
+		return nil
+	}
+
+	found := make([]string, len(processes))
+	for i, p := range processes {
+		found[i] = p.String()
+	}
+	if strings.Join(found, ", ") != strings.Join(d.deferredBy, ", ") {
+		d.logger.Info("Timeout exceeded, deferring context switch while Kubernetes tools are running",
+			"processes", strings.Join(found, ", "))
+	}
+	if d.deferredBy == nil {
+		d.deferredSince = time.Now()
+	}
+	d.deferredBy = found
+	return found
+}
+
+// handleCheckResult reports the outcome of a timeout check, deduplicating
+// repeated failures so a persistent problem doesn't flood the log
+func (d *Daemon) handleCheckResult(err error) {
+	now := time.Now()
+
+	d.healthMu.Lock()
+	d.lastCheck, d.lastCheckErr = now, err
+	d.healthMu.Unlock()
+
+	if err == nil {
+		if msg, ok := d.degraded.Resolve(now); ok {
+			d.notify(msg)
+		}
+		return
+	}
+
+	if msg, ok := d.degraded.Report(err, now); ok {
+		d.notify(msg)
+	}
+}
+
+// beat rewrites the heartbeat file for healthcheck, logging only the first
+// of a run of failures
+func (d *Daemon) beat() {
+	d.healthMu.Lock()
+	heartbeat := &Heartbeat{PID: os.Getpid(), UpdatedAt: time.Now(), LastCheck: d.lastCheck}
+	if d.lastCheckErr != nil {
+		heartbeat.LastCheckError = d.lastCheckErr.Error()
+	}
+	d.healthMu.Unlock()
+	heartbeat.WatcherProblems = d.watcherProblems()
+
+	if err := WriteHeartbeat(d.heartbeatPath, heartbeat); err != nil {
+		if !d.heartbeatFailing {
+			d.logger.Warn("Failed to write heartbeat", "error", err)
+		}
+		d.heartbeatFailing = true
+		return
+	}
+	d.heartbeatFailing = false
+}
+
+// notify surfaces an important daemon condition to the user
+func (d *Daemon) notify(message string) {
+	d.logger.Warn(message)
+
+	notifier := d.currentNotifier()
+	if notifier == nil {
+		return
+	}
+	if err := notifier.Notify(message); err != nil {
+		d.logger.Warn("Failed to send notification", "error", err)
+	}
+}
+
+// notifySwitch tells the user that the daemon switched their context,
+// offering actions as notification buttons
+func (d *Daemon) notifySwitch(event SwitchEvent, actions ...NotificationAction) {
+	notifier := d.currentNotifier()
+	if notifier == nil {
+		return
+	}
+	if err := notifier.NotifySwitch(event, actions...); err != nil {
+		d.logger.Warn("Failed to send context switch notification", "error", err)
+	}
+}
+
+// notifyPendingSwitch tells the user a switch is coming and how to cancel it.
+// On macOS, clicking the notification cancels the switch when
+// terminal-notifier or alerter is installed, and with alerter a button
+// snoozes timeout switching instead.
+func (d *Daemon) notifyPendingSwitch(pending PendingSwitch, gracePeriod time.Duration) {
+	notifier := d.currentNotifier()
+	if notifier == nil {
+		return
+	}
+
+	message := fmt.Sprintf("Switching kubectl context from '%s' to '%s' in %v. Run 'kubectx-timeout cancel-switch' to stay.",
+		pending.From, pending.To, gracePeriod)
+	err := notifier.Send(Notification{
+		Message:      message,
+		ClickCommand: cancelSwitchCommand(),
+		Actions: []NotificationAction{d.controlAction("Snooze 30m",
+			ControlRequest{Command: ControlPause, Duration: snoozeDuration.String()})},
+	})
+	if err != nil {
+		d.logger.Warn("Failed to send pending switch notification", "error", err)
+	}
+}
+
+// switchBackAction is a notification button that switches back to the
+// context a switch left
+func (d *Daemon) switchBackAction(fromContext string) NotificationAction {
+	return d.controlAction("Switch back", ControlRequest{Command: ControlSwitchBack, Context: fromContext})
+}
+
+// controlAction is a notification button that sends a request to the
+// daemon's control socket, as the CLI would
+func (d *Daemon) controlAction(label string, req ControlRequest) NotificationAction {
+	return NotificationAction{Label: label, Run: func() {
+		d.logger.Info("Notification action", "action", label)
+		if _, err := SendControlRequest(d.controlPath, req); err != nil {
+			d.logger.Warn("Notification action failed", "action", label, "error", err)
+		}
+	}}
+}
+
+// cancelSwitchCommand returns the shell command that cancels a pending
+// switch, using this binary's path when it can be determined
+func cancelSwitchCommand() string {
+	executable, err := os.Executable()
+	if err != nil {
+		return "kubectx-timeout cancel-switch"
+	}
+	return shellQuote(executable) + " cancel-switch"
+}
+
+// switchContext switches from one context to another, recording the reason
+// in the history log
+func (d *Daemon) switchContext(config *Config, fromContext, toContext, reason string) error {
+	event := SwitchEvent{FromContext: fromContext, ToContext: toContext, Reason: reason}
+	d.runHooks(config, HookPreSwitch, event)
+
 	// Use the safe switcher with safety checks
-	if err := d.switcher.SwitchContextSafe(toContext, d.config.Safety.NeverSwitchTo); err != nil {
+	if err := d.switcher.SwitchContextSafe(toContext, config.IsNeverSwitchTo); err != nil {
+		event.Error = err.Error()
+		d.runHooks(config, HookOnSwitchFailure, event)
 		return fmt.Errorf("context switch failed: %w", err)
 	}
 
-	d.logger.Printf("Successfully switched context from '%s' to '%s'", fromContext, toContext)
+	d.logger.Info("Switched context", "from", fromContext, "context", toContext)
 
 	// Record activity in the new context to keep state file in sync
 	// This prevents the daemon from immediately trying to switch again
-	if err := d.stateManager.RecordActivity(toContext); err != nil {
-		d.logger.Printf("Warning: failed to record activity after context switch: %v", err)
+	if err := RecordActivity(d.stateManager, toContext); err != nil {
+		d.logger.Warn("Failed to record activity after context switch", "context", toContext, "error", err)
 		// Don't return error - the switch was successful
 	}
 
+	d.recordHistory(HistoryEvent{
+		Type:        HistorySwitch,
+		Context:     toContext,
+		FromContext: fromContext,
+		Reason:      reason,
+	})
+
+	// Don't leave the abandoned context in a namespace like kube-system
+	d.resetNamespace(config, d.switcher, fromContext)
+
+	// Clear cached details of the cluster we switched away from
+	if config.ShouldClearCache(fromContext) {
+		d.clearContextCache(fromContext, config.CacheCleanup.HTTPCache)
+	}
+	d.revokeCredentials(config, GetKubeconfigPaths(), fromContext)
+
+	d.runHooks(config, HookPostSwitch, event)
 	return nil
 }
 
-// ReloadConfig reloads the daemon configuration
+// recordLastSwitch remembers an automatic switch, so the undo command can
+// reverse it
+func (d *Daemon) recordLastSwitch(config *Config, fromContext, toContext string) {
+	last := LastSwitch{From: fromContext, To: toContext, At: time.Now()}
+	if err := SetLastSwitch(d.stateManager, last); err != nil {
+		d.logger.Warn("Failed to record switch for undo", "error", err)
+	}
+
+	// The shell integration prints the notice before the next command
+	if d.noticePath == "" {
+		return
+	}
+	notice := NewSwitchNotice(last, config.Timeout.UndoWindow)
+	if config.State.Encrypt {
+		// Like the status summary, the notice is read without the key
+		notice.From, notice.To = "", ""
+	}
+	if err := WriteSwitchNotice(d.noticePath, notice); err != nil {
+		d.logger.Warn("Failed to write switch notice", "error", err)
+	}
+}
+
+// resetNamespace sets timeout.reset_namespace on a context the daemon
+// switched away from, if configured. Failures are logged but never affect
+// the switch.
+func (d *Daemon) resetNamespace(config *Config, switcher Switcher, contextName string) {
+	namespace := config.Timeout.ResetNamespace
+	if namespace == "" {
+		return
+	}
+	setter, ok := switcher.(NamespaceSetter)
+	if !ok {
+		return
+	}
+
+	if err := setter.SetContextNamespace(contextName, namespace); err != nil {
+		d.logger.Warn("Failed to reset namespace", "context", contextName, "namespace", namespace, "error", err)
+		return
+	}
+	d.logger.Info("Reset namespace of the context switched away from", "context", contextName, "namespace", namespace)
+}
+
+// runHooks runs the configured commands for a hook. Failures are logged but
+// never stop a switch: moving to the safe context matters more.
+func (d *Daemon) runHooks(config *Config, hook string, event SwitchEvent) {
+	for _, err := range config.Hooks.Run(hook, event) {
+		d.logger.Warn("Hook failed", "hook", hook, "error", err)
+	}
+}
+
+// recordHistory appends an event to the history log. Failures are logged but
+// never affect the daemon.
+func (d *Daemon) recordHistory(event HistoryEvent) {
+	if err := d.history.Append(event); err != nil {
+		d.logger.Warn("Failed to record history", "error", err)
+	}
+}
+
+// sealStoredData rewrites the state file and history log if they hold
+// plaintext while encryption is on. Failures are logged; the next write
+// encrypts the state file anyway.
+func (d *Daemon) sealStoredData() {
+	sm, ok := d.stateManager.(*StateManager)
+	if !ok || sm.cipher == nil {
+		return
+	}
+
+	// #nosec G304 -- path is the daemon's state file
+	if data, err := os.ReadFile(sm.path); err == nil && !isEncrypted(data) {
+		state, err := sm.Load()
+		if err == nil {
+			err = sm.Save(state)
+		}
+		if err != nil {
+			d.logger.Warn("Failed to encrypt state file", "error", err)
+		} else {
+			d.logger.Info("Encrypted state file")
+		}
+	}
+
+	if err := d.history.Reseal(); err != nil {
+		d.logger.Warn("Failed to encrypt history", "error", err)
+	}
+}
+
+// compactHistory prunes the history log to history.max_entries and
+// history.max_age, if either is set. Failures are logged but never affect
+// the daemon.
+func (d *Daemon) compactHistory(config *Config) {
+	if config.History.MaxEntries == 0 && config.History.MaxAge == 0 {
+		return
+	}
+
+	removed, err := d.history.Prune(config.History, time.Now())
+	if err != nil {
+		d.logger.Warn("Failed to compact history", "error", err)
+		return
+	}
+	if removed > 0 {
+		d.logger.Info("Compacted history", "removed", removed)
+	}
+}
+
+// clearContextCache removes kubectl's cached discovery data for a context's
+// cluster. Failures are logged but never affect the switch.
+func (d *Daemon) clearContextCache(contextName string, includeHTTP bool) {
+	server, err := GetContextServer(contextName)
+	if err != nil {
+		d.logger.Warn("Failed to clear kubectl cache", "context", contextName, "error", err)
+		return
+	}
+
+	removed, err := ClearServerCache(GetKubeCacheDir(), server, includeHTTP)
+	for _, dir := range removed {
+		d.logger.Info("Cleared kubectl cache after switching away", "context", contextName, "dir", dir)
+	}
+	if err != nil {
+		d.logger.Warn("Failed to clear kubectl cache", "context", contextName, "error", err)
+	}
+}
+
+// ReloadConfig reloads the daemon configuration from the file it was
+// started with, logging each setting that changed. An invalid or missing
+// file leaves the current configuration in place.
 func (d *Daemon) ReloadConfig() error {
-	// Load new configuration from XDG path
-	config, err := LoadConfig(GetConfigPath())
+	// A missing file would otherwise load the defaults in its place
+	if _, err := os.Stat(d.configPath); err != nil {
+		return MarkFailure(fmt.Errorf("failed to load config: %w", err), ErrConfigInvalid)
+	}
+
+	config, err := LoadConfig(d.configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Update daemon config
-	d.config = config
+	d.logConfigChanges(d.loadedConfig(), config)
+	d.setConfig(config)
+
+	return nil
+}
+
+// logConfigChanges logs each setting a reload changes, such as timeouts,
+// the default context, and the safety lists
+func (d *Daemon) logConfigChanges(before, after *Config) {
+	changes, err := DiffConfigs(before, after)
+	if err != nil {
+		d.logger.Warn("Failed to compare configurations", "error", err)
+		return
+	}
+	if len(changes) == 0 {
+		d.logger.Info("Configuration unchanged")
+		return
+	}
+	for _, change := range changes {
+		d.logger.Info("Configuration changed", "key", change.Key, "from", change.Old, "to", change.New)
+	}
+}
+
+// watchConfigFile reloads the configuration whenever its file changes, until
+// the daemon stops. Edits that don't validate are logged and ignored.
+func (d *Daemon) watchConfigFile() error {
+	// Files the configuration includes are watched too, as they were when
+	// the daemon started, and the organization policy if one is installed
+	paths := []string{filepath.Clean(d.configPath)}
+	if includes, err := ConfigIncludes(d.configPath); err == nil {
+		paths = append(paths, includes...)
+	}
+	if policy := orgPolicyPath(); policyInstalled(policy) {
+		paths = append(paths, policy)
+	}
+
+	watch := &fileWatch{
+		paths:  paths,
+		label:  "Config",
+		logger: d.logger,
+		ctx:    d.ctx,
+		onChange: func() error {
+			d.logger.Info("Config file changed, reloading configuration")
+			if err := d.reload(); err != nil {
+				return fmt.Errorf("keeping the current configuration: %w", err)
+			}
+			d.logger.Info("Configuration reloaded successfully")
+			return nil
+		},
+	}
+	return watch.run()
+}
+
+// reload reloads the configuration and refreshes the status summary, then
+// requests a check, since new timeouts move the deadlines
+func (d *Daemon) reload() error {
+	if err := d.ReloadConfig(); err != nil {
+		return err
+	}
+
+	d.refreshStatusSummary()
+	d.requestCheck()
+
+	return nil
+}
+
+// currentConfig returns the active configuration, with the chosen profile
+// applied until it expires. Callers that read several settings should keep
+// the returned snapshot rather than calling again, so a concurrent reload
+// can't mix old and new values.
+func (d *Daemon) currentConfig() *Config {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	if d.profileConfig != nil && d.profile.ActiveAt(time.Now()) {
+		return d.profileConfig
+	}
+	return d.config
+}
+
+// loadedConfig returns the configuration as loaded, without a profile
+func (d *Daemon) loadedConfig() *Config {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.config
+}
+
+// activeProfile returns the profile applied at now, if any
+func (d *Daemon) activeProfile(now time.Time) (ActiveProfile, bool) {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	if d.profileConfig != nil && d.profile.ActiveAt(now) {
+		return d.profile, true
+	}
+	return ActiveProfile{}, false
+}
 
+// setProfile applies profile to the configuration from now on, or stops
+// applying one if profile is zero. It returns an error wrapping
+// ErrUnknownProfile, and changes nothing, if the profile isn't configured.
+func (d *Daemon) setProfile(profile ActiveProfile) error {
+	d.configMu.Lock()
+	defer d.configMu.Unlock()
+
+	var profileConfig *Config
+	if profile.Name != "" {
+		var err error
+		if profileConfig, err = d.config.WithProfile(profile.Name); err != nil {
+			return err
+		}
+	}
+	d.profile = profile
+	d.profileConfig = profileConfig
 	return nil
 }
 
+// loadProfile applies the profile recorded in the state file, which may
+// have been chosen while the daemon wasn't running
+func (d *Daemon) loadProfile() {
+	state, err := d.stateManager.Load()
+	if err != nil {
+		d.logger.Warn("Failed to read profile", "error", err)
+		return
+	}
+	profile := state.ActiveProfile()
+	if !profile.ActiveAt(time.Now()) {
+		return
+	}
+	if err := d.setProfile(profile); err != nil {
+		d.logger.Warn("Ignoring profile", "profile", profile.Name, "error", err)
+		return
+	}
+	d.logger.Info("Using profile", "profile", profile.Name)
+}
+
+// currentNotifier returns the notifier for the active configuration
+func (d *Daemon) currentNotifier() *Notifier {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.notifier
+}
+
+// newNotifier creates the notifier for a configuration, logging the
+// notifications quiet hours or Focus hold back
+func (d *Daemon) newNotifier(config *Config) *Notifier {
+	notifier := NewNotifier(config.Notifications)
+	notifier.heldBack = func(reason, message string) {
+		d.logger.Info("Notification held back", "reason", reason, "message", message)
+	}
+	return notifier
+}
+
+// setConfig replaces the active configuration and the notifier built from it
+func (d *Daemon) setConfig(config *Config) {
+	d.applyOverrides(config)
+
+	d.configMu.Lock()
+	defer d.configMu.Unlock()
+	d.config = config
+	d.notifier = d.newNotifier(config)
+	d.profileConfig = nil
+	if d.profile.Name != "" {
+		// The profile may have been removed from the configuration
+		if profileConfig, err := config.WithProfile(d.profile.Name); err != nil {
+			d.logger.Warn("Ignoring profile", "profile", d.profile.Name, "error", err)
+			d.profile = ActiveProfile{}
+		} else {
+			d.profileConfig = profileConfig
+		}
+	}
+	SetKubectlTimeout(config.KubectlTimeout)
+	if rc, ok := d.switcher.(RetryConfigurer); ok {
+		rc.SetRetryPolicy(config.Switcher)
+	}
+	// The log format only changes on restart, but the level applies at once
+	if d.logLevel != nil && !d.debugLog {
+		d.logLevel.Set(ParseLogLevel(config.Daemon.LogLevel))
+	}
+}
+
+// applyOverrides applies the settings given as daemon options over a
+// loaded configuration
+func (d *Daemon) applyOverrides(config *Config) {
+	if d.checkInterval > 0 {
+		config.Timeout.CheckInterval = d.checkInterval
+	}
+}
+
 // Shutdown gracefully shuts down the daemon
 func (d *Daemon) Shutdown() {
-	d.logger.Println("Shutting down daemon gracefully...")
+	d.logger.Info("Shutting down daemon gracefully")
 
-	// Cancel context to signal shutdown
+	// Cancel context to signal shutdown - no new checks will start after this
 	d.cancel()
 
+	// Stop accepting control requests; any waiting on a check are refused
+	d.closeControl()
+
+	// Stop taking activity, writing what has been received
+	d.closeActivity()
+
+	// Drain: wait for an in-flight check or switch to complete
+	d.waitForInFlightCheck()
+
+	// Let widgets know the daemon is no longer protecting the session
+	d.publishStatusSummary(true)
+
+	// A stopped daemon has no heartbeat
+	if err := os.Remove(d.heartbeatPath); err != nil && !os.IsNotExist(err) {
+		d.logger.Warn("Failed to remove heartbeat", "error", err)
+	}
+
 	// Release PID file
 	if err := d.pidFile.Release(); err != nil {
-		d.logger.Printf("Warning: failed to release PID file: %v", err)
+		d.logger.Warn("Failed to release PID file", "error", err)
 	}
 
-	d.logger.Println("Daemon shutdown complete")
+	d.logger.Info("Daemon shutdown complete")
+
+	if d.logFile != nil {
+		if err := d.logFile.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close log file: %v\n", err)
+		}
+	}
+}
+
+// openLog sets up the default logger, writing to daemon.log_file with
+// rotation, or to stdout if log_file is empty or can't be opened or the
+// daemon runs in the foreground
+func (d *Daemon) openLog(config *Config, stateDir string) error {
+	d.logLevel.Set(ParseLogLevel(config.Daemon.LogLevel))
+	if d.foreground {
+		if d.debugLog {
+			d.logLevel.Set(slog.LevelDebug)
+		}
+		d.logger = NewLogger(os.Stdout, LogFormatConsole, d.logLevel)
+		return nil
+	}
+	d.logger = NewLogger(os.Stdout, config.Daemon.LogFormat, d.logLevel)
+	if config.Daemon.LogFile == "" {
+		return nil
+	}
+
+	maxSize := int64(config.Daemon.LogMaxSize) << 20 // MB
+	file, err := OpenRotatingFile(LogFilePath(stateDir, config.Daemon.LogFile), maxSize, config.Daemon.LogMaxBackups)
+	if err != nil {
+		return err
+	}
+	d.logFile = file
+	d.logger = NewLogger(file, config.Daemon.LogFormat, d.logLevel)
+	return nil
+}
+
+// waitForInFlightCheck blocks until any running timeout check has finished,
+// or until the shutdown timeout elapses
+func (d *Daemon) waitForInFlightCheck() {
+	done := make(chan struct{})
+	go func() {
+		d.checkMu.Lock()
+		// Nothing else can start once the context is canceled, so the lock
+		// only needs to be acquired to know the current check has finished
+		d.checkMu.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(d.shutdownTimeout):
+		d.logger.Warn("In-flight check did not finish in time, shutting down anyway", "timeout", d.shutdownTimeout)
+	}
 }
@@ -4,31 +4,38 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"testing"
 	"time"
 )
 
-// requireFswatch skips the test if fswatch is not available or not on macOS
-// fswatch is required for file monitoring tests and only works on macOS
-func requireFswatch(t *testing.T) {
+// requireNativeNotifications skips the test if the platform has no native
+// file notification support. The polling fallback is too slow for these
+// tests' timing and is covered separately.
+func requireNativeNotifications(t *testing.T) {
 	t.Helper()
 
-	// Check if we're on macOS
-	if runtime.GOOS != "darwin" {
-		t.Skip("fswatch file monitoring only works on macOS (requires FSEvents API)")
+	notifier, err := newNativeNotifier(filepath.Join(t.TempDir(), "probe"))
+	if err != nil {
+		t.Skipf("native file notifications unavailable: %v", err)
 	}
+	_ = notifier.Close()
+}
+
+// requireKubectl skips the test if kubectl isn't installed, since the file
+// watcher reads the current context through it
+func requireKubectl(t *testing.T) {
+	t.Helper()
 
-	// Check if fswatch is installed
-	if _, err := exec.LookPath("fswatch"); err != nil {
-		t.Skip("fswatch not installed - install with: brew install fswatch")
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		t.Skipf("Skipping test: kubectl not available: %v", err)
 	}
 }
 
 // TestWatchKubeconfigDetectsChanges verifies that the file watcher detects
 // context changes when the kubeconfig file is modified
 func TestWatchKubeconfigDetectsChanges(t *testing.T) {
-	requireFswatch(t)
+	requireNativeNotifications(t)
+	requireKubectl(t)
 	tmpDir := t.TempDir()
 	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
 	defer restoreKubeconfig()
@@ -73,7 +80,7 @@ safety:
 	time.Sleep(200 * time.Millisecond)
 
 	// Get initial activity timestamp
-	initialActivity, initialContext, err := daemon.stateManager.GetLastActivity()
+	initialActivity, initialContext, err := LastActivity(daemon.stateManager)
 	if err != nil {
 		t.Fatalf("Failed to get initial activity: %v", err)
 	}
@@ -123,7 +130,7 @@ users:
 	time.Sleep(500 * time.Millisecond)
 
 	// Check that activity was recorded
-	newActivity, newContext, err := daemon.stateManager.GetLastActivity()
+	newActivity, newContext, err := LastActivity(daemon.stateManager)
 	if err != nil {
 		t.Fatalf("Failed to get new activity: %v", err)
 	}
@@ -156,7 +163,8 @@ users:
 // records activity when the kubeconfig is modified, even if context stays the same
 // This is intentional - any kubeconfig modification indicates K8s activity and should extend timeout
 func TestWatchKubeconfigExtendsTimeoutOnModification(t *testing.T) {
-	requireFswatch(t)
+	requireNativeNotifications(t)
+	requireKubectl(t)
 	tmpDir := t.TempDir()
 	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
 	defer restoreKubeconfig()
@@ -197,7 +205,7 @@ safety:
 	time.Sleep(200 * time.Millisecond)
 
 	// Get initial activity timestamp
-	initialActivity, initialContext, err := daemon.stateManager.GetLastActivity()
+	initialActivity, initialContext, err := LastActivity(daemon.stateManager)
 	if err != nil {
 		t.Fatalf("Failed to get initial activity: %v", err)
 	}
@@ -253,7 +261,7 @@ users:
 	time.Sleep(500 * time.Millisecond)
 
 	// Check that activity WAS updated (any kubeconfig modification extends timeout)
-	newActivity, newContext, err := daemon.stateManager.GetLastActivity()
+	newActivity, newContext, err := LastActivity(daemon.stateManager)
 	if err != nil {
 		t.Fatalf("Failed to get new activity: %v", err)
 	}
@@ -348,7 +356,7 @@ safety:
 // TestWatchKubeconfigHandlesFileRecreation verifies that the watcher
 // can handle the kubeconfig file being removed and recreated
 func TestWatchKubeconfigHandlesFileRecreation(t *testing.T) {
-	requireFswatch(t)
+	requireNativeNotifications(t)
 	tmpDir := t.TempDir()
 	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
 	defer restoreKubeconfig()
@@ -1,9 +1,16 @@
 package internal
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -122,91 +129,100 @@ safety:
 }
 
 func TestDaemonReloadConfig(t *testing.T) {
-	// Note: ReloadConfig currently hardcodes the config path to ~/.kubectx-timeout/config.yaml
-	// This test creates that file if possible, but skips if it can't
+	switcher := &fakeSwitcher{current: "production"}
+	d := newFakeDaemon(t, switcher, &fakeStateStore{})
 
-	// Setup isolated test environment to avoid leaking real context names
-	tmpDir := t.TempDir()
-	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
-	defer restoreKubeconfig()
-
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		t.Skip("Cannot get home directory, skipping ReloadConfig test")
-	}
-
-	configDir := filepath.Join(homeDir, ".kubectx-timeout")
-	configPath := filepath.Join(configDir, "config.yaml")
-
-	// Check if config directory exists or can be created
-	if _, err := os.Stat(configDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(configDir, 0755); err != nil {
-			t.Skipf("Cannot create config directory: %v", err)
-		}
-		// Clean up after test
-		defer os.RemoveAll(configDir)
-	}
-
-	// Save original config if it exists
-	originalConfig, _ := os.ReadFile(configPath)
-	hasOriginal := originalConfig != nil
-
-	statePath := filepath.Join(tmpDir, "state.json")
-	pidPath := filepath.Join(tmpDir, "daemon.pid")
-
-	configContent := `
+	// The config lives outside the XDG path; reloads must read it from there
+	newConfigContent := `
 timeout:
-  default: 30m
-  check_interval: 30s
-default_context: test-default
-daemon:
-  enabled: true
-  log_level: info
+  default: 60m
+  check_interval: 1s
+default_context: staging
 notifications:
   enabled: false
 safety:
   check_active_kubectl: false
   validate_default_context: false
 `
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
-		t.Skipf("Failed to create config file: %v", err)
+	if err := os.WriteFile(d.configPath, []byte(newConfigContent), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+	if config := d.currentConfig(); config.DefaultContext != "staging" || config.Timeout.Default != 60*time.Minute {
+		t.Errorf("Expected reloaded config, got default_context %q and timeout %v", config.DefaultContext, config.Timeout.Default)
 	}
 
-	// Restore original config after test
-	if hasOriginal {
-		defer os.WriteFile(configPath, originalConfig, 0644)
-	} else {
-		defer os.Remove(configPath)
+	// An invalid edit keeps the current configuration
+	if err := os.WriteFile(d.configPath, []byte("default_context: local\ndaemon:\n  log_level: loud\n"), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if err := d.ReloadConfig(); err == nil {
+		t.Error("Expected an error reloading an invalid config")
+	}
+	if config := d.currentConfig(); config.DefaultContext != "staging" {
+		t.Errorf("Invalid config should not be applied, got default_context %q", config.DefaultContext)
 	}
 
-	pidFile := NewPIDFileWithPath(pidPath)
-	daemon, err := NewDaemonWithPIDFile(configPath, statePath, pidFile)
-	if err != nil {
-		t.Fatalf("NewDaemon() error = %v", err)
+	// So does a removed file, rather than falling back to the defaults
+	if err := os.Remove(d.configPath); err != nil {
+		t.Fatalf("Failed to remove config file: %v", err)
 	}
+	if err := d.ReloadConfig(); err == nil {
+		t.Error("Expected an error reloading a missing config")
+	}
+	if config := d.currentConfig(); config.DefaultContext != "staging" {
+		t.Errorf("Missing config should not be applied, got default_context %q", config.DefaultContext)
+	}
+}
+
+func TestDaemonWatchesConfigFile(t *testing.T) {
+	d := newFakeDaemon(t, &fakeSwitcher{current: "production"}, &fakeStateStore{})
+	d.summaryPath = filepath.Join(t.TempDir(), statusSummaryFileName)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.watchConfigFile()
+	}()
+	defer func() {
+		d.cancel()
+		<-done
+	}()
+
+	// Give the watcher time to start before editing
+	time.Sleep(200 * time.Millisecond)
 
-	// Update config file
 	newConfigContent := `
 timeout:
-  default: 60m
-  check_interval: 30s
-default_context: test-prod
-daemon:
-  enabled: true
-  log_level: debug
+  default: 10m
+  check_interval: 1s
+default_context: staging
 notifications:
   enabled: false
 safety:
   check_active_kubectl: false
   validate_default_context: false
 `
-	if err := os.WriteFile(configPath, []byte(newConfigContent), 0644); err != nil {
+	if err := os.WriteFile(d.configPath, []byte(newConfigContent), 0600); err != nil {
 		t.Fatalf("Failed to update config file: %v", err)
 	}
 
-	// Test reload - this will fail because ReloadConfig hardcodes the path
-	// but at least it exercises the code path
-	_ = daemon.ReloadConfig()
+	deadline := time.Now().Add(5 * time.Second)
+	for d.currentConfig().DefaultContext != "staging" {
+		if time.Now().After(deadline) {
+			t.Fatal("Config file edit was not applied")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// The main loop is told so it can check against the new timeouts
+	select {
+	case <-d.checkRequested:
+	default:
+		t.Error("Expected the main loop to be notified of the reload")
+	}
 }
 
 // TestDaemonStartupWithStaleState tests that daemon detects context changes on startup
@@ -269,7 +285,7 @@ safety:
 	}
 
 	// Check that activity was updated (should be recent, not 48h ago)
-	lastActivity, _, err := daemon.stateManager.GetLastActivity()
+	lastActivity, _, err := LastActivity(daemon.stateManager)
 	if err != nil {
 		t.Fatalf("Failed to get last activity: %v", err)
 	}
@@ -307,6 +323,8 @@ default_context: %s
 daemon:
   enabled: true
   log_level: info
+notifications:
+  enabled: false
 `, currentContext)
 
 	if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
@@ -339,7 +357,7 @@ daemon:
 	}
 
 	// Check that activity was updated (should be recent, not 48h ago)
-	lastActivity, recordedContext, err := daemon.stateManager.GetLastActivity()
+	lastActivity, recordedContext, err := LastActivity(daemon.stateManager)
 	if err != nil {
 		t.Fatalf("Failed to get last activity: %v", err)
 	}
@@ -383,6 +401,8 @@ default_context: %s
 daemon:
   enabled: true
   log_level: info
+notifications:
+  enabled: false
 `, currentContext)
 
 	if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
@@ -415,7 +435,7 @@ daemon:
 	}
 
 	// Check that activity was initialized (should be recent, not zero)
-	lastActivity, recordedContext, err := daemon.stateManager.GetLastActivity()
+	lastActivity, recordedContext, err := LastActivity(daemon.stateManager)
 	if err != nil {
 		t.Fatalf("Failed to get last activity: %v", err)
 	}
@@ -438,3 +458,774 @@ daemon:
 
 	t.Logf("Zero timestamp was correctly initialized on startup (last activity: %v ago)", timeSince)
 }
+
+func TestDaemonShutdownWaitsForInFlightCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Daemon{
+		ctx:             ctx,
+		cancel:          cancel,
+		logger:          discardLogger(),
+		pidFile:         NewPIDFileWithPath(filepath.Join(t.TempDir(), "daemon.pid")),
+		shutdownTimeout: 5 * time.Second,
+	}
+
+	// Simulate a check (and switch) in progress
+	d.checkMu.Lock()
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		d.Shutdown()
+		close(shutdownDone)
+	}()
+
+	select {
+	case <-shutdownDone:
+		t.Fatal("Shutdown returned while a check was still in flight")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if ctx.Err() == nil {
+		t.Error("expected context to be canceled at start of shutdown")
+	}
+
+	// Finish the in-flight check
+	d.checkMu.Unlock()
+
+	select {
+	case <-shutdownDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown did not return after in-flight check completed")
+	}
+
+	// No new checks run once shutdown has begun
+	d.runCheck()
+}
+
+func TestDaemonShutdownDrainIsBounded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Daemon{
+		ctx:             ctx,
+		cancel:          cancel,
+		logger:          discardLogger(),
+		pidFile:         NewPIDFileWithPath(filepath.Join(t.TempDir(), "daemon.pid")),
+		shutdownTimeout: 100 * time.Millisecond,
+	}
+
+	// Simulate a check that never finishes
+	d.checkMu.Lock()
+	defer d.checkMu.Unlock()
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		d.Shutdown()
+		close(shutdownDone)
+	}()
+
+	select {
+	case <-shutdownDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown did not honor its drain timeout")
+	}
+}
+
+func TestDaemonReloadConfigConcurrentWithChecks(t *testing.T) {
+	tmpDir := t.TempDir()
+	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
+	defer restoreKubeconfig()
+
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+timeout:
+  default: 30m
+  check_interval: 1s
+default_context: test-default
+notifications:
+  enabled: false
+safety:
+  check_active_kubectl: false
+  validate_default_context: false
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	d, err := NewDaemonWithPIDFile(configPath, filepath.Join(tmpDir, "state.json"),
+		NewPIDFileWithPath(filepath.Join(tmpDir, "daemon.pid")))
+	if err != nil {
+		t.Fatalf("NewDaemonWithPIDFile() error = %v", err)
+	}
+	d.logger = discardLogger()
+	d.summaryPath = filepath.Join(tmpDir, statusSummaryFileName)
+
+	// Reloads race with checks and summary updates; run with -race to verify
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if err := d.ReloadConfig(); err != nil {
+				t.Errorf("ReloadConfig() error = %v", err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		d.runCheck()
+		d.refreshStatusSummary()
+		d.notify("test notice")
+	}
+	<-done
+
+	if got := d.currentConfig().DefaultContext; got != "test-default" {
+		t.Errorf("DefaultContext after reload = %q, want %q", got, "test-default")
+	}
+}
+
+// fakeSwitcher is an in-memory Switcher that can simulate failures
+type fakeSwitcher struct {
+	mu         sync.Mutex
+	current    string
+	switchErr  error
+	contextErr error
+	switches   []string
+}
+
+func (f *fakeSwitcher) CurrentContext() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.contextErr != nil {
+		return "", f.contextErr
+	}
+	return f.current, nil
+}
+
+func (f *fakeSwitcher) SwitchContextSafe(targetContext string, neverSwitchTo func(string) bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.switchErr != nil {
+		return f.switchErr
+	}
+	if neverSwitchTo != nil && neverSwitchTo(targetContext) {
+		return MarkFailure(fmt.Errorf("cannot switch to context '%s'", targetContext), ErrSwitchRefused)
+	}
+	f.current = targetContext
+	f.switches = append(f.switches, targetContext)
+	return nil
+}
+
+// fakeStateStore is an in-memory StateStore that can simulate unreadable state
+type fakeStateStore struct {
+	mu      sync.Mutex
+	state   State
+	loadErr error
+}
+
+func (f *fakeStateStore) Load() (*State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.load()
+}
+
+func (f *fakeStateStore) Save(state *State) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.save(state)
+}
+
+func (f *fakeStateStore) Update(fn func(state *State) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, err := f.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(state); errors.Is(err, ErrStateUnchanged) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return f.save(state)
+}
+
+// load and save copy the state through JSON, as the state file does, so
+// callers never share its maps
+func (f *fakeStateStore) load() (*State, error) {
+	if f.loadErr != nil {
+		return nil, f.loadErr
+	}
+	data, err := json.Marshal(&f.state)
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (f *fakeStateStore) save(state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	f.state = State{}
+	return json.Unmarshal(data, &f.state)
+}
+
+// loadState loads the state from store, failing the test if it can't
+func loadState(t *testing.T, store StateStore) *State {
+	t.Helper()
+
+	state, err := store.Load()
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	return state
+}
+
+// newFakeDaemon creates a daemon backed by a fake switcher and state store,
+// so no kubectl or state file is involved
+func newFakeDaemon(t *testing.T, switcher *fakeSwitcher, store *fakeStateStore) *Daemon {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+timeout:
+  default: 10m
+  check_interval: 1s
+default_context: local
+notifications:
+  enabled: false
+safety:
+  check_active_kubectl: false
+  validate_default_context: false
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	d, err := NewDaemonWithPIDFile(configPath, filepath.Join(tmpDir, "state.json"),
+		NewPIDFileWithPath(filepath.Join(tmpDir, "daemon.pid")),
+		WithSwitcher(switcher), WithStateStore(store), WithLogger(discardLogger()))
+	if err != nil {
+		t.Fatalf("NewDaemonWithPIDFile() error = %v", err)
+	}
+	return d
+}
+
+func TestDaemonWithInjectedSwitcherAndStateStore(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	// Startup records fresh activity through the injected store
+	if _, context, _ := LastActivity(store); context != "production" {
+		t.Fatalf("Expected startup activity for 'production', got %q", context)
+	}
+
+	// Not timed out yet
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 0 {
+		t.Fatalf("Expected no switch before the timeout, got %v", switcher.switches)
+	}
+
+	// Timed out: switches through the fake and records activity in the new context
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "production"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 1 || switcher.switches[0] != "local" {
+		t.Errorf("Expected one switch to 'local', got %v", switcher.switches)
+	}
+	if _, context, _ := LastActivity(store); context != "local" {
+		t.Errorf("Expected activity recorded for 'local', got %q", context)
+	}
+
+	// The switch is remembered so undo can reverse it
+	if last := loadState(t, store).LastSwitch(); last.From != "production" || last.To != "local" || last.At.IsZero() {
+		t.Errorf("Expected the switch from 'production' recorded for undo, got %+v", last)
+	}
+}
+
+func TestDaemonSleepsUntilNextDeadline(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	d := newFakeDaemon(t, switcher, &fakeStateStore{})
+
+	// A file-backed store is watched, so the daemon needn't poll it
+	sm, err := NewStateManager(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("NewStateManager() error = %v", err)
+	}
+	d.stateManager = sm
+
+	lastActivity := time.Now().Add(-4 * time.Minute).Truncate(time.Second)
+	if err := sm.Save(&State{LastActivity: lastActivity, CurrentContext: "production"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if next, want := d.runCheck(), lastActivity.Add(10*time.Minute); !next.Equal(want) {
+		t.Errorf("Expected the next check at the timeout %v, got %v", want, next)
+	}
+
+	// Nothing is due on the default context, so only the safety net remains
+	switcher.current = "local"
+	if next := d.runCheck(); time.Until(next) < maxCheckDelay-time.Minute {
+		t.Errorf("Expected the next check about %v away, got %v", maxCheckDelay, time.Until(next))
+	}
+}
+
+func TestDaemonPollsOtherStateStores(t *testing.T) {
+	d := newFakeDaemon(t, &fakeSwitcher{current: "production"}, &fakeStateStore{})
+
+	// Changes to an injected store can't be watched for
+	now := time.Now()
+	if next := d.runCheck(); next.Sub(now) > 2*time.Second {
+		t.Errorf("Expected the next check within check_interval, got %v", next.Sub(now))
+	}
+}
+
+func TestDaemonDryRun(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+	WithDryRun()(d)
+	var logs bytes.Buffer
+	d.logger = NewLogger(&logs, LogFormatText, slog.LevelInfo)
+
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "production"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 0 {
+		t.Errorf("Expected no switch in a dry run, got %v", switcher.switches)
+	}
+	if !strings.Contains(logs.String(), `msg="Dry run: would switch context" context=production to=local`) {
+		t.Errorf("Expected the would-be switch in the log:\n%s", logs.String())
+	}
+
+	// A grace period isn't started either
+	config := *d.currentConfig()
+	config.Timeout.GracePeriod = time.Hour
+	d.setConfig(&config)
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if pending := loadState(t, store).PendingSwitch(); !pending.IsZero() {
+		t.Errorf("Expected no pending switch in a dry run, got %+v", pending)
+	}
+}
+
+func TestDaemonCheckIntervalOverride(t *testing.T) {
+	d := newFakeDaemon(t, &fakeSwitcher{current: "production"}, &fakeStateStore{})
+	WithCheckInterval(5 * time.Second)(d)
+
+	// The override outlasts reloads, and polls even a watched state file
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+	config := d.currentConfig()
+	if config.Timeout.CheckInterval != 5*time.Second {
+		t.Errorf("check_interval = %v, want the 5s override", config.Timeout.CheckInterval)
+	}
+	sm, err := NewStateManager(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("NewStateManager() error = %v", err)
+	}
+	d.stateManager = sm
+	now := time.Now()
+	if next := d.nextCheckAt(config, now); !next.Equal(now.Add(5 * time.Second)) {
+		t.Errorf("Expected the next check in 5s, got %v", next.Sub(now))
+	}
+}
+
+func TestDaemonDumpState(t *testing.T) {
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, &fakeSwitcher{current: "production"}, store)
+	var logs bytes.Buffer
+	d.logger = NewLogger(&logs, LogFormatText, slog.LevelInfo)
+
+	config := *d.currentConfig()
+	config.Notifications.Slack.Token = "xoxb-secret"
+	d.setConfig(&config)
+
+	d.dumpState(time.Now().Add(5 * time.Minute))
+
+	out := logs.String()
+	if strings.Count(out, "\n") != 1 {
+		t.Errorf("Expected the dump in one record:\n%s", out)
+	}
+	for _, want := range []string{
+		`msg="State dump"`,
+		"next_check_in=5m0s",
+		"policy.action=wait",
+		"policy.context=production policy.default_context=local policy.timeout=10m0s",
+		"config.timeout.default=10m0s",
+		"config.notifications.slack.token=REDACTED",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in the dump:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "xoxb-secret") {
+		t.Errorf("Expected the Slack token to be redacted:\n%s", out)
+	}
+}
+
+func TestNextTimeoutCheck(t *testing.T) {
+	config := DefaultConfig()
+	config.Timeout.CheckInterval = 30 * time.Second
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		in       PolicyInputs
+		decision PolicyDecision
+		want     time.Time
+	}{
+		{
+			name:     "waiting for the timeout",
+			in:       PolicyInputs{Now: now},
+			decision: PolicyDecision{Action: PolicyActionWait, SwitchAt: now.Add(5 * time.Minute)},
+			want:     now.Add(5 * time.Minute),
+		},
+		{
+			name:     "grace period",
+			in:       PolicyInputs{Now: now},
+			decision: PolicyDecision{Action: PolicyActionGrace, SwitchAt: now.Add(time.Minute)},
+			want:     now.Add(time.Minute),
+		},
+		{
+			name:     "extended",
+			in:       PolicyInputs{Now: now, ExtendedUntil: now.Add(time.Hour)},
+			decision: PolicyDecision{Action: PolicyActionNone},
+			want:     now.Add(time.Hour),
+		},
+		{
+			name:     "paused",
+			in:       PolicyInputs{Now: now, PausedUntil: now.Add(2 * time.Hour)},
+			decision: PolicyDecision{Action: PolicyActionNone},
+			want:     now.Add(2 * time.Hour),
+		},
+		{
+			name:     "on the default context",
+			in:       PolicyInputs{Now: now},
+			decision: PolicyDecision{Action: PolicyActionNone},
+		},
+		{
+			name:     "deferred for running tools",
+			in:       PolicyInputs{Now: now},
+			decision: PolicyDecision{Action: PolicyActionDefer},
+			want:     now.Add(30 * time.Second),
+		},
+		{
+			name:     "deferral capped sooner",
+			in:       PolicyInputs{Now: now},
+			decision: PolicyDecision{Action: PolicyActionDefer, SwitchAt: now.Add(10 * time.Second)},
+			want:     now.Add(10 * time.Second),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextTimeoutCheck(config, tt.in, tt.decision); !got.Equal(tt.want) {
+				t.Errorf("nextTimeoutCheck() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemonPerContextDefault(t *testing.T) {
+	switcher := &fakeSwitcher{current: "prod-us"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	configContent := `
+timeout:
+  default: 10m
+  check_interval: 1s
+default_context: local
+contexts:
+  prod-us:
+    default_context: staging-us
+notifications:
+  enabled: false
+safety:
+  check_active_kubectl: false
+  validate_default_context: false
+`
+	if err := os.WriteFile(d.configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "prod-us"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 1 || switcher.switches[0] != "staging-us" {
+		t.Errorf("Expected one switch to 'staging-us', got %v", switcher.switches)
+	}
+
+	// Other contexts still fall back to the global default
+	switcher.current = "dev"
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "dev"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 2 || switcher.switches[1] != "local" {
+		t.Errorf("Expected a second switch to 'local', got %v", switcher.switches)
+	}
+}
+
+func TestDaemonSwitchFailure(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "production"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	switcher.switchErr = errors.New("kubectl config use-context: exit status 1")
+
+	err := d.checkTimeout()
+	if !errors.Is(err, errSwitchFailed) {
+		t.Fatalf("checkTimeout() error = %v, want %v", err, errSwitchFailed)
+	}
+	if _, context, _ := LastActivity(store); context != "production" {
+		t.Errorf("Failed switch should not record activity, got context %q", context)
+	}
+
+	d.handleCheckResult(err)
+	if summary := d.buildStatusSummary(time.Now()); summary.State != SummaryStateDegraded {
+		t.Errorf("Expected degraded summary after a failed switch, got %q", summary.State)
+	}
+}
+
+func TestDaemonUnreadableState(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	store.loadErr = errors.New("invalid character '}' looking for beginning of value")
+
+	if err := d.checkTimeout(); !errors.Is(err, errStateUnavailable) {
+		t.Errorf("checkTimeout() error = %v, want %v", err, errStateUnavailable)
+	}
+	if len(switcher.switches) != 0 {
+		t.Errorf("Expected no switch with unreadable state, got %v", switcher.switches)
+	}
+}
+
+func TestDaemonContextUnavailable(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	switcher.contextErr = errors.New("kubectl: executable file not found in $PATH")
+
+	if err := d.checkTimeout(); !errors.Is(err, errContextUnavailable) {
+		t.Errorf("checkTimeout() error = %v, want %v", err, errContextUnavailable)
+	}
+}
+
+func TestDaemonGracePeriod(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	config := *d.currentConfig()
+	config.Timeout.GracePeriod = time.Hour
+	d.setConfig(&config)
+
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "production"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// The first due check starts the grace period instead of switching
+	for i := 0; i < 2; i++ {
+		if err := d.checkTimeout(); err != nil {
+			t.Fatalf("checkTimeout() error = %v", err)
+		}
+	}
+	if len(switcher.switches) != 0 {
+		t.Fatalf("Expected no switch during the grace period, got %v", switcher.switches)
+	}
+	pending := loadState(t, store).PendingSwitch()
+	if pending.From != "production" || pending.To != "local" || time.Until(pending.At) < 59*time.Minute {
+		t.Fatalf("Expected a pending switch in about an hour, got %+v", pending)
+	}
+	if summary := d.buildStatusSummary(time.Now()); summary.State != SummaryStatePending || summary.Deadline == nil || !summary.Deadline.Equal(pending.At) {
+		t.Errorf("Expected pending summary with the switch time as deadline, got %+v", summary)
+	}
+
+	// Once the grace period is over, the switch happens
+	pending.At = time.Now().Add(-time.Second)
+	if err := SetPendingSwitch(store, pending); err != nil {
+		t.Fatalf("SetPendingSwitch() error = %v", err)
+	}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 1 || switcher.switches[0] != "local" {
+		t.Errorf("Expected one switch to 'local' after the grace period, got %v", switcher.switches)
+	}
+	if pending := loadState(t, store).PendingSwitch(); !pending.IsZero() {
+		t.Errorf("Expected no pending switch after switching, got %+v", pending)
+	}
+}
+
+func TestDaemonGracePeriodCanceledByActivity(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	config := *d.currentConfig()
+	config.Timeout.GracePeriod = time.Hour
+	d.setConfig(&config)
+
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "production"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if pending := loadState(t, store).PendingSwitch(); pending.IsZero() {
+		t.Fatal("Expected a pending switch")
+	}
+
+	// Using kubectl again resets the timer, which drops the pending switch
+	if err := RecordActivity(store, "production"); err != nil {
+		t.Fatalf("RecordActivity() error = %v", err)
+	}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if pending := loadState(t, store).PendingSwitch(); !pending.IsZero() {
+		t.Errorf("Expected the pending switch to be dropped, got %+v", pending)
+	}
+	if len(switcher.switches) != 0 {
+		t.Errorf("Expected no switch, got %v", switcher.switches)
+	}
+}
+
+func TestDaemonPausedContext(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	pausedUntil := time.Now().Add(time.Hour)
+	store.state = State{
+		LastActivity:   time.Now().Add(-time.Hour),
+		CurrentContext: "production",
+		PausedContexts: map[string]time.Time{"production": pausedUntil},
+	}
+
+	// The paused context is never switched away from, however long it's idle
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 0 {
+		t.Fatalf("Expected no switch from a paused context, got %v", switcher.switches)
+	}
+	summary := d.buildStatusSummary(time.Now())
+	if summary.State != SummaryStatePaused || summary.PausedUntil == nil || !summary.PausedUntil.Equal(pausedUntil) {
+		t.Errorf("Expected paused state until %v, got %+v", pausedUntil, summary)
+	}
+
+	// Other contexts keep their timeouts
+	switcher.current = "staging"
+	store.state.CurrentContext = "staging"
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 1 {
+		t.Fatalf("Expected the unpaused context to be switched, got %v", switcher.switches)
+	}
+
+	// An expired pause no longer applies
+	switcher.current = "production"
+	store.state.LastActivity = time.Now().Add(-time.Hour)
+	store.state.CurrentContext = "production"
+	store.state.PausedContexts = map[string]time.Time{"production": time.Now().Add(-time.Minute)}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 2 {
+		t.Errorf("Expected a switch once the pause expired, got %v", switcher.switches)
+	}
+}
+
+func TestDaemonRecordsSwitchHistory(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	store.state = State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "production"}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+
+	events, err := d.history.Read(HistoryFilter{Type: HistorySwitch})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected one switch in the history, got %+v", events)
+	}
+	if events[0].FromContext != "production" || events[0].Context != "local" || !strings.HasPrefix(events[0].Reason, "inactive for") {
+		t.Errorf("Unexpected switch event: %+v", events[0])
+	}
+}
+
+// fakeNamespaceSwitcher is a fakeSwitcher that records namespace changes
+type fakeNamespaceSwitcher struct {
+	*fakeSwitcher
+	namespaces map[string]string
+}
+
+func (f *fakeNamespaceSwitcher) SetContextNamespace(context, namespace string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.namespaces[context] = namespace
+	return nil
+}
+
+func TestDaemonResetsNamespaceOnTimeout(t *testing.T) {
+	for _, resetNamespace := range []string{"", "default"} {
+		switcher := &fakeNamespaceSwitcher{fakeSwitcher: &fakeSwitcher{current: "production"}, namespaces: map[string]string{}}
+		store := &fakeStateStore{}
+		d := newFakeDaemon(t, switcher.fakeSwitcher, store)
+		d.switcher = switcher
+		d.currentConfig().Timeout.ResetNamespace = resetNamespace
+
+		if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "production"}); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if err := d.checkTimeout(); err != nil {
+			t.Fatalf("checkTimeout() error = %v", err)
+		}
+
+		if resetNamespace == "" {
+			if len(switcher.namespaces) != 0 {
+				t.Errorf("Expected namespaces left alone without reset_namespace, got %v", switcher.namespaces)
+			}
+			continue
+		}
+		if got := switcher.namespaces["production"]; got != resetNamespace || len(switcher.namespaces) != 1 {
+			t.Errorf("Expected only 'production' reset to %q, got %v", resetNamespace, switcher.namespaces)
+		}
+	}
+}
@@ -1,9 +1,8 @@
 package internal
 
 import (
-	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"testing"
@@ -16,19 +15,18 @@ func TestDaemonTimeoutTriggersSwitch(t *testing.T) {
 		t.Skip("Skipping slow test in short mode")
 	}
 
-	// Setup isolated test environment
 	tmpDir := t.TempDir()
-	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
-	defer restoreKubeconfig()
+	t.Setenv("KUBECONFIG", filepath.Join(tmpDir, "kubeconfig"))
 
-	// Use test contexts from isolated kubeconfig
+	// Use test contexts from an in-memory kubeconfig
 	prodContext := "test-prod"
 	safeContext := "test-default"
 
 	t.Logf("Testing timeout: %s (prod) -> %s (safe)", prodContext, safeContext)
 
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-	switcher := NewContextSwitcher(logger)
+	logger := NewLogger(os.Stdout, LogFormatText, slog.LevelDebug)
+	client := &fakeKubeClient{current: safeContext, contexts: []string{"test-default", "test-prod", "test-stage"}}
+	switcher := NewContextSwitcherWithClient(logger, client)
 
 	// Setup config and state files
 	configPath := filepath.Join(tmpDir, "config.yaml")
@@ -49,7 +47,11 @@ daemon:
   enabled: true
   log_file: %s
 
+notifications:
+  enabled: false
+
 safety:
+  check_active_kubectl: false
   never_switch_to: []
   never_switch_from: []
 `, prodContext, safeContext, logPath)
@@ -60,18 +62,12 @@ safety:
 
 	// Create daemon
 	pidFile := NewPIDFileWithPath(pidPath)
-	daemon, err := NewDaemonWithPIDFile(configPath, statePath, pidFile)
+	daemon, err := NewDaemonWithPIDFile(configPath, statePath, pidFile, WithKubeClient(client))
 	if err != nil {
 		t.Fatalf("NewDaemon failed: %v", err)
 	}
 
 	// Start daemon in background
-	daemonCtx, daemonCancel := context.WithCancel(context.Background())
-	defer daemonCancel()
-
-	daemon.ctx = daemonCtx
-	daemon.cancel = daemonCancel
-
 	daemonErrCh := make(chan error, 1)
 	go func() {
 		daemonErrCh <- daemon.Run()
@@ -86,12 +82,12 @@ safety:
 
 	// Step 1: Switch to production context
 	t.Logf("Switching to production context: %s", prodContext)
-	if err := switcher.SwitchContextSafe(prodContext, []string{}); err != nil {
+	if err := switcher.SwitchContextSafe(prodContext, nil); err != nil {
 		t.Fatalf("Failed to switch to prod context: %v", err)
 	}
 
 	// Verify we're on prod context
-	currentCtx, _ := GetCurrentContext()
+	currentCtx, _ := client.CurrentContext()
 	if currentCtx != prodContext {
 		t.Fatalf("Not on prod context after switch, got: %s", currentCtx)
 	}
@@ -103,12 +99,12 @@ safety:
 	}
 
 	t.Logf("Recording activity in context: %s", prodContext)
-	if err := stateManager.RecordActivity(prodContext); err != nil {
+	if err := RecordActivity(stateManager, prodContext); err != nil {
 		t.Fatalf("Failed to record activity: %v", err)
 	}
 
 	// Verify state was recorded
-	lastActivity, lastContext, err := stateManager.GetLastActivity()
+	lastActivity, lastContext, err := LastActivity(stateManager)
 	if err != nil {
 		t.Fatalf("Failed to get last activity: %v", err)
 	}
@@ -118,8 +114,7 @@ safety:
 		t.Errorf("State has wrong context: expected %s, got %s", prodContext, lastContext)
 	}
 
-	// Step 3: Wait for timeout to exceed (2s timeout + 500ms check interval + kubectl exec time)
-	// Need extra buffer for kubectl command execution which can be slow
+	// Step 3: Wait for timeout to exceed (2s timeout + 500ms check interval)
 	waitTime := 4 * time.Second
 	t.Logf("Waiting %v for timeout to trigger...", waitTime)
 	time.Sleep(waitTime)
@@ -127,7 +122,7 @@ safety:
 	// Step 4: Verify daemon switched to safe context (with retry for race conditions)
 	maxAttempts := 10
 	for i := 0; i < maxAttempts; i++ {
-		currentCtx, err = GetCurrentContext()
+		currentCtx, err = client.CurrentContext()
 		if err != nil {
 			t.Fatalf("Failed to get current context after timeout: %v", err)
 		}
@@ -148,7 +143,6 @@ safety:
 			maxAttempts, safeContext, currentCtx, string(logs))
 	}
 
-	// Cleanup happens automatically via defer restoreKubeconfig()
 }
 
 // TestDaemonDoesNotSwitchWhenActive tests that daemon doesn't switch if activity is ongoing
@@ -157,17 +151,16 @@ func TestDaemonDoesNotSwitchWhenActive(t *testing.T) {
 		t.Skip("Skipping slow test in short mode")
 	}
 
-	// Setup isolated test environment
 	tmpDir := t.TempDir()
-	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
-	defer restoreKubeconfig()
+	t.Setenv("KUBECONFIG", filepath.Join(tmpDir, "kubeconfig"))
 
-	// Use test contexts from isolated kubeconfig
+	// Use test contexts from an in-memory kubeconfig
 	prodContext := "test-prod"
 	safeContext := "test-default"
 
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-	switcher := NewContextSwitcher(logger)
+	logger := NewLogger(os.Stdout, LogFormatText, slog.LevelDebug)
+	client := &fakeKubeClient{current: safeContext, contexts: []string{"test-default", "test-prod", "test-stage"}}
+	switcher := NewContextSwitcherWithClient(logger, client)
 
 	// Setup config and state files
 	configPath := filepath.Join(tmpDir, "config.yaml")
@@ -175,8 +168,9 @@ func TestDaemonDoesNotSwitchWhenActive(t *testing.T) {
 	pidPath := filepath.Join(tmpDir, "daemon.pid")
 	logPath := filepath.Join(tmpDir, "daemon.log")
 
+	// The timeout outlasts the state file's activity coalescing window
 	configContent := fmt.Sprintf(`timeout:
-  default: 1s
+  default: 3s
   check_interval: 300ms
 
 default_context: %s
@@ -185,7 +179,11 @@ daemon:
   enabled: true
   log_file: %s
 
+notifications:
+  enabled: false
+
 safety:
+  check_active_kubectl: false
   never_switch_to: []
   never_switch_from: []
 `, safeContext, logPath)
@@ -196,18 +194,12 @@ safety:
 
 	// Create daemon
 	pidFile := NewPIDFileWithPath(pidPath)
-	daemon, err := NewDaemonWithPIDFile(configPath, statePath, pidFile)
+	daemon, err := NewDaemonWithPIDFile(configPath, statePath, pidFile, WithKubeClient(client))
 	if err != nil {
 		t.Fatalf("NewDaemon failed: %v", err)
 	}
 
 	// Start daemon
-	daemonCtx, daemonCancel := context.WithCancel(context.Background())
-	defer daemonCancel()
-
-	daemon.ctx = daemonCtx
-	daemon.cancel = daemonCancel
-
 	daemonErrCh := make(chan error, 1)
 	go func() {
 		daemonErrCh <- daemon.Run()
@@ -220,29 +212,28 @@ safety:
 	time.Sleep(200 * time.Millisecond)
 
 	// Switch to prod context
-	if err := switcher.SwitchContextSafe(prodContext, []string{}); err != nil {
+	if err := switcher.SwitchContextSafe(prodContext, nil); err != nil {
 		t.Fatalf("Failed to switch to prod context: %v", err)
 	}
 
-	// Record activity and keep recording every 500ms (before timeout)
+	// Record activity and keep recording every 500ms, past the timeout
 	stateManager, _ := NewStateManager(statePath)
 
-	for i := 0; i < 5; i++ {
-		stateManager.RecordActivity(prodContext)
+	for i := 0; i < 10; i++ {
+		RecordActivity(stateManager, prodContext)
 		t.Logf("Recording activity (iteration %d)", i+1)
 		time.Sleep(500 * time.Millisecond)
 	}
 
-	// After 5 iterations (2.5s), we should STILL be on prod context
+	// After 10 iterations (5s), we should STILL be on prod context
 	// because we kept recording activity
-	currentCtx, _ := GetCurrentContext()
+	currentCtx, _ := client.CurrentContext()
 	if currentCtx != prodContext {
 		logs, _ := os.ReadFile(logPath)
 		t.Errorf("Daemon switched context despite ongoing activity!\nExpected: %s\nActual: %s\n\nLogs:\n%s",
 			prodContext, currentCtx, string(logs))
 	}
 
-	// Cleanup happens automatically via defer restoreKubeconfig()
 }
 
 // TestDaemonRecordsActivityAfterSwitch tests whether daemon updates state after switching
@@ -251,17 +242,16 @@ func TestDaemonRecordsActivityAfterSwitch(t *testing.T) {
 		t.Skip("Skipping slow test in short mode")
 	}
 
-	// Setup isolated test environment
 	tmpDir := t.TempDir()
-	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
-	defer restoreKubeconfig()
+	t.Setenv("KUBECONFIG", filepath.Join(tmpDir, "kubeconfig"))
 
-	// Use test contexts from isolated kubeconfig
+	// Use test contexts from an in-memory kubeconfig
 	prodContext := "test-prod"
 	safeContext := "test-default"
 
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-	switcher := NewContextSwitcher(logger)
+	logger := NewLogger(os.Stdout, LogFormatText, slog.LevelDebug)
+	client := &fakeKubeClient{current: safeContext, contexts: []string{"test-default", "test-prod", "test-stage"}}
+	switcher := NewContextSwitcherWithClient(logger, client)
 
 	// Setup config and state files
 	configPath := filepath.Join(tmpDir, "config.yaml")
@@ -279,7 +269,11 @@ daemon:
   enabled: true
   log_file: %s
 
+notifications:
+  enabled: false
+
 safety:
+  check_active_kubectl: false
   never_switch_to: []
   never_switch_from: []
 `, safeContext, logPath)
@@ -290,18 +284,12 @@ safety:
 
 	// Create daemon
 	pidFile := NewPIDFileWithPath(pidPath)
-	daemon, err := NewDaemonWithPIDFile(configPath, statePath, pidFile)
+	daemon, err := NewDaemonWithPIDFile(configPath, statePath, pidFile, WithKubeClient(client))
 	if err != nil {
 		t.Fatalf("NewDaemon failed: %v", err)
 	}
 
 	// Start daemon
-	daemonCtx, daemonCancel := context.WithCancel(context.Background())
-	defer daemonCancel()
-
-	daemon.ctx = daemonCtx
-	daemon.cancel = daemonCancel
-
 	daemonErrCh := make(chan error, 1)
 	go func() {
 		daemonErrCh <- daemon.Run()
@@ -314,11 +302,11 @@ safety:
 	time.Sleep(200 * time.Millisecond)
 
 	// Switch to prod and record activity
-	switcher.SwitchContextSafe(prodContext, []string{})
+	switcher.SwitchContextSafe(prodContext, nil)
 	stateManager, _ := NewStateManager(statePath)
-	stateManager.RecordActivity(prodContext)
+	RecordActivity(stateManager, prodContext)
 
-	// Wait for timeout (1s) + check interval (300ms) + kubectl execution time (~1s)
+	// Wait for timeout (1s) + check interval (300ms), with room to spare
 	t.Logf("Waiting for timeout...")
 	time.Sleep(3 * time.Second)
 
@@ -326,7 +314,7 @@ safety:
 	var currentCtx string
 	maxAttempts := 10
 	for i := 0; i < maxAttempts; i++ {
-		currentCtx, _ = GetCurrentContext()
+		currentCtx, _ = client.CurrentContext()
 		if currentCtx == safeContext {
 			break
 		}
@@ -339,7 +327,7 @@ safety:
 	}
 
 	// Check state file - what context does it have recorded?
-	_, stateContext, err := stateManager.GetLastActivity()
+	_, stateContext, err := LastActivity(stateManager)
 	if err != nil {
 		t.Fatalf("Failed to get state: %v", err)
 	}
@@ -357,10 +345,9 @@ safety:
 	// Wait one more check interval to ensure daemon doesn't try to switch again
 	time.Sleep(500 * time.Millisecond)
 
-	currentCtx2, _ := GetCurrentContext()
+	currentCtx2, _ := client.CurrentContext()
 	if currentCtx2 != safeContext {
 		t.Errorf("Context changed again after daemon switch! Now at: %s", currentCtx2)
 	}
 
-	// Cleanup happens automatically via defer restoreKubeconfig()
 }
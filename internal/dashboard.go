@@ -0,0 +1,349 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Dashboard refresh intervals
+const (
+	// dashboardRefreshInterval is how often the dashboard reloads the
+	// config and asks the daemon for its status
+	dashboardRefreshInterval = 5 * time.Second
+	// dashboardHistoryEvery is how many refreshes pass between history
+	// reads, which parse the whole log
+	dashboardHistoryEvery = 6
+	// dashboardHistoryLines is how many recent switches the dashboard shows
+	dashboardHistoryLines = 8
+)
+
+// Dashboard is the bubbletea model behind the ui command: every context
+// with its timeout, the current context's countdown, recent switches, and
+// keys to pause, extend, or switch through the daemon's control socket
+type Dashboard struct {
+	configPath string
+	statePath  string
+
+	// Data sources; tests substitute fakes
+	send         func(socketPath string, req ControlRequest) (*ControlResponse, error)
+	listContexts func() ([]string, error)
+
+	now      time.Time
+	config   *Config
+	status   *StatusSummary
+	running  bool
+	contexts []string
+	history  []HistoryEvent
+	refreshN int
+
+	// confirmSwitch is set while waiting for the user to confirm a switch
+	confirmSwitch bool
+	message       string
+	err           error
+}
+
+// NewDashboard creates a dashboard for the daemon using the given config
+// and state files
+func NewDashboard(configPath, statePath string) *Dashboard {
+	return &Dashboard{
+		configPath:   configPath,
+		statePath:    statePath,
+		send:         SendControlRequest,
+		listContexts: GetAvailableContexts,
+		now:          time.Now(),
+	}
+}
+
+// RunDashboard shows the dashboard until the user quits
+func RunDashboard(configPath, statePath string) error {
+	_, err := tea.NewProgram(NewDashboard(configPath, statePath), tea.WithAltScreen()).Run()
+	return err
+}
+
+// Dashboard messages
+type (
+	dashboardTickMsg    time.Time
+	dashboardRefreshMsg struct {
+		config    *Config
+		configErr error
+		status    *StatusSummary
+		running   bool
+		// history is nil when it wasn't read this time
+		history []HistoryEvent
+	}
+	dashboardContextsMsg struct {
+		contexts []string
+		err      error
+	}
+	dashboardActionMsg struct {
+		message string
+		err     error
+	}
+)
+
+// Init loads the data and starts the clock
+func (d *Dashboard) Init() tea.Cmd {
+	return tea.Batch(d.refresh(), d.loadContexts, dashboardTick())
+}
+
+// dashboardTick ticks once a second, on the second, for the countdown
+func dashboardTick() tea.Cmd {
+	return tea.Every(time.Second, func(t time.Time) tea.Msg { return dashboardTickMsg(t) })
+}
+
+// Update handles a message
+func (d *Dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		return d, d.handleKey(msg.String())
+
+	case dashboardTickMsg:
+		d.now = time.Time(msg)
+		var cmd tea.Cmd
+		if d.now.Unix()%int64(dashboardRefreshInterval/time.Second) == 0 {
+			cmd = d.refresh()
+		}
+		return d, tea.Batch(cmd, dashboardTick())
+
+	case dashboardRefreshMsg:
+		if msg.configErr == nil {
+			d.config = msg.config
+		} else {
+			d.err = msg.configErr
+		}
+		d.status, d.running = msg.status, msg.running
+		if msg.history != nil {
+			d.history = msg.history
+		}
+		return d, nil
+
+	case dashboardContextsMsg:
+		if msg.err == nil {
+			d.contexts = msg.contexts
+		}
+		return d, nil
+
+	case dashboardActionMsg:
+		d.message, d.err = msg.message, msg.err
+		return d, d.refresh()
+	}
+	return d, nil
+}
+
+// handleKey carries out a key binding
+func (d *Dashboard) handleKey(key string) tea.Cmd {
+	if d.confirmSwitch {
+		d.confirmSwitch = false
+		if key == "y" {
+			return d.action(ControlRequest{Command: ControlForceSwitch}, "Switched to the default context")
+		}
+		d.message, d.err = "Switch canceled", nil
+		return nil
+	}
+
+	switch key {
+	case "q", "ctrl+c", "esc":
+		return tea.Quit
+	case "r":
+		d.refreshN = 0
+		return tea.Batch(d.refresh(), d.loadContexts)
+	case "p":
+		if d.status == nil || d.status.Context == "" {
+			d.message, d.err = "", fmt.Errorf("no current context to pause")
+			return nil
+		}
+		return d.action(ControlRequest{Command: ControlPause, Context: d.status.Context, Duration: MenuBarPauseDuration.String()},
+			fmt.Sprintf("Paused %s for %s", d.status.Context, FormatPromptDuration(MenuBarPauseDuration)))
+	case "e":
+		return d.action(ControlRequest{Command: ControlPause, Duration: MenuBarExtendDuration.String()},
+			fmt.Sprintf("Switching suppressed for %s", FormatPromptDuration(MenuBarExtendDuration)))
+	case "s":
+		d.confirmSwitch = true
+		d.message, d.err = "", nil
+	}
+	return nil
+}
+
+// action returns a command sending a control request to the daemon
+func (d *Dashboard) action(req ControlRequest, success string) tea.Cmd {
+	socketPath := ControlSocketPathForState(d.statePath)
+	return func() tea.Msg {
+		if _, err := d.send(socketPath, req); err != nil {
+			if errors.Is(err, ErrControlUnavailable) {
+				err = fmt.Errorf("the daemon isn't running (start it with: kubectx-timeout daemon-start)")
+			}
+			return dashboardActionMsg{err: err}
+		}
+		return dashboardActionMsg{message: success}
+	}
+}
+
+// refresh returns a command reloading the config, status, and (every few
+// refreshes) history. The command runs on its own goroutine, so it only
+// reads the dashboard's paths and data sources; Update applies the result.
+func (d *Dashboard) refresh() tea.Cmd {
+	configPath, statePath, send := d.configPath, d.statePath, d.send
+	readHistory := d.refreshN%dashboardHistoryEvery == 0
+	d.refreshN++
+
+	return func() tea.Msg {
+		var msg dashboardRefreshMsg
+		msg.config, msg.configErr = LoadConfig(configPath)
+
+		resp, err := send(ControlSocketPathForState(statePath), ControlRequest{Command: ControlStatus})
+		msg.running = err == nil
+		if err == nil {
+			msg.status = resp.Status
+		} else if summary, readErr := ReadStatusSummary(StatusSummaryPathForState(statePath)); readErr == nil {
+			// The daemon isn't running; show what it last knew
+			msg.status = summary
+		}
+
+		if readHistory {
+			history, err := OpenHistory(statePath, msg.config)
+			var events []HistoryEvent
+			if err == nil {
+				events, err = history.Read(HistoryFilter{Type: HistorySwitch})
+			}
+			if err == nil {
+				msg.history = append([]HistoryEvent{}, events[max(len(events)-dashboardHistoryLines, 0):]...)
+			}
+		}
+		return msg
+	}
+}
+
+// loadContexts lists the kubeconfig contexts
+func (d *Dashboard) loadContexts() tea.Msg {
+	contexts, err := d.listContexts()
+	return dashboardContextsMsg{contexts: contexts, err: err}
+}
+
+// View renders the dashboard
+func (d *Dashboard) View() string {
+	var b strings.Builder
+
+	daemon := "daemon not running"
+	if d.running && d.status != nil {
+		daemon = fmt.Sprintf("daemon running (PID %d)", d.status.DaemonPID)
+	}
+	fmt.Fprintf(&b, "kubectx-timeout · %s\n\n", daemon)
+
+	b.WriteString(d.currentLine())
+	b.WriteString("\n\n")
+
+	b.WriteString("Contexts\n")
+	for _, row := range d.contextRows() {
+		b.WriteString(row)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\nRecent switches\n")
+	if len(d.history) == 0 {
+		b.WriteString("  none yet\n")
+	}
+	for i := len(d.history) - 1; i >= 0; i-- {
+		event := d.history[i]
+		fmt.Fprintf(&b, "  %s  %s → %s", event.Time.Local().Format("Jan 02 15:04"), event.FromContext, event.Context)
+		if event.Reason != "" {
+			fmt.Fprintf(&b, " (%s)", event.Reason)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	if d.confirmSwitch {
+		b.WriteString("Switch to the default context now? y to confirm, any other key to cancel\n")
+	} else {
+		fmt.Fprintf(&b, "p pause context %s · e extend %s · s switch now · r refresh · q quit\n",
+			FormatPromptDuration(MenuBarPauseDuration), FormatPromptDuration(MenuBarExtendDuration))
+	}
+	if d.err != nil {
+		fmt.Fprintf(&b, "Error: %v\n", d.err)
+	} else if d.message != "" {
+		b.WriteString(d.message + "\n")
+	}
+
+	return b.String()
+}
+
+// currentLine describes the current context and its countdown
+func (d *Dashboard) currentLine() string {
+	s := d.status
+	if s == nil || s.Context == "" {
+		return "Current   unknown (no activity recorded yet)"
+	}
+
+	line := "Current   " + s.ContextLabel()
+	switch s.State {
+	case SummaryStateActive, SummaryStatePending:
+		if s.Deadline != nil {
+			remaining := max(s.Deadline.Sub(d.now), 0).Round(time.Second)
+			line += fmt.Sprintf(" → %s in %s", s.DefaultContext, remaining)
+		}
+	case SummaryStateExtended:
+		if s.ExtendedUntil != nil {
+			line += fmt.Sprintf(", switching suppressed until %s", s.ExtendedUntil.Local().Format("15:04"))
+		}
+	case SummaryStatePaused:
+		if s.PausedUntil != nil {
+			line += fmt.Sprintf(", paused until %s", s.PausedUntil.Local().Format("15:04"))
+		}
+	case SummaryStateDeferred:
+		line += ", switch waiting for " + strings.Join(s.DeferredBy, ", ")
+	case SummaryStateDegraded:
+		line += ", daemon degraded: " + s.DegradedReason
+	}
+	return line + fmt.Sprintf("  [%s]", s.State)
+}
+
+// contextRows renders a table of every known context: those in kubeconfig
+// and those named in the config
+func (d *Dashboard) contextRows() []string {
+	if d.config == nil {
+		return []string{"  (configuration not loaded)"}
+	}
+
+	names := slices.Clone(d.contexts)
+	for _, name := range slices.Sorted(maps.Keys(d.config.Contexts)) {
+		if !IsContextPattern(name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	current := ""
+	if d.status != nil {
+		current = d.status.Context
+	}
+
+	width := len("CONTEXT")
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+
+	rows := []string{fmt.Sprintf("  %-*s  %-9s  %s", width, "CONTEXT", "TIMEOUT", "SWITCHES TO")}
+	for _, name := range names {
+		marker := " "
+		if name == current {
+			marker = "▶"
+		}
+
+		target := d.config.GetDefaultContextFor(name)
+		timeout := d.config.GetTimeoutForContextAt(name, d.now).String()
+		switch {
+		case name == target:
+			timeout, target = "-", "(default)"
+		case d.config.IsNeverSwitchFrom(name):
+			timeout, target = "never", "-"
+		}
+		rows = append(rows, fmt.Sprintf("%s %-*s  %-9s  %s", marker, width, name, timeout, target))
+	}
+	return rows
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDashboardView(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+
+	now := time.Now()
+	deadline := now.Add(12*time.Minute + 30*time.Second)
+	daemon := &fakeMenuBarDaemon{running: true, status: StatusSummary{
+		State:          SummaryStateActive,
+		Context:        "prod",
+		DefaultContext: "local",
+		Deadline:       &deadline,
+		DaemonPID:      4242,
+	}}
+
+	history := NewHistory(HistoryPathForState(statePath))
+	if err := history.Append(HistoryEvent{Time: now, Type: HistorySwitch, FromContext: "staging", Context: "local", Reason: "inactive for 30m0s"}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	d := NewDashboard(filepath.Join(dir, "missing.yaml"), statePath)
+	d.send = daemon.send
+	d.listContexts = func() ([]string, error) { return []string{"local", "prod", "staging"}, nil }
+	d.now = now
+
+	d.Update(d.refresh()())
+	d.Update(d.loadContexts())
+	d.config.DefaultContext = "local"
+	d.config.Contexts = map[string]Context{"prod": {Timeout: 5 * time.Minute}}
+
+	view := d.View()
+	for _, want := range []string{
+		"daemon running (PID 4242)",
+		"Current   prod → local in 12m30s",
+		"▶ prod",
+		"5m0s",
+		"(default)",
+		"staging → local (inactive for 30m0s)",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("View() missing %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestDashboardKeys(t *testing.T) {
+	daemon := &fakeMenuBarDaemon{running: true, status: StatusSummary{State: SummaryStateActive, Context: "prod"}}
+	d := NewDashboard(filepath.Join(t.TempDir(), "config.yaml"), filepath.Join(t.TempDir(), "state.json"))
+	d.send = daemon.send
+	d.Update(d.refresh()())
+
+	press := func(key string) tea.Cmd {
+		_, cmd := d.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		return cmd
+	}
+
+	for _, key := range []string{"p", "e"} {
+		d.Update(press(key)())
+	}
+
+	// Switching asks first; anything but y cancels
+	if cmd := press("s"); cmd != nil || !d.confirmSwitch {
+		t.Fatal("s should ask for confirmation")
+	}
+	if cmd := press("n"); cmd != nil || d.confirmSwitch {
+		t.Fatal("n should cancel the switch")
+	}
+	press("s")
+	d.Update(press("y")())
+
+	want := []ControlRequest{
+		{Command: ControlStatus},
+		{Command: ControlPause, Context: "prod", Duration: "1h0m0s"},
+		{Command: ControlPause, Duration: "30m0s"},
+		{Command: ControlForceSwitch},
+	}
+	if fmt.Sprint(daemon.requests) != fmt.Sprint(want) {
+		t.Errorf("requests = %+v, want %+v", daemon.requests, want)
+	}
+	if d.err != nil || d.message != "Switched to the default context" {
+		t.Errorf("message = %q, err = %v", d.message, d.err)
+	}
+}
+
+func TestDashboardDaemonStopped(t *testing.T) {
+	d := NewDashboard(filepath.Join(t.TempDir(), "config.yaml"), filepath.Join(t.TempDir(), "state.json"))
+	d.send = (&fakeMenuBarDaemon{}).send
+	d.Update(d.refresh()())
+
+	if view := d.View(); !strings.Contains(view, "daemon not running") {
+		t.Errorf("View() should show the daemon as stopped:\n%s", view)
+	}
+
+	_, cmd := d.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	d.Update(cmd())
+	if d.err == nil || !strings.Contains(d.err.Error(), "daemon isn't running") {
+		t.Errorf("err = %v, want the daemon reported as not running", d.err)
+	}
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// Sentinel errors used to classify failures in the daemon's check loop
+var (
+	errStateUnavailable   = errors.New("state file unreadable")
+	errContextUnavailable = errors.New("kubeconfig unreadable")
+	errSwitchFailed       = errors.New("context switch failed")
+)
+
+// degradedRenotifyInterval is how long a condition must persist before the
+// degraded notification is repeated
+const degradedRenotifyInterval = 1 * time.Hour
+
+// classifyError maps a daemon error to a short, stable description of the
+// underlying condition so repeated failures can be deduplicated
+func classifyError(err error) string {
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return "kubectl not found in PATH"
+	case errors.Is(err, errStateUnavailable):
+		return errStateUnavailable.Error()
+	case errors.Is(err, errContextUnavailable):
+		return errContextUnavailable.Error()
+	case errors.Is(err, errSwitchFailed):
+		return errSwitchFailed.Error()
+	default:
+		return "unexpected error"
+	}
+}
+
+// degradedTracker deduplicates repeated daemon errors into a single
+// "degraded" condition that is reported once, repeated at a slow interval
+// while it persists, and cleared when the daemon recovers
+type degradedTracker struct {
+	condition    string
+	since        time.Time
+	failures     int
+	lastNotified time.Time
+	interval     time.Duration
+}
+
+// newDegradedTracker creates a tracker that repeats notifications at the given interval
+func newDegradedTracker(interval time.Duration) *degradedTracker {
+	return &degradedTracker{interval: interval}
+}
+
+// Report records a failure at the given time. It returns a message and true
+// when the failure should be surfaced to the user, or false when it is a
+// repeat of a condition that has already been reported recently.
+func (t *degradedTracker) Report(err error, now time.Time) (string, bool) {
+	condition := classifyError(err)
+
+	if condition != t.condition {
+		// New condition (or first failure) - report immediately
+		t.condition = condition
+		t.since = now
+		t.failures = 1
+		t.lastNotified = now
+		return fmt.Sprintf("daemon degraded since %s: %s (%v)",
+			t.since.Format("15:04"), t.condition, err), true
+	}
+
+	t.failures++
+	if now.Sub(t.lastNotified) < t.interval {
+		return "", false
+	}
+
+	t.lastNotified = now
+	return fmt.Sprintf("daemon degraded since %s: %s (%d failures, latest: %v)",
+		t.since.Format("15:04"), t.condition, t.failures, err), true
+}
+
+// Resolve clears any degraded condition. It returns a recovery message and
+// true if the daemon was previously degraded.
+func (t *degradedTracker) Resolve(now time.Time) (string, bool) {
+	if t.condition == "" {
+		return "", false
+	}
+
+	msg := fmt.Sprintf("daemon recovered after %v: %s resolved (%d failures)",
+		now.Sub(t.since).Round(time.Second), t.condition, t.failures)
+
+	t.condition = ""
+	t.since = time.Time{}
+	t.failures = 0
+	t.lastNotified = time.Time{}
+
+	return msg, true
+}
+
+// Degraded reports whether a failure condition is currently active
+func (t *degradedTracker) Degraded() bool {
+	return t.condition != ""
+}
+
+// Condition returns the active failure condition, or "" if not degraded
+func (t *degradedTracker) Condition() string {
+	return t.condition
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "kubectl missing",
+			err:      fmt.Errorf("%w: %w", errContextUnavailable, &exec.Error{Name: "kubectl", Err: exec.ErrNotFound}),
+			expected: "kubectl not found in PATH",
+		},
+		{
+			name:     "kubeconfig unreadable",
+			err:      fmt.Errorf("%w: %w", errContextUnavailable, errors.New("exit status 1")),
+			expected: "kubeconfig unreadable",
+		},
+		{
+			name:     "state unreadable",
+			err:      fmt.Errorf("%w: %w", errStateUnavailable, errors.New("permission denied")),
+			expected: "state file unreadable",
+		},
+		{
+			name:     "switch failed",
+			err:      fmt.Errorf("%w: %w", errSwitchFailed, errors.New("kubectl command failed")),
+			expected: "context switch failed",
+		},
+		{
+			name:     "unclassified",
+			err:      errors.New("something else"),
+			expected: "unexpected error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.expected {
+				t.Errorf("classifyError() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDegradedTrackerDeduplicates(t *testing.T) {
+	tracker := newDegradedTracker(time.Hour)
+	start := time.Date(2024, 1, 1, 10, 42, 0, 0, time.Local)
+	err := fmt.Errorf("%w: %w", errContextUnavailable, errors.New("exit status 1"))
+
+	msg, ok := tracker.Report(err, start)
+	if !ok {
+		t.Fatal("expected first failure to be reported")
+	}
+	if !strings.Contains(msg, "degraded since 10:42: kubeconfig unreadable") {
+		t.Errorf("unexpected message: %s", msg)
+	}
+
+	// Repeats within the interval are suppressed
+	for i := 1; i <= 10; i++ {
+		if _, ok := tracker.Report(err, start.Add(time.Duration(i)*30*time.Second)); ok {
+			t.Fatalf("expected repeat %d to be suppressed", i)
+		}
+	}
+
+	// After the interval the notification escalates with a failure count
+	msg, ok = tracker.Report(err, start.Add(time.Hour))
+	if !ok {
+		t.Fatal("expected escalation after interval")
+	}
+	if !strings.Contains(msg, "degraded since 10:42") || !strings.Contains(msg, "12 failures") {
+		t.Errorf("unexpected escalation message: %s", msg)
+	}
+}
+
+func TestDegradedTrackerNewCondition(t *testing.T) {
+	tracker := newDegradedTracker(time.Hour)
+	now := time.Now()
+
+	if _, ok := tracker.Report(fmt.Errorf("%w: x", errStateUnavailable), now); !ok {
+		t.Fatal("expected first failure to be reported")
+	}
+
+	// A different condition is reported immediately
+	msg, ok := tracker.Report(fmt.Errorf("%w: y", errSwitchFailed), now.Add(time.Second))
+	if !ok {
+		t.Fatal("expected new condition to be reported")
+	}
+	if !strings.Contains(msg, "context switch failed") {
+		t.Errorf("unexpected message: %s", msg)
+	}
+}
+
+func TestDegradedTrackerResolve(t *testing.T) {
+	tracker := newDegradedTracker(time.Hour)
+	now := time.Now()
+
+	// Resolving when healthy is a no-op
+	if _, ok := tracker.Resolve(now); ok {
+		t.Error("expected no recovery message when not degraded")
+	}
+
+	tracker.Report(fmt.Errorf("%w: x", errStateUnavailable), now)
+	if !tracker.Degraded() {
+		t.Fatal("expected tracker to be degraded")
+	}
+
+	msg, ok := tracker.Resolve(now.Add(5 * time.Minute))
+	if !ok {
+		t.Fatal("expected recovery message")
+	}
+	if !strings.Contains(msg, "recovered") || !strings.Contains(msg, "state file unreadable") {
+		t.Errorf("unexpected recovery message: %s", msg)
+	}
+	if tracker.Degraded() {
+		t.Error("expected tracker to be cleared after resolve")
+	}
+
+	// The same condition is reported again after recovery
+	if _, ok := tracker.Report(fmt.Errorf("%w: x", errStateUnavailable), now.Add(6*time.Minute)); !ok {
+		t.Error("expected condition to be reported again after recovery")
+	}
+}
+
+func TestDaemonHandleCheckResultDeduplicates(t *testing.T) {
+	var buf bytes.Buffer
+	d := &Daemon{
+		logger:   NewLogger(&buf, LogFormatText, slog.LevelDebug),
+		degraded: newDegradedTracker(time.Hour),
+	}
+
+	err := fmt.Errorf("%w: %w", errContextUnavailable, errors.New("exit status 1"))
+	for i := 0; i < 5; i++ {
+		d.handleCheckResult(err)
+	}
+
+	if count := strings.Count(buf.String(), "degraded since"); count != 1 {
+		t.Errorf("expected 1 degraded notice, got %d:\n%s", count, buf.String())
+	}
+
+	d.handleCheckResult(nil)
+	if !strings.Contains(buf.String(), "recovered") {
+		t.Errorf("expected recovery notice, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,332 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Desktop notification backends, for notifications.desktop
+const (
+	DesktopBackendMacOS   = "macos"
+	DesktopBackendLinux   = "linux"
+	DesktopBackendWindows = "windows"
+)
+
+// DesktopBackends lists the desktop notification backends
+var DesktopBackends = []string{DesktopBackendMacOS, DesktopBackendLinux, DesktopBackendWindows}
+
+// desktopNotificationTimeout bounds how long sending a desktop notification
+// may block the daemon
+const desktopNotificationTimeout = 5 * time.Second
+
+// alertTimeout is how long a notification with action buttons stays up
+// waiting for an answer
+const alertTimeout = 10 * time.Minute
+
+// Answers alerter gives besides the label of the button pressed
+const (
+	alerterContentClicked = "@CONTENTCLICKED"
+	alerterActionClicked  = "@ACTIONCLICKED"
+)
+
+// windowsToastAppID is the application a toast is shown as. Toasts need an
+// app registered with the Start menu, and Windows PowerShell always is.
+const windowsToastAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// windowsToastScript shows a toast with the title and message from the
+// environment, so neither is ever parsed as PowerShell
+const windowsToastScript = `$ErrorActionPreference = 'Stop'
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null
+$title = [Security.SecurityElement]::Escape($env:KUBECTX_TIMEOUT_NOTIFICATION_TITLE)
+$message = [Security.SecurityElement]::Escape($env:KUBECTX_TIMEOUT_NOTIFICATION_MESSAGE)
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml("<toast><visual><binding template=""ToastGeneric""><text>$title</text><text>$message</text></binding></visual></toast>")
+$toast = New-Object Windows.UI.Notifications.ToastNotification $xml
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($env:KUBECTX_TIMEOUT_NOTIFICATION_APP).Show($toast)
+`
+
+// errDesktopUnsupported is returned when a desktop notification backend
+// isn't available on this system
+var errDesktopUnsupported = errors.New("desktop notifications are not available")
+
+// Notification is a message for the user, with what they can do about it
+type Notification struct {
+	Title   string
+	Message string
+
+	// ClickCommand is run through the shell when the notification is
+	// clicked, where the backend supports that
+	ClickCommand string
+
+	// Actions are offered as buttons, where the backend supports them
+	Actions []NotificationAction
+}
+
+// NotificationAction is a button on a notification, which calls Run when
+// pressed. Run is called on its own goroutine, and any time until the
+// notification is dismissed.
+type NotificationAction struct {
+	Label string
+	Run   func()
+}
+
+// NotificationBackend delivers notifications through one channel: a desktop
+// notification service, or the user's open terminals
+type NotificationBackend interface {
+	// Name identifies the backend in config and messages
+	Name() string
+
+	// Send delivers a notification. It doesn't wait for the user to act on
+	// it.
+	Send(notification Notification) error
+}
+
+// NewDesktopBackend returns the named desktop notification backend, or the
+// one for this platform if name is empty
+func NewDesktopBackend(name string) (NotificationBackend, error) {
+	if name == "" {
+		name = defaultDesktopBackend()
+	}
+
+	switch name {
+	case DesktopBackendMacOS:
+		return macOSBackend{}, nil
+	case DesktopBackendLinux:
+		return linuxBackend{}, nil
+	case DesktopBackendWindows:
+		return windowsBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unknown desktop notification backend %q (want one of: %s)", name, strings.Join(DesktopBackends, ", "))
+	}
+}
+
+// defaultDesktopBackend returns the desktop backend for this platform. Every
+// platform other than macOS and Windows is assumed to have a freedesktop.org
+// notification service, as the BSDs generally do.
+func defaultDesktopBackend() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return DesktopBackendMacOS
+	case "windows":
+		return DesktopBackendWindows
+	default:
+		return DesktopBackendLinux
+	}
+}
+
+// macOSBackend shows Notification Center notifications. Notifications with
+// actions use alerter if it is installed, the only tool that shows buttons;
+// others use terminal-notifier if it is installed and osascript otherwise.
+// osascript notifications can't run a command when clicked.
+type macOSBackend struct{}
+
+func (macOSBackend) Name() string {
+	return DesktopBackendMacOS
+}
+
+func (macOSBackend) Send(notification Notification) error {
+	if runtime.GOOS != "darwin" {
+		return fmt.Errorf("%w: macOS notifications are only supported on macOS", errDesktopUnsupported)
+	}
+
+	if len(notification.Actions) > 0 {
+		if path, err := exec.LookPath("alerter"); err == nil {
+			return sendAlert(path, notification)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), desktopNotificationTimeout)
+	defer cancel()
+
+	title, message := notification.Title, notification.Message
+	var cmd *exec.Cmd
+	if path, err := exec.LookPath("terminal-notifier"); err == nil {
+		args := []string{"-title", title, "-message", message}
+		if notification.ClickCommand != "" {
+			args = append(args, "-execute", notification.ClickCommand)
+		}
+		// #nosec G204 -- arguments are passed directly, not through a shell
+		cmd = exec.CommandContext(ctx, path, args...)
+	} else {
+		// Pass the text as script arguments rather than interpolating it into
+		// the script, so quotes in context names can't alter the AppleScript
+		// #nosec G204 -- the script is constant; text is passed as arguments
+		cmd = exec.CommandContext(ctx, "osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			title, message)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to send macOS notification: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// sendAlert shows a notification with action buttons using alerter, which
+// stays up until it is answered or alertTimeout passes, and then prints the
+// answer. It waits for the answer in the background.
+func sendAlert(path string, notification Notification) error {
+	labels := make([]string, len(notification.Actions))
+	for i, action := range notification.Actions {
+		labels[i] = action.Label
+	}
+
+	// Bound the wait in case alerter never answers
+	ctx, cancel := context.WithTimeout(context.Background(), alertTimeout+time.Minute)
+
+	// #nosec G204 -- arguments are passed directly, not through a shell
+	cmd := exec.CommandContext(ctx, path,
+		"-title", notification.Title,
+		"-message", notification.Message,
+		"-actions", strings.Join(labels, ","),
+		"-closeLabel", "Dismiss",
+		"-timeout", fmt.Sprint(int(alertTimeout.Seconds())))
+	var output strings.Builder
+	cmd.Stdout = &output
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("failed to send macOS notification: %w", err)
+	}
+
+	go func() {
+		defer cancel()
+		if err := cmd.Wait(); err != nil {
+			return
+		}
+		if answer := alertAnswer(notification, output.String()); answer != nil {
+			answer()
+		}
+	}()
+	return nil
+}
+
+// alertAnswer returns what to do for alerter's answer to a notification: run
+// the action whose button was pressed, or the click command if the
+// notification itself was clicked. It returns nil for anything else, such as
+// the notification being dismissed or timing out.
+func alertAnswer(notification Notification, output string) func() {
+	answer := strings.TrimSpace(output)
+
+	// With a single action, alerter may only report that it was pressed
+	if answer == alerterActionClicked && len(notification.Actions) == 1 {
+		return notification.Actions[0].Run
+	}
+	for _, action := range notification.Actions {
+		if answer == action.Label {
+			return action.Run
+		}
+	}
+
+	if answer == alerterContentClicked && notification.ClickCommand != "" {
+		return func() {
+			// #nosec G204 -- the click command is built by the daemon, as for
+			// terminal-notifier's -execute
+			_ = exec.Command("/bin/sh", "-c", notification.ClickCommand).Run()
+		}
+	}
+	return nil
+}
+
+// linuxBackend sends notifications to the freedesktop.org notification
+// service over D-Bus: with notify-send (libnotify) if it is installed, and
+// gdbus otherwise. They have no action buttons, and clicking them does
+// nothing.
+type linuxBackend struct{}
+
+func (linuxBackend) Name() string {
+	return DesktopBackendLinux
+}
+
+func (linuxBackend) Send(notification Notification) error {
+	title, message := notification.Title, notification.Message
+	ctx, cancel := context.WithTimeout(context.Background(), desktopNotificationTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if path, err := exec.LookPath("notify-send"); err == nil {
+		// "--" so a message starting with "-" isn't taken for an option
+		// #nosec G204 -- arguments are passed directly, not through a shell
+		cmd = exec.CommandContext(ctx, path, "--app-name="+notificationTitle, "--", title, message)
+	} else if path, err := exec.LookPath("gdbus"); err == nil {
+		// gdbus parses each argument as a GVariant, so the text is quoted
+		// #nosec G204 -- arguments are passed directly, not through a shell
+		cmd = exec.CommandContext(ctx, path, "call", "--session",
+			"--dest", "org.freedesktop.Notifications",
+			"--object-path", "/org/freedesktop/Notifications",
+			"--method", "org.freedesktop.Notifications.Notify",
+			gvariantString(notificationTitle), "0", "''",
+			gvariantString(title), gvariantString(message), "[]", "{}", "-1")
+	} else {
+		return fmt.Errorf("%w: install notify-send (libnotify) or gdbus (GLib)", errDesktopUnsupported)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to send desktop notification: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// gvariantString quotes s as a GVariant text format string
+func gvariantString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// windowsBackend shows Windows toast notifications, through Windows
+// PowerShell, which can load the WinRT notification APIs. They have no
+// action buttons, and clicking them does nothing.
+type windowsBackend struct{}
+
+func (windowsBackend) Name() string {
+	return DesktopBackendWindows
+}
+
+func (windowsBackend) Send(notification Notification) error {
+	title, message := notification.Title, notification.Message
+	if runtime.GOOS != "windows" {
+		return fmt.Errorf("%w: toast notifications are only supported on Windows", errDesktopUnsupported)
+	}
+
+	path, err := exec.LookPath("powershell.exe")
+	if err != nil {
+		return fmt.Errorf("%w: Windows PowerShell (powershell.exe) not found", errDesktopUnsupported)
+	}
+
+	// PowerShell is slow to start, so allow it longer than other backends
+	ctx, cancel := context.WithTimeout(context.Background(), 2*desktopNotificationTimeout)
+	defer cancel()
+
+	// #nosec G204 -- the script is constant; text is passed in the environment
+	cmd := exec.CommandContext(ctx, path, "-NoProfile", "-NonInteractive", "-Command", windowsToastScript)
+	cmd.Env = append(os.Environ(),
+		"KUBECTX_TIMEOUT_NOTIFICATION_APP="+windowsToastAppID,
+		"KUBECTX_TIMEOUT_NOTIFICATION_TITLE="+title,
+		"KUBECTX_TIMEOUT_NOTIFICATION_MESSAGE="+message)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to send toast notification: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// terminalBackend writes a line to each of the user's open terminals
+type terminalBackend struct{}
+
+func (terminalBackend) Name() string {
+	return NotificationMethodTerminal
+}
+
+func (terminalBackend) Send(notification Notification) error {
+	return writeToUserTerminals(notification.Message)
+}
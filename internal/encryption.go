@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedPrefix starts the state file and each history line when they
+// are encrypted. Plain state and history are JSON, so they never start
+// with it.
+const encryptedPrefix = "enc:"
+
+// stateCipherVersion is the first byte of every sealed record, naming how
+// it was sealed: AES-256-GCM with a key derived by scrypt from the secret
+// and the salt that follows it
+const stateCipherVersion byte = 1
+
+// stateKeySize is the AES-256 key size
+const stateKeySize = 32
+
+// stateSaltSize is the size of the random salt keys are derived with
+const stateSaltSize = 16
+
+// scrypt cost parameters, the recommended ones for interactive use. Each
+// process derives a key once per salt, and every record beside the same
+// state file shares one.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// stateSaltFileName holds the salt new records are sealed with, beside the
+// state file and history log
+const stateSaltFileName = "state.salt"
+
+// ErrStateEncrypted is returned when reading an encrypted state file or
+// history log without the key, typically because state.encrypt was turned
+// off after it was written
+var ErrStateEncrypted = errors.New("encrypted with state.encrypt; enable it to read this file")
+
+// stateCipher seals and opens the state file and history lines with
+// AES-256-GCM, under keys derived from a secret: the passphrase in
+// state.key_file or the key in the Keychain
+type stateCipher struct {
+	secret []byte
+	salt   []byte
+
+	// aeads caches the cipher for each salt seen, as deriving a key is slow
+	// by design
+	mu    sync.Mutex
+	aeads map[string]cipher.AEAD
+}
+
+// newStateCipher creates a cipher that seals with a key derived from secret
+// and salt, and opens records sealed with any salt
+func newStateCipher(secret, salt []byte) (*stateCipher, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("encryption secret must not be empty")
+	}
+	if len(salt) != stateSaltSize {
+		return nil, fmt.Errorf("encryption salt must be %d bytes, got %d", stateSaltSize, len(salt))
+	}
+	c := &stateCipher{secret: secret, salt: salt, aeads: make(map[string]cipher.AEAD)}
+	if _, err := c.aeadFor(salt); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// aeadFor returns the cipher keyed by the secret and salt
+func (c *stateCipher) aeadFor(salt []byte) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if aead, ok := c.aeads[string(salt)]; ok {
+		return aead, nil
+	}
+
+	key, err := scrypt.Key(c.secret, salt, scryptN, scryptR, scryptP, stateKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	c.aeads[string(salt)] = aead
+	return aead, nil
+}
+
+// seal encrypts data into a single line of text starting with
+// encryptedPrefix: the version byte, salt, nonce, and ciphertext, base64
+// encoded. A nil cipher returns data unchanged.
+func (c *stateCipher) seal(data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	aead, err := c.aeadFor(c.salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := 1 + stateSaltSize + aead.NonceSize()
+	sealed := make([]byte, header, header+len(data)+aead.Overhead())
+	sealed[0] = stateCipherVersion
+	copy(sealed[1:], c.salt)
+	nonce := sealed[1+stateSaltSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed = aead.Seal(sealed, nonce, data, nil)
+
+	out := make([]byte, len(encryptedPrefix)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(out, encryptedPrefix)
+	base64.StdEncoding.Encode(out[len(encryptedPrefix):], sealed)
+	return out, nil
+}
+
+// open decrypts data sealed by seal. Data without encryptedPrefix is
+// plaintext written before encryption was turned on, and is returned
+// unchanged; encrypted data needs a cipher.
+func (c *stateCipher) open(data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, ErrStateEncrypted
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data[len(encryptedPrefix):])))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted data: %w", err)
+	}
+	if len(sealed) == 0 || sealed[0] != stateCipherVersion {
+		return nil, fmt.Errorf("encrypted data has an unknown format; it may be from a newer version")
+	}
+	if len(sealed) < 1+stateSaltSize {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	aead, err := c.aeadFor(sealed[1 : 1+stateSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[1+stateSaltSize:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (wrong key?): %w", err)
+	}
+	return plain, nil
+}
+
+// loadStateSalt returns the salt records in dir are sealed with, creating a
+// random one the first time
+func loadStateSalt(dir string) ([]byte, error) {
+	path := filepath.Join(dir, stateSaltFileName)
+	for {
+		// #nosec G304 -- path is derived from the state file path
+		salt, err := os.ReadFile(path)
+		if err == nil {
+			if len(salt) != stateSaltSize {
+				return nil, fmt.Errorf("%s is not a valid salt; remove it to create another", path)
+			}
+			return salt, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read encryption salt: %w", err)
+		}
+
+		salt = make([]byte, stateSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate encryption salt: %w", err)
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create state directory: %w", err)
+		}
+		// #nosec G304 -- path is derived from the state file path
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue // Another process just created it
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create encryption salt: %w", err)
+		}
+		_, err = f.Write(salt)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(path) // Don't leave a partial salt behind
+			return nil, fmt.Errorf("failed to write encryption salt: %w", err)
+		}
+		return salt, nil
+	}
+}
+
+// newStateCipherIn creates a cipher for the state file or history log in
+// dir, sealing with the salt kept there
+func newStateCipherIn(dir string, secret []byte) (*stateCipher, error) {
+	salt, err := loadStateSalt(dir)
+	if err != nil {
+		return nil, err
+	}
+	return newStateCipher(secret, salt)
+}
+
+// isEncrypted reports whether data was written by seal
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedPrefix))
+}
+
+// LoadStateKey returns the secret the state file and history log's
+// encryption keys are derived from: the passphrase in state.key_file if
+// set, and otherwise a random key kept in the macOS Keychain, where it is
+// created on first use
+func LoadStateKey(config StateConfig) ([]byte, error) {
+	if config.KeyFile == "" {
+		return keychainStateKey()
+	}
+
+	path, err := expandConfigPath(config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state.key_file: %w", err)
+	}
+	// Like ssh with private keys, refuse a key others can read
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("state.key_file %s must not be accessible by other users (chmod 600)", path)
+	}
+	// #nosec G304 -- path is the user's configured key file
+	passphrase, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state.key_file: %w", err)
+	}
+	passphrase = bytes.TrimSpace(passphrase)
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("state.key_file %s is empty", path)
+	}
+
+	return passphrase, nil
+}
+
+// stateKeyForConfig returns the encryption secret if state.encrypt is on, or
+// nil otherwise
+func stateKeyForConfig(config *Config) ([]byte, error) {
+	if config == nil || !config.State.Encrypt {
+		return nil, nil
+	}
+	key, err := LoadStateKey(config.State)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state encryption key: %w", err)
+	}
+	return key, nil
+}
+
+// OpenStateManager creates a state manager for the file at path that
+// encrypts it when config turns on state.encrypt
+func OpenStateManager(path string, config *Config) (*StateManager, error) {
+	sm, err := NewStateManager(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := stateKeyForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		if err := sm.SetEncryptionKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return sm, nil
+}
+
+// OpenHistory creates the history log kept beside the state file at
+// statePath, encrypting it when config turns on state.encrypt
+func OpenHistory(statePath string, config *Config) (*History, error) {
+	h := NewHistory(HistoryPathForState(statePath))
+	key, err := stateKeyForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		if err := h.SetEncryptionKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testStateKey and testStateSalt are a fixed encryption secret and salt
+// for tests
+var (
+	testStateKey  = bytes.Repeat([]byte{7}, stateKeySize)
+	testStateSalt = bytes.Repeat([]byte{1}, stateSaltSize)
+)
+
+func TestStateCipher(t *testing.T) {
+	c, err := newStateCipher(testStateKey, testStateSalt)
+	if err != nil {
+		t.Fatalf("newStateCipher() error = %v", err)
+	}
+
+	plain := []byte(`{"current_context":"customer-prod"}`)
+	sealed, err := c.seal(plain)
+	if err != nil {
+		t.Fatalf("seal() error = %v", err)
+	}
+	if !isEncrypted(sealed) || bytes.Contains(sealed, []byte("customer-prod")) || bytes.ContainsRune(sealed, '\n') {
+		t.Errorf("Expected one encrypted line without the context name, got %q", sealed)
+	}
+	if opened, err := c.open(sealed); err != nil || !bytes.Equal(opened, plain) {
+		t.Errorf("open() = %q, %v, want %q", opened, err, plain)
+	}
+
+	// The record names its format and salt
+	raw, err := base64.StdEncoding.DecodeString(string(sealed[len(encryptedPrefix):]))
+	if err != nil {
+		t.Fatalf("Failed to decode sealed record: %v", err)
+	}
+	if raw[0] != stateCipherVersion || !bytes.Equal(raw[1:1+stateSaltSize], testStateSalt) {
+		t.Errorf("Expected the version byte and salt first, got %x", raw[:1+stateSaltSize])
+	}
+
+	// Plaintext from before encryption was turned on passes through
+	if opened, err := c.open(plain); err != nil || !bytes.Equal(opened, plain) {
+		t.Errorf("open() of plaintext = %q, %v", opened, err)
+	}
+
+	var none *stateCipher
+	if _, err := none.open(sealed); !errors.Is(err, ErrStateEncrypted) {
+		t.Errorf("open() without a key error = %v, want %v", err, ErrStateEncrypted)
+	}
+
+	other, _ := newStateCipher(bytes.Repeat([]byte{8}, stateKeySize), testStateSalt)
+	if _, err := other.open(sealed); err == nil {
+		t.Error("Expected open() with the wrong key to fail")
+	}
+
+	// Records sealed with another salt open with the same secret
+	resalted, _ := newStateCipher(testStateKey, bytes.Repeat([]byte{2}, stateSaltSize))
+	if opened, err := resalted.open(sealed); err != nil || !bytes.Equal(opened, plain) {
+		t.Errorf("open() with another salt = %q, %v, want %q", opened, err, plain)
+	}
+
+	raw[0] = stateCipherVersion + 1
+	future := append([]byte(encryptedPrefix), base64.StdEncoding.EncodeToString(raw)...)
+	if _, err := c.open(future); err == nil {
+		t.Error("Expected open() of an unknown format to fail")
+	}
+
+	if _, err := newStateCipher(nil, testStateSalt); err == nil {
+		t.Error("Expected an empty secret to be rejected")
+	}
+	if _, err := newStateCipher(testStateKey, []byte("short")); err == nil {
+		t.Error("Expected a short salt to be rejected")
+	}
+}
+
+func TestLoadStateSalt(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+
+	salt, err := loadStateSalt(dir)
+	if err != nil {
+		t.Fatalf("loadStateSalt() error = %v", err)
+	}
+	if len(salt) != stateSaltSize {
+		t.Errorf("Expected a %d-byte salt, got %d", stateSaltSize, len(salt))
+	}
+	info, err := os.Stat(filepath.Join(dir, stateSaltFileName))
+	if err != nil {
+		t.Fatalf("Expected the salt saved: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the salt file 0600, got %v", info.Mode().Perm())
+	}
+
+	if again, err := loadStateSalt(dir); err != nil || !bytes.Equal(again, salt) {
+		t.Errorf("Expected the same salt again, got %x, %v", again, err)
+	}
+	if other, _ := loadStateSalt(t.TempDir()); bytes.Equal(other, salt) {
+		t.Error("Expected each state directory to get its own salt")
+	}
+}
+
+func TestStateManagerEncryption(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	sm, err := NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager() error = %v", err)
+	}
+	if err := RecordActivity(sm, "customer-prod"); err != nil {
+		t.Fatalf("RecordActivity() error = %v", err)
+	}
+
+	// Plain state is still read once encryption is on, and encrypted on
+	// the next write
+	if err := sm.SetEncryptionKey(testStateKey); err != nil {
+		t.Fatalf("SetEncryptionKey() error = %v", err)
+	}
+	state, err := sm.Load()
+	if err != nil {
+		t.Fatalf("Load() of plain state error = %v", err)
+	}
+	if err := sm.Save(state); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("Failed to read state file: %v", err)
+	}
+	if !isEncrypted(data) || bytes.Contains(data, []byte("customer-prod")) {
+		t.Errorf("Expected the state file encrypted, got %q", data)
+	}
+	if _, context, err := LastActivity(sm); err != nil || context != "customer-prod" {
+		t.Errorf("GetLastActivity() = %q, %v", context, err)
+	}
+
+	// Without the key it can't be read
+	plain, _ := NewStateManager(statePath)
+	if _, err := plain.Load(); !errors.Is(err, ErrStateEncrypted) {
+		t.Errorf("Load() without the key error = %v, want %v", err, ErrStateEncrypted)
+	}
+}
+
+func TestHistoryEncryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyFileName)
+	h := NewHistory(path)
+	if err := h.Append(HistoryEvent{Type: HistoryActivity, Context: "customer-a"}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	if err := h.SetEncryptionKey(testStateKey); err != nil {
+		t.Fatalf("SetEncryptionKey() error = %v", err)
+	}
+	if err := h.Append(HistoryEvent{Type: HistorySwitch, Context: "local", FromContext: "customer-b"}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	// Plain and encrypted events are read together
+	events, err := h.Read(HistoryFilter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(events) != 2 || events[0].Context != "customer-a" || events[1].FromContext != "customer-b" {
+		t.Errorf("Expected both events, got %+v", events)
+	}
+
+	// Resealing encrypts what was written in plain
+	if err := h.Reseal(); err != nil {
+		t.Fatalf("Reseal() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if !isEncrypted([]byte(line)) || strings.Contains(line, "customer") {
+			t.Errorf("Expected every line encrypted, got %q", line)
+		}
+	}
+	if events, err := h.Read(HistoryFilter{}); err != nil || len(events) != 2 {
+		t.Errorf("Read() after Reseal() = %+v, %v", events, err)
+	}
+
+	if _, err := NewHistory(path).Read(HistoryFilter{}); !errors.Is(err, ErrStateEncrypted) {
+		t.Errorf("Read() without the key error = %v, want %v", err, ErrStateEncrypted)
+	}
+}
+
+func TestLoadStateKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "state.key")
+	if err := os.WriteFile(keyFile, []byte("correct horse battery staple\n"), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	key, err := LoadStateKey(StateConfig{Encrypt: true, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("LoadStateKey() error = %v", err)
+	}
+	if string(key) != "correct horse battery staple" {
+		t.Errorf("Expected the passphrase without its newline, got %q", key)
+	}
+	if again, _ := LoadStateKey(StateConfig{Encrypt: true, KeyFile: keyFile}); !bytes.Equal(key, again) {
+		t.Error("Expected the same passphrase to give the same key")
+	}
+
+	if err := os.Chmod(keyFile, 0644); err != nil {
+		t.Fatalf("Failed to chmod key file: %v", err)
+	}
+	if _, err := LoadStateKey(StateConfig{Encrypt: true, KeyFile: keyFile}); err == nil {
+		t.Error("Expected a key file readable by others to be rejected")
+	}
+
+	empty := filepath.Join(dir, "empty.key")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	if _, err := LoadStateKey(StateConfig{Encrypt: true, KeyFile: empty}); err == nil {
+		t.Error("Expected an empty key file to be rejected")
+	}
+}
+
+func TestOpenStateManagerEncryptsWhenConfigured(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "state.key")
+	if err := os.WriteFile(keyFile, []byte("passphrase"), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	statePath := filepath.Join(dir, "state.json")
+
+	config := &Config{State: StateConfig{Encrypt: true, KeyFile: keyFile}}
+	sm, err := OpenStateManager(statePath, config)
+	if err != nil {
+		t.Fatalf("OpenStateManager() error = %v", err)
+	}
+	if err := RecordActivity(sm, "customer-prod"); err != nil {
+		t.Fatalf("RecordActivity() error = %v", err)
+	}
+	if data, _ := os.ReadFile(statePath); !isEncrypted(data) {
+		t.Errorf("Expected the state file encrypted, got %q", data)
+	}
+
+	// Off by default
+	plain, err := OpenStateManager(filepath.Join(dir, "plain.json"), &Config{})
+	if err != nil {
+		t.Fatalf("OpenStateManager() error = %v", err)
+	}
+	if plain.cipher != nil {
+		t.Error("Expected no encryption without state.encrypt")
+	}
+}
+
+func TestConfigStateEncryptNeedsKeyFile(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("the Keychain holds the key on macOS")
+	}
+	config := DefaultConfig()
+	config.DefaultContext = "local"
+	config.State.Encrypt = true
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "state.key_file") {
+		t.Errorf("Validate() error = %v, want one about state.key_file", err)
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextSources recognizes the tools that name the contexts they write to
+// kubeconfig, by the context name's prefix
+var contextSources = []struct {
+	prefix string
+	source string
+}{
+	{"arn:aws:eks:", "aws eks update-kubeconfig"},
+	{"gke_", "gcloud container clusters get-credentials"},
+	{"kind-", "kind"},
+	{"k3d-", "k3d"},
+	{"minikube", "minikube"},
+	{"docker-desktop", "Docker Desktop"},
+	{"rancher-desktop", "Rancher Desktop"},
+	{"orbstack", "OrbStack"},
+}
+
+// guessContextSource returns the tool that likely wrote a context to
+// kubeconfig, judging by its name, or "" if it can't be told. Editors and
+// IDE plugins such as Lens or VS Code leave no trace and can't be told.
+func guessContextSource(context string) string {
+	for _, s := range contextSources {
+		if strings.HasPrefix(context, s.prefix) {
+			return s.source
+		}
+	}
+	return ""
+}
+
+// detectExternalSwitch compares the current context with the one recorded
+// with the last activity. A mismatch is a switch made without the shell
+// integration, by an IDE plugin, a cloud CLI, or an edit the file watcher
+// missed, and counts as fresh activity in the new context so it isn't
+// switched away from at once on the old context's clock.
+func (d *Daemon) detectExternalSwitch() error {
+	currentContext, err := d.switcher.CurrentContext()
+	if err != nil {
+		// The timeout check reports it
+		return nil
+	}
+
+	_, lastContext, err := LastActivity(d.stateManager)
+	if err != nil {
+		return fmt.Errorf("%w: failed to get last activity: %w", errStateUnavailable, err)
+	}
+	if lastContext == "" || lastContext == currentContext {
+		return nil
+	}
+
+	reason := "changed outside kubectx-timeout"
+	source := guessContextSource(currentContext)
+	if source != "" {
+		reason += ", likely by " + source
+	}
+	d.logger.Info("Detected context switch made outside kubectx-timeout, resetting activity timer",
+		"from", lastContext, "context", currentContext, "source", source)
+	d.recordHistory(HistoryEvent{
+		Type:        HistoryContextChange,
+		Context:     currentContext,
+		FromContext: lastContext,
+		Reason:      reason,
+	})
+
+	if err := RecordActivity(d.stateManager, currentContext); err != nil {
+		return fmt.Errorf("%w: failed to record activity: %w", errStateUnavailable, err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDaemonDetectsExternalSwitch(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+	d.history = NewHistory(filepath.Join(t.TempDir(), historyFileName))
+
+	// Idle for an hour in staging, then gcloud made a GKE cluster current
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "staging"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	switcher.current = "gke_acme_us-east1_prod"
+
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 0 {
+		t.Errorf("Expected no switch right after an external switch, got %v", switcher.switches)
+	}
+	lastActivity, context, _ := LastActivity(store)
+	if context != "gke_acme_us-east1_prod" || time.Since(lastActivity) > time.Minute {
+		t.Errorf("Expected fresh activity in the new context, got %q at %v", context, lastActivity)
+	}
+
+	events, err := d.history.Read(HistoryFilter{Type: HistoryContextChange})
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(events) != 1 || events[0].FromContext != "staging" ||
+		events[0].Reason != "changed outside kubectx-timeout, likely by gcloud container clusters get-credentials" {
+		t.Errorf("Expected the change in the history, got %+v", events)
+	}
+
+	// Once recorded it times out as usual
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: switcher.current}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+	if len(switcher.switches) != 1 || switcher.switches[0] != "local" {
+		t.Errorf("Expected a switch to local, got %v", switcher.switches)
+	}
+}
+
+func TestGuessContextSource(t *testing.T) {
+	tests := map[string]string{
+		"arn:aws:eks:us-east-1:123456789012:cluster/prod": "aws eks update-kubeconfig",
+		"gke_acme_us-east1_prod":                          "gcloud container clusters get-credentials",
+		"kind-dev":                                        "kind",
+		"docker-desktop":                                  "Docker Desktop",
+		"production":                                      "",
+	}
+	for context, want := range tests {
+		if got := guessContextSource(context); got != want {
+			t.Errorf("guessContextSource(%q) = %q, want %q", context, got, want)
+		}
+	}
+}
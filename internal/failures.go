@@ -0,0 +1,58 @@
+package internal
+
+import "errors"
+
+// Failure types, which commands report with their own exit codes. Errors of
+// these types keep their own messages; test for them with errors.Is.
+var (
+	// ErrConfigInvalid is a configuration that can't be read or isn't valid
+	ErrConfigInvalid = errors.New("configuration error")
+	// ErrAlreadyInstalled is an install of something that is already there
+	ErrAlreadyInstalled = errors.New("already installed")
+	// ErrSwitchRefused is a switch the safety settings don't allow
+	ErrSwitchRefused = errors.New("switch refused by safety policy")
+	// ErrKubectlFailed is kubectl failing, timing out, or missing
+	ErrKubectlFailed = errors.New("kubectl failed")
+)
+
+// failureTypes names each failure type, so the control socket can tell a
+// client which one the daemon ran into
+var failureTypes = map[string]error{
+	"config":            ErrConfigInvalid,
+	"already_installed": ErrAlreadyInstalled,
+	"switch_refused":    ErrSwitchRefused,
+	"kubectl":           ErrKubectlFailed,
+}
+
+// failure is an error marked as one of the failure types
+type failure struct {
+	err  error
+	kind error
+}
+
+func (f *failure) Error() string {
+	return f.err.Error()
+}
+
+func (f *failure) Unwrap() []error {
+	return []error{f.err, f.kind}
+}
+
+// MarkFailure marks err as the failure type kind, keeping its message.
+// A nil err stays nil.
+func MarkFailure(err, kind error) error {
+	if err == nil {
+		return nil
+	}
+	return &failure{err: err, kind: kind}
+}
+
+// failureType returns the name of err's failure type, or "" if it has none
+func failureType(err error) string {
+	for name, kind := range failureTypes {
+		if errors.Is(err, kind) {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarkFailure(t *testing.T) {
+	cause := errors.New("exit status 1")
+	err := MarkFailure(fmt.Errorf("kubectl failed to run: %w", cause), ErrKubectlFailed)
+
+	if err.Error() != "kubectl failed to run: exit status 1" {
+		t.Errorf("Error() = %q, want the original message", err.Error())
+	}
+	if !errors.Is(err, ErrKubectlFailed) {
+		t.Error("Expected the error to be ErrKubectlFailed")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Expected the error to still wrap its cause")
+	}
+	if errors.Is(err, ErrSwitchRefused) {
+		t.Error("Expected the error not to be ErrSwitchRefused")
+	}
+
+	// Wrapping keeps the failure type
+	if got := failureType(fmt.Errorf("context switch failed: %w", err)); got != "kubectl" {
+		t.Errorf("failureType() = %q, want kubectl", got)
+	}
+	if got := failureType(cause); got != "" {
+		t.Errorf("failureType() = %q for an unmarked error, want none", got)
+	}
+
+	if MarkFailure(nil, ErrKubectlFailed) != nil {
+		t.Error("MarkFailure(nil) should be nil")
+	}
+}
+
+func TestLoadConfigFailureType(t *testing.T) {
+	for name, content := range map[string]string{
+		"unparsable": "timeout: [unclosed",
+		"invalid":    "default_context: prod\nsafety:\n  never_switch_to: [prod]\n",
+	} {
+		configPath := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		if _, err := LoadConfig(configPath); !errors.Is(err, ErrConfigInvalid) {
+			t.Errorf("%s: LoadConfig() error = %v, want ErrConfigInvalid", name, err)
+		}
+	}
+}
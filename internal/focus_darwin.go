@@ -0,0 +1,36 @@
+//go:build darwin
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// FocusActive reports whether a Focus (Do Not Disturb) is on. It reads the
+// assertions database macOS 12 and later keep Focus state in, which records
+// a Focus turned on by hand or from Control Center, and falls back to the
+// Do Not Disturb preference of earlier releases. A Focus turned on by its
+// schedule isn't recorded there; use notifications.quiet_hours for those.
+func FocusActive() (bool, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	// #nosec G304 -- fixed path under the user's home directory
+	data, err := os.ReadFile(filepath.Join(home, "Library", "DoNotDisturb", "DB", "Assertions.json"))
+	if err == nil {
+		return parseFocusAssertions(data)
+	}
+
+	// #nosec G204 -- command and arguments are hardcoded, not user input
+	output, legacyErr := exec.Command("defaults", "-currentHost", "read", "com.apple.notificationcenterui", "doNotDisturb").Output()
+	if legacyErr != nil {
+		return false, fmt.Errorf("failed to read Focus state: %w", err)
+	}
+	return strings.TrimSpace(string(output)) == "1", nil
+}
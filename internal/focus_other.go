@@ -0,0 +1,11 @@
+//go:build !darwin
+
+package internal
+
+import "errors"
+
+// FocusActive reports that reading Focus state is not implemented on this
+// platform
+func FocusActive() (bool, error) {
+	return false, errors.New("focus detection not supported on this platform")
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// heartbeatFileName is the liveness file's name within the state directory
+const heartbeatFileName = "heartbeat.json"
+
+// DefaultHealthMaxAge is how old the heartbeat may be before healthcheck
+// calls the daemon wedged. The daemon beats every statusSummaryInterval.
+const DefaultHealthMaxAge = time.Minute
+
+// Heartbeat is the liveness file the daemon rewrites on every pass through
+// its main loop, so monitoring can tell a running daemon from a wedged one
+type Heartbeat struct {
+	PID       int       `json:"pid"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// LastCheck is when the last timeout check finished, and
+	// LastCheckError why it failed, or "" if it succeeded
+	LastCheck      time.Time `json:"last_check,omitempty"`
+	LastCheckError string    `json:"last_check_error,omitempty"`
+
+	// WatcherProblems describes file watchers that keep stopping, which
+	// the daemon restarts with backoff
+	WatcherProblems []string `json:"watcher_problems,omitempty"`
+}
+
+// HeartbeatPathForState returns the heartbeat path that belongs with a
+// state file
+func HeartbeatPathForState(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), heartbeatFileName)
+}
+
+// WriteHeartbeat atomically replaces the heartbeat file at path
+func WriteHeartbeat(path string, heartbeat *Heartbeat) error {
+	data, err := json.Marshal(heartbeat)
+	if err != nil {
+		return fmt.Errorf("failed to marshal heartbeat: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+heartbeatFileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create heartbeat: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath) // Don't leave a partial write behind
+		return fmt.Errorf("failed to write heartbeat: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath) // Don't leave a partial write behind
+		return fmt.Errorf("failed to write heartbeat: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath) // Don't leave a partial write behind
+		return fmt.Errorf("failed to rename heartbeat: %w", err)
+	}
+	return nil
+}
+
+// ReadHeartbeat reads the heartbeat file at path
+func ReadHeartbeat(path string) (*Heartbeat, error) {
+	// #nosec G304 -- path is the heartbeat path in the state directory
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var heartbeat Heartbeat
+	if err := json.Unmarshal(data, &heartbeat); err != nil {
+		return nil, fmt.Errorf("failed to parse heartbeat: %w", err)
+	}
+	return &heartbeat, nil
+}
+
+// CheckHealth returns what is wrong with the daemon, if anything: it isn't
+// running, its heartbeat is older than maxAge, its last timeout check
+// failed, or a file watcher keeps stopping. An empty result means it's
+// healthy.
+func CheckHealth(pidFile *PIDFile, heartbeatPath string, maxAge time.Duration, now time.Time) []string {
+	if !pidFile.IsRunning() {
+		return []string{"daemon is not running"}
+	}
+
+	heartbeat, err := ReadHeartbeat(heartbeatPath)
+	if err != nil {
+		return []string{fmt.Sprintf("no heartbeat: %v", err)}
+	}
+
+	var problems []string
+	if pid, err := pidFile.ReadPID(); err == nil && pid != heartbeat.PID {
+		problems = append(problems, fmt.Sprintf("heartbeat was written by PID %d, not the running daemon (PID %d)", heartbeat.PID, pid))
+	}
+	if age := now.Sub(heartbeat.UpdatedAt); age > maxAge {
+		problems = append(problems, fmt.Sprintf("heartbeat is %s old, so the daemon looks stuck", age.Round(time.Second)))
+	}
+	switch {
+	case heartbeat.LastCheck.IsZero():
+		problems = append(problems, "no timeout check has finished yet")
+	case heartbeat.LastCheckError != "":
+		problems = append(problems, "last timeout check failed: "+heartbeat.LastCheckError)
+	}
+	return append(problems, heartbeat.WatcherProblems...)
+}
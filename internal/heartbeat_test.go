@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckHealth(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := NewPIDFileWithPath(filepath.Join(dir, "daemon.pid"))
+	heartbeatPath := filepath.Join(dir, heartbeatFileName)
+	now := time.Now()
+
+	if problems := CheckHealth(pidFile, heartbeatPath, time.Minute, now); len(problems) != 1 || problems[0] != "daemon is not running" {
+		t.Errorf("CheckHealth() without a daemon = %q", problems)
+	}
+
+	// This process stands in for the daemon
+	if err := pidFile.Acquire(); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer func() { _ = pidFile.Release() }()
+
+	tests := []struct {
+		name      string
+		heartbeat Heartbeat
+		want      string
+	}{
+		{"healthy", Heartbeat{PID: os.Getpid(), UpdatedAt: now.Add(-5 * time.Second), LastCheck: now.Add(-5 * time.Second)}, ""},
+		{"stuck", Heartbeat{PID: os.Getpid(), UpdatedAt: now.Add(-10 * time.Minute), LastCheck: now.Add(-10 * time.Minute)}, "heartbeat is 10m0s old"},
+		{"failing", Heartbeat{PID: os.Getpid(), UpdatedAt: now, LastCheck: now, LastCheckError: "kubectl not found"}, "last timeout check failed: kubectl not found"},
+		{"starting", Heartbeat{PID: os.Getpid(), UpdatedAt: now}, "no timeout check has finished yet"},
+		{"left over", Heartbeat{PID: os.Getpid() + 1, UpdatedAt: now, LastCheck: now}, "not the running daemon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := WriteHeartbeat(heartbeatPath, &tt.heartbeat); err != nil {
+				t.Fatalf("WriteHeartbeat() error = %v", err)
+			}
+			problems := CheckHealth(pidFile, heartbeatPath, time.Minute, now)
+			if tt.want == "" && len(problems) > 0 {
+				t.Errorf("CheckHealth() = %q, want healthy", problems)
+			}
+			if tt.want != "" && (len(problems) != 1 || !strings.Contains(problems[0], tt.want)) {
+				t.Errorf("CheckHealth() = %q, want one problem mentioning %q", problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemonHeartbeat(t *testing.T) {
+	d := newFakeDaemon(t, &fakeSwitcher{current: "production"}, &fakeStateStore{})
+	d.heartbeatPath = filepath.Join(t.TempDir(), heartbeatFileName)
+
+	d.handleCheckResult(errors.New("kubectl not found"))
+	d.beat()
+	heartbeat, err := ReadHeartbeat(d.heartbeatPath)
+	if err != nil {
+		t.Fatalf("ReadHeartbeat() error = %v", err)
+	}
+	if heartbeat.PID != os.Getpid() || heartbeat.LastCheck.IsZero() || heartbeat.LastCheckError != "kubectl not found" {
+		t.Errorf("Expected the failed check in the heartbeat, got %+v", heartbeat)
+	}
+
+	d.handleCheckResult(nil)
+	d.beat()
+	if heartbeat, _ := ReadHeartbeat(d.heartbeatPath); heartbeat == nil || heartbeat.LastCheckError != "" {
+		t.Errorf("Expected a successful check in the heartbeat, got %+v", heartbeat)
+	}
+}
@@ -0,0 +1,343 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// historyFileName is the history log's name within the state directory
+const historyFileName = "history.jsonl"
+
+// maxHistorySize is the size at which the history log is rotated. One
+// rotated log is kept, so history uses at most about twice this.
+const maxHistorySize = 10 * 1024 * 1024
+
+// History event types
+const (
+	// HistoryActivity is kubectl activity that resets the timeout
+	HistoryActivity = "activity"
+	// HistoryContextChange is a context change made outside kubectx-timeout,
+	// detected by the daemon
+	HistoryContextChange = "context_change"
+	// HistorySwitch is a context switch made by kubectx-timeout
+	HistorySwitch = "switch"
+)
+
+// HistoryEvent is one entry in the history log
+type HistoryEvent struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+
+	// Context is the context active after the event, and FromContext the
+	// one before it, for context changes and switches
+	Context     string `json:"context"`
+	FromContext string `json:"from_context,omitempty"`
+
+	// Reason explains what caused the event, e.g. "inactive for 30m0s"
+	Reason string `json:"reason,omitempty"`
+
+	// Kubeconfig is the KUBECONFIG of the per-shell session the event
+	// happened in, or empty for the default kubeconfig
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+
+	// Verb and Resource describe the kubectl command behind activity, as
+	// far as tracking.metadata allows; see KubectlCommand
+	Verb     string `json:"verb,omitempty"`
+	Resource string `json:"resource,omitempty"`
+}
+
+// HistoryFilter selects history events. Zero fields match everything.
+type HistoryFilter struct {
+	// Context matches events in or switching away from this context
+	Context string
+	Type    string
+	Since   time.Time
+	Until   time.Time
+}
+
+// Matches reports whether the event passes the filter
+func (f HistoryFilter) Matches(event HistoryEvent) bool {
+	if f.Context != "" && event.Context != f.Context && event.FromContext != f.Context {
+		return false
+	}
+	if f.Type != "" && event.Type != f.Type {
+		return false
+	}
+	if !f.Since.IsZero() && event.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && event.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// History is an append-only log of activity, context changes, and switches,
+// stored as one JSON object per line. A nil History records nothing.
+type History struct {
+	path string
+	mu   sync.Mutex
+
+	// cipher encrypts each line, if state.encrypt is on
+	cipher *stateCipher
+}
+
+// NewHistory creates a history log at path
+func NewHistory(path string) *History {
+	return &History{path: path}
+}
+
+// SetEncryptionKey makes the history log encrypt each event appended from
+// now on with a key derived from key, as for the state file. Plain events
+// written earlier are still read.
+func (h *History) SetEncryptionKey(key []byte) error {
+	c, err := newStateCipherIn(filepath.Dir(h.path), key)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cipher = c
+	return nil
+}
+
+// GetHistoryPath returns the path to the history log
+func GetHistoryPath() string {
+	return HistoryPathForState(GetStatePath())
+}
+
+// HistoryPathForState returns the history log path for the given state
+// file, which it is kept alongside
+func HistoryPathForState(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), historyFileName)
+}
+
+// Append adds an event to the log, stamping it with the current time if
+// it has none
+func (h *History) Append(event HistoryEvent) error {
+	if h == nil {
+		return nil
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history event: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if data, err = h.cipher.seal(data); err != nil {
+		return fmt.Errorf("failed to encrypt history event: %w", err)
+	}
+	data = append(data, '\n')
+
+	if info, err := os.Stat(h.path); err == nil && info.Size() >= maxHistorySize {
+		if err := os.Rename(h.path, h.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate history log: %w", err)
+		}
+	}
+
+	// O_APPEND keeps lines from the CLI and the daemon from interleaving
+	// #nosec G304 -- path is derived from the state file path
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history log: %w", err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write history log: %w", err)
+	}
+
+	return f.Close()
+}
+
+// Read returns the events matching the filter, oldest first, including
+// those in the rotated log. Lines that can't be parsed are skipped.
+func (h *History) Read(filter HistoryFilter) ([]HistoryEvent, error) {
+	if h == nil {
+		return nil, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var events []HistoryEvent
+	for _, path := range []string{h.path + ".1", h.path} {
+		found, err := readHistoryFile(path, filter, h.cipher)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, found...)
+	}
+
+	return events, nil
+}
+
+// readHistoryFile reads the matching events from one log file, decrypting
+// them with c. A missing file has no events.
+func readHistoryFile(path string, filter HistoryFilter, c *stateCipher) ([]HistoryEvent, error) {
+	// #nosec G304 -- path is derived from the state file path
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history log: %w", err)
+	}
+	defer f.Close()
+
+	var events []HistoryEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, err := c.open(scanner.Bytes())
+		if errors.Is(err, ErrStateEncrypted) {
+			return nil, fmt.Errorf("failed to read history log: %w", err)
+		}
+		var event HistoryEvent
+		if err != nil || json.Unmarshal(line, &event) != nil {
+			// A line cut short by a crash shouldn't hide the rest of the log
+			continue
+		}
+		if filter.Matches(event) {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history log: %w", err)
+	}
+
+	return events, nil
+}
+
+// Prune compacts the log to the retention in config, dropping events older
+// than MaxAge and all but the MaxEntries most recent, and folds the rotated
+// log into the current one. Lines that can't be parsed are dropped too. It
+// returns how many events were removed. An event another process appends
+// while the log is rewritten may be lost.
+func (h *History) Prune(config HistoryConfig, now time.Time) (int, error) {
+	if h == nil {
+		return 0, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var events []HistoryEvent
+	for _, path := range []string{h.path + ".1", h.path} {
+		found, err := readHistoryFile(path, HistoryFilter{}, h.cipher)
+		if err != nil {
+			return 0, err
+		}
+		events = append(events, found...)
+	}
+
+	kept := events
+	if config.MaxAge > 0 {
+		cutoff := now.Add(-config.MaxAge)
+		kept = slices.DeleteFunc(slices.Clone(kept), func(event HistoryEvent) bool {
+			return event.Time.Before(cutoff)
+		})
+	}
+	if config.MaxEntries > 0 && len(kept) > config.MaxEntries {
+		kept = kept[len(kept)-config.MaxEntries:]
+	}
+
+	_, rotatedErr := os.Stat(h.path + ".1")
+	if len(kept) == len(events) && os.IsNotExist(rotatedErr) {
+		return 0, nil
+	}
+
+	if err := h.rewrite(kept); err != nil {
+		return 0, err
+	}
+	return len(events) - len(kept), nil
+}
+
+// Reseal rewrites the log, and the rotated log, if any of it isn't in the
+// current format: plain lines once encryption is on. It runs when the
+// daemon starts, so turning on state.encrypt encrypts the existing history.
+func (h *History) Reseal() error {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cipher == nil {
+		return nil
+	}
+
+	stale := false
+	var events []HistoryEvent
+	for _, path := range []string{h.path + ".1", h.path} {
+		// #nosec G304 -- path is derived from the state file path
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read history log: %w", err)
+		}
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 && !isEncrypted(line) {
+				stale = true
+			}
+		}
+		found, err := readHistoryFile(path, HistoryFilter{}, h.cipher)
+		if err != nil {
+			return err
+		}
+		events = append(events, found...)
+	}
+	if !stale {
+		return nil
+	}
+	return h.rewrite(events)
+}
+
+// rewrite replaces the log with events, in the current format, and removes
+// the rotated log. The caller holds h.mu.
+func (h *History) rewrite(events []HistoryEvent) error {
+	var buf bytes.Buffer
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal history event: %w", err)
+		}
+		if data, err = h.cipher.seal(data); err != nil {
+			return fmt.Errorf("failed to encrypt history event: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	// Replace the log atomically, so a crash never leaves it half written
+	tmpPath := h.path + ".tmp"
+	if err := writeFileSync(tmpPath, buf.Bytes(), 0600); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to write history log: %w", err)
+	}
+	if err := os.Rename(tmpPath, h.path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace history log: %w", err)
+	}
+	if err := os.Remove(h.path + ".1"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove rotated history log: %w", err)
+	}
+	return nil
+}
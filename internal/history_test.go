@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyFileName)
+	h := NewHistory(path)
+
+	// A missing log has no events
+	events, err := h.Read(HistoryFilter{})
+	if err != nil || len(events) != 0 {
+		t.Fatalf("Read() = %v, %v, want no events", events, err)
+	}
+
+	now := time.Now()
+	appends := []HistoryEvent{
+		{Time: now.Add(-2 * time.Hour), Type: HistoryActivity, Context: "prod"},
+		{Time: now.Add(-time.Hour), Type: HistorySwitch, Context: "local", FromContext: "prod", Reason: "inactive for 30m0s"},
+		{Type: HistoryContextChange, Context: "staging", FromContext: "local"},
+	}
+	for _, event := range appends {
+		if err := h.Append(event); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("History log not created: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected history log mode 0600, got %v", info.Mode().Perm())
+	}
+
+	events, err = h.Read(HistoryFilter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	if events[1].Reason != "inactive for 30m0s" || events[1].FromContext != "prod" {
+		t.Errorf("Unexpected switch event: %+v", events[1])
+	}
+	if events[2].Time.IsZero() {
+		t.Error("Expected an event without a time to be stamped")
+	}
+}
+
+func TestHistoryFilter(t *testing.T) {
+	now := time.Now()
+	h := NewHistory(filepath.Join(t.TempDir(), historyFileName))
+	for _, event := range []HistoryEvent{
+		{Time: now.Add(-3 * time.Hour), Type: HistoryActivity, Context: "prod"},
+		{Time: now.Add(-2 * time.Hour), Type: HistorySwitch, Context: "local", FromContext: "prod"},
+		{Time: now.Add(-time.Hour), Type: HistoryActivity, Context: "staging"},
+	} {
+		if err := h.Append(event); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		want   int
+	}{
+		{name: "everything", filter: HistoryFilter{}, want: 3},
+		{name: "context includes switches away", filter: HistoryFilter{Context: "prod"}, want: 2},
+		{name: "type", filter: HistoryFilter{Type: HistoryActivity}, want: 2},
+		{name: "since", filter: HistoryFilter{Since: now.Add(-90 * time.Minute)}, want: 1},
+		{name: "until", filter: HistoryFilter{Until: now.Add(-150 * time.Minute)}, want: 1},
+		{name: "no match", filter: HistoryFilter{Context: "dev"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := h.Read(tt.filter)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(events) != tt.want {
+				t.Errorf("Read() returned %d events, want %d: %+v", len(events), tt.want, events)
+			}
+		})
+	}
+}
+
+func TestHistoryRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyFileName)
+	h := NewHistory(path)
+
+	if err := h.Append(HistoryEvent{Type: HistoryActivity, Context: "old"}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	// Pad the log to the rotation size with blank lines, which are skipped
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open history: %v", err)
+	}
+	if _, err := f.Write(bytes.Repeat([]byte("\n"), maxHistorySize)); err != nil {
+		t.Fatalf("Failed to pad history: %v", err)
+	}
+	_ = f.Close()
+	if err := h.Append(HistoryEvent{Type: HistoryActivity, Context: "new"}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("Expected a rotated log: %v", err)
+	}
+
+	// The rotated log is still read, oldest first
+	events, err := h.Read(HistoryFilter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(events) != 2 || events[0].Context != "old" || events[1].Context != "new" {
+		t.Errorf("Expected events from both logs in order, got %+v", events)
+	}
+}
+
+func TestHistorySkipsMalformedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyFileName)
+	content := `{"time":"2026-10-16T12:00:00Z","type":"activity","context":"prod"}
+{"time":"2026-10-16T12:01:00Z","type":"swi
+{"time":"2026-10-16T12:02:00Z","type":"activity","context":"staging"}
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write history: %v", err)
+	}
+
+	events, err := NewHistory(path).Read(HistoryFilter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(events) != 2 || events[1].Context != "staging" {
+		t.Errorf("Expected the two valid events, got %+v", events)
+	}
+}
+
+func TestHistoryPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), historyFileName)
+	h := NewHistory(path)
+
+	now := time.Now().Truncate(time.Second)
+	for i, context := range []string{"ancient", "old", "recent", "newer", "newest"} {
+		age := time.Duration(4-i) * 24 * time.Hour
+		if i == 0 {
+			age = 90 * 24 * time.Hour
+		}
+		if err := h.Append(HistoryEvent{Time: now.Add(-age), Type: HistoryActivity, Context: context}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	// A rotated log is folded into the compacted one
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("Failed to rotate history: %v", err)
+	}
+	if err := h.Append(HistoryEvent{Time: now, Type: HistorySwitch, Context: "local"}); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	removed, err := h.Prune(HistoryConfig{MaxAge: 30 * 24 * time.Hour}, now)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Prune() by age removed %d events, want 1", removed)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("Expected the rotated log removed, got %v", err)
+	}
+
+	removed, err = h.Prune(HistoryConfig{MaxEntries: 2}, now)
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("Prune() by count removed %d events, want 3", removed)
+	}
+
+	events, err := h.Read(HistoryFilter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(events) != 2 || events[0].Context != "newest" || events[1].Context != "local" {
+		t.Errorf("Expected the two most recent events kept in order, got %+v", events)
+	}
+
+	// Nothing left to remove leaves the log alone
+	if removed, err := h.Prune(HistoryConfig{MaxEntries: 2}, now); err != nil || removed != 0 {
+		t.Errorf("Prune() of a compacted log = %d, %v", removed, err)
+	}
+}
+
+func TestNilHistory(t *testing.T) {
+	var h *History
+	if err := h.Append(HistoryEvent{Type: HistoryActivity}); err != nil {
+		t.Errorf("Append() on nil history error = %v", err)
+	}
+	if events, err := h.Read(HistoryFilter{}); err != nil || events != nil {
+		t.Errorf("Read() on nil history = %v, %v", events, err)
+	}
+}
+
+func TestHistoryPathForState(t *testing.T) {
+	got := HistoryPathForState("/home/user/.local/state/kubectx-timeout/state.json")
+	if want := "/home/user/.local/state/kubectx-timeout/history.jsonl"; got != want {
+		t.Errorf("HistoryPathForState() = %q, want %q", got, want)
+	}
+}
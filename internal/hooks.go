@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Hook names, as used in the hooks config section
+const (
+	// HookOnTimeout runs when a context's timeout elapses, before any grace
+	// period or switch
+	HookOnTimeout = "on_timeout"
+	// HookPreSwitch runs before every switch the daemon makes
+	HookPreSwitch = "pre_switch"
+	// HookPostSwitch runs after every successful switch
+	HookPostSwitch = "post_switch"
+	// HookOnSwitchFailure runs when a switch failed after every retry
+	HookOnSwitchFailure = "on_switch_failure"
+)
+
+// DefaultHookTimeout is how long each hook command may run when
+// hooks.timeout is unset
+const DefaultHookTimeout = 30 * time.Second
+
+// HooksConfig holds shell commands run around context switches, for side
+// effects such as revoking credentials, dropping a VPN, or audit logging
+type HooksConfig struct {
+	OnTimeout  []string `yaml:"on_timeout,omitempty"`
+	PreSwitch  []string `yaml:"pre_switch,omitempty"`
+	PostSwitch []string `yaml:"post_switch,omitempty"`
+
+	OnSwitchFailure []string `yaml:"on_switch_failure,omitempty"`
+
+	// Timeout bounds each command; the command is killed when it expires
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// commands returns the commands configured for a hook
+func (h HooksConfig) commands(hook string) []string {
+	switch hook {
+	case HookOnTimeout:
+		return h.OnTimeout
+	case HookPreSwitch:
+		return h.PreSwitch
+	case HookPostSwitch:
+		return h.PostSwitch
+	case HookOnSwitchFailure:
+		return h.OnSwitchFailure
+	}
+	return nil
+}
+
+// validationErrors returns every problem with the hook settings
+func (h HooksConfig) validationErrors() []error {
+	var errs []error
+	for _, hook := range []string{HookOnTimeout, HookPreSwitch, HookPostSwitch, HookOnSwitchFailure} {
+		for _, command := range h.commands(hook) {
+			if strings.TrimSpace(command) == "" {
+				errs = append(errs, fmt.Errorf("hooks.%s: commands must not be empty", hook))
+				break
+			}
+		}
+	}
+	if h.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("hooks.timeout must not be negative"))
+	}
+	return errs
+}
+
+// HookEnv returns the environment variables describing a switch to hook
+// commands: KUBECTX_HOOK, KUBECTX_FROM_CONTEXT, KUBECTX_TO_CONTEXT, and
+// KUBECTX_SWITCH_REASON, plus KUBECTX_SWITCH_ERROR for a failed switch
+func HookEnv(hook string, event SwitchEvent) []string {
+	env := []string{
+		"KUBECTX_HOOK=" + hook,
+		"KUBECTX_FROM_CONTEXT=" + event.FromContext,
+		"KUBECTX_TO_CONTEXT=" + event.ToContext,
+		"KUBECTX_SWITCH_REASON=" + event.Reason,
+	}
+	if event.Error != "" {
+		env = append(env, "KUBECTX_SWITCH_ERROR="+event.Error)
+	}
+	return env
+}
+
+// Run runs a hook's commands in order with sh, each bounded by the hook
+// timeout, and returns an error for each that failed. A failing command
+// doesn't stop the ones after it.
+func (h HooksConfig) Run(hook string, event SwitchEvent) []error {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = DefaultHookTimeout
+	}
+
+	var errs []error
+	for _, command := range h.commands(hook) {
+		if err := runHookCommand(command, HookEnv(hook, event), timeout); err != nil {
+			errs = append(errs, fmt.Errorf("%s hook %q failed: %w", hook, command, err))
+		}
+	}
+	return errs
+}
+
+// runHookCommand runs one hook command through the shell: sh, or cmd on
+// Windows
+func runHookCommand(command string, env []string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+
+	// #nosec G204 -- hook commands come from the user's own config file
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	cmd.Env = append(os.Environ(), env...)
+	// Don't wait on background processes the command left holding the output
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHooksRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hooks.log")
+	hooks := HooksConfig{
+		PreSwitch: []string{
+			`echo "$KUBECTX_HOOK $KUBECTX_FROM_CONTEXT $KUBECTX_TO_CONTEXT $KUBECTX_SWITCH_REASON" >> "` + out + `"`,
+			`echo "denied" >&2; exit 3`,
+			`echo second >> "` + out + `"`,
+		},
+	}
+
+	errs := hooks.Run(HookPreSwitch, SwitchEvent{FromContext: "prod", ToContext: "local", Reason: "inactive for 30m0s"})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "exit status 3: denied") {
+		t.Errorf("Run() = %v, want the failing command reported with its output", errs)
+	}
+
+	// Commands after a failing one still run
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read hook output: %v", err)
+	}
+	if want := "pre_switch prod local inactive for 30m0s\nsecond\n"; string(data) != want {
+		t.Errorf("Hook output = %q, want %q", data, want)
+	}
+
+	if errs := hooks.Run(HookPostSwitch, SwitchEvent{}); len(errs) != 0 {
+		t.Errorf("Run() with no commands = %v, want none", errs)
+	}
+}
+
+func TestHooksTimeout(t *testing.T) {
+	hooks := HooksConfig{OnTimeout: []string{"sleep 10"}, Timeout: 100 * time.Millisecond}
+
+	start := time.Now()
+	errs := hooks.Run(HookOnTimeout, SwitchEvent{})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "timed out") {
+		t.Errorf("Run() = %v, want a timeout", errs)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() took %v, want the command killed at the timeout", elapsed)
+	}
+}
+
+func TestHooksValidation(t *testing.T) {
+	hooks := HooksConfig{PostSwitch: []string{"true", " "}, Timeout: -time.Second}
+	if errs := hooks.validationErrors(); len(errs) != 2 {
+		t.Errorf("validationErrors() = %v, want 2 problems", errs)
+	}
+}
+
+func TestDaemonRunsHooks(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	out := filepath.Join(t.TempDir(), "hooks.log")
+	record := `echo "$KUBECTX_HOOK $KUBECTX_TO_CONTEXT" >> "` + out + `"`
+	configContent := `
+timeout:
+  default: 10m
+  check_interval: 1s
+default_context: local
+notifications:
+  enabled: false
+safety:
+  check_active_kubectl: false
+  validate_default_context: false
+hooks:
+  on_timeout: ['` + record + `']
+  pre_switch: ['` + record + `']
+  post_switch: ['` + record + `']
+`
+	if err := os.WriteFile(d.configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "production"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := d.checkTimeout(); err != nil {
+		t.Fatalf("checkTimeout() error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read hook output: %v", err)
+	}
+	if want := "on_timeout local\npre_switch local\npost_switch local\n"; string(data) != want {
+		t.Errorf("Hook output = %q, want %q", data, want)
+	}
+}
+
+func TestDaemonRunsSwitchFailureHook(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production", switchErr: errors.New("kubeconfig is locked")}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	out := filepath.Join(t.TempDir(), "hooks.log")
+	configContent := `
+timeout:
+  default: 10m
+  check_interval: 1s
+default_context: local
+notifications:
+  enabled: false
+safety:
+  check_active_kubectl: false
+  validate_default_context: false
+hooks:
+  on_switch_failure: ['echo "$KUBECTX_FROM_CONTEXT $KUBECTX_SWITCH_ERROR" >> "` + out + `"']
+`
+	if err := os.WriteFile(d.configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	if err := store.Save(&State{LastActivity: time.Now().Add(-time.Hour), CurrentContext: "production"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := d.checkTimeout(); err == nil {
+		t.Fatal("Expected checkTimeout() to report the failed switch")
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read hook output: %v", err)
+	}
+	if want := "production kubeconfig is locked\n"; string(data) != want {
+		t.Errorf("Hook output = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,311 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContextRisk is how risky a context looks from its name
+type ContextRisk string
+
+// Context risks
+const (
+	// ContextRiskSafe contexts look like local or development clusters
+	ContextRiskSafe ContextRisk = "safe"
+	// ContextRiskProduction contexts look like production or staging
+	ContextRiskProduction ContextRisk = "production"
+	// ContextRiskUnknown contexts match neither list
+	ContextRiskUnknown ContextRisk = "unknown"
+)
+
+// safeContextPatterns indicate a safe/dev context, in priority order
+var safeContextPatterns = []string{
+	"local",
+	"docker-desktop",
+	"minikube",
+	"kind-",
+	"dev",
+	"development",
+	"test",
+}
+
+// dangerousContextPatterns indicate a production or staging context
+var dangerousContextPatterns = []string{
+	"prod",
+	"production",
+	"stage",
+	"staging",
+	"prd",
+}
+
+// InitTimeoutPresets are the timeouts init offers
+var InitTimeoutPresets = []time.Duration{
+	5 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+}
+
+// initProductionTimeout is the timeout init suggests for production-like
+// contexts
+const initProductionTimeout = 5 * time.Minute
+
+// initUseDefault is the answer that leaves a context on the default timeout
+const initUseDefault = "default"
+
+// ClassifyContext guesses a context's risk from its name. A name that looks
+// both safe and dangerous, like "dev-prod", counts as production.
+func ClassifyContext(name string) ContextRisk {
+	lower := strings.ToLower(name)
+	for _, pattern := range dangerousContextPatterns {
+		if strings.Contains(lower, pattern) {
+			return ContextRiskProduction
+		}
+	}
+	for _, pattern := range safeContextPatterns {
+		if strings.Contains(lower, pattern) {
+			return ContextRiskSafe
+		}
+	}
+	return ContextRiskUnknown
+}
+
+// PickSafeDefaultContext returns the context that best matches the safe
+// patterns, in their priority order, or "" if none looks safe
+func PickSafeDefaultContext(contexts []string) string {
+	for _, pattern := range safeContextPatterns {
+		for _, ctx := range contexts {
+			if strings.Contains(strings.ToLower(ctx), pattern) && ClassifyContext(ctx) == ContextRiskSafe {
+				return ctx
+			}
+		}
+	}
+	return ""
+}
+
+// InitWizard asks the questions behind the interactive init: the safe
+// default context, the default timeout, and a timeout for each context.
+// Every question has a suggested answer, taken on Enter or end of input,
+// so piping nothing to init still produces a sensible config.
+type InitWizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewInitWizard creates a wizard reading answers from in and writing
+// questions to out
+func NewInitWizard(in io.Reader, out io.Writer) *InitWizard {
+	return &InitWizard{in: bufio.NewReader(in), out: out}
+}
+
+// Run asks about the given contexts and fills in config with the answers.
+// Timeouts already in config are the suggested answers. current is the
+// current context, shown for reference.
+func (w *InitWizard) Run(config *Config, contexts []string, current string) error {
+	if len(contexts) == 0 {
+		return fmt.Errorf("no kubectl contexts available - please configure kubectl first")
+	}
+
+	width := 0
+	for _, ctx := range contexts {
+		width = max(width, len(ctx))
+	}
+	fmt.Fprintln(w.out, "Available kubectl contexts:")
+	for i, ctx := range contexts {
+		line := fmt.Sprintf("  %d. %-*s  %s", i+1, width, ctx, riskLabel(ClassifyContext(ctx)))
+		fmt.Fprintln(w.out, strings.TrimRight(line, " "))
+	}
+	if current != "" {
+		fmt.Fprintf(w.out, "\nCurrent context: %s\n", current)
+	}
+
+	// The safe default context
+	suggested := PickSafeDefaultContext(contexts)
+	if suggested == "" {
+		suggested = contexts[0]
+		for _, ctx := range contexts {
+			if ClassifyContext(ctx) != ContextRiskProduction {
+				suggested = ctx
+				break
+			}
+		}
+	}
+	fmt.Fprintln(w.out)
+	for {
+		answer, err := w.ask("Safe default context to switch to after a timeout (number or name)", suggested)
+		if err != nil {
+			return err
+		}
+		if ctx, ok := pickContext(contexts, answer); ok {
+			config.DefaultContext = ctx
+			break
+		}
+		fmt.Fprintf(w.out, "  %q is not one of the contexts above\n", answer)
+	}
+	if ClassifyContext(config.DefaultContext) == ContextRiskProduction {
+		fmt.Fprintf(w.out, "  ⚠ %s looks like production; timeouts will switch you into it\n", config.DefaultContext)
+	}
+
+	// The default timeout, or a preset that also sets production timeouts
+	presets := make([]string, len(InitTimeoutPresets))
+	for i, preset := range InitTimeoutPresets {
+		presets[i] = formatInitTimeout(preset)
+	}
+	suggestion := formatInitTimeout(config.Timeout.Default)
+	if config.Preset != "" {
+		suggestion = config.Preset
+	}
+	fmt.Fprintln(w.out, "\nTimeout presets:")
+	for _, preset := range TimeoutPresets {
+		fmt.Fprintf(w.out, "  %s\n", preset.Describe())
+	}
+	for {
+		answer, err := w.ask(fmt.Sprintf("Default timeout (%s, any duration, or a preset)", strings.Join(presets, ", ")), suggestion)
+		if err != nil {
+			return err
+		}
+		if preset, ok := LookupPreset(answer); ok {
+			preset.Apply(config)
+			break
+		}
+		if timeout, err := parseInitTimeout(answer); err == nil {
+			config.Timeout.Default = timeout
+			break
+		}
+		fmt.Fprintf(w.out, "  Invalid timeout %q\n", answer)
+	}
+
+	// A timeout for each context we could switch away from
+	others := slices.DeleteFunc(slices.Clone(contexts), func(ctx string) bool { return ctx == config.DefaultContext })
+	if len(others) > 0 {
+		fmt.Fprintf(w.out, "\nTimeout for each context (%q uses the default timeout):\n", initUseDefault)
+	}
+	productionTimeout := initProductionTimeout
+	if preset, ok := LookupPreset(config.Preset); ok {
+		productionTimeout = preset.Production
+	}
+	var production []string
+	for _, ctx := range others {
+		risk := ClassifyContext(ctx)
+		suggestion := initUseDefault
+		if risk == ContextRiskProduction {
+			suggestion = formatInitTimeout(productionTimeout)
+			production = append(production, ctx)
+		}
+		if preset := config.Contexts[ctx].Timeout; preset > 0 {
+			// Given on the command line
+			suggestion = formatInitTimeout(preset)
+		}
+
+		prompt := "  " + ctx
+		if risk == ContextRiskProduction {
+			prompt += " (" + riskLabel(risk) + ")"
+		}
+		for {
+			answer, err := w.ask(prompt, suggestion)
+			if err != nil {
+				return err
+			}
+			if answer == initUseDefault {
+				delete(config.Contexts, ctx)
+				break
+			}
+			if timeout, err := parseInitTimeout(answer); err == nil {
+				if config.Contexts == nil {
+					config.Contexts = map[string]Context{}
+				}
+				config.Contexts[ctx] = Context{Timeout: timeout}
+				break
+			}
+			fmt.Fprintf(w.out, "  Invalid timeout %q\n", answer)
+		}
+	}
+
+	// Keep timeouts from ever switching into production
+	if len(production) > 0 {
+		fmt.Fprintln(w.out)
+		answer, err := w.ask(fmt.Sprintf("Never switch to production-like contexts (%s)?", strings.Join(production, ", ")), "y")
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(strings.ToLower(answer), "y") {
+			config.Safety.NeverSwitchTo = production
+		}
+	}
+
+	return nil
+}
+
+// ask prints a question with its suggested answer and reads the reply,
+// returning the suggestion for an empty reply or at end of input
+func (w *InitWizard) ask(question, suggestion string) (string, error) {
+	fmt.Fprintf(w.out, "%s [%s]: ", question, suggestion)
+	line, err := w.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	if errors.Is(err, io.EOF) {
+		// Finish the prompt's line, since the user never pressed Enter
+		fmt.Fprintln(w.out)
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return suggestion, nil
+}
+
+// pickContext resolves an answer naming a context, by its number in the
+// list or its name
+func pickContext(contexts []string, answer string) (string, bool) {
+	if n, err := strconv.Atoi(answer); err == nil {
+		if n >= 1 && n <= len(contexts) {
+			return contexts[n-1], true
+		}
+		return "", false
+	}
+	if slices.Contains(contexts, answer) {
+		return answer, true
+	}
+	return "", false
+}
+
+// parseInitTimeout parses a timeout answer, which must be positive
+func parseInitTimeout(answer string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(answer)
+	if err != nil {
+		return 0, err
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be positive")
+	}
+	return timeout, nil
+}
+
+// formatInitTimeout formats a timeout briefly, as "5m" rather than "5m0s",
+// unless that would lose precision
+func formatInitTimeout(d time.Duration) string {
+	if short := FormatPromptDuration(d); short != "now" {
+		if parsed, err := time.ParseDuration(short); err == nil && parsed == d {
+			return short
+		}
+	}
+	return d.String()
+}
+
+// riskLabel describes a risk in the context list
+func riskLabel(risk ContextRisk) string {
+	switch risk {
+	case ContextRiskSafe:
+		return "safe"
+	case ContextRiskProduction:
+		return "looks like production"
+	}
+	return ""
+}
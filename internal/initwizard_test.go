@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyContext(t *testing.T) {
+	tests := []struct {
+		name string
+		want ContextRisk
+	}{
+		{"docker-desktop", ContextRiskSafe},
+		{"kind-dev", ContextRiskSafe},
+		{"prod-eu", ContextRiskProduction},
+		{"arn:aws:eks:us-east-1:123:cluster/staging", ContextRiskProduction},
+		{"dev-prod", ContextRiskProduction},
+		{"team-x", ContextRiskUnknown},
+	}
+	for _, tt := range tests {
+		if got := ClassifyContext(tt.name); got != tt.want {
+			t.Errorf("ClassifyContext(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPickSafeDefaultContext(t *testing.T) {
+	if got := PickSafeDefaultContext([]string{"prod", "dev-prod", "minikube", "local"}); got != "local" {
+		t.Errorf("PickSafeDefaultContext() = %q, want the highest-priority safe context", got)
+	}
+	if got := PickSafeDefaultContext([]string{"prod", "team-x"}); got != "" {
+		t.Errorf("PickSafeDefaultContext() = %q, want none", got)
+	}
+}
+
+func TestInitWizardSuggestions(t *testing.T) {
+	config := DefaultConfig()
+	var out bytes.Buffer
+
+	// No input at all takes every suggestion
+	err := NewInitWizard(strings.NewReader(""), &out).Run(config, []string{"prod-eu", "team-x", "minikube"}, "prod-eu")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if config.DefaultContext != "minikube" {
+		t.Errorf("DefaultContext = %q, want minikube", config.DefaultContext)
+	}
+	if config.Timeout.Default != 30*time.Minute {
+		t.Errorf("Timeout.Default = %v, want 30m", config.Timeout.Default)
+	}
+	if len(config.Contexts) != 1 || config.Contexts["prod-eu"].Timeout != 5*time.Minute {
+		t.Errorf("Contexts = %+v, want prod-eu at 5m only", config.Contexts)
+	}
+	if len(config.Safety.NeverSwitchTo) != 1 || config.Safety.NeverSwitchTo[0] != "prod-eu" {
+		t.Errorf("NeverSwitchTo = %v, want [prod-eu]", config.Safety.NeverSwitchTo)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "prod-eu   looks like production") {
+		t.Errorf("output should flag prod-eu:\n%s", out.String())
+	}
+}
+
+func TestInitWizardAnswers(t *testing.T) {
+	config := DefaultConfig()
+	var out bytes.Buffer
+
+	// A bad answer is asked again
+	answers := "nope\n2\n45m\n1h\nbogus\ndefault\nn\n"
+	err := NewInitWizard(strings.NewReader(answers), &out).Run(config, []string{"prod-eu", "team-x", "staging"}, "")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if config.DefaultContext != "team-x" {
+		t.Errorf("DefaultContext = %q, want team-x", config.DefaultContext)
+	}
+	if config.Timeout.Default != 45*time.Minute {
+		t.Errorf("Timeout.Default = %v, want 45m", config.Timeout.Default)
+	}
+	if len(config.Contexts) != 1 || config.Contexts["prod-eu"].Timeout != time.Hour {
+		t.Errorf("Contexts = %+v, want prod-eu at 1h only", config.Contexts)
+	}
+	if len(config.Safety.NeverSwitchTo) != 0 {
+		t.Errorf("NeverSwitchTo = %v, want none", config.Safety.NeverSwitchTo)
+	}
+	for _, want := range []string{`"nope" is not one of the contexts above`, `Invalid timeout "bogus"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestInitWizardNoContexts(t *testing.T) {
+	if err := NewInitWizard(strings.NewReader(""), &bytes.Buffer{}).Run(DefaultConfig(), nil, ""); err == nil {
+		t.Error("Run() should fail without contexts")
+	}
+}
+
+func TestInitWizardKeepsGivenTimeouts(t *testing.T) {
+	config := DefaultConfig()
+	config.Timeout.Default = 90 * time.Second
+	config.Contexts = map[string]Context{"team-x": {Timeout: 10 * time.Minute}, "team-y": {Timeout: time.Hour}}
+
+	// Accept the default context and default timeout, keep team-x's given
+	// timeout, and drop team-y's
+	answers := "\n\n\ndefault\n"
+	var out bytes.Buffer
+	err := NewInitWizard(strings.NewReader(answers), &out).Run(config, []string{"local", "team-x", "team-y"}, "")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if config.Timeout.Default != 90*time.Second {
+		t.Errorf("Timeout.Default = %v, want 1m30s", config.Timeout.Default)
+	}
+	if len(config.Contexts) != 1 || config.Contexts["team-x"].Timeout != 10*time.Minute {
+		t.Errorf("Contexts = %+v, want team-x at 10m only", config.Contexts)
+	}
+	if !strings.Contains(out.String(), "team-x [10m]") {
+		t.Errorf("output should suggest the given timeout:\n%s", out.String())
+	}
+}
@@ -0,0 +1,70 @@
+//go:build darwin
+
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// The Keychain item holding the state encryption key
+const (
+	keychainService = "kubectx-timeout"
+	keychainAccount = "state-encryption-key"
+)
+
+// keychainItemNotFound is security's exit status for a missing item
+const keychainItemNotFound = 44
+
+// keychainStateKey returns the state encryption key from the login
+// Keychain, creating a random one the first time
+func keychainStateKey() ([]byte, error) {
+	key, err := readKeychainStateKey()
+	var exitErr *exec.ExitError
+	if err == nil || !errors.As(err, &exitErr) || exitErr.ExitCode() != keychainItemNotFound {
+		return key, err
+	}
+
+	key = make([]byte, stateKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+	// With -w last and no value, security prompts for the password and
+	// its confirmation on stdin, so the key never appears in a process
+	// listing as an argument would
+	// #nosec G204 -- command and arguments are hardcoded, not user input
+	cmd := exec.Command("security", "add-generic-password",
+		"-s", keychainService, "-a", keychainAccount, "-w")
+	encoded := hex.EncodeToString(key)
+	cmd.Stdin = strings.NewReader(encoded + "\n" + encoded + "\n")
+	// Without a controlling terminal it can't prompt there instead
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Run(); err != nil {
+		// Another process may have just created it
+		if key, readErr := readKeychainStateKey(); readErr == nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("failed to store encryption key in the Keychain: %w", err)
+	}
+	return key, nil
+}
+
+// readKeychainStateKey reads the key from the Keychain
+func readKeychainStateKey() ([]byte, error) {
+	// #nosec G204 -- command and arguments are hardcoded, not user input
+	output, err := exec.Command("security", "find-generic-password",
+		"-s", keychainService, "-a", keychainAccount, "-w").Output()
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(output)))
+	if err != nil || len(key) != stateKeySize {
+		return nil, fmt.Errorf("the Keychain item %s/%s is not a valid encryption key", keychainService, keychainAccount)
+	}
+	return key, nil
+}
@@ -0,0 +1,10 @@
+//go:build !darwin
+
+package internal
+
+import "errors"
+
+// keychainStateKey reports that there is no Keychain on this platform
+func keychainStateKey() ([]byte, error) {
+	return nil, errors.New("no Keychain on this platform: set state.key_file")
+}
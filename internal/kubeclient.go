@@ -0,0 +1,423 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kube clients, as used in the kube_client setting
+const (
+	// KubeClientKubectl runs kubectl for every kubeconfig read and change
+	KubeClientKubectl = "kubectl"
+	// KubeClientNative reads and edits the kubeconfig files directly
+	KubeClientNative = "native"
+)
+
+// KubeClient reads and changes the current context of a kubeconfig.
+// ExecKubeClient runs kubectl; NativeKubeClient edits the files itself, and
+// tests may substitute a fake.
+type KubeClient interface {
+	// CurrentContext returns the current context, or an error if none is set
+	CurrentContext() (string, error)
+	// ListContexts returns every context's name
+	ListContexts() ([]string, error)
+	// UseContext makes name the current context
+	UseContext(name string) error
+	// UnsetContext leaves the kubeconfig with no current context
+	UnsetContext() error
+}
+
+// KubeconfigClient is a KubeClient that can act on a KUBECONFIG other than
+// its own, for per-shell kubeconfig sessions. Both built-in clients
+// implement it.
+type KubeconfigClient interface {
+	KubeClient
+	// ForKubeconfig returns a client acting on the given KUBECONFIG
+	ForKubeconfig(kubeconfig string) KubeClient
+}
+
+// namespaceClient is a KubeClient that can also read and set namespaces,
+// for activity tracking and timeout.reset_namespace
+type namespaceClient interface {
+	CurrentNamespace() (string, error)
+	SetContextNamespace(context, namespace string) error
+}
+
+// NewKubeClient returns the client named by kube_client for the given
+// KUBECONFIG, or the inherited one if it is empty. Anything but native
+// runs kubectl.
+func NewKubeClient(name, kubeconfig string) KubeClient {
+	if name == KubeClientNative {
+		return NativeKubeClient{Kubeconfig: kubeconfig}
+	}
+	return ExecKubeClient{Kubeconfig: kubeconfig}
+}
+
+// ExecKubeClient runs kubectl, bounded by kubectl_timeout
+type ExecKubeClient struct {
+	// Kubeconfig is the KUBECONFIG kubectl is run with, or "" for the
+	// inherited one
+	Kubeconfig string
+}
+
+// ForKubeconfig returns a client running kubectl with the given KUBECONFIG
+func (c ExecKubeClient) ForKubeconfig(kubeconfig string) KubeClient {
+	return ExecKubeClient{Kubeconfig: kubeconfig}
+}
+
+// CurrentContext returns the current kubectl context
+func (c ExecKubeClient) CurrentContext() (string, error) {
+	output, err := runKubectl(c.Kubeconfig, "config", "current-context")
+	if err != nil {
+		return "", fmt.Errorf("failed to get current context: %w", err)
+	}
+
+	context := strings.TrimSpace(string(output))
+	if context == "" {
+		return "", fmt.Errorf("no current context set")
+	}
+
+	return context, nil
+}
+
+// ListContexts returns the names kubectl config get-contexts lists
+func (c ExecKubeClient) ListContexts() ([]string, error) {
+	output, err := runKubectl(c.Kubeconfig, "config", "get-contexts", "-o", "name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contexts: %w", err)
+	}
+
+	contextsStr := strings.TrimSpace(string(output))
+	if contextsStr == "" {
+		return []string{}, nil
+	}
+
+	return strings.Split(contextsStr, "\n"), nil
+}
+
+// UseContext runs kubectl config use-context
+func (c ExecKubeClient) UseContext(name string) error {
+	// name is validated against kubectl config get-contexts output before use
+	if _, err := runKubectl(c.Kubeconfig, "config", "use-context", name); err != nil {
+		return fmt.Errorf("kubectl command failed: %w", err)
+	}
+	return nil
+}
+
+// UnsetContext runs kubectl config unset current-context
+func (c ExecKubeClient) UnsetContext() error {
+	if _, err := runKubectl(c.Kubeconfig, "config", "unset", "current-context"); err != nil {
+		return fmt.Errorf("kubectl command failed: %w", err)
+	}
+	return nil
+}
+
+// CurrentNamespace returns the current context's namespace, which is
+// "default" if the context doesn't set one
+func (c ExecKubeClient) CurrentNamespace() (string, error) {
+	output, err := runKubectl(c.Kubeconfig, "config", "view", "--minify", "-o", "jsonpath={..namespace}")
+	if err != nil {
+		return "", fmt.Errorf("failed to get current namespace: %w", err)
+	}
+
+	namespace := strings.TrimSpace(string(output))
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	return namespace, nil
+}
+
+// SetContextNamespace runs kubectl config set-context --namespace
+func (c ExecKubeClient) SetContextNamespace(context, namespace string) error {
+	if _, err := runKubectl(c.Kubeconfig, "config", "set-context", context, "--namespace="+namespace); err != nil {
+		return fmt.Errorf("kubectl command failed: %w", err)
+	}
+	return nil
+}
+
+// NativeKubeClient reads and edits kubeconfig files without kubectl,
+// merging them the way kubectl does: the first file to set the current
+// context or define a context wins. It avoids starting kubectl, and the
+// auth plugins kubectl may load, for what are only file edits. Changes hold
+// kubectl's lock on the files and replace them atomically, so they don't
+// race with kubectl or kubectx writing them.
+type NativeKubeClient struct {
+	// Kubeconfig is the KUBECONFIG to act on, or "" for the inherited one
+	Kubeconfig string
+}
+
+// kubeconfigDocument is one parsed kubeconfig file
+type kubeconfigDocument struct {
+	path string
+	root *yaml.Node
+}
+
+// ForKubeconfig returns a client acting on the given KUBECONFIG
+func (c NativeKubeClient) ForKubeconfig(kubeconfig string) KubeClient {
+	return NativeKubeClient{Kubeconfig: kubeconfig}
+}
+
+// load parses every existing file of the KUBECONFIG, in order
+func (c NativeKubeClient) load() ([]kubeconfigDocument, error) {
+	var docs []kubeconfigDocument
+	for _, path := range c.paths() {
+		// #nosec G304 -- path comes from KUBECONFIG or the default kubeconfig location
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubeconfig %s: %w", path, err)
+		}
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse kubeconfig %s: %w", path, err)
+		}
+		root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if len(doc.Content) > 0 {
+			root = doc.Content[0]
+		}
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("failed to parse kubeconfig %s: not a mapping", path)
+		}
+		docs = append(docs, kubeconfigDocument{path: path, root: root})
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no kubeconfig found")
+	}
+	return docs, nil
+}
+
+// paths returns the files of the KUBECONFIG, in order
+func (c NativeKubeClient) paths() []string {
+	kubeconfig := c.Kubeconfig
+	if kubeconfig == "" {
+		kubeconfig = os.Getenv("KUBECONFIG")
+	}
+	return kubeconfigPathsFor(kubeconfig)
+}
+
+// edit locks every existing file of the KUBECONFIG, loads them, and saves
+// those change returns, so nothing else writes them in between. Locks are
+// taken in KUBECONFIG order. action describes the change in errors loading
+// the files.
+func (c NativeKubeClient) edit(action string, change func(docs []kubeconfigDocument) ([]kubeconfigDocument, error)) error {
+	for _, path := range c.paths() {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		unlock, err := lockKubeconfig(path)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	docs, err := c.load()
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	changed, err := change(docs)
+	if err != nil {
+		return err
+	}
+	for _, doc := range changed {
+		if err := doc.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save writes a kubeconfig file back, keeping its permissions
+func (d kubeconfigDocument) save() error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(d.root); err != nil {
+		return fmt.Errorf("failed to encode kubeconfig %s: %w", d.path, err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode kubeconfig %s: %w", d.path, err)
+	}
+
+	if err := writeKubeconfigFile(d.path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write kubeconfig %s: %w", d.path, err)
+	}
+	return nil
+}
+
+// currentContext returns the current context and the file setting it
+func (c NativeKubeClient) currentContext(docs []kubeconfigDocument) (string, int) {
+	for i, doc := range docs {
+		if value := yamlMappingValue(doc.root, "current-context"); value != nil && value.Value != "" {
+			return value.Value, i
+		}
+	}
+	return "", -1
+}
+
+// findContext returns the context definition with the given name
+func (c NativeKubeClient) findContext(docs []kubeconfigDocument, name string) (*yaml.Node, int) {
+	for i, doc := range docs {
+		contexts := yamlMappingValue(doc.root, "contexts")
+		if contexts == nil || contexts.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, entry := range contexts.Content {
+			if n := yamlMappingValue(entry, "name"); n != nil && n.Value == name {
+				return entry, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+// CurrentContext returns the current context
+func (c NativeKubeClient) CurrentContext() (string, error) {
+	docs, err := c.load()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current context: %w", err)
+	}
+	context, _ := c.currentContext(docs)
+	if context == "" {
+		return "", fmt.Errorf("no current context set")
+	}
+	return context, nil
+}
+
+// ListContexts returns every context's name, sorted as kubectl lists them
+func (c NativeKubeClient) ListContexts() ([]string, error) {
+	docs, err := c.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contexts: %w", err)
+	}
+
+	names := []string{}
+	for _, doc := range docs {
+		contexts := yamlMappingValue(doc.root, "contexts")
+		if contexts == nil || contexts.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, entry := range contexts.Content {
+			if n := yamlMappingValue(entry, "name"); n != nil && n.Value != "" && !slices.Contains(names, n.Value) {
+				names = append(names, n.Value)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// UseContext sets current-context in the file that sets it now, or the
+// first file if none does, as kubectl config use-context does
+func (c NativeKubeClient) UseContext(name string) error {
+	return c.edit("switch context", func(docs []kubeconfigDocument) ([]kubeconfigDocument, error) {
+		if entry, _ := c.findContext(docs, name); entry == nil {
+			return nil, fmt.Errorf("no context exists with the name: %q", name)
+		}
+
+		_, i := c.currentContext(docs)
+		if i < 0 {
+			i = 0
+		}
+		setYAMLMappingValue(docs[i].root, "current-context", name)
+		return docs[i : i+1], nil
+	})
+}
+
+// UnsetContext removes current-context from every file that sets it
+func (c NativeKubeClient) UnsetContext() error {
+	return c.edit("unset current context", func(docs []kubeconfigDocument) ([]kubeconfigDocument, error) {
+		var changed []kubeconfigDocument
+		for _, doc := range docs {
+			if deleteYAMLMappingKey(doc.root, "current-context") {
+				changed = append(changed, doc)
+			}
+		}
+		return changed, nil
+	})
+}
+
+// CurrentNamespace returns the current context's namespace, which is
+// "default" if the context doesn't set one
+func (c NativeKubeClient) CurrentNamespace() (string, error) {
+	docs, err := c.load()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current namespace: %w", err)
+	}
+	context, _ := c.currentContext(docs)
+	if entry, _ := c.findContext(docs, context); entry != nil {
+		if ns := yamlMappingValue(yamlMappingValue(entry, "context"), "namespace"); ns != nil && ns.Value != "" {
+			return ns.Value, nil
+		}
+	}
+	return "default", nil
+}
+
+// SetContextNamespace sets the namespace of a context where it is defined
+func (c NativeKubeClient) SetContextNamespace(context, namespace string) error {
+	return c.edit("set namespace", func(docs []kubeconfigDocument) ([]kubeconfigDocument, error) {
+		entry, i := c.findContext(docs, context)
+		if entry == nil {
+			return nil, fmt.Errorf("no context exists with the name: %q", context)
+		}
+
+		settings := yamlMappingValue(entry, "context")
+		if settings == nil || settings.Kind != yaml.MappingNode {
+			settings = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setYAMLMappingNode(entry, "context", settings)
+		}
+		setYAMLMappingValue(settings, "namespace", namespace)
+		return docs[i : i+1], nil
+	})
+}
+
+// yamlMappingValue returns the value of key in a mapping node, or nil
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setYAMLMappingNode sets key in a mapping node, adding it if missing
+func setYAMLMappingNode(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// setYAMLMappingValue sets key in a mapping node to a string
+func setYAMLMappingValue(node *yaml.Node, key, value string) {
+	setYAMLMappingNode(node, key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+}
+
+// deleteYAMLMappingKey removes key from a mapping node, reporting whether
+// it was there
+func deleteYAMLMappingKey(node *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = slices.Delete(node.Content, i, i+2)
+			return true
+		}
+	}
+	return false
+}
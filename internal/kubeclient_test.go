@@ -0,0 +1,301 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKubeClient is an in-memory KubeClient that fails a set number of
+// context changes. Like kubectl, it refuses contexts not in contexts, if
+// any are listed.
+type fakeKubeClient struct {
+	mu       sync.Mutex
+	current  string
+	contexts []string
+	failures int
+	uses     int
+}
+
+func (f *fakeKubeClient) CurrentContext() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.current == "" {
+		return "", os.ErrNotExist
+	}
+	return f.current, nil
+}
+
+func (f *fakeKubeClient) ListContexts() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.contexts, nil
+}
+
+func (f *fakeKubeClient) UseContext(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.uses++
+	if f.failures > 0 {
+		f.failures--
+		return os.ErrPermission
+	}
+	if len(f.contexts) > 0 && !slices.Contains(f.contexts, name) {
+		return fmt.Errorf("no context exists with the name: %q", name)
+	}
+	f.current = name
+	return nil
+}
+
+func (f *fakeKubeClient) UnsetContext() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.current = ""
+	return nil
+}
+
+// writeKubeconfigs writes a work kubeconfig setting the current context
+// and a personal one adding a context of its own
+func writeKubeconfigs(t *testing.T) (work, personal string) {
+	t.Helper()
+	dir := t.TempDir()
+	work = filepath.Join(dir, "work.yaml")
+	personal = filepath.Join(dir, "personal.yaml")
+
+	workConfig := `apiVersion: v1
+kind: Config
+current-context: production
+contexts:
+  - name: production
+    context:
+      cluster: prod
+      user: admin
+      namespace: web
+  - name: local
+    context:
+      cluster: kind
+users:
+  - name: admin
+    user:
+      token: secret
+`
+	personalConfig := `apiVersion: v1
+kind: Config
+current-context: homelab
+contexts:
+  - name: homelab
+    context:
+      cluster: pi
+  - name: production
+    context:
+      cluster: shadowed
+`
+	if err := os.WriteFile(work, []byte(workConfig), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+	if err := os.WriteFile(personal, []byte(personalConfig), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+	return work, personal
+}
+
+func TestNativeKubeClient(t *testing.T) {
+	work, personal := writeKubeconfigs(t)
+	client := NativeKubeClient{Kubeconfig: work + string(os.PathListSeparator) + personal}
+
+	// The first file to set the current context wins
+	if context, err := client.CurrentContext(); err != nil || context != "production" {
+		t.Errorf("CurrentContext() = %q, %v, want production", context, err)
+	}
+	if namespace, err := client.CurrentNamespace(); err != nil || namespace != "web" {
+		t.Errorf("CurrentNamespace() = %q, %v, want web", namespace, err)
+	}
+
+	contexts, err := client.ListContexts()
+	if err != nil {
+		t.Fatalf("ListContexts() error = %v", err)
+	}
+	if want := []string{"homelab", "local", "production"}; !slices.Equal(contexts, want) {
+		t.Errorf("ListContexts() = %v, want %v", contexts, want)
+	}
+
+	if err := client.UseContext("local"); err != nil {
+		t.Fatalf("UseContext() error = %v", err)
+	}
+	if context, _ := client.CurrentContext(); context != "local" {
+		t.Errorf("Expected local after UseContext(), got %q", context)
+	}
+	if namespace, _ := client.CurrentNamespace(); namespace != "default" {
+		t.Errorf("Expected the default namespace in local, got %q", namespace)
+	}
+	data, err := os.ReadFile(work)
+	if err != nil {
+		t.Fatalf("Failed to read kubeconfig: %v", err)
+	}
+	if !strings.Contains(string(data), "current-context: local") || !strings.Contains(string(data), "token: secret") {
+		t.Errorf("Expected only the current context changed, got:\n%s", data)
+	}
+
+	if err := client.UseContext("staging"); err == nil {
+		t.Error("Expected UseContext() of a missing context to fail")
+	}
+
+	if err := client.SetContextNamespace("local", "kube-system"); err != nil {
+		t.Fatalf("SetContextNamespace() error = %v", err)
+	}
+	if namespace, _ := client.CurrentNamespace(); namespace != "kube-system" {
+		t.Errorf("Expected kube-system after SetContextNamespace(), got %q", namespace)
+	}
+
+	// Unsetting leaves no file to fall back on
+	if err := client.UnsetContext(); err != nil {
+		t.Fatalf("UnsetContext() error = %v", err)
+	}
+	if context, err := client.CurrentContext(); err == nil {
+		t.Errorf("Expected no current context after UnsetContext(), got %q", context)
+	}
+
+	if _, err := (NativeKubeClient{Kubeconfig: filepath.Join(t.TempDir(), "missing")}).CurrentContext(); err == nil {
+		t.Error("Expected an error without any kubeconfig")
+	}
+}
+
+func TestNativeKubeClientLocking(t *testing.T) {
+	work, _ := writeKubeconfigs(t)
+	if err := os.Chmod(work, 0640); err != nil {
+		t.Fatalf("Failed to chmod kubeconfig: %v", err)
+	}
+	link := filepath.Join(t.TempDir(), "config")
+	if err := os.Symlink(work, link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	client := NativeKubeClient{Kubeconfig: link}
+
+	// A change is written through the link, keeping the file's mode, and
+	// leaves no lock or temporary file behind
+	if err := client.UseContext("local"); err != nil {
+		t.Fatalf("UseContext() error = %v", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected the kubeconfig to stay a symlink, got %v, %v", info, err)
+	}
+	if info, err := os.Stat(work); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("Expected the kubeconfig to keep mode 0640, got %v, %v", info, err)
+	}
+	for _, dir := range []string{filepath.Dir(work), filepath.Dir(link)} {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".lock") || strings.HasSuffix(entry.Name(), ".tmp") {
+				t.Errorf("Expected no leftover files, found %s", entry.Name())
+			}
+		}
+	}
+
+	// Another process's lock holds the change off until it times out
+	orig := kubeconfigLockTimeout
+	kubeconfigLockTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { kubeconfigLockTimeout = orig })
+	lockPath := link + ".lock"
+	if err := os.WriteFile(lockPath, nil, 0600); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	if err := client.UseContext("production"); err == nil || !strings.Contains(err.Error(), "locked by another process") {
+		t.Errorf("UseContext() while locked error = %v, want the lock held", err)
+	}
+	if context, _ := client.CurrentContext(); context != "local" {
+		t.Errorf("Expected the locked kubeconfig unchanged, got %q", context)
+	}
+
+	// A stale lock is removed
+	old := time.Now().Add(-2 * kubeconfigLockStale)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+	if err := client.UseContext("production"); err != nil {
+		t.Fatalf("UseContext() with a stale lock error = %v", err)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale lock removed, got %v", err)
+	}
+}
+
+func TestNativeKubeClientConcurrentEdits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	var config strings.Builder
+	config.WriteString("apiVersion: v1\nkind: Config\ncurrent-context: ctx-0\ncontexts:\n")
+	const n = 8
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&config, "  - name: ctx-%d\n    context:\n      cluster: c%d\n", i, i)
+	}
+	if err := os.WriteFile(path, []byte(config.String()), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+	client := NativeKubeClient{Kubeconfig: path}
+
+	// Each edit reads, changes, and writes the whole file; without the
+	// lock, concurrent edits would lose each other's changes
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- client.SetContextNamespace(fmt.Sprintf("ctx-%d", i), fmt.Sprintf("ns-%d", i))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("SetContextNamespace() error = %v", err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		if err := client.UseContext(fmt.Sprintf("ctx-%d", i)); err != nil {
+			t.Fatalf("UseContext() error = %v", err)
+		}
+		if namespace, _ := client.CurrentNamespace(); namespace != fmt.Sprintf("ns-%d", i) {
+			t.Errorf("Namespace of ctx-%d = %q, want ns-%d", i, namespace, i)
+		}
+	}
+}
+
+func TestContextSwitcherWithClient(t *testing.T) {
+	client := &fakeKubeClient{current: "production", contexts: []string{"local", "production"}, failures: 2}
+	cs := NewContextSwitcherWithClient(nil, client)
+	cs.SetRetryPolicy(SwitcherConfig{RetryDelay: 1})
+
+	if err := cs.SwitchContextSafe("local", nil); err != nil {
+		t.Fatalf("SwitchContextSafe() error = %v", err)
+	}
+	if client.current != "local" || client.uses != 3 {
+		t.Errorf("Expected local after 3 attempts, got %q after %d", client.current, client.uses)
+	}
+
+	if err := cs.SwitchContextSafe("staging", nil); err == nil {
+		t.Error("Expected switching to a missing context to fail")
+	}
+}
+
+func TestNewKubeClient(t *testing.T) {
+	if _, ok := NewKubeClient(KubeClientNative, "").(NativeKubeClient); !ok {
+		t.Error("Expected the native client for kube_client: native")
+	}
+	for _, name := range []string{"", KubeClientKubectl} {
+		if _, ok := NewKubeClient(name, "").(ExecKubeClient); !ok {
+			t.Errorf("Expected kubectl for kube_client %q", name)
+		}
+	}
+
+	// Per-shell sessions keep the backend
+	cs := NewContextSwitcherWithClient(nil, NativeKubeClient{})
+	if session, ok := cs.ForKubeconfig("/tmp/kubie.yaml").(*ContextSwitcher); !ok || session.client != (NativeKubeClient{Kubeconfig: "/tmp/kubie.yaml"}) {
+		t.Errorf("Expected a native client for the session, got %+v", session)
+	}
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// kubeconfigLockTimeout is how long a change to a kubeconfig waits for
+// another process's lock on it
+var kubeconfigLockTimeout = 5 * time.Second
+
+// kubeconfigLockStale is the age after which a lock file is assumed to be
+// left behind by a process that died while holding it. kubectl holds its
+// lock only while it writes the file.
+const kubeconfigLockStale = time.Minute
+
+// kubeconfigLockRetry is how often a held lock is tried again
+const kubeconfigLockRetry = 20 * time.Millisecond
+
+// lockKubeconfig takes the lock kubectl and client-go take before writing a
+// kubeconfig file, a path.lock file created exclusively, and returns a
+// function that releases it. It waits up to kubeconfigLockTimeout for
+// another process to release it, and removes a lock that has gone stale.
+func lockKubeconfig(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(kubeconfigLockTimeout)
+	for {
+		// #nosec G304 -- path comes from KUBECONFIG or the default kubeconfig location
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock kubeconfig %s: %w", path, err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > kubeconfigLockStale {
+			// Another process may remove it first; either way, try again
+			_ = os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("kubeconfig %s is locked by another process; remove %s if none is writing it", path, lockPath)
+		}
+		time.Sleep(kubeconfigLockRetry)
+	}
+}
+
+// writeKubeconfigFile replaces a kubeconfig file with data by writing a
+// temporary file beside it and renaming it into place, so readers never see
+// a partial write. A symlinked kubeconfig is written through the link, and
+// the file keeps its permissions.
+func writeKubeconfigFile(path string, data []byte) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+
+	if err := writeFileSync(tmpPath, data, perm); err != nil {
+		_ = os.Remove(tmpPath) // Don't leave a partial write behind
+		return err
+	}
+	// CreateTemp makes the file 0600, which writeFileSync leaves alone
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
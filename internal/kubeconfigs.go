@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// KubeconfigPolicy is the timeout policy for a kubeconfig file of its own,
+// such as one for work and another for personal clusters. The daemon
+// watches and times out each listed file alongside the default kubeconfig.
+// Settings it leaves out keep their values from the rest of the
+// configuration.
+type KubeconfigPolicy struct {
+	// Path is the kubeconfig file; a leading ~ is the home directory
+	Path string `yaml:"path"`
+
+	// Timeout replaces timeout.default
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// DefaultContext replaces default_context, and should be a context in
+	// the file
+	DefaultContext string `yaml:"default_context,omitempty"`
+
+	// Contexts are per-context settings, replacing the contexts entries of
+	// the same name or pattern
+	Contexts map[string]Context `yaml:"contexts,omitempty"`
+}
+
+// cleanKubeconfigPath returns a kubeconfig path with ~ expanded and
+// cleaned, the form kubeconfig sessions are recorded under
+func cleanKubeconfigPath(path string) (string, error) {
+	expanded, err := expandConfigPath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(expanded), nil
+}
+
+// ManagedKubeconfigs returns the kubeconfig files listed under kubeconfigs,
+// with ~ expanded, in the order they are listed
+func (c *Config) ManagedKubeconfigs() []string {
+	var paths []string
+	for _, policy := range c.Kubeconfigs {
+		if path, err := cleanKubeconfigPath(policy.Path); err == nil && path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// kubeconfigPolicy returns the policy listed for a KUBECONFIG, if it names
+// a single listed file
+func (c *Config) kubeconfigPolicy(kubeconfig string) (KubeconfigPolicy, bool) {
+	if kubeconfig == "" || len(filepath.SplitList(kubeconfig)) != 1 {
+		return KubeconfigPolicy{}, false
+	}
+	kubeconfig = filepath.Clean(kubeconfig)
+	for _, policy := range c.Kubeconfigs {
+		if path, err := cleanKubeconfigPath(policy.Path); err == nil && path == kubeconfig {
+			return policy, true
+		}
+	}
+	return KubeconfigPolicy{}, false
+}
+
+// ForKubeconfig returns a copy of the configuration with the settings
+// listed under kubeconfigs for a KUBECONFIG applied, or the configuration
+// itself if it isn't listed
+func (c *Config) ForKubeconfig(kubeconfig string) *Config {
+	policy, ok := c.kubeconfigPolicy(kubeconfig)
+	if !ok {
+		return c
+	}
+	return c.withOverrides(policy.Timeout, policy.DefaultContext, policy.Contexts)
+}
+
+// kubeconfigValidationErrors checks each listed kubeconfig by validating
+// the configuration with its settings applied, reporting only the problems
+// they introduce
+func (c *Config) kubeconfigValidationErrors(baseErrs []error) []error {
+	var errs []error
+
+	base := make(map[string]bool, len(baseErrs))
+	for _, err := range baseErrs {
+		base[err.Error()] = true
+	}
+
+	seen := make(map[string]bool, len(c.Kubeconfigs))
+	for i, policy := range c.Kubeconfigs {
+		key := fmt.Sprintf("kubeconfigs[%d]", i)
+		path, err := cleanKubeconfigPath(policy.Path)
+		switch {
+		case policy.Path == "":
+			errs = append(errs, fmt.Errorf("%s.path is required", key))
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s.path: %w", key, err))
+			continue
+		case !filepath.IsAbs(path):
+			errs = append(errs, fmt.Errorf("%s.path must be absolute or start with ~, got '%s'", key, policy.Path))
+		case seen[path]:
+			errs = append(errs, fmt.Errorf("%s.path '%s' is listed more than once", key, policy.Path))
+		}
+		seen[path] = true
+		if policy.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("%s.timeout must not be negative", key))
+		}
+
+		withPolicy := c.withOverrides(policy.Timeout, policy.DefaultContext, policy.Contexts)
+		withPolicy.Kubeconfigs = nil
+		for _, err := range withPolicy.ValidationErrors() {
+			if !base[err.Error()] {
+				errs = append(errs, fmt.Errorf("%s (%s): %w", key, policy.Path, err))
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigKubeconfigs(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `aliases:
+  wp: work-prod
+timeout:
+  default: 30m
+  check_interval: 30s
+default_context: local
+kubeconfigs:
+  - path: ~/.kube/work.yaml
+    timeout: 15m
+    default_context: work-dev
+    contexts:
+      wp:
+        timeout: 5m
+  - path: /tmp/kube/../personal.yaml
+    default_context: minikube
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	work := filepath.Join(home, ".kube", "work.yaml")
+	if want := []string{work, "/tmp/personal.yaml"}; !slices.Equal(config.ManagedKubeconfigs(), want) {
+		t.Errorf("ManagedKubeconfigs() = %v, want %v", config.ManagedKubeconfigs(), want)
+	}
+
+	withWork := config.ForKubeconfig(work)
+	if withWork.DefaultContext != "work-dev" {
+		t.Errorf("DefaultContext for work = %q, want work-dev", withWork.DefaultContext)
+	}
+	if got := withWork.GetTimeoutForContext("work-staging"); got != 15*time.Minute {
+		t.Errorf("GetTimeoutForContext(work-staging) for work = %v, want 15m", got)
+	}
+	if got := withWork.GetTimeoutForContext("work-prod"); got != 5*time.Minute {
+		t.Errorf("GetTimeoutForContext(work-prod) for work = %v, want 5m", got)
+	}
+
+	withPersonal := config.ForKubeconfig("/tmp/personal.yaml")
+	if withPersonal.DefaultContext != "minikube" || withPersonal.Timeout.Default != 30*time.Minute {
+		t.Errorf("ForKubeconfig(personal) = %q after %v, want minikube after 30m", withPersonal.DefaultContext, withPersonal.Timeout.Default)
+	}
+
+	// Other kubeconfigs, and the default settings, are left alone
+	if got := config.ForKubeconfig("/tmp/kubie-1.yaml"); got != config {
+		t.Error("Expected ForKubeconfig() of an unlisted file to return the configuration itself")
+	}
+	if got := config.ForKubeconfig(work + string(filepath.ListSeparator) + "/tmp/personal.yaml"); got != config {
+		t.Error("Expected ForKubeconfig() of several files to return the configuration itself")
+	}
+	if config.DefaultContext != "local" || config.GetTimeoutForContext("work-prod") != 30*time.Minute {
+		t.Errorf("Expected the top-level settings unchanged, got %q after %v", config.DefaultContext, config.GetTimeoutForContext("work-prod"))
+	}
+}
+
+func TestKubeconfigValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		kubeconfigs []KubeconfigPolicy
+		wantErr     string
+	}{
+		{name: "valid", kubeconfigs: []KubeconfigPolicy{{Path: "~/.kube/work.yaml", DefaultContext: "work-dev"}, {Path: "/tmp/personal.yaml"}}},
+		{name: "no path", kubeconfigs: []KubeconfigPolicy{{DefaultContext: "work-dev"}}, wantErr: "kubeconfigs[0].path is required"},
+		{name: "relative path", kubeconfigs: []KubeconfigPolicy{{Path: "work.yaml"}}, wantErr: "must be absolute or start with ~"},
+		{name: "duplicate", kubeconfigs: []KubeconfigPolicy{{Path: "/tmp/work.yaml"}, {Path: "/tmp/./work.yaml"}}, wantErr: "kubeconfigs[1].path '/tmp/./work.yaml' is listed more than once"},
+		{name: "negative timeout", kubeconfigs: []KubeconfigPolicy{{Path: "/tmp/work.yaml", Timeout: -time.Minute}}, wantErr: "kubeconfigs[0].timeout must not be negative"},
+		{
+			name:        "invalid context pattern",
+			kubeconfigs: []KubeconfigPolicy{{Path: "/tmp/work.yaml", Contexts: map[string]Context{"/prod-(/": {Timeout: time.Minute}}}},
+			wantErr:     "kubeconfigs[0] (/tmp/work.yaml):",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.DefaultContext = "dev"
+			config.Kubeconfigs = tt.kubeconfigs
+
+			errs := config.ValidationErrors()
+			if tt.wantErr == "" {
+				if len(errs) > 0 {
+					t.Fatalf("ValidationErrors() = %v, want none", errs)
+				}
+				return
+			}
+			if !slices.ContainsFunc(errs, func(err error) bool { return strings.Contains(err.Error(), tt.wantErr) }) {
+				t.Errorf("ValidationErrors() = %v, want one containing %q", errs, tt.wantErr)
+			}
+		})
+	}
+}
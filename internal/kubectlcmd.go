@@ -0,0 +1,294 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// KubectlCommand is what record-activity learns from a wrapped command's
+// arguments
+type KubectlCommand struct {
+	// Verb is the subcommand, such as "get" or "apply", or "" if none was
+	// found. Subcommands with their own subcommands include them, as in
+	// "rollout restart".
+	Verb string
+
+	// Resource is the kind of resource the verb acts on, such as "pods" or
+	// "deployments", or "" if the verb takes none. It is always a known
+	// kind, a group-qualified custom resource, or "other"; never a name.
+	Resource string
+
+	// watch and interactive record the flags that keep a command running,
+	// such as logs -f, get -w, and run -it
+	watch       bool
+	interactive bool
+}
+
+// Levels of command metadata kept in the history log
+const (
+	// MetadataOff keeps no command metadata
+	MetadataOff = "off"
+	// MetadataVerb keeps the verb, such as "get"
+	MetadataVerb = "verb"
+	// MetadataVerbResource keeps the verb and the resource kind, such as
+	// "get" and "pods"
+	MetadataVerbResource = "verb+resource"
+)
+
+// kubectlValueFlags are kubectl's global flags that take a separate value,
+// so the value isn't mistaken for the verb
+var kubectlValueFlags = map[string]bool{
+	"-n": true, "--namespace": true,
+	"--context": true, "--cluster": true, "--user": true,
+	"--kubeconfig": true, "-s": true, "--server": true,
+	"--token": true, "--as": true, "--as-group": true, "--as-uid": true,
+	"--certificate-authority": true, "--client-certificate": true, "--client-key": true,
+	"--request-timeout": true, "--cache-dir": true, "-v": true, "--v": true,
+
+	// Common flags of the verbs themselves
+	"-o": true, "--output": true, "-l": true, "--selector": true,
+	"-f": true, "--filename": true, "-c": true, "--container": true,
+	"--field-selector": true, "-L": true, "--label-columns": true,
+	"--sort-by": true, "--for": true, "--timeout": true,
+}
+
+// nestedVerbs are subcommands whose own subcommand decides whether they
+// change the cluster
+var nestedVerbs = map[string]bool{
+	"rollout":     true,
+	"certificate": true,
+	"set":         true,
+}
+
+// writeVerbs change the cluster. Anything else, including unknown verbs,
+// counts as a read. helm's mutating verbs are included, since the shell
+// integration passes helm's arguments too.
+var writeVerbs = map[string]bool{
+	"apply": true, "create": true, "delete": true, "edit": true,
+	"patch": true, "replace": true, "scale": true, "autoscale": true,
+	"label": true, "annotate": true, "expose": true, "run": true,
+	"drain": true, "cordon": true, "uncordon": true, "taint": true,
+	"exec": true, "cp": true, "debug": true,
+	"rollout restart": true, "rollout undo": true, "rollout pause": true, "rollout resume": true,
+	"certificate approve": true, "certificate deny": true,
+	"set env": true, "set image": true, "set resources": true, "set selector": true,
+	"set serviceaccount": true, "set subject": true,
+
+	// helm
+	"install": true, "upgrade": true, "uninstall": true, "rollback": true,
+}
+
+// resourceVerbs take a resource kind as their first argument, as in
+// "get pods" or "delete deploy/web"
+var resourceVerbs = map[string]bool{
+	"get": true, "describe": true, "delete": true, "edit": true,
+	"patch": true, "label": true, "annotate": true, "scale": true,
+	"autoscale": true, "expose": true, "explain": true, "wait": true,
+	"top": true, "create": true,
+	"rollout status": true, "rollout history": true, "rollout restart": true,
+	"rollout undo": true, "rollout pause": true, "rollout resume": true,
+	"set env": true, "set image": true, "set resources": true, "set selector": true,
+	"set serviceaccount": true, "set subject": true,
+}
+
+// podVerbs act on a pod unless given a kind/name, as in "logs deploy/web"
+var podVerbs = map[string]bool{
+	"logs": true, "exec": true, "attach": true, "port-forward": true,
+	"cp": true, "debug": true,
+}
+
+// resourceKinds maps the names, singular names, and short names of the
+// built-in resource kinds to their plural names
+var resourceKinds = map[string]string{}
+
+func init() {
+	for plural, aliases := range map[string][]string{
+		"all":                        nil,
+		"pods":                       {"pod", "po"},
+		"services":                   {"service", "svc"},
+		"deployments":                {"deployment", "deploy"},
+		"replicasets":                {"replicaset", "rs"},
+		"statefulsets":               {"statefulset", "sts"},
+		"daemonsets":                 {"daemonset", "ds"},
+		"replicationcontrollers":     {"replicationcontroller", "rc"},
+		"jobs":                       {"job"},
+		"cronjobs":                   {"cronjob", "cj"},
+		"configmaps":                 {"configmap", "cm"},
+		"secrets":                    {"secret"},
+		"namespaces":                 {"namespace", "ns"},
+		"nodes":                      {"node", "no"},
+		"events":                     {"event", "ev"},
+		"endpoints":                  {"endpoint", "ep"},
+		"endpointslices":             {"endpointslice"},
+		"ingresses":                  {"ingress", "ing"},
+		"ingressclasses":             {"ingressclass"},
+		"networkpolicies":            {"networkpolicy", "netpol"},
+		"persistentvolumes":          {"persistentvolume", "pv"},
+		"persistentvolumeclaims":     {"persistentvolumeclaim", "pvc"},
+		"storageclasses":             {"storageclass", "sc"},
+		"serviceaccounts":            {"serviceaccount", "sa"},
+		"roles":                      {"role"},
+		"rolebindings":               {"rolebinding"},
+		"clusterroles":               {"clusterrole"},
+		"clusterrolebindings":        {"clusterrolebinding"},
+		"horizontalpodautoscalers":   {"horizontalpodautoscaler", "hpa"},
+		"poddisruptionbudgets":       {"poddisruptionbudget", "pdb"},
+		"priorityclasses":            {"priorityclass", "pc"},
+		"limitranges":                {"limitrange", "limits"},
+		"resourcequotas":             {"resourcequota", "quota"},
+		"leases":                     {"lease"},
+		"customresourcedefinitions":  {"customresourcedefinition", "crd", "crds"},
+		"certificatesigningrequests": {"certificatesigningrequest", "csr"},
+	} {
+		resourceKinds[plural] = plural
+		for _, alias := range aliases {
+			resourceKinds[alias] = plural
+		}
+	}
+}
+
+// ParseKubectlCommand finds the verb in a command's arguments, skipping
+// flags, and the kind of resource it acts on
+func ParseKubectlCommand(args []string) KubectlCommand {
+	var cmd KubectlCommand
+	verbDone := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			// The rest is a command to run in a container
+			break
+		}
+		if strings.HasPrefix(arg, "-") {
+			flag, _, _ := strings.Cut(arg, "=")
+			switch flag {
+			case "-w", "--watch", "--watch-only":
+				cmd.watch = true
+			case "-i", "-t", "-it", "-ti", "--stdin", "--tty":
+				cmd.interactive = true
+			case "-f", "--follow":
+				// logs -f follows rather than naming a file
+				if cmd.Verb == "logs" {
+					cmd.watch = true
+					continue
+				}
+			}
+			if kubectlValueFlags[arg] {
+				i++ // Skip the flag's value
+			}
+			continue
+		}
+
+		switch {
+		case cmd.Verb == "":
+			cmd.Verb = arg
+			verbDone = !nestedVerbs[arg]
+		case !verbDone:
+			// The subcommand of a nested verb
+			cmd.Verb += " " + arg
+			verbDone = true
+		case cmd.Resource == "" && (resourceVerbs[cmd.Verb] || podVerbs[cmd.Verb]):
+			cmd.Resource = resourceKind(cmd.Verb, arg)
+		}
+	}
+	return cmd
+}
+
+// resourceKind returns the kind of resource a verb's first argument names.
+// Anything that isn't recognizably a kind is "other", so a resource's name
+// is never mistaken for its kind and recorded.
+func resourceKind(verb, arg string) string {
+	kind, _, named := strings.Cut(arg, "/")
+	if podVerbs[verb] && !named {
+		// A bare pod name
+		return "pods"
+	}
+
+	var kinds []string
+	for _, k := range strings.Split(strings.ToLower(kind), ",") {
+		if plural, ok := resourceKinds[k]; ok {
+			kinds = append(kinds, plural)
+		} else if customResourcePattern.MatchString(k) {
+			// A custom resource such as certificates.cert-manager.io
+			kinds = append(kinds, k)
+		} else {
+			kinds = append(kinds, "other")
+		}
+	}
+	return strings.Join(kinds, ",")
+}
+
+// customResourcePattern matches a group-qualified resource kind
+var customResourcePattern = regexp.MustCompile(`^[a-z0-9]+(\.[a-z0-9-]+)+$`)
+
+// Redact returns the command with only what the metadata level keeps. The
+// zero KubectlCommand keeps nothing.
+func (c KubectlCommand) Redact(level string) KubectlCommand {
+	switch level {
+	case MetadataVerbResource:
+		return KubectlCommand{Verb: c.Verb, Resource: c.Resource}
+	case MetadataVerb:
+		return KubectlCommand{Verb: c.Verb}
+	default:
+		return KubectlCommand{}
+	}
+}
+
+// longRunningVerbs keep running until they're stopped, or until what they
+// wait on happens
+var longRunningVerbs = map[string]bool{
+	"attach": true, "port-forward": true, "proxy": true, "exec": true,
+	"debug": true, "wait": true, "rollout status": true,
+}
+
+// IsLongRunning reports whether the command typically runs for a long time,
+// streaming or waiting, so it should keep recording activity while it runs
+func (c KubectlCommand) IsLongRunning() bool {
+	switch c.Verb {
+	case "logs", "get", "events":
+		return c.watch
+	case "run":
+		return c.interactive
+	}
+	return longRunningVerbs[c.Verb]
+}
+
+// IsWrite reports whether the command changes the cluster
+func (c KubectlCommand) IsWrite() bool {
+	return writeVerbs[c.Verb]
+}
+
+// credentialSubcommands are the cloud CLIs whose subcommands fetch cluster
+// credentials into kubeconfig, making the cluster's context current. The
+// shell integration wraps these CLIs only when they're added to
+// shell.wrap_commands.
+var credentialSubcommands = map[string][]string{
+	"aws":    {"eks", "update-kubeconfig"},
+	"gcloud": {"container", "clusters", "get-credentials"},
+	"az":     {"aks", "get-credentials"},
+}
+
+// IsCredentialCommand reports whether the arguments of a wrapped cloud CLI
+// fetch cluster credentials, as aws eks update-kubeconfig does. Global flags
+// may come between the subcommand's words. The CLI itself isn't needed,
+// since an alias may stand for it.
+func IsCredentialCommand(args []string) bool {
+	for _, words := range credentialSubcommands {
+		if containsInOrder(args, words) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsInOrder reports whether args include every word, in order
+func containsInOrder(args, words []string) bool {
+	for _, arg := range args {
+		if arg == words[0] {
+			words = words[1:]
+			if len(words) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
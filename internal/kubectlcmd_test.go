@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseKubectlCommand(t *testing.T) {
+	tests := []struct {
+		args      string
+		wantVerb  string
+		wantWrite bool
+	}{
+		{"", "", false},
+		{"get pods", "get", false},
+		{"describe deploy/web", "describe", false},
+		{"logs -f web", "logs", false},
+		{"apply -f app.yaml", "apply", true},
+		{"delete pod web", "delete", true},
+		{"edit deploy web", "edit", true},
+		{"-n kube-system delete pod web", "delete", true},
+		{"--context prod get pods", "get", false},
+		{"--namespace=prod scale deploy web --replicas=0", "scale", true},
+		{"rollout status deploy/web", "rollout status", false},
+		{"rollout restart deploy/web", "rollout restart", true},
+		{"rollout -n prod undo deploy/web", "rollout undo", true},
+		{"set image deploy/web web=nginx", "set image", true},
+		{"upgrade web ./chart", "upgrade", true},
+		{"frobnicate", "frobnicate", false},
+	}
+
+	for _, tt := range tests {
+		cmd := ParseKubectlCommand(strings.Fields(tt.args))
+		if cmd.Verb != tt.wantVerb {
+			t.Errorf("ParseKubectlCommand(%q).Verb = %q, want %q", tt.args, cmd.Verb, tt.wantVerb)
+		}
+		if cmd.IsWrite() != tt.wantWrite {
+			t.Errorf("ParseKubectlCommand(%q).IsWrite() = %v, want %v", tt.args, cmd.IsWrite(), tt.wantWrite)
+		}
+	}
+}
+
+func TestParseKubectlCommandResource(t *testing.T) {
+	tests := []struct {
+		args string
+		want string
+	}{
+		{"get pods", "pods"},
+		{"get po web-0", "pods"},
+		{"-n prod get -o yaml deploy/web", "deployments"},
+		{"get pods,svc", "pods,services"},
+		{"describe certificates.cert-manager.io tls", "certificates.cert-manager.io"},
+		{"delete my-secret-name", "other"},
+		{"rollout restart deploy/web", "deployments"},
+		{"create secret generic db --from-literal=password=hunter2", "secrets"},
+		{"logs -f web-0", "pods"},
+		{"logs deploy/web", "deployments"},
+		{"exec -it web-0 -- sh", "pods"},
+		{"apply -f app.yaml", ""},
+		{"version", ""},
+		{"get", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ParseKubectlCommand(strings.Fields(tt.args)).Resource; got != tt.want {
+			t.Errorf("ParseKubectlCommand(%q).Resource = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestKubectlCommandIsLongRunning(t *testing.T) {
+	tests := []struct {
+		args string
+		want bool
+	}{
+		{"logs web-0", false},
+		{"logs -f web-0", true},
+		{"logs web-0 --follow", true},
+		{"get pods", false},
+		{"get pods -w", true},
+		{"-n prod get pods --watch", true},
+		{"port-forward svc/web 8080:80", true},
+		{"exec -it web-0 -- sh", true},
+		{"rollout status deploy/web", true},
+		{"run debug --image=busybox -it --rm", true},
+		{"run web --image=nginx", false},
+		{"apply -f app.yaml", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := ParseKubectlCommand(strings.Fields(tt.args)).IsLongRunning(); got != tt.want {
+			t.Errorf("ParseKubectlCommand(%q).IsLongRunning() = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestKubectlCommandRedact(t *testing.T) {
+	cmd := KubectlCommand{Verb: "get", Resource: "pods"}
+
+	tests := []struct {
+		level string
+		want  KubectlCommand
+	}{
+		{"", KubectlCommand{}},
+		{MetadataOff, KubectlCommand{}},
+		{MetadataVerb, KubectlCommand{Verb: "get"}},
+		{MetadataVerbResource, cmd},
+	}
+	for _, tt := range tests {
+		if got := cmd.Redact(tt.level); got != tt.want {
+			t.Errorf("Redact(%q) = %+v, want %+v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestIsCredentialCommand(t *testing.T) {
+	tests := []struct {
+		args string
+		want bool
+	}{
+		{"eks update-kubeconfig --name prod --region us-east-1", true},
+		{"--profile work --region us-east-1 eks update-kubeconfig --name prod", true},
+		{"container clusters get-credentials prod --zone us-east1-b", true},
+		{"aks get-credentials --resource-group rg --name prod", true},
+		{"eks list-clusters", false},
+		{"s3 ls", false},
+		{"container clusters list", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsCredentialCommand(strings.Fields(tt.args)); got != tt.want {
+			t.Errorf("IsCredentialCommand(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
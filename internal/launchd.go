@@ -118,11 +118,16 @@ func NewLaunchdManager(binaryPath string) (*LaunchdManager, error) {
 	}, nil
 }
 
+// Name returns the name of the service manager
+func (lm *LaunchdManager) Name() string {
+	return "launchd"
+}
+
 // Install installs the launchd plist and loads the daemon
 func (lm *LaunchdManager) Install() error {
 	// Check if already installed
 	if lm.IsInstalled() {
-		return fmt.Errorf("daemon is already installed at %s", lm.plistPath)
+		return MarkFailure(fmt.Errorf("daemon is already installed at %s", lm.plistPath), ErrAlreadyInstalled)
 	}
 
 	// Ensure LaunchAgents directory exists
@@ -332,9 +337,8 @@ func (lm *LaunchdManager) generatePlist() (string, error) {
 		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	stateDir := GetStateDir()
-	stdoutPath := filepath.Join(stateDir, "daemon.stdout.log")
-	stderrPath := filepath.Join(stateDir, "daemon.stderr.log")
+	stdoutPath := GetDaemonStdoutPath()
+	stderrPath := GetDaemonStderrPath()
 
 	// Get PATH from environment, or use a sensible default
 	pathEnv := os.Getenv("PATH")
@@ -354,6 +358,46 @@ func (lm *LaunchdManager) generatePlist() (string, error) {
 	return plist, nil
 }
 
+// ReplacePlistPaths rewrites paths in the installed plist, such as log
+// files that moved, and reloads the daemon if it is running so launchd
+// picks them up. It reports whether the plist changed.
+func (lm *LaunchdManager) ReplacePlistPaths(replacements map[string]string) (bool, error) {
+	if !lm.IsInstalled() {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(lm.plistPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read plist file: %w", err)
+	}
+
+	plist := string(data)
+	for from, to := range replacements {
+		plist = strings.ReplaceAll(plist, from, to)
+	}
+	if plist == string(data) {
+		return false, nil
+	}
+
+	running := lm.IsRunning()
+	if running {
+		if err := lm.Unload(); err != nil {
+			return false, fmt.Errorf("failed to stop daemon: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(lm.plistPath, []byte(plist), 0600); err != nil {
+		return false, fmt.Errorf("failed to write plist file: %w", err)
+	}
+
+	if running {
+		if err := lm.Load(); err != nil {
+			return true, fmt.Errorf("failed to start daemon: %w", err)
+		}
+	}
+	return true, nil
+}
+
 // GetPlistPath returns the path to the plist file
 func (lm *LaunchdManager) GetPlistPath() string {
 	return lm.plistPath
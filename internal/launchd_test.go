@@ -333,3 +333,33 @@ func TestRestart_NotInstalled(t *testing.T) {
 		}
 	}
 }
+
+func TestReplacePlistPaths(t *testing.T) {
+	plistPath := filepath.Join(t.TempDir(), LaunchdLabel+".plist")
+	original := "<string>/Users/me/.kubectx-timeout/daemon.stdout.log</string>\n"
+	if err := os.WriteFile(plistPath, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A label that's never loaded, so the test doesn't touch a real daemon
+	lm := &LaunchdManager{label: "com.kubectx-timeout.test-unloaded", plistPath: plistPath}
+	replacements := map[string]string{
+		"/Users/me/.kubectx-timeout/daemon.stdout.log": "/Users/me/.local/state/kubectx-timeout/daemon.stdout.log",
+	}
+
+	updated, err := lm.ReplacePlistPaths(replacements)
+	if err != nil || !updated {
+		t.Fatalf("ReplacePlistPaths() = %v, %v; want an update", updated, err)
+	}
+	data, err := os.ReadFile(plistPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "<string>/Users/me/.local/state/kubectx-timeout/daemon.stdout.log</string>\n" {
+		t.Errorf("plist = %q", data)
+	}
+
+	if updated, err := lm.ReplacePlistPaths(replacements); err != nil || updated {
+		t.Errorf("second ReplacePlistPaths() = %v, %v; want no change", updated, err)
+	}
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log output formats for daemon.log_format
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+	// LogFormatConsole is easier to read in a terminal than text, with a
+	// short timestamp and the message ahead of its fields
+	LogFormatConsole = "console"
+)
+
+// ParseLogLevel converts a daemon.log_level value to a slog level. Unknown
+// values are rejected by validation, and count as info here.
+func ParseLogLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// NewLogger creates a logger that writes records at or above level to w,
+// as logfmt-style text or, with LogFormatJSON, one JSON object per line
+func NewLogger(w io.Writer, format string, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: formatDurations}
+	switch format {
+	case LogFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts))
+	case LogFormatConsole:
+		return slog.New(&consoleHandler{mu: new(sync.Mutex), w: w, level: level})
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// formatDurations writes durations as "30m0s" rather than nanoseconds, which
+// the JSON handler would otherwise emit
+func formatDurations(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindDuration {
+		return slog.String(a.Key, a.Value.Duration().String())
+	}
+	return a
+}
+
+// consoleHandler writes records for a person watching a terminal, e.g.
+// "14:02:31 INFO  Timeout exceeded  context=prod idle=31m0s"
+type consoleHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Leveler
+
+	// attrs are the fields added with With, already formatted, and group
+	// prefixes the keys of later fields
+	attrs string
+	group string
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	if !r.Time.IsZero() {
+		buf.WriteString(r.Time.Format(time.TimeOnly) + " ")
+	}
+	fmt.Fprintf(&buf, "%-5s %s ", r.Level.String(), r.Message)
+	buf.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeConsoleAttr(&buf, h.group, a)
+		return true
+	})
+	buf.Truncate(len(strings.TrimRight(buf.String(), " ")))
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf.Bytes())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var buf bytes.Buffer
+	for _, a := range attrs {
+		writeConsoleAttr(&buf, h.group, a)
+	}
+	clone := *h
+	clone.attrs += buf.String()
+	return &clone
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.group += name + "."
+	return &clone
+}
+
+// writeConsoleAttr writes one field as " key=value", quoting values with
+// spaces and flattening groups into dotted keys
+func writeConsoleAttr(buf *bytes.Buffer, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, member := range a.Value.Group() {
+			writeConsoleAttr(buf, prefix, member)
+		}
+		return
+	}
+
+	value := a.Value.String()
+	if a.Value.Kind() == slog.KindDuration {
+		value = a.Value.Duration().String()
+	}
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(buf, " %s%s=%s", prefix, a.Key, value)
+}
+
+// discardLogger returns a logger that drops everything, for callers that
+// have nowhere useful to send log output
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+		"":      slog.LevelInfo,
+	}
+	for level, want := range tests {
+		if got := ParseLogLevel(level); got != want {
+			t.Errorf("ParseLogLevel(%q) = %v, want %v", level, got, want)
+		}
+	}
+}
+
+func TestNewLoggerFiltersByLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, LogFormatText, slog.LevelWarn)
+
+	logger.Info("routine check")
+	logger.Warn("failed to record history")
+
+	if strings.Contains(buf.String(), "routine check") {
+		t.Errorf("Info record should be filtered at warn level:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "failed to record history") {
+		t.Errorf("Warn record should be logged:\n%s", buf.String())
+	}
+}
+
+func TestNewLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, LogFormatJSON, slog.LevelInfo).With("component", "daemon")
+
+	logger.Info("Timeout exceeded", "context", "prod", "reason", "inactive", "timeout", 30*time.Minute)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Output is not JSON: %v\n%s", err, buf.String())
+	}
+	for key, want := range map[string]string{
+		"msg":       "Timeout exceeded",
+		"level":     "INFO",
+		"component": "daemon",
+		"context":   "prod",
+		"reason":    "inactive",
+		"timeout":   "30m0s",
+	} {
+		if record[key] != want {
+			t.Errorf("%s = %v, want %q", key, record[key], want)
+		}
+	}
+}
+
+func TestNewLoggerConsole(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, LogFormatConsole, slog.LevelInfo).With("component", "daemon")
+
+	logger.Debug("Next check scheduled")
+	logger.Info("Timeout exceeded", "context", "prod", "reason", "inactive for 31m", "timeout", 30*time.Minute)
+
+	line := strings.TrimSpace(buf.String())
+	if strings.Contains(line, "Next check scheduled") || strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("Expected only the info record:\n%s", buf.String())
+	}
+	want := `INFO  Timeout exceeded  component=daemon context=prod reason="inactive for 31m" timeout=30m0s`
+	if _, rest, _ := strings.Cut(line, " "); rest != want {
+		t.Errorf("Console record = %q, want the time then %q", line, want)
+	}
+}
+
+func TestDaemonLogLevelFollowsReload(t *testing.T) {
+	d := newFakeDaemon(t, &fakeSwitcher{current: "local"}, &fakeStateStore{})
+	if got := d.logLevel.Level(); got != slog.LevelInfo {
+		t.Fatalf("Initial level = %v, want info", got)
+	}
+
+	config := "default_context: local\ndaemon:\n  log_level: debug\n"
+	if err := os.WriteFile(d.configPath, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	if got := d.logLevel.Level(); got != slog.LevelDebug {
+		t.Errorf("Level after reload = %v, want debug", got)
+	}
+}
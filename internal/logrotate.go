@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile is a log file that is renamed to <path>.1 once it reaches
+// maxSize, shifting older backups to <path>.2 and so on and removing any
+// beyond maxBackups. A maxSize of zero disables rotation.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens a log file for appending, creating it and its
+// directory if needed
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path:       filepath.Clean(path),
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LogFilePath resolves daemon.log_file, which is relative to the state
+// directory unless absolute
+func LogFilePath(stateDir, logFile string) string {
+	if filepath.IsAbs(logFile) {
+		return filepath.Clean(logFile)
+	}
+	return filepath.Join(stateDir, logFile)
+}
+
+// Write appends p to the log file, rotating first if p would take the file
+// past maxSize. A single write larger than maxSize still goes to a fresh file.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		// Keep logging to whichever file is open if the rename failed
+		if err := r.rotate(); err != nil && r.file == nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// open opens the log file for appending and records its current size
+func (r *RotatingFile) open() error {
+	// #nosec G304 -- the path comes from the user's own config, resolved under the state directory
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file to <path>.1 and starts a new one. The file
+// is reopened even if shifting the backups failed. Callers must hold mu.
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	r.file = nil
+
+	shiftErr := r.shiftBackups()
+	if err := r.open(); err != nil {
+		return err
+	}
+	if shiftErr != nil {
+		return fmt.Errorf("failed to rotate log file: %w", shiftErr)
+	}
+	return nil
+}
+
+// shiftBackups renames each backup to the next number and the log file to
+// <path>.1, dropping the oldest
+func (r *RotatingFile) shiftBackups() error {
+	// Remove the oldest backup, along with any left from a larger max_backups
+	for i := max(r.maxBackups, 1); ; i++ {
+		if err := os.Remove(r.backupPath(i)); err != nil && i > r.maxBackups {
+			break
+		}
+	}
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(r.backupPath(i), r.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	var err error
+	if r.maxBackups > 0 {
+		err = os.Rename(r.path, r.backupPath(1))
+	} else {
+		err = os.Remove(r.path)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// backupPath returns the path of the nth most recent backup
+func (r *RotatingFile) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFileRotatesAndPrunes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "daemon.log")
+	r, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	defer r.Close()
+
+	// Each line fills the file, so every write after the first rotates
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), data, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected backups beyond log_max_backups to be removed, stat error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat log file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Log file permissions = %o, want 600", perm)
+	}
+}
+
+func TestRotatingFileAppendsToExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	if err := os.WriteFile(path, []byte("before restart\n"), 0600); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	r, err := OpenRotatingFile(path, 20, 1)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	defer r.Close()
+
+	// The existing size counts toward the limit
+	if _, err := r.Write([]byte("after restart\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	backup, _ := os.ReadFile(path + ".1")
+	if string(backup) != "before restart\n" {
+		t.Errorf("Backup = %q, want the log from before the restart", backup)
+	}
+}
+
+func TestRotatingFileWithoutLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	r, err := OpenRotatingFile(path, 0, 5)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := r.Write([]byte("line\n")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("A zero max size should never rotate, stat error = %v", err)
+	}
+	if _, err := r.Write([]byte("late\n")); err == nil {
+		t.Error("Write() after Close() should fail")
+	}
+}
+
+func TestDaemonLogsToRotatingFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	statePath := filepath.Join(tmpDir, "state", "state.json")
+	config := "default_context: local\ndaemon:\n  log_file: logs/daemon.log\n"
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	d, err := NewDaemonWithPIDFile(configPath, statePath, NewPIDFileWithPath(filepath.Join(tmpDir, "daemon.pid")),
+		WithSwitcher(&fakeSwitcher{current: "local"}))
+	if err != nil {
+		t.Fatalf("NewDaemonWithPIDFile() error = %v", err)
+	}
+	d.Shutdown()
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "state", "logs", "daemon.log"))
+	if err != nil {
+		t.Fatalf("Expected a log file under the state directory: %v", err)
+	}
+	if !strings.Contains(string(data), "Daemon shutdown complete") {
+		t.Errorf("Log file should contain the daemon's output:\n%s", data)
+	}
+}
+
+func TestLogFilePath(t *testing.T) {
+	if got := LogFilePath("/state", "daemon.log"); got != filepath.Join("/state", "daemon.log") {
+		t.Errorf("LogFilePath() = %q, want it under the state directory", got)
+	}
+	if got := LogFilePath("/state", "/var/log/kubectx-timeout.log"); got != "/var/log/kubectx-timeout.log" {
+		t.Errorf("LogFilePath() = %q, want the absolute path unchanged", got)
+	}
+}
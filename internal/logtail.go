@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+// logFollowInterval is how often a followed log file is checked for new output
+const logFollowInterval = 250 * time.Millisecond
+
+// DaemonLogPaths returns the files the daemon's output may be in: the
+// configured log_file, then the stdout and stderr files the service manager
+// writes to
+func DaemonLogPaths(config *Config, stateDir string) []string {
+	var paths []string
+	if config.Daemon.LogFile != "" {
+		paths = append(paths, LogFilePath(stateDir, config.Daemon.LogFile))
+	}
+	for _, path := range []string{GetDaemonStdoutPath(), GetDaemonStderrPath()} {
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// TailLines returns the last n lines of a file, reading backwards from the
+// end so large logs aren't read in full. It also returns the file size, the
+// offset to follow the file from.
+func TailLines(path string, n int) ([]string, int64, error) {
+	// #nosec G304 -- log paths come from the user's own config and state directory
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	size := info.Size()
+	if n <= 0 {
+		return nil, size, nil
+	}
+
+	// Read chunks from the end until there are more than n newlines, one
+	// extra for the newline that ends the last line
+	const chunkSize = 32 << 10
+	var data []byte
+	offset := size
+	for offset > 0 && bytes.Count(data, []byte("\n")) <= n {
+		read := min(chunkSize, offset)
+		offset -= read
+		chunk := make([]byte, read)
+		if _, err := file.ReadAt(chunk, offset); err != nil {
+			return nil, 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		data = append(chunk, data...)
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	if offset > 0 || len(lines) > n {
+		// The first line may be partial when the read stopped mid-file
+		lines = lines[max(len(lines)-n, 1):]
+	}
+	if len(data) == 0 {
+		lines = nil
+	}
+
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = string(line)
+	}
+	return result, size, nil
+}
+
+// FollowFile copies data appended to a file after offset to w until ctx is
+// canceled. When the file is rotated or truncated it starts again from the
+// beginning of the new file, and a missing file is waited for.
+func FollowFile(ctx context.Context, path string, offset int64, w io.Writer) error {
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+
+	for {
+		if file == nil {
+			// #nosec G304 -- log paths come from the user's own config and state directory
+			if f, err := os.Open(path); err == nil {
+				file = f
+			}
+		}
+
+		if file != nil {
+			opened, err := file.Stat()
+			if err != nil {
+				return fmt.Errorf("failed to stat %s: %w", path, err)
+			}
+			if opened.Size() < offset {
+				// Truncated in place
+				offset = 0
+			}
+
+			if opened.Size() > offset {
+				n, err := io.Copy(w, io.NewSectionReader(file, offset, opened.Size()-offset))
+				offset += n
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %w", path, err)
+				}
+			}
+
+			// A rotated file has been renamed away; read the new one from
+			// the start once everything written to the old one is out
+			if current, err := os.Stat(path); err != nil || !os.SameFile(opened, current) {
+				file.Close()
+				file = nil
+				offset = 0
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTailLines(t *testing.T) {
+	dir := t.TempDir()
+
+	var long strings.Builder
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintf(&long, "line %d with some padding to span several read chunks\n", i)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		n       int
+		want    []string
+	}{
+		{"last lines", "a\nb\nc\n", 2, []string{"b", "c"}},
+		{"fewer lines than asked", "a\nb\n", 10, []string{"a", "b"}},
+		{"unterminated last line", "a\nb\nc", 2, []string{"b", "c"}},
+		{"empty file", "", 5, nil},
+		{"zero lines", "a\n", 0, nil},
+		{"across chunks", long.String(), 3, []string{
+			"line 4998 with some padding to span several read chunks",
+			"line 4999 with some padding to span several read chunks",
+			"line 5000 with some padding to span several read chunks",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-"))
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			got, size, err := TailLines(path, tt.n)
+			if err != nil {
+				t.Fatalf("TailLines() error = %v", err)
+			}
+			if size != int64(len(tt.content)) {
+				t.Errorf("size = %d, want %d", size, len(tt.content))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("TailLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := TailLines(filepath.Join(dir, "missing"), 10); !os.IsNotExist(err) {
+		t.Errorf("TailLines() on a missing file error = %v, want not exist", err)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe to read while FollowFile writes to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFollowFileAcrossRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	logFile, err := OpenRotatingFile(path, 32, 1)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	defer logFile.Close()
+	if _, err := logFile.Write([]byte("already printed\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	_, offset, err := TailLines(path, 0)
+	if err != nil {
+		t.Fatalf("TailLines() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() { done <- FollowFile(ctx, path, offset, &out) }()
+
+	// The second write rotates the file
+	for _, line := range []string{"before rotation\n", "after rotation\n"} {
+		if _, err := logFile.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		time.Sleep(3 * logFollowInterval)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "after rotation") && time.Now().Before(deadline) {
+		time.Sleep(logFollowInterval)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("FollowFile() error = %v", err)
+	}
+
+	if got, want := out.String(), "before rotation\nafter rotation\n"; got != want {
+		t.Errorf("Followed output = %q, want %q", got, want)
+	}
+}
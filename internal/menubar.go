@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Menu bar defaults
+const (
+	// menuBarRefreshInterval is how often the menu bar asks the daemon for
+	// its status
+	menuBarRefreshInterval = 5 * time.Second
+	// MenuBarPauseDuration is how long "Pause" exempts the current context
+	MenuBarPauseDuration = time.Hour
+	// MenuBarExtendDuration is how long "Extend" suppresses switching
+	MenuBarExtendDuration = 30 * time.Minute
+)
+
+// MenuBar is the state behind the menu bar item: it talks to the daemon
+// over the control socket and decides what the item shows. The platform
+// code only draws it.
+type MenuBar struct {
+	socketPath string
+	// send makes control requests; tests substitute a fake daemon
+	send func(socketPath string, req ControlRequest) (*ControlResponse, error)
+
+	// status is the latest summary from the daemon, nil if it isn't running
+	status *StatusSummary
+}
+
+// NewMenuBar creates a menu bar that controls the daemon at socketPath
+func NewMenuBar(socketPath string) *MenuBar {
+	return &MenuBar{socketPath: socketPath, send: SendControlRequest}
+}
+
+// Refresh fetches the daemon's status. A daemon that isn't running is not
+// an error; the menu bar shows it as off.
+func (m *MenuBar) Refresh() error {
+	resp, err := m.send(m.socketPath, ControlRequest{Command: ControlStatus})
+	if errors.Is(err, ErrControlUnavailable) {
+		m.status = nil
+		return nil
+	}
+	if err != nil {
+		m.status = nil
+		return err
+	}
+	m.status = resp.Status
+	return nil
+}
+
+// Running reports whether the daemon answered the last refresh, which the
+// actions need
+func (m *MenuBar) Running() bool {
+	return m.status != nil
+}
+
+// Title returns the menu bar text: the time left, as in the prompt segment,
+// or just the context when there is nothing counting down
+func (m *MenuBar) Title(now time.Time) string {
+	if m.status == nil {
+		return "⎈ off"
+	}
+	// The status comes straight from the daemon, so it's never stale
+	status := *m.status
+	status.UpdatedAt = now
+	if text, _ := PromptSegment(&status, now, PromptOptions{}); text != "" {
+		return text
+	}
+	if status.Context == "" {
+		return "⎈"
+	}
+	return "⎈ " + status.ContextLabel()
+}
+
+// Tooltip describes the daemon's state in a sentence
+func (m *MenuBar) Tooltip() string {
+	if m.status == nil {
+		return "kubectx-timeout daemon is not running"
+	}
+	s := m.status
+	switch s.State {
+	case SummaryStateActive:
+		return fmt.Sprintf("Switching from %s to %s after %s of inactivity", s.ContextLabel(), s.DefaultContext, time.Duration(s.TimeoutSeconds)*time.Second)
+	case SummaryStateDegraded:
+		return "Daemon degraded: " + s.DegradedReason
+	}
+	return fmt.Sprintf("%s (%s)", s.ContextLabel(), s.State)
+}
+
+// Pause exempts the current context from switching for MenuBarPauseDuration
+func (m *MenuBar) Pause() error {
+	if m.status == nil || m.status.Context == "" {
+		return fmt.Errorf("no current context to pause")
+	}
+	return m.do(ControlRequest{Command: ControlPause, Context: m.status.Context, Duration: MenuBarPauseDuration.String()})
+}
+
+// Extend suppresses switching for MenuBarExtendDuration
+func (m *MenuBar) Extend() error {
+	return m.do(ControlRequest{Command: ControlPause, Duration: MenuBarExtendDuration.String()})
+}
+
+// SwitchNow switches to the default context right away
+func (m *MenuBar) SwitchNow() error {
+	return m.do(ControlRequest{Command: ControlForceSwitch})
+}
+
+// do sends a request and takes the status from its response
+func (m *MenuBar) do(req ControlRequest) error {
+	resp, err := m.send(m.socketPath, req)
+	if err != nil {
+		return err
+	}
+	m.status = resp.Status
+	return nil
+}
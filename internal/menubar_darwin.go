@@ -0,0 +1,74 @@
+//go:build darwin && cgo
+
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/systray"
+)
+
+// RunMenuBar shows the current context and time remaining in the macOS menu
+// bar, with items to pause, extend, and switch now, until the user quits.
+// It must be called from the main goroutine.
+func RunMenuBar(socketPath string) error {
+	m := NewMenuBar(socketPath)
+	systray.Run(func() { m.run() }, nil)
+	return nil
+}
+
+// run builds the menu and serves it; it handles refreshes and clicks on one
+// goroutine so the menu bar state needs no locking
+func (m *MenuBar) run() {
+	pause := systray.AddMenuItem(fmt.Sprintf("Pause This Context for %s", FormatPromptDuration(MenuBarPauseDuration)), "Don't switch away from the current context")
+	extend := systray.AddMenuItem(fmt.Sprintf("Extend %s", FormatPromptDuration(MenuBarExtendDuration)), "Don't switch any context for a while")
+	switchNow := systray.AddMenuItem("Switch Now", "Switch to the default context right away")
+	systray.AddSeparator()
+	status := systray.AddMenuItem("", "")
+	status.Disable()
+	systray.AddSeparator()
+	quit := systray.AddMenuItem("Quit", "Quit the menu bar item; the daemon keeps running")
+
+	// update redraws the menu after a refresh, or after an action that
+	// failed with err
+	update := func(err error) {
+		if err == nil {
+			err = m.Refresh()
+		}
+
+		systray.SetTitle(m.Title(time.Now()))
+		systray.SetTooltip(m.Tooltip())
+		if err != nil {
+			status.SetTitle("Error: " + err.Error())
+		} else {
+			status.SetTitle(m.Tooltip())
+		}
+		for _, item := range []*systray.MenuItem{pause, extend, switchNow} {
+			if m.Running() {
+				item.Enable()
+			} else {
+				item.Disable()
+			}
+		}
+	}
+
+	update(nil)
+	ticker := time.NewTicker(menuBarRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			update(nil)
+		case <-pause.ClickedCh:
+			update(m.Pause())
+		case <-extend.ClickedCh:
+			update(m.Extend())
+		case <-switchNow.ClickedCh:
+			update(m.SwitchNow())
+		case <-quit.ClickedCh:
+			systray.Quit()
+			return
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !darwin || !cgo
+
+package internal
+
+import "fmt"
+
+// RunMenuBar is only available in macOS builds, which need cgo for the
+// menu bar
+func RunMenuBar(socketPath string) error {
+	return fmt.Errorf("the menu bar is only available on macOS (built with cgo)")
+}
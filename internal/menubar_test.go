@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeMenuBarDaemon answers control requests like a daemon would
+type fakeMenuBarDaemon struct {
+	status   StatusSummary
+	running  bool
+	requests []ControlRequest
+}
+
+func (f *fakeMenuBarDaemon) send(_ string, req ControlRequest) (*ControlResponse, error) {
+	if !f.running {
+		return nil, fmt.Errorf("%w: connection refused", ErrControlUnavailable)
+	}
+	f.requests = append(f.requests, req)
+	status := f.status
+	return &ControlResponse{OK: true, Status: &status}, nil
+}
+
+func TestMenuBarTitle(t *testing.T) {
+	now := time.Now()
+	deadline := now.Add(12*time.Minute + 30*time.Second)
+	daemon := &fakeMenuBarDaemon{running: true, status: StatusSummary{
+		State:          SummaryStateActive,
+		Context:        "prod",
+		DefaultContext: "local",
+		Deadline:       &deadline,
+		TimeoutSeconds: 1800,
+	}}
+	m := &MenuBar{send: daemon.send}
+
+	if err := m.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got := m.Title(now); got != "⏱ prod 12m" {
+		t.Errorf("Title() = %q, want the time remaining", got)
+	}
+	if got := m.Tooltip(); got != "Switching from prod to local after 30m0s of inactivity" {
+		t.Errorf("Tooltip() = %q", got)
+	}
+
+	daemon.status = StatusSummary{State: SummaryStateDefault, Context: "local"}
+	if err := m.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got := m.Title(now); got != "⎈ local" {
+		t.Errorf("Title() = %q, want the context alone", got)
+	}
+
+	daemon.running = false
+	if err := m.Refresh(); err != nil {
+		t.Fatalf("Refresh() should not fail when the daemon is stopped: %v", err)
+	}
+	if got := m.Title(now); got != "⎈ off" || m.Running() {
+		t.Errorf("Title() = %q, Running() = %v, want the daemon shown as off", got, m.Running())
+	}
+}
+
+func TestMenuBarActions(t *testing.T) {
+	daemon := &fakeMenuBarDaemon{running: true, status: StatusSummary{State: SummaryStateActive, Context: "prod"}}
+	m := &MenuBar{send: daemon.send}
+	if err := m.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	for _, action := range []func() error{m.Pause, m.Extend, m.SwitchNow} {
+		if err := action(); err != nil {
+			t.Fatalf("action error = %v", err)
+		}
+	}
+
+	want := []ControlRequest{
+		{Command: ControlStatus},
+		{Command: ControlPause, Context: "prod", Duration: "1h0m0s"},
+		{Command: ControlPause, Duration: "30m0s"},
+		{Command: ControlForceSwitch},
+	}
+	if fmt.Sprint(daemon.requests) != fmt.Sprint(want) {
+		t.Errorf("requests = %+v, want %+v", daemon.requests, want)
+	}
+}
+
+func TestMenuBarPauseNeedsContext(t *testing.T) {
+	m := &MenuBar{send: (&fakeMenuBarDaemon{}).send}
+	if err := m.Refresh(); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if err := m.Pause(); err == nil {
+		t.Error("Pause() should fail without a running daemon")
+	}
+	if err := m.SwitchNow(); !errors.Is(err, ErrControlUnavailable) {
+		t.Errorf("SwitchNow() error = %v, want ErrControlUnavailable", err)
+	}
+}
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// legacyDirName is the directory under the home directory that releases
+// before the XDG layout kept both config and state in
+const legacyDirName = ".kubectx-timeout"
+
+// legacyConfigWarning makes sure the legacy config warning is printed
+// once per process, however often the config is loaded
+var legacyConfigWarning sync.Once
+
+// GetLegacyDir returns ~/.kubectx-timeout, where older releases kept the
+// config and state
+func GetLegacyDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, legacyDirName)
+}
+
+// legacyConfigPath returns the config file older releases used, if it
+// exists
+func legacyConfigPath() (string, bool) {
+	dir := GetLegacyDir()
+	if dir == "" {
+		return "", false
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// Migration is one file to move from the legacy directory
+type Migration struct {
+	From string
+	To   string
+
+	// Conflict is set when To already exists; the file is left in place
+	Conflict bool
+}
+
+// Migrator moves the config and state from the legacy ~/.kubectx-timeout
+// directory to the XDG config and state directories
+type Migrator struct {
+	legacyDir string
+	configDir string
+	stateDir  string
+}
+
+// NewMigrator creates a migrator from the legacy directory to the current
+// XDG directories
+func NewMigrator() *Migrator {
+	return &Migrator{
+		legacyDir: GetLegacyDir(),
+		configDir: GetConfigDir(),
+		stateDir:  GetStateDir(),
+	}
+}
+
+// Plan lists the files to move. The config file and shell integration go
+// to the config directory and everything else, such as state, history, and
+// logs, to the state directory. It returns nothing when there is no legacy
+// directory.
+func (m *Migrator) Plan() ([]Migration, error) {
+	if m.legacyDir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(m.legacyDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", m.legacyDir, err)
+	}
+
+	var plan []Migration
+	for _, entry := range entries {
+		// The socket and PID file belong to a running daemon, which
+		// recreates them
+		name := entry.Name()
+		if !entry.Type().IsRegular() || name == "daemon.pid" {
+			continue
+		}
+
+		dir := m.stateDir
+		if name == "config.yaml" || strings.HasPrefix(name, "integration.") {
+			dir = m.configDir
+		}
+		migration := Migration{From: filepath.Join(m.legacyDir, name), To: filepath.Join(dir, name)}
+		if _, err := os.Stat(migration.To); err == nil {
+			migration.Conflict = true
+		}
+		plan = append(plan, migration)
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].From < plan[j].From })
+	return plan, nil
+}
+
+// Migrate moves the planned files, skipping conflicts, and removes the
+// legacy directory if nothing is left in it. It returns the plan, with the
+// files that were moved.
+func (m *Migrator) Migrate() ([]Migration, error) {
+	plan, err := m.Plan()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range plan {
+		if migration.Conflict {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(migration.To), 0750); err != nil {
+			return plan, fmt.Errorf("failed to create %s: %w", filepath.Dir(migration.To), err)
+		}
+		if err := moveFile(migration.From, migration.To); err != nil {
+			return plan, fmt.Errorf("failed to move %s: %w", migration.From, err)
+		}
+	}
+
+	if len(plan) > 0 {
+		// Fails harmlessly if conflicts or other files remain
+		_ = os.Remove(m.legacyDir)
+	}
+	return plan, nil
+}
+
+// moveFile renames a file, copying it when the destination is on another
+// filesystem
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	// #nosec G304 -- from is a file in the legacy directory
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	// #nosec G304 -- to is a file in the config or state directory
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(to)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(to)
+		return err
+	}
+
+	return os.Remove(from)
+}
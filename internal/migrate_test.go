@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupLegacyDir points HOME and the XDG directories at a temporary
+// directory and creates a legacy ~/.kubectx-timeout with the given files
+func setupLegacyDir(t *testing.T, files ...string) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	legacyDir := filepath.Join(home, legacyDirName)
+	if err := os.MkdirAll(legacyDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(legacyDir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return legacyDir
+}
+
+func TestMigrate(t *testing.T) {
+	legacyDir := setupLegacyDir(t, "config.yaml", "state.json", "history.jsonl", "daemon.stdout.log", "daemon.pid")
+
+	plan, err := NewMigrator().Migrate()
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if len(plan) != 4 {
+		t.Errorf("Migrate() moved %d files, want 4 (not the PID file): %+v", len(plan), plan)
+	}
+
+	for _, path := range []string{
+		GetConfigPath(),
+		GetStatePath(),
+		filepath.Join(GetStateDir(), "history.jsonl"),
+		GetDaemonStdoutPath(),
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("expected %s to be moved: %v", path, err)
+			continue
+		}
+		if string(data) != filepath.Base(path) {
+			t.Errorf("%s holds %q, want the legacy file's contents", path, data)
+		}
+	}
+
+	// The PID file stays behind, so the directory does too
+	if _, err := os.Stat(filepath.Join(legacyDir, "config.yaml")); !os.IsNotExist(err) {
+		t.Error("expected the legacy config to be moved away")
+	}
+	if _, err := os.Stat(filepath.Join(legacyDir, "daemon.pid")); err != nil {
+		t.Errorf("expected the PID file to be left alone: %v", err)
+	}
+}
+
+func TestMigrateKeepsExistingFiles(t *testing.T) {
+	legacyDir := setupLegacyDir(t, "config.yaml")
+	if err := os.MkdirAll(GetConfigDir(), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(GetConfigPath(), []byte("current"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := NewMigrator().Migrate()
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if len(plan) != 1 || !plan[0].Conflict {
+		t.Fatalf("Migrate() plan = %+v, want one conflict", plan)
+	}
+
+	if data, _ := os.ReadFile(GetConfigPath()); string(data) != "current" {
+		t.Errorf("existing config was overwritten with %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(legacyDir, "config.yaml")); err != nil {
+		t.Errorf("expected the conflicting legacy config to stay: %v", err)
+	}
+}
+
+func TestMigrateRemovesEmptyLegacyDir(t *testing.T) {
+	legacyDir := setupLegacyDir(t, "state.json")
+
+	if _, err := NewMigrator().Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if _, err := os.Stat(legacyDir); !os.IsNotExist(err) {
+		t.Errorf("expected the empty legacy directory to be removed: %v", err)
+	}
+
+	// Running again has nothing to do
+	plan, err := NewMigrator().Migrate()
+	if err != nil || len(plan) != 0 {
+		t.Errorf("second Migrate() = %+v, %v; want nothing to do", plan, err)
+	}
+}
+
+func TestLoadConfigUsesLegacyConfig(t *testing.T) {
+	legacyDir := setupLegacyDir(t)
+	content := "default_context: legacy-local\n"
+	if err := os.WriteFile(filepath.Join(legacyDir, "config.yaml"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(GetConfigPath())
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.DefaultContext != "legacy-local" {
+		t.Errorf("DefaultContext = %q, want the legacy config's", config.DefaultContext)
+	}
+
+	// Only the default path falls back to the legacy config
+	config, err = LoadConfig(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.DefaultContext == "legacy-local" {
+		t.Error("an explicit config path should not use the legacy config")
+	}
+}
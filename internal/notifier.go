@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Notification methods
+const (
+	NotificationMethodTerminal = "terminal"
+	NotificationMethodDesktop  = "desktop"
+	NotificationMethodBoth     = "both"
+
+	// NotificationMethodMacOS is what desktop was called when only macOS
+	// had desktop notifications, still accepted
+	NotificationMethodMacOS = "macos"
+)
+
+// notificationTitle is the title of desktop notifications and the prefix of
+// terminal notifications
+const notificationTitle = "kubectx-timeout"
+
+// defaultSwitchMessage is used when notifications.message is not configured
+const defaultSwitchMessage = "Switched kubectl context from '{{.FromContext}}' to '{{.ToContext}}' ({{.Reason}})"
+
+// terminalDevicePatterns match the pseudo-terminals of open terminal windows:
+// /dev/pts/N on Linux and /dev/ttysN on macOS
+var terminalDevicePatterns = []string{"/dev/pts/[0-9]*", "/dev/ttys[0-9]*"}
+
+// SwitchEvent describes a context switch. Its fields are available to the
+// notifications.message template.
+type SwitchEvent struct {
+	FromContext string
+	ToContext   string
+	Reason      string
+
+	// Error is why the switch failed, for the on_switch_failure hook
+	Error string
+}
+
+// RenderSwitchMessage renders a notification message template for a switch.
+// An empty template uses the default message.
+func RenderSwitchMessage(messageTemplate string, event SwitchEvent) (string, error) {
+	if messageTemplate == "" {
+		messageTemplate = defaultSwitchMessage
+	}
+
+	tmpl, err := template.New("message").Parse(messageTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse message template: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, event); err != nil {
+		return "", fmt.Errorf("failed to render message template: %w", err)
+	}
+
+	return sb.String(), nil
+}
+
+// Notifier delivers notifications to the user according to the
+// notifications config: as desktop notifications, as a line written to the
+// user's open terminals, or both
+type Notifier struct {
+	config NotificationConfig
+
+	desktop  NotificationBackend
+	terminal NotificationBackend
+
+	// focusActive reports whether a macOS Focus is on, and heldBack is
+	// told about each notification quiet hours or Focus hold back
+	focusActive func() (bool, error)
+	heldBack    func(reason, message string)
+
+	// webhookRetryDelay is the wait before retrying a failed webhook or
+	// Slack message, and slackAPIURL is where Slack messages are posted
+	webhookRetryDelay time.Duration
+	slackAPIURL       string
+}
+
+// NewNotifier creates a notifier for the given settings
+func NewNotifier(config NotificationConfig) *Notifier {
+	desktop, err := NewDesktopBackend(config.Desktop)
+	if err != nil {
+		// Validate rejects unknown backends, so this is a config that
+		// skipped validation
+		desktop, _ = NewDesktopBackend("")
+	}
+
+	return &Notifier{
+		config:   config,
+		desktop:  desktop,
+		terminal: terminalBackend{},
+
+		focusActive: FocusActive,
+
+		webhookRetryDelay: webhookRetryDelay,
+		slackAPIURL:       slackPostMessageURL,
+	}
+}
+
+// NotifySwitch announces a context switch using the configured message
+// template, and posts it to the configured webhooks and Slack channel. The
+// desktop notification offers actions as buttons, where supported.
+func (n *Notifier) NotifySwitch(event SwitchEvent, actions ...NotificationAction) error {
+	message, err := RenderSwitchMessage(n.config.Message, event)
+	if err != nil {
+		return err
+	}
+	payload := NewWebhookPayload(event, message, time.Now())
+	notification := Notification{Message: message, Actions: actions}
+	return errors.Join(n.Send(notification), n.postWebhooks(payload), n.postSlack(payload))
+}
+
+// Notify delivers a message using every configured method. It does nothing
+// if notifications are disabled.
+func (n *Notifier) Notify(message string) error {
+	return n.Send(Notification{Message: message})
+}
+
+// NotifyWithAction delivers a message like Notify. Clicking the desktop
+// notification runs clickCommand through the shell, where the backend
+// supports that (terminal-notifier or alerter on macOS).
+func (n *Notifier) NotifyWithAction(message, clickCommand string) error {
+	return n.Send(Notification{Message: message, ClickCommand: clickCommand})
+}
+
+// Send delivers a notification using every configured method, titled
+// kubectx-timeout unless it has a title of its own. It does nothing if
+// notifications are disabled.
+func (n *Notifier) Send(notification Notification) error {
+	if !n.config.Enabled {
+		return nil
+	}
+	if reason := n.QuietReason(time.Now()); reason != "" {
+		if n.heldBack != nil {
+			n.heldBack(reason, notification.Message)
+		}
+		return nil
+	}
+	if notification.Title == "" {
+		notification.Title = notificationTitle
+	}
+
+	method := n.config.Method
+	var errs []error
+
+	if method == NotificationMethodTerminal || method == NotificationMethodBoth {
+		if err := n.terminal.Send(notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if method == NotificationMethodDesktop || method == NotificationMethodMacOS || method == NotificationMethodBoth {
+		err := n.desktop.Send(notification)
+		// "both" means terminal-only where desktop notifications aren't available
+		if err != nil && !(method == NotificationMethodBoth && errors.Is(err, errDesktopUnsupported)) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// QuietReason returns why desktop and terminal notifications are held back
+// at t (QuietReasonHours or QuietReasonFocus), or "" if they aren't. Focus
+// state that can't be read counts as no Focus, so notifications still
+// arrive.
+func (n *Notifier) QuietReason(t time.Time) string {
+	if n.config.QuietHours.Active(t) {
+		return QuietReasonHours
+	}
+	if n.config.RespectFocus && n.focusActive != nil {
+		if active, err := n.focusActive(); err == nil && active {
+			return QuietReasonFocus
+		}
+	}
+	return ""
+}
+
+// shellQuote quotes s as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,375 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingBackend records the messages it is sent, and fails with err
+type recordingBackend struct {
+	messages      []string
+	notifications []Notification
+	err           error
+}
+
+func (b *recordingBackend) Name() string {
+	return "recording"
+}
+
+func (b *recordingBackend) Send(notification Notification) error {
+	b.messages = append(b.messages, notification.Message)
+	b.notifications = append(b.notifications, notification)
+	return b.err
+}
+
+// newTestNotifier returns a notifier that records deliveries instead of
+// sending them
+func newTestNotifier(config NotificationConfig, desktopErr error) (*Notifier, *[]string, *[]string) {
+	desktop := &recordingBackend{err: desktopErr}
+	terminal := &recordingBackend{}
+	n := NewNotifier(config)
+	n.desktop = desktop
+	n.terminal = terminal
+	n.focusActive = nil
+	return n, &desktop.messages, &terminal.messages
+}
+
+func TestRenderSwitchMessage(t *testing.T) {
+	event := SwitchEvent{FromContext: "prod", ToContext: "local", Reason: "inactive for 30m0s"}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "default message",
+			template: "",
+			want:     "Switched kubectl context from 'prod' to 'local' (inactive for 30m0s)",
+		},
+		{
+			name:     "custom message",
+			template: "{{.FromContext}} -> {{.ToContext}}",
+			want:     "prod -> local",
+		},
+		{
+			name:     "unparsable template",
+			template: "{{.FromContext",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown field",
+			template: "{{.Cluster}}",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderSwitchMessage(tt.template, event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderSwitchMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RenderSwitchMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifierMethods(t *testing.T) {
+	tests := []struct {
+		method       string
+		wantDesktop  int
+		wantTerminal int
+	}{
+		{method: NotificationMethodTerminal, wantDesktop: 0, wantTerminal: 1},
+		{method: NotificationMethodDesktop, wantDesktop: 1, wantTerminal: 0},
+		{method: NotificationMethodMacOS, wantDesktop: 1, wantTerminal: 0},
+		{method: NotificationMethodBoth, wantDesktop: 1, wantTerminal: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			n, desktop, terminal := newTestNotifier(NotificationConfig{Enabled: true, Method: tt.method}, nil)
+
+			if err := n.NotifySwitch(SwitchEvent{FromContext: "prod", ToContext: "local"}); err != nil {
+				t.Fatalf("NotifySwitch() error = %v", err)
+			}
+			if len(*desktop) != tt.wantDesktop {
+				t.Errorf("Desktop notifications = %d, want %d", len(*desktop), tt.wantDesktop)
+			}
+			if len(*terminal) != tt.wantTerminal {
+				t.Errorf("Terminal notifications = %d, want %d", len(*terminal), tt.wantTerminal)
+			}
+		})
+	}
+}
+
+func TestNotifierDisabled(t *testing.T) {
+	n, desktop, terminal := newTestNotifier(NotificationConfig{Enabled: false, Method: NotificationMethodBoth}, nil)
+
+	if err := n.Notify("hello"); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if len(*desktop) != 0 || len(*terminal) != 0 {
+		t.Error("Disabled notifier should not deliver anything")
+	}
+}
+
+func TestNotifierCustomMessage(t *testing.T) {
+	config := NotificationConfig{Enabled: true, Method: NotificationMethodTerminal, Message: "left {{.FromContext}}"}
+	n, _, terminal := newTestNotifier(config, nil)
+
+	if err := n.NotifySwitch(SwitchEvent{FromContext: "prod", ToContext: "local"}); err != nil {
+		t.Fatalf("NotifySwitch() error = %v", err)
+	}
+	if len(*terminal) != 1 || (*terminal)[0] != "left prod" {
+		t.Errorf("Terminal notifications = %v, want [left prod]", *terminal)
+	}
+}
+
+func TestNotifierDesktopUnsupported(t *testing.T) {
+	// "both" falls back to terminal-only where desktop notifications aren't available
+	n, _, terminal := newTestNotifier(NotificationConfig{Enabled: true, Method: NotificationMethodBoth}, errDesktopUnsupported)
+	if err := n.Notify("hello"); err != nil {
+		t.Errorf("Notify() with method both error = %v, want nil", err)
+	}
+	if len(*terminal) != 1 {
+		t.Errorf("Terminal notifications = %d, want 1", len(*terminal))
+	}
+
+	// Explicitly asking for desktop notifications reports the problem
+	n, _, _ = newTestNotifier(NotificationConfig{Enabled: true, Method: NotificationMethodDesktop}, errDesktopUnsupported)
+	if err := n.Notify("hello"); !errors.Is(err, errDesktopUnsupported) {
+		t.Errorf("Notify() with method desktop error = %v, want %v", err, errDesktopUnsupported)
+	}
+
+	// Other failures are reported even with "both"
+	failure := errors.New("osascript failed")
+	n, _, terminal = newTestNotifier(NotificationConfig{Enabled: true, Method: NotificationMethodBoth}, failure)
+	if err := n.Notify("hello"); !errors.Is(err, failure) {
+		t.Errorf("Notify() error = %v, want %v", err, failure)
+	}
+	if len(*terminal) != 1 {
+		t.Error("Terminal notification should still be delivered when the desktop one fails")
+	}
+}
+
+func TestNotifierQuiet(t *testing.T) {
+	config := NotificationConfig{
+		Enabled:      true,
+		Method:       NotificationMethodBoth,
+		QuietHours:   QuietHoursConfig{Hours: "00:00-23:59"},
+		RespectFocus: true,
+	}
+	n, desktop, terminal := newTestNotifier(config, nil)
+	var heldBack []string
+	n.heldBack = func(reason, message string) {
+		heldBack = append(heldBack, reason+": "+message)
+	}
+
+	if err := n.Notify("hello"); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if len(*desktop) != 0 || len(*terminal) != 0 {
+		t.Error("Notifications should be held back during quiet hours")
+	}
+	if len(heldBack) != 1 || heldBack[0] != "quiet hours: hello" {
+		t.Errorf("Held back = %v, want [quiet hours: hello]", heldBack)
+	}
+
+	// Focus holds notifications back too, unless respect_focus is off
+	n.config.QuietHours = QuietHoursConfig{}
+	n.focusActive = func() (bool, error) { return true, nil }
+	if got := n.QuietReason(time.Now()); got != QuietReasonFocus {
+		t.Errorf("QuietReason() with Focus on = %q, want %q", got, QuietReasonFocus)
+	}
+	n.config.RespectFocus = false
+	if got := n.QuietReason(time.Now()); got != "" {
+		t.Errorf("QuietReason() ignoring Focus = %q, want none", got)
+	}
+
+	// Focus state that can't be read doesn't hold anything back
+	n.config.RespectFocus = true
+	n.focusActive = func() (bool, error) { return false, errors.New("no Focus here") }
+	if err := n.Notify("hello"); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if len(*desktop) != 1 || len(*terminal) != 1 {
+		t.Error("Notifications should be delivered when Focus state can't be read")
+	}
+}
+
+func TestNotifySwitchActions(t *testing.T) {
+	n, _, _ := newTestNotifier(NotificationConfig{Enabled: true, Method: NotificationMethodDesktop}, nil)
+	desktop := n.desktop.(*recordingBackend)
+
+	action := NotificationAction{Label: "Switch back", Run: func() {}}
+	if err := n.NotifySwitch(SwitchEvent{FromContext: "prod", ToContext: "local"}, action); err != nil {
+		t.Fatalf("NotifySwitch() error = %v", err)
+	}
+	if len(desktop.notifications) != 1 {
+		t.Fatalf("Desktop notifications = %d, want 1", len(desktop.notifications))
+	}
+	got := desktop.notifications[0]
+	if got.Title != "kubectx-timeout" || len(got.Actions) != 1 || got.Actions[0].Label != "Switch back" {
+		t.Errorf("Notification = %+v, want a kubectx-timeout notification with a Switch back action", got)
+	}
+}
+
+func TestAlertAnswer(t *testing.T) {
+	var ran []string
+	action := func(label string) NotificationAction {
+		return NotificationAction{Label: label, Run: func() { ran = append(ran, label) }}
+	}
+	notification := Notification{Actions: []NotificationAction{action("Snooze 30m"), action("Switch back")}}
+
+	tests := []struct {
+		output string
+		want   []string
+	}{
+		{"Switch back\n", []string{"Switch back"}},
+		{"Snooze 30m", []string{"Snooze 30m"}},
+		{"@CLOSED\n", nil},
+		{"@TIMEOUT\n", nil},
+		{"@CONTENTCLICKED\n", nil},
+		{"@ACTIONCLICKED\n", nil},
+	}
+	for _, tt := range tests {
+		ran = nil
+		if answer := alertAnswer(notification, tt.output); answer != nil {
+			answer()
+		}
+		if strings.Join(ran, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("alertAnswer(%q) ran %v, want %v", tt.output, ran, tt.want)
+		}
+	}
+
+	// With one action, alerter may only say a button was pressed
+	ran = nil
+	single := Notification{Actions: []NotificationAction{action("Switch back")}}
+	if answer := alertAnswer(single, "@ACTIONCLICKED"); answer != nil {
+		answer()
+	}
+	if len(ran) != 1 {
+		t.Errorf("alertAnswer(@ACTIONCLICKED) with one action ran %v, want it", ran)
+	}
+
+	// Clicking the notification runs the click command
+	if alertAnswer(Notification{ClickCommand: "true"}, "@CONTENTCLICKED") == nil {
+		t.Error("alertAnswer(@CONTENTCLICKED) = nil, want the click command")
+	}
+}
+
+func TestNewDesktopBackend(t *testing.T) {
+	for _, name := range DesktopBackends {
+		backend, err := NewDesktopBackend(name)
+		if err != nil {
+			t.Fatalf("NewDesktopBackend(%q) error = %v", name, err)
+		}
+		if backend.Name() != name {
+			t.Errorf("NewDesktopBackend(%q).Name() = %q", name, backend.Name())
+		}
+	}
+
+	backend, err := NewDesktopBackend("")
+	if err != nil {
+		t.Fatalf("NewDesktopBackend(\"\") error = %v", err)
+	}
+	if backend.Name() != defaultDesktopBackend() {
+		t.Errorf("NewDesktopBackend(\"\").Name() = %q, want %q", backend.Name(), defaultDesktopBackend())
+	}
+
+	if _, err := NewDesktopBackend("growl"); err == nil {
+		t.Error("NewDesktopBackend(growl) succeeded, want an error")
+	}
+}
+
+func TestLinuxBackend(t *testing.T) {
+	// A notify-send that records its arguments
+	tmpDir := t.TempDir()
+	argsFile := filepath.Join(tmpDir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + shellQuote(argsFile) + "\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "notify-send"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", tmpDir)
+
+	if err := (linuxBackend{}).Send(Notification{Title: "kubectx-timeout", Message: "-switched to 'local'"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--app-name=kubectx-timeout\n--\nkubectx-timeout\n-switched to 'local'\n"
+	if string(data) != want {
+		t.Errorf("notify-send arguments = %q, want %q", data, want)
+	}
+
+	// Without notify-send or gdbus, desktop notifications are unavailable
+	t.Setenv("PATH", t.TempDir())
+	if err := (linuxBackend{}).Send(Notification{Title: "kubectx-timeout", Message: "hello"}); !errors.Is(err, errDesktopUnsupported) {
+		t.Errorf("Send() without notify-send error = %v, want %v", err, errDesktopUnsupported)
+	}
+}
+
+func TestGVariantString(t *testing.T) {
+	tests := map[string]string{
+		"plain":      `'plain'`,
+		"don't":      `'don\'t'`,
+		`back\slash`: `'back\\slash'`,
+		"":           `''`,
+	}
+	for in, want := range tests {
+		if got := gvariantString(in); got != want {
+			t.Errorf("gvariantString(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestWriteToUserTerminals(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Regular files owned by the test user stand in for terminal devices
+	ttys := []string{filepath.Join(tmpDir, "pts1"), filepath.Join(tmpDir, "pts2")}
+	for _, tty := range ttys {
+		if err := os.WriteFile(tty, nil, 0600); err != nil {
+			t.Fatalf("Failed to create fake terminal: %v", err)
+		}
+	}
+
+	original := terminalDevicePatterns
+	terminalDevicePatterns = []string{filepath.Join(tmpDir, "pts*")}
+	defer func() { terminalDevicePatterns = original }()
+
+	if err := writeToUserTerminals("switched to local"); err != nil {
+		t.Fatalf("writeToUserTerminals() error = %v", err)
+	}
+
+	for _, tty := range ttys {
+		data, err := os.ReadFile(tty)
+		if err != nil {
+			t.Fatalf("Failed to read fake terminal: %v", err)
+		}
+		if !strings.Contains(string(data), "[kubectx-timeout] switched to local") {
+			t.Errorf("%s = %q, want the notification", tty, data)
+		}
+	}
+}
+
+func TestWriteToUserTerminalsNoTerminals(t *testing.T) {
+	original := terminalDevicePatterns
+	terminalDevicePatterns = []string{filepath.Join(t.TempDir(), "pts*")}
+	defer func() { terminalDevicePatterns = original }()
+
+	if err := writeToUserTerminals("hello"); err != nil {
+		t.Errorf("writeToUserTerminals() with no terminals error = %v, want nil", err)
+	}
+}
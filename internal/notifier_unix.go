@@ -0,0 +1,61 @@
+//go:build !windows
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// writeToUserTerminals writes a message to every terminal the current user
+// has open. Having no open terminals is not an error.
+func writeToUserTerminals(message string) error {
+	line := fmt.Sprintf("\r\n[%s] %s\r\n", notificationTitle, message)
+
+	var errs []error
+	for _, tty := range userTerminals() {
+		// O_NONBLOCK: never hang the daemon on a terminal that is stopped
+		// with ^S; O_NOCTTY: never make it the daemon's controlling terminal
+		// #nosec G304 -- tty is a terminal device owned by the current user
+		f, err := os.OpenFile(tty, os.O_WRONLY|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to open terminal %s: %w", tty, err))
+			continue
+		}
+		if _, err := f.WriteString(line); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write to terminal %s: %w", tty, err))
+		}
+		_ = f.Close()
+	}
+
+	return errors.Join(errs...)
+}
+
+// userTerminals returns the terminal devices owned by the current user
+func userTerminals() []string {
+	uid := os.Getuid()
+
+	var ttys []string
+	for _, pattern := range terminalDevicePatterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, tty := range matches {
+			info, err := os.Stat(tty)
+			if err != nil {
+				continue
+			}
+			stat, ok := info.Sys().(*syscall.Stat_t)
+			if !ok || int(stat.Uid) != uid {
+				continue
+			}
+			ttys = append(ttys, tty)
+		}
+	}
+
+	return ttys
+}
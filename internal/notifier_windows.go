@@ -0,0 +1,8 @@
+package internal
+
+// writeToUserTerminals writes a message to every terminal the current user
+// has open. Windows consoles have no device files another process can write
+// to, so there are never any to reach.
+func writeToUserTerminals(message string) error {
+	return nil
+}
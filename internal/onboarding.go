@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// onboardingWaitTimeout bounds how long onboarding waits for a freshly
+	// installed daemon to come up
+	onboardingWaitTimeout = 10 * time.Second
+
+	// onboardingPollInterval is how often onboarding re-checks while waiting
+	onboardingPollInterval = 250 * time.Millisecond
+)
+
+// OnboardingStep is the result of a single first-run check
+type OnboardingStep struct {
+	Name   string
+	OK     bool
+	Detail string
+	Remedy string // What the user should do if the step failed
+}
+
+// OnboardingChecker verifies that a fresh installation is actually protecting
+// the user: the daemon is up and watching the kubeconfig, and the shell
+// wrapper is recording activity
+type OnboardingChecker struct {
+	service      ServiceManager
+	pidFile      *PIDFile
+	stateManager *StateManager
+	configPath   string
+	controlPath  string
+
+	// since is when installation began; the watcher must have started after it
+	since time.Time
+
+	waitTimeout  time.Duration
+	pollInterval time.Duration
+
+	installedShells func() ([]string, error)
+	detectShell     func() (string, error)
+
+	// sendNotification delivers the test notification
+	sendNotification func(config NotificationConfig, message string) error
+}
+
+// NewOnboardingChecker creates a checker for a daemon installed with the given
+// service manager. since is the time installation began.
+func NewOnboardingChecker(service ServiceManager, since time.Time) (*OnboardingChecker, error) {
+	config, _ := LoadConfig(GetConfigPath())
+	stateManager, err := OpenStateManager(GetStatePath(), config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create state manager: %w", err)
+	}
+
+	return &OnboardingChecker{
+		service:         service,
+		pidFile:         NewPIDFile(),
+		stateManager:    stateManager,
+		configPath:      GetConfigPath(),
+		controlPath:     ControlSocketPathForState(GetStatePath()),
+		since:           since,
+		waitTimeout:     onboardingWaitTimeout,
+		pollInterval:    onboardingPollInterval,
+		installedShells: GetInstalledShells,
+		detectShell:     DetectShell,
+
+		sendNotification: sendTestNotification,
+	}, nil
+}
+
+// Run performs every onboarding check in order and returns the results
+func (oc *OnboardingChecker) Run() []OnboardingStep {
+	config := oc.checkConfig()
+	daemon := oc.checkDaemon()
+	control := oc.checkControlSocket(daemon.OK)
+	watcher := oc.checkWatcher(daemon.OK)
+	shell := oc.checkShellIntegration()
+	notifications := oc.checkNotifications(config.OK)
+
+	return []OnboardingStep{config, daemon, control, watcher, shell, notifications}
+}
+
+// checkConfig verifies a valid configuration file exists
+func (oc *OnboardingChecker) checkConfig() OnboardingStep {
+	step := OnboardingStep{Name: "Configuration"}
+
+	if _, err := os.Stat(oc.configPath); err != nil {
+		step.Detail = fmt.Sprintf("no config at %s", oc.configPath)
+		step.Remedy = "Create a config file: kubectx-timeout init"
+		return step
+	}
+
+	config, err := LoadConfig(oc.configPath)
+	if err != nil {
+		step.Detail = err.Error()
+		step.Remedy = fmt.Sprintf("Fix the configuration in %s, then run: kubectx-timeout daemon-restart", oc.configPath)
+		return step
+	}
+
+	step.OK = true
+	step.Detail = fmt.Sprintf("default context '%s'", config.DefaultContext)
+	return step
+}
+
+// checkDaemon waits for the service to report running and the daemon to hold
+// its PID file
+func (oc *OnboardingChecker) checkDaemon() OnboardingStep {
+	step := OnboardingStep{Name: "Daemon running"}
+
+	running := oc.waitFor(func() bool {
+		return oc.service.IsRunning() && oc.pidFile.IsRunning()
+	})
+	if !running {
+		step.Detail = fmt.Sprintf("%s service did not start within %v", oc.service.Name(), oc.waitTimeout)
+		step.Remedy = fmt.Sprintf("Check the daemon logs in %s, then run: kubectx-timeout daemon-start", GetStateDir())
+		return step
+	}
+
+	step.OK = true
+	if pid, err := oc.pidFile.ReadPID(); err == nil {
+		step.Detail = fmt.Sprintf("PID %d via %s", pid, oc.service.Name())
+	}
+	return step
+}
+
+// checkControlSocket waits for the daemon to answer a status request on its
+// control socket, which CLI commands use to reach it
+func (oc *OnboardingChecker) checkControlSocket(daemonRunning bool) OnboardingStep {
+	step := OnboardingStep{Name: "Control socket"}
+
+	if !daemonRunning {
+		step.Detail = "skipped, daemon is not running"
+		return step
+	}
+
+	var lastErr error
+	reachable := oc.waitFor(func() bool {
+		_, lastErr = SendControlRequest(oc.controlPath, ControlRequest{Command: ControlStatus})
+		return lastErr == nil
+	})
+	if !reachable {
+		step.Detail = lastErr.Error()
+		step.Remedy = fmt.Sprintf("Check the daemon logs in %s, then run: kubectx-timeout daemon-restart", GetStateDir())
+		return step
+	}
+
+	step.OK = true
+	step.Detail = fmt.Sprintf("daemon answering at %s", oc.controlPath)
+	return step
+}
+
+// checkWatcher waits for the daemon to report that kubeconfig monitoring began
+func (oc *OnboardingChecker) checkWatcher(daemonRunning bool) OnboardingStep {
+	step := OnboardingStep{Name: "Kubeconfig watcher"}
+
+	if !daemonRunning {
+		step.Detail = "skipped, daemon is not running"
+		return step
+	}
+
+	var mode string
+	active := oc.waitFor(func() bool {
+		state, err := oc.stateManager.Load()
+		if err != nil {
+			return false
+		}
+		m, startedAt := state.WatcherStatus()
+		if m == "" || startedAt.Before(oc.since) {
+			return false
+		}
+		mode = m
+		return true
+	})
+	if !active {
+		step.Detail = "daemon did not report kubeconfig monitoring"
+		step.Remedy = fmt.Sprintf("Check the daemon logs in %s", GetStateDir())
+		return step
+	}
+
+	step.OK = true
+	if mode == WatcherModeNative {
+		step.Detail = "native file notifications"
+	} else {
+		step.Detail = fmt.Sprintf("polling every %v", pollInterval)
+	}
+	return step
+}
+
+// checkShellIntegration verifies the kubectl wrapper is installed in a shell profile
+func (oc *OnboardingChecker) checkShellIntegration() OnboardingStep {
+	step := OnboardingStep{Name: "Shell integration"}
+
+	shells, err := oc.installedShells()
+	if err != nil || len(shells) == 0 {
+		step.Detail = "kubectl wrapper not found in any shell profile"
+		shell, detectErr := oc.detectShell()
+		if detectErr != nil {
+			shell = "<bash|zsh|fish|powershell>"
+		}
+		step.Remedy = fmt.Sprintf("Install the shell wrapper: kubectx-timeout install-shell %s", shell)
+		return step
+	}
+
+	step.OK = true
+	step.Detail = strings.Join(shells, ", ")
+	return step
+}
+
+// checkNotifications sends a test notification so the user knows what a
+// context switch will look like, and that notifications actually arrive
+func (oc *OnboardingChecker) checkNotifications(configValid bool) OnboardingStep {
+	step := OnboardingStep{Name: "Notifications"}
+
+	if !configValid {
+		step.Detail = "skipped, configuration is not valid"
+		return step
+	}
+
+	config, err := LoadConfig(oc.configPath)
+	if err != nil {
+		step.Detail = "skipped, configuration is not valid"
+		return step
+	}
+
+	if !config.Notifications.Enabled {
+		step.OK = true
+		step.Detail = "disabled"
+		return step
+	}
+
+	message := "Setup complete - you'll be notified here when your kubectl context is switched"
+	if err := oc.sendNotification(config.Notifications, message); err != nil {
+		step.Detail = err.Error()
+		step.Remedy = fmt.Sprintf("Set notifications.method to a method that works on this system in %s, then run: kubectx-timeout daemon-restart", oc.configPath)
+		return step
+	}
+
+	step.OK = true
+	step.Detail = fmt.Sprintf("sent a test notification (%s)", config.Notifications.Method)
+	return step
+}
+
+// sendTestNotification delivers a notification with the given settings.
+// Quiet hours and Focus don't apply, since setup is checking it arrives.
+func sendTestNotification(config NotificationConfig, message string) error {
+	config.QuietHours = QuietHoursConfig{}
+	config.RespectFocus = false
+	return NewNotifier(config).Notify(message)
+}
+
+// waitFor polls check until it succeeds or the wait timeout elapses
+func (oc *OnboardingChecker) waitFor(check func() bool) bool {
+	deadline := time.Now().Add(oc.waitTimeout)
+	for {
+		if check() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(oc.pollInterval)
+	}
+}
+
+// FormatOnboardingResult returns a summary of the onboarding checks, ending in
+// either a "you're protected" line or the next step the user needs to take
+func FormatOnboardingResult(steps []OnboardingStep) string {
+	var sb strings.Builder
+
+	sb.WriteString("\nSetup check:\n")
+	var remaining *OnboardingStep
+	for i := range steps {
+		step := &steps[i]
+		mark := "✓"
+		if !step.OK {
+			mark = "✗"
+			if remaining == nil && step.Remedy != "" {
+				remaining = step
+			}
+		}
+		if step.Detail != "" {
+			sb.WriteString(fmt.Sprintf("  %s %s: %s\n", mark, step.Name, step.Detail))
+		} else {
+			sb.WriteString(fmt.Sprintf("  %s %s\n", mark, step.Name))
+		}
+	}
+
+	if remaining != nil {
+		sb.WriteString(fmt.Sprintf("\nRemaining step (%s):\n  %s\n", remaining.Name, remaining.Remedy))
+	} else if OnboardingComplete(steps) {
+		sb.WriteString("\n✓ You're protected: kubectl contexts will switch to your default after inactivity\n")
+	}
+
+	return sb.String()
+}
+
+// OnboardingComplete reports whether every onboarding step passed
+func OnboardingComplete(steps []OnboardingStep) bool {
+	for _, step := range steps {
+		if !step.OK {
+			return false
+		}
+	}
+	return true
+}
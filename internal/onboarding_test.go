@@ -0,0 +1,281 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServiceManager is a ServiceManager that only reports a fixed running state
+type fakeServiceManager struct {
+	running bool
+}
+
+func (f *fakeServiceManager) Name() string               { return "fake" }
+func (f *fakeServiceManager) Install() error             { return nil }
+func (f *fakeServiceManager) Uninstall() error           { return nil }
+func (f *fakeServiceManager) Load() error                { return nil }
+func (f *fakeServiceManager) Unload() error              { return nil }
+func (f *fakeServiceManager) Restart() error             { return nil }
+func (f *fakeServiceManager) IsInstalled() bool          { return true }
+func (f *fakeServiceManager) IsRunning() bool            { return f.running }
+func (f *fakeServiceManager) GetStatus() (string, error) { return "", nil }
+
+// newTestOnboardingChecker returns a checker with every dependency under tmpDir
+// and a short wait so failing checks don't slow the tests down
+func newTestOnboardingChecker(t *testing.T, tmpDir string, service ServiceManager) *OnboardingChecker {
+	t.Helper()
+
+	sm, err := NewStateManager(filepath.Join(tmpDir, "state.json"))
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+
+	return &OnboardingChecker{
+		service:         service,
+		pidFile:         NewPIDFileWithPath(filepath.Join(tmpDir, "daemon.pid")),
+		stateManager:    sm,
+		configPath:      filepath.Join(tmpDir, "config.yaml"),
+		controlPath:     filepath.Join(tmpDir, controlSocketFileName),
+		since:           time.Now().Add(-time.Minute),
+		waitTimeout:     50 * time.Millisecond,
+		pollInterval:    10 * time.Millisecond,
+		installedShells: func() ([]string, error) { return []string{ShellZsh}, nil },
+		detectShell:     func() (string, error) { return ShellZsh, nil },
+
+		sendNotification: func(NotificationConfig, string) error { return nil },
+	}
+}
+
+// writeTestOnboardingConfig writes a minimal valid config for the checker
+func writeTestOnboardingConfig(t *testing.T, oc *OnboardingChecker) {
+	t.Helper()
+
+	config := "default_context: local\nsafety:\n  validate_default_context: false\n"
+	if err := os.WriteFile(oc.configPath, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+}
+
+func TestOnboardingChecker_AllPass(t *testing.T) {
+	tmpDir := t.TempDir()
+	oc := newTestOnboardingChecker(t, tmpDir, &fakeServiceManager{running: true})
+	writeTestOnboardingConfig(t, oc)
+
+	if err := oc.pidFile.Acquire(); err != nil {
+		t.Fatalf("Failed to acquire PID file: %v", err)
+	}
+	defer oc.pidFile.Release()
+
+	if err := SetWatcherStatus(oc.stateManager, WatcherModeNative); err != nil {
+		t.Fatalf("Failed to set watcher status: %v", err)
+	}
+
+	d := newControlTestDaemon(t, &fakeSwitcher{current: "local"}, &fakeStateStore{})
+	oc.controlPath = d.controlPath
+
+	steps := oc.Run()
+	for _, step := range steps {
+		if !step.OK {
+			t.Errorf("Expected step %q to pass, got: %s", step.Name, step.Detail)
+		}
+	}
+
+	if !OnboardingComplete(steps) {
+		t.Error("Expected onboarding to be complete")
+	}
+
+	output := FormatOnboardingResult(steps)
+	if !strings.Contains(output, "You're protected") {
+		t.Errorf("Expected success summary, got:\n%s", output)
+	}
+	if strings.Contains(output, "Remaining step") {
+		t.Errorf("Did not expect a remaining step, got:\n%s", output)
+	}
+}
+
+func TestOnboardingChecker_DaemonNotRunning(t *testing.T) {
+	tmpDir := t.TempDir()
+	oc := newTestOnboardingChecker(t, tmpDir, &fakeServiceManager{running: false})
+	writeTestOnboardingConfig(t, oc)
+
+	steps := oc.Run()
+	if OnboardingComplete(steps) {
+		t.Fatal("Expected onboarding to be incomplete")
+	}
+
+	daemon, control, watcher := steps[1], steps[2], steps[3]
+	if daemon.OK || daemon.Remedy == "" {
+		t.Errorf("Expected daemon step to fail with a remedy, got %+v", daemon)
+	}
+	if control.OK || control.Remedy != "" {
+		t.Errorf("Expected control socket step to be skipped without a remedy, got %+v", control)
+	}
+	if watcher.OK || watcher.Remedy != "" {
+		t.Errorf("Expected watcher step to be skipped without a remedy, got %+v", watcher)
+	}
+
+	output := FormatOnboardingResult(steps)
+	if !strings.Contains(output, "Remaining step (Daemon running)") {
+		t.Errorf("Expected daemon to be the remaining step, got:\n%s", output)
+	}
+	if strings.Contains(output, "You're protected") {
+		t.Errorf("Did not expect success summary, got:\n%s", output)
+	}
+}
+
+func TestOnboardingChecker_ControlSocketUnreachable(t *testing.T) {
+	tmpDir := t.TempDir()
+	oc := newTestOnboardingChecker(t, tmpDir, &fakeServiceManager{running: true})
+	writeTestOnboardingConfig(t, oc)
+
+	if err := oc.pidFile.Acquire(); err != nil {
+		t.Fatalf("Failed to acquire PID file: %v", err)
+	}
+	defer oc.pidFile.Release()
+
+	if err := SetWatcherStatus(oc.stateManager, WatcherModeNative); err != nil {
+		t.Fatalf("Failed to set watcher status: %v", err)
+	}
+
+	// The daemon is running but nothing listens on the control socket
+	steps := oc.Run()
+	if OnboardingComplete(steps) {
+		t.Fatal("Expected onboarding to be incomplete")
+	}
+
+	control := steps[2]
+	if control.OK || !strings.Contains(control.Remedy, "daemon-restart") {
+		t.Errorf("Expected control socket step to fail with a remedy, got %+v", control)
+	}
+
+	output := FormatOnboardingResult(steps)
+	if !strings.Contains(output, "Remaining step (Control socket)") {
+		t.Errorf("Expected the control socket to be the remaining step, got:\n%s", output)
+	}
+}
+
+func TestOnboardingChecker_StaleWatcherStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	oc := newTestOnboardingChecker(t, tmpDir, &fakeServiceManager{running: true})
+
+	if err := oc.pidFile.Acquire(); err != nil {
+		t.Fatalf("Failed to acquire PID file: %v", err)
+	}
+	defer oc.pidFile.Release()
+
+	// Watcher status left over from a previous daemon run
+	if err := SetWatcherStatus(oc.stateManager, WatcherModeNative); err != nil {
+		t.Fatalf("Failed to set watcher status: %v", err)
+	}
+	oc.since = time.Now().Add(time.Minute)
+
+	step := oc.checkWatcher(true)
+	if step.OK {
+		t.Error("Expected watcher status from before installation to be ignored")
+	}
+}
+
+func TestOnboardingChecker_Config(t *testing.T) {
+	tmpDir := t.TempDir()
+	oc := newTestOnboardingChecker(t, tmpDir, &fakeServiceManager{})
+
+	// Missing config
+	step := oc.checkConfig()
+	if step.OK || !strings.Contains(step.Remedy, "kubectx-timeout init") {
+		t.Errorf("Expected missing config to suggest init, got %+v", step)
+	}
+
+	// Invalid config
+	if err := os.WriteFile(oc.configPath, []byte("default_context: \"\"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	step = oc.checkConfig()
+	if step.OK || !strings.Contains(step.Detail, "default_context is required") {
+		t.Errorf("Expected invalid config to fail with the validation error, got %+v", step)
+	}
+
+	// Valid config
+	writeTestOnboardingConfig(t, oc)
+	step = oc.checkConfig()
+	if !step.OK || !strings.Contains(step.Detail, "local") {
+		t.Errorf("Expected valid config to pass, got %+v", step)
+	}
+}
+
+func TestOnboardingChecker_ShellIntegration(t *testing.T) {
+	tmpDir := t.TempDir()
+	oc := newTestOnboardingChecker(t, tmpDir, &fakeServiceManager{})
+
+	oc.installedShells = func() ([]string, error) { return nil, nil }
+	step := oc.checkShellIntegration()
+	if step.OK || !strings.Contains(step.Remedy, "install-shell zsh") {
+		t.Errorf("Expected remedy for detected shell, got %+v", step)
+	}
+
+	oc.detectShell = func() (string, error) { return "", errors.New("unknown shell") }
+	step = oc.checkShellIntegration()
+	if !strings.Contains(step.Remedy, "install-shell <bash|zsh|fish|powershell>") {
+		t.Errorf("Expected generic remedy when shell can't be detected, got %+v", step)
+	}
+
+	oc.installedShells = func() ([]string, error) { return []string{ShellBash, ShellZsh}, nil }
+	step = oc.checkShellIntegration()
+	if !step.OK || step.Detail != "bash, zsh" {
+		t.Errorf("Expected installed shells to pass, got %+v", step)
+	}
+}
+
+func TestOnboardingChecker_Notifications(t *testing.T) {
+	tmpDir := t.TempDir()
+	oc := newTestOnboardingChecker(t, tmpDir, &fakeServiceManager{running: false})
+
+	// Without a valid config there is nothing to test
+	step := oc.checkNotifications(false)
+	if step.OK || step.Remedy != "" {
+		t.Errorf("Expected notifications step to be skipped without a remedy, got %+v", step)
+	}
+
+	writeTestOnboardingConfig(t, oc)
+
+	var sent []string
+	oc.sendNotification = func(config NotificationConfig, message string) error {
+		if config.Method != NotificationMethodBoth {
+			t.Errorf("Expected configured method 'both', got %q", config.Method)
+		}
+		sent = append(sent, message)
+		return nil
+	}
+
+	step = oc.checkNotifications(true)
+	if !step.OK {
+		t.Errorf("Expected notifications step to pass, got %+v", step)
+	}
+	if len(sent) != 1 {
+		t.Errorf("Expected one test notification, got %d", len(sent))
+	}
+
+	oc.sendNotification = func(NotificationConfig, string) error {
+		return errors.New("osascript failed")
+	}
+	step = oc.checkNotifications(true)
+	if step.OK || step.Remedy == "" {
+		t.Errorf("Expected notifications step to fail with a remedy, got %+v", step)
+	}
+	if !strings.Contains(step.Detail, "osascript failed") {
+		t.Errorf("Expected failure detail, got %q", step.Detail)
+	}
+
+	// Disabled notifications aren't sent, and aren't a problem
+	config := "default_context: local\nnotifications:\n  enabled: false\nsafety:\n  validate_default_context: false\n"
+	if err := os.WriteFile(oc.configPath, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	step = oc.checkNotifications(true)
+	if !step.OK || step.Detail != "disabled" {
+		t.Errorf("Expected disabled notifications to pass, got %+v", step)
+	}
+}
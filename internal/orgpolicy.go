@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OrgPolicy is a system-wide policy an organization installs alongside the
+// binary, such as with an MDM profile. It caps timeouts and adds safety
+// entries, applied on top of the user's configuration, its includes, and
+// environment overrides, so none of them can loosen it.
+type OrgPolicy struct {
+	// MaxTimeout caps the timeout of every context
+	MaxTimeout time.Duration `yaml:"max_timeout,omitempty"`
+
+	// Contexts caps the timeouts of the contexts each name or pattern
+	// matches, beneath MaxTimeout
+	Contexts map[string]time.Duration `yaml:"contexts,omitempty"`
+
+	// NeverSwitchFrom and NeverSwitchTo are always in the user's
+	// safety.never_switch_from and safety.never_switch_to
+	NeverSwitchFrom []string `yaml:"never_switch_from,omitempty"`
+	NeverSwitchTo   []string `yaml:"never_switch_to,omitempty"`
+
+	// path is the file the policy was read from
+	path string
+}
+
+// orgPolicyPath returns where the policy is installed; tests replace it
+var orgPolicyPath = GetPolicyPath
+
+// LoadOrgPolicy reads and validates the policy file at path. It returns nil
+// if there is none. A policy that can't be read or is invalid is an error
+// rather than ignored, so a typo can't quietly lift it.
+func LoadOrgPolicy(path string) (*OrgPolicy, error) {
+	// #nosec G304 -- path is the system policy file
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	policy := OrgPolicy{path: path}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+	}
+
+	if errs := policy.validationErrors(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, errors.Join(errs...))
+	}
+	return &policy, nil
+}
+
+// policyInstalled reports whether there is a policy file at path
+func policyInstalled(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Path returns the file the policy was read from
+func (p *OrgPolicy) Path() string {
+	return p.path
+}
+
+// validationErrors returns every problem with the policy
+func (p *OrgPolicy) validationErrors() []error {
+	var errs []error
+
+	if p.MaxTimeout < 0 {
+		errs = append(errs, fmt.Errorf("max_timeout must not be negative"))
+	}
+	for _, name := range slices.Sorted(maps.Keys(p.Contexts)) {
+		if err := ValidateContextPattern(name); err != nil {
+			errs = append(errs, fmt.Errorf("contexts: %w", err))
+		}
+		if p.Contexts[name] <= 0 {
+			errs = append(errs, fmt.Errorf("contexts.%s must be a positive duration", name))
+		}
+	}
+	for _, list := range []struct {
+		key      string
+		patterns []string
+	}{
+		{"never_switch_from", p.NeverSwitchFrom},
+		{"never_switch_to", p.NeverSwitchTo},
+	} {
+		for _, pattern := range list.patterns {
+			if err := ValidateContextPattern(pattern); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", list.key, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// maxTimeoutFor returns the longest timeout the policy allows a context, if
+// it caps it: the shortest of max_timeout and the contexts entries that
+// match it
+func (p *OrgPolicy) maxTimeoutFor(contextName string) (time.Duration, bool) {
+	if p == nil {
+		return 0, false
+	}
+	limit := p.MaxTimeout
+	for pattern, d := range p.Contexts {
+		if MatchContextPattern(pattern, contextName) && (limit == 0 || d < limit) {
+			limit = d
+		}
+	}
+	return limit, limit > 0
+}
+
+// applyOrgPolicy enforces a policy on the configuration: its never_switch
+// entries are added to the user's, and timeouts are capped as they are
+// looked up, so profiles and schedules can't exceed them either
+func (c *Config) applyOrgPolicy(policy *OrgPolicy, sources ConfigSources) {
+	if policy == nil {
+		return
+	}
+	c.policy = policy
+
+	for _, list := range []struct {
+		key      string
+		dst      *[]string
+		patterns []string
+	}{
+		{"safety.never_switch_from", &c.Safety.NeverSwitchFrom, policy.NeverSwitchFrom},
+		{"safety.never_switch_to", &c.Safety.NeverSwitchTo, policy.NeverSwitchTo},
+	} {
+		added := false
+		for _, pattern := range list.patterns {
+			if !slices.Contains(*list.dst, pattern) {
+				*list.dst = append(*list.dst, pattern)
+				added = true
+			}
+		}
+		if added && sources != nil {
+			sources[list.key] = policy.path
+		}
+	}
+}
+
+// OrgPolicy returns the organization policy enforced on the configuration,
+// or nil if there is none
+func (c *Config) OrgPolicy() *OrgPolicy {
+	return c.policy
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withOrgPolicy installs a policy file for the duration of a test
+func withOrgPolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write policy file: %v", err)
+	}
+	orig := orgPolicyPath
+	orgPolicyPath = func() string { return path }
+	t.Cleanup(func() { orgPolicyPath = orig })
+	return path
+}
+
+func TestLoadOrgPolicy(t *testing.T) {
+	policy, err := LoadOrgPolicy(filepath.Join(t.TempDir(), "policy.yaml"))
+	if err != nil || policy != nil {
+		t.Fatalf("LoadOrgPolicy() with no file = %v, %v, want nil, nil", policy, err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `max_timeout: 1h
+contexts:
+  prod-*: 5m
+never_switch_from:
+  - break-glass
+never_switch_to:
+  - /prod-.*/
+`,
+		},
+		{name: "empty", content: ""},
+		{name: "unknown key", content: "timeout:\n  default: 1h\n", wantErr: "field timeout not found"},
+		{name: "negative max", content: "max_timeout: -5m\n", wantErr: "max_timeout must not be negative"},
+		{name: "zero context cap", content: "contexts:\n  prod: 0s\n", wantErr: "contexts.prod must be a positive duration"},
+		{name: "invalid pattern", content: "never_switch_to:\n  - /prod-(/\n", wantErr: "never_switch_to:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("Failed to write policy file: %v", err)
+			}
+
+			policy, err := LoadOrgPolicy(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadOrgPolicy() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadOrgPolicy() error = %v", err)
+			}
+			if policy.Path() != path {
+				t.Errorf("Path() = %q, want %q", policy.Path(), path)
+			}
+		})
+	}
+}
+
+func TestOrgPolicyEnforced(t *testing.T) {
+	policyPath := withOrgPolicy(t, `max_timeout: 1h
+contexts:
+  prod-*: 5m
+never_switch_from:
+  - break-glass
+never_switch_to:
+  - prod-*
+`)
+	t.Setenv(ConfigEnvVar("timeout", "default"), "")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	config := `timeout:
+  default: 2h
+  check_interval: 30s
+default_context: dev
+contexts:
+  staging:
+    timeout: 30m
+  prod-eu:
+    timeout: 1h
+safety:
+  never_switch_from:
+    - local
+profiles:
+  oncall:
+    timeout: 8h
+`
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	loaded, sources, err := ReadConfigSources(configPath)
+	if err != nil {
+		t.Fatalf("ReadConfigSources() error = %v", err)
+	}
+	if loaded.OrgPolicy() == nil || loaded.OrgPolicy().Path() != policyPath {
+		t.Fatalf("OrgPolicy() = %v, want the policy at %s", loaded.OrgPolicy(), policyPath)
+	}
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		context string
+		want    time.Duration
+	}{
+		{"dev", time.Hour},
+		{"staging", 30 * time.Minute},
+		{"prod-eu", 5 * time.Minute},
+	} {
+		if got := loaded.GetTimeoutForContextAt(tt.context, now); got != tt.want {
+			t.Errorf("GetTimeoutForContextAt(%q) = %v, want %v", tt.context, got, tt.want)
+		}
+	}
+
+	// A profile can't go past the policy either
+	oncall, err := loaded.WithProfile("oncall")
+	if err != nil {
+		t.Fatalf("WithProfile() error = %v", err)
+	}
+	if got := oncall.GetTimeoutForContextAt("dev", now); got != time.Hour {
+		t.Errorf("GetTimeoutForContextAt() with profile = %v, want 1h", got)
+	}
+
+	// The policy's entries are added to the user's
+	if want := []string{"local", "break-glass"}; !slices.Equal(loaded.Safety.NeverSwitchFrom, want) {
+		t.Errorf("NeverSwitchFrom = %v, want %v", loaded.Safety.NeverSwitchFrom, want)
+	}
+	if !loaded.IsNeverSwitchTo("prod-us") {
+		t.Error("Expected prod-us to be in never_switch_to")
+	}
+	if got := sources.Source("safety.never_switch_from"); got != policyPath {
+		t.Errorf("Source(safety.never_switch_from) = %q, want %q", got, policyPath)
+	}
+
+	// Nor can an environment override
+	t.Setenv(ConfigEnvVar("timeout", "default"), "3h")
+	t.Setenv(ConfigEnvVar("safety", "never_switch_to"), "staging")
+	loaded, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if got := loaded.GetTimeoutForContextAt("dev", now); got != time.Hour {
+		t.Errorf("GetTimeoutForContextAt() with override = %v, want 1h", got)
+	}
+	if !loaded.IsNeverSwitchTo("prod-us") {
+		t.Error("Expected prod-us to stay in never_switch_to despite the override")
+	}
+}
+
+func TestOrgPolicyInvalid(t *testing.T) {
+	withOrgPolicy(t, "max_timeout: soon\n")
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "config.yaml")); err == nil {
+		t.Fatal("Expected an invalid policy to be an error, even without a config file")
+	}
+}
@@ -3,14 +3,19 @@ package internal
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 )
 
 // GetConfigDir returns the configuration directory following XDG Base Directory spec.
-// Returns $XDG_CONFIG_HOME/kubectx-timeout if set, otherwise ~/.config/kubectx-timeout
+// Returns $XDG_CONFIG_HOME/kubectx-timeout if set, otherwise
+// %APPDATA%\kubectx-timeout on Windows and ~/.config/kubectx-timeout elsewhere
 func GetConfigDir() string {
 	if xdgConfig := os.Getenv("XDG_CONFIG_HOME"); xdgConfig != "" {
 		return filepath.Join(xdgConfig, "kubectx-timeout")
 	}
+	if appData := os.Getenv("APPDATA"); appData != "" && runtime.GOOS == "windows" {
+		return filepath.Join(appData, "kubectx-timeout")
+	}
 
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -22,11 +27,16 @@ func GetConfigDir() string {
 }
 
 // GetStateDir returns the state directory following XDG Base Directory spec.
-// Returns $XDG_STATE_HOME/kubectx-timeout if set, otherwise ~/.local/state/kubectx-timeout
+// Returns $XDG_STATE_HOME/kubectx-timeout if set, otherwise
+// %LOCALAPPDATA%\kubectx-timeout on Windows and ~/.local/state/kubectx-timeout
+// elsewhere
 func GetStateDir() string {
 	if xdgState := os.Getenv("XDG_STATE_HOME"); xdgState != "" {
 		return filepath.Join(xdgState, "kubectx-timeout")
 	}
+	if localAppData := os.Getenv("LOCALAPPDATA"); localAppData != "" && runtime.GOOS == "windows" {
+		return filepath.Join(localAppData, "kubectx-timeout")
+	}
 
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -42,6 +52,37 @@ func GetConfigPath() string {
 	return filepath.Join(GetConfigDir(), "config.yaml")
 }
 
+// GetPolicyPath returns where an organization installs its policy file:
+// /Library/Application Support/kubectx-timeout/policy.yaml on macOS,
+// %ProgramData%\kubectx-timeout\policy.yaml on Windows, and
+// /etc/kubectx-timeout/policy.yaml elsewhere. Unlike the configuration,
+// it is outside the user's home directory and needs an administrator to
+// change.
+func GetPolicyPath() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join("/Library", "Application Support", "kubectx-timeout", "policy.yaml")
+	case "windows":
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			programData = `C:\ProgramData`
+		}
+		return filepath.Join(programData, "kubectx-timeout", "policy.yaml")
+	default:
+		return filepath.Join("/etc", "kubectx-timeout", "policy.yaml")
+	}
+}
+
+// GetIntegrationPath returns the full path to the shell integration file
+// that the profile of the given shell sources. PowerShell only runs scripts
+// with a .ps1 extension.
+func GetIntegrationPath(shell string) string {
+	if shell == ShellPowerShell {
+		return filepath.Join(GetConfigDir(), "integration.ps1")
+	}
+	return filepath.Join(GetConfigDir(), "integration."+shell)
+}
+
 // GetStatePath returns the full path to the state file
 func GetStatePath() string {
 	return filepath.Join(GetStateDir(), "state.json")
@@ -52,20 +93,70 @@ func GetLogPath() string {
 	return filepath.Join(GetStateDir(), "daemon.log")
 }
 
+// GetDaemonStdoutPath returns where the service manager sends the daemon's
+// stdout
+func GetDaemonStdoutPath() string {
+	return filepath.Join(GetStateDir(), "daemon.stdout.log")
+}
+
+// GetDaemonStderrPath returns where the service manager sends the daemon's
+// stderr
+func GetDaemonStderrPath() string {
+	return filepath.Join(GetStateDir(), "daemon.stderr.log")
+}
+
+// GetKubeCacheDir returns kubectl's cache directory.
+// Returns $KUBECACHEDIR if set, otherwise ~/.kube/cache
+func GetKubeCacheDir() string {
+	if cacheDir := os.Getenv("KUBECACHEDIR"); cacheDir != "" {
+		return cacheDir
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		// Fallback if we can't get home directory
+		return filepath.Join("/tmp", ".kube", "cache")
+	}
+
+	return filepath.Join(home, ".kube", "cache")
+}
+
 // GetKubeconfigPath returns the path to the kubeconfig file.
-// Returns $KUBECONFIG if set, otherwise ~/.kube/config
+// Returns the first entry of $KUBECONFIG if set, otherwise ~/.kube/config
 func GetKubeconfigPath() string {
-	if kubeconfigEnv := os.Getenv("KUBECONFIG"); kubeconfigEnv != "" {
-		// KUBECONFIG can contain multiple paths separated by colons
-		// We only watch the first one as it has the highest precedence
-		return filepath.SplitList(kubeconfigEnv)[0]
+	return GetKubeconfigPaths()[0]
+}
+
+// GetKubeconfigPaths returns every kubeconfig file kubectl merges.
+// KUBECONFIG can contain multiple paths separated by colons, and the
+// current-context may be set in any of them. Returns ~/.kube/config if
+// KUBECONFIG is unset.
+func GetKubeconfigPaths() []string {
+	return kubeconfigPathsFor(os.Getenv("KUBECONFIG"))
+}
+
+// kubeconfigPathsFor returns the files of the given KUBECONFIG value, or
+// ~/.kube/config if it is empty
+func kubeconfigPathsFor(kubeconfig string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, path := range filepath.SplitList(kubeconfig) {
+		// Empty entries are ignored by kubectl, and duplicates are merged
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	if len(paths) > 0 {
+		return paths
 	}
 
 	home, err := os.UserHomeDir()
 	if err != nil {
 		// Fallback if we can't get home directory
-		return filepath.Join("/tmp", ".kube", "config")
+		return []string{filepath.Join("/tmp", ".kube", "config")}
 	}
 
-	return filepath.Join(home, ".kube", "config")
+	return []string{filepath.Join(home, ".kube", "config")}
 }
@@ -2,6 +2,7 @@ package internal
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -244,6 +245,52 @@ func TestGetKubeconfigPath(t *testing.T) {
 	}
 }
 
+func TestGetKubeconfigPaths(t *testing.T) {
+	tests := []struct {
+		name          string
+		kubeconfig    string
+		home          string
+		expectedPaths []string
+	}{
+		{
+			name:          "KUBECONFIG env with multiple paths (colon-separated)",
+			kubeconfig:    "/first/path/config:/second/path/config:/third/path/config",
+			home:          "/home/user",
+			expectedPaths: []string{"/first/path/config", "/second/path/config", "/third/path/config"},
+		},
+		{
+			name:          "KUBECONFIG env with empty and duplicate entries",
+			kubeconfig:    ":/first/path/config::/second/path/config:/first/path/config",
+			home:          "/home/user",
+			expectedPaths: []string{"/first/path/config", "/second/path/config"},
+		},
+		{
+			name:          "KUBECONFIG env with only separators, use default",
+			kubeconfig:    "::",
+			home:          "/home/user",
+			expectedPaths: []string{"/home/user/.kube/config"},
+		},
+		{
+			name:          "KUBECONFIG not set, use default",
+			kubeconfig:    "",
+			home:          "/home/user",
+			expectedPaths: []string{"/home/user/.kube/config"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", tt.home)
+			t.Setenv("KUBECONFIG", tt.kubeconfig)
+
+			result := GetKubeconfigPaths()
+			if strings.Join(result, ",") != strings.Join(tt.expectedPaths, ",") {
+				t.Errorf("GetKubeconfigPaths() = %v, want %v", result, tt.expectedPaths)
+			}
+		})
+	}
+}
+
 func TestGetLogPath(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -288,3 +335,15 @@ func TestGetLogPath(t *testing.T) {
 		})
 	}
 }
+
+func TestGetIntegrationPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/custom/config")
+
+	if got := GetIntegrationPath(ShellZsh); got != "/custom/config/kubectx-timeout/integration.zsh" {
+		t.Errorf("GetIntegrationPath() = %v, want /custom/config/kubectx-timeout/integration.zsh", got)
+	}
+	// PowerShell only runs .ps1 scripts
+	if got := GetIntegrationPath(ShellPowerShell); got != "/custom/config/kubectx-timeout/integration.ps1" {
+		t.Errorf("GetIntegrationPath() = %v, want /custom/config/kubectx-timeout/integration.ps1", got)
+	}
+}
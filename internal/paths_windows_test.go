@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWindowsAppDataDirs(t *testing.T) {
+	appData, localAppData := t.TempDir(), t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("APPDATA", appData)
+	t.Setenv("LOCALAPPDATA", localAppData)
+
+	if got, want := GetConfigDir(), filepath.Join(appData, "kubectx-timeout"); got != want {
+		t.Errorf("GetConfigDir() = %v, want %v", got, want)
+	}
+	if got, want := GetStateDir(), filepath.Join(localAppData, "kubectx-timeout"); got != want {
+		t.Errorf("GetStateDir() = %v, want %v", got, want)
+	}
+	if got, want := GetIntegrationPath(ShellPowerShell), filepath.Join(appData, "kubectx-timeout", "integration.ps1"); got != want {
+		t.Errorf("GetIntegrationPath() = %v, want %v", got, want)
+	}
+
+	// XDG variables still win, for users who set them deliberately
+	xdgConfig := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdgConfig)
+	if got, want := GetConfigDir(), filepath.Join(xdgConfig, "kubectx-timeout"); got != want {
+		t.Errorf("GetConfigDir() with XDG_CONFIG_HOME = %v, want %v", got, want)
+	}
+}
@@ -1,17 +1,25 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 )
 
 // PIDFile manages a PID file to ensure single daemon instance
+//
+// The file holds the daemon's PID on the first line and the process start
+// time on the second. The start time lets a stale file be detected even when
+// the OS has since reused the PID for an unrelated process.
 type PIDFile struct {
 	path string
+
+	mu       sync.Mutex
+	acquired bool
 }
 
 // NewPIDFile creates a new PID file manager using the default state directory
@@ -28,70 +36,175 @@ func NewPIDFileWithPath(path string) *PIDFile {
 }
 
 // Acquire creates the PID file and writes the current process ID
-// Returns an error if another instance is already running
+// Returns an error if another instance is already running.
+// Calling Acquire again on a PIDFile that already holds the file is a no-op.
 func (p *PIDFile) Acquire() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.acquired {
+		return nil
+	}
+
 	// Ensure state directory exists
 	stateDir := filepath.Dir(p.path)
 	if err := os.MkdirAll(stateDir, 0750); err != nil {
 		return fmt.Errorf("failed to create state directory: %w", err)
 	}
 
-	// Check if PID file already exists
-	if _, err := os.Stat(p.path); err == nil {
-		// PID file exists, check if process is still running
-		existingPID, err := p.ReadPID()
-		if err == nil && p.isProcessRunning(existingPID) {
+	// Serialize acquisition across processes so the stale check and removal
+	// below can't race with another daemon starting at the same time
+	unlock, err := lockDir(stateDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content := strconv.Itoa(os.Getpid()) + "\n"
+	if start, err := processStartTime(os.Getpid()); err == nil {
+		content += start + "\n"
+	}
+
+	// Create exclusively so two daemons starting at once can't both win.
+	// A stale file is removed and creation retried once.
+	for attempt := 0; attempt < 2; attempt++ {
+		err := p.createExclusive(content)
+		if err == nil {
+			p.acquired = true
+			return nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("failed to write PID file: %w", err)
+		}
+
+		// PID file exists, check if its owner is still running
+		existingPID, _, err := p.read()
+		if err == nil && p.IsRunning() {
 			return fmt.Errorf("daemon is already running with PID %d", existingPID)
 		}
+
 		// Stale PID file, remove it
 		_ = os.Remove(p.path) // Ignore error on cleanup
 	}
 
-	// Write current PID to file
-	pid := os.Getpid()
-	pidStr := strconv.Itoa(pid)
-	if err := os.WriteFile(p.path, []byte(pidStr+"\n"), 0600); err != nil {
-		return fmt.Errorf("failed to write PID file: %w", err)
+	return fmt.Errorf("failed to acquire PID file %s: another instance is starting", p.path)
+}
+
+// createExclusive writes content to the PID file, failing if it already exists
+func (p *PIDFile) createExclusive(content string) error {
+	f, err := os.OpenFile(p.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		_ = os.Remove(p.path)
+		return err
+	}
+
+	return f.Close()
 }
 
-// Release removes the PID file
+// Release removes the PID file if this PIDFile acquired it.
+// It is safe to call multiple times, and never removes a file that has since
+// been taken over by another process.
 func (p *PIDFile) Release() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.acquired {
+		return nil
+	}
+	p.acquired = false
+
+	// Only remove the file if it still belongs to this process
+	pid, _, err := p.read()
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+
 	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove PID file: %w", err)
 	}
 	return nil
 }
 
+// RemoveStale removes the PID file if the process it names is no longer
+// running. Returns true if a stale file was removed.
+func (p *PIDFile) RemoveStale() (bool, error) {
+	if _, err := os.Stat(p.path); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if p.IsRunning() {
+		return false, nil
+	}
+
+	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to remove stale PID file: %w", err)
+	}
+	return true, nil
+}
+
 // ReadPID reads the PID from the PID file
 func (p *PIDFile) ReadPID() (int, error) {
+	pid, _, err := p.read()
+	return pid, err
+}
+
+// read parses the PID file, returning the PID and the recorded start time
+// (empty for files written by older versions)
+func (p *PIDFile) read() (int, string, error) {
 	data, err := os.ReadFile(p.path)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read PID file: %w", err)
+		return 0, "", fmt.Errorf("failed to read PID file: %w", err)
 	}
 
-	pidStr := strings.TrimSpace(string(data))
-	pid, err := strconv.Atoi(pidStr)
+	lines := strings.SplitN(strings.TrimSpace(string(data)), "\n", 2)
+	pid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
 	if err != nil {
-		return 0, fmt.Errorf("invalid PID in file: %w", err)
+		return 0, "", fmt.Errorf("invalid PID in file: %w", err)
+	}
+
+	start := ""
+	if len(lines) > 1 {
+		start = strings.TrimSpace(lines[1])
 	}
 
-	return pid, nil
+	return pid, start, nil
 }
 
-// isProcessRunning checks if a process with the given PID is running
-func (p *PIDFile) isProcessRunning(pid int) bool {
-	// Send signal 0 to check if process exists
-	process, err := os.FindProcess(pid)
+// IsRunning checks whether the process recorded in the PID file is still
+// the one that wrote it
+func (p *PIDFile) IsRunning() bool {
+	pid, recordedStart, err := p.read()
 	if err != nil {
 		return false
 	}
 
-	// On Unix, FindProcess always succeeds, so we need to send a signal to check
-	err = process.Signal(syscall.Signal(0))
-	return err == nil
+	if !p.isProcessRunning(pid) {
+		return false
+	}
+
+	// Without a recorded start time we can only trust the PID
+	if recordedStart == "" {
+		return true
+	}
+
+	currentStart, err := processStartTime(pid)
+	if err != nil {
+		// Can't verify, assume the process is ours to be safe
+		return true
+	}
+
+	// A different start time means the PID was reused by another process
+	return currentStart == recordedStart
+}
+
+// isProcessRunning checks if a process with the given PID is running
+func (p *PIDFile) isProcessRunning(pid int) bool {
+	return ProcessExists(pid)
 }
 
 // GetPath returns the path to the PID file
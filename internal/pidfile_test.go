@@ -2,8 +2,10 @@ package internal
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"testing"
 )
 
@@ -147,3 +149,241 @@ func TestPIDFile_ReadPID_InvalidContent(t *testing.T) {
 		t.Error("Expected error when reading invalid PID file")
 	}
 }
+
+func TestPIDFile_ReleaseTwice(t *testing.T) {
+	tmpDir := t.TempDir()
+	pidPath := filepath.Join(tmpDir, "daemon.pid")
+
+	pidFile := &PIDFile{path: pidPath}
+	if err := pidFile.Acquire(); err != nil {
+		t.Fatalf("Failed to acquire PID file: %v", err)
+	}
+
+	// Shutdown and Run's deferred cleanup both release the PID file
+	if err := pidFile.Release(); err != nil {
+		t.Fatalf("First release failed: %v", err)
+	}
+	if err := pidFile.Release(); err != nil {
+		t.Errorf("Second release should be a no-op, got: %v", err)
+	}
+}
+
+func TestPIDFile_ReleaseDoesNotRemoveOtherOwner(t *testing.T) {
+	tmpDir := t.TempDir()
+	pidPath := filepath.Join(tmpDir, "daemon.pid")
+
+	pidFile := &PIDFile{path: pidPath}
+	if err := pidFile.Acquire(); err != nil {
+		t.Fatalf("Failed to acquire PID file: %v", err)
+	}
+
+	// Simulate a restarted daemon taking over the PID file
+	if err := os.WriteFile(pidPath, []byte("1\n"), 0600); err != nil {
+		t.Fatalf("Failed to overwrite PID file: %v", err)
+	}
+
+	if err := pidFile.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := os.Stat(pidPath); err != nil {
+		t.Error("Release should not remove a PID file owned by another process")
+	}
+}
+
+func TestPIDFile_ReleaseWithoutAcquire(t *testing.T) {
+	tmpDir := t.TempDir()
+	pidPath := filepath.Join(tmpDir, "daemon.pid")
+
+	// A PID file written by a running daemon
+	owner := &PIDFile{path: pidPath}
+	if err := owner.Acquire(); err != nil {
+		t.Fatalf("Failed to acquire PID file: %v", err)
+	}
+	defer owner.Release()
+
+	other := &PIDFile{path: pidPath}
+	if err := other.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := os.Stat(pidPath); err != nil {
+		t.Error("Release should not remove a PID file it did not acquire")
+	}
+}
+
+func TestPIDFile_AcquireIdempotent(t *testing.T) {
+	tmpDir := t.TempDir()
+	pidPath := filepath.Join(tmpDir, "daemon.pid")
+
+	pidFile := &PIDFile{path: pidPath}
+	if err := pidFile.Acquire(); err != nil {
+		t.Fatalf("First acquire failed: %v", err)
+	}
+	defer pidFile.Release()
+
+	if err := pidFile.Acquire(); err != nil {
+		t.Errorf("Acquiring again with the same PIDFile should succeed, got: %v", err)
+	}
+}
+
+func TestPIDFile_RecordsStartTime(t *testing.T) {
+	start, err := processStartTime(os.Getpid())
+	if err != nil {
+		t.Skipf("Process start time unavailable: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	pidPath := filepath.Join(tmpDir, "daemon.pid")
+
+	pidFile := &PIDFile{path: pidPath}
+	if err := pidFile.Acquire(); err != nil {
+		t.Fatalf("Failed to acquire PID file: %v", err)
+	}
+	defer pidFile.Release()
+
+	pid, recorded, err := pidFile.read()
+	if err != nil {
+		t.Fatalf("Failed to read PID file: %v", err)
+	}
+	if pid != os.Getpid() {
+		t.Errorf("Expected PID %d, got %d", os.Getpid(), pid)
+	}
+	if recorded != start {
+		t.Errorf("Expected start time %q, got %q", start, recorded)
+	}
+}
+
+func TestPIDFile_ReusedPIDIsStale(t *testing.T) {
+	if _, err := processStartTime(os.Getpid()); err != nil {
+		t.Skipf("Process start time unavailable: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	pidPath := filepath.Join(tmpDir, "daemon.pid")
+
+	// The PID is alive (it's ours) but the start time doesn't match, as if
+	// the daemon crashed and the OS handed its PID to another process
+	content := strconv.Itoa(os.Getpid()) + "\nnot-the-real-start-time\n"
+	if err := os.WriteFile(pidPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write PID file: %v", err)
+	}
+
+	pidFile := &PIDFile{path: pidPath}
+	if pidFile.IsRunning() {
+		t.Error("PID file with mismatched start time should not be considered running")
+	}
+
+	if err := pidFile.Acquire(); err != nil {
+		t.Fatalf("Acquire should replace a PID file whose PID was reused: %v", err)
+	}
+	defer pidFile.Release()
+
+	if !pidFile.IsRunning() {
+		t.Error("Freshly acquired PID file should be considered running")
+	}
+}
+
+func TestPIDFile_LegacyFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+	pidPath := filepath.Join(tmpDir, "daemon.pid")
+
+	// Files written by older versions contain only the PID
+	if err := os.WriteFile(pidPath, []byte(strconv.Itoa(os.Getpid())+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write PID file: %v", err)
+	}
+
+	pidFile := &PIDFile{path: pidPath}
+	if !pidFile.IsRunning() {
+		t.Error("Legacy PID file naming a live process should be considered running")
+	}
+	if err := pidFile.Acquire(); err == nil {
+		t.Error("Acquire should fail while a legacy PID file names a live process")
+	}
+}
+
+func TestPIDFile_RemoveStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	pidPath := filepath.Join(tmpDir, "daemon.pid")
+	pidFile := &PIDFile{path: pidPath}
+
+	// No file
+	removed, err := pidFile.RemoveStale()
+	if err != nil || removed {
+		t.Errorf("Expected nothing removed for missing file, got removed=%v err=%v", removed, err)
+	}
+
+	// Live owner
+	owner := &PIDFile{path: pidPath}
+	if err := owner.Acquire(); err != nil {
+		t.Fatalf("Failed to acquire PID file: %v", err)
+	}
+	removed, err = pidFile.RemoveStale()
+	if err != nil || removed {
+		t.Errorf("Expected live PID file to be kept, got removed=%v err=%v", removed, err)
+	}
+	if err := owner.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+
+	// Dead owner
+	if err := os.WriteFile(pidPath, []byte("999999\n"), 0600); err != nil {
+		t.Fatalf("Failed to write stale PID file: %v", err)
+	}
+	removed, err = pidFile.RemoveStale()
+	if err != nil || !removed {
+		t.Errorf("Expected stale PID file to be removed, got removed=%v err=%v", removed, err)
+	}
+	if _, err := os.Stat(pidPath); !os.IsNotExist(err) {
+		t.Error("Stale PID file should not exist after RemoveStale")
+	}
+}
+
+func TestPIDFile_ConcurrentAcquire(t *testing.T) {
+	tmpDir := t.TempDir()
+	pidPath := filepath.Join(tmpDir, "daemon.pid")
+
+	// Leave a stale file behind so every contender races on the cleanup path
+	if err := os.WriteFile(pidPath, []byte("999999\n"), 0600); err != nil {
+		t.Fatalf("Failed to write stale PID file: %v", err)
+	}
+
+	const contenders = 10
+	pidFiles := make([]*PIDFile, contenders)
+	errs := make([]error, contenders)
+
+	var wg sync.WaitGroup
+	for i := range pidFiles {
+		pidFiles[i] = &PIDFile{path: pidPath}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = pidFiles[i].Acquire()
+		}(i)
+	}
+	wg.Wait()
+
+	winners := 0
+	for i, err := range errs {
+		if err == nil {
+			winners++
+			defer pidFiles[i].Release()
+		}
+	}
+	if winners != 1 {
+		t.Errorf("Expected exactly one contender to acquire the PID file, got %d", winners)
+	}
+}
+
+func TestProcessExists(t *testing.T) {
+	if !ProcessExists(os.Getpid()) {
+		t.Error("ProcessExists() = false for the current process")
+	}
+
+	// A process that has exited and been waited for no longer exists
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to run child process: %v", err)
+	}
+	if ProcessExists(cmd.Process.Pid) {
+		t.Errorf("ProcessExists() = true for exited process %d", cmd.Process.Pid)
+	}
+}
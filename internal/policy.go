@@ -0,0 +1,311 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Policy actions, in the order a context moves through them as it idles
+const (
+	// PolicyActionNone means nothing will happen: the context is the
+	// default, exempt, extended, or paused
+	PolicyActionNone = "none"
+	// PolicyActionWait means the timeout is counting down
+	PolicyActionWait = "wait"
+	// PolicyActionDefer means the timeout has passed but Kubernetes tools
+	// are running (check_active_kubectl)
+	PolicyActionDefer = "defer"
+	// PolicyActionGrace means the timeout has passed and the switch is
+	// waiting out the grace period
+	PolicyActionGrace = "grace"
+	// PolicyActionSwitch means the context is switched to the default now
+	PolicyActionSwitch = "switch"
+)
+
+// PolicyInputs is everything the timeout policy decides on
+type PolicyInputs struct {
+	Now            time.Time `json:"now"`
+	CurrentContext string    `json:"current_context"`
+	DefaultContext string    `json:"default_context"`
+
+	// LastActivity is zero if no kubectl activity has been recorded
+	LastActivity time.Time     `json:"-"`
+	Timeout      time.Duration `json:"-"`
+	GracePeriod  time.Duration `json:"-"`
+
+	// LastWrite is when a command that changes the cluster last ran, and
+	// WriteTimeout the timeout that follows it (timeout.write_commands).
+	// A write only matters while it outlasts the last activity's timeout.
+	LastWrite    time.Time     `json:"-"`
+	WriteTimeout time.Duration `json:"-"`
+
+	// AfterHours is set outside the schedule's work hours, when Timeout is
+	// the after-hours timeout
+	AfterHours bool `json:"after_hours,omitempty"`
+
+	// NeverSwitchFrom is set if the current context is in never_switch_from,
+	// and DefaultForbidden if the default context is in never_switch_to
+	NeverSwitchFrom  bool `json:"never_switch_from"`
+	DefaultForbidden bool `json:"default_forbidden"`
+
+	// ExtendedUntil and PausedUntil are zero unless set by the extend and
+	// pause-context commands
+	ExtendedUntil time.Time `json:"-"`
+	PausedUntil   time.Time `json:"-"`
+
+	Pending PendingSwitch `json:"-"`
+
+	// ActiveProcesses are the running Kubernetes tools found when
+	// check_active_kubectl is enabled and a switch is due
+	ActiveProcesses []string `json:"active_processes,omitempty"`
+
+	// DeferredSince is when running tools started deferring the switch,
+	// and MaxDefer how long they may (safety.max_defer, zero for no limit).
+	// DeferredSince is only known to the daemon; when zero, the deferral
+	// starts now.
+	DeferredSince time.Time     `json:"-"`
+	MaxDefer      time.Duration `json:"-"`
+
+	// StaleCredentials describes expired credentials of the current and
+	// default contexts. They don't affect the decision.
+	StaleCredentials []string `json:"stale_credentials,omitempty"`
+}
+
+// MarshalJSON encodes durations as seconds and omits unset times
+func (in PolicyInputs) MarshalJSON() ([]byte, error) {
+	type inputs PolicyInputs
+	out := struct {
+		inputs
+		LastActivity        *time.Time `json:"last_activity,omitempty"`
+		TimeoutSeconds      int64      `json:"timeout_seconds"`
+		GracePeriodSeconds  int64      `json:"grace_period_seconds"`
+		LastWrite           *time.Time `json:"last_write,omitempty"`
+		WriteTimeoutSeconds int64      `json:"write_timeout_seconds,omitempty"`
+		ExtendedUntil       *time.Time `json:"extended_until,omitempty"`
+		PausedUntil         *time.Time `json:"paused_until,omitempty"`
+		PendingSwitchTo     string     `json:"pending_switch_to,omitempty"`
+		PendingSwitchAt     *time.Time `json:"pending_switch_at,omitempty"`
+		DeferredSince       *time.Time `json:"deferred_since,omitempty"`
+		MaxDeferSeconds     int64      `json:"max_defer_seconds,omitempty"`
+	}{
+		inputs:              inputs(in),
+		LastActivity:        optionalTime(in.LastActivity),
+		TimeoutSeconds:      int64(in.Timeout / time.Second),
+		GracePeriodSeconds:  int64(in.GracePeriod / time.Second),
+		LastWrite:           optionalTime(in.LastWrite),
+		WriteTimeoutSeconds: int64(in.WriteTimeout / time.Second),
+		ExtendedUntil:       optionalTime(in.ExtendedUntil),
+		PausedUntil:         optionalTime(in.PausedUntil),
+		PendingSwitchTo:     in.Pending.To,
+		PendingSwitchAt:     optionalTime(in.Pending.At),
+		DeferredSince:       optionalTime(in.DeferredSince),
+		MaxDeferSeconds:     int64(in.MaxDefer / time.Second),
+	}
+	return json.Marshal(out)
+}
+
+// optionalTime returns nil for the zero time, so it's omitted from JSON
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Idle returns how long the current context has been inactive. It is
+// unbounded if no activity has been recorded.
+func (in PolicyInputs) Idle() time.Duration {
+	if in.LastActivity.IsZero() {
+		return time.Duration(1<<63 - 1)
+	}
+	return in.Now.Sub(in.LastActivity)
+}
+
+// writeDeadline returns when the timeout after the last write ends, and
+// whether that is later than the timeout after the last activity
+func (in PolicyInputs) writeDeadline() (time.Time, bool) {
+	if in.LastWrite.IsZero() || in.WriteTimeout <= 0 || in.LastActivity.IsZero() {
+		return time.Time{}, false
+	}
+	deadline := in.LastWrite.Add(in.WriteTimeout)
+	return deadline, deadline.After(in.LastActivity.Add(in.Timeout))
+}
+
+// pendingMatches reports whether the recorded pending switch is the one
+// the policy would start now
+func (in PolicyInputs) pendingMatches() bool {
+	return in.Pending.From == in.CurrentContext && in.Pending.To == in.DefaultContext
+}
+
+// PolicyDecision is the outcome of the timeout policy, with the reasons
+// that led to it
+type PolicyDecision struct {
+	Action  string   `json:"action"`
+	Reasons []string `json:"reasons"`
+
+	// SwitchAt is when the switch is expected, for the wait and grace actions
+	SwitchAt time.Time `json:"-"`
+}
+
+// MarshalJSON omits an unset switch time
+func (d PolicyDecision) MarshalJSON() ([]byte, error) {
+	type decision PolicyDecision
+	return json.Marshal(struct {
+		decision
+		SwitchAt *time.Time `json:"switch_at,omitempty"`
+	}{decision(d), optionalTime(d.SwitchAt)})
+}
+
+// Due reports whether the timeout has passed and the switch is only held up
+// by running tools or the grace period, if anything
+func (d PolicyDecision) Due() bool {
+	return d.Action == PolicyActionDefer || d.Action == PolicyActionGrace || d.Action == PolicyActionSwitch
+}
+
+// GatherPolicyInputs collects the policy inputs from the config, the state
+// store, and the current kubectl context. Active processes and credentials
+// are left for the caller, as they are costly to collect.
+func GatherPolicyInputs(config *Config, store StateStore, switcher Switcher, now time.Time) (PolicyInputs, error) {
+	in := PolicyInputs{
+		Now:         now,
+		GracePeriod: config.Timeout.GracePeriod,
+		MaxDefer:    config.Safety.MaxDefer,
+	}
+
+	state, err := store.Load()
+	if err != nil {
+		return in, fmt.Errorf("%w: failed to load state: %w", errStateUnavailable, err)
+	}
+	state.mu.RLock()
+	in.LastActivity = state.LastActivity
+	in.LastWrite = state.LastWriteActivity
+	in.ExtendedUntil = state.ExtendedUntil
+	state.mu.RUnlock()
+
+	currentContext, err := switcher.CurrentContext()
+	if err != nil {
+		return in, fmt.Errorf("%w: %w", errContextUnavailable, err)
+	}
+	in.CurrentContext = currentContext
+	in.DefaultContext = config.GetDefaultContextFor(currentContext)
+	in.Timeout = config.GetTimeoutForContextAt(currentContext, now)
+	in.WriteTimeout = config.Timeout.WriteCommands
+	in.AfterHours = config.IsAfterHours(now)
+	in.NeverSwitchFrom = config.IsNeverSwitchFrom(currentContext)
+	in.DefaultForbidden = config.IsNeverSwitchTo(in.DefaultContext)
+	in.PausedUntil = state.ContextPausedUntil(currentContext)
+	in.Pending = state.PendingSwitch()
+
+	return in, nil
+}
+
+// EvaluatePolicy decides what the daemon does about the current context.
+// It has no side effects, so it can also explain decisions after the fact.
+func EvaluatePolicy(in PolicyInputs) PolicyDecision {
+	var d PolicyDecision
+	reason := func(format string, args ...any) {
+		d.Reasons = append(d.Reasons, fmt.Sprintf(format, args...))
+	}
+	for _, cred := range in.StaleCredentials {
+		reason("warning: %s", cred)
+	}
+
+	switch {
+	case in.CurrentContext == in.DefaultContext:
+		d.Action = PolicyActionNone
+		reason("already on the default context '%s'", in.DefaultContext)
+		return d
+	case in.NeverSwitchFrom:
+		d.Action = PolicyActionNone
+		reason("context '%s' is in never_switch_from", in.CurrentContext)
+		return d
+	case in.Now.Before(in.ExtendedUntil):
+		d.Action = PolicyActionNone
+		reason("switching suppressed by extend until %s (%s left)",
+			in.ExtendedUntil.Format(time.RFC3339), in.ExtendedUntil.Sub(in.Now).Round(time.Second))
+		return d
+	case in.Now.Before(in.PausedUntil):
+		d.Action = PolicyActionNone
+		reason("context '%s' paused until %s (%s left)",
+			in.CurrentContext, in.PausedUntil.Format(time.RFC3339), in.PausedUntil.Sub(in.Now).Round(time.Second))
+		return d
+	}
+
+	if in.AfterHours {
+		reason("outside work hours, so the after-hours timeout applies")
+	}
+
+	idle := in.Idle()
+	if writeDeadline, ok := in.writeDeadline(); ok && in.Now.Before(writeDeadline) {
+		d.Action = PolicyActionWait
+		d.SwitchAt = writeDeadline
+		reason("inactive for %s, but a command that changed the cluster ran %s ago and write_commands keeps the context for %s",
+			idle.Round(time.Second), in.Now.Sub(in.LastWrite).Round(time.Second), in.WriteTimeout)
+		reason("switch due at %s (in %s)", d.SwitchAt.Format(time.RFC3339), d.SwitchAt.Sub(in.Now).Round(time.Second))
+		if in.GracePeriod > 0 {
+			reason("followed by the %s grace period", in.GracePeriod)
+		}
+		return d
+	} else if in.LastActivity.IsZero() {
+		reason("no kubectl activity recorded")
+	} else if idle < in.Timeout {
+		d.Action = PolicyActionWait
+		d.SwitchAt = in.LastActivity.Add(in.Timeout)
+		reason("inactive for %s, timeout for '%s' is %s", idle.Round(time.Second), in.CurrentContext, in.Timeout)
+		reason("switch due at %s (in %s)", d.SwitchAt.Format(time.RFC3339), d.SwitchAt.Sub(in.Now).Round(time.Second))
+		if in.GracePeriod > 0 {
+			reason("followed by the %s grace period", in.GracePeriod)
+		}
+		return d
+	} else {
+		reason("inactive for %s, exceeding the %s timeout for '%s'", idle.Round(time.Second), in.Timeout, in.CurrentContext)
+	}
+
+	if in.DefaultForbidden {
+		reason("warning: default context '%s' is in never_switch_to, so the switch will be refused", in.DefaultContext)
+	}
+
+	if len(in.ActiveProcesses) > 0 {
+		deferredSince := in.DeferredSince
+		if deferredSince.IsZero() {
+			deferredSince = in.Now
+		}
+		deferUntil := deferredSince.Add(in.MaxDefer)
+
+		if in.MaxDefer <= 0 || in.Now.Before(deferUntil) {
+			d.Action = PolicyActionDefer
+			reason("deferred while Kubernetes tools are running: %s", strings.Join(in.ActiveProcesses, ", "))
+			if in.MaxDefer > 0 {
+				d.SwitchAt = deferUntil
+				reason("for at most %s more (max_defer %s)", deferUntil.Sub(in.Now).Round(time.Second), in.MaxDefer)
+			}
+			return d
+		}
+		reason("Kubernetes tools have deferred the switch for %s, reaching max_defer: %s",
+			in.Now.Sub(deferredSince).Round(time.Second), strings.Join(in.ActiveProcesses, ", "))
+	}
+
+	if in.GracePeriod > 0 {
+		switch {
+		case !in.pendingMatches():
+			d.Action = PolicyActionGrace
+			d.SwitchAt = in.Now.Add(in.GracePeriod)
+			reason("starting the %s grace period (cancel with: kubectx-timeout cancel-switch)", in.GracePeriod)
+			return d
+		case in.Now.Before(in.Pending.At):
+			d.Action = PolicyActionGrace
+			d.SwitchAt = in.Pending.At
+			reason("waiting out the grace period until %s (cancel with: kubectx-timeout cancel-switch)",
+				in.Pending.At.Format(time.RFC3339))
+			return d
+		default:
+			reason("grace period ended at %s", in.Pending.At.Format(time.RFC3339))
+		}
+	}
+
+	d.Action = PolicyActionSwitch
+	reason("switching to the default context '%s'", in.DefaultContext)
+	return d
+}
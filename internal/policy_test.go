@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluatePolicy(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	base := PolicyInputs{
+		Now:            now,
+		CurrentContext: "prod",
+		DefaultContext: "local",
+		LastActivity:   now.Add(-time.Hour),
+		Timeout:        30 * time.Minute,
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(in *PolicyInputs)
+		wantAction string
+		wantReason string
+		wantAt     time.Time
+	}{
+		{
+			name:       "timed out",
+			wantAction: PolicyActionSwitch,
+			wantReason: "exceeding the 30m0s timeout",
+		},
+		{
+			name:       "after hours",
+			modify:     func(in *PolicyInputs) { in.AfterHours = true },
+			wantAction: PolicyActionSwitch,
+			wantReason: "after-hours timeout applies",
+		},
+		{
+			name:       "counting down",
+			modify:     func(in *PolicyInputs) { in.LastActivity = now.Add(-10 * time.Minute) },
+			wantAction: PolicyActionWait,
+			wantReason: "switch due at",
+			wantAt:     now.Add(20 * time.Minute),
+		},
+		{
+			name: "kept alive by a write",
+			modify: func(in *PolicyInputs) {
+				in.LastWrite = now.Add(-40 * time.Minute)
+				in.WriteTimeout = 2 * time.Hour
+			},
+			wantAction: PolicyActionWait,
+			wantReason: "write_commands keeps the context",
+			wantAt:     now.Add(80 * time.Minute),
+		},
+		{
+			name: "write timeout over",
+			modify: func(in *PolicyInputs) {
+				in.LastWrite = now.Add(-3 * time.Hour)
+				in.WriteTimeout = 2 * time.Hour
+			},
+			wantAction: PolicyActionSwitch,
+			wantReason: "exceeding the 30m0s timeout",
+		},
+		{
+			name: "write timeout shorter than the timeout",
+			modify: func(in *PolicyInputs) {
+				in.LastActivity = now.Add(-10 * time.Minute)
+				in.LastWrite = now.Add(-10 * time.Minute)
+				in.WriteTimeout = 15 * time.Minute
+			},
+			wantAction: PolicyActionWait,
+			wantAt:     now.Add(20 * time.Minute),
+		},
+		{
+			name:       "no activity recorded",
+			modify:     func(in *PolicyInputs) { in.LastActivity = time.Time{} },
+			wantAction: PolicyActionSwitch,
+			wantReason: "no kubectl activity recorded",
+		},
+		{
+			name:       "default context",
+			modify:     func(in *PolicyInputs) { in.CurrentContext = "local" },
+			wantAction: PolicyActionNone,
+			wantReason: "already on the default context",
+		},
+		{
+			name:       "never_switch_from",
+			modify:     func(in *PolicyInputs) { in.NeverSwitchFrom = true },
+			wantAction: PolicyActionNone,
+			wantReason: "is in never_switch_from",
+		},
+		{
+			name:       "extended",
+			modify:     func(in *PolicyInputs) { in.ExtendedUntil = now.Add(time.Minute) },
+			wantAction: PolicyActionNone,
+			wantReason: "suppressed by extend",
+		},
+		{
+			name:       "expired extension",
+			modify:     func(in *PolicyInputs) { in.ExtendedUntil = now.Add(-time.Minute) },
+			wantAction: PolicyActionSwitch,
+		},
+		{
+			name:       "paused",
+			modify:     func(in *PolicyInputs) { in.PausedUntil = now.Add(time.Minute) },
+			wantAction: PolicyActionNone,
+			wantReason: "paused until",
+		},
+		{
+			name:       "running tools",
+			modify:     func(in *PolicyInputs) { in.ActiveProcesses = []string{"kubectl logs (PID 42)"} },
+			wantAction: PolicyActionDefer,
+			wantReason: "kubectl logs (PID 42)",
+		},
+		{
+			name: "running tools within max_defer",
+			modify: func(in *PolicyInputs) {
+				in.ActiveProcesses = []string{"kubectl port-forward (PID 42)"}
+				in.DeferredSince = now.Add(-10 * time.Minute)
+				in.MaxDefer = time.Hour
+			},
+			wantAction: PolicyActionDefer,
+			wantReason: "for at most 50m0s more",
+			wantAt:     now.Add(50 * time.Minute),
+		},
+		{
+			name: "running tools past max_defer",
+			modify: func(in *PolicyInputs) {
+				in.ActiveProcesses = []string{"kubectl port-forward (PID 42)"}
+				in.DeferredSince = now.Add(-2 * time.Hour)
+				in.MaxDefer = time.Hour
+			},
+			wantAction: PolicyActionSwitch,
+			wantReason: "reaching max_defer",
+		},
+		{
+			name:       "grace period starts",
+			modify:     func(in *PolicyInputs) { in.GracePeriod = 2 * time.Minute },
+			wantAction: PolicyActionGrace,
+			wantReason: "starting the 2m0s grace period",
+			wantAt:     now.Add(2 * time.Minute),
+		},
+		{
+			name: "grace period running",
+			modify: func(in *PolicyInputs) {
+				in.GracePeriod = 2 * time.Minute
+				in.Pending = PendingSwitch{From: "prod", To: "local", At: now.Add(time.Minute)}
+			},
+			wantAction: PolicyActionGrace,
+			wantReason: "waiting out the grace period",
+			wantAt:     now.Add(time.Minute),
+		},
+		{
+			name: "grace period ended",
+			modify: func(in *PolicyInputs) {
+				in.GracePeriod = 2 * time.Minute
+				in.Pending = PendingSwitch{From: "prod", To: "local", At: now.Add(-time.Second)}
+			},
+			wantAction: PolicyActionSwitch,
+			wantReason: "grace period ended",
+		},
+		{
+			name: "pending switch from another context",
+			modify: func(in *PolicyInputs) {
+				in.GracePeriod = 2 * time.Minute
+				in.Pending = PendingSwitch{From: "staging", To: "local", At: now.Add(-time.Second)}
+			},
+			wantAction: PolicyActionGrace,
+			wantReason: "starting the 2m0s grace period",
+			wantAt:     now.Add(2 * time.Minute),
+		},
+		{
+			name:       "forbidden default",
+			modify:     func(in *PolicyInputs) { in.DefaultForbidden = true },
+			wantAction: PolicyActionSwitch,
+			wantReason: "will be refused",
+		},
+		{
+			name:       "stale credentials",
+			modify:     func(in *PolicyInputs) { in.StaleCredentials = []string{"context prod has a token that expired 3d ago"} },
+			wantAction: PolicyActionSwitch,
+			wantReason: "warning: context prod has a token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := base
+			if tt.modify != nil {
+				tt.modify(&in)
+			}
+
+			decision := EvaluatePolicy(in)
+			if decision.Action != tt.wantAction {
+				t.Errorf("Action = %q, want %q (reasons: %v)", decision.Action, tt.wantAction, decision.Reasons)
+			}
+			if tt.wantReason != "" && !strings.Contains(strings.Join(decision.Reasons, "\n"), tt.wantReason) {
+				t.Errorf("Reasons = %v, want one containing %q", decision.Reasons, tt.wantReason)
+			}
+			if !decision.SwitchAt.Equal(tt.wantAt) {
+				t.Errorf("SwitchAt = %v, want %v", decision.SwitchAt, tt.wantAt)
+			}
+		})
+	}
+}
+
+func TestGatherPolicyInputs(t *testing.T) {
+	now := time.Now()
+	store := &fakeStateStore{state: State{
+		LastActivity:   now.Add(-time.Minute),
+		CurrentContext: "prod",
+		PausedContexts: map[string]time.Time{"prod": now.Add(time.Hour)},
+	}}
+	config := &Config{
+		DefaultContext: "local",
+		Timeout:        TimeoutConfig{Default: 30 * time.Minute, GracePeriod: time.Minute},
+		Safety:         SafetyConfig{NeverSwitchTo: []string{"local"}},
+	}
+
+	in, err := GatherPolicyInputs(config, store, &fakeSwitcher{current: "prod"}, now)
+	if err != nil {
+		t.Fatalf("GatherPolicyInputs() error = %v", err)
+	}
+	if in.CurrentContext != "prod" || in.Timeout != 30*time.Minute || in.GracePeriod != time.Minute {
+		t.Errorf("Unexpected inputs: %+v", in)
+	}
+	if !in.DefaultForbidden {
+		t.Error("Expected the default context to be reported as forbidden")
+	}
+	if !in.PausedUntil.Equal(now.Add(time.Hour)) {
+		t.Errorf("PausedUntil = %v, want %v", in.PausedUntil, now.Add(time.Hour))
+	}
+
+	if _, err := GatherPolicyInputs(config, store, &fakeSwitcher{contextErr: errors.New("no context")}, now); !errors.Is(err, errContextUnavailable) {
+		t.Errorf("GatherPolicyInputs() error = %v, want %v", err, errContextUnavailable)
+	}
+	store.loadErr = errors.New("corrupted")
+	if _, err := GatherPolicyInputs(config, store, &fakeSwitcher{current: "prod"}, now); !errors.Is(err, errStateUnavailable) {
+		t.Errorf("GatherPolicyInputs() error = %v, want %v", err, errStateUnavailable)
+	}
+}
+
+func TestPolicyJSON(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	in := PolicyInputs{
+		Now:            now,
+		CurrentContext: "prod",
+		DefaultContext: "local",
+		LastActivity:   now.Add(-10 * time.Minute),
+		Timeout:        30 * time.Minute,
+	}
+
+	data, err := json.Marshal(struct {
+		Inputs   PolicyInputs   `json:"inputs"`
+		Decision PolicyDecision `json:"decision"`
+	}{in, EvaluatePolicy(in)})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got map[string]map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got["inputs"]["timeout_seconds"] != float64(1800) || got["inputs"]["current_context"] != "prod" {
+		t.Errorf("Unexpected inputs JSON: %s", data)
+	}
+	if _, ok := got["inputs"]["extended_until"]; ok {
+		t.Errorf("Expected unset times to be omitted: %s", data)
+	}
+	if got["decision"]["action"] != PolicyActionWait || got["decision"]["switch_at"] != "2026-10-16T12:20:00Z" {
+		t.Errorf("Unexpected decision JSON: %s", data)
+	}
+}
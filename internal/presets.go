@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeoutPreset is a named pair of timeouts, so a configuration can start
+// from sensible values instead of designing a policy from scratch
+type TimeoutPreset struct {
+	Name string
+
+	// Default replaces timeout.default unless the configuration sets it
+	Default time.Duration
+
+	// Production is the timeout of contexts that look like production or
+	// staging by name and have no contexts or clusters entry of their own
+	Production time.Duration
+}
+
+// TimeoutPresets are the presets a configuration can name with preset:
+var TimeoutPresets = []TimeoutPreset{
+	{Name: "paranoid", Default: 30 * time.Minute, Production: 5 * time.Minute},
+	{Name: "standard", Default: time.Hour, Production: 15 * time.Minute},
+	{Name: "relaxed", Default: 4 * time.Hour, Production: time.Hour},
+}
+
+// LookupPreset returns the preset with the given name
+func LookupPreset(name string) (TimeoutPreset, bool) {
+	for _, preset := range TimeoutPresets {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+	return TimeoutPreset{}, false
+}
+
+// PresetNames returns the names of the presets, for messages
+func PresetNames() string {
+	return strings.Join(presetNameList(), ", ")
+}
+
+// presetNameList returns the names of the presets in order
+func presetNameList() []string {
+	names := make([]string, len(TimeoutPresets))
+	for i, preset := range TimeoutPresets {
+		names[i] = preset.Name
+	}
+	return names
+}
+
+// Describe summarizes a preset, e.g. "standard: 15m production/1h default"
+func (p TimeoutPreset) Describe() string {
+	return fmt.Sprintf("%s: %s production/%s default", p.Name, formatInitTimeout(p.Production), formatInitTimeout(p.Default))
+}
+
+// Apply sets the timeouts a preset supplies on a configuration
+func (p TimeoutPreset) Apply(config *Config) {
+	config.Preset = p.Name
+	config.Timeout.Default = p.Default
+}
+
+// presetTimeout returns the preset's timeout for a context with no contexts
+// or clusters entry: its production timeout if the context looks like
+// production by name
+func (c *Config) presetTimeout(contextName string) (time.Duration, bool) {
+	if c.Preset == "" {
+		return 0, false
+	}
+	preset, ok := LookupPreset(c.Preset)
+	if !ok || ClassifyContext(contextName) != ContextRiskProduction {
+		return 0, false
+	}
+	return preset.Production, true
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigPreset(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `preset: paranoid
+default_context: local
+contexts:
+  staging-eu:
+    timeout: 20m
+`
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	tests := []struct {
+		context string
+		want    time.Duration
+	}{
+		{"team-x", 30 * time.Minute},     // The preset's default
+		{"prod-eu", 5 * time.Minute},     // Looks like production
+		{"staging-eu", 20 * time.Minute}, // Its own entry wins
+	}
+	for _, tt := range tests {
+		if got := config.GetTimeoutForContext(tt.context); got != tt.want {
+			t.Errorf("GetTimeoutForContext(%q) = %v, want %v", tt.context, got, tt.want)
+		}
+	}
+
+	// Settings in the file override the preset's
+	content = "preset: relaxed\ndefault_context: local\ntimeout:\n  default: 2h\n"
+	if err := os.WriteFile(configPath, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	config, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.Timeout.Default != 2*time.Hour || config.GetTimeoutForContext("prod") != time.Hour {
+		t.Errorf("Expected a 2h default and the preset's 1h for production, got %v and %v",
+			config.Timeout.Default, config.GetTimeoutForContext("prod"))
+	}
+
+	config.Preset = "yolo"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "unknown preset") {
+		t.Errorf("Validate() error = %v, want one about the unknown preset", err)
+	}
+}
+
+func TestInitWizardPreset(t *testing.T) {
+	config := DefaultConfig()
+	var out bytes.Buffer
+
+	// Pick the default context, the standard preset, and accept the
+	// production suggestion it makes
+	answers := "1\nstandard\n\n"
+	err := NewInitWizard(strings.NewReader(answers), &out).Run(config, []string{"local", "prod-eu"}, "")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if config.Preset != "standard" || config.Timeout.Default != time.Hour {
+		t.Errorf("Expected the standard preset, got %q with default %v", config.Preset, config.Timeout.Default)
+	}
+	if config.Contexts["prod-eu"].Timeout != 15*time.Minute {
+		t.Errorf("Expected the preset's production timeout suggested, got %+v", config.Contexts)
+	}
+	if !strings.Contains(out.String(), "paranoid: 5m production/30m default") {
+		t.Errorf("output should list the presets:\n%s", out.String())
+	}
+}
@@ -0,0 +1,118 @@
+//go:build !windows
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Signals the daemon handles besides SIGINT and SIGTERM
+var (
+	// reloadSignal reloads the configuration
+	reloadSignal os.Signal = syscall.SIGHUP
+	// dumpStateSignal logs the daemon's state
+	dumpStateSignal os.Signal = syscall.SIGUSR1
+	// checkNowSignal runs a timeout check right away
+	checkNowSignal os.Signal = syscall.SIGUSR2
+)
+
+// daemonSignals are the signals the daemon's main loop handles
+var daemonSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, reloadSignal, dumpStateSignal, checkNowSignal}
+
+// StopProcess asks the process with the given PID to shut down gracefully
+func StopProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		return fmt.Errorf("failed to send SIGTERM: %w", err)
+	}
+	return nil
+}
+
+// ReloadProcess asks the daemon with the given PID to reload its
+// configuration
+func ReloadProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := process.Signal(syscall.SIGHUP); err != nil {
+		return fmt.Errorf("failed to send SIGHUP: %w", err)
+	}
+	return nil
+}
+
+// lockDir takes an exclusive advisory lock on a directory, returning a function
+// that releases it
+func lockDir(dir string) (func(), error) {
+	// #nosec G304 -- dir is the daemon state directory, not user input
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state directory: %w", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to lock state directory: %w", err)
+	}
+
+	// Closing the descriptor releases the lock
+	return func() { _ = f.Close() }, nil
+}
+
+// ProcessExists checks if a process with the given PID exists
+func ProcessExists(pid int) bool {
+	// Send signal 0 to check if process exists
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	// On Unix, FindProcess always succeeds, so we need to send a signal to check
+	err = process.Signal(syscall.Signal(0))
+	return err == nil
+}
+
+// processStartTime returns an opaque token identifying when a process started.
+// Two processes that share a PID at different times will have different tokens.
+func processStartTime(pid int) (string, error) {
+	if runtime.GOOS == "linux" {
+		// Field 22 of /proc/<pid>/stat is the start time in clock ticks since boot.
+		// The command name (field 2) may contain spaces, so parse after its closing paren.
+		data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+		if err != nil {
+			return "", fmt.Errorf("failed to read process stat: %w", err)
+		}
+		stat := string(data)
+		end := strings.LastIndexByte(stat, ')')
+		if end < 0 {
+			return "", fmt.Errorf("malformed process stat")
+		}
+		fields := strings.Fields(stat[end+1:])
+		// fields[0] is field 3 (state), so field 22 is fields[19]
+		if len(fields) < 20 {
+			return "", fmt.Errorf("malformed process stat")
+		}
+		return fields[19], nil
+	}
+
+	// #nosec G204 -- pid is an integer formatted with strconv, not user input
+	output, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get process start time: %w", err)
+	}
+	start := strings.Join(strings.Fields(string(output)), " ")
+	if start == "" {
+		return "", fmt.Errorf("process %d not found", pid)
+	}
+	return start, nil
+}
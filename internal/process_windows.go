@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// Windows delivers Ctrl+C and Ctrl+Break as os.Interrupt and console close,
+// logoff, and shutdown as SIGTERM. It has no equivalent of the other signals
+// the daemon handles on Unix; use the control socket and the status command
+// instead. A nil signal never matches one that was received.
+var (
+	reloadSignal    os.Signal
+	dumpStateSignal os.Signal
+	checkNowSignal  os.Signal
+)
+
+// daemonSignals are the signals the daemon's main loop handles
+var daemonSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// stillActive is the exit code GetExitCodeProcess reports for a process that
+// hasn't exited
+const stillActive = 259
+
+// StopProcess stops the process with the given PID. Windows can't signal
+// another process, so it is terminated.
+func StopProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	if err := process.Kill(); err != nil {
+		return fmt.Errorf("failed to terminate process: %w", err)
+	}
+	return nil
+}
+
+// ReloadProcess asks the daemon to reload its configuration. Windows has no
+// SIGHUP, so the request goes over the control socket instead.
+func ReloadProcess(pid int) error {
+	if _, err := SendControlRequest(GetControlSocketPath(), ControlRequest{Command: ControlReload}); err != nil {
+		return fmt.Errorf("failed to reload daemon (PID %d): %w", pid, err)
+	}
+	return nil
+}
+
+// lockDir takes an exclusive lock on a directory, returning a function that
+// releases it. Windows can't lock a directory handle, so the lock is held on
+// a file inside it.
+func lockDir(dir string) (func(), error) {
+	// #nosec G304 -- dir is the daemon state directory, not user input
+	f, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state directory lock: %w", err)
+	}
+
+	overlapped := new(windows.Overlapped)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, overlapped); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to lock state directory: %w", err)
+	}
+
+	// Closing the handle releases the lock
+	return func() { _ = f.Close() }, nil
+}
+
+// ProcessExists checks if a process with the given PID exists
+func ProcessExists(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid)) // #nosec G115 -- PIDs fit in 32 bits
+	if err != nil {
+		// Another user's process can't be opened, but it does exist
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer func() { _ = windows.CloseHandle(h) }()
+
+	// A handle can outlive the process, so check that it hasn't exited
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// processStartTime returns an opaque token identifying when a process started.
+// Two processes that share a PID at different times will have different tokens.
+func processStartTime(pid int) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid)) // #nosec G115 -- PIDs fit in 32 bits
+	if err != nil {
+		return "", fmt.Errorf("failed to open process %d: %w", pid, err)
+	}
+	defer func() { _ = windows.CloseHandle(h) }()
+
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return "", fmt.Errorf("failed to get process start time: %w", err)
+	}
+	return strconv.FormatInt(creation.Nanoseconds(), 10), nil
+}
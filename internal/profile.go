@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// profileNamePattern matches a valid profile name, which is typed on the
+// command line
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ErrUnknownProfile is returned when a profile isn't in the configuration
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// Profile is a named set of timeouts that can be switched to with the
+// profile command, such as longer production timeouts while on call.
+// Settings it leaves out keep their values from the rest of the
+// configuration.
+type Profile struct {
+	// Description is shown by profile list
+	Description string `yaml:"description,omitempty"`
+
+	// Timeout replaces timeout.default
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// DefaultContext replaces default_context
+	DefaultContext string `yaml:"default_context,omitempty"`
+
+	// Contexts are per-context settings, replacing the contexts entries of
+	// the same name or pattern
+	Contexts map[string]Context `yaml:"contexts,omitempty"`
+}
+
+// ActiveProfile is the profile chosen with the profile command, until Until
+// or until cleared if Until is zero
+type ActiveProfile struct {
+	Name  string
+	Until time.Time
+}
+
+// ActiveAt reports whether the profile applies at now
+func (p ActiveProfile) ActiveAt(now time.Time) bool {
+	return p.Name != "" && (p.Until.IsZero() || now.Before(p.Until))
+}
+
+// ProfileNames returns the configured profile names in alphabetical order
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// WithProfile returns a copy of the configuration with the named profile's
+// settings applied. It returns an error wrapping ErrUnknownProfile if there
+// is no such profile.
+func (c *Config) WithProfile(name string) (*Config, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w '%s' (configured: %s)", ErrUnknownProfile, name, c.profileList())
+	}
+
+	return c.withOverrides(profile.Timeout, profile.DefaultContext, profile.Contexts), nil
+}
+
+// withOverrides returns a copy of the configuration with a timeout,
+// default context, and per-context settings laid over its own. Zero values
+// leave the configuration's settings alone.
+func (c *Config) withOverrides(timeout time.Duration, defaultContext string, contexts map[string]Context) *Config {
+	overridden := *c
+	if timeout > 0 {
+		overridden.Timeout.Default = timeout
+	}
+	if defaultContext != "" {
+		overridden.DefaultContext = defaultContext
+	}
+	if len(contexts) > 0 {
+		overridden.Contexts = maps.Clone(c.Contexts)
+		if overridden.Contexts == nil {
+			overridden.Contexts = make(map[string]Context, len(contexts))
+		}
+		maps.Copy(overridden.Contexts, contexts)
+	}
+	return &overridden
+}
+
+// WithActiveProfile returns the configuration with the profile active at now
+// applied, or the configuration itself if none is
+func (c *Config) WithActiveProfile(profile ActiveProfile, now time.Time) (*Config, error) {
+	if !profile.ActiveAt(now) {
+		return c, nil
+	}
+	return c.WithProfile(profile.Name)
+}
+
+// profileList describes the configured profiles for error messages
+func (c *Config) profileList() string {
+	if len(c.Profiles) == 0 {
+		return "none"
+	}
+	return strings.Join(c.ProfileNames(), ", ")
+}
+
+// profileValidationErrors checks each profile by validating the
+// configuration with it applied, reporting only the problems the profile
+// introduces
+func (c *Config) profileValidationErrors(baseErrs []error) []error {
+	var errs []error
+
+	base := make(map[string]bool, len(baseErrs))
+	for _, err := range baseErrs {
+		base[err.Error()] = true
+	}
+
+	for _, name := range c.ProfileNames() {
+		profile := c.Profiles[name]
+		if !profileNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("invalid profile name '%s': use letters, digits, '.', '_', and '-'", name))
+		}
+		if profile.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("profiles.%s.timeout must not be negative", name))
+		}
+
+		withProfile, err := c.WithProfile(name)
+		if err != nil {
+			continue
+		}
+		withProfile.Profiles = nil
+		for _, err := range withProfile.ValidationErrors() {
+			if !base[err.Error()] {
+				errs = append(errs, fmt.Errorf("profiles.%s: %w", name, err))
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigWithProfile(t *testing.T) {
+	config := DefaultConfig()
+	config.DefaultContext = "local"
+	config.Contexts = map[string]Context{
+		"production": {Timeout: 5 * time.Minute},
+		"staging":    {Timeout: 15 * time.Minute},
+	}
+	config.Profiles = map[string]Profile{
+		"oncall": {
+			Timeout:        2 * time.Hour,
+			DefaultContext: "staging",
+			Contexts:       map[string]Context{"production": {Timeout: time.Hour}},
+		},
+	}
+
+	oncall, err := config.WithProfile("oncall")
+	if err != nil {
+		t.Fatalf("WithProfile() error = %v", err)
+	}
+	tests := []struct {
+		context string
+		want    time.Duration
+	}{
+		{"production", time.Hour},
+		{"staging", 15 * time.Minute},
+		{"dev", 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := oncall.GetTimeoutForContext(tt.context); got != tt.want {
+			t.Errorf("GetTimeoutForContext(%q) with profile = %v, want %v", tt.context, got, tt.want)
+		}
+	}
+	if got := oncall.GetDefaultContextFor("production"); got != "staging" {
+		t.Errorf("GetDefaultContextFor() with profile = %q, want staging", got)
+	}
+
+	// The configuration itself is left alone
+	if got := config.GetTimeoutForContext("production"); got != 5*time.Minute {
+		t.Errorf("GetTimeoutForContext() without profile = %v, want 5m", got)
+	}
+	if config.DefaultContext != "local" {
+		t.Errorf("DefaultContext changed to %q", config.DefaultContext)
+	}
+
+	if _, err := config.WithProfile("weekend"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("WithProfile() of an unknown profile error = %v, want ErrUnknownProfile", err)
+	}
+
+	// Only a profile in use applies
+	now := time.Now()
+	expired := ActiveProfile{Name: "oncall", Until: now.Add(-time.Minute)}
+	if got, err := config.WithActiveProfile(expired, now); err != nil || got != config {
+		t.Errorf("WithActiveProfile() of an expired profile = %v, %v, want the configuration itself", got, err)
+	}
+	active := ActiveProfile{Name: "oncall", Until: now.Add(time.Hour)}
+	if got, err := config.WithActiveProfile(active, now); err != nil || got.Timeout.Default != 2*time.Hour {
+		t.Errorf("WithActiveProfile() = %v, %v, want the oncall profile applied", got, err)
+	}
+}
+
+func TestProfileValidation(t *testing.T) {
+	config := DefaultConfig()
+	config.DefaultContext = "local"
+	config.Safety.NeverSwitchTo = []string{"prod-*"}
+	config.Profiles = map[string]Profile{
+		"normal":   {Timeout: 15 * time.Minute},
+		"on call":  {Timeout: time.Hour},
+		"negative": {Timeout: -time.Minute},
+		"unsafe":   {DefaultContext: "prod-eu"},
+	}
+
+	var messages []string
+	for _, err := range config.ValidationErrors() {
+		messages = append(messages, err.Error())
+	}
+	got := strings.Join(messages, "\n")
+
+	for _, want := range []string{
+		"invalid profile name 'on call'",
+		"profiles.negative.timeout must not be negative",
+		"profiles.unsafe: default_context 'prod-eu' is in never_switch_to list",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("ValidationErrors() = %q, want it to contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "profiles.normal") {
+		t.Errorf("ValidationErrors() = %q, want no errors for the normal profile", got)
+	}
+}
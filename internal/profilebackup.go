@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ProfileBackupSuffix is added to a shell profile's path for its backups.
+// Earlier versions kept a single backup with exactly this suffix; newer
+// ones add a timestamp after it.
+const ProfileBackupSuffix = ".kubectx-timeout.backup"
+
+// DefaultProfileBackups is how many timestamped backups of each shell
+// profile are kept when shell.profile_backups is 0
+const DefaultProfileBackups = 10
+
+// profileBackupTimeFormat is the timestamp in backup file names, which
+// sorts in time order
+const profileBackupTimeFormat = "20060102-150405.000"
+
+// ProfileBackup is one backup of a shell profile
+type ProfileBackup struct {
+	Path string
+	Time time.Time
+
+	// Legacy marks the single backup earlier versions kept, which is never
+	// removed to make room for newer ones
+	Legacy bool
+}
+
+// BackupProfile copies a shell profile to a new timestamped backup beside
+// it and removes the oldest timestamped backups past keep, or past
+// DefaultProfileBackups if keep is 0. It returns the backup's path, or ""
+// if the profile doesn't exist.
+func BackupProfile(profilePath string, keep int) (string, error) {
+	// #nosec G304 -- profilePath is constructed from user home dir and known profile names, not user input
+	content, err := os.ReadFile(profilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read profile for backup: %w", err)
+	}
+
+	var backupPath string
+	for {
+		backupPath = profilePath + ProfileBackupSuffix + "." + time.Now().Format(profileBackupTimeFormat)
+		// #nosec G304 -- backupPath is profilePath plus a fixed suffix and timestamp
+		f, err := os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			// Another backup this millisecond; wait for the next
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create backup: %w", err)
+		}
+		if _, err := f.Write(content); err != nil {
+			_ = f.Close()
+			_ = os.Remove(backupPath) // Don't leave a partial backup behind
+			return "", fmt.Errorf("failed to create backup: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			_ = os.Remove(backupPath)
+			return "", fmt.Errorf("failed to create backup: %w", err)
+		}
+		break
+	}
+
+	if err := pruneProfileBackups(profilePath, keep); err != nil {
+		return backupPath, err
+	}
+	return backupPath, nil
+}
+
+// ListProfileBackups returns the backups of a shell profile, newest first,
+// with the legacy single backup, if there is one, last
+func ListProfileBackups(profilePath string) ([]ProfileBackup, error) {
+	prefix := filepath.Base(profilePath) + ProfileBackupSuffix
+	entries, err := os.ReadDir(filepath.Dir(profilePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profile backups: %w", err)
+	}
+
+	var backups []ProfileBackup
+	var legacy *ProfileBackup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		path := filepath.Join(filepath.Dir(profilePath), name)
+		if name == prefix {
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			legacy = &ProfileBackup{Path: path, Time: info.ModTime(), Legacy: true}
+			continue
+		}
+		stamp, ok := strings.CutPrefix(name, prefix+".")
+		if !ok {
+			continue
+		}
+		t, err := time.ParseInLocation(profileBackupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, ProfileBackup{Path: path, Time: t})
+	}
+
+	slices.SortFunc(backups, func(a, b ProfileBackup) int { return b.Time.Compare(a.Time) })
+	if legacy != nil {
+		backups = append(backups, *legacy)
+	}
+	return backups, nil
+}
+
+// pruneProfileBackups removes the oldest timestamped backups of a shell
+// profile past keep, or past DefaultProfileBackups if keep is 0
+func pruneProfileBackups(profilePath string, keep int) error {
+	if keep <= 0 {
+		keep = DefaultProfileBackups
+	}
+	backups, err := ListProfileBackups(profilePath)
+	if err != nil {
+		return err
+	}
+	backups = slices.DeleteFunc(backups, func(b ProfileBackup) bool { return b.Legacy })
+
+	var errs []error
+	for _, backup := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(backup.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to remove old backup: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RestoreProfile replaces a shell profile with one of its backups, first
+// backing up the profile as it is, so the restore can be undone. keep is as
+// for BackupProfile. It returns the path of that backup, or "" if the
+// profile didn't exist.
+func RestoreProfile(profilePath, backupPath string, keep int) (string, error) {
+	// #nosec G304 -- backupPath is one of the profile's backups
+	content, err := os.ReadFile(backupPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	current, err := BackupProfile(profilePath, keep)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(profilePath, content, 0600); err != nil {
+		return current, fmt.Errorf("failed to write profile: %w", err)
+	}
+	return current, nil
+}
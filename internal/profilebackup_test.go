@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileBackupsKeepOriginal(t *testing.T) {
+	profilePath := filepath.Join(t.TempDir(), ".zshrc")
+	original := "# My shell config\nexport FOO=bar\n"
+	if err := os.WriteFile(profilePath, []byte(original), 0600); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+
+	// Install, uninstall, and install again: the original survives the
+	// later backups
+	code := IntegrationStartMarker + "\nkubectl() { :; }\n" + IntegrationEndMarker
+	if _, err := InstallIntegration(profilePath, code, 0); err != nil {
+		t.Fatalf("InstallIntegration() error = %v", err)
+	}
+	if _, err := UninstallIntegration(profilePath, 0); err != nil {
+		t.Fatalf("UninstallIntegration() error = %v", err)
+	}
+	if _, err := InstallIntegration(profilePath, code, 0); err != nil {
+		t.Fatalf("InstallIntegration() again error = %v", err)
+	}
+
+	backups, err := ListProfileBackups(profilePath)
+	if err != nil {
+		t.Fatalf("ListProfileBackups() error = %v", err)
+	}
+	if len(backups) != 3 {
+		t.Fatalf("ListProfileBackups() = %+v, want 3 backups", backups)
+	}
+	oldest := backups[len(backups)-1]
+	if content, _ := os.ReadFile(oldest.Path); string(content) != original {
+		t.Errorf("Oldest backup = %q, want the original profile", content)
+	}
+
+	// Restoring it backs up the profile as it is first
+	current, err := RestoreProfile(profilePath, oldest.Path, 0)
+	if err != nil {
+		t.Fatalf("RestoreProfile() error = %v", err)
+	}
+	if content, _ := os.ReadFile(profilePath); string(content) != original {
+		t.Errorf("Profile after restore = %q, want the original", content)
+	}
+	if installed, _ := IsIntegrationInstalled(current); !installed {
+		t.Errorf("Expected the backup made by the restore to hold the integration")
+	}
+}
+
+func TestProfileBackupRetention(t *testing.T) {
+	profilePath := filepath.Join(t.TempDir(), ".bashrc")
+	if err := os.WriteFile(profilePath, []byte("# bashrc\n"), 0600); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	// The single backup earlier versions kept is never pruned
+	legacy := profilePath + ProfileBackupSuffix
+	if err := os.WriteFile(legacy, []byte("# original\n"), 0600); err != nil {
+		t.Fatalf("Failed to create legacy backup: %v", err)
+	}
+
+	var made []string
+	for i := 0; i < 4; i++ {
+		backup, err := BackupProfile(profilePath, 2)
+		if err != nil {
+			t.Fatalf("BackupProfile() error = %v", err)
+		}
+		made = append(made, backup)
+	}
+
+	backups, err := ListProfileBackups(profilePath)
+	if err != nil {
+		t.Fatalf("ListProfileBackups() error = %v", err)
+	}
+	if len(backups) != 3 {
+		t.Fatalf("ListProfileBackups() = %+v, want the 2 newest and the legacy backup", backups)
+	}
+	if backups[0].Path != made[3] || backups[1].Path != made[2] {
+		t.Errorf("Expected the newest backups kept newest first, got %+v", backups)
+	}
+	if !backups[2].Legacy || backups[2].Path != legacy {
+		t.Errorf("Expected the legacy backup listed last, got %+v", backups[2])
+	}
+	if _, err := os.Stat(made[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest backup removed, got %v", err)
+	}
+
+	// A profile that doesn't exist has nothing to back up
+	if backup, err := BackupProfile(filepath.Join(t.TempDir(), ".missingrc"), 0); err != nil || backup != "" {
+		t.Errorf("BackupProfile() of a missing profile = %q, %v, want none", backup, err)
+	}
+}
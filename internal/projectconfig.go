@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFileName is the project configuration a repository can keep
+// at its root, applied to commands run anywhere inside it
+const ProjectConfigFileName = ".kubectx-timeout.yaml"
+
+// ProjectConfigEnv names a project configuration to use instead of looking
+// for one above the working directory, for tools such as direnv to export
+const ProjectConfigEnv = "KUBECTX_TIMEOUT_PROJECT_CONFIG"
+
+// ProjectConfig is a repository's timeouts and default context. It can only
+// make timeouts stricter: a timeout longer than the user's own is ignored,
+// so checking out a repository never keeps a context around for longer.
+// Likewise, a default context the user's own configuration doesn't switch
+// to is ignored, so a repository can't send anyone to a context of its
+// choosing.
+type ProjectConfig struct {
+	// Timeout applies to every context
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// DefaultContext is the context to switch to, instead of the user's. It
+	// must be one the user's configuration already switches to.
+	DefaultContext string `yaml:"default_context,omitempty"`
+
+	// Contexts are per-context settings, keyed by name or pattern as in the
+	// main configuration
+	Contexts map[string]ProjectContext `yaml:"contexts,omitempty"`
+}
+
+// ProjectContext is a project's settings for one context
+type ProjectContext struct {
+	Timeout        time.Duration `yaml:"timeout,omitempty"`
+	DefaultContext string        `yaml:"default_context,omitempty"`
+}
+
+// FindProjectConfig returns the project configuration for commands run in
+// dir: the one ProjectConfigEnv names, or else the nearest
+// .kubectx-timeout.yaml in dir or a directory above it. It returns "" if
+// there is none.
+func FindProjectConfig(dir string) string {
+	if path := os.Getenv(ProjectConfigEnv); path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+		return path
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectConfigFileName)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig reads and validates the project configuration at path.
+// Unknown keys are rejected, since settings that belong in the main
+// configuration have no effect here.
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	// #nosec G304 -- path is a project configuration found by FindProjectConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project config: %w", err)
+	}
+
+	var project ProjectConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&project); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse project config %s: %w", path, err)
+	}
+
+	if errs := project.validationErrors(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid project config %s: %w", path, errors.Join(errs...))
+	}
+	return &project, nil
+}
+
+// validationErrors returns every problem with the project configuration
+func (p *ProjectConfig) validationErrors() []error {
+	var errs []error
+
+	if p.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout must not be negative"))
+	}
+	for _, name := range slices.Sorted(maps.Keys(p.Contexts)) {
+		if err := ValidateContextPattern(name); err != nil {
+			errs = append(errs, fmt.Errorf("contexts: %w", err))
+		}
+		if p.Contexts[name].Timeout < 0 {
+			errs = append(errs, fmt.Errorf("contexts.%s.timeout must not be negative", name))
+		}
+	}
+
+	return errs
+}
+
+// contextSettings returns the project's settings for a context: an exact
+// entry, or else the first pattern in alphabetical order that matches it
+func (p *ProjectConfig) contextSettings(contextName string) (ProjectContext, bool) {
+	if ctx, ok := p.Contexts[contextName]; ok {
+		return ctx, true
+	}
+	for _, pattern := range slices.Sorted(maps.Keys(p.Contexts)) {
+		if IsContextPattern(pattern) && MatchContextPattern(pattern, contextName) {
+			return p.Contexts[pattern], true
+		}
+	}
+	return ProjectContext{}, false
+}
+
+// timeoutFor returns the project's timeout for a context, if it sets one
+func (p *ProjectConfig) timeoutFor(contextName string) (time.Duration, bool) {
+	if p == nil {
+		return 0, false
+	}
+	if ctx, ok := p.contextSettings(contextName); ok && ctx.Timeout > 0 {
+		return ctx.Timeout, true
+	}
+	return p.Timeout, p.Timeout > 0
+}
+
+// defaultContextFor returns the project's default context for a context, if
+// it sets one
+func (p *ProjectConfig) defaultContextFor(contextName string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	if ctx, ok := p.contextSettings(contextName); ok && ctx.DefaultContext != "" {
+		return ctx.DefaultContext, true
+	}
+	return p.DefaultContext, p.DefaultContext != ""
+}
+
+// untrustedDefaultContexts returns the project's default contexts that
+// aren't among the switch targets of config, in alphabetical order
+func (p *ProjectConfig) untrustedDefaultContexts(config *Config) []string {
+	if p == nil {
+		return nil
+	}
+	untrusted := map[string]bool{}
+	if p.DefaultContext != "" && !config.isSwitchTarget(p.DefaultContext) {
+		untrusted[p.DefaultContext] = true
+	}
+	for _, ctx := range p.Contexts {
+		if ctx.DefaultContext != "" && !config.isSwitchTarget(ctx.DefaultContext) {
+			untrusted[ctx.DefaultContext] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(untrusted))
+}
+
+// isSwitchTarget reports whether the user's own configuration switches to
+// the context: it is the default_context or a per-context default_context
+func (c *Config) isSwitchTarget(contextName string) bool {
+	if contextName == c.DefaultContext {
+		return true
+	}
+	for _, ctx := range c.Contexts {
+		if ctx.DefaultContext == contextName {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredProjectDefaultContexts returns the default contexts of the project
+// applied by WithProject that are ignored, because the user's own
+// configuration doesn't switch to them
+func (c *Config) ignoredProjectDefaultContexts() []string {
+	return c.project.untrustedDefaultContexts(c)
+}
+
+// WithProject returns a copy of the configuration with a project's
+// settings applied, or the configuration itself if project is nil
+func (c *Config) WithProject(project *ProjectConfig) *Config {
+	if project == nil {
+		return c
+	}
+	withProject := *c
+	withProject.project = project
+	return &withProject
+}
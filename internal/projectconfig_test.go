@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindProjectConfig(t *testing.T) {
+	t.Setenv(ProjectConfigEnv, "")
+	repo := t.TempDir()
+	nested := filepath.Join(repo, "deploy", "prod")
+	if err := os.MkdirAll(nested, 0700); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+
+	if got := FindProjectConfig(nested); got != "" {
+		t.Errorf("FindProjectConfig() without a project config = %q, want \"\"", got)
+	}
+
+	path := filepath.Join(repo, ProjectConfigFileName)
+	if err := os.WriteFile(path, []byte("timeout: 5m\n"), 0600); err != nil {
+		t.Fatalf("Failed to write project config: %v", err)
+	}
+	for _, dir := range []string{repo, nested} {
+		if got := FindProjectConfig(dir); got != path {
+			t.Errorf("FindProjectConfig(%q) = %q, want %q", dir, got, path)
+		}
+	}
+
+	// An exported path wins over looking for one
+	exported := filepath.Join(t.TempDir(), "team.yaml")
+	t.Setenv(ProjectConfigEnv, exported)
+	if got := FindProjectConfig(nested); got != exported {
+		t.Errorf("FindProjectConfig() with %s set = %q, want %q", ProjectConfigEnv, got, exported)
+	}
+}
+
+func TestLoadProjectConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `timeout: 15m
+default_context: sandbox
+contexts:
+  prod-*:
+    timeout: 5m
+`,
+		},
+		{name: "empty", content: ""},
+		{name: "unknown key", content: "daemon:\n  enabled: false\n", wantErr: "field daemon not found"},
+		{name: "negative timeout", content: "timeout: -5m\n", wantErr: "timeout must not be negative"},
+		{name: "invalid pattern", content: "contexts:\n  /prod-(/:\n    timeout: 5m\n", wantErr: "contexts:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ProjectConfigFileName)
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("Failed to write project config: %v", err)
+			}
+
+			_, err := LoadProjectConfig(path)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("LoadProjectConfig() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("LoadProjectConfig() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigWithProject(t *testing.T) {
+	config := DefaultConfig()
+	config.Timeout.Default = 30 * time.Minute
+	config.DefaultContext = "local"
+	config.Contexts = map[string]Context{
+		"prod-eu": {Timeout: 10 * time.Minute},
+		// The project can only pick contexts the user switches to anyway
+		"dev-us":     {DefaultContext: "sandbox"},
+		"staging-eu": {DefaultContext: "staging-readonly"},
+	}
+
+	project := &ProjectConfig{
+		Timeout:        time.Hour,
+		DefaultContext: "sandbox",
+		Contexts: map[string]ProjectContext{
+			"prod-*":  {Timeout: 5 * time.Minute},
+			"staging": {DefaultContext: "staging-readonly"},
+		},
+	}
+	withProject := config.WithProject(project)
+	now := time.Now()
+
+	tests := []struct {
+		context            string
+		wantTimeout        time.Duration
+		wantDefaultContext string
+	}{
+		// The project's stricter timeout applies
+		{"prod-eu", 5 * time.Minute, "sandbox"},
+		// A project timeout longer than the user's is ignored
+		{"dev", 30 * time.Minute, "sandbox"},
+		{"staging", 30 * time.Minute, "staging-readonly"},
+	}
+	for _, tt := range tests {
+		if got := withProject.GetTimeoutForContextAt(tt.context, now); got != tt.wantTimeout {
+			t.Errorf("GetTimeoutForContextAt(%q) = %v, want %v", tt.context, got, tt.wantTimeout)
+		}
+		if got := withProject.GetDefaultContextFor(tt.context); got != tt.wantDefaultContext {
+			t.Errorf("GetDefaultContextFor(%q) = %q, want %q", tt.context, got, tt.wantDefaultContext)
+		}
+	}
+
+	// The configuration itself is left alone
+	if got := config.GetTimeoutForContextAt("prod-eu", now); got != 10*time.Minute {
+		t.Errorf("Original GetTimeoutForContextAt(prod-eu) = %v, want 10m", got)
+	}
+	if got := config.GetDefaultContextFor("prod-eu"); got != "local" {
+		t.Errorf("Original GetDefaultContextFor(prod-eu) = %q, want local", got)
+	}
+	if config.WithProject(nil) != config {
+		t.Error("WithProject(nil) should return the configuration itself")
+	}
+}
+
+func TestConfigWithProjectIgnoresOtherDefaultContexts(t *testing.T) {
+	config := DefaultConfig()
+	config.DefaultContext = "local"
+	config.Contexts = map[string]Context{
+		"prod-*": {DefaultContext: "prod-readonly"},
+	}
+
+	project := &ProjectConfig{
+		DefaultContext: "attacker-prod",
+		Contexts: map[string]ProjectContext{
+			"staging":  {DefaultContext: "prod-readonly"},
+			"sandbox":  {DefaultContext: "local"},
+			"prod-eu":  {DefaultContext: "attacker-admin"},
+			"prod-us":  {Timeout: 5 * time.Minute},
+			"prod-dev": {DefaultContext: "attacker-admin"},
+		},
+	}
+	withProject := config.WithProject(project)
+
+	tests := []struct {
+		context string
+		want    string
+	}{
+		// The user's own switch targets are allowed
+		{"staging", "prod-readonly"},
+		{"sandbox", "local"},
+		// Any other context falls back to the user's choice
+		{"dev", "local"},
+		{"prod-eu", "prod-readonly"},
+		{"prod-us", "prod-readonly"},
+	}
+	for _, tt := range tests {
+		if got := withProject.GetDefaultContextFor(tt.context); got != tt.want {
+			t.Errorf("GetDefaultContextFor(%q) = %q, want %q", tt.context, got, tt.want)
+		}
+	}
+
+	want := []string{"attacker-admin", "attacker-prod"}
+	if got := withProject.ignoredProjectDefaultContexts(); !slices.Equal(got, want) {
+		t.Errorf("ignoredProjectDefaultContexts() = %v, want %v", got, want)
+	}
+	if got := config.ignoredProjectDefaultContexts(); len(got) != 0 {
+		t.Errorf("ignoredProjectDefaultContexts() without a project = %v, want none", got)
+	}
+}
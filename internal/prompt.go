@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Prompt levels, which pick the segment's color
+const (
+	PromptLevelOK       = "ok"
+	PromptLevelWarn     = "warn"
+	PromptLevelCritical = "critical"
+)
+
+// Prompt color modes
+const (
+	// PromptColorNever prints plain text, for prompts that color the segment
+	// themselves (such as a starship custom module's style)
+	PromptColorNever = "never"
+	// PromptColorANSI wraps the segment in raw ANSI escapes
+	PromptColorANSI = "ansi"
+	// PromptColorBash marks the escapes with the \001 and \002 bytes
+	// readline uses to measure the prompt. Bash's own \[ \] would print
+	// literally, since PS1 decodes them before running $(...).
+	PromptColorBash = "bash"
+	// PromptColorZsh uses zsh's %F{color} prompt escapes
+	PromptColorZsh = "zsh"
+)
+
+// promptStaleAfter is how old a status summary may be before the daemon is
+// assumed dead and the prompt shows nothing
+const promptStaleAfter = 30 * time.Second
+
+// PromptOptions controls the prompt segment. Below WarnAt remaining the
+// segment is at the warn level, and below CriticalAt the critical level.
+type PromptOptions struct {
+	WarnAt     time.Duration
+	CriticalAt time.Duration
+	Color      string
+}
+
+// promptColors are the color names for each level, as ANSI SGR codes and
+// zsh color names
+var promptColors = map[string]struct{ ansi, zsh string }{
+	PromptLevelOK:       {"32", "green"},
+	PromptLevelWarn:     {"33", "yellow"},
+	PromptLevelCritical: {"31", "red"},
+}
+
+// ValidPromptColor reports whether mode is a supported color mode
+func ValidPromptColor(mode string) bool {
+	switch mode {
+	case PromptColorNever, PromptColorANSI, PromptColorBash, PromptColorZsh:
+		return true
+	}
+	return false
+}
+
+// PromptSegment describes the daemon's state for a shell prompt, such as
+// "⏱ prod 12m", along with its level. It returns an empty text when there
+// is nothing worth showing: the default or an exempt context is active, or
+// the daemon isn't running. A redacted summary shows no context name.
+func PromptSegment(summary *StatusSummary, now time.Time, opts PromptOptions) (string, string) {
+	if !summaryShowsContext(summary, now) {
+		return "", ""
+	}
+
+	switch summary.State {
+	case SummaryStateActive, SummaryStatePending, SummaryStateDeferred:
+		if summary.Deadline == nil {
+			return "", ""
+		}
+		remaining := summary.Deadline.Sub(now)
+		level := PromptLevelOK
+		if remaining <= opts.CriticalAt || summary.State != SummaryStateActive {
+			level = PromptLevelCritical
+		} else if remaining <= opts.WarnAt {
+			level = PromptLevelWarn
+		}
+
+		text := joinPromptFields("⏱", summary.ContextLabel(), FormatPromptDuration(remaining))
+		if summary.State == SummaryStateDeferred {
+			text = joinPromptFields("⏱", summary.ContextLabel(), "deferred")
+		}
+		return text, level
+
+	case SummaryStateExtended, SummaryStatePaused:
+		until := summary.ExtendedUntil
+		if summary.State == SummaryStatePaused {
+			until = summary.PausedUntil
+		}
+		if until == nil {
+			return joinPromptFields("⏸", summary.ContextLabel()), PromptLevelOK
+		}
+		return joinPromptFields("⏸", summary.ContextLabel(), FormatPromptDuration(until.Sub(now))), PromptLevelOK
+
+	case SummaryStateDegraded:
+		return joinPromptFields("⚠", summary.ContextLabel()), PromptLevelWarn
+	}
+
+	return "", ""
+}
+
+// RemainingNotice returns a note of the time left, such as "[prod: 7m left]",
+// once at most below remains before the context is switched. It returns an
+// empty string otherwise, and whenever PromptSegment would show nothing.
+func RemainingNotice(summary *StatusSummary, now time.Time, below time.Duration) string {
+	if !summaryShowsContext(summary, now) {
+		return ""
+	}
+	if summary.State != SummaryStateActive && summary.State != SummaryStatePending {
+		return ""
+	}
+	if summary.Deadline == nil {
+		return ""
+	}
+
+	remaining := summary.Deadline.Sub(now)
+	if remaining > below {
+		return ""
+	}
+	message := FormatPromptDuration(remaining) + " left"
+	if remaining <= 0 {
+		message = "switching now"
+	}
+	if label := summary.ContextLabel(); label != "" {
+		message = label + ": " + message
+	}
+	return "[" + message + "]"
+}
+
+// summaryShowsContext reports whether a summary is recent and has a context
+// active, named or redacted
+func summaryShowsContext(summary *StatusSummary, now time.Time) bool {
+	if summary == nil || now.Sub(summary.UpdatedAt) > promptStaleAfter {
+		return false
+	}
+	return summary.Context != "" || summary.Redacted
+}
+
+// joinPromptFields joins the non-empty fields of a prompt segment with
+// spaces, so a redacted summary's missing label leaves no gap
+func joinPromptFields(fields ...string) string {
+	return strings.Join(slices.DeleteFunc(fields, func(field string) bool { return field == "" }), " ")
+}
+
+// FormatPromptDuration formats a remaining time compactly: 1h5m, 12m, or 45s
+func FormatPromptDuration(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if minutes == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, minutes)
+}
+
+// ColorPrompt colors a prompt segment for its level in the given mode
+func ColorPrompt(text, level, mode string) string {
+	colors, ok := promptColors[level]
+	if text == "" || !ok {
+		return text
+	}
+
+	switch mode {
+	case PromptColorANSI:
+		return "\033[" + colors.ansi + "m" + text + "\033[0m"
+	case PromptColorBash:
+		return "\001\033[" + colors.ansi + "m\002" + text + "\001\033[0m\002"
+	case PromptColorZsh:
+		// Context names may contain %, which zsh would expand
+		return "%F{" + colors.zsh + "}" + strings.ReplaceAll(text, "%", "%%") + "%f"
+	}
+	return text
+}
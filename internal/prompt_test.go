@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPromptSegment(t *testing.T) {
+	now := time.Date(2026, 10, 16, 14, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	opts := PromptOptions{WarnAt: 10 * time.Minute, CriticalAt: 2 * time.Minute}
+
+	tests := []struct {
+		name      string
+		summary   StatusSummary
+		wantText  string
+		wantLevel string
+	}{
+		{
+			name:      "counting down",
+			summary:   StatusSummary{State: SummaryStateActive, Context: "prod", Deadline: at(12*time.Minute + 30*time.Second)},
+			wantText:  "⏱ prod 12m",
+			wantLevel: PromptLevelOK,
+		},
+		{
+			name:      "below warn threshold",
+			summary:   StatusSummary{State: SummaryStateActive, Context: "prod", Deadline: at(5 * time.Minute)},
+			wantText:  "⏱ prod 5m",
+			wantLevel: PromptLevelWarn,
+		},
+		{
+			name:      "below critical threshold",
+			summary:   StatusSummary{State: SummaryStateActive, Context: "prod", Deadline: at(45 * time.Second)},
+			wantText:  "⏱ prod 45s",
+			wantLevel: PromptLevelCritical,
+		},
+		{
+			name:      "pending switch",
+			summary:   StatusSummary{State: SummaryStatePending, Context: "prod", Deadline: at(20 * time.Second)},
+			wantText:  "⏱ prod 20s",
+			wantLevel: PromptLevelCritical,
+		},
+		{
+			name:      "deferred by running tools",
+			summary:   StatusSummary{State: SummaryStateDeferred, Context: "prod", Deadline: at(-time.Minute)},
+			wantText:  "⏱ prod deferred",
+			wantLevel: PromptLevelCritical,
+		},
+		{
+			name:      "paused",
+			summary:   StatusSummary{State: SummaryStatePaused, Context: "prod", PausedUntil: at(90 * time.Minute)},
+			wantText:  "⏸ prod 1h30m",
+			wantLevel: PromptLevelOK,
+		},
+		{
+			name:      "degraded",
+			summary:   StatusSummary{State: SummaryStateDegraded, Context: "prod"},
+			wantText:  "⚠ prod",
+			wantLevel: PromptLevelWarn,
+		},
+		{
+			name:      "redacted",
+			summary:   StatusSummary{State: SummaryStateActive, Redacted: true, Deadline: at(12 * time.Minute)},
+			wantText:  "⏱ 12m",
+			wantLevel: PromptLevelOK,
+		},
+		{
+			name:    "redacted default context",
+			summary: StatusSummary{State: SummaryStateDefault, Redacted: true},
+		},
+		{
+			name:    "default context",
+			summary: StatusSummary{State: SummaryStateDefault, Context: "local"},
+		},
+		{
+			name:    "stopped daemon",
+			summary: StatusSummary{State: SummaryStateStopped, Context: "prod"},
+		},
+		{
+			name:    "stale summary",
+			summary: StatusSummary{State: SummaryStateActive, Context: "prod", Deadline: at(time.Minute), UpdatedAt: now.Add(-time.Minute)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.summary.UpdatedAt.IsZero() {
+				tt.summary.UpdatedAt = now.Add(-2 * time.Second)
+			}
+			text, level := PromptSegment(&tt.summary, now, opts)
+			if text != tt.wantText || level != tt.wantLevel {
+				t.Errorf("PromptSegment() = %q, %q, want %q, %q", text, level, tt.wantText, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestRemainingNotice(t *testing.T) {
+	now := time.Date(2026, 10, 16, 14, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name    string
+		summary StatusSummary
+		want    string
+	}{
+		{
+			name:    "plenty left",
+			summary: StatusSummary{State: SummaryStateActive, Context: "prod", Deadline: at(25 * time.Minute)},
+		},
+		{
+			name:    "below threshold",
+			summary: StatusSummary{State: SummaryStateActive, Context: "prod", Deadline: at(7*time.Minute + 10*time.Second)},
+			want:    "[prod: 7m left]",
+		},
+		{
+			name:    "pending switch",
+			summary: StatusSummary{State: SummaryStatePending, Context: "prod", Deadline: at(20 * time.Second)},
+			want:    "[prod: 20s left]",
+		},
+		{
+			name:    "aliased context",
+			summary: StatusSummary{State: SummaryStateActive, Context: "gke_acme_us-central1_prod", ContextAlias: "p", Deadline: at(3 * time.Minute)},
+			want:    "[p: 3m left]",
+		},
+		{
+			name:    "deadline passed",
+			summary: StatusSummary{State: SummaryStatePending, Context: "prod", Deadline: at(-time.Second)},
+			want:    "[prod: switching now]",
+		},
+		{
+			name:    "redacted",
+			summary: StatusSummary{State: SummaryStateActive, Redacted: true, Deadline: at(7 * time.Minute)},
+			want:    "[7m left]",
+		},
+		{
+			name:    "deferred by running tools",
+			summary: StatusSummary{State: SummaryStateDeferred, Context: "prod", Deadline: at(-time.Minute)},
+		},
+		{
+			name:    "paused",
+			summary: StatusSummary{State: SummaryStatePaused, Context: "prod", PausedUntil: at(time.Minute)},
+		},
+		{
+			name:    "stale summary",
+			summary: StatusSummary{State: SummaryStateActive, Context: "prod", Deadline: at(time.Minute), UpdatedAt: now.Add(-time.Minute)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.summary.UpdatedAt.IsZero() {
+				tt.summary.UpdatedAt = now.Add(-2 * time.Second)
+			}
+			if got := RemainingNotice(&tt.summary, now, 10*time.Minute); got != tt.want {
+				t.Errorf("RemainingNotice() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPromptDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		-time.Second:                    "now",
+		59 * time.Second:                "59s",
+		12*time.Minute + 59*time.Second: "12m",
+		2 * time.Hour:                   "2h",
+		2*time.Hour + 5*time.Minute:     "2h5m",
+	}
+	for d, want := range tests {
+		if got := FormatPromptDuration(d); got != want {
+			t.Errorf("FormatPromptDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestColorPrompt(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{PromptColorNever, "⏱ 100% 5m"},
+		{PromptColorANSI, "\033[33m⏱ 100% 5m\033[0m"},
+		{PromptColorBash, "\001\033[33m\002⏱ 100% 5m\001\033[0m\002"},
+		{PromptColorZsh, "%F{yellow}⏱ 100%% 5m%f"},
+	}
+	for _, tt := range tests {
+		if got := ColorPrompt("⏱ 100% 5m", PromptLevelWarn, tt.mode); got != tt.want {
+			t.Errorf("ColorPrompt(%s) = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+	if got := ColorPrompt("", PromptLevelWarn, PromptColorANSI); got != "" {
+		t.Errorf("ColorPrompt() of an empty segment = %q, want empty", got)
+	}
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Why notifications are held back
+const (
+	QuietReasonHours = "quiet hours"
+	QuietReasonFocus = "Focus"
+)
+
+// allWeekdays are the days quiet hours apply on when they leave them out
+var allWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// QuietHoursConfig holds the times desktop and terminal notifications are
+// held back and only logged. Switches still happen, and webhooks and Slack
+// are still sent.
+type QuietHoursConfig struct {
+	// Hours is a HH:MM-HH:MM range. A range that ends before it starts runs
+	// past midnight. Quiet hours are off if unset.
+	Hours string `yaml:"hours,omitempty"`
+
+	// Days are three-letter day names (mon, tue, ...), every day if unset.
+	// An overnight range belongs to the day it starts on.
+	Days []string `yaml:"days,omitempty"`
+
+	// Timezone is an IANA name such as Europe/Berlin, the local time zone
+	// if unset
+	Timezone string `yaml:"timezone,omitempty"`
+}
+
+// Enabled reports whether quiet hours are configured
+func (q QuietHoursConfig) Enabled() bool {
+	return q.Hours != ""
+}
+
+// Active reports whether t falls within quiet hours. Malformed settings are
+// rejected by validation, and count as never quiet here.
+func (q QuietHoursConfig) Active(t time.Time) bool {
+	if !q.Enabled() {
+		return false
+	}
+	if _, _, err := parseWorkHours(q.Hours); err != nil {
+		return false
+	}
+
+	days := q.Days
+	if len(days) == 0 {
+		days = allWeekdays
+	}
+	// The same window logic as work hours
+	return ScheduleConfig{WorkDays: days, WorkHours: q.Hours, Timezone: q.Timezone}.InWorkHours(t)
+}
+
+// validationErrors returns every problem with the quiet hours settings
+func (q QuietHoursConfig) validationErrors() []error {
+	var errs []error
+
+	if q.Hours != "" {
+		if _, _, err := parseWorkHours(q.Hours); err != nil {
+			errs = append(errs, fmt.Errorf("invalid notifications.quiet_hours.hours: %w", err))
+		}
+	} else if len(q.Days) > 0 || q.Timezone != "" {
+		errs = append(errs, fmt.Errorf("notifications.quiet_hours needs hours"))
+	}
+	for _, name := range q.Days {
+		if _, ok := weekdayNames[strings.ToLower(name)]; !ok {
+			errs = append(errs, fmt.Errorf("notifications.quiet_hours.days: unknown day '%s' (use mon, tue, wed, thu, fri, sat, sun)", name))
+		}
+	}
+	if q.Timezone != "" {
+		if _, err := time.LoadLocation(q.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("invalid notifications.quiet_hours.timezone: %w", err))
+		}
+	}
+
+	return errs
+}
+
+// parseFocusAssertions reports whether the macOS Do Not Disturb assertions
+// database (~/Library/DoNotDisturb/DB/Assertions.json) records a Focus
+// turned on by the user. Each record is one Focus that is on.
+func parseFocusAssertions(data []byte) (bool, error) {
+	var assertions struct {
+		Data []struct {
+			StoreAssertionRecords []json.RawMessage `json:"storeAssertionRecords"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &assertions); err != nil {
+		return false, fmt.Errorf("failed to parse Focus assertions: %w", err)
+	}
+	for _, entry := range assertions.Data {
+		if len(entry.StoreAssertionRecords) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursActive(t *testing.T) {
+	// 2024-01-01 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name  string
+		quiet QuietHoursConfig
+		t     time.Time
+		want  bool
+	}{
+		{"unset", QuietHoursConfig{}, at(1, 23, 0), false},
+		{"overnight, evening", QuietHoursConfig{Hours: "22:00-07:00"}, at(1, 23, 0), true},
+		{"overnight, early hours", QuietHoursConfig{Hours: "22:00-07:00"}, at(2, 6, 59), true},
+		{"overnight, daytime", QuietHoursConfig{Hours: "22:00-07:00"}, at(2, 7, 0), false},
+		{"every day by default", QuietHoursConfig{Hours: "22:00-07:00"}, at(6, 23, 0), true},
+		{"days, on", QuietHoursConfig{Hours: "22:00-07:00", Days: []string{"fri", "sat"}}, at(6, 23, 0), true},
+		{"days, overnight from an included day", QuietHoursConfig{Hours: "22:00-07:00", Days: []string{"sat"}}, at(7, 6, 0), true},
+		{"days, off", QuietHoursConfig{Hours: "22:00-07:00", Days: []string{"fri", "sat"}}, at(1, 23, 0), false},
+		{"malformed", QuietHoursConfig{Hours: "late"}, at(1, 23, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.quiet.Active(tt.t); got != tt.want {
+				t.Errorf("Active(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuietHoursValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		quiet    QuietHoursConfig
+		wantErrs int
+	}{
+		{"unset", QuietHoursConfig{}, 0},
+		{"valid", QuietHoursConfig{Hours: "22:00-07:00", Days: []string{"Mon"}, Timezone: "UTC"}, 0},
+		{"bad hours", QuietHoursConfig{Hours: "22:00"}, 1},
+		{"days without hours", QuietHoursConfig{Days: []string{"mon"}}, 1},
+		{"bad day and time zone", QuietHoursConfig{Hours: "22:00-07:00", Days: []string{"someday"}, Timezone: "Mars/Olympus"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.quiet.validationErrors(); len(errs) != tt.wantErrs {
+				t.Errorf("validationErrors() = %v, want %d errors", errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestParseFocusAssertions(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"on", `{"data":[{"storeAssertionRecords":[{"assertionDetails":{"assertionDetailsModeIdentifier":"com.apple.donotdisturb.mode.default"}}]}],"header":{}}`, true},
+		{"off", `{"data":[{"storeAssertionRecords":[]}],"header":{}}`, false},
+		{"never used", `{"data":[],"header":{}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFocusAssertions([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("parseFocusAssertions() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseFocusAssertions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseFocusAssertions([]byte("not json")); err == nil {
+		t.Error("parseFocusAssertions() of invalid JSON succeeded, want an error")
+	}
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultWorkHours are the work hours assumed when the schedule leaves them out
+const DefaultWorkHours = "09:00-18:00"
+
+// DefaultWorkDays are the work days assumed when the schedule leaves them out
+var DefaultWorkDays = []string{"mon", "tue", "wed", "thu", "fri"}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ScheduleConfig switches to different timeouts outside work hours
+type ScheduleConfig struct {
+	// WorkDays are three-letter day names (mon, tue, ...), Monday to Friday
+	// if unset
+	WorkDays []string `yaml:"work_days,omitempty"`
+
+	// WorkHours is a HH:MM-HH:MM range, 09:00-18:00 if unset. A range that
+	// ends before it starts runs past midnight.
+	WorkHours string `yaml:"work_hours,omitempty"`
+
+	// Timezone is an IANA name such as Europe/Berlin, the local time zone
+	// if unset
+	Timezone string `yaml:"timezone,omitempty"`
+
+	AfterHours AfterHoursConfig `yaml:"after_hours,omitempty"`
+}
+
+// AfterHoursConfig holds the timeouts used outside work hours. Contexts
+// without an after-hours timeout keep their usual one, and Default replaces
+// timeout.default.
+type AfterHoursConfig struct {
+	Default  time.Duration            `yaml:"default,omitempty"`
+	Contexts map[string]time.Duration `yaml:"contexts,omitempty"`
+}
+
+// Enabled reports whether any after-hours timeouts are configured
+func (s ScheduleConfig) Enabled() bool {
+	return s.AfterHours.Default > 0 || len(s.AfterHours.Contexts) > 0
+}
+
+// InWorkHours reports whether t falls within work hours. Malformed settings
+// are rejected by validation, and count as always in work hours here.
+func (s ScheduleConfig) InWorkHours(t time.Time) bool {
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return true
+		}
+		t = t.In(loc)
+	}
+
+	days := s.WorkDays
+	if len(days) == 0 {
+		days = DefaultWorkDays
+	}
+	hours := s.WorkHours
+	if hours == "" {
+		hours = DefaultWorkHours
+	}
+	start, end, err := parseWorkHours(hours)
+	if err != nil {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if start > end && minute < end {
+		// The early hours of an overnight range belong to the previous day
+		day = (day + 6) % 7
+	}
+	if !slices.ContainsFunc(days, func(name string) bool { return weekdayNames[strings.ToLower(name)] == day }) {
+		return false
+	}
+
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// afterHoursTimeout returns the after-hours timeout configured for a
+// context by exact name or pattern, if any
+func (s ScheduleConfig) afterHoursTimeout(contextName string) (time.Duration, bool) {
+	if d, ok := s.AfterHours.Contexts[contextName]; ok {
+		return d, true
+	}
+	for _, pattern := range slices.Sorted(maps.Keys(s.AfterHours.Contexts)) {
+		if IsContextPattern(pattern) && MatchContextPattern(pattern, contextName) {
+			return s.AfterHours.Contexts[pattern], true
+		}
+	}
+	return 0, false
+}
+
+// validationErrors returns every problem with the schedule settings
+func (s ScheduleConfig) validationErrors() []error {
+	var errs []error
+
+	for _, name := range s.WorkDays {
+		if _, ok := weekdayNames[strings.ToLower(name)]; !ok {
+			errs = append(errs, fmt.Errorf("schedule.work_days: unknown day '%s' (use mon, tue, wed, thu, fri, sat, sun)", name))
+		}
+	}
+	if s.WorkHours != "" {
+		if _, _, err := parseWorkHours(s.WorkHours); err != nil {
+			errs = append(errs, fmt.Errorf("invalid schedule.work_hours: %w", err))
+		}
+	}
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("invalid schedule.timezone: %w", err))
+		}
+	}
+
+	if s.AfterHours.Default < 0 {
+		errs = append(errs, fmt.Errorf("schedule.after_hours.default must not be negative"))
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.AfterHours.Contexts)) {
+		if err := ValidateContextPattern(name); err != nil {
+			errs = append(errs, fmt.Errorf("invalid schedule.after_hours.contexts pattern '%s': %w", name, err))
+		}
+		if s.AfterHours.Contexts[name] <= 0 {
+			errs = append(errs, fmt.Errorf("after-hours timeout for context '%s' must be positive", name))
+		}
+	}
+
+	return errs
+}
+
+// parseWorkHours parses a HH:MM-HH:MM range into minutes since midnight
+func parseWorkHours(hours string) (start, end int, err error) {
+	from, to, ok := strings.Cut(hours, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected HH:MM-HH:MM, got %q", hours)
+	}
+	if start, err = parseClock(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(to); err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("work hours %q start and end at the same time", hours)
+	}
+	return start, end, nil
+}
+
+// parseClock parses a HH:MM time of day into minutes since midnight
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleInWorkHours(t *testing.T) {
+	// 2024-01-15 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name     string
+		schedule ScheduleConfig
+		time     time.Time
+		want     bool
+	}{
+		{"default hours on Monday", ScheduleConfig{}, at(15, 10, 0), true},
+		{"default start is inclusive", ScheduleConfig{}, at(15, 9, 0), true},
+		{"default end is exclusive", ScheduleConfig{}, at(15, 18, 0), false},
+		{"before hours", ScheduleConfig{}, at(15, 8, 59), false},
+		{"Saturday", ScheduleConfig{}, at(20, 10, 0), false},
+		{"custom days", ScheduleConfig{WorkDays: []string{"Sat"}}, at(20, 10, 0), true},
+		{"custom hours", ScheduleConfig{WorkHours: "07:30-16:00"}, at(15, 7, 45), true},
+		{"overnight evening", ScheduleConfig{WorkHours: "22:00-06:00"}, at(15, 23, 0), true},
+		{"overnight early hours", ScheduleConfig{WorkHours: "22:00-06:00"}, at(16, 5, 0), true},
+		{"overnight into Saturday", ScheduleConfig{WorkHours: "22:00-06:00"}, at(20, 5, 0), true},
+		{"overnight into Monday", ScheduleConfig{WorkHours: "22:00-06:00"}, at(15, 5, 0), false},
+		{"overnight daytime", ScheduleConfig{WorkHours: "22:00-06:00"}, at(15, 12, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.InWorkHours(tt.time); got != tt.want {
+				t.Errorf("InWorkHours(%s) = %v, want %v", tt.time.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleTimezone(t *testing.T) {
+	schedule := ScheduleConfig{Timezone: "Asia/Tokyo"}
+	// 01:00 UTC on a Monday is 10:00 in Tokyo
+	ts := time.Date(2024, 1, 15, 1, 0, 0, 0, time.UTC)
+	if !schedule.InWorkHours(ts) {
+		t.Error("Expected 10:00 in Tokyo to be within work hours")
+	}
+	if schedule.InWorkHours(ts.Add(10 * time.Hour)) {
+		t.Error("Expected 20:00 in Tokyo to be outside work hours")
+	}
+}
+
+func TestScheduleValidationErrors(t *testing.T) {
+	schedule := ScheduleConfig{
+		WorkDays:  []string{"mon", "funday"},
+		WorkHours: "09:00-09:00",
+		Timezone:  "Mars/Olympus",
+		AfterHours: AfterHoursConfig{
+			Default:  -time.Minute,
+			Contexts: map[string]time.Duration{"prod": 0, "/(x/": time.Minute},
+		},
+	}
+
+	if errs := schedule.validationErrors(); len(errs) != 6 {
+		t.Errorf("validationErrors() = %v, want 6 problems", errs)
+	}
+
+	for _, hours := range []string{"9-18", "09:00", "25:00-26:00"} {
+		if _, _, err := parseWorkHours(hours); err == nil {
+			t.Errorf("parseWorkHours(%q) should fail", hours)
+		}
+	}
+}
+
+func TestGetTimeoutForContextAt(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Contexts = map[string]Context{
+		"prod": {Timeout: 30 * time.Minute},
+		"dev":  {Timeout: time.Hour},
+	}
+	cfg.Schedule = ScheduleConfig{
+		AfterHours: AfterHoursConfig{
+			Default:  10 * time.Minute,
+			Contexts: map[string]time.Duration{"prod*": 5 * time.Minute},
+		},
+	}
+
+	workHours := time.Date(2024, 1, 15, 10, 0, 0, 0, time.Local)
+	afterHours := time.Date(2024, 1, 15, 20, 0, 0, 0, time.Local)
+	weekend := time.Date(2024, 1, 20, 10, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		contextName string
+		now         time.Time
+		want        time.Duration
+	}{
+		{"prod", workHours, 30 * time.Minute},
+		{"prod", afterHours, 5 * time.Minute},
+		{"prod", weekend, 5 * time.Minute},
+		{"dev", afterHours, time.Hour},
+		{"staging", workHours, 30 * time.Minute},
+		{"staging", afterHours, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.GetTimeoutForContextAt(tt.contextName, tt.now); got != tt.want {
+			t.Errorf("GetTimeoutForContextAt(%s, %s) = %v, want %v", tt.contextName, tt.now.Format(time.RFC3339), got, tt.want)
+		}
+	}
+
+	// Without after-hours timeouts the schedule has no effect
+	cfg.Schedule.AfterHours = AfterHoursConfig{}
+	if got := cfg.GetTimeoutForContextAt("prod", afterHours); got != 30*time.Minute {
+		t.Errorf("GetTimeoutForContextAt(prod) = %v, want 30m without a schedule", got)
+	}
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"strings"
+	"time"
+)
+
+// ScreenLockReason is the switch reason recorded when switch_on_lock
+// switches because the screen was locked
+const ScreenLockReason = "screen locked"
+
+// screenLockPollInterval is how often the lock state is polled. There is no
+// portable lock notification without cgo, so polling keeps the daemon pure Go.
+const screenLockPollInterval = 2 * time.Second
+
+// parseIoregScreenLock reports whether `ioreg -n Root -d1` output shows the
+// console session's screen as locked. The key is absent while unlocked.
+func parseIoregScreenLock(output string) bool {
+	return strings.Contains(output, `"CGSSessionScreenIsLocked"=Yes`)
+}
+
+// parseLockedHint reports whether `loginctl show-session -p LockedHint
+// --value` output says the session is locked
+func parseLockedHint(output string) bool {
+	return strings.TrimSpace(output) == "yes"
+}
+
+// watchScreenLock polls the screen lock state while safety.switch_on_lock
+// is enabled, and switches to the default context when the screen gets
+// locked. It runs until the daemon stops.
+func (d *Daemon) watchScreenLock() {
+	ticker := time.NewTicker(screenLockPollInterval)
+	defer ticker.Stop()
+
+	locked := false
+	failing := false
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !d.currentConfig().Safety.SwitchOnLock {
+			locked = false
+			continue
+		}
+
+		nowLocked, err := d.isScreenLocked()
+		if err != nil {
+			// Log the first failure only; the setting may be on where
+			// the lock state can't be read
+			if !failing {
+				d.logger.Warn("Failed to read screen lock state, switch_on_lock is inactive", "error", err)
+			}
+			failing = true
+			continue
+		}
+		failing = false
+
+		if nowLocked && !locked {
+			d.runScreenLockSwitch()
+		}
+		locked = nowLocked
+	}
+}
+
+// runScreenLockSwitch switches to the default context after the screen was
+// locked, unless shutdown has begun
+func (d *Daemon) runScreenLockSwitch() {
+	d.checkMu.Lock()
+	defer d.checkMu.Unlock()
+
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	if err := d.switchWithoutTimeout(ScreenLockReason); err != nil {
+		d.logger.Error("Switching failed", "reason", ScreenLockReason, "error", err)
+	}
+	d.publishStatusSummary(false)
+}
+
+// switchWithoutTimeout treats an event such as a locked screen as the
+// timeout running out: the policy still honors never_switch_from,
+// extensions, pauses, and running Kubernetes tools, but there is no timeout
+// or grace period to wait out. Callers must hold checkMu.
+func (d *Daemon) switchWithoutTimeout(reason string) error {
+	config := d.currentConfig()
+
+	in, err := GatherPolicyInputs(config, d.stateManager, d.switcher, time.Now())
+	if err != nil {
+		return err
+	}
+	in.Timeout = 0
+	in.WriteTimeout = 0
+	in.GracePeriod = 0
+
+	decision := EvaluatePolicy(in)
+	if decision.Due() && config.Safety.CheckActiveKubectl {
+		in.ActiveProcesses = d.activeProcesses(in.CurrentContext)
+		in.DeferredSince = d.deferredSince
+		decision = EvaluatePolicy(in)
+	}
+	if decision.Action != PolicyActionSwitch {
+		d.logger.Info("Not switching", "reason", reason, "because", decision.Reasons[len(decision.Reasons)-1])
+		return nil
+	}
+
+	if d.skipSwitch("Dry run: would switch without waiting for the timeout", in, "reason", reason) {
+		return nil
+	}
+	d.logger.Info("Switching without waiting for the timeout", "context", in.CurrentContext, "to", in.DefaultContext, "reason", reason)
+	if err := d.switchContext(config, in.CurrentContext, in.DefaultContext, reason); err != nil {
+		return err
+	}
+	if err := ClearPendingSwitch(d.stateManager); err != nil {
+		d.logger.Warn("Failed to clear pending switch", "error", err)
+	}
+	d.recordLastSwitch(config, in.CurrentContext, in.DefaultContext)
+
+	d.notifySwitch(SwitchEvent{
+		FromContext: in.CurrentContext,
+		ToContext:   in.DefaultContext,
+		Reason:      reason,
+	}, d.switchBackAction(in.CurrentContext))
+	return nil
+}
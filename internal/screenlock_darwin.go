@@ -0,0 +1,19 @@
+//go:build darwin
+
+package internal
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// ScreenLocked reports whether the console user's screen is locked, as
+// recorded by the window server in the I/O Registry
+func ScreenLocked() (bool, error) {
+	// #nosec G204 -- command and arguments are hardcoded, not user input
+	output, err := exec.Command("ioreg", "-n", "Root", "-d1").Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to query ioreg: %w", err)
+	}
+	return parseIoregScreenLock(string(output)), nil
+}
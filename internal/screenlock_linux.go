@@ -0,0 +1,36 @@
+//go:build linux
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ScreenLocked reports whether the user's graphical session is locked,
+// using the LockedHint that systemd-logind keeps for screen lockers
+func ScreenLocked() (bool, error) {
+	// The daemon usually runs as a user service outside any session, so
+	// fall back to the user's display session
+	session := os.Getenv("XDG_SESSION_ID")
+	if session == "" {
+		// #nosec G204 -- the UID is formatted from an int, not user input
+		output, err := exec.Command("loginctl", "show-user", strconv.Itoa(os.Getuid()), "-p", "Display", "--value").Output()
+		if err != nil {
+			return false, fmt.Errorf("failed to find the graphical session: %w", err)
+		}
+		if session = strings.TrimSpace(string(output)); session == "" {
+			return false, fmt.Errorf("no graphical session found")
+		}
+	}
+
+	// #nosec G204 -- session ID comes from logind or its environment variable
+	output, err := exec.Command("loginctl", "show-session", session, "-p", "LockedHint", "--value").Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to read lock state of session %s: %w", session, err)
+	}
+	return parseLockedHint(string(output)), nil
+}
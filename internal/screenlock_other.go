@@ -0,0 +1,11 @@
+//go:build !darwin && !linux
+
+package internal
+
+import "errors"
+
+// ScreenLocked reports that reading the lock state is not implemented on
+// this platform
+func ScreenLocked() (bool, error) {
+	return false, errors.New("screen lock detection not supported on this platform")
+}
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseIoregScreenLock(t *testing.T) {
+	locked := `+-o Root  <class IORegistryEntry, id 0x100000100, retain 32>
+    {
+      "IOConsoleUsers" = ({"kCGSSessionOnConsoleKey"=Yes,"CGSSessionScreenIsLocked"=Yes,"kCGSSessionUserNameKey"="me"})
+    }`
+	unlocked := `+-o Root  <class IORegistryEntry, id 0x100000100, retain 32>
+    {
+      "IOConsoleUsers" = ({"kCGSSessionOnConsoleKey"=Yes,"kCGSSessionUserNameKey"="me"})
+    }`
+
+	if !parseIoregScreenLock(locked) {
+		t.Error("Expected the screen to be reported as locked")
+	}
+	if parseIoregScreenLock(unlocked) {
+		t.Error("Expected the screen to be reported as unlocked")
+	}
+}
+
+func TestParseLockedHint(t *testing.T) {
+	if !parseLockedHint("yes\n") || parseLockedHint("no\n") || parseLockedHint("") {
+		t.Error("parseLockedHint() should only report yes as locked")
+	}
+}
+
+func TestDaemonSwitchOnLock(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	// Fresh activity: the timeout is far off, but locking switches anyway
+	if err := RecordActivity(store, "production"); err != nil {
+		t.Fatalf("RecordActivity() error = %v", err)
+	}
+	d.runScreenLockSwitch()
+	if len(switcher.switches) != 1 || switcher.switches[0] != "local" {
+		t.Fatalf("Expected one switch to 'local', got %v", switcher.switches)
+	}
+	events, err := d.history.Read(HistoryFilter{})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Reason != ScreenLockReason {
+		t.Errorf("Expected a switch recorded with reason %q, got %+v", ScreenLockReason, events)
+	}
+}
+
+func TestDaemonSwitchOnLockHonorsExemptions(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher, store)
+
+	configContent := `
+timeout:
+  default: 10m
+  check_interval: 1s
+default_context: local
+notifications:
+  enabled: false
+safety:
+  check_active_kubectl: false
+  validate_default_context: false
+  switch_on_lock: true
+  never_switch_from:
+    - production
+`
+	if err := os.WriteFile(d.configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	d.runScreenLockSwitch()
+	if len(switcher.switches) != 0 {
+		t.Errorf("Expected no switch from a never_switch_from context, got %v", switcher.switches)
+	}
+
+	// Paused contexts are left alone too
+	switcher.current = "staging"
+	if _, err := PauseContext(store, "staging", time.Hour); err != nil {
+		t.Fatalf("PauseContext() error = %v", err)
+	}
+	d.runScreenLockSwitch()
+	if len(switcher.switches) != 0 {
+		t.Errorf("Expected no switch from a paused context, got %v", switcher.switches)
+	}
+}
+
+func TestDaemonWatchScreenLock(t *testing.T) {
+	switcher := &fakeSwitcher{current: "production"}
+	d := newFakeDaemon(t, switcher, &fakeStateStore{})
+	defer d.cancel()
+
+	configContent := `
+timeout:
+  default: 10m
+  check_interval: 1s
+default_context: local
+notifications:
+  enabled: false
+safety:
+  check_active_kubectl: false
+  validate_default_context: false
+  switch_on_lock: true
+`
+	if err := os.WriteFile(d.configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+
+	// The screen stays locked: only the transition switches
+	d.isScreenLocked = func() (bool, error) { return true, nil }
+	go d.watchScreenLock()
+
+	switches := func() []string {
+		switcher.mu.Lock()
+		defer switcher.mu.Unlock()
+		return append([]string(nil), switcher.switches...)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(switches()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := switches(); len(got) != 1 || got[0] != "local" {
+		t.Fatalf("Expected a switch to 'local' after locking, got %v", got)
+	}
+
+	// Back on production while still locked, nothing happens
+	switcher.mu.Lock()
+	switcher.current = "production"
+	switcher.mu.Unlock()
+	time.Sleep(screenLockPollInterval + 500*time.Millisecond)
+	if got := switches(); len(got) != 1 {
+		t.Errorf("Expected no further switches while locked, got %v", got)
+	}
+}
@@ -1,7 +1,7 @@
 package internal
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,7 +10,7 @@ import (
 
 // TestCommandInjectionPrevention tests that we safely handle malicious context names
 func TestCommandInjectionPrevention(t *testing.T) {
-	logger := log.New(os.Stdout, "[security-test] ", log.LstdFlags)
+	logger := NewLogger(os.Stdout, LogFormatText, slog.LevelDebug)
 	cs := NewContextSwitcher(logger)
 
 	maliciousContextNames := []string{
@@ -65,7 +65,7 @@ func TestFilePermissions(t *testing.T) {
 	}
 
 	// Record activity to create state file
-	if err := sm.RecordActivity("test"); err != nil {
+	if err := RecordActivity(sm, "test"); err != nil {
 		t.Fatalf("RecordActivity failed: %v", err)
 	}
 
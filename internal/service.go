@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// ServiceManager installs and controls the daemon as a per-user system service
+type ServiceManager interface {
+	// Name returns the name of the underlying service system (launchd, systemd, Task Scheduler)
+	Name() string
+	Install() error
+	Uninstall() error
+	Load() error
+	Unload() error
+	Restart() error
+	IsInstalled() bool
+	IsRunning() bool
+	GetStatus() (string, error)
+}
+
+// NewServiceManager returns the service manager for the current platform:
+// launchd on macOS, systemd user units on Linux, and Task Scheduler on Windows
+func NewServiceManager(binaryPath string) (ServiceManager, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		lm, err := NewLaunchdManager(binaryPath)
+		if err != nil {
+			return nil, err
+		}
+		return lm, nil
+	case "linux":
+		sm, err := NewSystemdManager(binaryPath)
+		if err != nil {
+			return nil, err
+		}
+		return sm, nil
+	case "windows":
+		tm, err := NewTaskSchedulerManager(binaryPath)
+		if err != nil {
+			return nil, err
+		}
+		return tm, nil
+	default:
+		return nil, fmt.Errorf("daemon service installation is not supported on %s", runtime.GOOS)
+	}
+}
@@ -0,0 +1,346 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// sessionRetention is how long a per-shell kubeconfig session is tracked
+// after its last activity. Older sessions were switched long ago or belong
+// to shells that are gone.
+const sessionRetention = 24 * time.Hour
+
+// SessionKubeconfig returns the KUBECONFIG to track as a per-shell session,
+// or "" when it is unset or names only the default ~/.kube/config. A single
+// file is cleaned, so it is the same session however it is spelled.
+func SessionKubeconfig(kubeconfig string) string {
+	paths := filepath.SplitList(kubeconfig)
+	paths = slices.DeleteFunc(paths, func(path string) bool { return path == "" })
+	if len(paths) == 0 {
+		return ""
+	}
+
+	if home, err := os.UserHomeDir(); err == nil && len(paths) == 1 &&
+		filepath.Clean(paths[0]) == filepath.Join(home, ".kube", "config") {
+		return ""
+	}
+
+	if len(paths) == 1 {
+		return filepath.Clean(paths[0])
+	}
+	return kubeconfig
+}
+
+// listedKubeconfigs returns the files listed under kubeconfigs that are
+// timed out as sessions: all but the daemon's own kubeconfig, which the
+// main check covers
+func listedKubeconfigs(config *Config) []string {
+	own := SessionKubeconfig(os.Getenv("KUBECONFIG"))
+	return slices.DeleteFunc(config.ManagedKubeconfigs(), func(path string) bool {
+		return SessionKubeconfig(path) == "" || path == own
+	})
+}
+
+// kubeconfigExists reports whether any of the files in a KUBECONFIG still
+// exist. Tools like kubie remove a shell's kubeconfig when it exits.
+func kubeconfigExists(kubeconfig string) bool {
+	for _, path := range filepath.SplitList(kubeconfig) {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSessions times out each per-shell kubeconfig session with recent
+// activity, and each file listed under kubeconfigs, the way checkTimeout
+// does the daemon's own kubeconfig. Sessions whose kubeconfig is gone or,
+// unless it is listed, that have been idle past sessionRetention are
+// forgotten. Failures are logged rather than degrading the daemon, since
+// the default kubeconfig is still being looked after.
+func (d *Daemon) checkSessions(config *Config, now time.Time) {
+	sessionSwitcher, ok := d.switcher.(KubeconfigSwitcher)
+	if !ok {
+		return
+	}
+
+	state, err := d.stateManager.Load()
+	if err != nil {
+		d.logger.Warn("Failed to read kubeconfig sessions", "error", err)
+		return
+	}
+	sessions := state.KubeconfigSessions()
+	sessions = d.trackKubeconfigs(config, sessionSwitcher, sessions)
+
+	own := SessionKubeconfig(os.Getenv("KUBECONFIG"))
+	for _, kubeconfig := range slices.Sorted(maps.Keys(sessions)) {
+		session := sessions[kubeconfig]
+		_, listed := config.kubeconfigPolicy(kubeconfig)
+		if (!listed && now.Sub(session.LastActivity) > sessionRetention) || !kubeconfigExists(kubeconfig) {
+			d.logger.Debug("Forgetting kubeconfig session", "kubeconfig", kubeconfig)
+			if err := ForgetSession(d.stateManager, kubeconfig); err != nil {
+				d.logger.Warn("Failed to forget kubeconfig session", "kubeconfig", kubeconfig, "error", err)
+			}
+			continue
+		}
+
+		// The main check already covers the daemon's own kubeconfig
+		if kubeconfig == own {
+			continue
+		}
+
+		sessionConfig, err := configForSession(config, kubeconfig, session)
+		if err != nil {
+			// Fall back to the user's own settings, which the project can
+			// only make stricter
+			d.logger.Warn("Ignoring project config of kubeconfig session", "kubeconfig", kubeconfig, "error", err)
+		}
+		if ignored := sessionConfig.ignoredProjectDefaultContexts(); len(ignored) > 0 {
+			d.logger.Warn("Ignoring project default contexts you don't switch to yourself",
+				"kubeconfig", kubeconfig, "project", session.Project, "contexts", ignored)
+		}
+		if err := d.checkSession(sessionConfig, sessionSwitcher.ForKubeconfig(kubeconfig), kubeconfig, session, now); err != nil {
+			d.logger.Warn("Failed to check kubeconfig session", "kubeconfig", kubeconfig, "error", err)
+		}
+	}
+}
+
+// nextSessionCheck returns when the earliest kubeconfig session times
+// out, judging by the context recorded with its last activity, or the zero
+// time if there are none. A session already past its timeout, because
+// something held up its switch, is checked again after check_interval.
+func (d *Daemon) nextSessionCheck(config *Config, now time.Time) time.Time {
+	if _, ok := d.switcher.(KubeconfigSwitcher); !ok {
+		return time.Time{}
+	}
+
+	state, err := d.stateManager.Load()
+	if err != nil {
+		return time.Time{}
+	}
+	sessions := state.KubeconfigSessions()
+
+	own := SessionKubeconfig(os.Getenv("KUBECONFIG"))
+	var next time.Time
+	for kubeconfig, session := range sessions {
+		// checkSessions logs a project config it can't load
+		sessionConfig, _ := configForSession(config, kubeconfig, session)
+
+		// Sessions on their default context have nothing to time out
+		context := session.CurrentContext
+		if kubeconfig == own || context == "" || context == sessionConfig.GetDefaultContextFor(context) || config.IsNeverSwitchFrom(context) {
+			continue
+		}
+		at := session.LastActivity.Add(sessionConfig.GetTimeoutForContextAt(session.CurrentContext, now))
+		if !at.After(now) {
+			at = now.Add(config.Timeout.CheckInterval)
+		}
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	return next
+}
+
+// configForSession returns config with the settings listed under
+// kubeconfigs for a session's kubeconfig applied, then the project
+// configuration of its last activity. If the project configuration can't
+// be loaded, it returns the configuration without it along with the error.
+func configForSession(config *Config, kubeconfig string, session KubeconfigSession) (*Config, error) {
+	config = config.ForKubeconfig(kubeconfig)
+	if session.Project == "" {
+		return config, nil
+	}
+	project, err := LoadProjectConfig(session.Project)
+	if err != nil {
+		return config, err
+	}
+	return config.WithProject(project), nil
+}
+
+// trackKubeconfigs starts a session for each file listed under kubeconfigs
+// that doesn't have one, so it is timed out even if no shell uses it, and
+// returns sessions with them added. Its clock starts now, on its current
+// context.
+func (d *Daemon) trackKubeconfigs(config *Config, switcher KubeconfigSwitcher, sessions map[string]KubeconfigSession) map[string]KubeconfigSession {
+	for _, kubeconfig := range listedKubeconfigs(config) {
+		if _, ok := sessions[kubeconfig]; ok || !kubeconfigExists(kubeconfig) {
+			continue
+		}
+		// A file with no current context has nothing to time out yet
+		context, _ := switcher.ForKubeconfig(kubeconfig).CurrentContext()
+		if err := RecordSessionActivity(d.stateManager, kubeconfig, context, ""); err != nil {
+			d.logger.Warn("Failed to start tracking kubeconfig", "kubeconfig", kubeconfig, "error", err)
+			continue
+		}
+		d.logger.Debug("Tracking listed kubeconfig", "kubeconfig", kubeconfig, "context", context)
+		if sessions == nil {
+			sessions = make(map[string]KubeconfigSession)
+		}
+		sessions[kubeconfig] = KubeconfigSession{LastActivity: time.Now(), CurrentContext: context}
+	}
+	return sessions
+}
+
+// watchKubeconfigs records activity in a file listed under kubeconfigs
+// whenever it changes, the way the kubeconfig watcher does for the default
+// kubeconfig, until the daemon stops. It watches the files listed when the
+// daemon started.
+func (d *Daemon) watchKubeconfigs(switcher KubeconfigSwitcher, paths []string) error {
+	// Notifications don't say which file changed, so compare each with
+	// how it was last seen
+	last := make(map[string]fileSignature, len(paths))
+	for _, path := range paths {
+		last[path] = statFile(path)
+	}
+
+	watch := &fileWatch{
+		paths:  paths,
+		label:  "Listed kubeconfig",
+		logger: d.logger,
+		ctx:    d.ctx,
+		onChange: func() error {
+			var errs []error
+			for _, path := range paths {
+				current := statFile(path)
+				if current == last[path] || !current.exists {
+					continue
+				}
+				last[path] = current
+				if err := d.kubeconfigChanged(switcher.ForKubeconfig(path), path); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				}
+			}
+			return errors.Join(errs...)
+		},
+	}
+	return watch.run()
+}
+
+// kubeconfigChanged records activity in a listed kubeconfig that changed,
+// noting in the history if its context did, and requests a check since the
+// deadline moved
+func (d *Daemon) kubeconfigChanged(switcher Switcher, kubeconfig string) error {
+	currentContext, err := switcher.CurrentContext()
+	if err != nil {
+		// Typically a transient state while the file is being written
+		return nil
+	}
+
+	state, err := d.stateManager.Load()
+	if err != nil {
+		return fmt.Errorf("failed to read kubeconfig sessions: %w", err)
+	}
+	sessions := state.KubeconfigSessions()
+	session := sessions[kubeconfig]
+
+	event := HistoryEvent{Type: HistoryActivity, Context: currentContext, Reason: "kubeconfig modified", Kubeconfig: kubeconfig}
+	if session.CurrentContext != currentContext {
+		d.logger.Info("Detected context switch in listed kubeconfig",
+			"kubeconfig", kubeconfig, "from", session.CurrentContext, "context", currentContext)
+		event = HistoryEvent{Type: HistoryContextChange, Context: currentContext, FromContext: session.CurrentContext, Reason: "kubeconfig changed", Kubeconfig: kubeconfig}
+	}
+	d.recordHistory(event)
+
+	if err := RecordSessionActivity(d.stateManager, kubeconfig, currentContext, session.Project); err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
+	}
+	d.requestCheck()
+	return nil
+}
+
+// checkSession applies the timeout policy to one per-shell kubeconfig.
+// Sessions switch without a grace period, since the pending switch the
+// cancel-switch command acts on belongs to the default kubeconfig.
+func (d *Daemon) checkSession(config *Config, switcher Switcher, kubeconfig string, session KubeconfigSession, now time.Time) error {
+	currentContext, err := switcher.CurrentContext()
+	if err != nil {
+		// Typically no current context, because an earlier timeout unset it
+		d.logger.Debug("No current context in kubeconfig session", "kubeconfig", kubeconfig, "error", err)
+		return nil
+	}
+
+	in := PolicyInputs{
+		Now:             now,
+		CurrentContext:  currentContext,
+		DefaultContext:  config.GetDefaultContextFor(currentContext),
+		LastActivity:    session.LastActivity,
+		Timeout:         config.GetTimeoutForContextAt(currentContext, now),
+		AfterHours:      config.IsAfterHours(now),
+		NeverSwitchFrom: config.IsNeverSwitchFrom(currentContext),
+	}
+	in.DefaultForbidden = config.IsNeverSwitchTo(in.DefaultContext)
+	state, err := d.stateManager.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	state.mu.RLock()
+	in.ExtendedUntil = state.ExtendedUntil
+	state.mu.RUnlock()
+	in.PausedUntil = state.ContextPausedUntil(currentContext)
+
+	decision := EvaluatePolicy(in)
+	if decision.Due() && config.Safety.CheckActiveKubectl && d.findActiveProcesses != nil {
+		// A broken process listing never disables the timeout
+		if processes, err := d.findActiveProcesses(); err == nil {
+			for _, p := range processes {
+				if p.UsesContext(currentContext) {
+					in.ActiveProcesses = append(in.ActiveProcesses, p.String())
+				}
+			}
+			decision = EvaluatePolicy(in)
+		}
+	}
+	if decision.Action != PolicyActionSwitch {
+		return nil
+	}
+
+	reason := timeoutReason(in)
+	d.logger.Info("Timeout exceeded in kubeconfig session",
+		"kubeconfig", kubeconfig, "context", currentContext, "idle", in.Idle().Round(time.Second), "timeout", in.Timeout)
+	if d.skipSwitch("Dry run: would switch kubeconfig session", in, "kubeconfig", kubeconfig) {
+		return nil
+	}
+
+	if err := switcher.SwitchContextSafe(in.DefaultContext, config.IsNeverSwitchTo); err != nil {
+		d.runHooks(config, HookOnSwitchFailure, SwitchEvent{FromContext: currentContext, ToContext: in.DefaultContext, Reason: reason, Error: err.Error()})
+		return fmt.Errorf("context switch failed: %w", err)
+	}
+
+	// The default context may not be in the session's kubeconfig, in which
+	// case the switcher leaves it with no context
+	toContext, err := switcher.CurrentContext()
+	if err != nil {
+		toContext = ""
+	}
+	d.logger.Info("Switched kubeconfig session", "kubeconfig", kubeconfig, "from", currentContext, "context", toContext)
+	d.resetNamespace(config, switcher, currentContext)
+	d.revokeCredentials(config, kubeconfigPathsFor(kubeconfig), currentContext)
+
+	// Restart the session's clock so it isn't switched again every check
+	if err := RecordSessionActivity(d.stateManager, kubeconfig, toContext, session.Project); err != nil {
+		d.logger.Warn("Failed to record activity after context switch", "kubeconfig", kubeconfig, "error", err)
+	}
+
+	d.recordHistory(HistoryEvent{
+		Type:        HistorySwitch,
+		Context:     toContext,
+		FromContext: currentContext,
+		Reason:      reason,
+		Kubeconfig:  kubeconfig,
+	})
+
+	if toContext == "" {
+		toContext = "no context"
+	}
+	d.notifySwitch(SwitchEvent{FromContext: currentContext, ToContext: toContext, Reason: reason})
+	return nil
+}
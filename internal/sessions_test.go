@@ -0,0 +1,276 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSessionKubeconfig(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	tests := []struct {
+		kubeconfig string
+		want       string
+	}{
+		{"", ""},
+		{string(filepath.ListSeparator), ""},
+		{filepath.Join(home, ".kube", "config"), ""},
+		{"/tmp/kubie-abc.yaml", "/tmp/kubie-abc.yaml"},
+		{"/tmp/kubie/../kubie-abc.yaml", "/tmp/kubie-abc.yaml"},
+		{filepath.Join(home, ".kube", "config") + string(filepath.ListSeparator) + "/tmp/other", filepath.Join(home, ".kube", "config") + string(filepath.ListSeparator) + "/tmp/other"},
+	}
+	for _, tt := range tests {
+		if got := SessionKubeconfig(tt.kubeconfig); got != tt.want {
+			t.Errorf("SessionKubeconfig(%q) = %q, want %q", tt.kubeconfig, got, tt.want)
+		}
+	}
+}
+
+func TestStateManagerSessions(t *testing.T) {
+	sm, err := NewStateManager(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("NewStateManager() error = %v", err)
+	}
+
+	if err := RecordSessionActivity(sm, "/tmp/kubie-1.yaml", "production", ""); err != nil {
+		t.Fatalf("RecordSessionActivity() error = %v", err)
+	}
+
+	sessions := loadState(t, sm).KubeconfigSessions()
+	session, ok := sessions["/tmp/kubie-1.yaml"]
+	if !ok || session.CurrentContext != "production" || time.Since(session.LastActivity) > time.Minute {
+		t.Errorf("Expected recent session in 'production', got %+v", sessions)
+	}
+
+	// The default kubeconfig's activity is untouched
+	if lastActivity, context, _ := LastActivity(sm); !lastActivity.IsZero() || context != "" {
+		t.Errorf("Expected no default activity, got %v in %q", lastActivity, context)
+	}
+
+	if err := ForgetSession(sm, "/tmp/kubie-1.yaml"); err != nil {
+		t.Fatalf("ForgetSession() error = %v", err)
+	}
+	if sessions := loadState(t, sm).KubeconfigSessions(); len(sessions) != 0 {
+		t.Errorf("Expected no sessions after ForgetSession, got %+v", sessions)
+	}
+}
+
+// fakeKubeconfigSwitcher is a fakeSwitcher for the default kubeconfig with
+// one fakeSwitcher per session kubeconfig
+type fakeKubeconfigSwitcher struct {
+	*fakeSwitcher
+	sessions map[string]*fakeSwitcher
+}
+
+func (f *fakeKubeconfigSwitcher) ForKubeconfig(kubeconfig string) Switcher {
+	return f.sessions[kubeconfig]
+}
+
+func TestDaemonChecksKubeconfigSessions(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	dir := t.TempDir()
+	idle := filepath.Join(dir, "kubie-idle.yaml")
+	active := filepath.Join(dir, "kubie-active.yaml")
+	for _, path := range []string{idle, active} {
+		if err := os.WriteFile(path, []byte("apiVersion: v1\n"), 0600); err != nil {
+			t.Fatalf("Failed to write kubeconfig: %v", err)
+		}
+	}
+	gone := filepath.Join(dir, "kubie-gone.yaml")
+
+	switcher := &fakeKubeconfigSwitcher{
+		fakeSwitcher: &fakeSwitcher{current: "local"},
+		sessions: map[string]*fakeSwitcher{
+			idle:   {current: "production"},
+			active: {current: "staging"},
+			gone:   {current: "production"},
+		},
+	}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher.fakeSwitcher, store)
+	d.switcher = switcher
+
+	now := time.Now()
+	store.state.Sessions = map[string]KubeconfigSession{
+		idle:   {LastActivity: now.Add(-time.Hour), CurrentContext: "production"},
+		active: {LastActivity: now.Add(-time.Minute), CurrentContext: "staging"},
+		gone:   {LastActivity: now.Add(-time.Hour), CurrentContext: "production"},
+	}
+
+	d.checkSessions(d.currentConfig(), now)
+
+	if got := switcher.sessions[idle].switches; len(got) != 1 || got[0] != "local" {
+		t.Errorf("Expected the idle session to switch to 'local', got %v", got)
+	}
+	if got := switcher.sessions[active].switches; len(got) != 0 {
+		t.Errorf("Expected no switch in the active session, got %v", got)
+	}
+	if got := switcher.sessions[gone].switches; len(got) != 0 {
+		t.Errorf("Expected no switch for a removed kubeconfig, got %v", got)
+	}
+	if got := switcher.switches; len(got) != 0 {
+		t.Errorf("Expected the default kubeconfig untouched, got %v", got)
+	}
+
+	sessions := loadState(t, store).KubeconfigSessions()
+	if _, ok := sessions[gone]; ok {
+		t.Error("Expected the removed kubeconfig's session to be forgotten")
+	}
+	if session := sessions[idle]; session.CurrentContext != "local" || now.Sub(session.LastActivity) > time.Minute {
+		t.Errorf("Expected the switched session restarted in 'local', got %+v", session)
+	}
+
+	events, err := d.history.Read(HistoryFilter{Type: HistorySwitch})
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(events) != 1 || events[0].Kubeconfig != idle || events[0].FromContext != "production" {
+		t.Errorf("Expected one switch recorded for the idle session, got %+v", events)
+	}
+}
+
+func TestDaemonChecksSessionProjectConfig(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	dir := t.TempDir()
+	strict := filepath.Join(dir, "kubie-strict.yaml")
+	lenient := filepath.Join(dir, "kubie-lenient.yaml")
+	for _, path := range []string{strict, lenient} {
+		if err := os.WriteFile(path, []byte("apiVersion: v1\n"), 0600); err != nil {
+			t.Fatalf("Failed to write kubeconfig: %v", err)
+		}
+	}
+
+	// One repository shortens the 10m timeout, the other tries to lengthen it
+	strictProject := filepath.Join(dir, "strict", ProjectConfigFileName)
+	lenientProject := filepath.Join(dir, "lenient", ProjectConfigFileName)
+	for path, content := range map[string]string{
+		strictProject:  "timeout: 5m\ndefault_context: sandbox\n",
+		lenientProject: "timeout: 2h\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("Failed to create project: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write project config: %v", err)
+		}
+	}
+
+	switcher := &fakeKubeconfigSwitcher{
+		fakeSwitcher: &fakeSwitcher{current: "local"},
+		sessions: map[string]*fakeSwitcher{
+			strict:  {current: "production"},
+			lenient: {current: "production"},
+		},
+	}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher.fakeSwitcher, store)
+	d.switcher = switcher
+	// A project may only pick a context the user switches to anyway
+	d.config.Contexts = map[string]Context{"dev": {DefaultContext: "sandbox"}}
+
+	now := time.Now()
+	store.state.Sessions = map[string]KubeconfigSession{
+		strict: {LastActivity: now.Add(-7 * time.Minute), CurrentContext: "production", Project: strictProject},
+	}
+
+	// Past the project's 5m, so due within check_interval rather than in 3m
+	if next := d.nextSessionCheck(d.currentConfig(), now); next.After(now.Add(time.Second)) {
+		t.Errorf("Expected the strict session to be due now, next check at %v", next)
+	}
+
+	store.state.Sessions[lenient] = KubeconfigSession{
+		LastActivity: now.Add(-15 * time.Minute), CurrentContext: "production", Project: lenientProject,
+	}
+
+	d.checkSessions(d.currentConfig(), now)
+
+	if got := switcher.sessions[strict].switches; len(got) != 1 || got[0] != "sandbox" {
+		t.Errorf("Expected the strict project's session to switch to 'sandbox' after 5m, got %v", got)
+	}
+	if got := switcher.sessions[lenient].switches; len(got) != 1 || got[0] != "local" {
+		t.Errorf("Expected the user's 10m timeout to outrank the project's 2h, got %v", got)
+	}
+
+	sessions := loadState(t, store).KubeconfigSessions()
+	if session := sessions[strict]; session.Project != strictProject {
+		t.Errorf("Expected the switched session to keep its project config, got %+v", session)
+	}
+}
+
+func TestDaemonChecksListedKubeconfigs(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work.yaml")
+	personal := filepath.Join(dir, "personal.yaml")
+	for _, path := range []string{work, personal} {
+		if err := os.WriteFile(path, []byte("apiVersion: v1\n"), 0600); err != nil {
+			t.Fatalf("Failed to write kubeconfig: %v", err)
+		}
+	}
+
+	switcher := &fakeKubeconfigSwitcher{
+		fakeSwitcher: &fakeSwitcher{current: "local"},
+		sessions: map[string]*fakeSwitcher{
+			work:     {current: "work-prod"},
+			personal: {current: "home-lab"},
+		},
+	}
+	store := &fakeStateStore{}
+	d := newFakeDaemon(t, switcher.fakeSwitcher, store)
+	d.switcher = switcher
+
+	config := *d.currentConfig()
+	config.Kubeconfigs = []KubeconfigPolicy{
+		{Path: work, Timeout: 5 * time.Minute, DefaultContext: "work-dev"},
+		{Path: personal, DefaultContext: "minikube"},
+	}
+
+	// Listed files are tracked without any shell using them
+	now := time.Now()
+	d.checkSessions(&config, now)
+	sessions := loadState(t, store).KubeconfigSessions()
+	if session, ok := sessions[work]; !ok || session.CurrentContext != "work-prod" {
+		t.Fatalf("Expected the work kubeconfig tracked in 'work-prod', got %+v", sessions)
+	}
+	if got := switcher.sessions[work].switches; len(got) != 0 {
+		t.Errorf("Expected no switch when tracking starts, got %v", got)
+	}
+
+	// Each times out on its own settings, and a listed file isn't
+	// forgotten however long it has been idle
+	store.state.Sessions[work] = KubeconfigSession{LastActivity: now.Add(-6 * time.Minute), CurrentContext: "work-prod"}
+	store.state.Sessions[personal] = KubeconfigSession{LastActivity: now.Add(-30 * time.Hour), CurrentContext: "home-lab"}
+	d.checkSessions(&config, now)
+
+	if got := switcher.sessions[work].switches; len(got) != 1 || got[0] != "work-dev" {
+		t.Errorf("Expected the work kubeconfig to switch to 'work-dev' after 5m, got %v", got)
+	}
+	if got := switcher.sessions[personal].switches; len(got) != 1 || got[0] != "minikube" {
+		t.Errorf("Expected the personal kubeconfig to switch to 'minikube', got %v", got)
+	}
+	if got := switcher.switches; len(got) != 0 {
+		t.Errorf("Expected the default kubeconfig untouched, got %v", got)
+	}
+
+	// A context switch in a listed file restarts its clock
+	switcher.sessions[work].current = "work-staging"
+	if err := d.kubeconfigChanged(switcher.sessions[work], work); err != nil {
+		t.Fatalf("kubeconfigChanged() error = %v", err)
+	}
+	sessions = loadState(t, store).KubeconfigSessions()
+	if session := sessions[work]; session.CurrentContext != "work-staging" || time.Since(session.LastActivity) > time.Minute {
+		t.Errorf("Expected fresh activity in 'work-staging', got %+v", session)
+	}
+	events, err := d.history.Read(HistoryFilter{Type: HistoryContextChange})
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(events) != 1 || events[0].Kubeconfig != work || events[0].FromContext != "work-dev" {
+		t.Errorf("Expected one context change recorded for the work kubeconfig, got %+v", events)
+	}
+}
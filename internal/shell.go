@@ -6,7 +6,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Shell types
@@ -14,8 +19,13 @@ const (
 	ShellBash = "bash"
 	ShellZsh  = "zsh"
 	ShellFish = "fish"
+	// ShellPowerShell covers both PowerShell 7 (pwsh) and Windows PowerShell
+	ShellPowerShell = "powershell"
 )
 
+// SupportedShells are the shells the integration can be installed in
+var SupportedShells = []string{ShellBash, ShellZsh, ShellFish, ShellPowerShell}
+
 // ShellIntegration markers for identification
 const (
 	IntegrationStartMarker = "# >>> kubectx-timeout shell integration >>>"
@@ -31,10 +41,15 @@ type ShellProfile struct {
 
 // DetectShell detects the user's current shell
 func DetectShell() (string, error) {
+	// PowerShell is the only supported shell on Windows
+	if runtime.GOOS == "windows" {
+		return ShellPowerShell, nil
+	}
+
 	// Try $SHELL environment variable first
 	shellEnv := os.Getenv("SHELL")
 	if shellEnv != "" {
-		base := filepath.Base(shellEnv)
+		base := shellName(filepath.Base(shellEnv))
 		if isValidShell(base) {
 			return base, nil
 		}
@@ -50,7 +65,7 @@ func DetectShell() (string, error) {
 			shell := strings.TrimSpace(string(output))
 			shell = filepath.Base(shell)
 			// Remove leading dash if present (login shells)
-			shell = strings.TrimPrefix(shell, "-")
+			shell = shellName(strings.TrimPrefix(shell, "-"))
 			if isValidShell(shell) {
 				return shell, nil
 			}
@@ -60,14 +75,18 @@ func DetectShell() (string, error) {
 	return "", fmt.Errorf("unable to detect shell")
 }
 
+// shellName returns the shell type of a shell executable's name: the
+// name itself, except that pwsh is PowerShell
+func shellName(executable string) string {
+	if executable == "pwsh" {
+		return ShellPowerShell
+	}
+	return executable
+}
+
 // isValidShell checks if the shell is supported
 func isValidShell(shell string) bool {
-	switch shell {
-	case ShellBash, ShellZsh, ShellFish:
-		return true
-	default:
-		return false
-	}
+	return slices.Contains(SupportedShells, shell)
 }
 
 // GetShellProfilePath returns the profile path for the given shell
@@ -96,6 +115,8 @@ func GetShellProfilePath(shell string) (string, error) {
 		profile = filepath.Join(home, ".zshrc")
 	case ShellFish:
 		profile = filepath.Join(home, ".config", "fish", "config.fish")
+	case ShellPowerShell:
+		profile = powerShellProfilePath(home)
 	default:
 		return "", fmt.Errorf("unsupported shell: %s", shell)
 	}
@@ -103,82 +124,845 @@ func GetShellProfilePath(shell string) (string, error) {
 	return profile, nil
 }
 
-// GetShellIntegrationCode returns the shell integration code for the given shell
+// powerShellProfilePath returns the current user's PowerShell profile for
+// the current host. On Windows that is PowerShell 7's, unless only Windows
+// PowerShell has one.
+func powerShellProfilePath(home string) string {
+	const name = "Microsoft.PowerShell_profile.ps1"
+	if runtime.GOOS != "windows" {
+		return filepath.Join(home, ".config", "powershell", name)
+	}
+
+	pwshProfile := filepath.Join(home, "Documents", "PowerShell", name)
+	windowsProfile := filepath.Join(home, "Documents", "WindowsPowerShell", name)
+	if _, err := os.Stat(pwshProfile); err != nil {
+		if _, err := os.Stat(windowsProfile); err == nil {
+			return windowsProfile
+		}
+	}
+	return pwshProfile
+}
+
+// DefaultWrapCommands are the commands the shell integration wraps when
+// shell.wrap_commands isn't configured
+var DefaultWrapCommands = []string{"kubectl", "kubectx", "kubens", "helm", "k9s"}
+
+// sessionCommands are interactive tools that can stay open for hours. Their
+// wrappers keep recording activity for as long as they run.
+var sessionCommands = map[string]bool{
+	"k9s": true,
+}
+
+// contextSwitchCommands change the context or namespace. Their wrappers
+// record activity after they succeed, so the new context is the one recorded.
+var contextSwitchCommands = map[string]bool{
+	"kubectx": true,
+	"kubens":  true,
+}
+
+// wrapCommandPattern matches command names that are safe to use as shell
+// function names
+var wrapCommandPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateWrapCommands checks that commands can be wrapped by the shell
+// integration
+func ValidateWrapCommands(commands []string) error {
+	if len(commands) == 0 {
+		return fmt.Errorf("at least one command is required")
+	}
+
+	seen := make(map[string]bool, len(commands))
+	for _, command := range commands {
+		if !wrapCommandPattern.MatchString(command) {
+			return fmt.Errorf("invalid command name %q", command)
+		}
+		if seen[command] {
+			return fmt.Errorf("command %q is listed more than once", command)
+		}
+		seen[command] = true
+	}
+
+	return nil
+}
+
+// ShellAlias is a short name for a wrapped command, such as k for kubectl.
+// The integration tracks it like the command itself.
+type ShellAlias struct {
+	Name    string
+	Command string
+}
+
+// aliasCommandPattern matches alias targets: a command name or a path to one
+var aliasCommandPattern = regexp.MustCompile(`^[A-Za-z0-9._/~-]+$`)
+
+// aliasDefinitionPattern matches single-command alias definitions in bash,
+// zsh (alias k=kubectl) and fish (alias k kubectl) profiles
+var aliasDefinitionPattern = regexp.MustCompile(`^\s*alias\s+([A-Za-z0-9][A-Za-z0-9._-]*)(?:=|\s+)(['"]?)([^\s'"]+)(['"]?)\s*(?:#.*)?$`)
+
+// powerShellAliasPattern matches alias definitions in PowerShell profiles
+// (Set-Alias k kubectl, or with -Name and -Value), with the same groups as
+// aliasDefinitionPattern
+var powerShellAliasPattern = regexp.MustCompile(`^\s*(?i:(?:set|new)-alias)\s+(?i:-name\s+)?([A-Za-z0-9][A-Za-z0-9._-]*)\s+(?i:-value\s+)?(['"]?)([^\s'"]+)(['"]?)\s*(?:#.*)?$`)
+
+// ParseShellAliases parses name=command entries, as used by
+// shell.extra_aliases
+func ParseShellAliases(entries []string) ([]ShellAlias, error) {
+	aliases := make([]ShellAlias, 0, len(entries))
+	for _, entry := range entries {
+		name, command, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid alias %q: expected name=command", entry)
+		}
+		aliases = append(aliases, ShellAlias{Name: name, Command: command})
+	}
+
+	if err := validateShellAliases(aliases, nil); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// DetectShellAliases scans a shell profile for aliases that run one of
+// commands, such as alias k=kubectl. Aliases with arguments are skipped. A
+// missing profile has no aliases.
+func DetectShellAliases(profilePath string, commands []string) ([]ShellAlias, error) {
+	// #nosec G304 -- profilePath is constructed from user home dir and known profile names
+	content, err := os.ReadFile(profilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+
+	var aliases []ShellAlias
+	index := make(map[string]int)
+	for _, line := range strings.Split(string(content), "\n") {
+		match := aliasDefinitionPattern.FindStringSubmatch(line)
+		if match == nil {
+			match = powerShellAliasPattern.FindStringSubmatch(line)
+		}
+		if match == nil || match[2] != match[4] {
+			continue
+		}
+		name, command := match[1], match[3]
+		if slices.Contains(commands, name) || !slices.Contains(commands, filepath.Base(command)) {
+			continue
+		}
+
+		// Like the shell, the last definition wins
+		if i, ok := index[name]; ok {
+			aliases[i].Command = command
+			continue
+		}
+		index[name] = len(aliases)
+		aliases = append(aliases, ShellAlias{Name: name, Command: command})
+	}
+
+	return aliases, nil
+}
+
+// validateShellAliases checks that aliases can be wrapped alongside commands
+func validateShellAliases(aliases []ShellAlias, commands []string) error {
+	seen := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		if !wrapCommandPattern.MatchString(alias.Name) {
+			return fmt.Errorf("invalid alias name %q", alias.Name)
+		}
+		if !aliasCommandPattern.MatchString(alias.Command) {
+			return fmt.Errorf("invalid command %q for alias %q", alias.Command, alias.Name)
+		}
+		if slices.Contains(commands, alias.Name) {
+			return fmt.Errorf("alias %q is also a wrapped command", alias.Name)
+		}
+		if seen[alias.Name] {
+			return fmt.Errorf("alias %q is listed more than once", alias.Name)
+		}
+		seen[alias.Name] = true
+	}
+
+	return nil
+}
+
+// GetShellIntegrationCode returns the shell integration code for the given
+// shell, wrapping DefaultWrapCommands
 func GetShellIntegrationCode(shell string, binaryPath string) (string, error) {
-	switch shell {
-	case ShellBash:
-		return fmt.Sprintf(`%s
-# Function-based kubectl wrapper
-# This is lighter weight than aliasing to a script
-_kubectx_timeout_kubectl() {
-    local kubectx_timeout_bin="${KUBECTX_TIMEOUT_BIN:-%s}"
+	return GetShellIntegrationCodeForCommands(shell, binaryPath, DefaultWrapCommands, nil)
+}
 
-    # Record activity in background (non-blocking)
-    if [ -x "$kubectx_timeout_bin" ]; then
-        "$kubectx_timeout_bin" record-activity >/dev/null 2>&1 &
-    fi
+// GetShellIntegrationCodeForCommands returns the shell integration code for
+// the given shell, with a wrapper that records activity for each command and
+// alias. Alias wrappers replace the aliases, which would otherwise shadow them.
+func GetShellIntegrationCodeForCommands(shell string, binaryPath string, commands []string, aliases []ShellAlias) (string, error) {
+	return GetShellIntegrationCodeWithOptions(shell, binaryPath, commands, aliases, ShellIntegrationOptions{})
+}
 
-    # Execute kubectl with all arguments
-    command kubectl "$@"
+// ShellIntegrationOptions are the wrappers' optional behaviors, from the
+// shell section of the config
+type ShellIntegrationOptions struct {
+	// RemainingTime makes command wrappers print the time left, such as
+	// "[prod: 7m left]", after each command once less than this remains.
+	// Zero never prints it.
+	RemainingTime time.Duration
 }
 
-# Create kubectl alias/function
-# Use a function instead of alias for better compatibility
-kubectl() {
-    _kubectx_timeout_kubectl "$@"
+// GetShellIntegrationCodeWithOptions returns the shell integration code for
+// the given shell, like GetShellIntegrationCodeForCommands, with the given
+// options
+func GetShellIntegrationCodeWithOptions(shell string, binaryPath string, commands []string, aliases []ShellAlias, opts ShellIntegrationOptions) (string, error) {
+	if err := ValidateWrapCommands(commands); err != nil {
+		return "", err
+	}
+	if err := validateShellAliases(aliases, commands); err != nil {
+		return "", err
+	}
+
+	var wrapper func(name, command, binaryPath string, opts ShellIntegrationOptions) string
+	switch shell {
+	case ShellBash, ShellZsh:
+		wrapper = posixWrapper
+	case ShellFish:
+		wrapper = fishWrapper
+	case ShellPowerShell:
+		wrapper = powerShellWrapper
+	default:
+		return "", fmt.Errorf("unsupported shell: %s", shell)
+	}
+
+	var b strings.Builder
+	b.WriteString(IntegrationStartMarker + "\n")
+	if shell == ShellPowerShell {
+		fmt.Fprintf(&b, powerShellHelpers, powerShellQuote(binaryPath))
+	}
+	for _, command := range commands {
+		b.WriteString(wrapper(command, command, binaryPath, opts))
+	}
+	for _, alias := range aliases {
+		b.WriteString(wrapper(alias.Name, alias.Command, binaryPath, opts))
+	}
+
+	// Export for use in subshells
+	if shell == ShellBash {
+		b.WriteString("\n")
+		for _, command := range commands {
+			fmt.Fprintf(&b, "export -f %s 2>/dev/null || true\n", wrapperHelperName(command))
+		}
+		for _, alias := range aliases {
+			fmt.Fprintf(&b, "export -f %s 2>/dev/null || true\n", wrapperHelperName(alias.Name))
+		}
+	}
+
+	b.WriteString(IntegrationEndMarker + "\n")
+	return b.String(), nil
 }
 
-# Export for use in subshells
-export -f _kubectx_timeout_kubectl 2>/dev/null || true
-%s
-`, IntegrationStartMarker, binaryPath, IntegrationEndMarker), nil
+// wrapperHelperName returns the name of the bash/zsh helper function that
+// does the work for a command's wrapper
+func wrapperHelperName(command string) string {
+	return "_kubectx_timeout_" + strings.NewReplacer("-", "_", ".", "_").Replace(command)
+}
 
-	case ShellZsh:
-		return fmt.Sprintf(`%s
-# Function-based kubectl wrapper
-# This is lighter weight than aliasing to a script
-_kubectx_timeout_kubectl() {
-    local kubectx_timeout_bin="${KUBECTX_TIMEOUT_BIN:-%s}"
+// posixWrapper returns the bash/zsh wrapper named name that runs command. The
+// wrapper calls a helper function, which bash exports for use in subshells.
+func posixWrapper(name, command, binaryPath string, opts ShellIntegrationOptions) string {
+	var body string
+	switch kind := filepath.Base(command); {
+	case sessionCommands[kind]:
+		body = `    local heartbeat_pid=""
 
-    # Record activity in background (non-blocking)
+    # Record activity in background until %[1]s exits. The heartbeat also
+    # stops on its own if this shell exits.
     if [ -x "$kubectx_timeout_bin" ]; then
+        heartbeat_pid=$("$kubectx_timeout_bin" record-activity --while-pid $$ >/dev/null 2>&1 & echo $!)
+    fi
+
+    # Execute %[1]s with all arguments
+    command %[1]s "$@"
+    local exit_code=$?
+
+    if [ -n "$heartbeat_pid" ]; then
+        kill "$heartbeat_pid" 2>/dev/null
+    fi
+    return $exit_code
+`
+	case credentialSubcommands[kind] != nil:
+		body = `
+    # Execute %[1]s with all arguments
+    command %[1]s "$@"
+    local exit_code=$?
+
+    # Record activity after fetching cluster credentials, which makes the
+    # cluster's context current; other %[1]s commands are ignored
+    if [ $exit_code -eq 0 ] && [ -x "$kubectx_timeout_bin" ]; then
+        "$kubectx_timeout_bin" record-activity --credentials --args "$*" >/dev/null 2>&1 &
+    fi
+    return $exit_code
+`
+	case contextSwitchCommands[kind]:
+		body = `
+    # Execute %[1]s with all arguments
+    command %[1]s "$@"
+    local exit_code=$?
+
+    # Record activity after a successful switch, so the new context is recorded
+    if [ $exit_code -eq 0 ] && [ -x "$kubectx_timeout_bin" ]; then
         "$kubectx_timeout_bin" record-activity >/dev/null 2>&1 &
     fi
+    return $exit_code
+`
+	default:
+		body = `    local heartbeat_pid=""
+
+    # Record activity in background (non-blocking). The arguments tell
+    # reads from writes, and long-running commands such as logs -f keep
+    # recording until %[1]s exits.
+    if [ -x "$kubectx_timeout_bin" ]; then
+        heartbeat_pid=$("$kubectx_timeout_bin" record-activity --args "$*" --while-pid $$ >/dev/null 2>&1 & echo $!)
+    fi
 
-    # Execute kubectl with all arguments
-    command kubectl "$@"
-}
+    # Execute %[1]s with all arguments
+    command %[1]s "$@"
+    local exit_code=$?
+
+    if [ -n "$heartbeat_pid" ]; then
+        kill "$heartbeat_pid" 2>/dev/null
+    fi
+`
+		if opts.RemainingTime > 0 {
+			body += `
+    # Show the time left once it runs low (shell.remaining_time)
+    if [ -x "$kubectx_timeout_bin" ]; then
+        "$kubectx_timeout_bin" prompt --left-below ` + formatInitTimeout(opts.RemainingTime) + ` >&2
+    fi
+`
+		}
+		body += `    return $exit_code
+`
+	}
+
+	// An alias would shadow its wrapper, and expand in the definition itself
+	header := fmt.Sprintf("\n# %s wrapper\n", name)
+	if name != command {
+		header = fmt.Sprintf("\n# %s wrapper (alias for %s)\nunalias %s 2>/dev/null\n", name, command, name)
+	}
 
-# Create kubectl alias/function
-# Use a function instead of alias for better compatibility
-kubectl() {
-    _kubectx_timeout_kubectl "$@"
+	return header + fmt.Sprintf(`%[2]s() {
+    local kubectx_timeout_bin="${KUBECTX_TIMEOUT_BIN:-%[3]s}"
+
+    # Tell of an automatic switch since the last command, once
+    if [ -f "${XDG_STATE_HOME:-$HOME/.local/state}/kubectx-timeout/switch-notice" ] && [ -x "$kubectx_timeout_bin" ]; then
+        "$kubectx_timeout_bin" switch-notice || true
+    fi
+`+body+`}
+
+%[4]s() {
+    %[2]s "$@"
+}
+`, command, wrapperHelperName(name), binaryPath, name)
 }
-%s
-`, IntegrationStartMarker, binaryPath, IntegrationEndMarker), nil
 
-	case ShellFish:
-		return fmt.Sprintf(`%s
-# Fish shell kubectl wrapper
-function kubectl
-    set kubectx_timeout_bin %s
+// fishWrapper returns the fish wrapper named name that runs command
+func fishWrapper(name, command, binaryPath string, opts ShellIntegrationOptions) string {
+	var body string
+	switch kind := filepath.Base(command); {
+	case sessionCommands[kind]:
+		body = `    set -l heartbeat_pid
 
-    # Record activity in background (non-blocking)
+    # Record activity in background until %[1]s exits. The heartbeat also
+    # stops on its own if this shell exits.
     if test -x "$kubectx_timeout_bin"
+        $kubectx_timeout_bin record-activity --while-pid $fish_pid >/dev/null 2>&1 &
+        set heartbeat_pid $last_pid
+    end
+
+    # Execute %[1]s with all arguments
+    command %[1]s $argv
+    set -l exit_code $status
+
+    if test -n "$heartbeat_pid"
+        kill $heartbeat_pid 2>/dev/null
+    end
+    return $exit_code
+`
+	case credentialSubcommands[kind] != nil:
+		body = `
+    # Execute %[1]s with all arguments
+    command %[1]s $argv
+    set -l exit_code $status
+
+    # Record activity after fetching cluster credentials, which makes the
+    # cluster's context current; other %[1]s commands are ignored
+    if test $exit_code -eq 0; and test -x "$kubectx_timeout_bin"
+        $kubectx_timeout_bin record-activity --credentials --args "$argv" >/dev/null 2>&1 &
+    end
+    return $exit_code
+`
+	case contextSwitchCommands[kind]:
+		body = `
+    # Execute %[1]s with all arguments
+    command %[1]s $argv
+    set -l exit_code $status
+
+    # Record activity after a successful switch, so the new context is recorded
+    if test $exit_code -eq 0; and test -x "$kubectx_timeout_bin"
         $kubectx_timeout_bin record-activity >/dev/null 2>&1 &
     end
+    return $exit_code
+`
+	default:
+		body = `    set -l heartbeat_pid
 
-    # Execute kubectl with all arguments
-    command kubectl $argv
-end
-%s
-`, IntegrationStartMarker, binaryPath, IntegrationEndMarker), nil
+    # Record activity in background (non-blocking). The arguments tell
+    # reads from writes, and long-running commands such as logs -f keep
+    # recording until %[1]s exits.
+    if test -x "$kubectx_timeout_bin"
+        $kubectx_timeout_bin record-activity --args "$argv" --while-pid $fish_pid >/dev/null 2>&1 &
+        set heartbeat_pid $last_pid
+    end
+
+    # Execute %[1]s with all arguments
+    command %[1]s $argv
+    set -l exit_code $status
+
+    if test -n "$heartbeat_pid"
+        kill $heartbeat_pid 2>/dev/null
+    end
+`
+		if opts.RemainingTime > 0 {
+			body += `
+    # Show the time left once it runs low (shell.remaining_time)
+    if test -x "$kubectx_timeout_bin"
+        $kubectx_timeout_bin prompt --left-below ` + formatInitTimeout(opts.RemainingTime) + ` >&2
+    end
+`
+		}
+		body += `    return $exit_code
+`
+	}
+
+	// fish aliases are functions, so the wrapper simply replaces them
+	header := fmt.Sprintf("\n# %s wrapper\n", name)
+	if name != command {
+		header = fmt.Sprintf("\n# %s wrapper (alias for %s)\n", name, command)
+	}
+
+	return header + fmt.Sprintf(`function %[3]s
+    set -l kubectx_timeout_bin %[2]s
+
+    # Tell of an automatic switch since the last command, once
+    set -l kubectx_timeout_state $HOME/.local/state
+    test -n "$XDG_STATE_HOME"; and set kubectx_timeout_state $XDG_STATE_HOME
+    if test -f $kubectx_timeout_state/kubectx-timeout/switch-notice; and test -x "$kubectx_timeout_bin"
+        $kubectx_timeout_bin switch-notice
+    end
+`+body+`end
+`, command, binaryPath, name)
+}
+
+// powerShellHelpers are the functions PowerShell wrappers share. Its argument
+// is the quoted binary path.
+const powerShellHelpers = `
+# Tells of an automatic switch since the last command, once
+function _KubectxTimeoutNotice {
+    $dir = if ($env:XDG_STATE_HOME) { $env:XDG_STATE_HOME } elseif ($env:LOCALAPPDATA) { $env:LOCALAPPDATA } else { Join-Path $HOME '.local/state' }
+    if (-not (Test-Path -LiteralPath (Join-Path $dir 'kubectx-timeout/switch-notice') -PathType Leaf)) { return }
+
+    $bin = if ($env:KUBECTX_TIMEOUT_BIN) { $env:KUBECTX_TIMEOUT_BIN } else { %[1]s }
+    if (Test-Path -LiteralPath $bin -PathType Leaf) { & $bin switch-notice }
+}
+
+# Prints the time left to stderr once less than $Below remains, keeping the
+# exit code of the command before it
+function _KubectxTimeoutRemaining([string]$Below) {
+    $code = $global:LASTEXITCODE
+    $bin = if ($env:KUBECTX_TIMEOUT_BIN) { $env:KUBECTX_TIMEOUT_BIN } else { %[1]s }
+    if (Test-Path -LiteralPath $bin -PathType Leaf) {
+        $text = & $bin prompt --left-below $Below
+        if ($text) { [Console]::Error.WriteLine($text) }
+    }
+    $global:LASTEXITCODE = $code
+}
+
+# Starts kubectx-timeout record-activity with the given arguments in the
+# background, without a window, and returns its process
+function _KubectxTimeoutRecord {
+    $bin = if ($env:KUBECTX_TIMEOUT_BIN) { $env:KUBECTX_TIMEOUT_BIN } else { %[1]s }
+    if (-not (Test-Path -LiteralPath $bin -PathType Leaf)) { return }
+
+    $info = New-Object System.Diagnostics.ProcessStartInfo $bin
+    $info.Arguments = (@('record-activity') + $args | ForEach-Object { '"' + ("$_" -replace '"', '\"') + '"' }) -join ' '
+    # The process's own directory isn't the location PowerShell is at, which
+    # a project's .kubectx-timeout.yaml is looked for from
+    if ($PWD.Provider.Name -eq 'FileSystem') { $info.WorkingDirectory = $PWD.ProviderPath }
+    $info.UseShellExecute = $false
+    $info.CreateNoWindow = $true
+    $info.RedirectStandardOutput = $true
+    $info.RedirectStandardError = $true
+    try { [System.Diagnostics.Process]::Start($info) } catch { }
+}
+
+# Returns the application a wrapper runs, rather than the wrapper itself
+function _KubectxTimeoutCommand([string]$Name) {
+    Get-Command -Name $Name -CommandType Application -ErrorAction Stop | Select-Object -First 1
+}
+`
+
+// powerShellWrapper returns the PowerShell wrapper named name that runs
+// command
+func powerShellWrapper(name, command, binaryPath string, opts ShellIntegrationOptions) string {
+	var body string
+	switch kind := filepath.Base(command); {
+	case sessionCommands[kind]:
+		body = `    # Record activity in background until %[1]s exits. The heartbeat also
+    # stops on its own if this shell exits.
+    $heartbeat = _KubectxTimeoutRecord '--while-pid' $PID
+
+    # Execute %[1]s with all arguments
+    try {
+        & (_KubectxTimeoutCommand %[2]s) @args
+    } finally {
+        if ($heartbeat) { Stop-Process -Id $heartbeat.Id -ErrorAction SilentlyContinue }
+    }
+`
+	case credentialSubcommands[kind] != nil:
+		body = `    # Execute %[1]s with all arguments
+    & (_KubectxTimeoutCommand %[2]s) @args
+
+    # Record activity after fetching cluster credentials, which makes the
+    # cluster's context current; other %[1]s commands are ignored
+    if ($LASTEXITCODE -eq 0) {
+        $null = _KubectxTimeoutRecord '--credentials' '--args' "$args"
+    }
+`
+	case contextSwitchCommands[kind]:
+		body = `    # Execute %[1]s with all arguments
+    & (_KubectxTimeoutCommand %[2]s) @args
+
+    # Record activity after a successful switch, so the new context is recorded
+    if ($LASTEXITCODE -eq 0) {
+        $null = _KubectxTimeoutRecord
+    }
+`
+	default:
+		body = `    # Record activity in background (non-blocking). The arguments tell
+    # reads from writes, and long-running commands such as logs -f keep
+    # recording until %[1]s exits.
+    $heartbeat = _KubectxTimeoutRecord '--args' "$args" '--while-pid' $PID
+
+    # Execute %[1]s with all arguments
+    try {
+        & (_KubectxTimeoutCommand %[2]s) @args
+    } finally {
+        if ($heartbeat) { Stop-Process -Id $heartbeat.Id -ErrorAction SilentlyContinue }
+    }
+`
+		if opts.RemainingTime > 0 {
+			body += `
+    # Show the time left once it runs low (shell.remaining_time)
+    _KubectxTimeoutRemaining '` + formatInitTimeout(opts.RemainingTime) + `'
+`
+		}
+	}
+
+	// PowerShell runs an alias before a function of the same name
+	header := fmt.Sprintf("\n# %s wrapper\n", name)
+	if name != command {
+		header = fmt.Sprintf("\n# %s wrapper (alias for %s)\nRemove-Item -Path Alias:%s -Force -ErrorAction SilentlyContinue\n", name, command, name)
+	}
+
+	return header + fmt.Sprintf("function %[3]s {\n    _KubectxTimeoutNotice\n\n"+body+"}\n", command, powerShellQuote(command), name)
+}
+
+// powerShellQuote quotes s as a single-quoted PowerShell string
+func powerShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Integration modes
+const (
+	// IntegrationModeWrapper defines a shell function for each wrapped command
+	IntegrationModeWrapper = "wrapper"
+	// IntegrationModeHook records activity from preexec hooks that watch the
+	// command line, leaving the user's own functions and aliases alone
+	IntegrationModeHook = "hook"
+)
+
+// GetShellHookCode returns hook-mode shell integration code for the given
+// shell. Instead of wrapping commands, it watches each command line from a
+// preexec hook (zsh's add-zsh-hook, bash-preexec for bash, or fish's
+// fish_preexec event) and records activity when a command runs one of
+// commands or aliases.
+func GetShellHookCode(shell string, binaryPath string, commands []string, aliases []ShellAlias) (string, error) {
+	if err := ValidateWrapCommands(commands); err != nil {
+		return "", err
+	}
+	if err := validateShellAliases(aliases, commands); err != nil {
+		return "", err
+	}
+
+	tracked := slices.Clone(commands)
+	var sessions, switchers, credentials []string
+	addTracked := func(name, command string) {
+		if sessionCommands[filepath.Base(command)] {
+			sessions = append(sessions, name)
+		}
+		if contextSwitchCommands[filepath.Base(command)] {
+			switchers = append(switchers, name)
+		}
+		if credentialSubcommands[filepath.Base(command)] != nil {
+			credentials = append(credentials, name)
+		}
+	}
+	for _, command := range commands {
+		addTracked(command, command)
+	}
+	for _, alias := range aliases {
+		tracked = append(tracked, alias.Name)
+		addTracked(alias.Name, alias.Command)
+	}
+	list := func(names []string) string { return strings.Join(names, " ") }
+
+	switch shell {
+	case ShellBash, ShellZsh:
+		split := `read -ra words <<< "$1"`
+		register := `# Requires bash-preexec (https://github.com/rcaloras/bash-preexec)
+preexec_functions+=(_kubectx_timeout_preexec)
+precmd_functions+=(_kubectx_timeout_precmd)`
+		if shell == ShellZsh {
+			split = `words=(${(z)1})`
+			register = `autoload -Uz add-zsh-hook
+add-zsh-hook preexec _kubectx_timeout_preexec
+add-zsh-hook precmd _kubectx_timeout_precmd`
+		}
+		return fmt.Sprintf(posixHookTemplate, IntegrationStartMarker, binaryPath,
+			list(tracked), list(sessions), list(switchers), list(credentials), split, register, IntegrationEndMarker), nil
+
+	case ShellFish:
+		return fmt.Sprintf(fishHookTemplate, IntegrationStartMarker, binaryPath,
+			list(tracked), list(sessions), list(switchers), list(credentials), IntegrationEndMarker), nil
+
+	case ShellPowerShell:
+		return "", fmt.Errorf("hook mode is not supported for PowerShell; use wrapper mode")
 
 	default:
 		return "", fmt.Errorf("unsupported shell: %s", shell)
 	}
 }
 
+// posixHookTemplate is the bash/zsh hook-mode integration. Its arguments are
+// the start marker, binary path, tracked, session, context switch, and
+// credential commands, the line splitting $1 into words, the hook
+// registration, and the end marker.
+const posixHookTemplate = `%s
+# Hook-based activity tracking: watches command lines instead of wrapping
+# commands, so existing kubectl functions and aliases keep working
+_kubectx_timeout_bin="${KUBECTX_TIMEOUT_BIN:-%s}"
+_kubectx_timeout_commands="%s"
+_kubectx_timeout_sessions="%s"
+_kubectx_timeout_switchers="%s"
+_kubectx_timeout_credentials="%s"
+_kubectx_timeout_matched=""
+_kubectx_timeout_args=""
+_kubectx_timeout_pending=""
+_kubectx_timeout_heartbeat=""
+
+# Sets _kubectx_timeout_matched to the first tracked command the command
+# line runs, looking only at words in command position, and
+# _kubectx_timeout_args to its arguments
+_kubectx_timeout_match() {
+    local word expect=1
+    local -a words
+    %s
+    _kubectx_timeout_matched=""
+    _kubectx_timeout_args=""
+    for word in "${words[@]}"; do
+        if [ -n "$_kubectx_timeout_matched" ]; then
+            case "$word" in
+                '|'|'||'|'&&'|';'|'&'|'|&') return 0 ;;
+            esac
+            _kubectx_timeout_args="$_kubectx_timeout_args${_kubectx_timeout_args:+ }$word"
+            continue
+        fi
+        case "$word" in
+            '|'|'||'|'&&'|';'|'&'|'|&') expect=1; continue ;;
+        esac
+        [ $expect -eq 1 ] || continue
+        case "$word" in
+            *=*|sudo|command|builtin|exec|env|time|nohup|noglob) continue ;;
+        esac
+        word="${word##*/}"
+        case " $_kubectx_timeout_commands " in
+            *" $word "*) _kubectx_timeout_matched="$word"; continue ;;
+        esac
+        expect=0
+    done
+    [ -n "$_kubectx_timeout_matched" ]
+}
+
+_kubectx_timeout_preexec() {
+    _kubectx_timeout_match "$1" || return 0
+    [ -x "$_kubectx_timeout_bin" ] || return 0
+
+    # Tell of an automatic switch since the last command, once
+    if [ -f "${XDG_STATE_HOME:-$HOME/.local/state}/kubectx-timeout/switch-notice" ]; then
+        "$_kubectx_timeout_bin" switch-notice
+    fi
+
+    case " $_kubectx_timeout_switchers " in
+        # Record after a successful switch, so the new context is recorded
+        *" $_kubectx_timeout_matched "*) _kubectx_timeout_pending=1; return 0 ;;
+    esac
+    case " $_kubectx_timeout_credentials " in
+        # Record after fetching cluster credentials, which makes the
+        # cluster's context current; other commands are ignored
+        *" $_kubectx_timeout_matched "*) _kubectx_timeout_pending=credentials; return 0 ;;
+    esac
+    case " $_kubectx_timeout_sessions " in
+        # Keep recording until the session ends. The heartbeat also stops
+        # on its own if this shell exits.
+        *" $_kubectx_timeout_matched "*)
+            _kubectx_timeout_heartbeat=$("$_kubectx_timeout_bin" record-activity --while-pid $$ >/dev/null 2>&1 & echo $!)
+            return 0 ;;
+    esac
+
+    # Record activity in background (non-blocking). The arguments tell
+    # reads from writes, and long-running commands such as logs -f keep
+    # recording until the command line finishes.
+    _kubectx_timeout_heartbeat=$("$_kubectx_timeout_bin" record-activity --args "$_kubectx_timeout_args" --while-pid $$ >/dev/null 2>&1 & echo $!)
+}
+
+_kubectx_timeout_precmd() {
+    local exit_code=$?
+
+    if [ -n "$_kubectx_timeout_heartbeat" ]; then
+        kill "$_kubectx_timeout_heartbeat" 2>/dev/null
+        _kubectx_timeout_heartbeat=""
+    fi
+    if [ -n "$_kubectx_timeout_pending" ]; then
+        if [ $exit_code -eq 0 ] && [ "$_kubectx_timeout_pending" = credentials ]; then
+            ("$_kubectx_timeout_bin" record-activity --credentials --args "$_kubectx_timeout_args" >/dev/null 2>&1 &)
+        elif [ $exit_code -eq 0 ]; then
+            ("$_kubectx_timeout_bin" record-activity >/dev/null 2>&1 &)
+        fi
+        _kubectx_timeout_pending=""
+    fi
+    return $exit_code
+}
+
+%s
+%s
+`
+
+// fishHookTemplate is the fish hook-mode integration. Its arguments are the
+// start marker, binary path, tracked, session, context switch, and
+// credential commands, and the end marker.
+const fishHookTemplate = `%s
+# Hook-based activity tracking: watches command lines instead of wrapping
+# commands, so existing kubectl functions and aliases keep working
+set -g _kubectx_timeout_bin %s
+set -g _kubectx_timeout_commands %s
+set -g _kubectx_timeout_sessions %s
+set -g _kubectx_timeout_switchers %s
+set -g _kubectx_timeout_credentials %s
+set -g _kubectx_timeout_pending
+set -g _kubectx_timeout_pending_args
+set -g _kubectx_timeout_heartbeat
+
+# Prints the first tracked command the command line runs, looking only at
+# words in command position, followed by its arguments
+function _kubectx_timeout_match
+    set -l expect 1
+    set -l matched
+    for word in (string split -n ' ' -- $argv[1])
+        if test -n "$matched"
+            switch $word
+                case '|' '||' '&&' ';' '&' and or not
+                    break
+            end
+            echo $word
+            continue
+        end
+        switch $word
+            case '|' '||' '&&' ';' '&' and or not
+                set expect 1
+                continue
+        end
+        test $expect -eq 1; or continue
+        switch $word
+            case '*=*' sudo command builtin exec env time nohup
+                continue
+        end
+        set -l name (string replace -r '.*/' '' -- $word)
+        if contains -- $name $_kubectx_timeout_commands
+            echo $name
+            set matched $name
+            continue
+        end
+        set expect 0
+    end
+    test -n "$matched"
+end
+
+function _kubectx_timeout_preexec --on-event fish_preexec
+    set -l words (_kubectx_timeout_match $argv[1]); or return 0
+    set -l name $words[1]
+    set -l args
+    if test (count $words) -gt 1
+        set args $words[2..-1]
+    end
+    test -x "$_kubectx_timeout_bin"; or return 0
+
+    # Tell of an automatic switch since the last command, once
+    set -l state $HOME/.local/state
+    test -n "$XDG_STATE_HOME"; and set state $XDG_STATE_HOME
+    if test -f $state/kubectx-timeout/switch-notice
+        $_kubectx_timeout_bin switch-notice
+    end
+
+    if contains -- $name $_kubectx_timeout_switchers
+        # Record after a successful switch, so the new context is recorded
+        set -g _kubectx_timeout_pending 1
+    else if contains -- $name $_kubectx_timeout_credentials
+        # Record after fetching cluster credentials, which makes the
+        # cluster's context current; other commands are ignored
+        set -g _kubectx_timeout_pending credentials
+        set -g _kubectx_timeout_pending_args "$args"
+    else if contains -- $name $_kubectx_timeout_sessions
+        # Keep recording until the session ends. The heartbeat also stops
+        # on its own if this shell exits.
+        $_kubectx_timeout_bin record-activity --while-pid $fish_pid >/dev/null 2>&1 &
+        set -g _kubectx_timeout_heartbeat $last_pid
+    else
+        # Record activity in background (non-blocking). The arguments tell
+        # reads from writes, and long-running commands such as logs -f keep
+        # recording until the command line finishes.
+        $_kubectx_timeout_bin record-activity --args "$args" --while-pid $fish_pid >/dev/null 2>&1 &
+        set -g _kubectx_timeout_heartbeat $last_pid
+    end
+end
+
+function _kubectx_timeout_postexec --on-event fish_postexec
+    set -l exit_code $status
+
+    if test -n "$_kubectx_timeout_heartbeat"
+        kill $_kubectx_timeout_heartbeat 2>/dev/null
+        set -g _kubectx_timeout_heartbeat
+    end
+    if test -n "$_kubectx_timeout_pending"
+        if test $exit_code -eq 0; and test -x "$_kubectx_timeout_bin"
+            if test "$_kubectx_timeout_pending" = 1
+                $_kubectx_timeout_bin record-activity >/dev/null 2>&1 &
+            else
+                $_kubectx_timeout_bin record-activity --credentials --args "$_kubectx_timeout_pending_args" >/dev/null 2>&1 &
+            end
+        end
+        set -g _kubectx_timeout_pending
+        set -g _kubectx_timeout_pending_args
+    end
+end
+%s
+`
+
 // IsIntegrationInstalled checks if the integration is already installed
 func IsIntegrationInstalled(profilePath string) (bool, error) {
 	// #nosec G304 -- profilePath is constructed from user home dir and known profile names, not user input
@@ -206,63 +990,122 @@ func IsIntegrationInstalled(profilePath string) (bool, error) {
 	return false, nil
 }
 
-// InstallIntegration installs the shell integration to the profile file
-func InstallIntegration(profilePath string, integrationCode string) error {
+// GetIntegrationSourceCode returns the profile block that sources the
+// integration file, so the file can be regenerated without editing the
+// profile again
+func GetIntegrationSourceCode(shell string, integrationPath string) (string, error) {
+	var line string
+	switch shell {
+	case ShellBash, ShellZsh:
+		line = fmt.Sprintf(`[ -f %[1]q ] && source %[1]q`, integrationPath)
+	case ShellFish:
+		line = fmt.Sprintf(`test -f %[1]q; and source %[1]q`, integrationPath)
+	case ShellPowerShell:
+		line = fmt.Sprintf(`if (Test-Path -LiteralPath %[1]s) { . %[1]s }`, powerShellQuote(integrationPath))
+	default:
+		return "", fmt.Errorf("unsupported shell: %s", shell)
+	}
+
+	return IntegrationStartMarker + "\n" + line + "\n" + IntegrationEndMarker + "\n", nil
+}
+
+// IsIntegrationFileSourced checks whether the integration in the profile
+// sources integrationPath, rather than being an inline block written by an
+// older version
+func IsIntegrationFileSourced(profilePath string, integrationPath string) (bool, error) {
+	// #nosec G304 -- profilePath is constructed from user home dir and known profile names, not user input
+	content, err := os.ReadFile(profilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read profile: %w", err)
+	}
+
+	return strings.Contains(string(content), strconv.Quote(integrationPath)) ||
+		strings.Contains(string(content), powerShellQuote(integrationPath)), nil
+}
+
+// InstallIntegrationFile writes the integration code to integrationPath,
+// replacing any previous version. The file is written atomically so a shell
+// starting meanwhile never sources half of it.
+func InstallIntegrationFile(integrationPath string, integrationCode string) error {
+	if err := os.MkdirAll(filepath.Dir(integrationPath), 0750); err != nil {
+		return fmt.Errorf("failed to create integration directory: %w", err)
+	}
+
+	tmpPath := integrationPath + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(integrationCode), 0600); err != nil {
+		return fmt.Errorf("failed to write integration file: %w", err)
+	}
+	if err := os.Rename(tmpPath, integrationPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to install integration file: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveIntegrationFile removes the integration file, if there is one
+func RemoveIntegrationFile(integrationPath string) error {
+	if err := os.Remove(integrationPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove integration file: %w", err)
+	}
+	return nil
+}
+
+// InstallIntegration installs the shell integration to the profile file,
+// first backing the profile up as BackupProfile does with keepBackups. It
+// returns the backup's path, or "" if there was no profile to back up.
+func InstallIntegration(profilePath string, integrationCode string, keepBackups int) (string, error) {
 	// Check if already installed
 	installed, err := IsIntegrationInstalled(profilePath)
 	if err != nil {
-		return fmt.Errorf("failed to check installation status: %w", err)
+		return "", fmt.Errorf("failed to check installation status: %w", err)
 	}
 	if installed {
-		return fmt.Errorf("integration already installed in %s", profilePath)
+		return "", MarkFailure(fmt.Errorf("integration already installed in %s", profilePath), ErrAlreadyInstalled)
 	}
 
 	// Ensure profile directory exists
 	profileDir := filepath.Dir(profilePath)
 	if err := os.MkdirAll(profileDir, 0750); err != nil {
-		return fmt.Errorf("failed to create profile directory: %w", err)
+		return "", fmt.Errorf("failed to create profile directory: %w", err)
 	}
 
-	// Create backup
-	backupPath := profilePath + ".kubectx-timeout.backup"
-	if _, err := os.Stat(profilePath); err == nil {
-		// #nosec G304 -- profilePath is constructed from user home dir and known profile names, not user input
-		content, err := os.ReadFile(profilePath)
-		if err != nil {
-			return fmt.Errorf("failed to read profile for backup: %w", err)
-		}
-		// #nosec G703 -- backupPath is profilePath (from user home dir + known profile names) + fixed suffix, not user input
-		if err := os.WriteFile(backupPath, content, 0600); err != nil {
-			return fmt.Errorf("failed to create backup: %w", err)
-		}
+	backupPath, err := BackupProfile(profilePath, keepBackups)
+	if err != nil {
+		return "", err
 	}
 
 	// Append integration code
 	// #nosec G304 -- profilePath is constructed from user home dir and known profile names, not user input
 	file, err := os.OpenFile(profilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
 	if err != nil {
-		return fmt.Errorf("failed to open profile: %w", err)
+		return backupPath, fmt.Errorf("failed to open profile: %w", err)
 	}
 	defer file.Close()
 
 	// Add newlines before and after for readability
 	content := fmt.Sprintf("\n%s\n", integrationCode)
 	if _, err := file.WriteString(content); err != nil {
-		return fmt.Errorf("failed to write integration code: %w", err)
+		return backupPath, fmt.Errorf("failed to write integration code: %w", err)
 	}
 
-	return nil
+	return backupPath, nil
 }
 
-// UninstallIntegration removes the shell integration from the profile file
-func UninstallIntegration(profilePath string) error {
+// UninstallIntegration removes the shell integration from the profile file,
+// first backing the profile up as BackupProfile does with keepBackups. It
+// returns the backup's path, or "" if there was nothing to remove.
+func UninstallIntegration(profilePath string, keepBackups int) (string, error) {
 	// #nosec G304 -- profilePath is constructed from user home dir and known profile names, not user input
 	file, err := os.Open(profilePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil // Nothing to uninstall
+			return "", nil // Nothing to uninstall
 		}
-		return fmt.Errorf("failed to open profile: %w", err)
+		return "", fmt.Errorf("failed to open profile: %w", err)
 	}
 	defer file.Close()
 
@@ -292,31 +1135,24 @@ func UninstallIntegration(profilePath string) error {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to read profile: %w", err)
+		return "", fmt.Errorf("failed to read profile: %w", err)
 	}
 
 	if !found {
-		return nil // Integration not found, nothing to remove
+		return "", nil // Integration not found, nothing to remove
 	}
 
-	// Create backup
-	backupPath := profilePath + ".kubectx-timeout.backup"
-	// #nosec G304 -- profilePath is constructed from user home dir and known profile names, not user input
-	content, err := os.ReadFile(profilePath)
+	backupPath, err := BackupProfile(profilePath, keepBackups)
 	if err != nil {
-		return fmt.Errorf("failed to read profile for backup: %w", err)
-	}
-	// #nosec G703 -- backupPath is profilePath (from user home dir + known profile names) + fixed suffix, not user input
-	if err := os.WriteFile(backupPath, content, 0600); err != nil {
-		return fmt.Errorf("failed to create backup: %w", err)
+		return "", err
 	}
 
 	// Write new content
 	if err := os.WriteFile(profilePath, []byte(newContent.String()), 0600); err != nil {
-		return fmt.Errorf("failed to write profile: %w", err)
+		return backupPath, fmt.Errorf("failed to write profile: %w", err)
 	}
 
-	return nil
+	return backupPath, nil
 }
 
 // VerifyInstallation checks if the installation was successful
@@ -337,7 +1173,8 @@ func VerifyInstallation(profilePath string, binaryPath string) []string {
 	// Check if binary exists and is executable
 	if _, err := os.Stat(binaryPath); err != nil {
 		issues = append(issues, fmt.Sprintf("Binary not found at %s", binaryPath))
-	} else if info, err := os.Stat(binaryPath); err == nil {
+	} else if info, err := os.Stat(binaryPath); err == nil && runtime.GOOS != "windows" {
+		// Windows has no executable bit
 		if info.Mode().Perm()&0111 == 0 {
 			issues = append(issues, fmt.Sprintf("Binary at %s is not executable", binaryPath))
 		}
@@ -3,8 +3,10 @@ package internal
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDetectShell(t *testing.T) {
@@ -64,6 +66,8 @@ func TestIsValidShell(t *testing.T) {
 		{"bash", true},
 		{"zsh", true},
 		{"fish", true},
+		{"powershell", true},
+		{"pwsh", false},
 		{"sh", false},
 		{"ksh", false},
 		{"tcsh", false},
@@ -259,11 +263,433 @@ func TestGetShellIntegrationCode(t *testing.T) {
 				if !strings.Contains(code, "kubectl") {
 					t.Errorf("Code missing kubectl reference")
 				}
+				// helm is wrapped too
+				if !strings.Contains(code, "command helm") {
+					t.Errorf("Code missing helm wrapper")
+				}
+				// k9s keeps recording activity while it runs
+				if !strings.Contains(code, "command k9s") || !strings.Contains(code, "record-activity --while-pid") {
+					t.Errorf("Code missing k9s heartbeat wrapper")
+				}
+				// kubectx is wrapped so manual switches start the timer
+				if !strings.Contains(code, "command kubectx") {
+					t.Errorf("Code missing kubectx wrapper")
+				}
+			}
+		})
+	}
+}
+
+// TestKubectxWrapperRecordsActivityAfterSwitch tests that the kubectx wrapper
+// records activity AFTER the context switch completes, not before, so the NEW
+// context is captured rather than the old one.
+// This is a regression test for the race condition where record-activity ran
+// in parallel with kubectx and captured the context from before the switch.
+func TestKubectxWrapperRecordsActivityAfterSwitch(t *testing.T) {
+	for _, shell := range []string{ShellBash, ShellZsh, ShellFish} {
+		t.Run(shell, func(t *testing.T) {
+			code, err := GetShellIntegrationCode(shell, "/usr/local/bin/kubectx-timeout")
+			if err != nil {
+				t.Fatalf("GetShellIntegrationCode failed: %v", err)
+			}
+
+			// The kubectx wrapper runs until the next wrapper's comment
+			start := strings.Index(code, "# kubectx wrapper")
+			if start == -1 {
+				t.Fatal("kubectx wrapper not found in integration")
+			}
+			wrapper := code[start:]
+			if end := strings.Index(wrapper[1:], "\n# "); end != -1 {
+				wrapper = wrapper[:end+1]
+			}
+
+			cmdKubectxPos := strings.Index(wrapper, "command kubectx")
+			recordActivityPos := strings.Index(wrapper, "record-activity")
+			if cmdKubectxPos == -1 || recordActivityPos == -1 {
+				t.Fatalf("kubectx wrapper should run kubectx and record activity:\n%s", wrapper)
+			}
+
+			// This is the key test: record-activity must come AFTER command kubectx
+			if cmdKubectxPos > recordActivityPos {
+				t.Errorf("kubectx wrapper records activity (%d) before running kubectx (%d), "+
+					"so the old context would be recorded:\n%s", recordActivityPos, cmdKubectxPos, wrapper)
+			}
+
+			// The exit code of kubectx is preserved
+			if !strings.Contains(wrapper, "return $exit_code") {
+				t.Errorf("kubectx wrapper should preserve the exit code of kubectx:\n%s", wrapper)
 			}
 		})
 	}
 }
 
+func TestGetShellIntegrationCodeForCommands(t *testing.T) {
+	binaryPath := "/usr/local/bin/kubectx-timeout"
+	commands := []string{"kubectl", "stern", "kubens", "k9s"}
+
+	for _, shell := range []string{ShellBash, ShellZsh, ShellFish} {
+		t.Run(shell, func(t *testing.T) {
+			code, err := GetShellIntegrationCodeForCommands(shell, binaryPath, commands, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for _, command := range commands {
+				if !strings.Contains(code, "command "+command+" ") {
+					t.Errorf("Code missing %s wrapper", command)
+				}
+			}
+			// Only the configured commands are wrapped
+			if strings.Contains(code, "command helm") {
+				t.Error("Code wraps helm, which wasn't configured")
+			}
+			// Only long-running sessions get a heartbeat regardless of
+			// their arguments
+			if strings.Count(code, "record-activity --while-pid") != 1 {
+				t.Error("Expected an unconditional heartbeat for k9s only")
+			}
+			// Context switchers record activity after they succeed
+			kubens := code[strings.Index(code, "# kubens wrapper"):]
+			if strings.Index(kubens, "command kubens") > strings.Index(kubens, "record-activity") {
+				t.Error("kubens wrapper should record activity after running kubens")
+			}
+			if shell == ShellBash && !strings.Contains(code, "export -f _kubectx_timeout_stern") {
+				t.Error("Bash code should export the stern helper")
+			}
+		})
+	}
+
+	invalid := [][]string{
+		nil,
+		{"kubectl", "kubectl"},
+		{"kubectl; rm -rf ~"},
+		{"-x"},
+	}
+	for _, commands := range invalid {
+		if _, err := GetShellIntegrationCodeForCommands(ShellBash, binaryPath, commands, nil); err == nil {
+			t.Errorf("Expected an error for commands %q", commands)
+		}
+	}
+}
+
+func TestGetShellIntegrationCodeWithOptions(t *testing.T) {
+	binaryPath := "/usr/local/bin/kubectx-timeout"
+	commands := []string{"kubectl", "kubectx", "k9s"}
+
+	for _, shell := range SupportedShells {
+		t.Run(shell, func(t *testing.T) {
+			want := "prompt --left-below 10m"
+			if shell == ShellPowerShell {
+				want = "_KubectxTimeoutRemaining '10m'"
+			}
+
+			code, err := GetShellIntegrationCodeForCommands(shell, binaryPath, commands, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if strings.Contains(code, want) {
+				t.Error("Code prints the time left, which wasn't configured")
+			}
+
+			code, err = GetShellIntegrationCodeWithOptions(shell, binaryPath, commands, nil,
+				ShellIntegrationOptions{RemainingTime: 10 * time.Minute})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			// Only commands that run against the current context print it
+			if strings.Count(code, want) != 1 {
+				t.Errorf("Expected the kubectl wrapper alone to print the time left, got:\n%s", code)
+			}
+			kubectl := code[strings.Index(code, "# kubectl wrapper"):strings.Index(code, "# kubectx wrapper")]
+			if !strings.Contains(kubectl, want) {
+				t.Error("kubectl wrapper should print the time left")
+			}
+		})
+	}
+}
+
+func TestGetShellHookCode(t *testing.T) {
+	binaryPath := "/usr/local/bin/kubectx-timeout"
+
+	for _, shell := range []string{ShellBash, ShellZsh, ShellFish} {
+		t.Run(shell, func(t *testing.T) {
+			code, err := GetShellHookCode(shell, binaryPath, DefaultWrapCommands, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !strings.HasPrefix(code, IntegrationStartMarker) || !strings.Contains(code, IntegrationEndMarker) {
+				t.Error("Code missing integration markers")
+			}
+			if !strings.Contains(code, binaryPath) {
+				t.Error("Code missing binary path")
+			}
+			// Hook mode must not shadow the user's commands with functions
+			if strings.Contains(code, "kubectl()") || strings.Contains(code, "function kubectl") {
+				t.Error("Hook mode should not define a kubectl function")
+			}
+			if !strings.Contains(code, "kubectl kubectx kubens helm k9s") {
+				t.Error("Code missing the tracked commands")
+			}
+			if !strings.Contains(code, "--while-pid") {
+				t.Error("Code missing the session heartbeat")
+			}
+		})
+	}
+
+	hooks := map[string]string{
+		ShellBash: "preexec_functions+=(_kubectx_timeout_preexec)",
+		ShellZsh:  "add-zsh-hook preexec _kubectx_timeout_preexec",
+		ShellFish: "--on-event fish_preexec",
+	}
+	for shell, hook := range hooks {
+		code, _ := GetShellHookCode(shell, binaryPath, DefaultWrapCommands, nil)
+		if !strings.Contains(code, hook) {
+			t.Errorf("%s code missing hook registration %q", shell, hook)
+		}
+	}
+
+	if _, err := GetShellHookCode("tcsh", binaryPath, DefaultWrapCommands, nil); err == nil {
+		t.Error("Expected an error for an unsupported shell")
+	}
+	if _, err := GetShellHookCode(ShellBash, binaryPath, []string{"kubectl;"}, nil); err == nil {
+		t.Error("Expected an error for an invalid command")
+	}
+}
+
+func TestShellAliasWrappers(t *testing.T) {
+	binaryPath := "/usr/local/bin/kubectx-timeout"
+	aliases := []ShellAlias{{Name: "k", Command: "/opt/bin/kubectl"}, {Name: "kx", Command: "kubectx"}}
+
+	for _, shell := range []string{ShellBash, ShellZsh, ShellFish} {
+		t.Run(shell, func(t *testing.T) {
+			code, err := GetShellIntegrationCodeForCommands(shell, binaryPath, DefaultWrapCommands, aliases)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !strings.Contains(code, "# k wrapper (alias for /opt/bin/kubectl)") {
+				t.Error("Code missing the k alias wrapper")
+			}
+			if !strings.Contains(code, "command /opt/bin/kubectl ") {
+				t.Error("k wrapper should run the alias target")
+			}
+			// An alias for a context switcher records after it succeeds
+			kx := code[strings.Index(code, "# kx wrapper"):]
+			if strings.Index(kx, "command kubectx") > strings.Index(kx, "record-activity") {
+				t.Error("kx wrapper should record activity after running kubectx")
+			}
+			// bash and zsh aliases would shadow the wrapper
+			if shell != ShellFish && !strings.Contains(code, "unalias k 2>/dev/null") {
+				t.Error("Code should remove the k alias before defining its wrapper")
+			}
+		})
+	}
+
+	code, err := GetShellHookCode(ShellZsh, binaryPath, DefaultWrapCommands, aliases)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(code, `_kubectx_timeout_commands="kubectl kubectx kubens helm k9s k kx"`) ||
+		!strings.Contains(code, `_kubectx_timeout_switchers="kubectx kubens kx"`) {
+		t.Errorf("Hook code should track the aliases like their commands:\n%s", code)
+	}
+
+	invalid := [][]ShellAlias{
+		{{Name: "kubectl", Command: "kubectl"}},
+		{{Name: "k", Command: "kubectl"}, {Name: "k", Command: "kubectl"}},
+		{{Name: "k;", Command: "kubectl"}},
+		{{Name: "k", Command: "kubectl --context prod"}},
+	}
+	for _, aliases := range invalid {
+		if _, err := GetShellIntegrationCodeForCommands(ShellBash, binaryPath, DefaultWrapCommands, aliases); err == nil {
+			t.Errorf("Expected an error for aliases %+v", aliases)
+		}
+	}
+}
+
+func TestDetectShellAliases(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), ".zshrc")
+	content := `export PATH=$HOME/bin:$PATH
+alias k=kubectl
+alias kc='/usr/local/bin/kubectl'   # pinned version
+alias kx="kubectx"
+alias kgp='kubectl get pods'
+alias ll='ls -la'
+alias kubectl=kubecolor
+alias k kubectl
+alias kc=kubectl
+`
+	if err := os.WriteFile(profile, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	aliases, err := DetectShellAliases(profile, DefaultWrapCommands)
+	if err != nil {
+		t.Fatalf("DetectShellAliases failed: %v", err)
+	}
+
+	want := []ShellAlias{{Name: "k", Command: "kubectl"}, {Name: "kc", Command: "kubectl"}, {Name: "kx", Command: "kubectx"}}
+	if !slices.Equal(aliases, want) {
+		t.Errorf("DetectShellAliases() = %+v, want %+v", aliases, want)
+	}
+
+	aliases, err = DetectShellAliases(filepath.Join(t.TempDir(), "missing"), DefaultWrapCommands)
+	if err != nil || len(aliases) != 0 {
+		t.Errorf("Expected no aliases for a missing profile, got %+v, %v", aliases, err)
+	}
+}
+
+func TestPowerShellIntegration(t *testing.T) {
+	binaryPath := `C:\Program Files\kubectx-timeout\kubectx-timeout.exe`
+	aliases := []ShellAlias{{Name: "k", Command: "kubectl"}}
+
+	code, err := GetShellIntegrationCodeForCommands(ShellPowerShell, binaryPath, DefaultWrapCommands, aliases)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"else { 'C:\\Program Files\\kubectx-timeout\\kubectx-timeout.exe' }",
+		"function kubectl {",
+		"& (_KubectxTimeoutCommand 'kubectl') @args",
+		"_KubectxTimeoutRecord '--args' \"$args\" '--while-pid' $PID",
+		"function k9s {",
+		"Remove-Item -Path Alias:k -Force",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("Code missing %q:\n%s", want, code)
+		}
+	}
+	// kubectx records activity after it succeeds
+	kubectx := code[strings.Index(code, "# kubectx wrapper"):]
+	if strings.Index(kubectx, "_KubectxTimeoutCommand 'kubectx'") > strings.Index(kubectx, "if ($LASTEXITCODE -eq 0)") {
+		t.Error("kubectx wrapper should record activity after running kubectx")
+	}
+
+	// The profile dot-sources the integration file
+	integrationPath := `C:\Users\o'brien\AppData\Roaming\kubectx-timeout\integration.ps1`
+	source, err := GetIntegrationSourceCode(ShellPowerShell, integrationPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(source, `. 'C:\Users\o''brien\AppData\Roaming\kubectx-timeout\integration.ps1'`) {
+		t.Errorf("Source code should dot-source the quoted integration file:\n%s", source)
+	}
+	profile := filepath.Join(t.TempDir(), "Microsoft.PowerShell_profile.ps1")
+	if err := os.WriteFile(profile, []byte(source), 0600); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	if sourced, err := IsIntegrationFileSourced(profile, integrationPath); err != nil || !sourced {
+		t.Errorf("IsIntegrationFileSourced() = %v, %v, want true", sourced, err)
+	}
+
+	if _, err := GetShellHookCode(ShellPowerShell, binaryPath, DefaultWrapCommands, nil); err == nil {
+		t.Error("Expected an error for hook mode in PowerShell")
+	}
+	if _, err := GetCompletionScript(ShellPowerShell, "kubectx-timeout", nil); err == nil {
+		t.Error("Expected an error for PowerShell completion")
+	}
+}
+
+func TestDetectPowerShellAliases(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), "Microsoft.PowerShell_profile.ps1")
+	content := `Set-Alias k kubectl
+set-alias -Name kx -Value 'kubectx'
+New-Alias -Name kn kubens   # namespaces
+Set-Alias ll Get-ChildItem
+`
+	if err := os.WriteFile(profile, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	aliases, err := DetectShellAliases(profile, DefaultWrapCommands)
+	if err != nil {
+		t.Fatalf("DetectShellAliases failed: %v", err)
+	}
+
+	want := []ShellAlias{{Name: "k", Command: "kubectl"}, {Name: "kx", Command: "kubectx"}, {Name: "kn", Command: "kubens"}}
+	if !slices.Equal(aliases, want) {
+		t.Errorf("DetectShellAliases() = %+v, want %+v", aliases, want)
+	}
+}
+
+func TestParseShellAliases(t *testing.T) {
+	aliases, err := ParseShellAliases([]string{"k=kubectl", "kx=kubectx"})
+	if err != nil {
+		t.Fatalf("ParseShellAliases failed: %v", err)
+	}
+	want := []ShellAlias{{Name: "k", Command: "kubectl"}, {Name: "kx", Command: "kubectx"}}
+	if !slices.Equal(aliases, want) {
+		t.Errorf("ParseShellAliases() = %+v, want %+v", aliases, want)
+	}
+
+	for _, entries := range [][]string{{"k"}, {"k=kubectl", "k=kubectl"}, {"=kubectl"}, {"k=kubectl get"}} {
+		if _, err := ParseShellAliases(entries); err == nil {
+			t.Errorf("Expected an error for %q", entries)
+		}
+	}
+}
+
+func TestInstallIntegrationFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	profilePath := filepath.Join(tmpDir, ".zshrc")
+	integrationPath := filepath.Join(tmpDir, "config", "integration.zsh")
+
+	sourceCode, err := GetIntegrationSourceCode(ShellZsh, integrationPath)
+	if err != nil {
+		t.Fatalf("GetIntegrationSourceCode failed: %v", err)
+	}
+	if !strings.Contains(sourceCode, `source "`+integrationPath+`"`) {
+		t.Errorf("Source code should source the integration file:\n%s", sourceCode)
+	}
+	if _, err := GetIntegrationSourceCode("tcsh", integrationPath); err == nil {
+		t.Error("Expected an error for an unsupported shell")
+	}
+
+	// An inline integration from an older version doesn't source the file
+	if _, err := InstallIntegration(profilePath, IntegrationStartMarker+"\nkubectl() { :; }\n"+IntegrationEndMarker+"\n", 0); err != nil {
+		t.Fatalf("InstallIntegration failed: %v", err)
+	}
+	if sourced, err := IsIntegrationFileSourced(profilePath, integrationPath); err != nil || sourced {
+		t.Errorf("IsIntegrationFileSourced() = %v, %v for an inline integration", sourced, err)
+	}
+	if _, err := UninstallIntegration(profilePath, 0); err != nil {
+		t.Fatalf("UninstallIntegration failed: %v", err)
+	}
+
+	if err := InstallIntegrationFile(integrationPath, "# v1\n"); err != nil {
+		t.Fatalf("InstallIntegrationFile failed: %v", err)
+	}
+	if _, err := InstallIntegration(profilePath, sourceCode, 0); err != nil {
+		t.Fatalf("InstallIntegration failed: %v", err)
+	}
+	if sourced, err := IsIntegrationFileSourced(profilePath, integrationPath); err != nil || !sourced {
+		t.Errorf("IsIntegrationFileSourced() = %v, %v, want true", sourced, err)
+	}
+
+	// Upgrading only rewrites the file
+	profileBefore, _ := os.ReadFile(profilePath)
+	if err := InstallIntegrationFile(integrationPath, "# v2\n"); err != nil {
+		t.Fatalf("InstallIntegrationFile failed: %v", err)
+	}
+	if content, _ := os.ReadFile(integrationPath); string(content) != "# v2\n" {
+		t.Errorf("Integration file = %q, want the new version", content)
+	}
+	if profileAfter, _ := os.ReadFile(profilePath); string(profileAfter) != string(profileBefore) {
+		t.Error("Upgrading the integration file should leave the profile alone")
+	}
+
+	if err := RemoveIntegrationFile(integrationPath); err != nil {
+		t.Fatalf("RemoveIntegrationFile failed: %v", err)
+	}
+	if _, err := os.Stat(integrationPath); !os.IsNotExist(err) {
+		t.Error("Integration file should be removed")
+	}
+	if err := RemoveIntegrationFile(integrationPath); err != nil {
+		t.Errorf("Removing a missing integration file should succeed, got %v", err)
+	}
+}
+
 func TestInstallAndUninstallIntegration(t *testing.T) {
 	// Create a temporary directory for test
 	tmpDir, err := os.MkdirTemp("", "shell-test-*")
@@ -282,7 +708,7 @@ func TestInstallAndUninstallIntegration(t *testing.T) {
 			t.Fatalf("Failed to get integration code: %v", err)
 		}
 
-		err = InstallIntegration(profilePath, code)
+		_, err = InstallIntegration(profilePath, code, 0)
 		if err != nil {
 			t.Fatalf("Failed to install integration: %v", err)
 		}
@@ -319,7 +745,7 @@ func TestInstallAndUninstallIntegration(t *testing.T) {
 			t.Fatalf("Failed to get integration code: %v", err)
 		}
 
-		err = InstallIntegration(profilePath, code)
+		_, err = InstallIntegration(profilePath, code, 0)
 		if err == nil {
 			t.Errorf("Expected error for duplicate installation, got none")
 		}
@@ -330,7 +756,7 @@ func TestInstallAndUninstallIntegration(t *testing.T) {
 
 	// Test uninstallation
 	t.Run("uninstall integration", func(t *testing.T) {
-		err := UninstallIntegration(profilePath)
+		_, err := UninstallIntegration(profilePath, 0)
 		if err != nil {
 			t.Fatalf("Failed to uninstall integration: %v", err)
 		}
@@ -359,7 +785,7 @@ func TestInstallAndUninstallIntegration(t *testing.T) {
 
 	// Test uninstalling when not installed
 	t.Run("uninstall when not installed", func(t *testing.T) {
-		err := UninstallIntegration(profilePath)
+		_, err := UninstallIntegration(profilePath, 0)
 		if err != nil {
 			t.Errorf("Unexpected error when uninstalling non-installed integration: %v", err)
 		}
@@ -387,12 +813,15 @@ func TestInstallIntegrationCreatesBackup(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to get integration code: %v", err)
 	}
-	if err := InstallIntegration(profilePath, code); err != nil {
+	backupPath, err := InstallIntegration(profilePath, code, 0)
+	if err != nil {
 		t.Fatalf("Failed to install integration: %v", err)
 	}
 
 	// Verify backup was created with original content
-	backupPath := profilePath + ".kubectx-timeout.backup"
+	if !strings.HasPrefix(backupPath, profilePath+ProfileBackupSuffix+".") {
+		t.Errorf("Expected a timestamped backup of %s, got %q", profilePath, backupPath)
+	}
 	backupContent, err := os.ReadFile(backupPath)
 	if err != nil {
 		t.Fatalf("Backup file not created at %s: %v", backupPath, err)
@@ -423,7 +852,7 @@ func TestUninstallIntegrationCreatesBackup(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to get integration code: %v", err)
 	}
-	if err := InstallIntegration(profilePath, code); err != nil {
+	if _, err := InstallIntegration(profilePath, code, 0); err != nil {
 		t.Fatalf("Failed to install integration: %v", err)
 	}
 
@@ -434,12 +863,12 @@ func TestUninstallIntegrationCreatesBackup(t *testing.T) {
 	}
 
 	// Uninstall integration (should create backup of profile-with-integration)
-	if err := UninstallIntegration(profilePath); err != nil {
+	backupPath, err := UninstallIntegration(profilePath, 0)
+	if err != nil {
 		t.Fatalf("Failed to uninstall integration: %v", err)
 	}
 
 	// Verify backup was created with the content that existed before uninstall
-	backupPath := profilePath + ".kubectx-timeout.backup"
 	backupContent, err := os.ReadFile(backupPath)
 	if err != nil {
 		t.Fatalf("Backup file not created at %s: %v", backupPath, err)
@@ -473,7 +902,7 @@ func TestInstallIntegrationPreservesExistingContent(t *testing.T) {
 		t.Fatalf("Failed to get integration code: %v", err)
 	}
 
-	err = InstallIntegration(profilePath, code)
+	_, err = InstallIntegration(profilePath, code, 0)
 	if err != nil {
 		t.Fatalf("Failed to install integration: %v", err)
 	}
@@ -535,7 +964,7 @@ func TestVerifyInstallation(t *testing.T) {
 			t.Fatalf("Failed to get integration code: %v", err)
 		}
 
-		err = InstallIntegration(profilePath, code)
+		_, err = InstallIntegration(profilePath, code, 0)
 		if err != nil {
 			t.Fatalf("Failed to install integration: %v", err)
 		}
@@ -646,3 +1075,36 @@ func TestIsIntegrationInstalled(t *testing.T) {
 		}
 	})
 }
+
+func TestCredentialCommandWrappers(t *testing.T) {
+	binaryPath := "/usr/local/bin/kubectx-timeout"
+	commands := []string{"kubectl", "aws", "gcloud"}
+
+	for _, shell := range []string{ShellBash, ShellZsh, ShellFish} {
+		t.Run(shell, func(t *testing.T) {
+			code, err := GetShellIntegrationCodeForCommands(shell, binaryPath, commands, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// Cloud CLIs record after they succeed, and only when fetching
+			// credentials, which record-activity decides from the arguments
+			for _, command := range []string{"aws", "gcloud"} {
+				wrapper := code[strings.Index(code, "# "+command+" wrapper"):]
+				run := strings.Index(wrapper, "command "+command)
+				record := strings.Index(wrapper, "record-activity --credentials --args")
+				if run < 0 || record < run {
+					t.Errorf("%s wrapper should record credential activity after running %s:\n%s", command, command, wrapper)
+				}
+			}
+
+			hook, err := GetShellHookCode(shell, binaryPath, commands, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !strings.Contains(hook, "record-activity --credentials --args") {
+				t.Error("Hook code should record credential activity for aws and gcloud")
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"errors"
+	"time"
+)
+
+// Simulation is a hypothetical situation for SimulatePolicy to decide on
+type Simulation struct {
+	// Context is the context to pretend is current
+	Context string
+
+	// Idle is how long ago kubectl was last used in it
+	Idle time.Duration
+
+	// At is when to decide, which matters to work-hours schedules; the
+	// zero time means now
+	At time.Time
+
+	// ActiveProcesses are the Kubernetes tools to pretend are running. If
+	// nil, the running ones are looked up once a switch is due.
+	ActiveProcesses []string
+}
+
+// SimulatePolicy decides what the daemon would do if sim.Context had been
+// idle for sim.Idle, running the same policy as a timeout check, without
+// running kubectl or changing anything. Extensions and pauses come from
+// store, which may be nil to assume there are none. With a grace period,
+// the daemon is assumed to have started it when the timeout passed.
+func SimulatePolicy(config *Config, store StateStore, sim Simulation) (PolicyInputs, PolicyDecision, error) {
+	now := sim.At
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	simulated := &simulatedState{store: store, lastActivity: now.Add(-sim.Idle)}
+	in, err := GatherPolicyInputs(config, simulated, simulatedContext(sim.Context), now)
+	if err != nil {
+		return in, PolicyDecision{}, err
+	}
+
+	if in.GracePeriod > 0 && in.Idle() >= in.Timeout {
+		in.Pending = PendingSwitch{
+			From: in.CurrentContext,
+			To:   in.DefaultContext,
+			At:   in.LastActivity.Add(in.Timeout + in.GracePeriod),
+		}
+	}
+
+	// Like the daemon, only look for running tools once a switch is due
+	decision := EvaluatePolicy(in)
+	if !decision.Due() || !config.Safety.CheckActiveKubectl {
+		return in, decision, nil
+	}
+	in.ActiveProcesses = sim.ActiveProcesses
+	if sim.ActiveProcesses == nil {
+		processes, err := FindActiveKubeProcesses()
+		if err != nil {
+			return in, decision, err
+		}
+		for _, p := range processes {
+			if p.UsesContext(in.CurrentContext) {
+				in.ActiveProcesses = append(in.ActiveProcesses, p.String())
+			}
+		}
+	}
+	return in, EvaluatePolicy(in), nil
+}
+
+// simulatedState is a state store whose last activity is made up, with
+// extensions and pauses read from a real store if there is one. A pending
+// switch belongs to the real context, so there is none.
+type simulatedState struct {
+	store        StateStore
+	lastActivity time.Time
+}
+
+func (s *simulatedState) Load() (*State, error) {
+	simulated := &State{LastActivity: s.lastActivity}
+	if s.store == nil {
+		return simulated, nil
+	}
+
+	state, err := s.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	simulated.ExtendedUntil = state.ExtendedUntil
+	simulated.PausedContexts = state.PausedContexts
+	return simulated, nil
+}
+
+func (s *simulatedState) Save(*State) error {
+	return errors.New("a simulation doesn't change the state")
+}
+
+func (s *simulatedState) Update(func(*State) error) error {
+	return errors.New("a simulation doesn't change the state")
+}
+
+// simulatedContext is a switcher stuck on one context
+type simulatedContext string
+
+func (c simulatedContext) CurrentContext() (string, error) {
+	return string(c), nil
+}
+
+func (c simulatedContext) SwitchContextSafe(string, func(string) bool) error {
+	return errors.New("a simulation doesn't switch contexts")
+}
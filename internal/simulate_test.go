@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulatePolicy(t *testing.T) {
+	config := DefaultConfig()
+	config.DefaultContext = "local"
+	config.Contexts = map[string]Context{"prod": {Timeout: 15 * time.Minute}}
+	config.Safety.NeverSwitchFrom = []string{"dev-*"}
+	config.Safety.MaxDefer = 0
+
+	store := &fakeStateStore{}
+	if _, err := PauseContext(store, "staging", time.Hour); err != nil {
+		t.Fatalf("PauseContext() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		sim       Simulation
+		want      string
+		reasonHas string
+	}{
+		{"counting down", Simulation{Context: "prod", Idle: 10 * time.Minute, ActiveProcesses: []string{}}, PolicyActionWait, "timeout for 'prod' is 15m0s"},
+		{"timed out", Simulation{Context: "prod", Idle: 42 * time.Minute, ActiveProcesses: []string{}}, PolicyActionSwitch, "switching to the default context 'local'"},
+		{"tools running", Simulation{Context: "prod", Idle: 42 * time.Minute, ActiveProcesses: []string{"kubectl (pid 42)"}}, PolicyActionDefer, "kubectl (pid 42)"},
+		{"never_switch_from", Simulation{Context: "dev-1", Idle: 42 * time.Hour}, PolicyActionNone, "never_switch_from"},
+		{"paused", Simulation{Context: "staging", Idle: 42 * time.Hour}, PolicyActionNone, "paused until"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, decision, err := SimulatePolicy(config, store, tt.sim)
+			if err != nil {
+				t.Fatalf("SimulatePolicy() error = %v", err)
+			}
+			if decision.Action != tt.want || !strings.Contains(strings.Join(decision.Reasons, "\n"), tt.reasonHas) {
+				t.Errorf("SimulatePolicy() = %s %q, want %s mentioning %q", decision.Action, decision.Reasons, tt.want, tt.reasonHas)
+			}
+		})
+	}
+
+	// The grace period is assumed to have started when the timeout passed
+	config.Timeout.GracePeriod = 5 * time.Minute
+	for idle, want := range map[time.Duration]string{
+		17 * time.Minute: PolicyActionGrace,
+		21 * time.Minute: PolicyActionSwitch,
+	} {
+		_, decision, err := SimulatePolicy(config, nil, Simulation{Context: "prod", Idle: idle, ActiveProcesses: []string{}})
+		if err != nil {
+			t.Fatalf("SimulatePolicy() error = %v", err)
+		}
+		if decision.Action != want {
+			t.Errorf("After %v idle with a 5m grace period, action = %s, want %s", idle, decision.Action, want)
+		}
+	}
+
+	if last, _, _ := LastActivity(store); !last.IsZero() {
+		t.Errorf("Expected the simulation to leave the state alone, got activity at %v", last)
+	}
+}
@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/user"
+)
+
+// slackPostMessageURL is the Slack Web API method that posts a message
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// SlackConfig holds the bot token and channel for Slack notifications. The
+// token is best set with the KUBECTX_TIMEOUT_NOTIFICATIONS_SLACK_TOKEN
+// environment variable rather than in the config file.
+type SlackConfig struct {
+	Token   string `yaml:"token,omitempty"`
+	Channel string `yaml:"channel,omitempty"`
+}
+
+// Enabled reports whether Slack notifications are configured
+func (s SlackConfig) Enabled() bool {
+	return s.Token != "" && s.Channel != ""
+}
+
+// validationErrors returns every problem with the Slack settings
+func (s SlackConfig) validationErrors() []error {
+	if (s.Token == "") != (s.Channel == "") {
+		return []error{fmt.Errorf("notifications.slack needs both token and channel")}
+	}
+	return nil
+}
+
+// slackMessage is the chat.postMessage request body
+type slackMessage struct {
+	Channel string `json:"channel"`
+	Text    string `json:"text"`
+}
+
+// slackResponse is the part of a Web API response that reports failures,
+// which Slack sends with a 200 status
+type slackResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// FormatSlackMessage describes a switch for a platform team's channel: who
+// was switched, on which machine, which context was reset, and why
+func FormatSlackMessage(payload WebhookPayload) string {
+	who := "unknown user"
+	if u, err := user.Current(); err == nil {
+		who = u.Username
+	}
+	return fmt.Sprintf(":lock: kubectx-timeout switched *%s* on `%s` from `%s` to `%s` (%s)",
+		who, payload.Host, payload.FromContext, payload.ToContext, payload.Reason)
+}
+
+// postSlack posts a switch to the configured Slack channel, retrying like
+// webhooks do
+func (n *Notifier) postSlack(payload WebhookPayload) error {
+	slack := n.config.Slack
+	if !slack.Enabled() {
+		return nil
+	}
+
+	body, err := json.Marshal(slackMessage{Channel: slack.Channel, Text: FormatSlackMessage(payload)})
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack message: %w", err)
+	}
+
+	err = n.withRetries(func() (bool, error) {
+		retry, respBody, err := postJSON(n.slackAPIURL, body, slack.Token, n.webhookTimeout())
+		if err != nil {
+			return retry, err
+		}
+
+		var resp slackResponse
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			return false, fmt.Errorf("unexpected response: %w", err)
+		}
+		if !resp.OK {
+			return resp.Error == "ratelimited", fmt.Errorf("slack API error: %s", resp.Error)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to post to Slack channel %s: %w", slack.Channel, err)
+	}
+	return nil
+}
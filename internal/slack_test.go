@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSlackNotifier returns a notifier that only posts to a fake Slack API
+func newSlackNotifier(apiURL string, retries int) *Notifier {
+	n := NewNotifier(NotificationConfig{
+		Slack:    SlackConfig{Token: "xoxb-test", Channel: "#platform"},
+		Webhooks: WebhookConfig{Timeout: time.Second, Retries: retries},
+	})
+	n.webhookRetryDelay = time.Millisecond
+	n.slackAPIURL = apiURL
+	return n
+}
+
+func TestSlackPostMessage(t *testing.T) {
+	received := make(chan slackMessage, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer xoxb-test" {
+			t.Errorf("Authorization = %q, want the bot token", got)
+		}
+		var msg slackMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("Failed to decode message: %v", err)
+		}
+		received <- msg
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	event := SwitchEvent{FromContext: "prod", ToContext: "local", Reason: "inactive for 30m0s"}
+	if err := newSlackNotifier(server.URL, 0).NotifySwitch(event); err != nil {
+		t.Fatalf("NotifySwitch() error = %v", err)
+	}
+
+	msg := <-received
+	if msg.Channel != "#platform" {
+		t.Errorf("Channel = %q, want #platform", msg.Channel)
+	}
+	for _, want := range []string{"`prod`", "`local`", "inactive for 30m0s", "`" + hostnameForTest(t) + "`"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("Message %q should contain %q", msg.Text, want)
+		}
+	}
+}
+
+func TestSlackAPIError(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		// Slack reports failures with a 200 status
+		_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+	}))
+	defer server.Close()
+
+	err := newSlackNotifier(server.URL, 2).NotifySwitch(SwitchEvent{})
+	if err == nil || !strings.Contains(err.Error(), "channel_not_found") {
+		t.Fatalf("NotifySwitch() error = %v, want channel_not_found", err)
+	}
+	if strings.Contains(err.Error(), "xoxb-test") {
+		t.Errorf("Error should not include the token: %v", err)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("Attempts = %d, want no retries for a permanent error", got)
+	}
+}
+
+func TestSlackValidation(t *testing.T) {
+	if errs := (SlackConfig{Token: "xoxb-test"}).validationErrors(); len(errs) != 1 {
+		t.Errorf("validationErrors() = %v, want a missing channel reported", errs)
+	}
+	if errs := (SlackConfig{}).validationErrors(); len(errs) != 0 {
+		t.Errorf("validationErrors() = %v, want none when unset", errs)
+	}
+}
+
+func hostnameForTest(t *testing.T) string {
+	t.Helper()
+	return NewWebhookPayload(SwitchEvent{}, "", time.Now()).Host
+}
@@ -0,0 +1,95 @@
+package internal
+
+import "time"
+
+// Policies for timeout.on_wake
+const (
+	// OnWakeReset restarts the timeout, as if kubectl had just been used
+	OnWakeReset = "reset"
+	// OnWakeSwitch switches to the default context right away
+	OnWakeSwitch = "switch"
+	// OnWakeEvaluate checks the timeout right away, counting the time asleep
+	OnWakeEvaluate = "evaluate"
+)
+
+// WakeReason is the switch reason recorded when on_wake switches
+const WakeReason = "waking from sleep"
+
+const (
+	// sleepPollInterval is how often the clocks are compared to detect sleep
+	sleepPollInterval = 5 * time.Second
+
+	// sleepThreshold is how far the wall clock must run ahead of the
+	// monotonic clock to count as sleep rather than scheduling jitter
+	sleepThreshold = 30 * time.Second
+)
+
+// timeAsleep returns how long the system slept between two readings of
+// time.Now. The monotonic clock stops while macOS and Linux are suspended
+// and the wall clock doesn't, so the difference is the time spent asleep.
+func timeAsleep(before, after time.Time) time.Duration {
+	return after.Round(0).Sub(before.Round(0)) - after.Sub(before)
+}
+
+// watchSleep detects the system waking from sleep and applies the on_wake
+// policy. It runs until the daemon stops.
+func (d *Daemon) watchSleep() {
+	ticker := time.NewTicker(sleepPollInterval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		if asleep := timeAsleep(last, now); asleep > sleepThreshold {
+			d.logger.Info("System woke from sleep", "asleep", asleep.Round(time.Second))
+			d.handleWake()
+		}
+		last = now
+	}
+}
+
+// handleWake applies the on_wake policy after the system wakes, unless
+// shutdown has begun
+func (d *Daemon) handleWake() {
+	d.checkMu.Lock()
+	defer d.checkMu.Unlock()
+
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	switch d.currentConfig().Timeout.OnWake {
+	case OnWakeReset:
+		currentContext, err := d.switcher.CurrentContext()
+		if err != nil {
+			d.logger.Warn("Failed to reset the timeout after waking", "error", err)
+			return
+		}
+		if err := RecordActivity(d.stateManager, currentContext); err != nil {
+			d.logger.Warn("Failed to reset the timeout after waking", "context", currentContext, "error", err)
+			return
+		}
+		d.clearPendingSwitch()
+		d.logger.Info("Timeout reset after waking", "context", currentContext)
+
+	case OnWakeSwitch:
+		if err := d.switchWithoutTimeout(WakeReason); err != nil {
+			d.logger.Error("Switching failed", "reason", WakeReason, "error", err)
+		}
+
+	default:
+		// Don't wait for the next tick: the timeout may have passed while asleep
+		d.handleCheckResult(d.checkTimeout())
+	}
+
+	d.publishStatusSummary(false)
+
+	// The main loop's timer didn't run while asleep, so let it reschedule
+	d.requestCheck()
+}
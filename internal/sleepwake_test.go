@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTimeAsleep(t *testing.T) {
+	before := time.Now()
+	if got := timeAsleep(before, before.Add(time.Minute)); got != 0 {
+		t.Errorf("timeAsleep() = %v, want 0 when both clocks advance together", got)
+	}
+}
+
+// setOnWake rewrites the fake daemon's config with the given on_wake policy
+func setOnWake(t *testing.T, d *Daemon, policy string) {
+	t.Helper()
+	configContent := `
+timeout:
+  default: 10m
+  check_interval: 1s
+  on_wake: ` + policy + `
+default_context: local
+notifications:
+  enabled: false
+safety:
+  check_active_kubectl: false
+  validate_default_context: false
+`
+	if err := os.WriteFile(d.configPath, []byte(configContent), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if err := d.ReloadConfig(); err != nil {
+		t.Fatalf("ReloadConfig() error = %v", err)
+	}
+}
+
+func TestDaemonHandleWake(t *testing.T) {
+	// Activity from before a long sleep
+	stale := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		policy       string
+		lastActivity time.Time
+		wantSwitches int
+		wantReset    bool
+	}{
+		{policy: OnWakeReset, lastActivity: stale, wantSwitches: 0, wantReset: true},
+		{policy: OnWakeEvaluate, lastActivity: stale, wantSwitches: 1},
+		{policy: OnWakeEvaluate, lastActivity: time.Now(), wantSwitches: 0},
+		{policy: OnWakeSwitch, lastActivity: time.Now(), wantSwitches: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			switcher := &fakeSwitcher{current: "production"}
+			store := &fakeStateStore{}
+			d := newFakeDaemon(t, switcher, store)
+			setOnWake(t, d, tt.policy)
+
+			if err := store.Save(&State{LastActivity: tt.lastActivity, CurrentContext: "production"}); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			d.handleWake()
+
+			if len(switcher.switches) != tt.wantSwitches {
+				t.Errorf("Expected %d switches, got %v", tt.wantSwitches, switcher.switches)
+			}
+			lastActivity, _, _ := LastActivity(store)
+			if reset := lastActivity.After(tt.lastActivity); tt.wantReset && !reset {
+				t.Errorf("Expected the timeout to be reset, last activity is %v", lastActivity)
+			}
+		})
+	}
+}
+
+func TestValidateOnWake(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DefaultContext = "local"
+	if cfg.Timeout.OnWake != OnWakeEvaluate {
+		t.Errorf("Default on_wake = %q, want %q", cfg.Timeout.OnWake, OnWakeEvaluate)
+	}
+
+	cfg.Timeout.OnWake = "snooze"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() should reject an unknown on_wake policy")
+	}
+}
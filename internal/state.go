@@ -2,6 +2,7 @@ package internal
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,6 +18,53 @@ type State struct {
 	// CurrentContext is the current kubectl context at time of last activity
 	CurrentContext string `json:"current_context"`
 
+	// LastWriteActivity is when a kubectl command that changes the cluster,
+	// such as apply or delete, was last run. Zero if none was recorded.
+	LastWriteActivity time.Time `json:"last_write_activity"`
+
+	// CurrentNamespace is CurrentContext's namespace at the last activity
+	// recorded by the shell integration, or empty if unknown
+	CurrentNamespace string `json:"current_namespace,omitempty"`
+
+	// ExtendedUntil suppresses timeout switching until this time, regardless
+	// of LastActivity. Set by the extend command.
+	ExtendedUntil time.Time `json:"extended_until"`
+
+	// WatcherMode is how the daemon is monitoring the kubeconfig ("native" or
+	// "polling"), and WatcherStartedAt when that monitoring began. Empty if
+	// the watcher has not started.
+	WatcherMode      string    `json:"watcher_mode,omitempty"`
+	WatcherStartedAt time.Time `json:"watcher_started_at"`
+
+	// PendingSwitchFrom and PendingSwitchTo describe a timeout switch waiting
+	// out the grace period. It happens at PendingSwitchAt unless canceled
+	// with the cancel-switch command. Empty if no switch is pending.
+	PendingSwitchFrom string    `json:"pending_switch_from,omitempty"`
+	PendingSwitchTo   string    `json:"pending_switch_to,omitempty"`
+	PendingSwitchAt   time.Time `json:"pending_switch_at"`
+
+	// LastSwitchFrom and LastSwitchTo describe the daemon's last automatic
+	// switch, made at LastSwitchAt, which the undo command reverses. Empty
+	// once it has been undone.
+	LastSwitchFrom string    `json:"last_switch_from,omitempty"`
+	LastSwitchTo   string    `json:"last_switch_to,omitempty"`
+	LastSwitchAt   time.Time `json:"last_switch_at"`
+
+	// Profile names the profile chosen with the profile command, which
+	// applies until ProfileUntil, or until cleared if that is zero
+	Profile      string    `json:"profile,omitempty"`
+	ProfileUntil time.Time `json:"profile_until"`
+
+	// PausedContexts maps context names to the time until which timeout
+	// switching away from them is suppressed. Set by the pause-context
+	// command; entries are ignored once they expire and pruned on write.
+	PausedContexts map[string]time.Time `json:"paused_contexts,omitempty"`
+
+	// Sessions maps the KUBECONFIG of shells using their own kubeconfig,
+	// such as those kubie and kubeswitch start, to their activity. The
+	// daemon times out each of them separately.
+	Sessions map[string]KubeconfigSession `json:"sessions,omitempty"`
+
 	// Version is the state file format version for future compatibility
 	Version int `json:"version"`
 
@@ -25,10 +73,98 @@ type State struct {
 
 const stateVersion = 1
 
+// activityCoalesceWindow is how far activity in an unchanged context may
+// advance before it is written. A burst of commands rewrites the state file
+// once rather than per command; a couple of seconds makes no difference to
+// a timeout measured in minutes.
+const activityCoalesceWindow = 2 * time.Second
+
+// StateStore persists kubectl activity for the daemon and the kubeconfig
+// watcher. StateManager implements it with a JSON file; tests and embedders
+// can substitute their own to simulate unreadable or corrupted state. The
+// functions that record activity, extensions, pauses, and switches work on
+// any StateStore through Update.
+type StateStore interface {
+	// Load returns the stored state, or an empty State if none is stored
+	Load() (*State, error)
+	// Save replaces the stored state
+	Save(state *State) error
+	// Update loads the state, applies fn, and saves the result unless fn
+	// returns an error, with no other update in between. If fn returns
+	// ErrStateUnchanged, nothing is saved and Update returns nil.
+	Update(fn func(state *State) error) error
+}
+
+// PendingSwitch is a timeout switch waiting out the grace period
+type PendingSwitch struct {
+	From string
+	To   string
+	At   time.Time // When the switch happens unless canceled
+}
+
+// IsZero reports whether no switch is pending
+func (p PendingSwitch) IsZero() bool {
+	return p.To == ""
+}
+
+// LastSwitch is the daemon's last automatic switch, which undo reverses
+type LastSwitch struct {
+	From string
+	To   string
+	At   time.Time
+}
+
+// IsZero reports whether there is no switch to undo
+func (l LastSwitch) IsZero() bool {
+	return l.From == ""
+}
+
+// ErrNothingToUndo is returned by undo when there is no switch it may
+// reverse: none was made, it was undone already, or the undo window passed
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// CheckUndo returns an error wrapping ErrNothingToUndo unless the switch can
+// still be undone at now, within window of it. A zero window turns undo off.
+func (l LastSwitch) CheckUndo(window time.Duration, now time.Time) error {
+	switch {
+	case window <= 0:
+		return fmt.Errorf("%w: undo is turned off (timeout.undo_window is 0)", ErrNothingToUndo)
+	case l.IsZero():
+		return fmt.Errorf("%w: no automatic switch to undo", ErrNothingToUndo)
+	case now.Sub(l.At) > window:
+		return fmt.Errorf("%w: the switch from '%s' was %s ago, longer than the %s undo window",
+			ErrNothingToUndo, l.From, now.Sub(l.At).Round(time.Second), window)
+	}
+	return nil
+}
+
 // StateManager handles reading and writing state to disk
 type StateManager struct {
 	path string
 	mu   sync.Mutex
+
+	// activity is the last activity read from the file, which
+	// GetLastActivity reuses while the file is unchanged
+	activity activityCache
+
+	// cipher encrypts the file, if state.encrypt is on
+	cipher *stateCipher
+}
+
+// activityCache is the last activity in a state file as of the file's
+// identity, modification time, and size
+type activityCache struct {
+	file         os.FileInfo
+	lastActivity time.Time
+	context      string
+}
+
+// matches reports whether the cache was read from the file described by
+// info. Every save replaces the file, so a write within the file system's
+// timestamp granularity still changes its identity or size in practice.
+func (c activityCache) matches(info os.FileInfo) bool {
+	return c.file != nil && os.SameFile(c.file, info) &&
+		c.file.ModTime().Equal(info.ModTime()) && c.file.Size() == info.Size()
 }
 
 // NewStateManager creates a new state manager
@@ -51,12 +187,31 @@ func NewStateManager(path string) (*StateManager, error) {
 	return &StateManager{path: path}, nil
 }
 
+// SetEncryptionKey makes the state manager encrypt the state file with a
+// key derived from key, the secret LoadStateKey returns, from now on. Plain
+// state written earlier is still read, and encrypted on the next write.
+func (sm *StateManager) SetEncryptionKey(key []byte) error {
+	c, err := newStateCipherIn(filepath.Dir(sm.path), key)
+	if err != nil {
+		return err
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.cipher = c
+	return nil
+}
+
 // Load reads the current state from disk
 // If the file doesn't exist, returns a new empty state
 func (sm *StateManager) Load() (*State, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	return sm.load()
+}
+
+// load reads the state file. The caller must hold sm.mu.
+func (sm *StateManager) load() (*State, error) {
 	// Check if file exists
 	if _, err := os.Stat(sm.path); os.IsNotExist(err) {
 		// Return empty state
@@ -70,6 +225,9 @@ func (sm *StateManager) Load() (*State, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read state file: %w", err)
 	}
+	if data, err = sm.cipher.open(data); err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
 
 	// Parse JSON
 	var state State
@@ -85,11 +243,50 @@ func (sm *StateManager) Load() (*State, error) {
 	return &state, nil
 }
 
-// Save writes the state to disk
+// Save writes the state to disk, replacing whatever is there. Changes to
+// the state that depend on what it held go through Update instead.
 func (sm *StateManager) Save(state *State) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	return sm.save(state)
+}
+
+// ErrStateUnchanged is returned by a function passed to Update to leave the
+// state file as it is. Update then returns nil.
+var ErrStateUnchanged = errors.New("state unchanged")
+
+// Update loads the state, applies fn to it, and saves the result, with no
+// other update in between: not from this process, which sm.mu keeps out,
+// nor from the shell integration, the CLI, or a daemon in another, which
+// write the same file and are kept out by a lock on the state directory.
+func (sm *StateManager) Update(fn func(state *State) error) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	unlock, err := lockDir(filepath.Dir(sm.path))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := sm.load()
+	if err != nil {
+		return fmt.Errorf("failed to load state: %w", err)
+	}
+	if err := fn(state); errors.Is(err, ErrStateUnchanged) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := sm.save(state); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// save writes the state file. The caller must hold sm.mu.
+func (sm *StateManager) save(state *State) error {
 	state.mu.Lock()
 	defer state.mu.Unlock()
 
@@ -101,45 +298,165 @@ func (sm *StateManager) Save(state *State) error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
+	if data, err = sm.cipher.seal(data); err != nil {
+		return fmt.Errorf("failed to encrypt state: %w", err)
+	}
+
+	// Write to a temporary file of this save's own, then rename it over the
+	// state file. The shell integration, the CLI, and the daemon all save
+	// the state, so a shared temporary file could be written by two at once.
+	tmp, err := os.CreateTemp(filepath.Dir(sm.path), "."+filepath.Base(sm.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
 
-	// Write to temporary file first, then rename for atomic operation
-	tmpPath := sm.path + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+	if err := writeFileSync(tmpPath, data, 0600); err != nil {
+		_ = os.Remove(tmpPath) // Don't leave a partial write behind
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
 
 	// Atomic rename
 	if err := os.Rename(tmpPath, sm.path); err != nil {
+		_ = os.Remove(tmpPath) // Don't leave a partial write behind
 		return fmt.Errorf("failed to rename state file: %w", err)
 	}
 
 	return nil
 }
 
-// RecordActivity updates the state with current activity
-func (sm *StateManager) RecordActivity(context string) error {
-	// Load current state
-	state, err := sm.Load()
+// writeFileSync writes data to a file and flushes it to disk before closing,
+// so a subsequent rename never exposes a partially written file
+func writeFileSync(path string, data []byte, perm os.FileMode) error {
+	// #nosec G304 -- path is derived from the state file path
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
-		return fmt.Errorf("failed to load state: %w", err)
+		return err
 	}
 
-	// Update state
-	state.mu.Lock()
-	state.LastActivity = time.Now()
-	state.CurrentContext = context
-	state.mu.Unlock()
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
 
-	// Save state
-	if err := sm.Save(state); err != nil {
-		return fmt.Errorf("failed to save state: %w", err)
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
 	}
 
-	return nil
+	return f.Close()
+}
+
+// Activity is what the shell integration knows about one kubectl command
+type Activity struct {
+	Context string
+
+	// Namespace is the context's namespace. Empty keeps the one recorded
+	// for the same context.
+	Namespace string
+
+	// Write is set for commands that change the cluster, which
+	// timeout.write_commands keeps alive longer
+	Write bool
+
+	// At is when the activity happened; zero means now
+	At time.Time
+}
+
+// RecordActivity records activity in context now in the store. The recorded
+// namespace is kept if the context hasn't changed.
+func RecordActivity(store StateStore, context string) error {
+	return RecordActivities(store, []Activity{{Context: context}})
+}
+
+// RecordActivities applies several activities in order with a single write
+// of the store, as the daemon does with activity sent to its socket
+func RecordActivities(store StateStore, activities []Activity) error {
+	if len(activities) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	return store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if state.recordActivities(activities, now) {
+			return ErrStateUnchanged
+		}
+		return nil
+	})
+}
+
+// recordActivities applies activities to the state in order, with zero
+// times standing for now. It reports whether the result differs so little
+// from what was recorded that the state file needn't be written. The caller
+// must hold state.mu.
+func (s *State) recordActivities(activities []Activity, now time.Time) bool {
+	prevContext, prevNamespace := s.CurrentContext, s.CurrentNamespace
+	prevActivity, prevWrite := s.LastActivity, s.LastWriteActivity
+	for _, activity := range activities {
+		at := activity.At
+		if at.IsZero() {
+			at = now
+		}
+		namespace := activity.Namespace
+		if namespace == "" && s.CurrentContext == activity.Context {
+			namespace = s.CurrentNamespace
+		}
+		s.LastActivity = at
+		s.CurrentContext = activity.Context
+		s.CurrentNamespace = namespace
+		if activity.Write {
+			s.LastWriteActivity = at
+		}
+	}
+	return s.CurrentContext == prevContext && s.CurrentNamespace == prevNamespace &&
+		withinCoalesceWindow(prevActivity, s.LastActivity) &&
+		withinCoalesceWindow(prevWrite, s.LastWriteActivity)
+}
+
+// withinCoalesceWindow reports whether activity recorded at prev may stand
+// for activity at next without rewriting the state file
+func withinCoalesceWindow(prev, next time.Time) bool {
+	if prev.IsZero() {
+		return next.IsZero()
+	}
+	d := next.Sub(prev)
+	return d >= 0 && d < activityCoalesceWindow
 }
 
-// GetLastActivity returns the timestamp of the last kubectl activity
-func (sm *StateManager) GetLastActivity() (time.Time, string, error) {
+// LastActivity returns the time and context of the last kubectl activity in
+// the store
+func LastActivity(store StateStore) (time.Time, string, error) {
+	if sm, ok := store.(*StateManager); ok {
+		return sm.lastActivity()
+	}
+
+	state, err := store.Load()
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+
+	return state.LastActivity, state.CurrentContext, nil
+}
+
+// lastActivity returns the last activity in the state file. The daemon asks
+// on every check, so an unchanged file isn't reread.
+func (sm *StateManager) lastActivity() (time.Time, string, error) {
+	info, statErr := os.Stat(sm.path)
+	if statErr == nil {
+		sm.mu.Lock()
+		cache := sm.activity
+		sm.mu.Unlock()
+		if cache.matches(info) {
+			return cache.lastActivity, cache.context, nil
+		}
+	}
+
 	state, err := sm.Load()
 	if err != nil {
 		return time.Time{}, "", err
@@ -148,20 +465,344 @@ func (sm *StateManager) GetLastActivity() (time.Time, string, error) {
 	state.mu.RLock()
 	defer state.mu.RUnlock()
 
+	// A write since the Stat only makes the cache miss next time
+	if statErr == nil {
+		sm.mu.Lock()
+		sm.activity = activityCache{file: info, lastActivity: state.LastActivity, context: state.CurrentContext}
+		sm.mu.Unlock()
+	}
+
 	return state.LastActivity, state.CurrentContext, nil
 }
 
-// TimeSinceLastActivity returns the duration since last activity
-func (sm *StateManager) TimeSinceLastActivity() (time.Duration, error) {
-	lastActivity, _, err := sm.GetLastActivity()
+// ExtendDeadline suppresses timeout switching for the given duration from
+// now. It returns the time until which switching is suppressed.
+func ExtendDeadline(store StateStore, d time.Duration) (time.Time, error) {
+	if d <= 0 {
+		return time.Time{}, fmt.Errorf("extension must be positive")
+	}
+
+	until := time.Now().Add(d)
+	err := store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		state.ExtendedUntil = until
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return until, nil
+}
+
+// ClearExtension ends a deadline extension early. It reports whether an
+// extension was in effect, and does not write the store if none was
+// recorded.
+func ClearExtension(store StateStore) (bool, error) {
+	var until time.Time
+	err := store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		until = state.ExtendedUntil
+		if until.IsZero() {
+			return ErrStateUnchanged
+		}
+		state.ExtendedUntil = time.Time{}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return time.Now().Before(until), nil
+}
+
+// SetWatcherStatus records how the daemon's kubeconfig watcher is monitoring
+// for changes, marking it as started now
+func SetWatcherStatus(store StateStore, mode string) error {
+	return store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		state.WatcherMode = mode
+		state.WatcherStartedAt = time.Now()
+		return nil
+	})
+}
+
+// WatcherStatus returns how the daemon's kubeconfig watcher is monitoring
+// for changes and when it started
+func (s *State) WatcherStatus() (string, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.WatcherMode, s.WatcherStartedAt
+}
+
+// PendingSwitch returns the switch waiting out the grace period, if any
+func (s *State) PendingSwitch() PendingSwitch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return PendingSwitch{
+		From: s.PendingSwitchFrom,
+		To:   s.PendingSwitchTo,
+		At:   s.PendingSwitchAt,
+	}
+}
+
+// SetPendingSwitch records a switch waiting out the grace period
+func SetPendingSwitch(store StateStore, pending PendingSwitch) error {
+	return store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		state.PendingSwitchFrom = pending.From
+		state.PendingSwitchTo = pending.To
+		state.PendingSwitchAt = pending.At
+		return nil
+	})
+}
+
+// ClearPendingSwitch forgets the pending switch. It does not write the
+// store if no switch is pending.
+func ClearPendingSwitch(store StateStore) error {
+	_, err := takePendingSwitch(store, false)
+	return err
+}
+
+// CancelPendingSwitch aborts the pending switch and resets the activity timer
+// for the context it would have switched away from, in a single write. It
+// returns the canceled switch, or a zero PendingSwitch if none was pending.
+func CancelPendingSwitch(store StateStore) (PendingSwitch, error) {
+	return takePendingSwitch(store, true)
+}
+
+// takePendingSwitch forgets the pending switch and returns it, recording
+// activity now in the context it would have switched away from if
+// resetTimer is set
+func takePendingSwitch(store StateStore, resetTimer bool) (PendingSwitch, error) {
+	var pending PendingSwitch
+	err := store.Update(func(state *State) error {
+		pending = state.PendingSwitch()
+
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if pending.IsZero() {
+			return ErrStateUnchanged
+		}
+		state.PendingSwitchFrom = ""
+		state.PendingSwitchTo = ""
+		state.PendingSwitchAt = time.Time{}
+		if resetTimer {
+			state.LastActivity = time.Now()
+			state.CurrentContext = pending.From
+		}
+		return nil
+	})
 	if err != nil {
-		return 0, err
+		return PendingSwitch{}, err
 	}
 
-	// If no activity recorded yet, return a large duration
-	if lastActivity.IsZero() {
-		return 24 * time.Hour, nil
+	return pending, nil
+}
+
+// LastSwitch returns the daemon's last automatic switch, if it hasn't been
+// undone
+func (s *State) LastSwitch() LastSwitch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return LastSwitch{
+		From: s.LastSwitchFrom,
+		To:   s.LastSwitchTo,
+		At:   s.LastSwitchAt,
 	}
+}
 
-	return time.Since(lastActivity), nil
+// SetLastSwitch records the daemon's last automatic switch. A zero
+// LastSwitch forgets it, once undone.
+func SetLastSwitch(store StateStore, last LastSwitch) error {
+	return store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		state.LastSwitchFrom = last.From
+		state.LastSwitchTo = last.To
+		state.LastSwitchAt = last.At
+		return nil
+	})
+}
+
+// ActiveProfile returns the profile chosen with the profile command, which
+// may have expired. A zero ActiveProfile means none was chosen.
+func (s *State) ActiveProfile() ActiveProfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return ActiveProfile{Name: s.Profile, Until: s.ProfileUntil}
+}
+
+// SetProfile records the profile chosen with the profile command. A zero
+// ActiveProfile goes back to the configuration without a profile.
+func SetProfile(store StateStore, profile ActiveProfile) error {
+	return store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		state.Profile = profile.Name
+		state.ProfileUntil = profile.Until
+		return nil
+	})
+}
+
+// PauseContext suppresses timeout switching away from a single context for
+// the given duration from now, replacing any earlier pause of that context.
+// It returns the time until which the context is paused.
+func PauseContext(store StateStore, context string, d time.Duration) (time.Time, error) {
+	if context == "" {
+		return time.Time{}, fmt.Errorf("context name is required")
+	}
+	if d <= 0 {
+		return time.Time{}, fmt.Errorf("pause must be positive")
+	}
+
+	now := time.Now()
+	until := now.Add(d)
+	err := store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		state.prunePausedContexts(now)
+		if state.PausedContexts == nil {
+			state.PausedContexts = make(map[string]time.Time)
+		}
+		state.PausedContexts[context] = until
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return until, nil
+}
+
+// ResumeContext ends a context's pause early. It reports whether the context
+// was paused, and does not write the store if it wasn't.
+func ResumeContext(store StateStore, context string) (bool, error) {
+	now := time.Now()
+	var paused bool
+	err := store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		until, ok := state.PausedContexts[context]
+		if !ok {
+			return ErrStateUnchanged
+		}
+		paused = now.Before(until)
+		delete(state.PausedContexts, context)
+		state.prunePausedContexts(now)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return paused, nil
+}
+
+// ContextPausedUntil returns the time until which timeout switching away
+// from the context is suppressed. A zero time means the context has never
+// been paused.
+func (s *State) ContextPausedUntil(context string) time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.PausedContexts[context]
+}
+
+// ActivePauses returns the contexts that are paused at now and when each
+// pause ends
+func (s *State) ActivePauses(now time.Time) map[string]time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	paused := make(map[string]time.Time)
+	for context, until := range s.PausedContexts {
+		if now.Before(until) {
+			paused[context] = until
+		}
+	}
+
+	return paused
+}
+
+// prunePausedContexts drops pauses that ended before now. The caller must
+// hold state.mu.
+func (s *State) prunePausedContexts(now time.Time) {
+	for context, until := range s.PausedContexts {
+		if !now.Before(until) {
+			delete(s.PausedContexts, context)
+		}
+	}
+	if len(s.PausedContexts) == 0 {
+		s.PausedContexts = nil
+	}
+}
+
+// KubeconfigSession is the activity in shells sharing a KUBECONFIG of their
+// own, such as the per-shell kubeconfigs kubie and kubeswitch create
+type KubeconfigSession struct {
+	LastActivity   time.Time `json:"last_activity"`
+	CurrentContext string    `json:"current_context"`
+
+	// Project is the project configuration (.kubectx-timeout.yaml) for the
+	// directory of the last activity, if there was one
+	Project string `json:"project,omitempty"`
+}
+
+// RecordSessionActivity records activity in the shells using the given
+// KUBECONFIG, run in the directory of the given project configuration, if
+// any. It leaves the default kubeconfig's activity alone.
+func RecordSessionActivity(store StateStore, kubeconfig, context, project string) error {
+	if kubeconfig == "" {
+		return fmt.Errorf("kubeconfig is required")
+	}
+
+	return store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if state.Sessions == nil {
+			state.Sessions = make(map[string]KubeconfigSession)
+		}
+		state.Sessions[kubeconfig] = KubeconfigSession{LastActivity: time.Now(), CurrentContext: context, Project: project}
+		return nil
+	})
+}
+
+// KubeconfigSessions returns a copy of the activity recorded for each
+// KUBECONFIG
+func (s *State) KubeconfigSessions() map[string]KubeconfigSession {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := make(map[string]KubeconfigSession, len(s.Sessions))
+	for kubeconfig, session := range s.Sessions {
+		sessions[kubeconfig] = session
+	}
+
+	return sessions
+}
+
+// ForgetSession stops tracking a KUBECONFIG, for example because its shell
+// has exited. It does not write the store if it wasn't tracked.
+func ForgetSession(store StateStore, kubeconfig string) error {
+	return store.Update(func(state *State) error {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if _, ok := state.Sessions[kubeconfig]; !ok {
+			return ErrStateUnchanged
+		}
+		delete(state.Sessions, kubeconfig)
+		if len(state.Sessions) == 0 {
+			state.Sessions = nil
+		}
+		return nil
+	})
 }
@@ -1,8 +1,13 @@
 package internal
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -114,7 +119,7 @@ func TestStateManagerRecordActivity(t *testing.T) {
 
 	// Record activity
 	before := time.Now()
-	if err := sm.RecordActivity("production"); err != nil {
+	if err := RecordActivity(sm, "production"); err != nil {
 		t.Fatalf("RecordActivity failed: %v", err)
 	}
 	after := time.Now()
@@ -134,6 +139,48 @@ func TestStateManagerRecordActivity(t *testing.T) {
 	}
 }
 
+func TestStateManagerRecordActivityNamespace(t *testing.T) {
+	sm, err := NewStateManager(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+
+	if err := RecordActivities(sm, []Activity{{Context: "production", Namespace: "kube-system"}}); err != nil {
+		t.Fatalf("RecordActivityDetails failed: %v", err)
+	}
+
+	// Activity without a namespace in the same context keeps it
+	if err := RecordActivity(sm, "production"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	state, err := sm.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if state.CurrentNamespace != "kube-system" {
+		t.Errorf("expected namespace 'kube-system' kept, got %q", state.CurrentNamespace)
+	}
+
+	// Only writes are recorded as such
+	if lastWrite := loadState(t, sm).LastWriteActivity; !lastWrite.IsZero() {
+		t.Errorf("expected no write recorded, got %v", lastWrite)
+	}
+	if err := RecordActivities(sm, []Activity{{Context: "production", Write: true}}); err != nil {
+		t.Fatalf("RecordActivityDetails failed: %v", err)
+	}
+	if lastWrite := loadState(t, sm).LastWriteActivity; time.Since(lastWrite) > time.Minute {
+		t.Errorf("expected a recent write, got %v", lastWrite)
+	}
+
+	// A different context forgets it
+	if err := RecordActivity(sm, "local"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	if state, _ = sm.Load(); state.CurrentNamespace != "" {
+		t.Errorf("expected no namespace after a context change, got %q", state.CurrentNamespace)
+	}
+}
+
 func TestStateManagerGetLastActivity(t *testing.T) {
 	tmpDir := t.TempDir()
 	statePath := filepath.Join(tmpDir, "state.json")
@@ -144,12 +191,12 @@ func TestStateManagerGetLastActivity(t *testing.T) {
 	}
 
 	// Record activity
-	if err := sm.RecordActivity("dev"); err != nil {
+	if err := RecordActivity(sm, "dev"); err != nil {
 		t.Fatalf("RecordActivity failed: %v", err)
 	}
 
 	// Get last activity
-	lastActivity, context, err := sm.GetLastActivity()
+	lastActivity, context, err := LastActivity(sm)
 	if err != nil {
 		t.Fatalf("GetLastActivity failed: %v", err)
 	}
@@ -169,38 +216,74 @@ func TestStateManagerGetLastActivity(t *testing.T) {
 	}
 }
 
-func TestStateManagerTimeSinceLastActivity(t *testing.T) {
-	tmpDir := t.TempDir()
-	statePath := filepath.Join(tmpDir, "state.json")
-
+func TestStateManagerRecordActivityCoalesces(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
 	sm, err := NewStateManager(statePath)
 	if err != nil {
 		t.Fatalf("NewStateManager failed: %v", err)
 	}
 
-	// Test with no activity (should return large duration)
-	duration, err := sm.TimeSinceLastActivity()
+	if err := RecordActivity(sm, "dev"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	before, err := os.Stat(statePath)
 	if err != nil {
-		t.Fatalf("TimeSinceLastActivity failed: %v", err)
+		t.Fatalf("Stat failed: %v", err)
 	}
 
-	if duration < 24*time.Hour {
-		t.Errorf("expected duration >= 24h for no activity, got %v", duration)
+	// A burst in the same context leaves the file alone
+	if err := RecordActivity(sm, "dev"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	after, err := os.Stat(statePath)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("Expected repeated activity in the same context not to rewrite the state file")
 	}
 
-	// Record activity
-	if err := sm.RecordActivity("staging"); err != nil {
+	// A write, a new context, or enough time passing is always written
+	if err := RecordActivities(sm, []Activity{{Context: "dev", Write: true}}); err != nil {
+		t.Fatalf("RecordActivityDetails failed: %v", err)
+	}
+	if lastWrite := loadState(t, sm).LastWriteActivity; lastWrite.IsZero() {
+		t.Error("Expected a write command to be recorded")
+	}
+	if err := RecordActivity(sm, "prod"); err != nil {
 		t.Fatalf("RecordActivity failed: %v", err)
 	}
+	if _, context, _ := LastActivity(sm); context != "prod" {
+		t.Errorf("Expected a context change to be recorded, got %q", context)
+	}
+	later := time.Now().Add(activityCoalesceWindow)
+	if err := RecordActivities(sm, []Activity{{Context: "prod", At: later}}); err != nil {
+		t.Fatalf("RecordActivities failed: %v", err)
+	}
+	if lastActivity, _, _ := LastActivity(sm); !lastActivity.Equal(later) {
+		t.Errorf("Expected activity past the coalescing window to be recorded, got %v", lastActivity)
+	}
+}
 
-	// Check duration again
-	duration, err = sm.TimeSinceLastActivity()
+func TestStateManagerGetLastActivitySeesOtherWriters(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	reader, err := NewStateManager(statePath)
 	if err != nil {
-		t.Fatalf("TimeSinceLastActivity failed: %v", err)
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+	writer, err := NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
 	}
 
-	if duration > 5*time.Second {
-		t.Errorf("expected duration < 5s after recording activity, got %v", duration)
+	// The cached activity is dropped once another process replaces the file
+	for _, context := range []string{"dev", "prod", "staging"} {
+		if err := writer.Save(&State{LastActivity: time.Now(), CurrentContext: context}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if _, got, _ := LastActivity(reader); got != context {
+			t.Errorf("GetLastActivity() context = %q, want %q", got, context)
+		}
 	}
 }
 
@@ -220,12 +303,12 @@ func TestStateManagerConcurrentAccess(t *testing.T) {
 			defer func() { done <- true }()
 
 			// Record activity
-			if err := sm.RecordActivity("concurrent-test"); err != nil {
+			if err := RecordActivity(sm, "concurrent-test"); err != nil {
 				t.Errorf("RecordActivity failed: %v", err)
 			}
 
 			// Read activity
-			if _, _, err := sm.GetLastActivity(); err != nil {
+			if _, _, err := LastActivity(sm); err != nil {
 				t.Errorf("GetLastActivity failed: %v", err)
 			}
 		}(i)
@@ -259,7 +342,7 @@ func TestStateFilePermissions(t *testing.T) {
 	}
 
 	// Record activity to create file
-	if err := sm.RecordActivity("test"); err != nil {
+	if err := RecordActivity(sm, "test"); err != nil {
 		t.Fatalf("RecordActivity failed: %v", err)
 	}
 
@@ -285,3 +368,513 @@ func TestStateFilePermissions(t *testing.T) {
 		t.Errorf("expected directory permissions 0700, got %o", dirMode)
 	}
 }
+
+func TestStateManagerExtendDeadline(t *testing.T) {
+	tmpDir := t.TempDir()
+	statePath := filepath.Join(tmpDir, "state.json")
+
+	sm, err := NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+
+	// No extension recorded yet
+	until := loadState(t, sm).ExtendedUntil
+	if !until.IsZero() {
+		t.Errorf("expected zero ExtendedUntil, got %v", until)
+	}
+
+	// Non-positive durations are rejected
+	if _, err := ExtendDeadline(sm, 0); err == nil {
+		t.Error("expected error for zero extension")
+	}
+
+	if err := RecordActivity(sm, "production"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+
+	before := time.Now()
+	until, err = ExtendDeadline(sm, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("ExtendDeadline failed: %v", err)
+	}
+	if until.Before(before.Add(30 * time.Minute)) {
+		t.Errorf("ExtendedUntil %v is earlier than expected", until)
+	}
+
+	// Extension survives subsequent activity recording
+	if err := RecordActivity(sm, "production"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+
+	loaded := loadState(t, sm).ExtendedUntil
+	if !loaded.Equal(until) {
+		t.Errorf("expected ExtendedUntil %v, got %v", until, loaded)
+	}
+
+	// Extension does not change the recorded context
+	_, context, err := LastActivity(sm)
+	if err != nil {
+		t.Fatalf("GetLastActivity failed: %v", err)
+	}
+	if context != "production" {
+		t.Errorf("expected context 'production', got '%s'", context)
+	}
+}
+
+func TestStateManagerWatcherStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	statePath := filepath.Join(tmpDir, "state.json")
+
+	sm, err := NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+
+	mode, startedAt := loadState(t, sm).WatcherStatus()
+	if mode != "" || !startedAt.IsZero() {
+		t.Errorf("expected no watcher status, got mode %q started %v", mode, startedAt)
+	}
+
+	before := time.Now()
+	if err := SetWatcherStatus(sm, WatcherModePolling); err != nil {
+		t.Fatalf("SetWatcherStatus failed: %v", err)
+	}
+
+	// Watcher status survives activity recording
+	if err := RecordActivity(sm, "production"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+
+	mode, startedAt = loadState(t, sm).WatcherStatus()
+	if mode != WatcherModePolling {
+		t.Errorf("expected mode %q, got %q", WatcherModePolling, mode)
+	}
+	if startedAt.Before(before) {
+		t.Errorf("WatcherStartedAt %v is earlier than expected", startedAt)
+	}
+}
+
+func TestStateManagerSaveNoPartialWrites(t *testing.T) {
+	tmpDir := t.TempDir()
+	statePath := filepath.Join(tmpDir, "state.json")
+
+	sm, err := NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+
+	// Readers must never observe a partially written state file
+	done := make(chan struct{})
+	readErrs := make(chan error, 1)
+	go func() {
+		defer close(readErrs)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			// #nosec G304 -- test file path
+			data, err := os.ReadFile(statePath)
+			if err != nil {
+				continue // Not written yet
+			}
+			var s State
+			if err := json.Unmarshal(data, &s); err != nil {
+				readErrs <- fmt.Errorf("observed partial write: %w", err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		if err := RecordActivity(sm, fmt.Sprintf("context-%d", i)); err != nil {
+			t.Fatalf("RecordActivity failed: %v", err)
+		}
+	}
+	close(done)
+
+	if err := <-readErrs; err != nil {
+		t.Fatal(err)
+	}
+
+	assertNoStateTempFiles(t, tmpDir, "successful saves")
+}
+
+// assertNoStateTempFiles fails the test if a save left a temporary file in
+// the state directory
+func assertNoStateTempFiles(t *testing.T, dir, after string) {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".state.json.*.tmp"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(matches) > 0 {
+		t.Errorf("temporary state files left behind after %s: %v", after, matches)
+	}
+}
+
+func TestStateManagerUpdateIsAtomic(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	// Pauses and activity recorded at once, from separate managers as from
+	// separate processes, are all kept
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		sm, err := NewStateManager(statePath)
+		if err != nil {
+			t.Fatalf("NewStateManager failed: %v", err)
+		}
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := PauseContext(sm, fmt.Sprintf("context-%d-%d", id, j), time.Hour); err != nil {
+					t.Errorf("PauseContext failed: %v", err)
+				}
+				if err := RecordActivity(sm, fmt.Sprintf("context-%d", id)); err != nil {
+					t.Errorf("RecordActivity failed: %v", err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	sm, err := NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+	paused := loadState(t, sm).ActivePauses(time.Now())
+	if len(paused) != 80 {
+		t.Errorf("Expected all 80 pauses kept, got %d", len(paused))
+	}
+}
+
+func TestStateManagerUpdate(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	sm, err := NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+
+	if err := sm.Update(func(state *State) error {
+		state.CurrentContext = "production"
+		return nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	info, err := os.Stat(statePath)
+	if err != nil {
+		t.Fatalf("Expected the state file written: %v", err)
+	}
+
+	// ErrStateUnchanged leaves the file alone
+	if err := sm.Update(func(state *State) error {
+		state.CurrentContext = "staging"
+		return ErrStateUnchanged
+	}); err != nil {
+		t.Fatalf("Update returning ErrStateUnchanged failed: %v", err)
+	}
+	// Any other error is returned, and nothing is written
+	errBoom := errors.New("boom")
+	if err := sm.Update(func(state *State) error {
+		state.CurrentContext = "staging"
+		return errBoom
+	}); !errors.Is(err, errBoom) {
+		t.Fatalf("Update error = %v, want %v", err, errBoom)
+	}
+
+	if after, err := os.Stat(statePath); err != nil || !os.SameFile(info, after) {
+		t.Errorf("Expected the state file left alone, got %v", err)
+	}
+	if state, err := sm.Load(); err != nil || state.CurrentContext != "production" {
+		t.Errorf("Load() = %+v, %v, want context production", state, err)
+	}
+}
+
+func TestStateManagerConcurrentSavers(t *testing.T) {
+	tmpDir := t.TempDir()
+	statePath := filepath.Join(tmpDir, "state.json")
+
+	// Separate managers stand for separate processes: the shell
+	// integration, the CLI, and the daemon
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		sm, err := NewStateManager(statePath)
+		if err != nil {
+			t.Fatalf("NewStateManager failed: %v", err)
+		}
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			context := fmt.Sprintf("context-%d-%s", id, strings.Repeat("x", id*512))
+			for j := 0; j < 25; j++ {
+				if err := sm.Save(&State{CurrentContext: context, LastActivity: time.Now()}); err != nil {
+					t.Errorf("Save failed: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Whichever save was last, the file is one whole save
+	sm, err := NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+	state, err := sm.Load()
+	if err != nil {
+		t.Fatalf("Load failed after concurrent saves: %v", err)
+	}
+	if !strings.HasPrefix(state.CurrentContext, "context-") {
+		t.Errorf("Unexpected context after concurrent saves: %q", state.CurrentContext)
+	}
+	assertNoStateTempFiles(t, tmpDir, "concurrent saves")
+}
+
+func TestStateManagerSaveFailureCleansUp(t *testing.T) {
+	tmpDir := t.TempDir()
+	statePath := filepath.Join(tmpDir, "state.json")
+
+	// A directory at the state path makes the final rename fail
+	if err := os.MkdirAll(filepath.Join(statePath, "blocker"), 0700); err != nil {
+		t.Fatalf("Failed to create blocking directory: %v", err)
+	}
+
+	sm, err := NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+
+	if err := sm.Save(&State{CurrentContext: "test"}); err == nil {
+		t.Fatal("expected Save to fail when state path is a directory")
+	}
+
+	assertNoStateTempFiles(t, tmpDir, "a failed save")
+}
+
+func TestStateManagerPendingSwitch(t *testing.T) {
+	tmpDir := t.TempDir()
+	statePath := filepath.Join(tmpDir, "state.json")
+
+	sm, err := NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+
+	// Nothing pending: cancel is a no-op and doesn't touch the timer
+	canceled, err := CancelPendingSwitch(sm)
+	if err != nil {
+		t.Fatalf("CancelPendingSwitch failed: %v", err)
+	}
+	if !canceled.IsZero() {
+		t.Errorf("expected nothing to cancel, got %+v", canceled)
+	}
+	if lastActivity, _, _ := LastActivity(sm); !lastActivity.IsZero() {
+		t.Errorf("expected no activity recorded, got %v", lastActivity)
+	}
+
+	want := PendingSwitch{From: "production", To: "local", At: time.Now().Add(time.Minute).Round(0)}
+	if err := SetPendingSwitch(sm, want); err != nil {
+		t.Fatalf("SetPendingSwitch failed: %v", err)
+	}
+
+	got := loadState(t, sm).PendingSwitch()
+	if got.From != want.From || got.To != want.To || !got.At.Equal(want.At) {
+		t.Errorf("expected pending switch %+v, got %+v", want, got)
+	}
+
+	// Canceling clears the switch and resets the timer for the source context
+	before := time.Now()
+	canceled, err = CancelPendingSwitch(sm)
+	if err != nil {
+		t.Fatalf("CancelPendingSwitch failed: %v", err)
+	}
+	if canceled.From != "production" || canceled.To != "local" {
+		t.Errorf("expected the pending switch to be returned, got %+v", canceled)
+	}
+
+	lastActivity, context, err := LastActivity(sm)
+	if err != nil {
+		t.Fatalf("GetLastActivity failed: %v", err)
+	}
+	if context != "production" || lastActivity.Before(before) {
+		t.Errorf("expected fresh activity in 'production', got %q at %v", context, lastActivity)
+	}
+	if got := loadState(t, sm).PendingSwitch(); !got.IsZero() {
+		t.Errorf("expected no pending switch after cancel, got %+v", got)
+	}
+
+	// Clearing with nothing pending is a no-op
+	if err := ClearPendingSwitch(sm); err != nil {
+		t.Errorf("ClearPendingSwitch failed: %v", err)
+	}
+}
+
+func TestStateManagerLastSwitch(t *testing.T) {
+	sm, err := NewStateManager(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+
+	if last := loadState(t, sm).LastSwitch(); !last.IsZero() {
+		t.Fatalf("expected no last switch, got %+v, %v", last, err)
+	}
+
+	want := LastSwitch{From: "production", To: "local", At: time.Now().Round(0)}
+	if err := SetLastSwitch(sm, want); err != nil {
+		t.Fatalf("SetLastSwitch failed: %v", err)
+	}
+	// Other writes keep it
+	if err := RecordActivity(sm, "local"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	got := loadState(t, sm).LastSwitch()
+	if got.From != want.From || got.To != want.To || !got.At.Equal(want.At) {
+		t.Errorf("expected last switch %+v, got %+v", want, got)
+	}
+
+	if err := SetLastSwitch(sm, LastSwitch{}); err != nil {
+		t.Fatalf("SetLastSwitch failed: %v", err)
+	}
+	if got := loadState(t, sm).LastSwitch(); !got.IsZero() {
+		t.Errorf("expected the last switch forgotten, got %+v", got)
+	}
+}
+
+func TestLastSwitchCheckUndo(t *testing.T) {
+	now := time.Now()
+	last := LastSwitch{From: "production", To: "local", At: now.Add(-5 * time.Minute)}
+
+	tests := []struct {
+		name    string
+		last    LastSwitch
+		window  time.Duration
+		wantErr bool
+	}{
+		{"within the window", last, 10 * time.Minute, false},
+		{"past the window", last, time.Minute, true},
+		{"undo turned off", last, 0, true},
+		{"nothing switched", LastSwitch{}, 10 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.last.CheckUndo(tt.window, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckUndo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrNothingToUndo) {
+				t.Errorf("CheckUndo() error = %v, want ErrNothingToUndo", err)
+			}
+		})
+	}
+}
+
+func TestStateManagerPauseContext(t *testing.T) {
+	sm, err := NewStateManager(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+
+	// Invalid pauses are rejected
+	if _, err := PauseContext(sm, "prod-eu", 0); err == nil {
+		t.Error("expected error for zero pause")
+	}
+	if _, err := PauseContext(sm, "", time.Hour); err == nil {
+		t.Error("expected error for empty context name")
+	}
+
+	until, err := PauseContext(sm, "prod-eu", time.Hour)
+	if err != nil {
+		t.Fatalf("PauseContext failed: %v", err)
+	}
+
+	// Only the paused context is affected
+	loaded := loadState(t, sm).ContextPausedUntil("prod-eu")
+	if !loaded.Equal(until) {
+		t.Errorf("expected prod-eu paused until %v, got %v", until, loaded)
+	}
+	if other := loadState(t, sm).ContextPausedUntil("prod-us"); !other.IsZero() {
+		t.Errorf("expected prod-us not paused, got %v", other)
+	}
+
+	// The pause survives activity recording
+	if err := RecordActivity(sm, "prod-eu"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	paused := loadState(t, sm).ActivePauses(time.Now())
+	if len(paused) != 1 || !paused["prod-eu"].Equal(until) {
+		t.Errorf("expected only prod-eu paused, got %v", paused)
+	}
+
+	// Expired pauses are not reported and are pruned on the next write
+	state, err := sm.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	state.PausedContexts["staging"] = time.Now().Add(-time.Minute)
+	if err := sm.Save(state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if paused := loadState(t, sm).ActivePauses(time.Now()); len(paused) != 1 {
+		t.Errorf("expected the expired pause to be ignored, got %v", paused)
+	}
+	if _, err := PauseContext(sm, "prod-us", time.Hour); err != nil {
+		t.Fatalf("PauseContext failed: %v", err)
+	}
+	state, err = sm.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := state.PausedContexts["staging"]; ok {
+		t.Error("expected the expired pause to be pruned")
+	}
+
+	// Resuming ends the pause early
+	resumed, err := ResumeContext(sm, "prod-eu")
+	if err != nil {
+		t.Fatalf("ResumeContext failed: %v", err)
+	}
+	if !resumed {
+		t.Error("expected prod-eu to have been paused")
+	}
+	if loaded := loadState(t, sm).ContextPausedUntil("prod-eu"); !loaded.IsZero() {
+		t.Errorf("expected prod-eu pause cleared, got %v", loaded)
+	}
+	if resumed, _ := ResumeContext(sm, "prod-eu"); resumed {
+		t.Error("expected resuming an unpaused context to report false")
+	}
+}
+
+func TestStateManagerProfile(t *testing.T) {
+	sm, err := NewStateManager(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("NewStateManager failed: %v", err)
+	}
+
+	if profile := loadState(t, sm).ActiveProfile(); profile.Name != "" {
+		t.Fatalf("expected no profile, got %+v, %v", profile, err)
+	}
+
+	want := ActiveProfile{Name: "oncall", Until: time.Now().Add(8 * time.Hour).Round(0)}
+	if err := SetProfile(sm, want); err != nil {
+		t.Fatalf("SetProfile failed: %v", err)
+	}
+	// Other writes keep it
+	if err := RecordActivity(sm, "production"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	got := loadState(t, sm).ActiveProfile()
+	if got.Name != want.Name || !got.Until.Equal(want.Until) {
+		t.Errorf("expected profile %+v, got %+v", want, got)
+	}
+
+	if err := SetProfile(sm, ActiveProfile{}); err != nil {
+		t.Fatalf("SetProfile failed: %v", err)
+	}
+	if got := loadState(t, sm).ActiveProfile(); got.Name != "" {
+		t.Errorf("expected the profile cleared, got %+v", got)
+	}
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ContextStats is the usage of one context over a stats window
+type ContextStats struct {
+	Context string `json:"context"`
+
+	// Time is how long the context was current, judged by the history: a
+	// context stays current from one event until the next
+	Time time.Duration `json:"-"`
+
+	// Activity is the number of recorded kubectl invocations
+	Activity int `json:"activity"`
+
+	// AutoSwitches is the number of timeout switches away from the context
+	AutoSwitches int `json:"auto_switches"`
+}
+
+// MarshalJSON encodes the time as seconds
+func (c ContextStats) MarshalJSON() ([]byte, error) {
+	type stats ContextStats
+	return json.Marshal(struct {
+		stats
+		TimeSeconds int64 `json:"time_seconds"`
+	}{stats(c), int64(c.Time / time.Second)})
+}
+
+// CommandStats is how often one kubectl command was run over a stats window
+type CommandStats struct {
+	// Command is the verb, followed by the resource kind if recorded, as
+	// in "get pods"
+	Command string `json:"command"`
+	Count   int    `json:"count"`
+}
+
+// HistoryStats summarizes the history over a window
+type HistoryStats struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+
+	// Contexts is sorted by time spent, most used first
+	Contexts []ContextStats `json:"contexts"`
+
+	// Commands is sorted by count, most run first. It only covers activity
+	// recorded with tracking.metadata on.
+	Commands []CommandStats `json:"commands,omitempty"`
+
+	// AutoSwitches are switches made by the daemon's timeout, and
+	// ManualSwitches those requested with switch-now
+	AutoSwitches   int `json:"auto_switches"`
+	ManualSwitches int `json:"manual_switches"`
+
+	// AverageIdle is the mean time between the last activity and an
+	// auto-switch, or zero if there were none
+	AverageIdle time.Duration `json:"-"`
+}
+
+// MarshalJSON encodes the average idle time as seconds
+func (s HistoryStats) MarshalJSON() ([]byte, error) {
+	type stats HistoryStats
+	return json.Marshal(struct {
+		stats
+		AverageIdleSeconds int64 `json:"average_idle_seconds"`
+	}{stats(s), int64(s.AverageIdle / time.Second)})
+}
+
+// ComputeStats summarizes events, oldest first, over the window from since
+// to until. Events before the window only establish which context was
+// current when it began.
+func ComputeStats(events []HistoryEvent, since, until time.Time) HistoryStats {
+	stats := HistoryStats{Since: since, Until: until}
+	byContext := make(map[string]*ContextStats)
+	get := func(name string) *ContextStats {
+		if byContext[name] == nil {
+			byContext[name] = &ContextStats{Context: name}
+		}
+		return byContext[name]
+	}
+
+	// addTime credits the part of [from, to) inside the window to a context
+	addTime := func(name string, from, to time.Time) {
+		if name == "" {
+			return
+		}
+		if from.Before(since) {
+			from = since
+		}
+		if to.After(until) {
+			to = until
+		}
+		if to.After(from) {
+			get(name).Time += to.Sub(from)
+		}
+	}
+
+	byCommand := make(map[string]int)
+
+	var current string
+	var currentSince, lastActivity time.Time
+	var totalIdle time.Duration
+	var idleSwitches int
+	for _, event := range events {
+		if event.Time.After(until) {
+			break
+		}
+		addTime(current, currentSince, event.Time)
+		inWindow := !event.Time.Before(since)
+
+		switch event.Type {
+		case HistoryActivity, HistoryContextChange:
+			if inWindow && event.Type == HistoryActivity {
+				get(event.Context).Activity++
+				if event.Verb != "" {
+					byCommand[strings.TrimSpace(event.Verb+" "+event.Resource)]++
+				}
+			}
+			lastActivity = event.Time
+		case HistorySwitch:
+			if inWindow && event.Reason == SwitchNowReason {
+				stats.ManualSwitches++
+			} else if inWindow {
+				stats.AutoSwitches++
+				get(event.FromContext).AutoSwitches++
+				if !lastActivity.IsZero() {
+					totalIdle += event.Time.Sub(lastActivity)
+					idleSwitches++
+				}
+			}
+			// A switch resets the activity timer
+			lastActivity = event.Time
+		}
+
+		current, currentSince = event.Context, event.Time
+	}
+	addTime(current, currentSince, until)
+
+	for _, c := range byContext {
+		stats.Contexts = append(stats.Contexts, *c)
+	}
+	sort.Slice(stats.Contexts, func(i, j int) bool {
+		a, b := stats.Contexts[i], stats.Contexts[j]
+		if a.Time != b.Time {
+			return a.Time > b.Time
+		}
+		if a.Activity != b.Activity {
+			return a.Activity > b.Activity
+		}
+		return a.Context < b.Context
+	})
+
+	for command, count := range byCommand {
+		stats.Commands = append(stats.Commands, CommandStats{Command: command, Count: count})
+	}
+	sort.Slice(stats.Commands, func(i, j int) bool {
+		a, b := stats.Commands[i], stats.Commands[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Command < b.Command
+	})
+
+	if idleSwitches > 0 {
+		stats.AverageIdle = totalIdle / time.Duration(idleSwitches)
+	}
+
+	return stats
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	events := []HistoryEvent{
+		// Before the window: only sets the starting context
+		{Time: at(-30), Type: HistoryActivity, Context: "local"},
+		{Time: at(10), Type: HistoryContextChange, Context: "prod", FromContext: "local"},
+		{Time: at(20), Type: HistoryActivity, Context: "prod"},
+		{Time: at(30), Type: HistoryActivity, Context: "prod"},
+		{Time: at(60), Type: HistorySwitch, Context: "local", FromContext: "prod", Reason: "inactive for 30m0s"},
+		{Time: at(70), Type: HistoryContextChange, Context: "staging", FromContext: "local"},
+		{Time: at(80), Type: HistoryActivity, Context: "staging"},
+		{Time: at(90), Type: HistorySwitch, Context: "local", FromContext: "staging", Reason: SwitchNowReason},
+		{Time: at(130), Type: HistorySwitch, Context: "local", FromContext: "prod", Reason: "no activity recorded"},
+		// After the window: ignored
+		{Time: at(200), Type: HistoryActivity, Context: "prod"},
+	}
+
+	stats := ComputeStats(events, start, at(120))
+
+	if stats.AutoSwitches != 1 || stats.ManualSwitches != 1 {
+		t.Errorf("Switches = %d auto, %d manual, want 1 and 1", stats.AutoSwitches, stats.ManualSwitches)
+	}
+	if stats.AverageIdle != 30*time.Minute {
+		t.Errorf("AverageIdle = %v, want 30m", stats.AverageIdle)
+	}
+
+	want := []ContextStats{
+		{Context: "prod", Time: 50 * time.Minute, Activity: 2, AutoSwitches: 1},
+		{Context: "local", Time: 50 * time.Minute, Activity: 0},
+		{Context: "staging", Time: 20 * time.Minute, Activity: 1},
+	}
+	if len(stats.Contexts) != len(want) {
+		t.Fatalf("Contexts = %+v, want %+v", stats.Contexts, want)
+	}
+	for i := range want {
+		if stats.Contexts[i] != want[i] {
+			t.Errorf("Contexts[%d] = %+v, want %+v", i, stats.Contexts[i], want[i])
+		}
+	}
+}
+
+func TestComputeStatsCommands(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	events := []HistoryEvent{
+		{Time: at(1), Type: HistoryActivity, Context: "prod", Verb: "get", Resource: "pods"},
+		{Time: at(2), Type: HistoryActivity, Context: "prod", Verb: "apply"},
+		{Time: at(3), Type: HistoryActivity, Context: "prod", Verb: "get", Resource: "pods"},
+		// Recorded with tracking.metadata off
+		{Time: at(4), Type: HistoryActivity, Context: "prod"},
+	}
+
+	stats := ComputeStats(events, start, at(10))
+
+	want := []CommandStats{{Command: "get pods", Count: 2}, {Command: "apply", Count: 1}}
+	if len(stats.Commands) != len(want) {
+		t.Fatalf("Commands = %+v, want %+v", stats.Commands, want)
+	}
+	for i := range want {
+		if stats.Commands[i] != want[i] {
+			t.Errorf("Commands[%d] = %+v, want %+v", i, stats.Commands[i], want[i])
+		}
+	}
+}
+
+func TestComputeStatsEmpty(t *testing.T) {
+	now := time.Now()
+	stats := ComputeStats(nil, now.Add(-time.Hour), now)
+	if len(stats.Contexts) != 0 || stats.AutoSwitches != 0 || stats.AverageIdle != 0 {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+}
+
+func TestHistoryStatsJSON(t *testing.T) {
+	stats := HistoryStats{
+		Contexts:    []ContextStats{{Context: "prod", Time: time.Hour, Activity: 3}},
+		AverageIdle: 90 * time.Second,
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got struct {
+		Contexts []map[string]any `json:"contexts"`
+		Average  float64          `json:"average_idle_seconds"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got.Average != 90 || len(got.Contexts) != 1 || got.Contexts[0]["time_seconds"] != float64(3600) {
+		t.Errorf("Unexpected stats JSON: %s", data)
+	}
+}
@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// statusSummaryVersion is the status summary schema version. It is only
+// incremented for incompatible changes; new fields may be added at any time.
+const statusSummaryVersion = 1
+
+// statusSummaryFileName is the summary file's name within the state directory
+const statusSummaryFileName = "status.json"
+
+// Status summary states
+const (
+	// SummaryStateActive means the timeout is counting down
+	SummaryStateActive = "active"
+	// SummaryStateDefault means the default context is active, nothing to do
+	SummaryStateDefault = "default"
+	// SummaryStateExempt means the context is in never_switch_from
+	SummaryStateExempt = "exempt"
+	// SummaryStateExtended means switching is suppressed by the extend command
+	SummaryStateExtended = "extended"
+	// SummaryStatePaused means switching away from this context is
+	// suppressed by the pause-context command
+	SummaryStatePaused = "paused"
+	// SummaryStateDeferred means the timeout has passed but the switch is
+	// waiting for running Kubernetes tools (check_active_kubectl)
+	SummaryStateDeferred = "deferred"
+	// SummaryStatePending means the timeout has passed and the switch is
+	// waiting out the grace period, when it can still be canceled
+	SummaryStatePending = "pending"
+	// SummaryStateDegraded means the daemon can't currently check the timeout
+	SummaryStateDegraded = "degraded"
+	// SummaryStateStopped means the daemon has shut down
+	SummaryStateStopped = "stopped"
+)
+
+// StatusSummary is a small, secret-free snapshot of the daemon's state for
+// lightweight consumers (prompt segments, tmux, menubar scripts) that read
+// the file directly instead of invoking the binary. See docs/status-widget.md
+// for the schema.
+//
+// With state.encrypt on, the summary written to disk is redacted: it leaves
+// out context names, which the encrypted state file exists to protect.
+type StatusSummary struct {
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	State     string    `json:"state"`
+
+	Context        string `json:"context"`
+	DefaultContext string `json:"default_context"`
+
+	// ContextAlias is the alias of the context, if it has one, for a shorter
+	// label than a generated context name
+	ContextAlias string `json:"context_alias,omitempty"`
+
+	// Deadline is when the context will be switched, and RemainingSeconds
+	// the time left as of UpdatedAt. Both are only set in the active,
+	// deferred, and pending states.
+	Deadline         *time.Time `json:"deadline,omitempty"`
+	RemainingSeconds *int64     `json:"remaining_seconds,omitempty"`
+	TimeoutSeconds   int64      `json:"timeout_seconds"`
+
+	ExtendedUntil  *time.Time `json:"extended_until,omitempty"`
+	PausedUntil    *time.Time `json:"paused_until,omitempty"`
+	DeferredBy     []string   `json:"deferred_by,omitempty"`
+	DegradedReason string     `json:"degraded_reason,omitempty"`
+
+	// Profile is the profile chosen with the profile command, applied until
+	// ProfileUntil if that is set
+	Profile      string     `json:"profile,omitempty"`
+	ProfileUntil *time.Time `json:"profile_until,omitempty"`
+
+	// DaemonPID is the PID of the daemon that wrote the summary, so readers
+	// can tell a stale summary from a crashed daemon
+	DaemonPID int `json:"daemon_pid"`
+
+	// Redacted marks a summary written with state.encrypt on, which has no
+	// context names even when a context is active
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// Redact removes the names of the contexts and profile, leaving the state
+// and countdown
+func (s *StatusSummary) Redact() {
+	s.Context = ""
+	s.DefaultContext = ""
+	s.ContextAlias = ""
+	s.Profile = ""
+	s.Redacted = true
+}
+
+// ContextLabel returns the context's alias, or its name if it has none, for
+// prompts and status bars
+func (s *StatusSummary) ContextLabel() string {
+	if s.ContextAlias != "" {
+		return s.ContextAlias
+	}
+	return s.Context
+}
+
+// GetStatusSummaryPath returns the path to the status summary file
+func GetStatusSummaryPath() string {
+	return filepath.Join(GetStateDir(), statusSummaryFileName)
+}
+
+// StatusSummaryPathForState returns the status summary path that belongs
+// with a state file
+func StatusSummaryPathForState(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), statusSummaryFileName)
+}
+
+// WriteStatusSummary atomically replaces the summary file at path.
+// The file is readable only by the user, like the rest of the state
+// directory, since context names can reveal customers and clusters.
+func WriteStatusSummary(path string, summary *StatusSummary) error {
+	summary.Version = statusSummaryVersion
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal status summary: %w", err)
+	}
+	data = append(data, '\n')
+
+	// Write to a temporary file in the same directory, then rename, so
+	// readers never see a partially written summary
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+statusSummaryFileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create status summary: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if err := writeSummaryFile(tmp, data); err != nil {
+		_ = os.Remove(tmpPath) // Don't leave a partial write behind
+		return fmt.Errorf("failed to write status summary: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath) // Don't leave a partial write behind
+		return fmt.Errorf("failed to rename status summary: %w", err)
+	}
+
+	return nil
+}
+
+// writeSummaryFile writes data to an open temporary file, makes it
+// private to the user, and flushes it to disk before closing
+func writeSummaryFile(f *os.File, data []byte) error {
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	// CreateTemp already makes the file 0600; don't rely on it
+	if err := f.Chmod(0600); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// ReadStatusSummary reads the summary file at path
+func ReadStatusSummary(path string) (*StatusSummary, error) {
+	// #nosec G304 -- path is the status summary path in the state directory
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status summary: %w", err)
+	}
+
+	var summary StatusSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse status summary: %w", err)
+	}
+
+	if summary.Version > statusSummaryVersion {
+		return nil, fmt.Errorf("status summary version %d is newer than supported version %d", summary.Version, statusSummaryVersion)
+	}
+
+	return &summary, nil
+}
@@ -0,0 +1,301 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteStatusSummary(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "status.json")
+
+	remaining := int64(90)
+	deadline := time.Now().Add(90 * time.Second).Truncate(time.Second)
+	summary := &StatusSummary{
+		UpdatedAt:        time.Now().Truncate(time.Second),
+		State:            SummaryStateActive,
+		Context:          "production",
+		DefaultContext:   "local",
+		Deadline:         &deadline,
+		RemainingSeconds: &remaining,
+		TimeoutSeconds:   1800,
+		DaemonPID:        os.Getpid(),
+	}
+
+	if err := WriteStatusSummary(path, summary); err != nil {
+		t.Fatalf("WriteStatusSummary failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat summary: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected summary permissions 0600, got %o", perm)
+	}
+
+	loaded, err := ReadStatusSummary(path)
+	if err != nil {
+		t.Fatalf("ReadStatusSummary failed: %v", err)
+	}
+	if loaded.Version != statusSummaryVersion {
+		t.Errorf("Expected version %d, got %d", statusSummaryVersion, loaded.Version)
+	}
+	if loaded.State != SummaryStateActive || loaded.Context != "production" || loaded.DefaultContext != "local" {
+		t.Errorf("Unexpected summary: %+v", loaded)
+	}
+	if loaded.RemainingSeconds == nil || *loaded.RemainingSeconds != 90 {
+		t.Errorf("Expected 90 remaining seconds, got %v", loaded.RemainingSeconds)
+	}
+	if loaded.Deadline == nil || !loaded.Deadline.Equal(deadline) {
+		t.Errorf("Expected deadline %v, got %v", deadline, loaded.Deadline)
+	}
+
+	// Only the summary itself is left behind
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the summary file, found %d entries", len(entries))
+	}
+}
+
+func TestWriteStatusSummaryOmitsInactiveFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+
+	if err := WriteStatusSummary(path, &StatusSummary{State: SummaryStateDefault, Context: "local"}); err != nil {
+		t.Fatalf("WriteStatusSummary failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read summary: %v", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("Summary is not valid JSON: %v", err)
+	}
+	for _, key := range []string{"deadline", "remaining_seconds", "extended_until", "degraded_reason"} {
+		if _, ok := raw[key]; ok {
+			t.Errorf("Expected %q to be omitted in the default state", key)
+		}
+	}
+}
+
+func TestReadStatusSummaryNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	content := fmt.Sprintf(`{"version": %d, "state": "active"}`, statusSummaryVersion+1)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write summary: %v", err)
+	}
+
+	if _, err := ReadStatusSummary(path); err == nil {
+		t.Error("Expected error for newer summary version")
+	}
+}
+
+// newSummaryTestDaemon returns a daemon with just enough set up to build and
+// publish status summaries
+func newSummaryTestDaemon(t *testing.T) *Daemon {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	sm, err := NewStateManager(filepath.Join(tmpDir, "state.json"))
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.DefaultContext = "local"
+	config.Timeout.Default = 10 * time.Minute
+	config.Safety.NeverSwitchFrom = []string{"pinned"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return &Daemon{
+		config:       config,
+		stateManager: sm,
+		ctx:          ctx,
+		cancel:       cancel,
+		logger:       discardLogger(),
+		degraded:     newDegradedTracker(time.Hour),
+		summaryPath:  filepath.Join(tmpDir, statusSummaryFileName),
+	}
+}
+
+func TestDaemonBuildStatusSummary(t *testing.T) {
+	d := newSummaryTestDaemon(t)
+
+	if err := RecordActivity(d.stateManager, "production"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	lastActivity, _, err := LastActivity(d.stateManager)
+	if err != nil {
+		t.Fatalf("GetLastActivity failed: %v", err)
+	}
+
+	now := lastActivity.Add(4 * time.Minute)
+	summary := d.buildStatusSummary(now)
+	if summary.State != SummaryStateActive {
+		t.Fatalf("Expected active state, got %q", summary.State)
+	}
+	if summary.Context != "production" || summary.DefaultContext != "local" {
+		t.Errorf("Unexpected contexts: %+v", summary)
+	}
+	if summary.TimeoutSeconds != 600 {
+		t.Errorf("Expected timeout 600s, got %d", summary.TimeoutSeconds)
+	}
+	if summary.RemainingSeconds == nil || *summary.RemainingSeconds != 360 {
+		t.Errorf("Expected 360 remaining seconds, got %v", summary.RemainingSeconds)
+	}
+	if summary.Deadline == nil || !summary.Deadline.Equal(lastActivity.Add(10*time.Minute)) {
+		t.Errorf("Unexpected deadline: %v", summary.Deadline)
+	}
+
+	// Remaining time never goes negative
+	summary = d.buildStatusSummary(lastActivity.Add(time.Hour))
+	if summary.RemainingSeconds == nil || *summary.RemainingSeconds != 0 {
+		t.Errorf("Expected 0 remaining seconds after timeout, got %v", summary.RemainingSeconds)
+	}
+
+	// Extension
+	until, err := ExtendDeadline(d.stateManager, time.Hour)
+	if err != nil {
+		t.Fatalf("ExtendDeadline failed: %v", err)
+	}
+	summary = d.buildStatusSummary(time.Now())
+	if summary.State != SummaryStateExtended || summary.ExtendedUntil == nil || !summary.ExtendedUntil.Equal(until) {
+		t.Errorf("Expected extended state until %v, got %+v", until, summary)
+	}
+	if summary.RemainingSeconds != nil {
+		t.Error("Expected no remaining seconds while extended")
+	}
+
+	// Exempt and default contexts
+	if err := RecordActivity(d.stateManager, "pinned"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	if summary = d.buildStatusSummary(time.Now()); summary.State != SummaryStateExempt {
+		t.Errorf("Expected exempt state, got %q", summary.State)
+	}
+	if err := RecordActivity(d.stateManager, "local"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	if summary = d.buildStatusSummary(time.Now()); summary.State != SummaryStateDefault {
+		t.Errorf("Expected default state, got %q", summary.State)
+	}
+
+	// Degraded
+	d.handleCheckResult(fmt.Errorf("%w: %w", errContextUnavailable, errors.New("exit status 1")))
+	summary = d.buildStatusSummary(time.Now())
+	if summary.State != SummaryStateDegraded || summary.DegradedReason == "" {
+		t.Errorf("Expected degraded state with a reason, got %+v", summary)
+	}
+}
+
+func TestDaemonPublishStatusSummary(t *testing.T) {
+	d := newSummaryTestDaemon(t)
+	d.pidFile = NewPIDFileWithPath(filepath.Join(t.TempDir(), "daemon.pid"))
+	d.shutdownTimeout = time.Second
+
+	if err := RecordActivity(d.stateManager, "production"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+
+	d.refreshStatusSummary()
+	summary, err := ReadStatusSummary(d.summaryPath)
+	if err != nil {
+		t.Fatalf("ReadStatusSummary failed: %v", err)
+	}
+	if summary.State != SummaryStateActive || summary.DaemonPID != os.Getpid() {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+
+	// Shutdown marks the summary as stopped
+	d.Shutdown()
+	summary, err = ReadStatusSummary(d.summaryPath)
+	if err != nil {
+		t.Fatalf("ReadStatusSummary failed: %v", err)
+	}
+	if summary.State != SummaryStateStopped || summary.RemainingSeconds != nil {
+		t.Errorf("Expected stopped summary without a countdown, got %+v", summary)
+	}
+
+	// No further updates after shutdown
+	if err := RecordActivity(d.stateManager, "staging"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+	d.refreshStatusSummary()
+	summary, err = ReadStatusSummary(d.summaryPath)
+	if err != nil {
+		t.Fatalf("ReadStatusSummary failed: %v", err)
+	}
+	if summary.State != SummaryStateStopped || summary.Context != "production" {
+		t.Errorf("Expected summary to stay stopped after shutdown, got %+v", summary)
+	}
+}
+
+func TestDaemonPublishStatusSummaryRedacted(t *testing.T) {
+	d := newSummaryTestDaemon(t)
+	d.config.State.Encrypt = true
+	d.config.Aliases = map[string]string{"prod": "production"}
+
+	if err := RecordActivity(d.stateManager, "production"); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
+	}
+
+	d.publishStatusSummary(false)
+	data, err := os.ReadFile(d.summaryPath)
+	if err != nil {
+		t.Fatalf("Failed to read summary: %v", err)
+	}
+	for _, name := range []string{"production", "prod", "local"} {
+		if strings.Contains(string(data), `"`+name+`"`) {
+			t.Errorf("Expected %q left out of the summary, got:\n%s", name, data)
+		}
+	}
+
+	summary, err := ReadStatusSummary(d.summaryPath)
+	if err != nil {
+		t.Fatalf("ReadStatusSummary failed: %v", err)
+	}
+	if !summary.Redacted || summary.State != SummaryStateActive || summary.Deadline == nil {
+		t.Errorf("Expected a redacted summary with the countdown, got %+v", summary)
+	}
+
+	// The status command, over the control socket, still names the context
+	if full := d.buildStatusSummary(time.Now()); full.Context != "production" || full.Redacted {
+		t.Errorf("Expected the built summary to keep the context, got %+v", full)
+	}
+}
+
+func TestDaemonPublishStatusSummaryLogsFailureOnce(t *testing.T) {
+	d := newSummaryTestDaemon(t)
+	var buf strings.Builder
+	d.logger = NewLogger(&buf, LogFormatText, slog.LevelDebug)
+	d.summaryPath = filepath.Join(t.TempDir(), "missing", statusSummaryFileName)
+
+	for i := 0; i < 3; i++ {
+		d.publishStatusSummary(false)
+	}
+	if count := strings.Count(buf.String(), "Failed to write status summary"); count != 1 {
+		t.Errorf("Expected 1 warning, got %d:\n%s", count, buf.String())
+	}
+
+	d.summaryPath = filepath.Join(t.TempDir(), statusSummaryFileName)
+	d.publishStatusSummary(false)
+	if !strings.Contains(buf.String(), "recovered") {
+		t.Errorf("Expected recovery message, got:\n%s", buf.String())
+	}
+}
@@ -2,49 +2,243 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"math/rand/v2"
+	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// DefaultKubectlTimeout bounds each kubectl command when kubectl_timeout is
+// unset
+const DefaultKubectlTimeout = 10 * time.Second
+
+// kubectlTimeout is kubectl_timeout in nanoseconds, or 0 for the default.
+// It is shared by every kubectl command, including those run without a
+// ContextSwitcher.
+var kubectlTimeout atomic.Int64
+
+// SetKubectlTimeout sets how long each kubectl command may run before it is
+// killed. Zero restores DefaultKubectlTimeout.
+func SetKubectlTimeout(d time.Duration) {
+	kubectlTimeout.Store(int64(d))
+}
+
+// KubectlTimeout returns how long each kubectl command may run
+func KubectlTimeout() time.Duration {
+	if d := time.Duration(kubectlTimeout.Load()); d > 0 {
+		return d
+	}
+	return DefaultKubectlTimeout
+}
+
+// Switcher reads and switches the kubectl context on behalf of the daemon.
+// ContextSwitcher implements it with kubectl; tests and embedders can
+// substitute their own to simulate failures or slow switches.
+type Switcher interface {
+	// CurrentContext returns the active kubectl context
+	CurrentContext() (string, error)
+	// SwitchContextSafe switches to targetContext unless neverSwitchTo
+	// reports it as forbidden, as Config.IsNeverSwitchTo does
+	SwitchContextSafe(targetContext string, neverSwitchTo func(context string) bool) error
+}
+
+// NamespaceSetter is a Switcher that can also set a context's namespace,
+// for timeout.reset_namespace. ContextSwitcher implements it.
+type NamespaceSetter interface {
+	// SetContextNamespace sets the namespace kubectl uses in a context
+	SetContextNamespace(context, namespace string) error
+}
+
+// RetryConfigurer is a Switcher whose retry policy follows the switcher
+// config section. ContextSwitcher implements it.
+type RetryConfigurer interface {
+	// SetRetryPolicy replaces how failed switches are retried
+	SetRetryPolicy(config SwitcherConfig)
+}
+
+// KubeconfigSwitcher is a Switcher that can also act on a KUBECONFIG other
+// than the daemon's own, to time out per-shell kubeconfig sessions.
+// ContextSwitcher implements it.
+type KubeconfigSwitcher interface {
+	Switcher
+	// ForKubeconfig returns a Switcher acting on the given KUBECONFIG
+	ForKubeconfig(kubeconfig string) Switcher
+}
+
+// Retry backoff strategies for switcher.backoff
+const (
+	// BackoffFixed waits retry_delay between every attempt
+	BackoffFixed = "fixed"
+	// BackoffExponential doubles the wait after each failed retry, up to
+	// max_retry_delay
+	BackoffExponential = "exponential"
+)
+
+// Defaults for unset switcher settings
+const (
+	DefaultSwitchRetries       = 3
+	DefaultSwitchRetryDelay    = time.Second
+	DefaultSwitchMaxRetryDelay = 30 * time.Second
+)
+
+// SwitcherConfig holds how a context switch is retried when kubectl fails.
+// Zero fields use the defaults: 3 attempts 1s apart.
+type SwitcherConfig struct {
+	// MaxRetries is how many times each switch is attempted in all
+	MaxRetries int `yaml:"max_retries,omitempty"`
+
+	// RetryDelay is the wait before the first retry
+	RetryDelay time.Duration `yaml:"retry_delay,omitempty"`
+
+	// Backoff is fixed (the default) or exponential
+	Backoff string `yaml:"backoff,omitempty"`
+
+	// MaxRetryDelay caps the exponential backoff
+	MaxRetryDelay time.Duration `yaml:"max_retry_delay,omitempty"`
+
+	// Jitter waits a random time between half and all of each delay
+	Jitter bool `yaml:"jitter,omitempty"`
+}
+
+// withDefaults returns the config with unset fields filled in
+func (c SwitcherConfig) withDefaults() SwitcherConfig {
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultSwitchRetries
+	}
+	if c.RetryDelay == 0 {
+		c.RetryDelay = DefaultSwitchRetryDelay
+	}
+	if c.Backoff == "" {
+		c.Backoff = BackoffFixed
+	}
+	if c.MaxRetryDelay == 0 {
+		c.MaxRetryDelay = DefaultSwitchMaxRetryDelay
+	}
+	return c
+}
+
+// delay returns how long to wait after the given failed attempt, counting
+// from 1
+func (c SwitcherConfig) delay(attempt int) time.Duration {
+	delay := c.RetryDelay
+	if c.Backoff == BackoffExponential {
+		for i := 1; i < attempt && delay < c.MaxRetryDelay; i++ {
+			delay *= 2
+		}
+		delay = min(delay, max(c.MaxRetryDelay, c.RetryDelay))
+	}
+	if c.Jitter && delay > 1 {
+		// #nosec G404 -- jitter only spreads out retries
+		delay = delay/2 + rand.N(delay/2+1)
+	}
+	return delay
+}
+
+// validationErrors returns every problem with the switcher settings
+func (c SwitcherConfig) validationErrors() []error {
+	var errs []error
+	if c.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("switcher.max_retries must not be negative"))
+	}
+	if c.RetryDelay < 0 {
+		errs = append(errs, fmt.Errorf("switcher.retry_delay must not be negative"))
+	}
+	if c.MaxRetryDelay < 0 {
+		errs = append(errs, fmt.Errorf("switcher.max_retry_delay must not be negative"))
+	}
+	switch c.Backoff {
+	case "", BackoffFixed, BackoffExponential:
+	default:
+		errs = append(errs, fmt.Errorf("switcher.backoff must be one of: fixed, exponential"))
+	}
+	return errs
+}
+
 // ContextSwitcher handles safe kubectl context switching
 type ContextSwitcher struct {
-	logger     *log.Logger
-	maxRetries int
-	retryDelay time.Duration
+	logger *slog.Logger
+
+	// retry is the retry policy with defaults filled in. The daemon
+	// replaces it when the config is reloaded.
+	retryMu sync.Mutex
+	retry   SwitcherConfig
+
+	// client reads and changes the kubeconfig
+	client KubeClient
+
+	// kubeconfig is the per-shell KUBECONFIG the client acts on, or "" for
+	// the daemon's own
+	kubeconfig string
+}
+
+// NewContextSwitcher creates a new context switcher that runs kubectl. A
+// nil logger discards its output.
+func NewContextSwitcher(logger *slog.Logger) *ContextSwitcher {
+	return NewContextSwitcherWithClient(logger, ExecKubeClient{})
+}
+
+// NewContextSwitcherWithClient creates a context switcher that reads and
+// changes the kubeconfig through client. A nil logger discards its output.
+func NewContextSwitcherWithClient(logger *slog.Logger, client KubeClient) *ContextSwitcher {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	return &ContextSwitcher{
+		logger: logger.With("component", "switcher"),
+		retry:  SwitcherConfig{}.withDefaults(),
+		client: client,
+	}
+}
+
+// SetRetryPolicy replaces how failed switches are retried
+func (cs *ContextSwitcher) SetRetryPolicy(config SwitcherConfig) {
+	cs.retryMu.Lock()
+	defer cs.retryMu.Unlock()
+	cs.retry = config.withDefaults()
 }
 
-// NewContextSwitcher creates a new context switcher
-func NewContextSwitcher(logger *log.Logger) *ContextSwitcher {
+// retryPolicy returns the current retry policy
+func (cs *ContextSwitcher) retryPolicy() SwitcherConfig {
+	cs.retryMu.Lock()
+	defer cs.retryMu.Unlock()
+	return cs.retry
+}
+
+// ForKubeconfig returns a switcher acting on the given KUBECONFIG instead
+// of the daemon's own. Clients that can't act on another KUBECONFIG are
+// replaced by kubectl.
+func (cs *ContextSwitcher) ForKubeconfig(kubeconfig string) Switcher {
+	var client KubeClient = ExecKubeClient{Kubeconfig: kubeconfig}
+	if kc, ok := cs.client.(KubeconfigClient); ok {
+		client = kc.ForKubeconfig(kubeconfig)
+	}
 	return &ContextSwitcher{
-		logger:     logger,
-		maxRetries: 3,
-		retryDelay: 1 * time.Second,
+		logger:     cs.logger.With("kubeconfig", kubeconfig),
+		retry:      cs.retryPolicy(),
+		client:     client,
+		kubeconfig: kubeconfig,
 	}
 }
 
+// CurrentContext returns the current kubectl context
+func (cs *ContextSwitcher) CurrentContext() (string, error) {
+	return cs.client.CurrentContext()
+}
+
 // ListContexts returns a list of available kubectl contexts
 func (cs *ContextSwitcher) ListContexts() ([]string, error) {
-	return GetAvailableContexts()
+	return cs.client.ListContexts()
 }
 
 // GetAvailableContexts returns a list of all available kubectl contexts (global helper)
 func GetAvailableContexts() ([]string, error) {
-	cmd := exec.Command("kubectl", "config", "get-contexts", "-o", "name")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list contexts: %w", err)
-	}
-
-	contextsStr := strings.TrimSpace(string(output))
-	if contextsStr == "" {
-		return []string{}, nil
-	}
-
-	contexts := strings.Split(contextsStr, "\n")
-	return contexts, nil
+	return ExecKubeClient{}.ListContexts()
 }
 
 // ValidateContext checks if a context exists in kubectl config
@@ -66,71 +260,117 @@ func (cs *ContextSwitcher) ValidateContext(contextName string) error {
 // SwitchContext switches to the specified kubectl context with retry logic
 func (cs *ContextSwitcher) SwitchContext(targetContext string) error {
 	// Get current context
-	currentContext, err := GetCurrentContext()
+	currentContext, err := cs.CurrentContext()
 	if err != nil {
 		return fmt.Errorf("failed to get current context: %w", err)
 	}
 
 	// Check if already on target context
 	if currentContext == targetContext {
-		cs.logger.Printf("Already on context '%s', no switch needed", targetContext)
+		cs.logger.Debug("Already on target context, no switch needed", "context", targetContext)
 		return nil
 	}
 
 	// Validate target context exists
 	if err := cs.ValidateContext(targetContext); err != nil {
-		return err
+		if cs.kubeconfig == "" {
+			return err
+		}
+		// Per-shell kubeconfigs often hold only the context the shell was
+		// started for; leaving them on no context is the safe equivalent
+		cs.logger.Info("Target context is not in this kubeconfig, unsetting the current context instead", "context", targetContext)
+		return cs.unsetCurrentContext()
 	}
 
 	// Attempt to switch with retry logic
+	retry := cs.retryPolicy()
 	var lastErr error
-	for attempt := 1; attempt <= cs.maxRetries; attempt++ {
-		cs.logger.Printf("Switching context from '%s' to '%s' (attempt %d/%d)",
-			currentContext, targetContext, attempt, cs.maxRetries)
+	for attempt := 1; attempt <= retry.MaxRetries; attempt++ {
+		cs.logger.Info("Switching context",
+			"from", currentContext, "context", targetContext, "attempt", attempt, "max_attempts", retry.MaxRetries)
 
 		err := cs.executeSwitch(targetContext)
 		if err == nil {
-			cs.logger.Printf("Successfully switched context to '%s'", targetContext)
+			cs.logger.Info("Switched context", "context", targetContext)
 			return nil
 		}
 
 		lastErr = err
-		cs.logger.Printf("Context switch attempt %d failed: %v", attempt, err)
+		cs.logger.Warn("Context switch attempt failed", "context", targetContext, "attempt", attempt, "error", err)
 
 		// Wait before retry (except on last attempt)
-		if attempt < cs.maxRetries {
-			cs.logger.Printf("Retrying in %v...", cs.retryDelay)
-			time.Sleep(cs.retryDelay)
+		if attempt < retry.MaxRetries {
+			delay := retry.delay(attempt)
+			cs.logger.Debug("Retrying context switch", "delay", delay)
+			time.Sleep(delay)
 		}
 	}
 
-	return fmt.Errorf("failed to switch context after %d attempts: %w", cs.maxRetries, lastErr)
+	return fmt.Errorf("failed to switch context after %d attempts: %w", retry.MaxRetries, lastErr)
 }
 
 // executeSwitch performs the actual context switch
 func (cs *ContextSwitcher) executeSwitch(targetContext string) error {
-	// #nosec G204 -- targetContext is validated against kubectl config get-contexts output before use
-	cmd := exec.Command("kubectl", "config", "use-context", targetContext)
+	return cs.client.UseContext(targetContext)
+}
+
+// SetContextNamespace sets the namespace kubectl uses in a context
+func (cs *ContextSwitcher) SetContextNamespace(context, namespace string) error {
+	nc, ok := cs.client.(namespaceClient)
+	if !ok {
+		return fmt.Errorf("kube client can't set namespaces")
+	}
+	return nc.SetContextNamespace(context, namespace)
+}
+
+// unsetCurrentContext leaves the kubeconfig without a current context, so
+// kubectl refuses to run until the user picks one
+func (cs *ContextSwitcher) unsetCurrentContext() error {
+	if err := cs.client.UnsetContext(); err != nil {
+		return err
+	}
+
+	cs.logger.Info("Unset current context")
+	return nil
+}
+
+// runKubectl runs kubectl with the given KUBECONFIG, or the inherited one if
+// it is empty, and returns its output. kubectl is killed if it runs past
+// kubectl_timeout, since a broken auth plugin or unreachable exec
+// credential can hang it forever. Errors include what it wrote to stderr.
+func runKubectl(kubeconfig string, args ...string) ([]byte, error) {
+	timeout := KubectlTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// #nosec G204 -- callers pass fixed kubectl subcommands and validated context names
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	if kubeconfig != "" {
+		cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfig)
+	}
+	// Don't wait on a credential plugin left holding the output
+	cmd.WaitDelay = time.Second
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
-
 	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, MarkFailure(fmt.Errorf("kubectl %s timed out after %v", strings.Join(args[:min(len(args), 2)], " "), timeout), ErrKubectlFailed)
+	}
 	if err != nil {
-		return fmt.Errorf("kubectl command failed: %w, stderr: %s", err, stderr.String())
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, MarkFailure(fmt.Errorf("%w, stderr: %s", err, msg), ErrKubectlFailed)
+		}
+		return nil, MarkFailure(err, ErrKubectlFailed)
 	}
-
-	cs.logger.Printf("kubectl output: %s", strings.TrimSpace(string(output)))
-	return nil
+	return output, nil
 }
 
 // SwitchContextSafe is a wrapper that includes additional safety checks
-func (cs *ContextSwitcher) SwitchContextSafe(targetContext string, neverSwitchTo []string) error {
-	// Check if target is in never_switch_to list
-	for _, ctx := range neverSwitchTo {
-		if ctx == targetContext {
-			return fmt.Errorf("cannot switch to context '%s': it is in the never_switch_to list", targetContext)
-		}
+func (cs *ContextSwitcher) SwitchContextSafe(targetContext string, neverSwitchTo func(context string) bool) error {
+	// Check if target matches never_switch_to or never_switch_to_clusters
+	if neverSwitchTo != nil && neverSwitchTo(targetContext) {
+		return MarkFailure(fmt.Errorf("cannot switch to context '%s': it is in the never_switch_to list", targetContext), ErrSwitchRefused)
 	}
 
 	return cs.SwitchContext(targetContext)
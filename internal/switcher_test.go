@@ -1,13 +1,17 @@
 package internal
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewContextSwitcher(t *testing.T) {
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
+	logger := NewLogger(os.Stdout, LogFormatText, slog.LevelDebug)
 	cs := NewContextSwitcher(logger)
 
 	if cs == nil {
@@ -18,19 +22,21 @@ func TestNewContextSwitcher(t *testing.T) {
 		t.Error("ContextSwitcher has nil logger")
 	}
 
-	if cs.maxRetries != 3 {
-		t.Errorf("expected maxRetries to be 3, got %d", cs.maxRetries)
+	if cs.retry.MaxRetries != 3 {
+		t.Errorf("expected maxRetries to be 3, got %d", cs.retry.MaxRetries)
 	}
 }
 
-func TestListContexts(t *testing.T) {
-	// Setup isolated test environment to avoid leaking real context names
-	tmpDir := t.TempDir()
-	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
-	defer restoreKubeconfig()
+// newTestContextSwitcher returns a switcher on an in-memory kubeconfig
+// holding the test contexts, currently on test-default
+func newTestContextSwitcher() (*ContextSwitcher, *fakeKubeClient) {
+	client := &fakeKubeClient{current: "test-default", contexts: []string{"test-default", "test-prod", "test-stage"}}
+	logger := NewLogger(os.Stdout, LogFormatText, slog.LevelDebug)
+	return NewContextSwitcherWithClient(logger, client), client
+}
 
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-	cs := NewContextSwitcher(logger)
+func TestListContexts(t *testing.T) {
+	cs, _ := newTestContextSwitcher()
 
 	contexts, err := cs.ListContexts()
 	if err != nil {
@@ -38,10 +44,10 @@ func TestListContexts(t *testing.T) {
 	}
 
 	if len(contexts) == 0 {
-		t.Fatal("No contexts found from isolated kubeconfig")
+		t.Fatal("No contexts found")
 	}
 
-	// Verify we got test contexts from isolated kubeconfig
+	// Verify we got the test contexts
 	expectedContexts := []string{"test-default", "test-prod", "test-stage"}
 	if len(contexts) != len(expectedContexts) {
 		t.Errorf("Expected %d contexts, got %d", len(expectedContexts), len(contexts))
@@ -54,15 +60,9 @@ func TestListContexts(t *testing.T) {
 }
 
 func TestValidateContext(t *testing.T) {
-	// Setup isolated test environment to avoid leaking real context names
-	tmpDir := t.TempDir()
-	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
-	defer restoreKubeconfig()
+	cs, _ := newTestContextSwitcher()
 
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-	cs := NewContextSwitcher(logger)
-
-	// Test validating an existing context from isolated kubeconfig
+	// Test validating an existing context
 	err := cs.ValidateContext("test-default")
 	if err != nil {
 		t.Errorf("ValidateContext failed for existing context 'test-default': %v", err)
@@ -76,15 +76,8 @@ func TestValidateContext(t *testing.T) {
 }
 
 func TestSwitchContextSameContext(t *testing.T) {
-	// Setup isolated test environment
-	tmpDir := t.TempDir()
-	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
-	defer restoreKubeconfig()
-
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-	cs := NewContextSwitcher(logger)
+	cs, client := newTestContextSwitcher()
 
-	// Use test context from isolated kubeconfig
 	currentContext := "test-default"
 
 	// Try to switch to the same context (should be no-op)
@@ -93,8 +86,8 @@ func TestSwitchContextSameContext(t *testing.T) {
 		t.Errorf("SwitchContext failed when switching to same context: %v", err)
 	}
 
-	// Verify we're still on the same context
-	afterContext, err := GetCurrentContext()
+	// Verify we're still on the same context, without a switch
+	afterContext, err := client.CurrentContext()
 	if err != nil {
 		t.Fatalf("Failed to get context after switch: %v", err)
 	}
@@ -102,35 +95,33 @@ func TestSwitchContextSameContext(t *testing.T) {
 	if afterContext != currentContext {
 		t.Errorf("Context changed unexpectedly: %s -> %s", currentContext, afterContext)
 	}
+	if client.uses != 0 {
+		t.Errorf("Expected no context change, got %d", client.uses)
+	}
 }
 
 func TestSwitchContextNonExistent(t *testing.T) {
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-	cs := NewContextSwitcher(logger)
+	cs, client := newTestContextSwitcher()
 
 	// Try to switch to non-existent context
 	err := cs.SwitchContext("definitely-does-not-exist-context")
 	if err == nil {
 		t.Error("SwitchContext should have failed for non-existent context")
 	}
+	if client.uses != 0 {
+		t.Errorf("Expected the context validated before switching, got %d attempts", client.uses)
+	}
 }
 
 func TestSwitchContextSafe(t *testing.T) {
-	// Setup isolated test environment
-	tmpDir := t.TempDir()
-	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
-	defer restoreKubeconfig()
-
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-	cs := NewContextSwitcher(logger)
-
-	// Use test context from isolated kubeconfig
-	currentContext := "test-default"
+	cs, client := newTestContextSwitcher()
+	currentContext := "test-prod"
 
 	// Test with never_switch_to list containing the target context
-	neverSwitchTo := []string{"production", "prod", currentContext}
+	config := DefaultConfig()
+	config.Safety.NeverSwitchTo = []string{"production", "prod", currentContext}
 
-	err := cs.SwitchContextSafe(currentContext, neverSwitchTo)
+	err := cs.SwitchContextSafe(currentContext, config.IsNeverSwitchTo)
 	if err == nil {
 		t.Error("SwitchContextSafe should have failed when target is in never_switch_to list")
 	}
@@ -138,16 +129,31 @@ func TestSwitchContextSafe(t *testing.T) {
 	if err != nil && err.Error() != "" {
 		t.Logf("Expected error: %v", err)
 	}
+	if !errors.Is(err, ErrSwitchRefused) {
+		t.Errorf("Expected ErrSwitchRefused, got %v", err)
+	}
+
+	// Patterns in never_switch_to are matched too
+	config.Safety.NeverSwitchTo = []string{"/^test-/"}
+	if err := cs.SwitchContextSafe(currentContext, config.IsNeverSwitchTo); err == nil {
+		t.Error("SwitchContextSafe should have failed when target matches a never_switch_to pattern")
+	}
+
+	// And so are the clusters in never_switch_to_clusters
+	config.Safety.NeverSwitchTo = nil
+	config.Safety.NeverSwitchToClusters = []string{"https://*.prod.example.com"}
+	config.contextServer = func(string) string { return "https://api.prod.example.com:6443" }
+	if err := cs.SwitchContextSafe(currentContext, config.IsNeverSwitchTo); !errors.Is(err, ErrSwitchRefused) {
+		t.Errorf("Expected ErrSwitchRefused for a never_switch_to_clusters server, got %v", err)
+	}
+
+	if client.uses != 0 {
+		t.Errorf("Expected every switch refused before changing the context, got %d attempts", client.uses)
+	}
 }
 
 func TestExecuteSwitch(t *testing.T) {
-	// Setup isolated test environment
-	tmpDir := t.TempDir()
-	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
-	defer restoreKubeconfig()
-
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-	cs := NewContextSwitcher(logger)
+	cs, client := newTestContextSwitcher()
 
 	t.Run("valid context", func(t *testing.T) {
 		err := cs.executeSwitch("test-prod")
@@ -155,7 +161,7 @@ func TestExecuteSwitch(t *testing.T) {
 			t.Fatalf("executeSwitch failed for valid context: %v", err)
 		}
 
-		current, err := GetCurrentContext()
+		current, err := client.CurrentContext()
 		if err != nil {
 			t.Fatalf("Failed to get current context: %v", err)
 		}
@@ -175,15 +181,12 @@ func TestExecuteSwitch(t *testing.T) {
 }
 
 func TestSwitchContextWithRetry(t *testing.T) {
-	// Setup isolated test environment
-	tmpDir := t.TempDir()
-	restoreKubeconfig := setupTestKubeconfig(t, tmpDir)
-	defer restoreKubeconfig()
+	cs, client := newTestContextSwitcher()
+	cs.SetRetryPolicy(SwitcherConfig{RetryDelay: time.Millisecond})
 
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-	cs := NewContextSwitcher(logger)
+	// The first attempt fails, as kubectl does when the kubeconfig is locked
+	client.failures = 1
 
-	// Use test contexts from isolated kubeconfig
 	currentContext := "test-default"
 	targetContext := "test-prod"
 
@@ -196,7 +199,7 @@ func TestSwitchContextWithRetry(t *testing.T) {
 	}
 
 	// Verify the switch
-	afterContext, err := GetCurrentContext()
+	afterContext, err := client.CurrentContext()
 	if err != nil {
 		t.Fatalf("Failed to get context after switch: %v", err)
 	}
@@ -204,11 +207,84 @@ func TestSwitchContextWithRetry(t *testing.T) {
 	if afterContext != targetContext {
 		t.Errorf("Expected context '%s', got '%s'", targetContext, afterContext)
 	}
+	if client.uses != 2 {
+		t.Errorf("Expected the switch retried once, got %d attempts", client.uses)
+	}
 
-	// Switch back to original context for cleanup
-	t.Logf("Switching back to original context '%s'", currentContext)
-	err = cs.SwitchContext(currentContext)
-	if err != nil {
-		t.Errorf("Failed to switch back to original context: %v", err)
+	// Every attempt failing is reported
+	client.failures = DefaultSwitchRetries
+	if err := cs.SwitchContext(currentContext); err == nil {
+		t.Error("Expected SwitchContext to fail when every attempt fails")
+	}
+}
+
+func TestRunKubectlTimeout(t *testing.T) {
+	// A kubectl that hangs, like one stuck in a broken auth plugin
+	bin := t.TempDir()
+	script := "#!/bin/sh\nsleep 30\n"
+	if err := os.WriteFile(filepath.Join(bin, "kubectl"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake kubectl: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	SetKubectlTimeout(100 * time.Millisecond)
+	defer SetKubectlTimeout(0)
+
+	start := time.Now()
+	_, err := runKubectl("", "config", "current-context")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("runKubectl() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected kubectl killed at the timeout, took %v", elapsed)
+	}
+
+	if _, err := NewContextSwitcher(NewLogger(os.Stdout, LogFormatText, slog.LevelDebug)).ListContexts(); err == nil {
+		t.Error("Expected ListContexts() to fail when kubectl hangs")
+	}
+}
+
+func TestKubectlTimeoutDefault(t *testing.T) {
+	defer SetKubectlTimeout(0)
+
+	if got := KubectlTimeout(); got != DefaultKubectlTimeout {
+		t.Errorf("KubectlTimeout() = %v, want %v", got, DefaultKubectlTimeout)
+	}
+	SetKubectlTimeout(time.Minute)
+	if got := KubectlTimeout(); got != time.Minute {
+		t.Errorf("KubectlTimeout() = %v, want %v", got, time.Minute)
+	}
+}
+
+func TestSwitcherConfigDelay(t *testing.T) {
+	fixed := SwitcherConfig{RetryDelay: 2 * time.Second}.withDefaults()
+	for attempt := 1; attempt <= 3; attempt++ {
+		if got := fixed.delay(attempt); got != 2*time.Second {
+			t.Errorf("fixed delay(%d) = %v, want 2s", attempt, got)
+		}
+	}
+
+	exponential := SwitcherConfig{Backoff: BackoffExponential, MaxRetryDelay: 5 * time.Second}.withDefaults()
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := exponential.delay(attempt); got != want {
+			t.Errorf("exponential delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	jittered := SwitcherConfig{Backoff: BackoffExponential, Jitter: true}.withDefaults()
+	for i := 0; i < 100; i++ {
+		if got := jittered.delay(3); got < 2*time.Second || got > 4*time.Second {
+			t.Fatalf("jittered delay(3) = %v, want between 2s and 4s", got)
+		}
+	}
+}
+
+func TestSwitcherConfigValidation(t *testing.T) {
+	config := SwitcherConfig{MaxRetries: -1, RetryDelay: -time.Second, Backoff: "linear"}
+	if errs := config.validationErrors(); len(errs) != 3 {
+		t.Errorf("Expected 3 validation errors, got %v", errs)
+	}
+	if errs := (SwitcherConfig{Backoff: BackoffExponential, Jitter: true}).validationErrors(); len(errs) != 0 {
+		t.Errorf("Expected no validation errors, got %v", errs)
 	}
 }
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// switchNoticeFileName is the switch notice's name within the state directory.
+// The shell integration looks for it under this name before each command.
+const switchNoticeFileName = "switch-notice"
+
+// SwitchNotice tells the shell integration about an automatic switch the
+// user hasn't seen yet. The daemon writes it when it switches, and the next
+// wrapped command prints it once and removes it.
+type SwitchNotice struct {
+	// From and To are empty when state.encrypt is on, which keeps context
+	// names out of files written in plaintext
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+
+	// UndoUntil is when undo stops being able to reverse the switch, unset
+	// if undo is turned off
+	UndoUntil *time.Time `json:"undo_until,omitempty"`
+}
+
+// GetSwitchNoticePath returns the path to the switch notice file
+func GetSwitchNoticePath() string {
+	return filepath.Join(GetStateDir(), switchNoticeFileName)
+}
+
+// SwitchNoticePathForState returns the switch notice path that belongs with
+// a state file
+func SwitchNoticePathForState(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), switchNoticeFileName)
+}
+
+// NewSwitchNotice describes the switch last for the shell, which undo can
+// reverse within window of it
+func NewSwitchNotice(last LastSwitch, window time.Duration) SwitchNotice {
+	notice := SwitchNotice{From: last.From, To: last.To, At: last.At}
+	if window > 0 {
+		until := last.At.Add(window)
+		notice.UndoUntil = &until
+	}
+	return notice
+}
+
+// WriteSwitchNotice writes the notice to path, replacing any earlier one
+func WriteSwitchNotice(path string, notice SwitchNotice) error {
+	data, err := json.Marshal(notice)
+	if err != nil {
+		return fmt.Errorf("failed to marshal switch notice: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write switch notice: %w", err)
+	}
+	return nil
+}
+
+// TakeSwitchNotice reads and removes the notice at path. It returns nil if
+// there is none, or another shell took it first, so only one shell prints it.
+func TakeSwitchNotice(path string) (*SwitchNotice, error) {
+	// #nosec G304 -- path is the switch notice path in the state directory
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read switch notice: %w", err)
+	}
+
+	if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to remove switch notice: %w", err)
+	}
+
+	var notice SwitchNotice
+	if err := json.Unmarshal(data, &notice); err != nil {
+		return nil, fmt.Errorf("failed to parse switch notice: %w", err)
+	}
+	return &notice, nil
+}
+
+// RemoveSwitchNotice removes the notice at path, once the switch it
+// describes has been undone
+func RemoveSwitchNotice(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove switch notice: %w", err)
+	}
+	return nil
+}
+
+// Message describes the switch as of now, with how to undo it while that is
+// still possible, e.g. "context was reset from prod to dev 14m ago; run
+// 'kubectx-timeout undo' to restore"
+func (n SwitchNotice) Message(now time.Time) string {
+	ago := "just now"
+	if age := now.Sub(n.At); age >= time.Minute {
+		ago = formatAge(age) + " ago"
+	}
+
+	message := fmt.Sprintf("context was reset from %s to %s %s", n.From, n.To, ago)
+	if n.From == "" || n.To == "" {
+		message = "context was reset " + ago
+	}
+	if n.UndoUntil != nil && now.Before(*n.UndoUntil) {
+		message += "; run 'kubectx-timeout undo' to restore"
+	}
+	return message
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTakeSwitchNotice(t *testing.T) {
+	path := SwitchNoticePathForState(filepath.Join(t.TempDir(), "state.json"))
+
+	notice, err := TakeSwitchNotice(path)
+	if err != nil || notice != nil {
+		t.Fatalf("TakeSwitchNotice() with no notice = %v, %v, want nil, nil", notice, err)
+	}
+
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	last := LastSwitch{From: "prod", To: "dev", At: at}
+	if err := WriteSwitchNotice(path, NewSwitchNotice(last, 10*time.Minute)); err != nil {
+		t.Fatalf("WriteSwitchNotice() error = %v", err)
+	}
+
+	notice, err = TakeSwitchNotice(path)
+	if err != nil {
+		t.Fatalf("TakeSwitchNotice() error = %v", err)
+	}
+	if notice == nil || notice.From != "prod" || notice.To != "dev" || !notice.At.Equal(at) {
+		t.Fatalf("TakeSwitchNotice() = %+v, want the switch from prod to dev", notice)
+	}
+	if notice.UndoUntil == nil || !notice.UndoUntil.Equal(at.Add(10*time.Minute)) {
+		t.Errorf("UndoUntil = %v, want %v", notice.UndoUntil, at.Add(10*time.Minute))
+	}
+
+	// Only the first command tells of the switch
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the notice to be removed once taken, stat error = %v", err)
+	}
+	if notice, err := TakeSwitchNotice(path); err != nil || notice != nil {
+		t.Errorf("TakeSwitchNotice() again = %v, %v, want nil, nil", notice, err)
+	}
+
+	// Removing a notice that's gone is fine
+	if err := RemoveSwitchNotice(path); err != nil {
+		t.Errorf("RemoveSwitchNotice() error = %v", err)
+	}
+}
+
+func TestSwitchNoticeMessage(t *testing.T) {
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	last := LastSwitch{From: "prod", To: "dev", At: at}
+
+	tests := []struct {
+		name   string
+		window time.Duration
+		now    time.Time
+		want   string
+	}{
+		{
+			name:   "within undo window",
+			window: 30 * time.Minute,
+			now:    at.Add(14*time.Minute + 20*time.Second),
+			want:   "context was reset from prod to dev 14m ago; run 'kubectx-timeout undo' to restore",
+		},
+		{
+			name:   "just switched",
+			window: 30 * time.Minute,
+			now:    at.Add(10 * time.Second),
+			want:   "context was reset from prod to dev just now; run 'kubectx-timeout undo' to restore",
+		},
+		{
+			name:   "undo window passed",
+			window: 10 * time.Minute,
+			now:    at.Add(2 * time.Hour),
+			want:   "context was reset from prod to dev 2h ago",
+		},
+		{
+			name:   "undo turned off",
+			window: 0,
+			now:    at.Add(time.Minute),
+			want:   "context was reset from prod to dev 1m ago",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewSwitchNotice(last, tt.window).Message(tt.now); got != tt.want {
+				t.Errorf("Message() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// With state.encrypt on, the notice has no context names
+	redacted := SwitchNotice{At: at}
+	if got, want := redacted.Message(at.Add(5*time.Minute)), "context was reset 5m ago"; got != want {
+		t.Errorf("Message() = %q, want %q", got, want)
+	}
+}
+
+func TestRecordLastSwitchRedactsNotice(t *testing.T) {
+	d := newSummaryTestDaemon(t)
+	d.noticePath = filepath.Join(t.TempDir(), switchNoticeFileName)
+	config := d.config
+	config.State.Encrypt = true
+
+	d.recordLastSwitch(config, "production", "local")
+	notice, err := TakeSwitchNotice(d.noticePath)
+	if err != nil || notice == nil {
+		t.Fatalf("TakeSwitchNotice() = %v, %v, want a notice", notice, err)
+	}
+	if notice.From != "" || notice.To != "" {
+		t.Errorf("Expected no context names in the notice, got %+v", notice)
+	}
+
+	// Undo still knows where to switch back to
+	last := loadState(t, d.stateManager).LastSwitch()
+	if last.From != "production" || last.To != "local" {
+		t.Errorf("Expected the last switch recorded in the state, got %+v", last)
+	}
+}
@@ -0,0 +1,277 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	// SystemdUnitName is the name of the systemd user unit for the daemon
+	SystemdUnitName = "kubectx-timeout.service"
+
+	// SystemdUnitTemplate is the template for the systemd user unit file
+	SystemdUnitTemplate = `[Unit]
+Description=kubectx-timeout daemon (automatic kubectl context switching)
+Documentation=https://github.com/mrf/kubectx-timeout
+
+[Service]
+Type=simple
+ExecStart={{.BinaryPath}} daemon
+WorkingDirectory={{.HomeDir}}
+
+# Restart if it crashes, throttled to prevent rapid restarts
+Restart=on-failure
+RestartSec=10
+
+# Environment
+Environment=PATH={{.Path}}
+Environment=HOME={{.HomeDir}}
+
+# Output (XDG Base Directory compliant)
+StandardOutput=append:{{.StdoutPath}}
+StandardError=append:{{.StderrPath}}
+
+# Lower priority
+Nice=1
+
+[Install]
+WantedBy=default.target
+`
+)
+
+// SystemdManager handles systemd user unit operations for Linux
+type SystemdManager struct {
+	unitName   string
+	unitPath   string
+	binaryPath string
+}
+
+// NewSystemdManager creates a new systemd manager instance
+func NewSystemdManager(binaryPath string) (*SystemdManager, error) {
+	// Verify we're on Linux
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("systemd is only available on Linux")
+	}
+
+	unitPath, err := GetSystemdUnitPath()
+	if err != nil {
+		return nil, err
+	}
+
+	// If no binary path specified, try to find the current executable
+	if binaryPath == "" {
+		execPath, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine executable path: %w", err)
+		}
+		// Resolve symlinks
+		binaryPath, err = filepath.EvalSymlinks(execPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve executable path: %w", err)
+		}
+	}
+
+	return &SystemdManager{
+		unitName:   SystemdUnitName,
+		unitPath:   unitPath,
+		binaryPath: binaryPath,
+	}, nil
+}
+
+// GetSystemdUnitPath returns the path to the systemd user unit file.
+// Returns $XDG_CONFIG_HOME/systemd/user/kubectx-timeout.service if set,
+// otherwise ~/.config/systemd/user/kubectx-timeout.service
+func GetSystemdUnitPath() (string, error) {
+	if xdgConfig := os.Getenv("XDG_CONFIG_HOME"); xdgConfig != "" {
+		return filepath.Join(xdgConfig, "systemd", "user", SystemdUnitName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".config", "systemd", "user", SystemdUnitName), nil
+}
+
+// Name returns the name of the service manager
+func (sm *SystemdManager) Name() string {
+	return "systemd"
+}
+
+// Install writes the unit file and enables and starts the daemon
+func (sm *SystemdManager) Install() error {
+	// Check if already installed
+	if sm.IsInstalled() {
+		return MarkFailure(fmt.Errorf("daemon is already installed at %s", sm.unitPath), ErrAlreadyInstalled)
+	}
+
+	// Ensure systemd user unit directory exists
+	unitDir := filepath.Dir(sm.unitPath)
+	if err := os.MkdirAll(unitDir, 0750); err != nil {
+		return fmt.Errorf("failed to create systemd user directory: %w", err)
+	}
+
+	// Ensure state directory exists
+	stateDir := GetStateDir()
+	if err := os.MkdirAll(stateDir, 0750); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Generate unit content
+	unitContent, err := sm.generateUnit()
+	if err != nil {
+		return fmt.Errorf("failed to generate unit file: %w", err)
+	}
+
+	// Write unit file
+	if err := os.WriteFile(sm.unitPath, []byte(unitContent), 0600); err != nil {
+		return fmt.Errorf("failed to write unit file: %w", err)
+	}
+
+	// Load the daemon
+	if err := sm.Load(); err != nil {
+		// If load fails, clean up the unit file
+		_ = os.Remove(sm.unitPath) // Ignore error on cleanup
+		return fmt.Errorf("failed to load daemon: %w", err)
+	}
+
+	return nil
+}
+
+// Uninstall stops and disables the daemon and removes the unit file
+func (sm *SystemdManager) Uninstall() error {
+	// Check if installed
+	if !sm.IsInstalled() {
+		return fmt.Errorf("daemon is not installed")
+	}
+
+	// Stop and disable
+	if err := sm.Unload(); err != nil {
+		return fmt.Errorf("failed to unload daemon: %w", err)
+	}
+
+	// Remove unit file
+	if err := os.Remove(sm.unitPath); err != nil {
+		return fmt.Errorf("failed to remove unit file: %w", err)
+	}
+
+	// Let systemd forget the removed unit (ignore errors, unit is already gone)
+	_ = sm.systemctl("daemon-reload")
+
+	return nil
+}
+
+// Restart restarts the daemon
+func (sm *SystemdManager) Restart() error {
+	if !sm.IsInstalled() {
+		return fmt.Errorf("daemon is not installed. Run 'kubectx-timeout daemon-install' first")
+	}
+
+	if err := sm.systemctl("restart", sm.unitName); err != nil {
+		return fmt.Errorf("failed to restart daemon: %w", err)
+	}
+
+	return nil
+}
+
+// Load enables and starts the daemon using systemctl --user
+func (sm *SystemdManager) Load() error {
+	// Pick up any changes to the unit file
+	if err := sm.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return sm.systemctl("enable", "--now", sm.unitName)
+}
+
+// Unload stops and disables the daemon using systemctl --user
+func (sm *SystemdManager) Unload() error {
+	return sm.systemctl("disable", "--now", sm.unitName)
+}
+
+// IsInstalled checks if the unit file exists
+func (sm *SystemdManager) IsInstalled() bool {
+	_, err := os.Stat(sm.unitPath)
+	return err == nil
+}
+
+// IsRunning checks if the daemon is currently active
+func (sm *SystemdManager) IsRunning() bool {
+	// #nosec G204 - unitName is a constant (SystemdUnitName)
+	cmd := exec.Command("systemctl", "--user", "is-active", "--quiet", sm.unitName)
+	return cmd.Run() == nil
+}
+
+// GetStatus returns the daemon status information
+func (sm *SystemdManager) GetStatus() (string, error) {
+	installed := sm.IsInstalled()
+	running := sm.IsRunning()
+
+	var status strings.Builder
+	status.WriteString("Daemon Status:\n")
+	status.WriteString(fmt.Sprintf("  Installed: %v\n", installed))
+	status.WriteString(fmt.Sprintf("  Running: %v\n", running))
+	status.WriteString(fmt.Sprintf("  Unit Path: %s\n", sm.unitPath))
+	status.WriteString(fmt.Sprintf("  Binary Path: %s\n", sm.binaryPath))
+
+	if installed {
+		// Get detailed status from systemctl
+		// #nosec G204 - unitName is a constant (SystemdUnitName)
+		cmd := exec.Command("systemctl", "--user", "status", "--no-pager", sm.unitName)
+		// systemctl status exits non-zero for inactive units, so show output regardless
+		output, _ := cmd.CombinedOutput()
+		if len(output) > 0 {
+			status.WriteString(fmt.Sprintf("\nSystemctl Info:\n%s", string(output)))
+		}
+	}
+
+	return status.String(), nil
+}
+
+// GetUnitPath returns the path to the unit file
+func (sm *SystemdManager) GetUnitPath() string {
+	return sm.unitPath
+}
+
+// systemctl runs a systemctl --user command
+func (sm *SystemdManager) systemctl(args ...string) error {
+	fullArgs := append([]string{"--user"}, args...)
+	// #nosec G204 - arguments are fixed subcommands and the constant unit name
+	cmd := exec.Command("systemctl", fullArgs...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s failed: %w\nOutput: %s", strings.Join(fullArgs, " "), err, string(output))
+	}
+	return nil
+}
+
+// generateUnit generates the unit file content
+func (sm *SystemdManager) generateUnit() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	stdoutPath := GetDaemonStdoutPath()
+	stderrPath := GetDaemonStderrPath()
+
+	// Get PATH from environment, or use a sensible default
+	pathEnv := os.Getenv("PATH")
+	if pathEnv == "" {
+		pathEnv = "/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin"
+	}
+
+	// Simple template replacement, matching the launchd plist generation
+	unit := SystemdUnitTemplate
+	unit = strings.ReplaceAll(unit, "{{.BinaryPath}}", sm.binaryPath)
+	unit = strings.ReplaceAll(unit, "{{.StdoutPath}}", stdoutPath)
+	unit = strings.ReplaceAll(unit, "{{.StderrPath}}", stderrPath)
+	unit = strings.ReplaceAll(unit, "{{.HomeDir}}", homeDir)
+	unit = strings.ReplaceAll(unit, "{{.Path}}", pathEnv)
+
+	return unit, nil
+}
@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNewSystemdManager(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Skipping systemd tests on non-Linux platform")
+	}
+
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+	binaryPath := "/usr/local/bin/kubectx-timeout"
+	sm, err := NewSystemdManager(binaryPath)
+	if err != nil {
+		t.Fatalf("Failed to create systemd manager: %v", err)
+	}
+
+	if sm.unitName != SystemdUnitName {
+		t.Errorf("Expected unit name %s, got %s", SystemdUnitName, sm.unitName)
+	}
+
+	if sm.binaryPath != binaryPath {
+		t.Errorf("Expected binary path %s, got %s", binaryPath, sm.binaryPath)
+	}
+
+	expectedUnitPath := filepath.Join(tmpDir, "systemd", "user", SystemdUnitName)
+	if sm.GetUnitPath() != expectedUnitPath {
+		t.Errorf("Expected unit path %s, got %s", expectedUnitPath, sm.GetUnitPath())
+	}
+
+	if sm.Name() != "systemd" {
+		t.Errorf("Expected name systemd, got %s", sm.Name())
+	}
+}
+
+func TestNewSystemdManager_NonLinux(t *testing.T) {
+	if runtime.GOOS == "linux" {
+		t.Skip("Skipping non-Linux test on Linux platform")
+	}
+
+	_, err := NewSystemdManager("")
+	if err == nil {
+		t.Error("Expected error on non-Linux platform, got nil")
+	}
+}
+
+func TestGetSystemdUnitPath(t *testing.T) {
+	t.Run("with XDG_CONFIG_HOME", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", "/custom/config")
+
+		path, err := GetSystemdUnitPath()
+		if err != nil {
+			t.Fatalf("GetSystemdUnitPath failed: %v", err)
+		}
+
+		expected := filepath.Join("/custom/config", "systemd", "user", SystemdUnitName)
+		if path != expected {
+			t.Errorf("Expected %s, got %s", expected, path)
+		}
+	})
+
+	t.Run("without XDG_CONFIG_HOME", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", "")
+
+		home, err := os.UserHomeDir()
+		if err != nil {
+			t.Fatalf("Failed to get home directory: %v", err)
+		}
+
+		path, err := GetSystemdUnitPath()
+		if err != nil {
+			t.Fatalf("GetSystemdUnitPath failed: %v", err)
+		}
+
+		expected := filepath.Join(home, ".config", "systemd", "user", SystemdUnitName)
+		if path != expected {
+			t.Errorf("Expected %s, got %s", expected, path)
+		}
+	})
+}
+
+func TestGenerateUnit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Skipping systemd tests on non-Linux platform")
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	binaryPath := "/usr/local/bin/kubectx-timeout"
+	sm, err := NewSystemdManager(binaryPath)
+	if err != nil {
+		t.Fatalf("Failed to create systemd manager: %v", err)
+	}
+
+	unit, err := sm.generateUnit()
+	if err != nil {
+		t.Fatalf("Failed to generate unit: %v", err)
+	}
+
+	expected := []string{
+		"[Unit]",
+		"[Service]",
+		"[Install]",
+		"ExecStart=" + binaryPath + " daemon",
+		"Restart=on-failure",
+		"WantedBy=default.target",
+		filepath.Join(GetStateDir(), "daemon.stdout.log"),
+		filepath.Join(GetStateDir(), "daemon.stderr.log"),
+	}
+	for _, s := range expected {
+		if !strings.Contains(unit, s) {
+			t.Errorf("Unit missing expected content %q", s)
+		}
+	}
+
+	if strings.Contains(unit, "{{.") {
+		t.Error("Unit contains unreplaced template placeholders")
+	}
+}
+
+func TestSystemdIsInstalled(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Skipping systemd tests on non-Linux platform")
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	sm, err := NewSystemdManager("/usr/local/bin/kubectx-timeout")
+	if err != nil {
+		t.Fatalf("Failed to create systemd manager: %v", err)
+	}
+
+	if sm.IsInstalled() {
+		t.Error("Expected unit not to be installed in fresh config dir")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sm.GetUnitPath()), 0750); err != nil {
+		t.Fatalf("Failed to create unit dir: %v", err)
+	}
+	if err := os.WriteFile(sm.GetUnitPath(), []byte("[Unit]\n"), 0600); err != nil {
+		t.Fatalf("Failed to write unit: %v", err)
+	}
+
+	if !sm.IsInstalled() {
+		t.Error("Expected unit to be reported as installed")
+	}
+}
+
+func TestSystemdUninstall_NotInstalled(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Skipping systemd tests on non-Linux platform")
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	sm, err := NewSystemdManager("/usr/local/bin/kubectx-timeout")
+	if err != nil {
+		t.Fatalf("Failed to create systemd manager: %v", err)
+	}
+
+	if err := sm.Uninstall(); err == nil {
+		t.Error("Expected error when uninstalling a unit that is not installed")
+	}
+
+	if err := sm.Restart(); err == nil {
+		t.Error("Expected error when restarting a unit that is not installed")
+	}
+}
+
+func TestNewServiceManager(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	manager, err := NewServiceManager("/usr/local/bin/kubectx-timeout")
+	switch runtime.GOOS {
+	case "darwin":
+		if err != nil {
+			t.Fatalf("NewServiceManager failed: %v", err)
+		}
+		if manager.Name() != "launchd" {
+			t.Errorf("Expected launchd manager, got %s", manager.Name())
+		}
+	case "linux":
+		if err != nil {
+			t.Fatalf("NewServiceManager failed: %v", err)
+		}
+		if manager.Name() != "systemd" {
+			t.Errorf("Expected systemd manager, got %s", manager.Name())
+		}
+	case "windows":
+		if err != nil {
+			t.Fatalf("NewServiceManager failed: %v", err)
+		}
+		if manager.Name() != "Task Scheduler" {
+			t.Errorf("Expected Task Scheduler manager, got %s", manager.Name())
+		}
+	default:
+		if err == nil {
+			t.Error("Expected error on unsupported platform")
+		}
+	}
+}
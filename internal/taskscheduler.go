@@ -0,0 +1,295 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// ScheduledTaskName is the name of the Task Scheduler task for the daemon
+	ScheduledTaskName = "kubectx-timeout"
+
+	// ScheduledTaskTemplate is the template for the Task Scheduler task
+	// definition. conhost --headless runs the daemon without a console
+	// window, which would otherwise open at every logon.
+	ScheduledTaskTemplate = `<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>kubectx-timeout daemon (automatic kubectl context switching)</Description>
+    <URI>\{{.TaskName}}</URI>
+  </RegistrationInfo>
+  <Triggers>
+    <LogonTrigger>
+      <Enabled>true</Enabled>
+      <UserId>{{.UserID}}</UserId>
+    </LogonTrigger>
+  </Triggers>
+  <Principals>
+    <Principal id="Author">
+      <UserId>{{.UserID}}</UserId>
+      <LogonType>InteractiveToken</LogonType>
+      <RunLevel>LeastPrivilege</RunLevel>
+    </Principal>
+  </Principals>
+  <Settings>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
+    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>
+    <StartWhenAvailable>true</StartWhenAvailable>
+    <ExecutionTimeLimit>PT0S</ExecutionTimeLimit>
+    <!-- Restart if it crashes, throttled to prevent rapid restarts -->
+    <RestartOnFailure>
+      <Interval>PT1M</Interval>
+      <Count>999</Count>
+    </RestartOnFailure>
+    <Priority>7</Priority>
+  </Settings>
+  <Actions Context="Author">
+    <Exec>
+      <Command>conhost.exe</Command>
+      <Arguments>--headless "{{.BinaryPath}}" daemon</Arguments>
+      <WorkingDirectory>{{.HomeDir}}</WorkingDirectory>
+    </Exec>
+  </Actions>
+</Task>
+`
+)
+
+// TaskSchedulerManager handles Task Scheduler operations for Windows
+type TaskSchedulerManager struct {
+	taskName   string
+	binaryPath string
+	userID     string
+}
+
+// NewTaskSchedulerManager creates a new Task Scheduler manager instance
+func NewTaskSchedulerManager(binaryPath string) (*TaskSchedulerManager, error) {
+	// Verify we're on Windows
+	if runtime.GOOS != "windows" {
+		return nil, fmt.Errorf("Task Scheduler is only available on Windows")
+	}
+
+	// The task runs as, and starts at the logon of, the current user
+	current, err := user.Current()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine current user: %w", err)
+	}
+
+	// If no binary path specified, try to find the current executable
+	if binaryPath == "" {
+		execPath, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine executable path: %w", err)
+		}
+		// Resolve symlinks
+		binaryPath, err = filepath.EvalSymlinks(execPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve executable path: %w", err)
+		}
+	}
+
+	return &TaskSchedulerManager{
+		taskName:   ScheduledTaskName,
+		binaryPath: binaryPath,
+		userID:     current.Username,
+	}, nil
+}
+
+// Name returns the name of the service manager
+func (tm *TaskSchedulerManager) Name() string {
+	return "Task Scheduler"
+}
+
+// Install registers the task and starts the daemon
+func (tm *TaskSchedulerManager) Install() error {
+	// Check if already installed
+	if tm.IsInstalled() {
+		return MarkFailure(fmt.Errorf("daemon is already installed as scheduled task %s", tm.taskName), ErrAlreadyInstalled)
+	}
+
+	// Ensure state directory exists
+	stateDir := GetStateDir()
+	if err := os.MkdirAll(stateDir, 0750); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Generate task definition
+	taskXML, err := tm.generateTaskXML()
+	if err != nil {
+		return fmt.Errorf("failed to generate task definition: %w", err)
+	}
+
+	// schtasks only reads task definitions from a file
+	f, err := os.CreateTemp("", "kubectx-timeout-task-*.xml")
+	if err != nil {
+		return fmt.Errorf("failed to create task definition file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(encodeUTF16(taskXML))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write task definition file: %w", err)
+	}
+
+	if err := tm.schtasks("/Create", "/TN", tm.taskName, "/XML", f.Name()); err != nil {
+		return fmt.Errorf("failed to register task: %w", err)
+	}
+
+	// Load the daemon
+	if err := tm.Load(); err != nil {
+		// If load fails, clean up the task
+		_ = tm.schtasks("/Delete", "/TN", tm.taskName, "/F") // Ignore error on cleanup
+		return fmt.Errorf("failed to load daemon: %w", err)
+	}
+
+	return nil
+}
+
+// Uninstall stops the daemon and deletes the task
+func (tm *TaskSchedulerManager) Uninstall() error {
+	// Check if installed
+	if !tm.IsInstalled() {
+		return fmt.Errorf("daemon is not installed")
+	}
+
+	// Stop and disable
+	if err := tm.Unload(); err != nil {
+		return fmt.Errorf("failed to unload daemon: %w", err)
+	}
+
+	if err := tm.schtasks("/Delete", "/TN", tm.taskName, "/F"); err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+
+	return nil
+}
+
+// Restart restarts the daemon
+func (tm *TaskSchedulerManager) Restart() error {
+	if !tm.IsInstalled() {
+		return fmt.Errorf("daemon is not installed. Run 'kubectx-timeout daemon-install' first")
+	}
+
+	// Ending a task that isn't running fails, which doesn't matter here
+	_ = tm.schtasks("/End", "/TN", tm.taskName)
+	if err := tm.schtasks("/Run", "/TN", tm.taskName); err != nil {
+		return fmt.Errorf("failed to restart daemon: %w", err)
+	}
+
+	return nil
+}
+
+// Load enables the task, so it runs at logon, and starts the daemon
+func (tm *TaskSchedulerManager) Load() error {
+	if err := tm.schtasks("/Change", "/TN", tm.taskName, "/ENABLE"); err != nil {
+		return err
+	}
+	return tm.schtasks("/Run", "/TN", tm.taskName)
+}
+
+// Unload stops the daemon and disables the task, so it doesn't run at the
+// next logon either
+func (tm *TaskSchedulerManager) Unload() error {
+	if tm.IsRunning() {
+		if err := tm.schtasks("/End", "/TN", tm.taskName); err != nil {
+			return err
+		}
+	}
+	return tm.schtasks("/Change", "/TN", tm.taskName, "/DISABLE")
+}
+
+// IsInstalled checks if the task is registered
+func (tm *TaskSchedulerManager) IsInstalled() bool {
+	return tm.schtasks("/Query", "/TN", tm.taskName) == nil
+}
+
+// IsRunning checks if the daemon is currently running. schtasks reports the
+// task's status in the system language, so the PID file is checked instead.
+func (tm *TaskSchedulerManager) IsRunning() bool {
+	return NewPIDFile().IsRunning()
+}
+
+// GetStatus returns the daemon status information
+func (tm *TaskSchedulerManager) GetStatus() (string, error) {
+	installed := tm.IsInstalled()
+	running := tm.IsRunning()
+
+	var status strings.Builder
+	status.WriteString("Daemon Status:\n")
+	status.WriteString(fmt.Sprintf("  Installed: %v\n", installed))
+	status.WriteString(fmt.Sprintf("  Running: %v\n", running))
+	status.WriteString(fmt.Sprintf("  Task Name: %s\n", tm.taskName))
+	status.WriteString(fmt.Sprintf("  Binary Path: %s\n", tm.binaryPath))
+
+	if installed {
+		// Get detailed status from schtasks
+		// #nosec G204 - taskName is a constant (ScheduledTaskName)
+		cmd := exec.Command("schtasks", "/Query", "/TN", tm.taskName, "/V", "/FO", "LIST")
+		output, _ := cmd.CombinedOutput()
+		if len(output) > 0 {
+			status.WriteString(fmt.Sprintf("\nSchtasks Info:\n%s", string(output)))
+		}
+	}
+
+	return status.String(), nil
+}
+
+// GetTaskName returns the name of the scheduled task
+func (tm *TaskSchedulerManager) GetTaskName() string {
+	return tm.taskName
+}
+
+// schtasks runs a schtasks command
+func (tm *TaskSchedulerManager) schtasks(args ...string) error {
+	// #nosec G204 - arguments are fixed subcommands, the constant task name,
+	// and a temporary file path
+	cmd := exec.Command("schtasks", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("schtasks %s failed: %w\nOutput: %s", strings.Join(args, " "), err, string(output))
+	}
+	return nil
+}
+
+// generateTaskXML generates the task definition
+func (tm *TaskSchedulerManager) generateTaskXML() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	// Simple template replacement, matching the launchd plist generation
+	task := ScheduledTaskTemplate
+	task = strings.ReplaceAll(task, "{{.TaskName}}", xmlEscape(tm.taskName))
+	task = strings.ReplaceAll(task, "{{.UserID}}", xmlEscape(tm.userID))
+	task = strings.ReplaceAll(task, "{{.BinaryPath}}", xmlEscape(tm.binaryPath))
+	task = strings.ReplaceAll(task, "{{.HomeDir}}", xmlEscape(homeDir))
+
+	return task, nil
+}
+
+// xmlEscape escapes s for use as XML character data
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s)) // Writing to a strings.Builder can't fail
+	return b.String()
+}
+
+// encodeUTF16 encodes s as UTF-16 with a byte order mark, the encoding
+// schtasks expects task definitions in
+func encodeUTF16(s string) []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, utf16.Encode([]rune("\uFEFF"+s))) // Writing to a bytes.Buffer can't fail
+	return buf.Bytes()
+}
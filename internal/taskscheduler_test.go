@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestNewTaskSchedulerManager_NonWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping non-Windows test on Windows platform")
+	}
+
+	_, err := NewTaskSchedulerManager("")
+	if err == nil {
+		t.Error("Expected error on non-Windows platform, got nil")
+	}
+}
+
+func TestGenerateTaskXML(t *testing.T) {
+	tm := &TaskSchedulerManager{
+		taskName:   ScheduledTaskName,
+		binaryPath: `C:\Tools & Utilities\kubectx-timeout.exe`,
+		userID:     `WORKSTATION\alice`,
+	}
+
+	task, err := tm.generateTaskXML()
+	if err != nil {
+		t.Fatalf("Failed to generate task: %v", err)
+	}
+
+	expected := []string{
+		`<URI>\kubectx-timeout</URI>`,
+		"<LogonTrigger>",
+		`<UserId>WORKSTATION\alice</UserId>`,
+		"<LogonType>InteractiveToken</LogonType>",
+		"<RestartOnFailure>",
+		"<ExecutionTimeLimit>PT0S</ExecutionTimeLimit>",
+		`<Arguments>--headless "C:\Tools &amp; Utilities\kubectx-timeout.exe" daemon</Arguments>`,
+	}
+	for _, s := range expected {
+		if !strings.Contains(task, s) {
+			t.Errorf("Task missing expected content %q", s)
+		}
+	}
+
+	if strings.Contains(task, "{{.") {
+		t.Error("Task contains unreplaced template placeholders")
+	}
+}
+
+func TestEncodeUTF16(t *testing.T) {
+	encoded := encodeUTF16("<Task/>")
+	if !bytes.HasPrefix(encoded, []byte{0xFF, 0xFE}) {
+		t.Fatalf("encodeUTF16() = % x, want a little-endian byte order mark", encoded[:2])
+	}
+
+	units := make([]uint16, (len(encoded)-2)/2)
+	for i := range units {
+		units[i] = uint16(encoded[2+2*i]) | uint16(encoded[3+2*i])<<8
+	}
+	if got := string(utf16.Decode(units)); got != "<Task/>" {
+		t.Errorf("encodeUTF16() decodes to %q, want %q", got, "<Task/>")
+	}
+}
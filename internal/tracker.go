@@ -1,22 +1,31 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
-	"strings"
 	"time"
 )
 
-const (
-	shellBash = "bash"
-	shellZsh  = "zsh"
-)
-
 // ActivityTracker tracks kubectl command activity
 type ActivityTracker struct {
 	stateManager *StateManager
+	history      *History
 	configPath   string
+
+	// socketPath is the daemon's activity socket, tried before writing the
+	// state file
+	socketPath string
+
+	// client reads the current context; nil until first use, when it is
+	// chosen by kube_client
+	client KubeClient
+
+	// config is loaded on first use, since recording activity through the
+	// daemon rarely needs it
+	config       *Config
+	configLoaded bool
+	encrypted    bool
 }
 
 // NewActivityTracker creates a new activity tracker
@@ -28,154 +37,222 @@ func NewActivityTracker(statePath string, configPath string) (*ActivityTracker,
 
 	return &ActivityTracker{
 		stateManager: sm,
+		history:      NewHistory(HistoryPathForState(sm.path)),
 		configPath:   configPath,
+		socketPath:   ActivitySocketPathForState(sm.path),
 	}, nil
 }
 
-// GetCurrentContext returns the current kubectl context
-func GetCurrentContext() (string, error) {
-	cmd := exec.Command("kubectl", "config", "current-context")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to get current context: %w", err)
-	}
+// SetKubeClient makes the tracker read the current context through client
+// instead of the one named by kube_client
+func (at *ActivityTracker) SetKubeClient(client KubeClient) {
+	at.client = client
+}
 
-	context := strings.TrimSpace(string(output))
-	if context == "" {
-		return "", fmt.Errorf("no current context set")
+// kubeClient returns the client the current context is read through
+func (at *ActivityTracker) kubeClient() KubeClient {
+	if at.client == nil {
+		name := KubeClientKubectl
+		if config := at.loadConfig(); config != nil {
+			name = config.KubeClient
+		}
+		at.client = NewKubeClient(name, "")
 	}
+	return at.client
+}
+
+// GetCurrentContext returns the current kubectl context
+func GetCurrentContext() (string, error) {
+	return ExecKubeClient{}.CurrentContext()
+}
 
-	return context, nil
+// GetCurrentNamespace returns the current kubectl context's namespace,
+// which is "default" if the context doesn't set one
+func GetCurrentNamespace() (string, error) {
+	return ExecKubeClient{}.CurrentNamespace()
 }
 
 // RecordActivity records kubectl activity with the current context
 func (at *ActivityTracker) RecordActivity() error {
+	return at.RecordCommand(nil)
+}
+
+// RecordCommand records kubectl activity with the current context for a
+// command with the given arguments, which are used to tell reads from
+// writes and never stored. Only the verb and resource kind reach the
+// history log, when tracking.metadata allows. Without arguments the
+// activity is a read. A running daemon is sent the activity to record;
+// otherwise the state file is written directly.
+func (at *ActivityTracker) RecordCommand(args []string) error {
 	// Get current context
-	context, err := GetCurrentContext()
+	client := at.kubeClient()
+	context, err := client.CurrentContext()
 	if err != nil {
 		// If we can't get the context, still record activity with empty context
 		// This ensures we don't break the user's kubectl workflow
 		context = "unknown"
 	}
 
-	// Record activity
-	if err := at.stateManager.RecordActivity(context); err != nil {
-		return fmt.Errorf("failed to record activity: %w", err)
+	// The namespace is only informational, so failing to read it is fine
+	var namespace string
+	if nc, ok := client.(namespaceClient); ok {
+		namespace, _ = nc.CurrentNamespace()
 	}
 
-	return nil
-}
+	// Shells with their own kubeconfig, such as kubie's, are also timed
+	// out on their own
+	kubeconfig := SessionKubeconfig(os.Getenv("KUBECONFIG"))
 
-// GetLastActivity returns the last activity timestamp and context
-func (at *ActivityTracker) GetLastActivity() (ActivityInfo, error) {
-	lastActivity, context, err := at.stateManager.GetLastActivity()
-	if err != nil {
-		return ActivityInfo{}, fmt.Errorf("failed to get last activity: %w", err)
+	// A session in a repository with a .kubectx-timeout.yaml follows it
+	var project string
+	if kubeconfig != "" {
+		if dir, err := os.Getwd(); err == nil {
+			project = FindProjectConfig(dir)
+		}
 	}
 
-	return ActivityInfo{
-		LastActivity:   lastActivity,
-		CurrentContext: context,
-	}, nil
-}
+	command := ParseKubectlCommand(args)
+	write := command.IsWrite()
+	command = command.Redact(at.metadataLevel(args))
 
-// ActivityInfo contains information about kubectl activity
-type ActivityInfo struct {
-	LastActivity   time.Time
-	CurrentContext string
-}
+	// The daemon coalesces activity into fewer writes of the state file
+	if at.socketPath != "" {
+		err := SendActivity(at.socketPath, ActivityMessage{
+			Context:    context,
+			Namespace:  namespace,
+			Write:      write,
+			Kubeconfig: kubeconfig,
+			Project:    project,
+			Verb:       command.Verb,
+			Resource:   command.Resource,
+		})
+		if err == nil {
+			return nil
+		}
+	}
 
-// GenerateShellIntegration generates shell integration code for the given shell
-func GenerateShellIntegration(shell string, binaryPath string) (string, error) {
-	if binaryPath == "" {
-		binaryPath = "/usr/local/bin/kubectx-timeout"
+	if err := at.useEncryption(); err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
 	}
 
-	switch shell {
-	case shellBash, shellZsh:
-		return fmt.Sprintf(`# kubectx-timeout shell integration for %s
-# Add this to your ~/.%src
+	// Record activity
+	activity := Activity{Context: context, Namespace: namespace, Write: write}
+	if err := RecordActivities(at.stateManager, []Activity{activity}); err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
+	}
+	if kubeconfig != "" {
+		if err := RecordSessionActivity(at.stateManager, kubeconfig, context, project); err != nil {
+			return fmt.Errorf("failed to record activity: %w", err)
+		}
+	}
 
-kubectl() {
-    # Record activity before executing kubectl
-    if [ -x "%s" ]; then
-        "%s" record-activity >/dev/null 2>&1 &
-    fi
+	// History is best effort; it must never break the user's kubectl workflow
+	_ = at.history.Append(HistoryEvent{
+		Type:       HistoryActivity,
+		Context:    context,
+		Kubeconfig: kubeconfig,
+		Verb:       command.Verb,
+		Resource:   command.Resource,
+	})
 
-    # Execute the real kubectl
-    command kubectl "$@"
+	return nil
 }
 
-kubectx() {
-    # Execute the real kubectx first
-    command kubectx "$@"
-    local exit_code=$?
-
-    # Record activity after context switch (only if successful)
-    # This ensures we capture the NEW context, not the old one
-    if [ $exit_code -eq 0 ] && [ -x "%s" ]; then
-        "%s" record-activity >/dev/null 2>&1 &
-    fi
-
-    # Return the original exit code
-    return $exit_code
+// metadataLevel returns the configured tracking.metadata, or MetadataOff
+// if the config can't be loaded. The config is only read for commands with
+// arguments, since there is nothing to keep otherwise.
+func (at *ActivityTracker) metadataLevel(args []string) string {
+	if len(args) == 0 {
+		return MetadataOff
+	}
+	config := at.loadConfig()
+	if config == nil {
+		return MetadataOff
+	}
+	return config.Tracking.Metadata
 }
-`, shell, shell, binaryPath, binaryPath, binaryPath, binaryPath), nil
 
-	default:
-		return "", fmt.Errorf("unsupported shell: %s", shell)
+// loadConfig returns the config, loading it the first time, or nil if
+// there is none or it can't be loaded
+func (at *ActivityTracker) loadConfig() *Config {
+	if !at.configLoaded && at.configPath != "" {
+		at.config, _ = LoadConfig(at.configPath)
 	}
+	at.configLoaded = true
+	return at.config
 }
 
-// InstallShellIntegration installs shell integration to the user's profile
-func InstallShellIntegration(shell string) error {
-	home, err := os.UserHomeDir()
+// useEncryption sets up the state file and history log's encryption, if
+// state.encrypt is on, before the tracker reads or writes them itself
+func (at *ActivityTracker) useEncryption() error {
+	if at.encrypted {
+		return nil
+	}
+	key, err := stateKeyForConfig(at.loadConfig())
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return err
 	}
-
-	var profilePath string
-	switch shell {
-	case shellBash:
-		profilePath = home + "/.bashrc"
-	case shellZsh:
-		profilePath = home + "/.zshrc"
-	default:
-		return fmt.Errorf("unsupported shell: %s", shell)
+	if key != nil {
+		if err := at.stateManager.SetEncryptionKey(key); err != nil {
+			return err
+		}
+		if err := at.history.SetEncryptionKey(key); err != nil {
+			return err
+		}
 	}
+	at.encrypted = true
+	return nil
+}
 
-	// Generate integration code
-	integration, err := GenerateShellIntegration(shell, "/usr/local/bin/kubectx-timeout")
-	if err != nil {
-		return err
+// Heartbeat records activity now and then every interval for as long as the
+// process with the given PID is alive, so long-running tools such as k9s,
+// and commands such as kubectl logs -f, keep the context. The first beat
+// records the command with args, and later ones plain activity, so the
+// command counts once in the history. It returns when the process exits or
+// ctx is canceled. Failures to record are retried on the next beat rather
+// than returned.
+func (at *ActivityTracker) Heartbeat(ctx context.Context, pid int, interval time.Duration, args []string) error {
+	if interval <= 0 {
+		return fmt.Errorf("heartbeat interval must be positive")
 	}
 
-	// Check if already installed
-	// #nosec G304 -- profilePath is a user shell profile path
-	content, err := os.ReadFile(profilePath)
-	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to read profile: %w", err)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ProcessExists(pid) {
+		if err := at.RecordCommand(args); err == nil {
+			args = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
 	}
 
-	if strings.Contains(string(content), "kubectx-timeout shell integration") {
-		return fmt.Errorf("shell integration already installed in %s", profilePath)
+	return nil
+}
+
+// GetLastActivity returns the last activity timestamp and context
+func (at *ActivityTracker) GetLastActivity() (ActivityInfo, error) {
+	if err := at.useEncryption(); err != nil {
+		return ActivityInfo{}, fmt.Errorf("failed to get last activity: %w", err)
 	}
 
-	// Append to profile
-	// #nosec G304 -- profilePath is a user shell profile path
-	f, err := os.OpenFile(profilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	lastActivity, context, err := LastActivity(at.stateManager)
 	if err != nil {
-		return fmt.Errorf("failed to open profile: %w", err)
+		return ActivityInfo{}, fmt.Errorf("failed to get last activity: %w", err)
 	}
-	defer func() {
-		if closeErr := f.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("failed to close profile: %w", closeErr)
-		}
-	}()
 
-	if _, err := f.WriteString("\n" + integration); err != nil {
-		return fmt.Errorf("failed to write to profile: %w", err)
-	}
+	return ActivityInfo{
+		LastActivity:   lastActivity,
+		CurrentContext: context,
+	}, nil
+}
 
-	return nil
+// ActivityInfo contains information about kubectl activity
+type ActivityInfo struct {
+	LastActivity   time.Time
+	CurrentContext string
 }
@@ -1,21 +1,14 @@
 package internal
 
 import (
+	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"strings"
 	"testing"
 	"time"
 )
 
-// Helper function to get profile path for a given shell
-func getProfilePath(homeDir, shell string) string {
-	if shell == "bash" {
-		return filepath.Join(homeDir, ".bashrc")
-	}
-	return filepath.Join(homeDir, ".zshrc")
-}
-
 func TestNewActivityTracker(t *testing.T) {
 	tmpDir := t.TempDir()
 	statePath := filepath.Join(tmpDir, "state.json")
@@ -69,6 +62,121 @@ func TestActivityTrackerRecordActivity(t *testing.T) {
 	}
 }
 
+func TestActivityTrackerRecordsCommandMetadata(t *testing.T) {
+	tests := []struct {
+		metadata     string
+		wantVerb     string
+		wantResource string
+	}{
+		{MetadataOff, "", ""},
+		{MetadataVerb, "delete", ""},
+		{MetadataVerbResource, "delete", "secrets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metadata, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			statePath := filepath.Join(tmpDir, "state.json")
+			configPath := filepath.Join(tmpDir, "config.yaml")
+			configContent := "default_context: local\ntracking:\n  metadata: " + tt.metadata + "\n"
+			if err := os.WriteFile(configPath, []byte(configContent), 0600); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+
+			tracker, err := NewActivityTracker(statePath, configPath)
+			if err != nil {
+				t.Fatalf("NewActivityTracker failed: %v", err)
+			}
+			if err := tracker.RecordCommand([]string{"delete", "secret", "db-password"}); err != nil {
+				t.Fatalf("RecordCommand failed: %v", err)
+			}
+
+			events, err := tracker.history.Read(HistoryFilter{})
+			if err != nil {
+				t.Fatalf("Failed to read history: %v", err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("Expected one history event, got %+v", events)
+			}
+			if events[0].Verb != tt.wantVerb || events[0].Resource != tt.wantResource {
+				t.Errorf("Recorded %q %q, want %q %q", events[0].Verb, events[0].Resource, tt.wantVerb, tt.wantResource)
+			}
+		})
+	}
+}
+
+func TestActivityTrackerHeartbeat(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("default_context: local\ntracking:\n  metadata: verb\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	tracker, err := NewActivityTracker(filepath.Join(tmpDir, "state.json"), configPath)
+	if err != nil {
+		t.Fatalf("NewActivityTracker failed: %v", err)
+	}
+
+	// Stands in for kubectl logs -f
+	cmd := exec.Command("sleep", "0.5")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- tracker.Heartbeat(context.Background(), cmd.Process.Pid, 50*time.Millisecond, []string{"logs", "-f", "web"})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Heartbeat didn't stop after the process exited")
+	}
+	<-exited
+
+	events, err := tracker.history.Read(HistoryFilter{Type: HistoryActivity})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(events) < 2 {
+		t.Errorf("Expected activity recorded repeatedly while the process ran, got %d events", len(events))
+	}
+	// The command itself is only counted once
+	for i, event := range events {
+		want := ""
+		if i == 0 {
+			want = "logs"
+		}
+		if event.Verb != want {
+			t.Errorf("events[%d].Verb = %q, want %q", i, event.Verb, want)
+		}
+	}
+
+	// Canceling stops a heartbeat for a process that keeps running
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		done <- tracker.Heartbeat(ctx, os.Getpid(), time.Hour, nil)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Heartbeat didn't stop when canceled")
+	}
+
+	if err := tracker.Heartbeat(context.Background(), os.Getpid(), 0, nil); err == nil {
+		t.Error("Expected an error for a zero interval")
+	}
+}
+
 func TestActivityTrackerGetLastActivity(t *testing.T) {
 	tmpDir := t.TempDir()
 	statePath := filepath.Join(tmpDir, "state.json")
@@ -105,102 +213,6 @@ func TestActivityTrackerGetLastActivity(t *testing.T) {
 	}
 }
 
-func TestGenerateShellIntegration(t *testing.T) {
-	tests := []struct {
-		shell      string
-		binaryPath string
-		wantError  bool
-	}{
-		{"bash", "/usr/local/bin/kubectx-timeout", false},
-		{"zsh", "/usr/local/bin/kubectx-timeout", false},
-		{"bash", "", false}, // Should use default path
-		{"fish", "/usr/local/bin/kubectx-timeout", true}, // Unsupported
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.shell, func(t *testing.T) {
-			integration, err := GenerateShellIntegration(tt.shell, tt.binaryPath)
-
-			if (err != nil) != tt.wantError {
-				t.Errorf("GenerateShellIntegration() error = %v, wantError %v", err, tt.wantError)
-				return
-			}
-
-			if !tt.wantError {
-				// Verify integration contains expected elements
-				if !strings.Contains(integration, "kubectl()") {
-					t.Error("integration should contain kubectl function")
-				}
-
-				if !strings.Contains(integration, "record-activity") {
-					t.Error("integration should contain record-activity command")
-				}
-
-				if !strings.Contains(integration, tt.shell) {
-					t.Errorf("integration should mention shell '%s'", tt.shell)
-				}
-
-				// If binary path specified, it should be in the integration
-				if tt.binaryPath != "" && !strings.Contains(integration, tt.binaryPath) {
-					t.Errorf("integration should contain binary path '%s'", tt.binaryPath)
-				}
-			}
-		})
-	}
-}
-
-func TestInstallShellIntegration(t *testing.T) {
-	// Create a temporary home directory
-	tmpHome := t.TempDir()
-
-	// Override HOME for this test
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpHome)
-	defer os.Setenv("HOME", oldHome)
-
-	tests := []struct {
-		name      string
-		shell     string
-		wantError bool
-	}{
-		{"bash", "bash", false},
-		{"zsh", "zsh", false},
-		{"unsupported", "fish", true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := InstallShellIntegration(tt.shell)
-
-			if (err != nil) != tt.wantError {
-				t.Errorf("InstallShellIntegration() error = %v, wantError %v", err, tt.wantError)
-				return
-			}
-
-			if tt.wantError {
-				return // Expected error case, nothing more to verify
-			}
-
-			// Verify profile file was created/updated
-			profilePath := getProfilePath(tmpHome, tt.shell)
-			content, err := os.ReadFile(profilePath)
-			if err != nil {
-				t.Fatalf("Failed to read profile: %v", err)
-			}
-
-			if !strings.Contains(string(content), "kubectx-timeout shell integration") {
-				t.Error("Profile should contain integration marker")
-			}
-
-			// Try installing again - should fail (already installed)
-			err = InstallShellIntegration(tt.shell)
-			if err == nil {
-				t.Error("Installing twice should return error")
-			}
-		})
-	}
-}
-
 func TestGetCurrentContext(t *testing.T) {
 	// Setup isolated test environment to avoid leaking real context names
 	tmpDir := t.TempDir()
@@ -225,98 +237,24 @@ func TestGetCurrentContext(t *testing.T) {
 	t.Logf("Current kubectl context: %s", context)
 }
 
-// TestGenerateShellIntegrationIncludesKubectx tests that shell integration includes kubectx wrapper
-// This is a regression test for the issue where manually running kubectx doesn't start the timer
-func TestGenerateShellIntegrationIncludesKubectx(t *testing.T) {
-	tests := []struct {
-		shell      string
-		binaryPath string
-	}{
-		{"bash", "/usr/local/bin/kubectx-timeout"},
-		{"zsh", "/usr/local/bin/kubectx-timeout"},
+func TestActivityTrackerUsesKubeClient(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	tmpDir := t.TempDir()
+	tracker, err := NewActivityTracker(filepath.Join(tmpDir, "state.json"), filepath.Join(tmpDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("NewActivityTracker failed: %v", err)
 	}
+	tracker.socketPath = ""
+	tracker.SetKubeClient(&fakeKubeClient{current: "customer-prod"})
 
-	for _, tt := range tests {
-		t.Run(tt.shell, func(t *testing.T) {
-			integration, err := GenerateShellIntegration(tt.shell, tt.binaryPath)
-			if err != nil {
-				t.Fatalf("GenerateShellIntegration failed: %v", err)
-			}
-
-			// Verify integration contains kubectx wrapper
-			if !strings.Contains(integration, "kubectx()") {
-				t.Error("integration should contain kubectx function wrapper")
-			}
-
-			// Verify kubectx wrapper calls record-activity
-			if !strings.Contains(integration, "kubectx") && strings.Contains(integration, "record-activity") {
-				t.Error("kubectx wrapper should call record-activity before executing kubectx")
-			}
-
-			// Verify kubectx wrapper calls the real kubectx command
-			if !strings.Contains(integration, "command kubectx") {
-				t.Error("kubectx wrapper should execute 'command kubectx' to invoke the real kubectx")
-			}
-		})
+	if err := tracker.RecordActivity(); err != nil {
+		t.Fatalf("RecordActivity failed: %v", err)
 	}
-}
-
-// TestKubectxWrapperRecordsActivityAfterSwitch tests that kubectx wrapper records activity
-// AFTER the context switch completes, not before. This ensures we capture the NEW context,
-// not the old one.
-// This is a regression test for the race condition where record-activity runs in parallel
-// with kubectx and might capture the old context before the switch completes.
-func TestKubectxWrapperRecordsActivityAfterSwitch(t *testing.T) {
-	tests := []struct {
-		shell string
-	}{
-		{"bash"},
-		{"zsh"},
+	info, err := tracker.GetLastActivity()
+	if err != nil {
+		t.Fatalf("GetLastActivity failed: %v", err)
 	}
-
-	for _, tt := range tests {
-		t.Run(tt.shell, func(t *testing.T) {
-			integration, err := GenerateShellIntegration(tt.shell, "/usr/local/bin/kubectx-timeout")
-			if err != nil {
-				t.Fatalf("GenerateShellIntegration failed: %v", err)
-			}
-
-			// Find the kubectx function in the integration
-			kubectxStart := strings.Index(integration, "kubectx() {")
-			if kubectxStart == -1 {
-				t.Fatal("kubectx function not found in integration")
-			}
-
-			// Find the end of the kubectx function (next closing brace at start of line)
-			kubectxEnd := strings.Index(integration[kubectxStart:], "\n}")
-			if kubectxEnd == -1 {
-				t.Fatal("kubectx function end not found")
-			}
-			kubectxFunc := integration[kubectxStart : kubectxStart+kubectxEnd+2]
-
-			// Verify that "command kubectx" appears BEFORE "record-activity" in the function
-			cmdKubectxPos := strings.Index(kubectxFunc, "command kubectx")
-			recordActivityPos := strings.Index(kubectxFunc, "record-activity")
-
-			if cmdKubectxPos == -1 {
-				t.Error("kubectx function should contain 'command kubectx'")
-			}
-			if recordActivityPos == -1 {
-				t.Error("kubectx function should contain 'record-activity'")
-			}
-
-			// This is the key test: record-activity must come AFTER command kubectx
-			if cmdKubectxPos > recordActivityPos {
-				t.Errorf("kubectx wrapper has incorrect order: record-activity (%d) should come AFTER command kubectx (%d)\n"+
-					"This causes a race condition where the old context is recorded instead of the new one.\n"+
-					"Function:\n%s",
-					recordActivityPos, cmdKubectxPos, kubectxFunc)
-			}
-
-			// Verify that we capture and return the exit code
-			if !strings.Contains(kubectxFunc, "exit_code") && !strings.Contains(kubectxFunc, "return") {
-				t.Error("kubectx wrapper should preserve exit code from command kubectx")
-			}
-		})
+	if info.CurrentContext != "customer-prod" {
+		t.Errorf("Expected the client's context, got %q", info.CurrentContext)
 	}
 }
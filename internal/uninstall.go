@@ -11,18 +11,21 @@ import (
 
 // UninstallOptions contains options for uninstallation
 type UninstallOptions struct {
-	KeepConfig  bool   // Keep configuration and state files
-	KeepBinary  bool   // Keep the binary file
-	Force       bool   // Skip confirmations
-	AllShells   bool   // Remove from all detected shell profiles
-	TargetShell string // Specific shell to target (bash, zsh, fish)
-	BinaryPath  string // Path to the binary to remove
+	KeepConfig     bool   // Keep configuration and state files
+	KeepBinary     bool   // Keep the binary file
+	Force          bool   // Skip confirmations
+	AllShells      bool   // Remove from all detected shell profiles
+	TargetShell    string // Specific shell to target (bash, zsh, fish, powershell)
+	BinaryPath     string // Path to the binary to remove
+	ProfileBackups int    // Backups of each shell profile to keep, 0 for the default
 }
 
 // UninstallResult tracks what was removed during uninstallation
 type UninstallResult struct {
 	DaemonStopped   bool
 	LaunchdRemoved  bool
+	SystemdRemoved  bool
+	TaskRemoved     bool
 	ShellsProcessed []string
 	ConfigRemoved   bool
 	StateRemoved    bool
@@ -39,11 +42,21 @@ func Uninstall(opts UninstallOptions) (*UninstallResult, error) {
 		Errors:          []error{},
 	}
 
-	// Step 1: Stop and remove daemon (macOS launchd)
-	if runtime.GOOS == "darwin" {
+	// Step 1: Stop and remove daemon (macOS launchd, Linux systemd, Windows
+	// Task Scheduler)
+	switch runtime.GOOS {
+	case "darwin":
 		if err := stopAndRemoveDaemon(result); err != nil {
 			result.Errors = append(result.Errors, fmt.Errorf("daemon removal: %w", err))
 		}
+	case "linux":
+		if err := stopAndRemoveSystemdUnit(result); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("daemon removal: %w", err))
+		}
+	case "windows":
+		if err := stopAndRemoveScheduledTask(result); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("daemon removal: %w", err))
+		}
 	}
 
 	// Step 2: Remove shell integration
@@ -117,13 +130,74 @@ func stopAndRemoveDaemon(result *UninstallResult) error {
 	return nil
 }
 
+// stopAndRemoveSystemdUnit stops the running daemon and removes the systemd user unit
+func stopAndRemoveSystemdUnit(result *UninstallResult) error {
+	unitPath, err := GetSystemdUnitPath()
+	if err != nil {
+		return err
+	}
+
+	// Check if unit exists
+	if _, err := os.Stat(unitPath); os.IsNotExist(err) {
+		// No daemon installed, nothing to do
+		return nil
+	}
+
+	// Try to stop and disable the daemon
+	// #nosec G204 -- unit name is hardcoded, not user input
+	cmd := exec.Command("systemctl", "--user", "disable", "--now", SystemdUnitName)
+	if err := cmd.Run(); err != nil {
+		// Continue even if disable failed - the unit might not be loaded
+		result.DaemonStopped = false
+	} else {
+		result.DaemonStopped = true
+	}
+
+	// Remove the unit file
+	if err := os.Remove(unitPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove unit file: %w", err)
+	}
+
+	// Let systemd forget the removed unit
+	// #nosec G204 -- command is hardcoded, not user input
+	// #nosec G104 -- Intentionally ignoring error, unit file is already gone
+	exec.Command("systemctl", "--user", "daemon-reload").Run()
+
+	result.SystemdRemoved = true
+	return nil
+}
+
+// stopAndRemoveScheduledTask stops the running daemon and deletes the Task
+// Scheduler task
+func stopAndRemoveScheduledTask(result *UninstallResult) error {
+	tm, err := NewTaskSchedulerManager("")
+	if err != nil {
+		return err
+	}
+
+	if !tm.IsInstalled() {
+		// No daemon installed, nothing to do
+		return nil
+	}
+
+	// Continue even if stopping failed - the daemon might not be running
+	result.DaemonStopped = tm.Unload() == nil
+
+	if err := tm.schtasks("/Delete", "/TN", tm.taskName, "/F"); err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+
+	result.TaskRemoved = true
+	return nil
+}
+
 // removeShellIntegration removes the kubectl wrapper from shell profiles
 func removeShellIntegration(opts UninstallOptions, result *UninstallResult) error {
 	var shellsToProcess []string
 
 	if opts.AllShells {
 		// Process all supported shells
-		shellsToProcess = []string{ShellBash, ShellZsh, ShellFish}
+		shellsToProcess = SupportedShells
 	} else if opts.TargetShell != "" {
 		// Process specific shell
 		shellsToProcess = []string{opts.TargetShell}
@@ -132,7 +206,7 @@ func removeShellIntegration(opts UninstallOptions, result *UninstallResult) erro
 		detected, err := DetectShell()
 		if err != nil {
 			// If detection fails, try all shells
-			shellsToProcess = []string{ShellBash, ShellZsh, ShellFish}
+			shellsToProcess = SupportedShells
 		} else {
 			shellsToProcess = []string{detected}
 		}
@@ -164,13 +238,16 @@ func removeShellIntegration(opts UninstallOptions, result *UninstallResult) erro
 		}
 
 		// Uninstall the integration
-		if err := UninstallIntegration(profilePath); err != nil {
+		backupPath, err := UninstallIntegration(profilePath, opts.ProfileBackups)
+		if err != nil {
 			result.Errors = append(result.Errors, fmt.Errorf("failed to uninstall %s integration: %w", shell, err))
 			continue
 		}
+		if err := RemoveIntegrationFile(GetIntegrationPath(shell)); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to uninstall %s integration: %w", shell, err))
+		}
 
 		result.ShellsProcessed = append(result.ShellsProcessed, shell)
-		backupPath := profilePath + ".kubectx-timeout.backup"
 		result.BackupsCreated = append(result.BackupsCreated, backupPath)
 	}
 
@@ -239,6 +316,11 @@ func GetLaunchdPlistPath() (string, error) {
 
 // CheckDaemonStatus checks if the daemon is currently running
 func CheckDaemonStatus() (bool, error) {
+	if runtime.GOOS == "windows" {
+		// Windows has no pgrep
+		return NewPIDFile().IsRunning(), nil
+	}
+
 	if runtime.GOOS != "darwin" {
 		// On non-macOS systems, check if process is running
 		// #nosec G204 -- command is hardcoded, not user input
@@ -262,7 +344,7 @@ func CheckDaemonStatus() (bool, error) {
 // GetInstalledShells returns a list of shells that have the integration installed
 func GetInstalledShells() ([]string, error) {
 	var installed []string
-	shells := []string{ShellBash, ShellZsh, ShellFish}
+	shells := SupportedShells
 
 	for _, shell := range shells {
 		profilePath, err := GetShellProfilePath(shell)
@@ -297,7 +379,7 @@ func FormatUninstallResult(result *UninstallResult) string {
 	sb.WriteString(strings.Repeat("=", 60) + "\n")
 
 	// Daemon
-	if result.LaunchdRemoved {
+	if result.LaunchdRemoved || result.SystemdRemoved || result.TaskRemoved {
 		if result.DaemonStopped {
 			sb.WriteString("✓ Daemon stopped and removed\n")
 		} else {
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// watchdogMinBackoff and watchdogMaxBackoff bound the wait before a
+	// stopped watcher is restarted, doubling with each failure in a row
+	watchdogMinBackoff = time.Second
+	watchdogMaxBackoff = 5 * time.Minute
+
+	// watchdogStableAfter is how long a restarted watcher must run before
+	// its earlier failures are forgiven
+	watchdogStableAfter = time.Minute
+
+	// watchdogFailureThreshold is how many failures in a row make a
+	// watcher's trouble persistent, reported by healthcheck and daemon-status
+	watchdogFailureThreshold = 3
+)
+
+// watchdog keeps one of the daemon's watcher goroutines running, restarting
+// it with backoff if it stops or panics before the daemon shuts down.
+// Without it, file-based detection would silently stop for the rest of the
+// daemon's life.
+type watchdog struct {
+	name   string
+	logger *slog.Logger
+	ctx    context.Context
+
+	// minBackoff is the first wait before a restart
+	minBackoff time.Duration
+
+	// running is unset while waiting out the backoff
+	mu        sync.Mutex
+	running   bool
+	failures  int
+	lastErr   error
+	startedAt time.Time
+}
+
+// supervise runs watch under a new watchdog until the daemon stops. watch
+// should return nil only once the daemon's context is canceled.
+func (d *Daemon) supervise(name string, watch func() error) {
+	w := &watchdog{name: name, logger: d.logger, ctx: d.ctx, minBackoff: watchdogMinBackoff}
+	d.healthMu.Lock()
+	d.watchdogs = append(d.watchdogs, w)
+	d.healthMu.Unlock()
+	w.run(watch)
+}
+
+// run calls watch, and again after a backoff each time it stops early
+func (w *watchdog) run(watch func() error) {
+	for {
+		w.mu.Lock()
+		w.running, w.startedAt = true, time.Now()
+		w.mu.Unlock()
+
+		err := w.call(watch)
+		if w.ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("stopped unexpectedly")
+		}
+
+		delay := w.recordFailure(err)
+		w.logger.Warn("Watcher stopped, restarting", "watcher", w.name, "error", err, "in", delay)
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// call runs watch, turning a panic into an error
+func (w *watchdog) call(watch func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return watch()
+}
+
+// recordFailure counts a failure and returns how long to wait before
+// restarting. A watcher that ran for a while starts the count over.
+func (w *watchdog) recordFailure(err error) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if time.Since(w.startedAt) >= watchdogStableAfter {
+		w.failures = 0
+	}
+	w.failures++
+	w.lastErr = err
+	w.running = false
+
+	delay := w.minBackoff << min(w.failures-1, 16)
+	return min(delay, watchdogMaxBackoff)
+}
+
+// problem describes the watcher's persistent failure, or returns "" if it
+// is running fine or has only failed now and then
+func (w *watchdog) problem() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failures < watchdogFailureThreshold || (w.running && time.Since(w.startedAt) >= watchdogStableAfter) {
+		return ""
+	}
+	return fmt.Sprintf("%s watcher failed %d times in a row: %v", w.name, w.failures, w.lastErr)
+}
+
+// watcherProblems describes the daemon's persistently failing watchers
+func (d *Daemon) watcherProblems() []string {
+	d.healthMu.Lock()
+	watchdogs := d.watchdogs
+	d.healthMu.Unlock()
+
+	var problems []string
+	for _, w := range watchdogs {
+		if problem := w.problem(); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	return problems
+}
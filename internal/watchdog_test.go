@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchdogRestartsWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &watchdog{name: "kubeconfig", logger: discardLogger(), ctx: ctx, minBackoff: time.Millisecond}
+
+	// Fails, panics, and fails again, then runs until the daemon stops
+	var calls atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.run(func() error {
+			switch calls.Add(1) {
+			case 1:
+				return errors.New("native file notifications stopped")
+			case 2:
+				panic("watcher bug")
+			case 3:
+				return errors.New("native file notifications stopped")
+			}
+			<-ctx.Done()
+			return nil
+		})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("Expected the watcher to be restarted 3 times, got %d calls", calls.Load())
+	}
+
+	// Three failures in a row are reported until the watcher has run a while
+	if problem := w.problem(); !strings.Contains(problem, "kubeconfig watcher failed 3 times in a row: native file notifications stopped") {
+		t.Errorf("problem() = %q", problem)
+	}
+	w.mu.Lock()
+	w.startedAt = time.Now().Add(-watchdogStableAfter)
+	w.mu.Unlock()
+	if problem := w.problem(); problem != "" {
+		t.Errorf("Expected no problem once the watcher is stable, got %q", problem)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watchdog didn't stop with the daemon")
+	}
+}
+
+func TestWatchdogBackoff(t *testing.T) {
+	w := &watchdog{minBackoff: time.Second, startedAt: time.Now()}
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if got := w.recordFailure(errors.New("stopped")); got != want {
+			t.Errorf("recordFailure() = %v, want %v", got, want)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		w.recordFailure(errors.New("stopped"))
+	}
+	if got := w.recordFailure(errors.New("stopped")); got != watchdogMaxBackoff {
+		t.Errorf("recordFailure() = %v, want the %v cap", got, watchdogMaxBackoff)
+	}
+
+	// A watcher that ran for a while starts over
+	w.startedAt = time.Now().Add(-watchdogStableAfter)
+	if got := w.recordFailure(errors.New("stopped")); got != time.Second || w.failures != 1 {
+		t.Errorf("recordFailure() after a stable run = %v with %d failures, want 1s and 1", got, w.failures)
+	}
+}
@@ -1,148 +1,330 @@
 package internal
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
+	"strings"
+	"sync"
 	"time"
 )
 
-// KubeconfigWatcher monitors ~/.kube/config for changes
+const (
+	// debounceDelay coalesces bursts of file events into a single check
+	debounceDelay = 100 * time.Millisecond
+
+	// pollInterval is how often the kubeconfig is checked when native
+	// file notifications are not available
+	pollInterval = 1 * time.Second
+)
+
+// Watcher modes recorded in state
+const (
+	WatcherModeNative  = "native"
+	WatcherModePolling = "polling"
+)
+
+// fileNotifier delivers a signal whenever the watched file may have changed.
+// The events channel is closed when the notifier stops.
+type fileNotifier interface {
+	Events() <-chan struct{}
+	Close() error
+}
+
+// newMultiNotifier creates a native notifier for each path, merging their
+// events into one stream. It fails if any path can't be watched natively, so
+// the caller falls back to polling all of them.
+func newMultiNotifier(paths []string) (fileNotifier, error) {
+	notifiers := make([]fileNotifier, 0, len(paths))
+	for _, path := range paths {
+		notifier, err := newNativeNotifier(path)
+		if err != nil {
+			for _, n := range notifiers {
+				_ = n.Close()
+			}
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		notifiers = append(notifiers, notifier)
+	}
+
+	if len(notifiers) == 1 {
+		return notifiers[0], nil
+	}
+	return newMergedNotifier(notifiers), nil
+}
+
+// mergedNotifier combines several notifiers into a single event stream.
+// It stops as soon as any of them stops.
+type mergedNotifier struct {
+	notifiers []fileNotifier
+	events    chan struct{}
+}
+
+// newMergedNotifier starts forwarding events from all notifiers
+func newMergedNotifier(notifiers []fileNotifier) *mergedNotifier {
+	m := &mergedNotifier{
+		notifiers: notifiers,
+		events:    make(chan struct{}, 1),
+	}
+
+	stopped := make(chan struct{}, len(notifiers))
+	var wg sync.WaitGroup
+	for _, n := range notifiers {
+		wg.Add(1)
+		go func(n fileNotifier) {
+			defer wg.Done()
+			for range n.Events() {
+				// Non-blocking send: one pending signal is enough to trigger a check
+				select {
+				case m.events <- struct{}{}:
+				default:
+				}
+			}
+			stopped <- struct{}{}
+		}(n)
+	}
+
+	go func() {
+		// Once one notifier stops, stop the rest and close the merged stream
+		<-stopped
+		_ = m.Close()
+		wg.Wait()
+		close(m.events)
+	}()
+
+	return m
+}
+
+// Events returns the channel signaled when any watched file changes
+func (m *mergedNotifier) Events() <-chan struct{} {
+	return m.events
+}
+
+// Close stops every underlying notifier
+func (m *mergedNotifier) Close() error {
+	var errs []error
+	for _, n := range m.notifiers {
+		if err := n.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// KubeconfigWatcher monitors ~/.kube/config (or every file in $KUBECONFIG) for changes
 type KubeconfigWatcher struct {
-	kubeconfigPath string
-	stateManager   *StateManager
-	logger         *log.Logger
-	ctx            context.Context
+	kubeconfigPaths []string
+	stateManager    StateStore
+	history         *History
+	logger          *slog.Logger
+	ctx             context.Context
 }
 
-// NewKubeconfigWatcher creates a new kubeconfig watcher
-func NewKubeconfigWatcher(stateManager *StateManager, logger *log.Logger, ctx context.Context) (*KubeconfigWatcher, error) {
-	// Get kubeconfig path using the centralized function
-	kubeconfigPath := GetKubeconfigPath()
+// NewKubeconfigWatcher creates a new kubeconfig watcher. A nil logger
+// discards its output.
+func NewKubeconfigWatcher(stateManager StateStore, logger *slog.Logger, ctx context.Context) (*KubeconfigWatcher, error) {
+	if logger == nil {
+		logger = discardLogger()
+	}
 
-	// Clean the path to resolve any .. or other path issues (security hardening)
-	kubeconfigPath = filepath.Clean(kubeconfigPath)
+	// Get kubeconfig paths using the centralized function
+	var kubeconfigPaths []string
+	for _, path := range GetKubeconfigPaths() {
+		// Clean the path to resolve any .. or other path issues (security hardening)
+		kubeconfigPaths = append(kubeconfigPaths, filepath.Clean(path))
+	}
 
 	return &KubeconfigWatcher{
-		kubeconfigPath: kubeconfigPath,
-		stateManager:   stateManager,
-		logger:         logger,
-		ctx:            ctx,
+		kubeconfigPaths: kubeconfigPaths,
+		stateManager:    stateManager,
+		logger:          logger.With("component", "watcher"),
+		ctx:             ctx,
 	}, nil
 }
 
-// Watch starts monitoring the kubeconfig file for changes
-// This runs in a separate goroutine and uses fswatch on macOS (FSEvents API)
-// If fswatch is not available, it degrades gracefully and logs a warning
-func (w *KubeconfigWatcher) Watch() {
-	// Check if fswatch is available
-	if !w.isFswatchAvailable() {
-		w.logger.Println("fswatch not found - kubeconfig file monitoring disabled")
-		w.logger.Println("Install fswatch for automatic context switch detection: brew install fswatch")
-		return
+// Watch starts monitoring the kubeconfig files for changes
+// This runs in a separate goroutine and uses native file notifications where
+// the platform supports them (inotify on Linux), falling back to polling the
+// file's modification time otherwise. No external tools are required.
+// It returns nil once ctx is canceled, or an error if monitoring stopped on
+// its own, so the caller can start it again.
+func (w *KubeconfigWatcher) Watch() error {
+	return w.fileWatch().run()
+}
+
+// fileWatch returns the file watch that drives the kubeconfig watcher
+func (w *KubeconfigWatcher) fileWatch() *fileWatch {
+	return &fileWatch{
+		paths:    w.kubeconfigPaths,
+		label:    "Kubeconfig",
+		logger:   w.logger,
+		ctx:      w.ctx,
+		onChange: w.handleConfigChange,
+		onMode:   w.recordMode,
 	}
+}
+
+// watchWithNotifier handles kubeconfig change events from a native notifier
+func (w *KubeconfigWatcher) watchWithNotifier(notifier fileNotifier) bool {
+	return w.fileWatch().watchWithNotifier(notifier)
+}
+
+// watchWithPolling polls the kubeconfig files for changes
+func (w *KubeconfigWatcher) watchWithPolling() {
+	w.fileWatch().watchWithPolling()
+}
 
-	// Check if kubeconfig file exists
-	if _, err := os.Stat(w.kubeconfigPath); os.IsNotExist(err) {
-		w.logger.Printf("Kubeconfig file not found at %s - file monitoring disabled", w.kubeconfigPath)
-		return
+// recordMode saves the watcher mode to state so other commands can tell the
+// watcher is active
+func (w *KubeconfigWatcher) recordMode(mode string) {
+	if err := SetWatcherStatus(w.stateManager, mode); err != nil {
+		w.logger.Warn("Failed to record watcher status", "error", err)
 	}
+}
+
+// fileWatch calls onChange whenever one of its files changes, until ctx is
+// canceled. It is shared by the kubeconfig and daemon config watchers.
+type fileWatch struct {
+	paths []string
+	// label names the watched files in log messages
+	label  string
+	logger *slog.Logger
+	ctx    context.Context
+
+	onChange func() error
+	// onMode, if set, is told which watcher mode is in use
+	onMode func(mode string)
+}
+
+// run watches the files with native notifications, falling back to polling,
+// until ctx is canceled. If notifications stop before then (e.g. the
+// directory was removed), it returns an error so the daemon's watchdog can
+// start it again, with native notifications if they are back.
+func (f *fileWatch) run() error {
+	f.logger.Info("Starting "+strings.ToLower(f.label)+" file monitoring", "paths", strings.Join(f.paths, string(filepath.ListSeparator)))
 
-	w.logger.Printf("Starting kubeconfig file monitoring at %s", w.kubeconfigPath)
+	notifier, err := newMultiNotifier(f.paths)
+	if err != nil {
+		f.logger.Info("Native file notifications unavailable, polling instead", "interval", pollInterval, "error", err)
+		f.recordMode(WatcherModePolling)
+		f.watchWithPolling()
+		return nil
+	}
+	defer notifier.Close()
 
-	// Start fswatch process
-	if err := w.watchWithFswatch(); err != nil {
-		w.logger.Printf("fswatch monitoring stopped: %v", err)
+	f.recordMode(WatcherModeNative)
+	if !f.watchWithNotifier(notifier) {
+		return errors.New("native file notifications stopped")
 	}
+	return nil
 }
 
-// isFswatchAvailable checks if fswatch is installed and available
-func (w *KubeconfigWatcher) isFswatchAvailable() bool {
-	// Only use fswatch on macOS where FSEvents API is available
-	if runtime.GOOS != "darwin" {
-		return false
+// recordMode reports the watcher mode, if anyone is listening
+func (f *fileWatch) recordMode(mode string) {
+	if f.onMode != nil {
+		f.onMode(mode)
 	}
+}
 
-	// Check if fswatch binary exists
-	_, err := exec.LookPath("fswatch")
-	return err == nil
+// handleChange calls onChange, logging any error
+func (f *fileWatch) handleChange() {
+	if err := f.onChange(); err != nil {
+		f.logger.Error("Failed to handle "+strings.ToLower(f.label)+" change", "error", err)
+	}
 }
 
-// watchWithFswatch uses fswatch to monitor the kubeconfig file
-func (w *KubeconfigWatcher) watchWithFswatch() error {
-	// Use fswatch with FSEvents API on macOS
-	// -0: Use NUL character as separator (more reliable for paths with spaces)
-	// -1: Exit after first event set (we restart the loop to handle context cancellation)
-	// --event Created,Updated,Renamed: Only watch for relevant events
-	// -r: Recursive (needed even for single file to detect changes reliably)
-	// -l 0.5: Latency of 0.5 seconds (debounce rapid changes)
+// watchWithNotifier handles change events from a native notifier, coalescing
+// bursts of events (a single write often produces several) into one check.
+// It returns true if monitoring stopped because the context was canceled.
+func (f *fileWatch) watchWithNotifier(notifier fileNotifier) bool {
+	debounce := time.NewTimer(debounceDelay)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+	defer debounce.Stop()
 
 	for {
 		select {
-		case <-w.ctx.Done():
-			w.logger.Println("Kubeconfig file monitoring stopped (context canceled)")
-			return nil
-		default:
-			// Continue monitoring
-		}
+		case <-f.ctx.Done():
+			f.logger.Info(f.label + " file monitoring stopped")
+			return true
 
-		// Start fswatch process
-		// #nosec G204 -- kubeconfigPath is cleaned with filepath.Clean in NewKubeconfigWatcher
-		// and only used as a file path argument (not executed). CommandContext prevents shell
-		// injection by not using shell interpretation.
-		cmd := exec.CommandContext(w.ctx, "fswatch",
-			"-0",              // NUL separator
-			"-1",              // Exit after first set of events
-			"--event=Created", // Watch for file creation
-			"--event=Updated", // Watch for file updates
-			"--event=Renamed", // Watch for file renames
-			"-l", "0.5",       // 0.5 second latency (debounce)
-			w.kubeconfigPath,
-		)
-
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			return fmt.Errorf("failed to create stdout pipe: %w", err)
-		}
+		case _, ok := <-notifier.Events():
+			if !ok {
+				return f.ctx.Err() != nil
+			}
+			debounce.Reset(debounceDelay)
 
-		if err := cmd.Start(); err != nil {
-			return fmt.Errorf("failed to start fswatch: %w", err)
+		case <-debounce.C:
+			f.handleChange()
 		}
+	}
+}
 
-		// Read events from fswatch
-		scanner := bufio.NewScanner(stdout)
-		scanner.Split(scanNullTerminated)
+// watchWithPolling checks each file's size and modification time at a fixed
+// interval. Used when native notifications are unavailable.
+func (f *fileWatch) watchWithPolling() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
 
-		for scanner.Scan() {
-			// File was modified, check for context change
-			if err := w.handleConfigChange(); err != nil {
-				w.logger.Printf("Error handling config change: %v", err)
-			}
-		}
+	last := make([]fileSignature, len(f.paths))
+	for i, path := range f.paths {
+		last[i] = statFile(path)
+	}
 
-		if err := scanner.Err(); err != nil {
-			w.logger.Printf("Error reading fswatch output: %v", err)
-		}
+	for {
+		select {
+		case <-f.ctx.Done():
+			f.logger.Info(f.label + " file monitoring stopped")
+			return
 
-		// Wait for process to exit
-		if err := cmd.Wait(); err != nil {
-			// Check if context was canceled
-			if w.ctx.Err() != nil {
-				return nil
+		case <-ticker.C:
+			changed := false
+			for i, path := range f.paths {
+				current := statFile(path)
+				if current == last[i] {
+					continue
+				}
+				last[i] = current
+
+				// A missing file is a transient state (e.g. during an atomic rewrite)
+				if current.exists {
+					changed = true
+				}
+			}
+			if !changed {
+				continue
 			}
-			// Otherwise log error and retry
-			w.logger.Printf("fswatch process exited with error: %v, retrying in 5 seconds...", err)
-			time.Sleep(5 * time.Second)
+
+			f.handleChange()
 		}
 	}
 }
 
-// handleConfigChange is called when the kubeconfig file changes
+// fileSignature captures the attributes used to detect changes when polling
+type fileSignature struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// statFile returns the current signature of the file at path
+func statFile(path string) fileSignature {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileSignature{}
+	}
+	return fileSignature{
+		exists:  true,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	}
+}
+
+// handleConfigChange is called when any kubeconfig file changes
 // It checks if the context actually changed and records activity if so
 func (w *KubeconfigWatcher) handleConfigChange() error {
 	// Get current context
@@ -154,43 +336,40 @@ func (w *KubeconfigWatcher) handleConfigChange() error {
 	}
 
 	// Get last recorded context
-	_, lastContext, err := w.stateManager.GetLastActivity()
+	_, lastContext, err := LastActivity(w.stateManager)
 	if err != nil {
 		// If we can't get last activity, record fresh activity
-		w.logger.Printf("Detected context switch to '%s' (no previous state)", currentContext)
-		return w.stateManager.RecordActivity(currentContext)
+		w.logger.Info("Detected context switch with no previous state", "context", currentContext)
+		return RecordActivity(w.stateManager, currentContext)
 	}
 
 	// Check if context actually changed
 	if lastContext != currentContext {
-		w.logger.Printf("Detected context switch from '%s' to '%s' via file monitoring", lastContext, currentContext)
-		return w.stateManager.RecordActivity(currentContext)
+		w.logger.Info("Detected context switch via file monitoring",
+			"from", lastContext, "context", currentContext, "source", guessContextSource(currentContext))
+		w.recordHistory(HistoryEvent{
+			Type:        HistoryContextChange,
+			Context:     currentContext,
+			FromContext: lastContext,
+			Reason:      "kubeconfig changed",
+		})
+		return RecordActivity(w.stateManager, currentContext)
 	}
 
 	// Context didn't change, but file was modified (might be other kubeconfig changes)
 	// Still record activity to extend timeout
-	w.logger.Printf("Detected kubeconfig modification while in context '%s' (extending timeout)", currentContext)
-	return w.stateManager.RecordActivity(currentContext)
+	w.logger.Debug("Detected kubeconfig modification, extending timeout", "context", currentContext)
+	w.recordHistory(HistoryEvent{
+		Type:    HistoryActivity,
+		Context: currentContext,
+		Reason:  "kubeconfig modified",
+	})
+	return RecordActivity(w.stateManager, currentContext)
 }
 
-// scanNullTerminated is a split function for bufio.Scanner that splits on NUL bytes
-func scanNullTerminated(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF && len(data) == 0 {
-		return 0, nil, nil
+// recordHistory appends an event to the history log, if the watcher has one
+func (w *KubeconfigWatcher) recordHistory(event HistoryEvent) {
+	if err := w.history.Append(event); err != nil {
+		w.logger.Warn("Failed to record history", "error", err)
 	}
-
-	// Find NUL terminator
-	for i := 0; i < len(data); i++ {
-		if data[i] == 0 {
-			return i + 1, data[0:i], nil
-		}
-	}
-
-	// If we're at EOF, return what we have
-	if atEOF {
-		return len(data), data, nil
-	}
-
-	// Request more data
-	return 0, nil, nil
 }
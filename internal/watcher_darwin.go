@@ -0,0 +1,210 @@
+//go:build darwin
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileVnodeMask selects the changes to the file itself that indicate the
+// kubeconfig may have changed
+const fileVnodeMask = unix.NOTE_WRITE | unix.NOTE_EXTEND | unix.NOTE_ATTRIB |
+	unix.NOTE_DELETE | unix.NOTE_RENAME | unix.NOTE_REVOKE
+
+// dirVnodeMask selects the changes to the parent directory. kqueue can only
+// watch open files, so as with inotify the directory is watched too, to see
+// atomic rewrites (write to temp file, rename over the original) and a file
+// that doesn't exist yet being created.
+const dirVnodeMask = unix.NOTE_WRITE | unix.NOTE_DELETE | unix.NOTE_RENAME | unix.NOTE_REVOKE
+
+// kqueueNotifier watches a single file via kqueue on the file and its parent
+// directory
+type kqueueNotifier struct {
+	kq   int
+	path string
+	dir  int
+
+	// file is the watched file's descriptor, or -1 if it doesn't exist, and
+	// info identifies it, to tell when a directory change replaced it
+	file int
+	info os.FileInfo
+
+	// wake is a pipe whose write end Close closes, waking kevent
+	wake      [2]int
+	events    chan struct{}
+	closeOnce sync.Once
+}
+
+// newNativeNotifier creates a kqueue-based notifier for the given file
+func newNativeNotifier(path string) (fileNotifier, error) {
+	// Follow symlinks (e.g. ~/.kube/config managed by a dotfiles repo) so
+	// writes to the real file are seen
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	dirPath := filepath.Dir(path)
+	if _, err := os.Stat(dirPath); err != nil {
+		return nil, fmt.Errorf("kubeconfig directory not accessible: %w", err)
+	}
+
+	kq, err := unix.Kqueue()
+	if err != nil {
+		return nil, fmt.Errorf("kqueue failed: %w", err)
+	}
+	unix.CloseOnExec(kq)
+	n := &kqueueNotifier{kq: kq, path: path, dir: -1, file: -1, wake: [2]int{-1, -1}, events: make(chan struct{}, 1)}
+
+	if err := unix.Pipe(n.wake[:]); err != nil {
+		n.closeDescriptors()
+		return nil, fmt.Errorf("pipe failed: %w", err)
+	}
+	unix.CloseOnExec(n.wake[0])
+	unix.CloseOnExec(n.wake[1])
+	if err := n.register(n.wake[0], unix.EVFILT_READ, 0); err != nil {
+		n.closeDescriptors()
+		return nil, err
+	}
+
+	if n.dir, err = openEventOnly(dirPath); err != nil {
+		n.closeDescriptors()
+		return nil, fmt.Errorf("failed to open %s: %w", dirPath, err)
+	}
+	if err := n.register(n.dir, unix.EVFILT_VNODE, dirVnodeMask); err != nil {
+		n.closeDescriptors()
+		return nil, err
+	}
+	n.watchFile()
+
+	go n.readEvents()
+
+	return n, nil
+}
+
+// openEventOnly opens a file or directory only to watch it, so the watch
+// doesn't keep its volume from being unmounted
+func openEventOnly(path string) (int, error) {
+	return unix.Open(path, unix.O_EVTONLY|unix.O_CLOEXEC, 0)
+}
+
+// register adds a descriptor to the kqueue
+func (n *kqueueNotifier) register(fd int, filter int16, fflags uint32) error {
+	var change unix.Kevent_t
+	unix.SetKevent(&change, fd, int(filter), unix.EV_ADD|unix.EV_CLEAR)
+	change.Fflags = fflags
+	if _, err := unix.Kevent(n.kq, []unix.Kevent_t{change}, nil, nil); err != nil {
+		return fmt.Errorf("kevent failed: %w", err)
+	}
+	return nil
+}
+
+// watchFile watches the file now at the path, replacing the watch on the
+// one before it. It reports whether the file changed identity: created,
+// removed, or replaced by another.
+func (n *kqueueNotifier) watchFile() bool {
+	info, err := os.Stat(n.path)
+	if err != nil {
+		info = nil
+	}
+	if n.file >= 0 && info != nil && os.SameFile(info, n.info) {
+		return false
+	}
+	replaced := n.file >= 0 || info != nil
+
+	// Closing a descriptor removes its kqueue registration
+	if n.file >= 0 {
+		_ = unix.Close(n.file)
+		n.file = -1
+	}
+	n.info = nil
+	if info != nil {
+		if fd, err := openEventOnly(n.path); err == nil {
+			if n.register(fd, unix.EVFILT_VNODE, fileVnodeMask) == nil {
+				n.file, n.info = fd, info
+			} else {
+				_ = unix.Close(fd)
+			}
+		}
+	}
+	return replaced
+}
+
+// Events returns the channel signaled when the watched file changes
+func (n *kqueueNotifier) Events() <-chan struct{} {
+	return n.events
+}
+
+// Close stops the notifier. The event loop releases the kqueue descriptors
+// when it sees the wake pipe close.
+func (n *kqueueNotifier) Close() error {
+	var err error
+	n.closeOnce.Do(func() {
+		err = unix.Close(n.wake[1])
+	})
+	return err
+}
+
+// closeDescriptors releases every descriptor but the wake pipe's write end,
+// which belongs to Close
+func (n *kqueueNotifier) closeDescriptors() {
+	for _, fd := range []int{n.file, n.dir, n.wake[0], n.kq} {
+		if fd >= 0 {
+			_ = unix.Close(fd)
+		}
+	}
+	n.file, n.dir, n.wake[0], n.kq = -1, -1, -1, -1
+}
+
+// readEvents waits for kqueue events and forwards those for the watched file
+func (n *kqueueNotifier) readEvents() {
+	defer close(n.events)
+	defer n.closeDescriptors()
+
+	buf := make([]unix.Kevent_t, 8)
+	for {
+		count, err := unix.Kevent(n.kq, nil, buf, nil)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		changed := false
+		for _, event := range buf[:count] {
+			switch int(event.Ident) {
+			case n.wake[0]:
+				return
+			case n.dir:
+				if event.Fflags&(unix.NOTE_DELETE|unix.NOTE_RENAME|unix.NOTE_REVOKE) != 0 {
+					// The directory itself has gone away
+					return
+				}
+				// An entry was added, removed, or renamed; only those that
+				// replace the file matter
+				if n.watchFile() {
+					changed = true
+				}
+			case n.file:
+				changed = true
+				if event.Fflags&(unix.NOTE_DELETE|unix.NOTE_RENAME|unix.NOTE_REVOKE) != 0 {
+					n.watchFile()
+				}
+			}
+		}
+
+		if changed {
+			// Non-blocking send: one pending signal is enough to trigger a check
+			select {
+			case n.events <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
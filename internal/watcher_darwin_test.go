@@ -0,0 +1,119 @@
+//go:build darwin
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// expectEvent fails the test unless the notifier signals a change soon, then
+// drains any follow-up events from the same change
+func expectEvent(t *testing.T, notifier fileNotifier, what string) {
+	t.Helper()
+	select {
+	case <-notifier.Events():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected event for %s", what)
+	}
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-notifier.Events():
+	default:
+	}
+}
+
+func TestKqueueNotifier(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config")
+	if err := os.WriteFile(path, []byte("initial"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	notifier, err := newNativeNotifier(path)
+	if err != nil {
+		t.Fatalf("newNativeNotifier failed: %v", err)
+	}
+
+	// Changes to other files in the directory are ignored
+	if err := os.WriteFile(filepath.Join(tmpDir, "other"), []byte("x"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	select {
+	case <-notifier.Events():
+		t.Fatal("unexpected event for unrelated file")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Writes in place are detected
+	if err := os.WriteFile(path, []byte("written"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	expectEvent(t, notifier, "write to watched file")
+
+	// Atomic replace of the watched file is detected
+	tmpPath := filepath.Join(tmpDir, "config.tmp")
+	if err := os.WriteFile(tmpPath, []byte("updated"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		t.Fatalf("Failed to rename file: %v", err)
+	}
+	expectEvent(t, notifier, "atomic replace of watched file")
+
+	// And so are writes to the file that replaced it
+	if err := os.WriteFile(path, []byte("rewritten"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	expectEvent(t, notifier, "write to replaced file")
+
+	// Close stops the notifier and closes the events channel
+	if err := notifier.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if err := notifier.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-notifier.Events():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events channel not closed after Close")
+		}
+	}
+}
+
+func TestKqueueNotifierCreatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+
+	notifier, err := newNativeNotifier(path)
+	if err != nil {
+		t.Fatalf("newNativeNotifier failed: %v", err)
+	}
+	defer notifier.Close()
+
+	// A kubeconfig that doesn't exist yet is seen once created
+	if err := os.WriteFile(path, []byte("created"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	expectEvent(t, notifier, "creation of watched file")
+
+	if err := os.WriteFile(path, []byte("written"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	expectEvent(t, notifier, "write to created file")
+}
+
+func TestKqueueNotifierMissingDirectory(t *testing.T) {
+	_, err := newNativeNotifier(filepath.Join(t.TempDir(), "missing", "config"))
+	if err == nil {
+		t.Error("expected error when kubeconfig directory does not exist")
+	}
+}
@@ -0,0 +1,127 @@
+//go:build linux
+
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// inotifyMask selects the events that indicate the kubeconfig may have changed.
+// The parent directory is watched rather than the file itself so that atomic
+// rewrites (write to temp file, rename over the original) are detected too.
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_CREATE |
+	syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyNotifier watches a single file via inotify on its parent directory
+type inotifyNotifier struct {
+	file      *os.File
+	name      string
+	events    chan struct{}
+	closeOnce sync.Once
+}
+
+// newNativeNotifier creates an inotify-based notifier for the given file
+func newNativeNotifier(path string) (fileNotifier, error) {
+	// Follow symlinks (e.g. ~/.kube/config managed by a dotfiles repo) so
+	// writes to the real file are seen
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("kubeconfig directory not accessible: %w", err)
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify_init1 failed: %w", err)
+	}
+
+	if _, err := syscall.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("inotify_add_watch failed for %s: %w", dir, err)
+	}
+
+	// A non-blocking descriptor is registered with the runtime poller, so
+	// Close unblocks a pending Read
+	n := &inotifyNotifier{
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		name:   filepath.Base(path),
+		events: make(chan struct{}, 1),
+	}
+	go n.readEvents()
+
+	return n, nil
+}
+
+// Events returns the channel signaled when the watched file changes
+func (n *inotifyNotifier) Events() <-chan struct{} {
+	return n.events
+}
+
+// Close stops the notifier and releases the inotify descriptor
+func (n *inotifyNotifier) Close() error {
+	var err error
+	n.closeOnce.Do(func() {
+		err = n.file.Close()
+	})
+	return err
+}
+
+// readEvents reads raw inotify events and forwards those for the watched file
+func (n *inotifyNotifier) readEvents() {
+	defer close(n.events)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		changed, stop := parseInotifyEvents(buf[:count], n.name)
+		if changed {
+			// Non-blocking send: one pending signal is enough to trigger a check
+			select {
+			case n.events <- struct{}{}:
+			default:
+			}
+		}
+		if stop {
+			return
+		}
+	}
+}
+
+// parseInotifyEvents decodes a buffer of inotify events. It reports whether any
+// event concerns the named file, and whether the watch itself has gone away.
+func parseInotifyEvents(buf []byte, name string) (changed bool, stop bool) {
+	offset := 0
+	for offset+syscall.SizeofInotifyEvent <= len(buf) {
+		mask := binary.NativeEndian.Uint32(buf[offset+4 : offset+8])
+		nameLen := int(binary.NativeEndian.Uint32(buf[offset+12 : offset+16]))
+
+		start := offset + syscall.SizeofInotifyEvent
+		end := start + nameLen
+		if end > len(buf) {
+			break
+		}
+		eventName := string(bytes.TrimRight(buf[start:end], "\x00"))
+
+		if mask&(syscall.IN_IGNORED|syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+			stop = true
+		} else if eventName == name {
+			changed = true
+		}
+
+		offset = end
+	}
+	return changed, stop
+}
@@ -0,0 +1,177 @@
+//go:build linux
+
+package internal
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// rawInotifyEvent encodes an inotify event the way the kernel does
+func rawInotifyEvent(mask uint32, name string) []byte {
+	nameLen := 0
+	if name != "" {
+		// Names are NUL-terminated and padded
+		nameLen = (len(name)/16 + 1) * 16
+	}
+	buf := make([]byte, syscall.SizeofInotifyEvent+nameLen)
+	binary.NativeEndian.PutUint32(buf[0:4], 1)
+	binary.NativeEndian.PutUint32(buf[4:8], mask)
+	binary.NativeEndian.PutUint32(buf[12:16], uint32(nameLen))
+	copy(buf[syscall.SizeofInotifyEvent:], name)
+	return buf
+}
+
+func TestParseInotifyEvents(t *testing.T) {
+	tests := []struct {
+		name        string
+		buf         []byte
+		wantChanged bool
+		wantStop    bool
+	}{
+		{
+			name:        "write to watched file",
+			buf:         rawInotifyEvent(syscall.IN_CLOSE_WRITE, "config"),
+			wantChanged: true,
+		},
+		{
+			name: "write to unrelated file",
+			buf:  rawInotifyEvent(syscall.IN_CLOSE_WRITE, "state.json"),
+		},
+		{
+			name: "multiple events",
+			buf: append(rawInotifyEvent(syscall.IN_CREATE, "config.lock"),
+				rawInotifyEvent(syscall.IN_MOVED_TO, "config")...),
+			wantChanged: true,
+		},
+		{
+			name:     "watch removed",
+			buf:      rawInotifyEvent(syscall.IN_IGNORED, ""),
+			wantStop: true,
+		},
+		{
+			name: "truncated buffer",
+			buf:  rawInotifyEvent(syscall.IN_CLOSE_WRITE, "config")[:syscall.SizeofInotifyEvent+2],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed, stop := parseInotifyEvents(tt.buf, "config")
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if stop != tt.wantStop {
+				t.Errorf("stop = %v, want %v", stop, tt.wantStop)
+			}
+		})
+	}
+}
+
+func TestInotifyNotifier(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config")
+	if err := os.WriteFile(path, []byte("initial"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	notifier, err := newNativeNotifier(path)
+	if err != nil {
+		t.Fatalf("newNativeNotifier failed: %v", err)
+	}
+
+	// Changes to other files in the directory are ignored
+	if err := os.WriteFile(filepath.Join(tmpDir, "other"), []byte("x"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	select {
+	case <-notifier.Events():
+		t.Fatal("unexpected event for unrelated file")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Atomic replace of the watched file is detected
+	tmpPath := filepath.Join(tmpDir, "config.tmp")
+	if err := os.WriteFile(tmpPath, []byte("updated"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		t.Fatalf("Failed to rename file: %v", err)
+	}
+	select {
+	case <-notifier.Events():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected event for atomic replace of watched file")
+	}
+
+	// Close stops the notifier and closes the events channel
+	if err := notifier.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if err := notifier.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-notifier.Events():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events channel not closed after Close")
+		}
+	}
+}
+
+func TestInotifyNotifierMissingDirectory(t *testing.T) {
+	_, err := newNativeNotifier(filepath.Join(t.TempDir(), "missing", "config"))
+	if err == nil {
+		t.Error("expected error when kubeconfig directory does not exist")
+	}
+}
+
+func TestMultiNotifier(t *testing.T) {
+	firstDir, secondDir := t.TempDir(), t.TempDir()
+	first := filepath.Join(firstDir, "config")
+	second := filepath.Join(secondDir, "config")
+
+	notifier, err := newMultiNotifier([]string{first, second})
+	if err != nil {
+		t.Fatalf("newMultiNotifier failed: %v", err)
+	}
+	defer notifier.Close()
+
+	// A change to any file in the list is reported
+	for _, path := range []string{second, first} {
+		if err := os.WriteFile(path, []byte("updated"), 0600); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		select {
+		case <-notifier.Events():
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected event for write to %s", path)
+		}
+		// Drain any follow-up events from the same write
+		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-notifier.Events():
+		default:
+		}
+	}
+}
+
+func TestMultiNotifierMissingDirectory(t *testing.T) {
+	paths := []string{
+		filepath.Join(t.TempDir(), "config"),
+		filepath.Join(t.TempDir(), "missing", "config"),
+	}
+	if _, err := newMultiNotifier(paths); err == nil {
+		t.Error("expected error when any kubeconfig directory does not exist")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !windows && !darwin
+
+package internal
+
+import "errors"
+
+// newNativeNotifier reports that native notifications are not implemented on
+// this platform, so the watcher falls back to polling
+func newNativeNotifier(path string) (fileNotifier, error) {
+	return nil, errors.New("native file notifications not supported on this platform")
+}
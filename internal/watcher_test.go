@@ -2,9 +2,10 @@ package internal
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -21,7 +22,7 @@ func TestNewKubeconfigWatcher(t *testing.T) {
 	}
 
 	// Create logger
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
+	logger := NewLogger(os.Stdout, LogFormatText, slog.LevelDebug)
 
 	// Create context
 	ctx := context.Background()
@@ -37,8 +38,8 @@ func TestNewKubeconfigWatcher(t *testing.T) {
 	}
 
 	// Verify kubeconfig path was set correctly
-	if watcher.kubeconfigPath == "" {
-		t.Error("Kubeconfig path should not be empty")
+	if len(watcher.kubeconfigPaths) != 1 {
+		t.Fatalf("Expected a single kubeconfig path, got %v", watcher.kubeconfigPaths)
 	}
 
 	home, err := os.UserHomeDir()
@@ -47,8 +48,8 @@ func TestNewKubeconfigWatcher(t *testing.T) {
 	}
 	expectedPath := filepath.Join(home, ".kube", "config")
 
-	if watcher.kubeconfigPath != expectedPath {
-		t.Errorf("Expected kubeconfig path %s, got %s", expectedPath, watcher.kubeconfigPath)
+	if watcher.kubeconfigPaths[0] != expectedPath {
+		t.Errorf("Expected kubeconfig path %s, got %s", expectedPath, watcher.kubeconfigPaths[0])
 	}
 }
 
@@ -70,7 +71,7 @@ func TestNewKubeconfigWatcher_WithEnvVar(t *testing.T) {
 	}
 
 	// Create logger
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
+	logger := NewLogger(os.Stdout, LogFormatText, slog.LevelDebug)
 
 	// Create context
 	ctx := context.Background()
@@ -81,43 +82,11 @@ func TestNewKubeconfigWatcher_WithEnvVar(t *testing.T) {
 		t.Fatalf("Failed to create kubeconfig watcher: %v", err)
 	}
 
-	if watcher.kubeconfigPath != testKubeconfigPath {
-		t.Errorf("Expected kubeconfig path %s from env var, got %s", testKubeconfigPath, watcher.kubeconfigPath)
+	if len(watcher.kubeconfigPaths) != 1 || watcher.kubeconfigPaths[0] != testKubeconfigPath {
+		t.Errorf("Expected kubeconfig path %s from env var, got %v", testKubeconfigPath, watcher.kubeconfigPaths)
 	}
 }
 
-func TestKubeconfigWatcher_IsFswatchAvailable(t *testing.T) {
-	// Create temporary directory for test
-	tmpDir := t.TempDir()
-	statePath := filepath.Join(tmpDir, "state.json")
-
-	// Create state manager
-	sm, err := NewStateManager(statePath)
-	if err != nil {
-		t.Fatalf("Failed to create state manager: %v", err)
-	}
-
-	// Create logger
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
-
-	// Create context
-	ctx := context.Background()
-
-	watcher, err := NewKubeconfigWatcher(sm, logger, ctx)
-	if err != nil {
-		t.Fatalf("Failed to create kubeconfig watcher: %v", err)
-	}
-
-	// Test fswatch availability
-	// On macOS with fswatch installed, this should return true
-	// On other platforms or without fswatch, it should return false
-	available := watcher.isFswatchAvailable()
-
-	t.Logf("fswatch available: %v", available)
-	// We don't assert the value since it depends on the environment
-	// Just verify the method doesn't panic
-}
-
 func TestKubeconfigWatcher_HandleConfigChange(t *testing.T) {
 	// Check if kubectl is available
 	if _, err := GetCurrentContext(); err != nil {
@@ -138,7 +107,7 @@ func TestKubeconfigWatcher_HandleConfigChange(t *testing.T) {
 	}
 
 	// Create logger
-	logger := log.New(os.Stdout, "[test] ", log.LstdFlags)
+	logger := NewLogger(os.Stdout, LogFormatText, slog.LevelDebug)
 
 	// Create context
 	ctx := context.Background()
@@ -149,7 +118,7 @@ func TestKubeconfigWatcher_HandleConfigChange(t *testing.T) {
 	}
 
 	// Record initial activity
-	if err := sm.RecordActivity("staging"); err != nil {
+	if err := RecordActivity(sm, "staging"); err != nil {
 		t.Fatalf("Failed to record initial activity: %v", err)
 	}
 
@@ -157,7 +126,7 @@ func TestKubeconfigWatcher_HandleConfigChange(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	// Get last activity timestamp
-	lastActivity, _, err := sm.GetLastActivity()
+	lastActivity, _, err := LastActivity(sm)
 	if err != nil {
 		t.Fatalf("Failed to get last activity: %v", err)
 	}
@@ -168,7 +137,7 @@ func TestKubeconfigWatcher_HandleConfigChange(t *testing.T) {
 	}
 
 	// Verify activity was recorded
-	newActivity, context, err := sm.GetLastActivity()
+	newActivity, context, err := LastActivity(sm)
 	if err != nil {
 		t.Fatalf("Failed to get new activity: %v", err)
 	}
@@ -184,64 +153,224 @@ func TestKubeconfigWatcher_HandleConfigChange(t *testing.T) {
 	t.Logf("Context after change: %s", context)
 }
 
-func TestScanNullTerminated(t *testing.T) {
-	tests := []struct {
-		name    string
-		data    []byte
-		atEOF   bool
-		wantAdv int
-		wantTok []byte
-		wantErr error
-	}{
-		{
-			name:    "simple null-terminated string",
-			data:    []byte("hello\x00world"),
-			atEOF:   false,
-			wantAdv: 6,
-			wantTok: []byte("hello"),
-			wantErr: nil,
-		},
-		{
-			name:    "empty string at EOF",
-			data:    []byte{},
-			atEOF:   true,
-			wantAdv: 0,
-			wantTok: nil,
-			wantErr: nil,
-		},
-		{
-			name:    "incomplete data, not at EOF",
-			data:    []byte("incomplete"),
-			atEOF:   false,
-			wantAdv: 0,
-			wantTok: nil,
-			wantErr: nil,
-		},
-		{
-			name:    "incomplete data at EOF",
-			data:    []byte("incomplete"),
-			atEOF:   true,
-			wantAdv: 10,
-			wantTok: []byte("incomplete"),
-			wantErr: nil,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			adv, tok, err := scanNullTerminated(tt.data, tt.atEOF)
-
-			if adv != tt.wantAdv {
-				t.Errorf("advance = %d, want %d", adv, tt.wantAdv)
-			}
+func TestStatFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "kubeconfig")
 
-			if string(tok) != string(tt.wantTok) {
-				t.Errorf("token = %q, want %q", tok, tt.wantTok)
-			}
+	if sig := statFile(path); sig.exists {
+		t.Error("expected missing file to report exists=false")
+	}
+
+	if err := os.WriteFile(path, []byte("one"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	first := statFile(path)
+	if !first.exists || first.size != 3 {
+		t.Errorf("unexpected signature for new file: %+v", first)
+	}
+
+	if err := os.WriteFile(path, []byte("three"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if second := statFile(path); second == first {
+		t.Error("expected signature to change after rewrite")
+	}
+}
+
+func TestKubeconfigWatcher_Polling(t *testing.T) {
+	tmpDir := t.TempDir()
+	cleanup := setupTestKubeconfig(t, tmpDir)
+	defer cleanup()
+
+	if _, err := GetCurrentContext(); err != nil {
+		t.Skipf("Skipping test: kubectl not available or not working: %v", err)
+	}
+
+	sm, err := NewStateManager(filepath.Join(tmpDir, "state.json"))
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher, err := NewKubeconfigWatcher(sm, NewLogger(os.Stdout, LogFormatText, slog.LevelDebug), ctx)
+	if err != nil {
+		t.Fatalf("Failed to create kubeconfig watcher: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		watcher.watchWithPolling()
+		close(done)
+	}()
+
+	// Let the poller take its initial snapshot before modifying the file
+	time.Sleep(100 * time.Millisecond)
+
+	kubeconfigPath := GetKubeconfigPath()
+	content, err := os.ReadFile(kubeconfigPath)
+	if err != nil {
+		t.Fatalf("Failed to read kubeconfig: %v", err)
+	}
+	if err := os.WriteFile(kubeconfigPath, append(content, []byte("\n# modified\n")...), 0600); err != nil {
+		t.Fatalf("Failed to modify kubeconfig: %v", err)
+	}
+
+	deadline := time.Now().Add(3 * pollInterval)
+	for time.Now().Before(deadline) {
+		if _, context, _ := LastActivity(sm); context != "" {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
 
-			if err != tt.wantErr {
-				t.Errorf("error = %v, want %v", err, tt.wantErr)
+	if _, context, _ := LastActivity(sm); context != "test-default" {
+		t.Errorf("expected polling to record activity for 'test-default', got %q", context)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("polling watcher did not stop after context cancellation")
+	}
+}
+
+func TestNewKubeconfigWatcher_MultiplePaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	first := filepath.Join(tmpDir, "first")
+	second := filepath.Join(tmpDir, "second")
+
+	t.Setenv("KUBECONFIG", first+string(filepath.ListSeparator)+second)
+
+	sm, err := NewStateManager(filepath.Join(tmpDir, "state.json"))
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+
+	watcher, err := NewKubeconfigWatcher(sm, NewLogger(os.Stdout, LogFormatText, slog.LevelDebug), context.Background())
+	if err != nil {
+		t.Fatalf("Failed to create kubeconfig watcher: %v", err)
+	}
+
+	if len(watcher.kubeconfigPaths) != 2 || watcher.kubeconfigPaths[0] != first || watcher.kubeconfigPaths[1] != second {
+		t.Errorf("Expected kubeconfig paths [%s %s], got %v", first, second, watcher.kubeconfigPaths)
+	}
+}
+
+// fakeNotifier is a fileNotifier driven directly by tests
+type fakeNotifier struct {
+	events    chan struct{}
+	closeOnce sync.Once
+}
+
+func newFakeNotifier() *fakeNotifier {
+	return &fakeNotifier{events: make(chan struct{}, 1)}
+}
+
+func (f *fakeNotifier) Events() <-chan struct{} {
+	return f.events
+}
+
+func (f *fakeNotifier) Close() error {
+	f.closeOnce.Do(func() { close(f.events) })
+	return nil
+}
+
+func TestMergedNotifier(t *testing.T) {
+	a, b := newFakeNotifier(), newFakeNotifier()
+	merged := newMergedNotifier([]fileNotifier{a, b})
+
+	// Events from either notifier are forwarded
+	for _, n := range []*fakeNotifier{a, b} {
+		n.events <- struct{}{}
+		select {
+		case <-merged.Events():
+		case <-time.After(time.Second):
+			t.Fatal("expected event to be forwarded")
+		}
+	}
+
+	// When one notifier stops, the merged stream stops too
+	_ = a.Close()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-merged.Events():
+			if !ok {
+				// The other notifier was closed as well
+				if _, open := <-b.events; open {
+					t.Error("expected remaining notifier to be closed")
+				}
+				return
 			}
-		})
+		case <-deadline:
+			t.Fatal("merged notifier did not stop after a notifier stopped")
+		}
+	}
+}
+
+func TestKubeconfigWatcher_PollingMultipleFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	cleanup := setupTestKubeconfig(t, tmpDir)
+	defer cleanup()
+
+	if _, err := GetCurrentContext(); err != nil {
+		t.Skipf("Skipping test: kubectl not available or not working: %v", err)
+	}
+
+	// Add a second kubeconfig without a current-context
+	second := filepath.Join(tmpDir, "second-kubeconfig.yaml")
+	if err := os.WriteFile(second, []byte("apiVersion: v1\nkind: Config\n"), 0600); err != nil {
+		t.Fatalf("Failed to write second kubeconfig: %v", err)
+	}
+	// setupTestKubeconfig's cleanup restores the original KUBECONFIG
+	os.Setenv("KUBECONFIG", GetKubeconfigPath()+string(filepath.ListSeparator)+second)
+
+	sm, err := NewStateManager(filepath.Join(tmpDir, "state.json"))
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher, err := NewKubeconfigWatcher(sm, NewLogger(os.Stdout, LogFormatText, slog.LevelDebug), ctx)
+	if err != nil {
+		t.Fatalf("Failed to create kubeconfig watcher: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		watcher.watchWithPolling()
+		close(done)
+	}()
+
+	// Let the poller take its initial snapshot before modifying the file
+	time.Sleep(100 * time.Millisecond)
+
+	// Only the second file changes
+	if err := os.WriteFile(second, []byte("apiVersion: v1\nkind: Config\n# modified\n"), 0600); err != nil {
+		t.Fatalf("Failed to modify second kubeconfig: %v", err)
+	}
+
+	deadline := time.Now().Add(3 * pollInterval)
+	for time.Now().Before(deadline) {
+		if _, context, _ := LastActivity(sm); context != "" {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, context, _ := LastActivity(sm); context != "test-default" {
+		t.Errorf("expected change to second kubeconfig to record activity for 'test-default', got %q", context)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("polling watcher did not stop after context cancellation")
 	}
 }
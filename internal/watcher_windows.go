@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+// directoryChangesMask selects the changes that indicate the kubeconfig may
+// have changed. As with inotify, the parent directory is watched rather than
+// the file itself so that atomic rewrites are detected too.
+const directoryChangesMask = windows.FILE_NOTIFY_CHANGE_FILE_NAME | windows.FILE_NOTIFY_CHANGE_LAST_WRITE |
+	windows.FILE_NOTIFY_CHANGE_SIZE | windows.FILE_NOTIFY_CHANGE_CREATION
+
+// fileNotifyInformationSize is the size of FILE_NOTIFY_INFORMATION without
+// its file name
+const fileNotifyInformationSize = 12
+
+// directoryChangesNotifier watches a single file via ReadDirectoryChangesW on
+// its parent directory
+type directoryChangesNotifier struct {
+	name   string
+	events chan struct{}
+
+	mu     sync.Mutex
+	handle windows.Handle
+	closed bool
+}
+
+// newNativeNotifier creates a ReadDirectoryChangesW-based notifier for the
+// given file
+func newNativeNotifier(path string) (fileNotifier, error) {
+	// Follow symlinks so writes to the real file are seen
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("kubeconfig directory not accessible: %w", err)
+	}
+
+	dirPtr, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig directory %s: %w", dir, err)
+	}
+
+	// Share everything, so the watch never stops kubectl replacing the file
+	handle, err := windows.CreateFile(dirPtr, windows.FILE_LIST_DIRECTORY,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil,
+		windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s for watching: %w", dir, err)
+	}
+
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		_ = windows.CloseHandle(handle)
+		return nil, fmt.Errorf("failed to create event: %w", err)
+	}
+
+	n := &directoryChangesNotifier{
+		name:   filepath.Base(path),
+		events: make(chan struct{}, 1),
+		handle: handle,
+	}
+	go n.readEvents(event)
+
+	return n, nil
+}
+
+// Events returns the channel signaled when the watched file changes
+func (n *directoryChangesNotifier) Events() <-chan struct{} {
+	return n.events
+}
+
+// Close stops the notifier by canceling the pending read; readEvents then
+// releases the directory handle
+func (n *directoryChangesNotifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil
+	}
+	n.closed = true
+	if err := windows.CancelIoEx(n.handle, nil); err != nil && err != windows.ERROR_NOT_FOUND {
+		return fmt.Errorf("failed to cancel directory watch: %w", err)
+	}
+	return nil
+}
+
+// readEvents reads directory changes and forwards those for the watched file
+func (n *directoryChangesNotifier) readEvents(event windows.Handle) {
+	defer close(n.events)
+	defer func() {
+		n.mu.Lock()
+		n.closed = true
+		_ = windows.CloseHandle(n.handle)
+		_ = windows.CloseHandle(event)
+		n.mu.Unlock()
+	}()
+
+	// ReadDirectoryChangesW needs a DWORD-aligned buffer, which an
+	// allocation this size always is
+	buf := make([]byte, 64*1024)
+	for {
+		overlapped := windows.Overlapped{HEvent: event}
+		if err := windows.ReadDirectoryChanges(n.handle, &buf[0], uint32(len(buf)), false,
+			directoryChangesMask, nil, &overlapped, 0); err != nil {
+			return
+		}
+
+		// Fails with ERROR_OPERATION_ABORTED once Close cancels the read, or
+		// when the directory itself goes away
+		var count uint32
+		if err := windows.GetOverlappedResult(n.handle, &overlapped, &count, true); err != nil {
+			return
+		}
+
+		// A count of zero means the buffer overflowed and the changes were
+		// lost, so the file may have changed
+		if count == 0 || parseDirectoryChanges(buf[:count], n.name) {
+			// Non-blocking send: one pending signal is enough to trigger a check
+			select {
+			case n.events <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// parseDirectoryChanges decodes a buffer of FILE_NOTIFY_INFORMATION records.
+// It reports whether any of them concerns the named file; Windows file names
+// are case-insensitive.
+func parseDirectoryChanges(buf []byte, name string) bool {
+	offset := 0
+	for offset+fileNotifyInformationSize <= len(buf) {
+		next := int(binary.LittleEndian.Uint32(buf[offset : offset+4]))
+		nameLen := int(binary.LittleEndian.Uint32(buf[offset+8 : offset+12]))
+
+		start := offset + fileNotifyInformationSize
+		end := start + nameLen
+		if end > len(buf) {
+			break
+		}
+		units := make([]uint16, nameLen/2)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(buf[start+2*i:])
+		}
+		if strings.EqualFold(string(utf16.Decode(units)), name) {
+			return true
+		}
+
+		if next == 0 {
+			break
+		}
+		offset += next
+	}
+	return false
+}
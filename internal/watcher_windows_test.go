@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+// rawDirectoryChanges encodes FILE_NOTIFY_INFORMATION records the way
+// ReadDirectoryChangesW does, one per name
+func rawDirectoryChanges(action uint32, names ...string) []byte {
+	var buf []byte
+	for i, name := range names {
+		units := utf16.Encode([]rune(name))
+		// Records are DWORD-aligned
+		size := (fileNotifyInformationSize + 2*len(units) + 3) &^ 3
+		record := make([]byte, size)
+		if i < len(names)-1 {
+			binary.LittleEndian.PutUint32(record[0:4], uint32(size))
+		}
+		binary.LittleEndian.PutUint32(record[4:8], action)
+		binary.LittleEndian.PutUint32(record[8:12], uint32(2*len(units)))
+		for j, unit := range units {
+			binary.LittleEndian.PutUint16(record[fileNotifyInformationSize+2*j:], unit)
+		}
+		buf = append(buf, record...)
+	}
+	return buf
+}
+
+func TestParseDirectoryChanges(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		want bool
+	}{
+		{"write to watched file", rawDirectoryChanges(windows.FILE_ACTION_MODIFIED, "config"), true},
+		{"different case", rawDirectoryChanges(windows.FILE_ACTION_MODIFIED, "Config"), true},
+		{"write to unrelated file", rawDirectoryChanges(windows.FILE_ACTION_MODIFIED, "state.json"), false},
+		{"multiple records", rawDirectoryChanges(windows.FILE_ACTION_RENAMED_NEW_NAME, "config.lock", "config"), true},
+		{"truncated buffer", rawDirectoryChanges(windows.FILE_ACTION_MODIFIED, "config")[:fileNotifyInformationSize+2], false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDirectoryChanges(tt.buf, "config"); got != tt.want {
+				t.Errorf("parseDirectoryChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectoryChangesNotifier(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config")
+	if err := os.WriteFile(path, []byte("initial"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	notifier, err := newNativeNotifier(path)
+	if err != nil {
+		t.Fatalf("newNativeNotifier failed: %v", err)
+	}
+
+	// Changes to other files in the directory are ignored
+	if err := os.WriteFile(filepath.Join(tmpDir, "other"), []byte("x"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	select {
+	case <-notifier.Events():
+		t.Fatal("unexpected event for unrelated file")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Atomic replace of the watched file is detected
+	tmpPath := filepath.Join(tmpDir, "config.tmp")
+	if err := os.WriteFile(tmpPath, []byte("updated"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		t.Fatalf("Failed to rename file: %v", err)
+	}
+	select {
+	case <-notifier.Events():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected event for atomic replace of watched file")
+	}
+
+	// Close stops the notifier and closes the events channel
+	if err := notifier.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if err := notifier.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-notifier.Events():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events channel not closed after Close")
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Webhook defaults, used by DefaultConfig
+const (
+	DefaultWebhookTimeout = 5 * time.Second
+	DefaultWebhookRetries = 2
+)
+
+// webhookRetryDelay is the wait before the first retry; it doubles for each
+// one after that
+const webhookRetryDelay = time.Second
+
+// WebhookConfig holds the URLs that are sent a JSON payload for every switch
+type WebhookConfig struct {
+	URLs []string `yaml:"urls,omitempty"`
+
+	// Timeout bounds each attempt, and Retries is how many times a failed
+	// delivery is attempted again
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	Retries int           `yaml:"retries,omitempty"`
+}
+
+// WebhookPayload is the JSON body posted to webhooks. Text holds the
+// rendered notification message, so Slack incoming webhooks display it.
+type WebhookPayload struct {
+	Timestamp   time.Time `json:"timestamp"`
+	FromContext string    `json:"from_context"`
+	ToContext   string    `json:"to_context"`
+	Reason      string    `json:"reason"`
+	Host        string    `json:"host"`
+	Text        string    `json:"text"`
+}
+
+// NewWebhookPayload describes a switch for webhooks
+func NewWebhookPayload(event SwitchEvent, message string, now time.Time) WebhookPayload {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return WebhookPayload{
+		Timestamp:   now.UTC(),
+		FromContext: event.FromContext,
+		ToContext:   event.ToContext,
+		Reason:      event.Reason,
+		Host:        host,
+		Text:        message,
+	}
+}
+
+// validationErrors returns every problem with the webhook settings
+func (w WebhookConfig) validationErrors() []error {
+	var errs []error
+	for _, raw := range w.URLs {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			// The URL may embed a secret, so it isn't repeated in the error
+			errs = append(errs, fmt.Errorf("notifications.webhooks.urls: each entry must be an http or https URL"))
+			break
+		}
+	}
+	if w.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("notifications.webhooks.timeout must not be negative"))
+	}
+	if w.Retries < 0 {
+		errs = append(errs, fmt.Errorf("notifications.webhooks.retries must not be negative"))
+	}
+	return errs
+}
+
+// postWebhooks sends the payload to every configured URL concurrently and
+// waits for all of them, retrying failed deliveries
+func (n *Notifier) postWebhooks(payload WebhookPayload) error {
+	urls := n.config.Webhooks.URLs
+	if len(urls) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	for i, target := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = n.postWebhook(target, body)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// postWebhook delivers a payload to one URL, retrying with exponential
+// backoff on network errors, 429, and 5xx responses
+func (n *Notifier) postWebhook(target string, body []byte) error {
+	// Report the host only, since webhook URLs often embed a secret
+	host := target
+	if u, err := url.Parse(target); err == nil {
+		host = u.Host
+	}
+
+	err := n.withRetries(func() (bool, error) {
+		retry, _, err := postJSON(target, body, "", n.webhookTimeout())
+		return retry, err
+	})
+	if err != nil {
+		return fmt.Errorf("webhook to %s failed: %w", host, err)
+	}
+	return nil
+}
+
+// webhookTimeout returns the per-attempt timeout for outgoing requests
+func (n *Notifier) webhookTimeout() time.Duration {
+	if n.config.Webhooks.Timeout == 0 {
+		return DefaultWebhookTimeout
+	}
+	return n.config.Webhooks.Timeout
+}
+
+// withRetries makes a delivery attempt and retries it, per the webhook
+// retries setting, while it fails in a way worth retrying. The wait between
+// attempts doubles each time.
+func (n *Notifier) withRetries(attempt func() (retry bool, err error)) error {
+	delay := n.webhookRetryDelay
+	var err error
+	for i := 0; i <= n.config.Webhooks.Retries; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		var retry bool
+		if retry, err = attempt(); err == nil || !retry {
+			break
+		}
+	}
+	return err
+}
+
+// postJSON makes one POST attempt with a JSON body, authenticating with
+// token if set. It returns the response body on success, and whether a
+// failure is worth retrying.
+func postJSON(target string, body []byte, token string, timeout time.Duration) (bool, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, nil, fmt.Errorf("invalid request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "kubectx-timeout")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// Strip the URL from the error, it may embed a secret
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return true, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, respBody, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, nil, fmt.Errorf("unexpected status %s", resp.Status)
+}
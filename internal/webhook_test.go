@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newWebhookNotifier returns a notifier that only posts to the given URLs
+func newWebhookNotifier(retries int, urls ...string) *Notifier {
+	n := NewNotifier(NotificationConfig{
+		Enabled:  false,
+		Webhooks: WebhookConfig{URLs: urls, Timeout: time.Second, Retries: retries},
+	})
+	n.webhookRetryDelay = time.Millisecond
+	return n
+}
+
+func TestWebhookPayload(t *testing.T) {
+	received := make(chan WebhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			t.Errorf("Unexpected request %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		var payload WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		received <- payload
+	}))
+	defer server.Close()
+
+	// Webhooks are sent even with notifications disabled
+	n := newWebhookNotifier(0, server.URL)
+	event := SwitchEvent{FromContext: "prod", ToContext: "local", Reason: "inactive for 30m0s"}
+	if err := n.NotifySwitch(event); err != nil {
+		t.Fatalf("NotifySwitch() error = %v", err)
+	}
+
+	payload := <-received
+	if payload.FromContext != "prod" || payload.ToContext != "local" || payload.Reason != "inactive for 30m0s" {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+	if payload.Host == "" || payload.Timestamp.IsZero() {
+		t.Errorf("Payload should include the host and timestamp: %+v", payload)
+	}
+	if payload.Text != "Switched kubectl context from 'prod' to 'local' (inactive for 30m0s)" {
+		t.Errorf("Payload text = %q", payload.Text)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	if err := newWebhookNotifier(2, server.URL).NotifySwitch(SwitchEvent{}); err != nil {
+		t.Errorf("NotifySwitch() error = %v, want success on the last retry", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("Attempts = %d, want 3", got)
+	}
+
+	attempts.Store(0)
+	if err := newWebhookNotifier(1, server.URL).NotifySwitch(SwitchEvent{}); err == nil {
+		t.Error("Expected an error when retries run out")
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("Attempts = %d, want 2", got)
+	}
+}
+
+func TestWebhookClientErrorNotRetried(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := newWebhookNotifier(3, server.URL+"/hooks/secret-token").NotifySwitch(SwitchEvent{})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("NotifySwitch() error = %v, want a 404", err)
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Error should not include the webhook URL path: %v", err)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("Attempts = %d, want no retries for a client error", got)
+	}
+}
+
+func TestWebhookValidation(t *testing.T) {
+	valid := WebhookConfig{URLs: []string{"https://hooks.example.com/x"}, Timeout: time.Second, Retries: 2}
+	if errs := valid.validationErrors(); len(errs) != 0 {
+		t.Errorf("validationErrors() = %v, want none", errs)
+	}
+
+	invalid := WebhookConfig{URLs: []string{"ftp://example.com"}, Timeout: -time.Second, Retries: -1}
+	if errs := invalid.validationErrors(); len(errs) != 3 {
+		t.Errorf("validationErrors() = %v, want 3 problems", errs)
+	}
+}
@@ -0,0 +1,308 @@
+// Package kubectxtimeout is the public API for embedding kubectx-timeout's
+// timeout tracking in other tools, such as platform CLIs and TUIs, without
+// shelling out to the binary.
+//
+// The types are aliases of the implementation's, so values move freely
+// between this package and anything built on it. Only what is declared here
+// is supported; the implementation's other exported names may change
+// between releases.
+//
+// A minimal embedding loads the config, records activity as the user runs
+// commands, and runs the daemon loop, which switches to the default context
+// once the current one has been idle for its timeout:
+//
+//	config, err := kubectxtimeout.LoadConfig(kubectxtimeout.ConfigPath())
+//	...
+//	store, err := kubectxtimeout.NewStateManager(kubectxtimeout.StatePath())
+//	...
+//	daemon, err := kubectxtimeout.NewDaemon(kubectxtimeout.ConfigPath(), kubectxtimeout.StatePath(),
+//		kubectxtimeout.WithStateStore(store))
+//	...
+//	go daemon.Run()
+//	...
+//	_ = kubectxtimeout.RecordActivity(store, currentContext)
+package kubectxtimeout
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mrf/kubectx-timeout/internal"
+)
+
+// Configuration
+type (
+	// Config is the kubectx-timeout configuration
+	Config = internal.Config
+	// Context holds one context's settings in Config.Contexts
+	Context = internal.Context
+	// TimeoutConfig holds the global timeout settings
+	TimeoutConfig = internal.TimeoutConfig
+	// NotificationConfig holds the notification settings
+	NotificationConfig = internal.NotificationConfig
+	// SafetyConfig holds the safety checks made before switching
+	SafetyConfig = internal.SafetyConfig
+	// SwitcherConfig holds how failed context switches are retried
+	SwitcherConfig = internal.SwitcherConfig
+	// TrackingConfig holds what is recorded about kubectl activity
+	TrackingConfig = internal.TrackingConfig
+	// HistoryConfig holds the history log's retention
+	HistoryConfig = internal.HistoryConfig
+	// ProjectConfig is a repository's .kubectx-timeout.yaml, applied with
+	// Config.WithProject
+	ProjectConfig = internal.ProjectConfig
+	// ProjectContext holds one context's settings in ProjectConfig.Contexts
+	ProjectContext = internal.ProjectContext
+	// Profile is a named set of timeouts in Config.Profiles, applied with
+	// Config.WithProfile
+	Profile = internal.Profile
+	// KubeconfigPolicy holds one file's settings in Config.Kubeconfigs,
+	// applied with Config.ForKubeconfig
+	KubeconfigPolicy = internal.KubeconfigPolicy
+	// OrgPolicy is an organization's policy.yaml, enforced by LoadConfig
+	OrgPolicy = internal.OrgPolicy
+)
+
+// ConfigPath returns the default config file path,
+// ~/.config/kubectx-timeout/config.yaml unless XDG_CONFIG_HOME is set
+func ConfigPath() string {
+	return internal.GetConfigPath()
+}
+
+// StatePath returns the default state file path,
+// ~/.local/state/kubectx-timeout/state.json unless XDG_STATE_HOME is set
+func StatePath() string {
+	return internal.GetStatePath()
+}
+
+// PolicyPath returns where an organization installs its policy file
+func PolicyPath() string {
+	return internal.GetPolicyPath()
+}
+
+// DefaultConfig returns the configuration used when there is no config file
+func DefaultConfig() *Config {
+	return internal.DefaultConfig()
+}
+
+// LoadConfig loads and validates the config file at path, applying
+// environment overrides and the organization policy. A missing file yields
+// the defaults.
+func LoadConfig(path string) (*Config, error) {
+	return internal.LoadConfig(path)
+}
+
+// FindProjectConfig returns the .kubectx-timeout.yaml that applies to
+// commands run in dir, or "" if there is none
+func FindProjectConfig(dir string) string {
+	return internal.FindProjectConfig(dir)
+}
+
+// LoadProjectConfig reads and validates a project configuration
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	return internal.LoadProjectConfig(path)
+}
+
+// LoadOrgPolicy reads and validates a policy file, returning nil if there
+// is none
+func LoadOrgPolicy(path string) (*OrgPolicy, error) {
+	return internal.LoadOrgPolicy(path)
+}
+
+// ConfigSchema returns a JSON Schema of the config file, for editors
+func ConfigSchema() ([]byte, error) {
+	return internal.ConfigSchema()
+}
+
+// SaveConfig writes a config file that LoadConfig reads back as config
+func SaveConfig(path string, config *Config) error {
+	return internal.SaveConfig(path, config)
+}
+
+// Activity state
+type (
+	// State is the persisted activity state
+	State = internal.State
+	// StateStore persists kubectl activity. StateManager implements it
+	// with a JSON file; embedders may substitute their own, whose Update
+	// must save nothing when the function returns ErrStateUnchanged.
+	StateStore = internal.StateStore
+	// StateManager is the file-backed StateStore the binary uses
+	StateManager = internal.StateManager
+	// PendingSwitch is a switch waiting out the grace period
+	PendingSwitch = internal.PendingSwitch
+	// LastSwitch is the last automatic switch, which undo reverses
+	LastSwitch = internal.LastSwitch
+	// ActiveProfile is the profile chosen with the profile command
+	ActiveProfile = internal.ActiveProfile
+	// KubeconfigSession is the activity in shells using their own
+	// KUBECONFIG, such as kubie's
+	KubeconfigSession = internal.KubeconfigSession
+)
+
+// ErrStateUnchanged is returned by a StateStore's Update function when
+// there is nothing to save
+var ErrStateUnchanged = internal.ErrStateUnchanged
+
+// NewStateManager creates a state store backed by the file at path, which
+// is shared with the kubectx-timeout binary when it is the default path
+func NewStateManager(path string) (*StateManager, error) {
+	return internal.NewStateManager(path)
+}
+
+// RecordActivity records kubectl activity in context now in store
+func RecordActivity(store StateStore, context string) error {
+	return internal.RecordActivity(store, context)
+}
+
+// LastActivity returns the time and context of the last kubectl activity
+// in store
+func LastActivity(store StateStore) (time.Time, string, error) {
+	return internal.LastActivity(store)
+}
+
+// Context switching
+type (
+	// Switcher reads and switches the kubectl context. ContextSwitcher
+	// implements it with kubectl; embedders may substitute their own.
+	Switcher = internal.Switcher
+	// ContextSwitcher switches contexts with kubectl, retrying failures
+	ContextSwitcher = internal.ContextSwitcher
+	// KubeconfigSwitcher is a Switcher that can also act on per-shell
+	// kubeconfigs; the daemon only times those out with one
+	KubeconfigSwitcher = internal.KubeconfigSwitcher
+	// KubeClient reads and changes a kubeconfig's current context
+	KubeClient = internal.KubeClient
+	// ExecKubeClient is a KubeClient that runs kubectl
+	ExecKubeClient = internal.ExecKubeClient
+	// NativeKubeClient is a KubeClient that edits kubeconfig files directly
+	NativeKubeClient = internal.NativeKubeClient
+)
+
+// NewContextSwitcher creates a kubectl-based switcher. A nil logger
+// discards its output.
+func NewContextSwitcher(logger *slog.Logger) *ContextSwitcher {
+	return internal.NewContextSwitcher(logger)
+}
+
+// NewContextSwitcherWithClient creates a switcher that reads and changes
+// the kubeconfig through client. A nil logger discards its output.
+func NewContextSwitcherWithClient(logger *slog.Logger, client KubeClient) *ContextSwitcher {
+	return internal.NewContextSwitcherWithClient(logger, client)
+}
+
+// Watcher records a context change as activity whenever a kubeconfig file
+// changes
+type Watcher = internal.KubeconfigWatcher
+
+// NewWatcher creates a watcher of the kubeconfig files kubectl reads,
+// recording changes in store. Watch runs it until ctx is done. A nil logger
+// discards its output.
+func NewWatcher(ctx context.Context, store StateStore, logger *slog.Logger) (*Watcher, error) {
+	return internal.NewKubeconfigWatcher(store, logger, ctx)
+}
+
+// Notifications
+type (
+	// Notifier tells the user about switches, by desktop notification
+	// and/or a message in their terminals
+	Notifier = internal.Notifier
+	// SwitchEvent describes a context switch for notifications
+	SwitchEvent = internal.SwitchEvent
+	// NotificationBackend delivers notifications through one channel
+	NotificationBackend = internal.NotificationBackend
+	// Notification is a message for the user, with any action buttons
+	Notification = internal.Notification
+	// NotificationAction is a button on a notification
+	NotificationAction = internal.NotificationAction
+)
+
+// NewNotifier creates a notifier with the given settings
+func NewNotifier(config NotificationConfig) *Notifier {
+	return internal.NewNotifier(config)
+}
+
+// NewDesktopBackend returns the named desktop notification backend (macos,
+// linux, or windows), or the one for this platform if name is empty
+func NewDesktopBackend(name string) (NotificationBackend, error) {
+	return internal.NewDesktopBackend(name)
+}
+
+// The daemon
+type (
+	// Daemon watches for inactivity and switches to the default context
+	Daemon = internal.Daemon
+	// DaemonOption customizes a Daemon
+	DaemonOption = internal.DaemonOption
+)
+
+// NewDaemon creates a daemon using the given config and state files. Run
+// runs it until it is stopped.
+func NewDaemon(configPath, statePath string, opts ...DaemonOption) (*Daemon, error) {
+	return internal.NewDaemon(configPath, statePath, opts...)
+}
+
+// WithSwitcher makes the daemon switch contexts with s instead of kubectl
+func WithSwitcher(s Switcher) DaemonOption {
+	return internal.WithSwitcher(s)
+}
+
+// WithKubeClient makes the daemon's switcher use client instead of kubectl
+func WithKubeClient(client KubeClient) DaemonOption {
+	return internal.WithKubeClient(client)
+}
+
+// WithStateStore makes the daemon keep its state in s instead of the state
+// file
+func WithStateStore(s StateStore) DaemonOption {
+	return internal.WithStateStore(s)
+}
+
+// WithLogger makes the daemon log to logger
+func WithLogger(logger *slog.Logger) DaemonOption {
+	return internal.WithLogger(logger)
+}
+
+// Controlling a running daemon
+type (
+	// StatusSummary is the daemon's view of the current context and its
+	// deadline
+	StatusSummary = internal.StatusSummary
+	// ControlRequest is a command for a running daemon
+	ControlRequest = internal.ControlRequest
+	// ControlResponse is a running daemon's reply
+	ControlResponse = internal.ControlResponse
+)
+
+// Control commands
+const (
+	ControlStatus       = internal.ControlStatus
+	ControlPause        = internal.ControlPause
+	ControlResume       = internal.ControlResume
+	ControlReload       = internal.ControlReload
+	ControlForceSwitch  = internal.ControlForceSwitch
+	ControlCancelSwitch = internal.ControlCancelSwitch
+	ControlReset        = internal.ControlReset
+)
+
+// ErrControlUnavailable is returned by SendControlRequest when no daemon is
+// listening
+var ErrControlUnavailable = internal.ErrControlUnavailable
+
+// ControlSocketPath returns the control socket of a daemon using the state
+// file at statePath
+func ControlSocketPath(statePath string) string {
+	return internal.ControlSocketPathForState(statePath)
+}
+
+// SendControlRequest sends a request to the daemon listening at socketPath
+func SendControlRequest(socketPath string, req ControlRequest) (*ControlResponse, error) {
+	return internal.SendControlRequest(socketPath, req)
+}
+
+// ReadStatusSummary reads the status summary a daemon using the state file
+// at statePath publishes, without contacting the daemon
+func ReadStatusSummary(statePath string) (*StatusSummary, error) {
+	return internal.ReadStatusSummary(internal.StatusSummaryPathForState(statePath))
+}
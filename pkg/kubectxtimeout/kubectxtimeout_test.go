@@ -0,0 +1,90 @@
+package kubectxtimeout_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mrf/kubectx-timeout/pkg/kubectxtimeout"
+)
+
+// fakeSwitcher shows that embedders can supply their own Switcher
+type fakeSwitcher struct {
+	current string
+}
+
+func (f *fakeSwitcher) CurrentContext() (string, error) {
+	return f.current, nil
+}
+
+func (f *fakeSwitcher) SwitchContextSafe(target string, _ func(string) bool) error {
+	f.current = target
+	return nil
+}
+
+var _ kubectxtimeout.Switcher = (*fakeSwitcher)(nil)
+
+func TestConfigRoundTrip(t *testing.T) {
+	config := kubectxtimeout.DefaultConfig()
+	config.DefaultContext = "local"
+	config.Contexts = map[string]kubectxtimeout.Context{"prod": {Timeout: 5 * time.Minute}}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := kubectxtimeout.SaveConfig(path, config); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+	loaded, err := kubectxtimeout.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if got := loaded.GetTimeoutForContext("prod"); got != 5*time.Minute {
+		t.Errorf("GetTimeoutForContext(prod) = %v, want 5m", got)
+	}
+}
+
+func TestStateManager(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	store, err := kubectxtimeout.NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager() error = %v", err)
+	}
+	if err := kubectxtimeout.RecordActivity(store, "prod"); err != nil {
+		t.Fatalf("RecordActivity() error = %v", err)
+	}
+
+	_, context, err := kubectxtimeout.LastActivity(store)
+	if err != nil || context != "prod" {
+		t.Errorf("LastActivity() = %q, %v; want prod", context, err)
+	}
+}
+
+func TestDaemonWithFakes(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := kubectxtimeout.DefaultConfig()
+	config.DefaultContext = "local"
+	if err := kubectxtimeout.SaveConfig(configPath, config); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+
+	statePath := filepath.Join(dir, "state.json")
+	store, err := kubectxtimeout.NewStateManager(statePath)
+	if err != nil {
+		t.Fatalf("NewStateManager() error = %v", err)
+	}
+
+	daemon, err := kubectxtimeout.NewDaemon(configPath, statePath,
+		kubectxtimeout.WithSwitcher(&fakeSwitcher{current: "prod"}),
+		kubectxtimeout.WithStateStore(store))
+	if err != nil || daemon == nil {
+		t.Fatalf("NewDaemon() = %v, %v", daemon, err)
+	}
+
+	// No daemon is running, so control requests report it unavailable
+	_, err = kubectxtimeout.SendControlRequest(kubectxtimeout.ControlSocketPath(statePath),
+		kubectxtimeout.ControlRequest{Command: kubectxtimeout.ControlStatus})
+	if !errors.Is(err, kubectxtimeout.ErrControlUnavailable) {
+		t.Errorf("SendControlRequest() error = %v, want ErrControlUnavailable", err)
+	}
+}